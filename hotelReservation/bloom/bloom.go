@@ -0,0 +1,67 @@
+// Package bloom implements a Bloom filter of strings, which tells keys that
+// were definitely never added from those that may have been.
+package bloom
+
+import (
+	"hash/fnv"
+	"math"
+)
+
+// Filter is a Bloom filter sized for an expected number of keys and false
+// positive rate. It is safe for concurrent use once the keys are added, but
+// Add must not run concurrently with other calls.
+type Filter struct {
+	bits []uint64
+	m    uint64
+	k    int
+}
+
+// New returns a filter for n keys, 1 if less, giving false positives with
+// probability p, clamped between 1e-9 and 0.5.
+func New(n int, p float64) *Filter {
+	if n < 1 {
+		n = 1
+	}
+	p = math.Min(math.Max(p, 1e-9), 0.5)
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	if m < 64 {
+		m = 64
+	}
+	k := int(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &Filter{bits: make([]uint64, (m+63)/64), m: m, k: k}
+}
+
+// Add adds key to f.
+func (f *Filter) Add(key string) {
+	h1, h2 := hashes(key)
+	for i := 0; i < f.k; i++ {
+		bit := (h1 + uint64(i)*h2) % f.m
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// MayContain reports whether key may have been added to f. It is false only
+// if key was never added.
+func (f *Filter) MayContain(key string) bool {
+	h1, h2 := hashes(key)
+	for i := 0; i < f.k; i++ {
+		bit := (h1 + uint64(i)*h2) % f.m
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// hashes returns the two hashes of key the k bit positions are derived from,
+// the second odd so that it is never zero.
+func hashes(key string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	h1 := h.Sum64()
+	h.Write([]byte{0})
+	return h1, h.Sum64() | 1
+}
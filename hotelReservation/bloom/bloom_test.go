@@ -0,0 +1,50 @@
+package bloom
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFilterNoFalseNegatives(t *testing.T) {
+	f := New(1000, 0.01)
+	for i := 0; i < 1000; i++ {
+		f.Add(fmt.Sprintf("hotel-%d", i))
+	}
+	for i := 0; i < 1000; i++ {
+		if key := fmt.Sprintf("hotel-%d", i); !f.MayContain(key) {
+			t.Errorf("MayContain(%q) = false for an added key", key)
+		}
+	}
+}
+
+func TestFilterFalsePositiveRate(t *testing.T) {
+	for _, p := range []float64{0.1, 0.01, 0.001} {
+		f := New(2000, p)
+		for i := 0; i < 2000; i++ {
+			f.Add(fmt.Sprintf("hotel-%d", i))
+		}
+		positives := 0
+		const absent = 50000
+		for i := 0; i < absent; i++ {
+			if f.MayContain(fmt.Sprintf("absent-%d", i)) {
+				positives++
+			}
+		}
+		if rate := float64(positives) / absent; rate > 2*p {
+			t.Errorf("rate %v: got %v false positives", p, rate)
+		}
+	}
+}
+
+func TestFilterEmpty(t *testing.T) {
+	for _, n := range []int{-1, 0} {
+		f := New(n, 0)
+		if f.MayContain("hotel-1") {
+			t.Errorf("New(%d, 0): empty filter may contain hotel-1", n)
+		}
+		f.Add("hotel-1")
+		if !f.MayContain("hotel-1") {
+			t.Errorf("New(%d, 0): filter rules out an added key", n)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// LRU is a least recently used cache of protobuf messages holding up to max
+// entries for ttl each. Messages are copied in and out, so callers are free
+// to modify them.
+type LRU struct {
+	mu      sync.Mutex
+	max     int
+	ttl     time.Duration
+	lru     *list.List // of *entry, most recently used first
+	entries map[string]*list.Element
+}
+
+type entry struct {
+	key     string
+	msg     proto.Message
+	expires time.Time
+}
+
+// New returns a cache of max entries kept for ttl, nil if either is zero or
+// less, which disables caching.
+func New(max int, ttl time.Duration) *LRU {
+	if max <= 0 || ttl <= 0 {
+		return nil
+	}
+	return &LRU{
+		max:     max,
+		ttl:     ttl,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Get returns a copy of the message cached for key at now, if any.
+func (c *LRU) Get(key string, now time.Time) (proto.Message, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*entry)
+	if !now.Before(e.expires) {
+		c.lru.Remove(el)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	return proto.Clone(e.msg), true
+}
+
+// Put caches a copy of msg for key from now, evicting the least recently
+// used entry if the cache is full.
+func (c *LRU) Put(key string, msg proto.Message, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := &entry{key: key, msg: proto.Clone(msg), expires: now.Add(c.ttl)}
+	if el, ok := c.entries[key]; ok {
+		el.Value = e
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(e)
+	for c.lru.Len() > c.max {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*entry).key)
+	}
+}
@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// get returns the string cached for key at now, if any.
+func get(c *LRU, key string, now time.Time) (string, bool) {
+	msg, ok := c.Get(key, now)
+	if !ok {
+		return "", false
+	}
+	return msg.(*wrapperspb.StringValue).Value, true
+}
+
+func TestLRUHitMiss(t *testing.T) {
+	c := New(10, time.Minute)
+	now := time.Now()
+	if _, ok := get(c, "a", now); ok {
+		t.Error("hit in an empty cache")
+	}
+	c.Put("a", wrapperspb.String("first"), now)
+	if v, ok := get(c, "a", now); !ok || v != "first" {
+		t.Errorf("got %q, %v, want a hit of first", v, ok)
+	}
+	if _, ok := get(c, "b", now); ok {
+		t.Error("hit of a key never put")
+	}
+
+	c.Put("a", wrapperspb.String("second"), now)
+	if v, _ := get(c, "a", now); v != "second" {
+		t.Errorf("got %q after replacing it, want second", v)
+	}
+}
+
+func TestLRUExpiry(t *testing.T) {
+	c := New(10, time.Minute)
+	now := time.Now()
+	c.Put("a", wrapperspb.String("first"), now)
+
+	if _, ok := get(c, "a", now.Add(time.Minute-time.Nanosecond)); !ok {
+		t.Error("miss before the TTL")
+	}
+	if _, ok := get(c, "a", now.Add(time.Minute)); ok {
+		t.Error("hit once the TTL is over")
+	}
+	// the expired entry is gone, not only hidden
+	if _, ok := get(c, "a", now); ok {
+		t.Error("expired entry still cached")
+	}
+
+	// putting again starts a new TTL
+	c.Put("a", wrapperspb.String("first"), now.Add(time.Hour))
+	if _, ok := get(c, "a", now.Add(time.Hour+30*time.Second)); !ok {
+		t.Error("miss within the TTL of the new entry")
+	}
+}
+
+func TestLRUEviction(t *testing.T) {
+	c := New(2, time.Minute)
+	now := time.Now()
+	c.Put("a", wrapperspb.String("a"), now)
+	c.Put("b", wrapperspb.String("b"), now)
+	// a becomes the most recently used
+	get(c, "a", now)
+	c.Put("c", wrapperspb.String("c"), now)
+
+	if _, ok := get(c, "b", now); ok {
+		t.Error("least recently used entry kept")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := get(c, key, now); !ok {
+			t.Errorf("entry %s evicted", key)
+		}
+	}
+}
+
+func TestLRUCopies(t *testing.T) {
+	c := New(10, time.Minute)
+	now := time.Now()
+	msg := wrapperspb.String("original")
+	c.Put("a", msg, now)
+	msg.Value = "changed after Put"
+
+	got, _ := c.Get("a", now)
+	got.(*wrapperspb.StringValue).Value = "changed after Get"
+	if v, _ := get(c, "a", now); v != "original" {
+		t.Errorf("got %q, want the cached copy untouched", v)
+	}
+	if !proto.Equal(msg, wrapperspb.String("changed after Put")) {
+		t.Errorf("caller's message changed to %v", msg)
+	}
+}
+
+func TestNewDisabled(t *testing.T) {
+	if c := New(0, time.Minute); c != nil {
+		t.Error("cache of no entries enabled")
+	}
+	if c := New(10, 0); c != nil {
+		t.Error("cache of no TTL enabled")
+	}
+}
@@ -3,9 +3,10 @@ package main
 import (
 	"context"
 	"fmt"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/datagen"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
 	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type Restaurant struct {
@@ -60,11 +61,17 @@ func initializeDatabase(url string) (*mongo.Client, func()) {
 		&Museum{"6", 37.3867, -122.5012, "M6", "technology"},
 	}
 
+	if n := tune.GetDatagenHotels(); n > 0 {
+		newPoints = nil
+		for _, h := range datagen.Generate(tune.GetDatagenSeed(), n) {
+			newPoints = append(newPoints, point{h.Id, h.Lat, h.Lon})
+		}
+	}
+
 	uri := fmt.Sprintf("mongodb://%s", url)
 	log.Info().Msgf("Attempting connection to %v", uri)
 
-	opts := options.Client().ApplyURI(uri)
-	client, err := mongo.Connect(context.TODO(), opts)
+	client, err := tune.NewMongoClient(uri)
 	if err != nil {
 		log.Panic().Msg(err.Error())
 	}
@@ -7,21 +7,18 @@ import (
 	"os"
 	"strconv"
 
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/graceful"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/registry"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/attractions"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
 
-	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
-
-	"time"
 	// "github.com/bradfitz/gomemcache/memcache"
 )
 
 func main() {
 	tune.Init()
-	log.Logger = zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}).With().Timestamp().Caller().Logger()
 
 	log.Info().Msg("Reading config...")
 	jsonFile, err := os.Open("config.json")
@@ -52,6 +49,7 @@ func main() {
 
 	serv_port, _ := strconv.Atoi(result["AttractionsPort"])
 	serv_ip := result["AttractionsIP"]
+	metricsPort, _ := strconv.Atoi(result["AttractionsMetricsPort"])
 	log.Info().Msgf("Read target port: %v", serv_port)
 	log.Info().Msgf("Read consul address: %v", result["consulAddress"])
 	log.Info().Msgf("Read jaeger address: %v", result["jaegerAddress"])
@@ -64,7 +62,11 @@ func main() {
 	flag.Parse()
 
 	log.Info().Msgf("Initializing jaeger agent [service name: %v | host: %v]...", "attractions", *jaegeraddr)
-	tracer, err := tracing.Init("attractions", *jaegeraddr)
+	sampler, err := tracing.ParseSampler(result["AttractionsSamplerType"], result["AttractionsSamplerParam"])
+	if err != nil {
+		log.Panic().Msgf("Got error while reading sampler config: %v", err)
+	}
+	tracer, err := tracing.InitWithSampler("attractions", *jaegeraddr, sampler)
 	if err != nil {
 		log.Panic().Msgf("Got error while initializing jaeger agent: %v", err)
 	}
@@ -82,10 +84,13 @@ func main() {
 		// Port:     *port,
 		Registry:    registry,
 		Port:        serv_port,
+		MetricsPort: metricsPort,
 		IpAddr:      serv_ip,
 		MongoClient: mongo_session,
 	}
 
 	log.Info().Msg("Starting server...")
-	log.Fatal().Msg(srv.Run().Error())
+	if err := graceful.Serve(srv.Run, srv.Shutdown); err != nil {
+		log.Fatal().Msg(err.Error())
+	}
 }
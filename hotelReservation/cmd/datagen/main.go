@@ -0,0 +1,35 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/datagen"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
+	"github.com/rs/zerolog/log"
+)
+
+// datagen prints the hotels the services seed their database with given the
+// same DATAGEN_HOTELS and DATAGEN_SEED, e.g. to drive a workload with their
+// ids and coordinates.
+func main() {
+	log.Logger = tune.NewLogger(os.Stderr)
+
+	var (
+		hotels = flag.Int("hotels", 80, "Number of hotels")
+		seed   = flag.Int64("seed", 1, "Random seed")
+	)
+	flag.Parse()
+
+	dataset := datagen.Generate(*seed, *hotels)
+	if err := datagen.Validate(dataset); err != nil {
+		log.Fatal().Msgf("Generated an inconsistent dataset: %v", err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(dataset); err != nil {
+		log.Fatal().Msgf("Failed to write the dataset: %v", err)
+	}
+}
@@ -1,24 +1,28 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"strconv"
 	"time"
 
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/graceful"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/registry"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/frontend"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
-	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
+// selfTestTimeout bounds the whole self-test.
+const selfTestTimeout = 30 * time.Second
+
 func main() {
 	tune.Init()
-	log.Logger = zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}).With().Timestamp().Caller().Logger()
 
 	log.Info().Msg("Reading config...")
 	jsonFile, err := os.Open("config.json")
@@ -39,10 +43,15 @@ func main() {
 	var (
 		jaegerAddr = flag.String("jaegeraddr", result["jaegerAddress"], "Jaeger address")
 		consulAddr = flag.String("consuladdr", result["consulAddress"], "Consul address")
+		selfTest   = flag.Bool("selftest", false, "Run a search, profile, rate and reservation flow against the backends, print the outcome of each step and exit, with status 1 if one failed")
 	)
 	flag.Parse()
 	log.Info().Msgf("Initializing jaeger agent [service name: %v | host: %v]...", "frontend", *jaegerAddr)
-	tracer, err := tracing.Init("frontend", *jaegerAddr)
+	sampler, err := tracing.ParseSampler(result["FrontendSamplerType"], result["FrontendSamplerParam"])
+	if err != nil {
+		log.Panic().Msgf("Got error while reading sampler config: %v", err)
+	}
+	tracer, err := tracing.InitWithSampler("frontend", *jaegerAddr, sampler)
 	if err != nil {
 		log.Panic().Msgf("Got error while initializing jaeger agent: %v", err)
 	}
@@ -56,14 +65,37 @@ func main() {
 	log.Info().Msg("Consul agent initialized")
 
 	srv := &frontend.Server{
-		KnativeDns: knativeDNS,
-		Registry:   registry,
-		Tracer:     tracer,
-		IpAddr:     servIP,
-		ConsulAddr: *consulAddr,
-		Port:       servPort,
+		KnativeDns:        knativeDNS,
+		Registry:          registry,
+		Tracer:            tracer,
+		IpAddr:            servIP,
+		ConsulAddr:        *consulAddr,
+		Port:              servPort,
+		GrpcPoolSize:      tune.GetGrpcPoolSize(),
+		RequestBudget:     time.Duration(tune.GetRequestBudget()) * time.Millisecond,
+		BudgetSlice:       float64(tune.GetBudgetWarnPercent()) / 100,
+		TraceSampleTokens: tune.GetTraceSampleTokens(),
+		Timeouts:          tune.GetDependencyTimeouts(),
+		HedgeDelay:        time.Duration(tune.GetHedgeDelay()) * time.Millisecond,
+		MaxHedges:         tune.GetMaxHedges(),
+	}
+
+	if *selfTest {
+		if err := srv.InitClients(); err != nil {
+			log.Fatal().Msgf("Self-test failed to dial the backends: %v", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), selfTestTimeout)
+		report := srv.SelfTest(ctx)
+		cancel()
+		fmt.Print(report)
+		if !report.OK() {
+			os.Exit(1)
+		}
+		return
 	}
 
 	log.Info().Msg("Starting server...")
-	log.Fatal().Msg(srv.Run().Error())
+	if err := graceful.Serve(srv.Run, srv.Shutdown); err != nil {
+		log.Fatal().Msg(err.Error())
+	}
 }
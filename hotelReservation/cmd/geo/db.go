@@ -5,27 +5,35 @@ import (
 	"fmt"
 	"strconv"
 
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/datagen"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
 	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type point struct {
 	Pid  string  `bson:"hotelId"`
 	Plat float64 `bson:"lat"`
 	Plon float64 `bson:"lon"`
+	// Region is the region tag of the hotel, see datagen.Region.
+	Region string `bson:"region"`
+}
+
+// newPoint returns the point of a hotel, tagged with the region it lies in.
+func newPoint(hotelID string, lat, lon float64) point {
+	return point{hotelID, lat, lon, datagen.Region(lat, lon)}
 }
 
 func initializeDatabase(url string) (*mongo.Client, func()) {
 	log.Info().Msg("Generating test data...")
 
 	newPoints := []interface{}{
-		point{"1", 37.7867, -122.4112},
-		point{"2", 37.7854, -122.4005},
-		point{"3", 37.7854, -122.4071},
-		point{"4", 37.7936, -122.3930},
-		point{"5", 37.7831, -122.4181},
-		point{"6", 37.7863, -122.4015},
+		newPoint("1", 37.7867, -122.4112),
+		newPoint("2", 37.7854, -122.4005),
+		newPoint("3", 37.7854, -122.4071),
+		newPoint("4", 37.7936, -122.3930),
+		newPoint("5", 37.7831, -122.4181),
+		newPoint("6", 37.7863, -122.4015),
 	}
 
 	for i := 7; i <= 80; i++ {
@@ -33,14 +41,20 @@ func initializeDatabase(url string) (*mongo.Client, func()) {
 		lat := 37.7835 + float64(i)/500.0*3
 		lon := -122.41 + float64(i)/500.0*4
 
-		newPoints = append(newPoints, point{hotelID, lat, lon})
+		newPoints = append(newPoints, newPoint(hotelID, lat, lon))
+	}
+
+	if n := tune.GetDatagenHotels(); n > 0 {
+		newPoints = nil
+		for _, h := range datagen.Generate(tune.GetDatagenSeed(), n) {
+			newPoints = append(newPoints, point{h.Id, h.Lat, h.Lon, h.Region})
+		}
 	}
 
 	uri := fmt.Sprintf("mongodb://%s", url)
 	log.Info().Msgf("Attempting connection to %v", uri)
 
-	opts := options.Client().ApplyURI(uri)
-	client, err := mongo.Connect(context.TODO(), opts)
+	client, err := tune.NewMongoClient(uri)
 	if err != nil {
 		log.Panic().Msg(err.Error())
 	}
@@ -8,17 +8,16 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/graceful"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/registry"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/geo"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
-	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
 func main() {
 	tune.Init()
-	log.Logger = zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}).With().Timestamp().Caller().Logger()
 
 	log.Info().Msg("Reading config...")
 	jsonFile, err := os.Open("config.json")
@@ -39,6 +38,7 @@ func main() {
 
 	servPort, _ := strconv.Atoi(result["GeoPort"])
 	servIP := result["GeoIP"]
+	metricsPort, _ := strconv.Atoi(result["GeoMetricsPort"])
 
 	var (
 		jaegerAddr = flag.String("jaegeraddr", result["jaegerAddress"], "Jaeger address")
@@ -47,7 +47,11 @@ func main() {
 	flag.Parse()
 
 	log.Info().Msgf("Initializing jaeger agent [service name: %v | host: %v]...", "geo", *jaegerAddr)
-	tracer, err := tracing.Init("geo", *jaegerAddr)
+	sampler, err := tracing.ParseSampler(result["GeoSamplerType"], result["GeoSamplerParam"])
+	if err != nil {
+		log.Panic().Msgf("Got error while reading sampler config: %v", err)
+	}
+	tracer, err := tracing.InitWithSampler("geo", *jaegerAddr, sampler)
 	if err != nil {
 		log.Panic().Msgf("Got error while initializing jaeger agent: %v", err)
 	}
@@ -61,13 +65,18 @@ func main() {
 	log.Info().Msg("Consul agent initialized")
 
 	srv := &geo.Server{
-		Port:        servPort,
-		IpAddr:      servIP,
-		Tracer:      tracer,
-		Registry:    registry,
-		MongoClient: mongoClient,
+		Port:           servPort,
+		MetricsPort:    metricsPort,
+		IpAddr:         servIP,
+		Tracer:         tracer,
+		Registry:       registry,
+		MongoClient:    mongoClient,
+		ReloadInterval: time.Duration(tune.GetGeoReloadInterval()) * time.Second,
+		AdminAllowlist: tune.GetAdminAllowlist(),
 	}
 
 	log.Info().Msg("Starting server...")
-	log.Fatal().Msg(srv.Run().Error())
+	if err := graceful.Serve(srv.Run, srv.Shutdown); err != nil {
+		log.Fatal().Msg(err.Error())
+	}
 }
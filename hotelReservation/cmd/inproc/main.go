@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/graceful"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/inproc"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
+	"github.com/rs/zerolog/log"
+)
+
+// inproc runs all the services in one process, over in-memory stores, with
+// the frontend serving on the given port.
+func main() {
+	tune.Init()
+
+	var (
+		port   = flag.Int("port", 5000, "Frontend port")
+		hotels = flag.Int("hotels", 80, "Number of hotels")
+		seed   = flag.Int64("seed", 1, "Random seed of the hotels")
+	)
+	flag.Parse()
+
+	cluster, err := inproc.Start(inproc.Options{
+		Hotels:       *hotels,
+		Seed:         *seed,
+		FrontendPort: *port,
+	})
+	if err != nil {
+		log.Fatal().Msgf("Failed to start the services: %v", err)
+	}
+	defer cluster.Close()
+
+	log.Info().Msgf("Serving on %s", cluster.Frontend)
+	err = graceful.Serve(cluster.Wait, cluster.Close)
+	if err != nil {
+		log.Fatal().Msg(err.Error())
+	}
+}
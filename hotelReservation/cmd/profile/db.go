@@ -5,9 +5,10 @@ import (
 	"fmt"
 	"strconv"
 
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/datagen"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
 	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type Hotel struct {
@@ -16,6 +17,7 @@ type Hotel struct {
 	PhoneNumber string   `bson:"phoneNumber"`
 	Description string   `bson:"description"`
 	Address     *Address `bson:"address"`
+	Stars       float32  `bson:"stars"`
 }
 
 type Address struct {
@@ -48,6 +50,7 @@ func initializeDatabase(url string) (*mongo.Client, func()) {
 				37.7867,
 				-122.4112,
 			},
+			4.5,
 		},
 		Hotel{
 			"2",
@@ -64,6 +67,7 @@ func initializeDatabase(url string) (*mongo.Client, func()) {
 				37.7854,
 				-122.4005,
 			},
+			4,
 		},
 		Hotel{
 			"3",
@@ -80,6 +84,7 @@ func initializeDatabase(url string) (*mongo.Client, func()) {
 				37.7834,
 				-122.4071,
 			},
+			4,
 		},
 		Hotel{
 			"4",
@@ -96,6 +101,7 @@ func initializeDatabase(url string) (*mongo.Client, func()) {
 				37.7936,
 				-122.3930,
 			},
+			4.5,
 		},
 		Hotel{
 			"5",
@@ -112,6 +118,7 @@ func initializeDatabase(url string) (*mongo.Client, func()) {
 				37.7831,
 				-122.4181,
 			},
+			3,
 		},
 		Hotel{
 			"6",
@@ -128,6 +135,7 @@ func initializeDatabase(url string) (*mongo.Client, func()) {
 				37.7863,
 				-122.4015,
 			},
+			5,
 		},
 	}
 
@@ -155,15 +163,41 @@ func initializeDatabase(url string) (*mongo.Client, func()) {
 					lat,
 					lon,
 				},
+				float32(3 + i%3),
 			},
 		)
 	}
 
+	if n := tune.GetDatagenHotels(); n > 0 {
+		newProfiles = nil
+		for _, h := range datagen.Generate(tune.GetDatagenSeed(), n) {
+			newProfiles = append(
+				newProfiles,
+				Hotel{
+					h.Id,
+					h.Name,
+					h.PhoneNumber,
+					h.Description,
+					&Address{
+						h.Number,
+						h.Street,
+						h.City,
+						h.State,
+						h.Country,
+						h.PostalCode,
+						float32(h.Lat),
+						float32(h.Lon),
+					},
+					h.Stars,
+				},
+			)
+		}
+	}
+
 	uri := fmt.Sprintf("mongodb://%s", url)
 	log.Info().Msgf("Attempting connection to %v", uri)
 
-	opts := options.Client().ApplyURI(uri)
-	client, err := mongo.Connect(context.TODO(), opts)
+	client, err := tune.NewMongoClient(uri)
 	if err != nil {
 		log.Panic().Msg(err.Error())
 	}
@@ -8,17 +8,18 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/graceful"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/registry"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/profile"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
-	"github.com/rs/zerolog"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/warmup"
+	"github.com/opentracing/opentracing-go"
 	"github.com/rs/zerolog/log"
 )
 
 func main() {
 	tune.Init()
-	log.Logger = zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}).With().Timestamp().Caller().Logger()
 
 	log.Info().Msg("Reading config...")
 	jsonFile, err := os.Open("config.json")
@@ -40,10 +41,12 @@ func main() {
 	log.Info().Msgf("Read profile memcashed address: %v", result["ProfileMemcAddress"])
 	log.Info().Msg("Initializing Memcashed client...")
 	memcClient := tune.NewMemCClient2(result["ProfileMemcAddress"])
+	defer memcClient.Close()
 	log.Info().Msg("Success")
 
 	servPort, _ := strconv.Atoi(result["ProfilePort"])
 	servIP := result["ProfileIP"]
+	metricsPort, _ := strconv.Atoi(result["ProfileMetricsPort"])
 
 	var (
 		jaegerAddr = flag.String("jaegeraddr", result["jaegerAddress"], "Jaeger address")
@@ -52,10 +55,16 @@ func main() {
 	flag.Parse()
 
 	log.Info().Msgf("Initializing jaeger agent [service name: %v | host: %v]...", "profile", *jaegerAddr)
-	tracer, err := tracing.Init("profile", *jaegerAddr)
+	sampler, err := tracing.ParseSampler(result["ProfileSamplerType"], result["ProfileSamplerParam"])
+	if err != nil {
+		log.Panic().Msgf("Got error while reading sampler config: %v", err)
+	}
+	tracer, err := tracing.InitWithSampler("profile", *jaegerAddr, sampler)
 	if err != nil {
 		log.Panic().Msgf("Got error while initializing jaeger agent: %v", err)
 	}
+	// the server spans its memcached and MongoDB calls with the global tracer
+	opentracing.SetGlobalTracer(tracer)
 	log.Info().Msg("Jaeger agent initialized")
 
 	log.Info().Msgf("Initializing consul agent [host: %v]...", *consulAddr)
@@ -66,14 +75,24 @@ func main() {
 	log.Info().Msg("Consul agent initialized")
 
 	srv := &profile.Server{
-		Port:        servPort,
-		IpAddr:      servIP,
-		Tracer:      tracer,
-		Registry:    registry,
-		MongoClient: mongoClient,
-		MemcClient:  memcClient,
+		Port:           servPort,
+		MetricsPort:    metricsPort,
+		IpAddr:         servIP,
+		Tracer:         tracer,
+		Registry:       registry,
+		MongoClient:    mongoClient,
+		MemcClient:     memcClient,
+		MemcTTL:        int32(tune.GetMemCTTL()),
+		NegativeTTL:    int32(tune.GetMemCNegativeTTL()),
+		MongoFanout:    tune.GetMongoFanout(),
+		BloomFPRate:    tune.GetBloomFPRate(),
+		BloomRefresh:   time.Duration(tune.GetBloomRefresh()) * time.Second,
+		Warmup:         warmup.Config{Mode: tune.GetWarmup(), Concurrency: tune.GetWarmupConcurrency()},
+		AdminAllowlist: tune.GetAdminAllowlist(),
 	}
 
 	log.Info().Msg("Starting server...")
-	log.Fatal().Msg(srv.Run().Error())
+	if err := graceful.Serve(srv.Run, srv.Shutdown); err != nil {
+		log.Fatal().Msg(err.Error())
+	}
 }
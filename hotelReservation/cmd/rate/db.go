@@ -5,9 +5,10 @@ import (
 	"fmt"
 	"strconv"
 
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/datagen"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
 	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type RoomType struct {
@@ -18,6 +19,13 @@ type RoomType struct {
 	TotalRateInclusive float64 `bson:"totalRateInclusive"`
 }
 
+type NightlyRate struct {
+	HotelId string  `bson:"hotelId"`
+	Code    string  `bson:"code"`
+	Date    string  `bson:"date"`
+	Rate    float64 `bson:"rate"`
+}
+
 type RatePlan struct {
 	HotelId  string    `bson:"hotelId"`
 	Code     string    `bson:"code"`
@@ -119,11 +127,34 @@ func initializeDatabase(url string) (*mongo.Client, func()) {
 		)
 	}
 
+	generated := false
+	if n := tune.GetDatagenHotels(); n > 0 {
+		generated = true
+		newRatePlans = nil
+		for _, h := range datagen.Generate(tune.GetDatagenSeed(), n) {
+			newRatePlans = append(
+				newRatePlans,
+				RatePlan{
+					h.Id,
+					"RACK",
+					h.InDate,
+					h.OutDate,
+					&RoomType{
+						h.Rate,
+						h.RoomCode,
+						h.RoomDescription,
+						h.Rate,
+						h.RateInclusive,
+					},
+				},
+			)
+		}
+	}
+
 	uri := fmt.Sprintf("mongodb://%s", url)
 	log.Info().Msgf("Attempting connection to %v", uri)
 
-	opts := options.Client().ApplyURI(uri)
-	client, err := mongo.Connect(context.TODO(), opts)
+	client, err := tune.NewMongoClient(uri)
 	if err != nil {
 		log.Panic().Msg(err.Error())
 	}
@@ -136,6 +167,30 @@ func initializeDatabase(url string) (*mongo.Client, func()) {
 	}
 	log.Info().Msg("Successfully inserted test data into rate DB")
 
+	// Weekend nights of the first hotels are priced above their total rate.
+	newNightlyRates := []interface{}{
+		NightlyRate{"1", "RACK", "2015-04-10", 129.00},
+		NightlyRate{"1", "RACK", "2015-04-11", 139.00},
+		NightlyRate{"2", "RACK", "2015-04-10", 159.00},
+		NightlyRate{"2", "RACK", "2015-04-11", 169.00},
+		NightlyRate{"3", "RACK", "2015-04-10", 129.00},
+		NightlyRate{"3", "RACK", "2015-04-11", 139.00},
+	}
+
+	// generated hotels have no such nights, their rates are set from scratch
+	if generated {
+		newNightlyRates = nil
+	}
+
+	collection = client.Database("rate-db").Collection("nightly")
+	if len(newNightlyRates) > 0 {
+		_, err = collection.InsertMany(context.TODO(), newNightlyRates)
+		if err != nil {
+			log.Fatal().Msg(err.Error())
+		}
+		log.Info().Msg("Successfully inserted nightly rate data into rate DB")
+	}
+
 	return client, func() {
 		if err := client.Disconnect(context.TODO()); err != nil {
 			log.Fatal().Msg(err.Error())
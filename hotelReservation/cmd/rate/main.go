@@ -7,19 +7,18 @@ import (
 	"os"
 	"strconv"
 
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/graceful"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/registry"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/rate"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
-	"github.com/rs/zerolog"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/warmup"
+	"github.com/opentracing/opentracing-go"
 	"github.com/rs/zerolog/log"
-
-	"time"
 )
 
 func main() {
 	tune.Init()
-	log.Logger = zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}).With().Timestamp().Caller().Logger()
 
 	log.Info().Msg("Reading config...")
 	jsonFile, err := os.Open("config.json")
@@ -41,10 +40,24 @@ func main() {
 	log.Info().Msgf("Read profile memcashed address: %v", result["RateMemcAddress"])
 	log.Info().Msg("Initializing Memcashed client...")
 	memcClient := tune.NewMemCClient2(result["RateMemcAddress"])
+	defer memcClient.Close()
 	log.Info().Msg("Success")
 
+	exchangeRates, err := rate.ParseExchangeRates(result["RateExchangeRates"])
+	if err != nil {
+		log.Panic().Msgf("Got error while parsing exchange rates: %v", err)
+	}
+	log.Info().Msgf("Loaded exchange rates: %v", exchangeRates)
+
+	surge, err := rate.ParseSurge(result["RateSurge"])
+	if err != nil {
+		log.Panic().Msgf("Got error while parsing surge multipliers: %v", err)
+	}
+	log.Info().Msgf("Loaded surge multipliers: %v", surge)
+
 	servPort, _ := strconv.Atoi(result["RatePort"])
 	servIP := result["RateIP"]
+	metricsPort, _ := strconv.Atoi(result["RateMetricsPort"])
 
 	var (
 		jaegerAddr = flag.String("jaegeraddr", result["jaegerAddress"], "Jaeger address")
@@ -53,10 +66,16 @@ func main() {
 	flag.Parse()
 
 	log.Info().Msgf("Initializing jaeger agent [service name: %v | host: %v]...", "rate", *jaegerAddr)
-	tracer, err := tracing.Init("rate", *jaegerAddr)
+	sampler, err := tracing.ParseSampler(result["RateSamplerType"], result["RateSamplerParam"])
+	if err != nil {
+		log.Panic().Msgf("Got error while reading sampler config: %v", err)
+	}
+	tracer, err := tracing.InitWithSampler("rate", *jaegerAddr, sampler)
 	if err != nil {
 		log.Panic().Msgf("Got error while initializing jaeger agent: %v", err)
 	}
+	// the server spans its memcached and MongoDB calls with the global tracer
+	opentracing.SetGlobalTracer(tracer)
 	log.Info().Msg("Jaeger agent initialized")
 
 	log.Info().Msgf("Initializing consul agent [host: %v]...", *consulAddr)
@@ -67,14 +86,23 @@ func main() {
 	log.Info().Msg("Consul agent initialized")
 
 	srv := &rate.Server{
-		Tracer:      tracer,
-		Registry:    registry,
-		Port:        servPort,
-		IpAddr:      servIP,
-		MongoClient: mongoClient,
-		MemcClient:  memcClient,
+		Tracer:          tracer,
+		Registry:        registry,
+		Port:            servPort,
+		MetricsPort:     metricsPort,
+		IpAddr:          servIP,
+		MongoClient:     mongoClient,
+		MemcClient:      memcClient,
+		ExchangeRates:   exchangeRates,
+		Surge:           surge,
+		DefaultRoomType: tune.GetDefaultRoomType(),
+		Warmup:          warmup.Config{Mode: tune.GetWarmup(), Concurrency: tune.GetWarmupConcurrency()},
+		MongoFanout:     tune.GetMongoFanout(),
+		AdminAllowlist:  tune.GetAdminAllowlist(),
 	}
 
 	log.Info().Msg("Starting server...")
-	log.Fatal().Msg(srv.Run().Error())
+	if err := graceful.Serve(srv.Run, srv.Shutdown); err != nil {
+		log.Fatal().Msg(err.Error())
+	}
 }
@@ -5,9 +5,10 @@ import (
 	"fmt"
 	"strconv"
 
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/datagen"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
 	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type Hotel struct {
@@ -63,11 +64,17 @@ func initializeDatabase(url string) (*mongo.Client, func()) {
 		)
 	}
 
+	if n := tune.GetDatagenHotels(); n > 0 {
+		newHotels = nil
+		for _, h := range datagen.Generate(tune.GetDatagenSeed(), n) {
+			newHotels = append(newHotels, Hotel{h.Id, h.Lat, h.Lon, h.Rate, h.RateInclusive})
+		}
+	}
+
 	uri := fmt.Sprintf("mongodb://%s", url)
 	log.Info().Msgf("Attempting connection to %v", uri)
 
-	opts := options.Client().ApplyURI(uri)
-	client, err := mongo.Connect(context.TODO(), opts)
+	client, err := tune.NewMongoClient(uri)
 	if err != nil {
 		log.Panic().Msg(err.Error())
 	}
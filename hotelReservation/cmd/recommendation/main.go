@@ -9,17 +9,16 @@ import (
 
 	"strconv"
 
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/graceful"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/registry"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/recommendation"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
-	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
 func main() {
 	tune.Init()
-	log.Logger = zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}).With().Timestamp().Caller().Logger()
 
 	log.Info().Msg("Reading config...")
 	jsonFile, err := os.Open("config.json")
@@ -40,6 +39,7 @@ func main() {
 
 	servPort, _ := strconv.Atoi(result["RecommendPort"])
 	servIP := result["RecommendIP"]
+	metricsPort, _ := strconv.Atoi(result["RecommendMetricsPort"])
 
 	var (
 		jaegerAddr = flag.String("jaegeraddr", result["jaegerAddress"], "Jaeger address")
@@ -48,7 +48,11 @@ func main() {
 	flag.Parse()
 
 	log.Info().Msgf("Initializing jaeger agent [service name: %v | host: %v]...", "recommendation", *jaegerAddr)
-	tracer, err := tracing.Init("recommendation", *jaegerAddr)
+	sampler, err := tracing.ParseSampler(result["RecommendSamplerType"], result["RecommendSamplerParam"])
+	if err != nil {
+		log.Panic().Msgf("Got error while reading sampler config: %v", err)
+	}
+	tracer, err := tracing.InitWithSampler("recommendation", *jaegerAddr, sampler)
 	if err != nil {
 		log.Panic().Msgf("Got error while initializing jaeger agent: %v", err)
 	}
@@ -62,13 +66,22 @@ func main() {
 	log.Info().Msg("Consul agent initialized")
 
 	srv := &recommendation.Server{
-		Port:        servPort,
-		IpAddr:      servIP,
-		Tracer:      tracer,
-		Registry:    registry,
-		MongoClient: mongoClient,
+		Port:              servPort,
+		MetricsPort:       metricsPort,
+		IpAddr:            servIP,
+		Tracer:            tracer,
+		ConsulAddr:        *consulAddr,
+		KnativeDns:        result["KnativeDomainName"],
+		Registry:          registry,
+		MongoClient:       mongoClient,
+		CompressThreshold: tune.GetCompressThreshold(),
+		MemoEntries:       tune.GetRecommendMemoEntries(),
+		MemoTTL:           time.Duration(tune.GetRecommendMemoTTL()) * time.Millisecond,
+		RatingRefresh:     time.Duration(tune.GetRatingRefresh()) * time.Second,
 	}
 
 	log.Info().Msg("Starting server...")
-	log.Fatal().Msg(srv.Run().Error())
+	if err := graceful.Serve(srv.Run, srv.Shutdown); err != nil {
+		log.Fatal().Msg(err.Error())
+	}
 }
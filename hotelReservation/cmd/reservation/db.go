@@ -5,7 +5,11 @@ import (
 	"fmt"
 	"strconv"
 
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/datagen"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/reservation"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
 	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -18,18 +22,30 @@ type Reservation struct {
 	Number       int    `bson:"number"`
 }
 
+type Night struct {
+	HotelId  string `bson:"hotelId"`
+	RoomType string `bson:"roomType"`
+	Date     string `bson:"date"`
+	Reserved int    `bson:"reserved"`
+}
+
 type Number struct {
 	HotelId string `bson:"hotelId"`
 	Number  int    `bson:"numberOfRoom"`
 }
 
-func initializeDatabase(url string) (*mongo.Client, func()) {
+func initializeDatabase(url string, shards int) (*mongo.Client, func()) {
 	log.Info().Msg("Generating test data...")
 
 	newReservations := []interface{}{
 		Reservation{"4", "Alice", "2015-04-09", "2015-04-10", 1},
 	}
 
+	// must match the reservations above, which are of the default room type
+	newNights := []interface{}{
+		Night{"4", tune.GetDefaultRoomType(), "2015-04-09", 1},
+	}
+
 	newNumbers := []interface{}{
 		Number{"1", 200},
 		Number{"2", 200},
@@ -52,29 +68,81 @@ func initializeDatabase(url string) (*mongo.Client, func()) {
 		newNumbers = append(newNumbers, Number{hotelID, roomNumber})
 	}
 
+	if n := tune.GetDatagenHotels(); n > 0 {
+		newNumbers = nil
+		for _, h := range datagen.Generate(tune.GetDatagenSeed(), n) {
+			newNumbers = append(newNumbers, Number{h.Id, h.Rooms})
+		}
+		// generated datasets start without any booking
+		newReservations, newNights = nil, nil
+	}
+
 	uri := fmt.Sprintf("mongodb://%s", url)
 	log.Info().Msgf("Attempting connection to %v", uri)
 
-	opts := options.Client().ApplyURI(uri)
-	client, err := mongo.Connect(context.TODO(), opts)
+	client, err := tune.NewMongoClient(uri)
 	if err != nil {
 		log.Panic().Msg(err.Error())
 	}
 	log.Info().Msg("Successfully connected to MongoDB")
 
 	database := client.Database("reservation-db")
-	resCollection := database.Collection("reservation")
 	numCollection := database.Collection("number")
 
-	_, err = resCollection.InsertMany(context.TODO(), newReservations)
+	_, err = numCollection.InsertMany(context.TODO(), newNumbers)
 	if err != nil {
 		log.Fatal().Msg(err.Error())
 	}
 
-	_, err = numCollection.InsertMany(context.TODO(), newNumbers)
+	// retries rely on this index to never book twice with the same key
+	_, err = database.Collection("idempotency").Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "key", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
 	if err != nil {
 		log.Fatal().Msg(err.Error())
 	}
+
+	// reservations and nights are stored on the shard of their hotel
+	ring := reservation.NewShardRing(shards)
+	shardReservations := make(map[int][]interface{})
+	for _, r := range newReservations {
+		i := ring.Shard(r.(Reservation).HotelId)
+		shardReservations[i] = append(shardReservations[i], r)
+	}
+	shardNights := make(map[int][]interface{})
+	for _, n := range newNights {
+		i := ring.Shard(n.(Night).HotelId)
+		shardNights[i] = append(shardNights[i], n)
+	}
+
+	for i := 0; i < shards; i++ {
+		shard := client.Database(reservation.ShardDatabaseName(i))
+		if len(shardReservations[i]) > 0 {
+			_, err = shard.Collection("reservation").InsertMany(context.TODO(), shardReservations[i])
+			if err != nil {
+				log.Fatal().Msg(err.Error())
+			}
+		}
+
+		// bookings rely on this index to never overfill a night
+		nightCollection := shard.Collection("night")
+		_, err = nightCollection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+			Keys:    bson.D{{Key: "hotelId", Value: 1}, {Key: "roomType", Value: 1}, {Key: "date", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		})
+		if err != nil {
+			log.Fatal().Msg(err.Error())
+		}
+
+		// the nights are already there if the service restarted
+		if len(shardNights[i]) > 0 {
+			_, err = nightCollection.InsertMany(context.TODO(), shardNights[i])
+			if err != nil && !mongo.IsDuplicateKeyError(err) {
+				log.Fatal().Msg(err.Error())
+			}
+		}
+	}
 	log.Info().Msg("Successfully inserted test data into reservation DB")
 
 	return client, func() {
@@ -8,17 +8,17 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/graceful"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/registry"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/reservation"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
-	"github.com/rs/zerolog"
+	"github.com/opentracing/opentracing-go"
 	"github.com/rs/zerolog/log"
 )
 
 func main() {
 	tune.Init()
-	log.Logger = zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}).With().Timestamp().Caller().Logger()
 
 	log.Info().Msg("Reading config...")
 	jsonFile, err := os.Open("config.json")
@@ -33,17 +33,21 @@ func main() {
 	var result map[string]string
 	json.Unmarshal([]byte(byteValue), &result)
 
+	shards := tune.GetReservationShards()
+
 	log.Info().Msg("Initializing DB connection...")
-	mongoClient, mongoClose := initializeDatabase(result["ReserveMongoAddress"])
+	mongoClient, mongoClose := initializeDatabase(result["ReserveMongoAddress"], shards)
 	defer mongoClose()
 
 	log.Info().Msgf("Read profile memcashed address: %v", result["ReserveMemcAddress"])
 	log.Info().Msg("Initializing Memcashed client...")
 	memcClient := tune.NewMemCClient2(result["ReserveMemcAddress"])
+	defer memcClient.Close()
 	log.Info().Msg("Success")
 
 	servPort, _ := strconv.Atoi(result["ReservePort"])
 	servIP := result["ReserveIP"]
+	metricsPort, _ := strconv.Atoi(result["ReserveMetricsPort"])
 
 	var (
 		jaegerAddr = flag.String("jaegeraddr", result["jaegerAddress"], "Jaeger address")
@@ -52,10 +56,16 @@ func main() {
 	flag.Parse()
 
 	log.Info().Msgf("Initializing jaeger agent [service name: %v | host: %v]...", "reservation", *jaegerAddr)
-	tracer, err := tracing.Init("reservation", *jaegerAddr)
+	sampler, err := tracing.ParseSampler(result["ReserveSamplerType"], result["ReserveSamplerParam"])
+	if err != nil {
+		log.Panic().Msgf("Got error while reading sampler config: %v", err)
+	}
+	tracer, err := tracing.InitWithSampler("reservation", *jaegerAddr, sampler)
 	if err != nil {
 		log.Panic().Msgf("Got error while initializing jaeger agent: %v", err)
 	}
+	// the server spans its memcached and MongoDB calls with the global tracer
+	opentracing.SetGlobalTracer(tracer)
 	log.Info().Msg("Jaeger agent initialized")
 
 	log.Info().Msgf("Initializing consul agent [host: %v]...", *consulAddr)
@@ -66,14 +76,22 @@ func main() {
 	log.Info().Msg("Consul agent initialized")
 
 	srv := &reservation.Server{
-		Tracer:      tracer,
-		Registry:    registry,
-		Port:        servPort,
-		IpAddr:      servIP,
-		MongoClient: mongoClient,
-		MemcClient:  memcClient,
+		Tracer:                 tracer,
+		Registry:               registry,
+		Port:                   servPort,
+		MetricsPort:            metricsPort,
+		IpAddr:                 servIP,
+		MongoClient:            mongoClient,
+		Shards:                 reservation.ShardDatabases(mongoClient, shards),
+		MemcClient:             memcClient,
+		FreeCancellationWindow: time.Duration(tune.GetFreeCancellationHours()) * time.Hour,
+		DefaultRoomType:        tune.GetDefaultRoomType(),
+		IdempotencyWindow:      time.Duration(tune.GetIdempotencyHours()) * time.Hour,
+		AdminAllowlist:         tune.GetAdminAllowlist(),
 	}
 
 	log.Info().Msg("Starting server...")
-	log.Fatal().Msg(srv.Run().Error())
+	if err := graceful.Serve(srv.Run, srv.Shutdown); err != nil {
+		log.Fatal().Msg(err.Error())
+	}
 }
@@ -7,21 +7,19 @@ import (
 	"os"
 	"strconv"
 
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/graceful"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/registry"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/review"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
 
-	"github.com/rs/zerolog"
+	"github.com/opentracing/opentracing-go"
 	"github.com/rs/zerolog/log"
-
-	"time"
 	// "github.com/bradfitz/gomemcache/memcache"
 )
 
 func main() {
 	tune.Init()
-	log.Logger = zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}).With().Timestamp().Caller().Logger()
 
 	log.Info().Msg("Reading config...")
 	jsonFile, err := os.Open("config.json")
@@ -48,10 +46,12 @@ func main() {
 	// memc_client.Timeout = time.Second * 2
 	// memc_client.MaxIdleConns = 512
 	memc_client := tune.NewMemCClient2(result["ReviewMemcAddress"])
+	defer memc_client.Close()
 	log.Info().Msg("Successfull")
 
 	serv_port, _ := strconv.Atoi(result["ReviewPort"])
 	serv_ip := result["ReviewIP"]
+	metricsPort, _ := strconv.Atoi(result["ReviewMetricsPort"])
 	log.Info().Msgf("Read target port: %v", serv_port)
 	log.Info().Msgf("Read consul address: %v", result["consulAddress"])
 	log.Info().Msgf("Read jaeger address: %v", result["jaegerAddress"])
@@ -64,10 +64,16 @@ func main() {
 	flag.Parse()
 
 	log.Info().Msgf("Initializing jaeger agent [service name: %v | host: %v]...", "review", *jaegeraddr)
-	tracer, err := tracing.Init("review", *jaegeraddr)
+	sampler, err := tracing.ParseSampler(result["ReviewSamplerType"], result["ReviewSamplerParam"])
+	if err != nil {
+		log.Panic().Msgf("Got error while reading sampler config: %v", err)
+	}
+	tracer, err := tracing.InitWithSampler("review", *jaegeraddr, sampler)
 	if err != nil {
 		log.Panic().Msgf("Got error while initializing jaeger agent: %v", err)
 	}
+	// the server spans its memcached and MongoDB calls with the global tracer
+	opentracing.SetGlobalTracer(tracer)
 	log.Info().Msg("Jaeger agent initialized")
 
 	log.Info().Msgf("Initializing consul agent [host: %v]...", *consuladdr)
@@ -82,11 +88,15 @@ func main() {
 		// Port:     *port,
 		Registry:    registry,
 		Port:        serv_port,
+		MetricsPort: metricsPort,
 		IpAddr:      serv_ip,
 		MongoClient: mongo_session,
 		MemcClient:  memc_client,
+		RatingTTL:   int32(tune.GetRatingTTL()),
 	}
 
 	log.Info().Msg("Starting server...")
-	log.Fatal().Msg(srv.Run().Error())
+	if err := graceful.Serve(srv.Run, srv.Shutdown); err != nil {
+		log.Fatal().Msg(err.Error())
+	}
 }
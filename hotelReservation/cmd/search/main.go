@@ -9,17 +9,16 @@ import (
 
 	"strconv"
 
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/graceful"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/registry"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/search"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
-	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
 func main() {
 	tune.Init()
-	log.Logger = zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}).With().Timestamp().Caller().Logger()
 
 	log.Info().Msg("Reading config...")
 	jsonFile, err := os.Open("config.json")
@@ -36,6 +35,7 @@ func main() {
 
 	servPort, _ := strconv.Atoi(result["SearchPort"])
 	servIP := result["SearchIP"]
+	metricsPort, _ := strconv.Atoi(result["SearchMetricsPort"])
 	knativeDNS := result["KnativeDomainName"]
 
 	var (
@@ -45,7 +45,11 @@ func main() {
 	flag.Parse()
 
 	log.Info().Msgf("Initializing jaeger agent [service name: %v | host: %v]...", "search", *jaegerAddr)
-	tracer, err := tracing.Init("search", *jaegerAddr)
+	sampler, err := tracing.ParseSampler(result["SearchSamplerType"], result["SearchSamplerParam"])
+	if err != nil {
+		log.Panic().Msgf("Got error while reading sampler config: %v", err)
+	}
+	tracer, err := tracing.InitWithSampler("search", *jaegerAddr, sampler)
 	if err != nil {
 		log.Panic().Msgf("Got error while initializing jaeger agent: %v", err)
 	}
@@ -59,14 +63,26 @@ func main() {
 	log.Info().Msg("Consul agent initialized")
 
 	srv := &search.Server{
-		Tracer:     tracer,
-		Port:       servPort,
-		IpAddr:     servIP,
-		ConsulAddr: *consulAddr,
-		KnativeDns: knativeDNS,
-		Registry:   registry,
+		Tracer:            tracer,
+		Port:              servPort,
+		MetricsPort:       metricsPort,
+		IpAddr:            servIP,
+		ConsulAddr:        *consulAddr,
+		KnativeDns:        knativeDNS,
+		Registry:          registry,
+		BudgetSlice:       float64(tune.GetBudgetWarnPercent()) / 100,
+		CompressThreshold: tune.GetCompressThreshold(),
+		CacheEntries:      tune.GetSearchCacheEntries(),
+		CacheTTL:          time.Duration(tune.GetSearchCacheTTL()) * time.Millisecond,
+		CachePrecision:    tune.GetSearchCachePrecision(),
+		MissingData:       tune.GetSearchMissingData(),
+		Timeouts:          tune.GetDependencyTimeouts(),
+		HedgeDelay:        time.Duration(tune.GetHedgeDelay()) * time.Millisecond,
+		MaxHedges:         tune.GetMaxHedges(),
 	}
 
 	log.Info().Msg("Starting server...")
-	log.Fatal().Msg(srv.Run().Error())
+	if err := graceful.Serve(srv.Run, srv.Shutdown); err != nil {
+		log.Fatal().Msg(err.Error())
+	}
 }
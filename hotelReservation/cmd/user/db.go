@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"strconv"
 
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
 	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -39,18 +41,28 @@ func initializeDatabase(url string) (*mongo.Client, func()) {
 	uri := fmt.Sprintf("mongodb://%s", url)
 	log.Info().Msgf("Attempting connection to %v", uri)
 
-	opts := options.Client().ApplyURI(uri)
-	client, err := mongo.Connect(context.TODO(), opts)
+	client, err := tune.NewMongoClient(uri)
 	if err != nil {
 		log.Panic().Msg(err.Error())
 	}
 	log.Info().Msg("Successfully connected to MongoDB")
 
 	collection := client.Database("user-db").Collection("user")
-	_, err = collection.InsertMany(context.TODO(), newUsers)
+
+	// RegisterUser relies on this index to keep usernames unique
+	_, err = collection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "username", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
 	if err != nil {
 		log.Fatal().Msg(err.Error())
 	}
+
+	// the users are already there if the service restarted
+	_, err = collection.InsertMany(context.TODO(), newUsers)
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		log.Fatal().Msg(err.Error())
+	}
 	log.Info().Msg("Successfully inserted test data into user DB")
 
 	return client, func() {
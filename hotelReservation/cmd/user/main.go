@@ -6,20 +6,18 @@ import (
 	"io/ioutil"
 	"os"
 	"strconv"
-	"time"
 
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/graceful"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/registry"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/user"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
-	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
 func main() {
 	tune.Init()
 	// initializeDatabase()
-	log.Logger = zerolog.New(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}).With().Timestamp().Caller().Logger()
 
 	log.Info().Msg("Reading config...")
 	jsonFile, err := os.Open("config.json")
@@ -40,6 +38,7 @@ func main() {
 
 	servPort, _ := strconv.Atoi(result["UserPort"])
 	servIP := result["UserIP"]
+	metricsPort, _ := strconv.Atoi(result["UserMetricsPort"])
 
 	var (
 		jaegerAddr = flag.String("jaegeraddr", result["jaegerAddress"], "Jaeger address")
@@ -48,7 +47,11 @@ func main() {
 	flag.Parse()
 
 	log.Info().Msgf("Initializing jaeger agent [service name: %v | host: %v]...", "user", *jaegerAddr)
-	tracer, err := tracing.Init("user", *jaegerAddr)
+	sampler, err := tracing.ParseSampler(result["UserSamplerType"], result["UserSamplerParam"])
+	if err != nil {
+		log.Panic().Msgf("Got error while reading sampler config: %v", err)
+	}
+	tracer, err := tracing.InitWithSampler("user", *jaegerAddr, sampler)
 	if err != nil {
 		log.Panic().Msgf("Got error while initializing jaeger agent: %v", err)
 	}
@@ -62,13 +65,18 @@ func main() {
 	log.Info().Msg("Consul agent initialized")
 
 	srv := &user.Server{
-		Port:        servPort,
-		IpAddr:      servIP,
-		Tracer:      tracer,
-		Registry:    registry,
-		MongoClient: mongoClient,
+		Port:              servPort,
+		MetricsPort:       metricsPort,
+		IpAddr:            servIP,
+		Tracer:            tracer,
+		Registry:          registry,
+		MongoClient:       mongoClient,
+		BcryptCost:        tune.GetBcryptCost(),
+		MinPasswordLength: tune.GetMinPasswordLength(),
 	}
 
 	log.Info().Msg("Starting server...")
-	log.Fatal().Msg(srv.Run().Error())
+	if err := graceful.Serve(srv.Run, srv.Shutdown); err != nil {
+		log.Fatal().Msg(err.Error())
+	}
 }
@@ -0,0 +1,99 @@
+package coalesce
+
+import (
+	"fmt"
+	"sync"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// LoadFunc loads keys, returning the values it found by key.
+type LoadFunc func(keys []string) (map[string]interface{}, error)
+
+// Group collapses concurrent loads of the same keys with a
+// golang.org/x/sync/singleflight flight per key: a key is loaded by one
+// caller at a time, and the callers asking for it meanwhile wait for that
+// load and share its result. Results and errors are only handed to the
+// callers already waiting and are never cached.
+//
+// A caller asking for several keys loads them with a single call to its
+// LoadFunc as soon as it leads the flight of any of them, so batched queries
+// stay batched. The keys it joined the flights of are loaded along, but
+// their values are those of the flights.
+//
+// The zero Group is ready to use.
+type Group struct {
+	flights singleflight.Group
+}
+
+// found is the result of the flight of a key: its value, if loaded.
+type found struct {
+	value interface{}
+	ok    bool
+}
+
+// batch loads the keys of one caller at most once, when the flight of the
+// first of them it leads runs.
+type batch struct {
+	keys   []string
+	load   LoadFunc
+	once   sync.Once
+	values map[string]interface{}
+	err    error
+}
+
+// get returns the value of key, loading the batch if not yet loaded. A
+// panic of the load is returned as an error, as it would otherwise crash
+// the process from the goroutine of the flight.
+func (b *batch) get(key string) (interface{}, error) {
+	b.once.Do(func() {
+		defer func() {
+			if r := recover(); r != nil {
+				b.err = fmt.Errorf("load of %v panicked: %v", b.keys, r)
+			}
+		}()
+		b.values, b.err = b.load(b.keys)
+	})
+	if b.err != nil {
+		return nil, b.err
+	}
+	value, ok := b.values[key]
+	return found{value, ok}, nil
+}
+
+// Load returns the values of keys, calling load for those no other caller
+// is loading and waiting for the loads of the others. Keys not found are
+// missing from the result. The first error of the loads is returned along
+// with the values of the loads that succeeded.
+func (g *Group) Load(keys []string, load LoadFunc) (map[string]interface{}, error) {
+	b := &batch{load: load}
+	results := make(map[string]<-chan singleflight.Result, len(keys))
+	for _, key := range keys {
+		if _, ok := results[key]; !ok {
+			b.keys = append(b.keys, key)
+			results[key] = nil
+		}
+	}
+	for _, key := range b.keys {
+		key := key
+		results[key] = g.flights.DoChan(key, func() (interface{}, error) {
+			return b.get(key)
+		})
+	}
+
+	values := make(map[string]interface{}, len(keys))
+	var err error
+	for _, key := range b.keys {
+		res := <-results[key]
+		if res.Err != nil {
+			if err == nil {
+				err = res.Err
+			}
+			continue
+		}
+		if f := res.Val.(found); f.ok {
+			values[key] = f.value
+		}
+	}
+	return values, err
+}
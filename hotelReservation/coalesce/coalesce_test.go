@@ -0,0 +1,221 @@
+package coalesce
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// loadAll calls g.Load(keys, load) from n goroutines at once, returning
+// their results.
+func loadAll(g *Group, n int, keys []string, load LoadFunc) ([]map[string]interface{}, []error) {
+	values := make([]map[string]interface{}, n)
+	errs := make([]error, n)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			values[i], errs[i] = g.Load(keys, load)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	return values, errs
+}
+
+// slowLoad returns a load finding the keys in found after a while, counting
+// its calls.
+func slowLoad(calls *int32, found map[string]interface{}) LoadFunc {
+	return func(keys []string) (map[string]interface{}, error) {
+		atomic.AddInt32(calls, 1)
+		time.Sleep(50 * time.Millisecond)
+		values := make(map[string]interface{})
+		for _, key := range keys {
+			if v, ok := found[key]; ok {
+				values[key] = v
+			}
+		}
+		return values, nil
+	}
+}
+
+func TestLoadCollapsesConcurrentMisses(t *testing.T) {
+	var g Group
+	var calls int32
+	values, errs := loadAll(&g, 50, []string{"1", "2"}, slowLoad(&calls, map[string]interface{}{"1": "one"}))
+
+	if calls != 1 {
+		t.Errorf("load called %d times for 50 concurrent callers, want 1", calls)
+	}
+	for i := range values {
+		if errs[i] != nil {
+			t.Errorf("caller %d: %v", i, errs[i])
+		}
+		if fmt.Sprint(values[i]) != "map[1:one]" {
+			t.Errorf("caller %d got %v, want key 1 only", i, values[i])
+		}
+	}
+}
+
+func TestLoadBatchesKeys(t *testing.T) {
+	var g Group
+	var mu sync.Mutex
+	var batches [][]string
+	release := make(chan struct{})
+	load := func(keys []string) (map[string]interface{}, error) {
+		mu.Lock()
+		batches = append(batches, append([]string(nil), keys...))
+		n := len(batches)
+		mu.Unlock()
+		<-release
+		values := make(map[string]interface{})
+		for _, key := range keys {
+			values[key] = fmt.Sprintf("%s@%d", key, n)
+		}
+		return values, nil
+	}
+
+	first := make(chan map[string]interface{})
+	go func() {
+		values, _ := g.Load([]string{"1", "2"}, load)
+		first <- values
+	}()
+	for {
+		mu.Lock()
+		n := len(batches)
+		mu.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	second := make(chan map[string]interface{})
+	go func() {
+		values, _ := g.Load([]string{"2", "3", "4", "3"}, load)
+		second <- values
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	if got := fmt.Sprint(<-first); got != "map[1:1@1 2:2@1]" {
+		t.Errorf("first caller got %v, want keys 1 and 2 of the first load", got)
+	}
+	// key 2 is that of the flight of the first caller
+	if got := fmt.Sprint(<-second); got != "map[2:2@1 3:3@2 4:4@2]" {
+		t.Errorf("second caller got %v, want key 2 of the first load, 3 and 4 of the second", got)
+	}
+	// the second caller leads keys 3 and 4, loading its keys in one call
+	if len(batches) != 2 {
+		t.Fatalf("got loads %v, want 2", batches)
+	}
+	sort.Strings(batches[1])
+	if fmt.Sprint(batches[1]) != "[2 3 4]" {
+		t.Errorf("second load of %v, want [2 3 4]", batches[1])
+	}
+}
+
+func TestLoadJoinsEveryFlight(t *testing.T) {
+	var g Group
+	var calls int32
+	release := make(chan struct{})
+	load := func(keys []string) (map[string]interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return map[string]interface{}{"1": "one", "2": "two"}, nil
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		g.Load([]string{"1", "2"}, load)
+	}()
+	for atomic.LoadInt32(&calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// a caller whose keys are all in flight doesn't load
+	joined := make(chan map[string]interface{})
+	go func() {
+		values, _ := g.Load([]string{"2"}, load)
+		joined <- values
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	<-done
+	if got := fmt.Sprint(<-joined); got != "map[2:two]" {
+		t.Errorf("got %v, want key 2", got)
+	}
+	if calls != 1 {
+		t.Errorf("load called %d times, want 1", calls)
+	}
+}
+
+func TestLoadErrorSharedNotCached(t *testing.T) {
+	var g Group
+	var calls int32
+	failing := func(keys []string) (map[string]interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(50 * time.Millisecond)
+		return nil, errors.New("mongo is down")
+	}
+	_, errs := loadAll(&g, 20, []string{"1"}, failing)
+	for i, err := range errs {
+		if err == nil || err.Error() != "mongo is down" {
+			t.Errorf("caller %d got %v, want the load error", i, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("failing load called %d times, want 1", calls)
+	}
+
+	// the next caller loads again
+	values, err := g.Load([]string{"1"}, slowLoad(&calls, map[string]interface{}{"1": "one"}))
+	if err != nil || values["1"] != "one" {
+		t.Errorf("load after the failure got %v, %v, want key 1", values, err)
+	}
+	if calls != 2 {
+		t.Errorf("load called %d times in all, want 2", calls)
+	}
+}
+
+func TestLoadPanicReleasesWaiters(t *testing.T) {
+	var g Group
+	started := make(chan struct{})
+	led := make(chan error)
+	go func() {
+		_, err := g.Load([]string{"1"}, func(keys []string) (map[string]interface{}, error) {
+			close(started)
+			time.Sleep(20 * time.Millisecond)
+			panic("boom")
+		})
+		led <- err
+	}()
+	<-started
+	waited := make(chan error)
+	go func() {
+		_, err := g.Load([]string{"1"}, func(keys []string) (map[string]interface{}, error) {
+			return map[string]interface{}{"1": "one"}, nil
+		})
+		waited <- err
+	}()
+
+	// the panic fails the load of every caller, rather than the process
+	for _, ch := range []chan error{led, waited} {
+		select {
+		case err := <-ch:
+			if err == nil || !strings.Contains(err.Error(), "boom") {
+				t.Errorf("caller of a panicked load got %v, want the panic", err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("caller of a panicked load left hanging")
+		}
+	}
+}
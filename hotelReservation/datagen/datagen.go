@@ -0,0 +1,154 @@
+// Package datagen generates synthetic hotel datasets of any size for
+// benchmarking.
+//
+// A dataset is fully determined by its seed and number of hotels. Every
+// service seeds its own database from the same parameters, so geo, profile,
+// rate, recommendation, reservation and attractions all know of the same
+// hotels, at the same coordinates and prices.
+package datagen
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+)
+
+// The area hotels are spread over. It covers the coordinates searched by the
+// wrk2 workloads.
+const (
+	MinLat = 37.7835
+	MaxLat = 38.2635
+	MinLon = -122.41
+	MaxLon = -121.77
+)
+
+// InDate is the first night rate plans are offered for. They end on one of
+// outDates, both within the dates searched by the wrk2 workloads.
+const InDate = "2015-04-09"
+
+var (
+	outDates = []string{"2015-04-17", "2015-04-24"}
+
+	namePrefixes = []string{"Grand", "Royal", "Bay", "Golden Gate", "Union Square", "Harbor", "Park", "Hilltop"}
+	nameSuffixes = []string{"Hotel", "Inn", "Suites", "Lodge", "Resort"}
+	streetNames  = []string{"Market St", "Mission St", "Geary St", "Powell St", "Van Ness Ave", "3rd St", "Eddy St", "Kearny St"}
+	rooms        = []struct{ code, description string }{
+		{"KNG", "King sized bed"},
+		{"QN", "Queen sized bed"},
+		{"DBL", "Two double beds"},
+	}
+)
+
+// Hotel holds everything the services store about a hotel.
+type Hotel struct {
+	Id          string  `json:"id"`
+	Name        string  `json:"name"`
+	PhoneNumber string  `json:"phoneNumber"`
+	Description string  `json:"description"`
+	Street      string  `json:"street"`
+	Number      string  `json:"number"`
+	City        string  `json:"city"`
+	State       string  `json:"state"`
+	Country     string  `json:"country"`
+	PostalCode  string  `json:"postalCode"`
+	Region      string  `json:"region"`
+	Lat         float64 `json:"lat"`
+	Lon         float64 `json:"lon"`
+	Stars       float32 `json:"stars"`
+
+	// Rooms is the number of rooms of the hotel.
+	Rooms int `json:"rooms"`
+
+	// The rate plan of the hotel.
+	RoomCode        string  `json:"roomCode"`
+	RoomDescription string  `json:"roomDescription"`
+	InDate          string  `json:"inDate"`
+	OutDate         string  `json:"outDate"`
+	Rate            float64 `json:"rate"`
+	RateInclusive   float64 `json:"rateInclusive"`
+}
+
+// Generate returns n hotels, with ids "1" to n, generated from seed. The
+// same seed and n always yield the same hotels.
+func Generate(seed int64, n int) []Hotel {
+	r := rand.New(rand.NewSource(seed))
+	hotels := make([]Hotel, 0, n)
+	for i := 1; i <= n; i++ {
+		id := strconv.Itoa(i)
+		room := rooms[r.Intn(len(rooms))]
+		rate := float64(80+r.Intn(220)) + 0.99*float64(r.Intn(2))
+		name := fmt.Sprintf("%s %s", namePrefixes[r.Intn(len(namePrefixes))], nameSuffixes[r.Intn(len(nameSuffixes))])
+		stars := float32(2+r.Intn(9)) / 2
+		capacity := 50 * (1 + r.Intn(8))
+
+		hotels = append(hotels, Hotel{
+			Id:          id,
+			Name:        name,
+			PhoneNumber: fmt.Sprintf("(415) %03d-%04d", 200+r.Intn(800), r.Intn(10000)),
+			Description: fmt.Sprintf("%s is a %v-star benchmark hotel of %d rooms.", name, stars, capacity),
+			Street:      streetNames[r.Intn(len(streetNames))],
+			Number:      strconv.Itoa(1 + r.Intn(2000)),
+			City:        "San Francisco",
+			State:       "CA",
+			Country:     "United States",
+			PostalCode:  strconv.Itoa(94102 + r.Intn(30)),
+			Lat:         MinLat + r.Float64()*(MaxLat-MinLat),
+			Lon:         MinLon + r.Float64()*(MaxLon-MinLon),
+			Stars:       stars,
+
+			Rooms: capacity,
+
+			RoomCode:        room.code,
+			RoomDescription: room.description,
+			InDate:          InDate,
+			OutDate:         outDates[r.Intn(len(outDates))],
+			Rate:            rate,
+			// taxes and fees of 10 to 20%
+			RateInclusive: float64(int(rate*(110+float64(r.Intn(11))))) / 100,
+		})
+		h := &hotels[len(hotels)-1]
+		h.Region = Region(h.Lat, h.Lon)
+	}
+	return hotels
+}
+
+// Region returns the region of a hotel at lat, lon: the quarter of the
+// generated area it lies in, north-west, north-east, south-west or
+// south-east. Hotels outside of the area are in the nearest quarter.
+func Region(lat, lon float64) string {
+	ns, ew := "south", "west"
+	if lat >= (MinLat+MaxLat)/2 {
+		ns = "north"
+	}
+	if lon >= (MinLon+MaxLon)/2 {
+		ew = "east"
+	}
+	return ns + "-" + ew
+}
+
+// Validate checks that hotels are consistent with one another, as generated
+// by Generate: ids are unique, coordinates lie within the generated area,
+// every hotel has rooms and a rate plan for a valid stay.
+func Validate(hotels []Hotel) error {
+	seen := make(map[string]bool, len(hotels))
+	for _, h := range hotels {
+		switch {
+		case h.Id == "":
+			return fmt.Errorf("hotel without an id")
+		case seen[h.Id]:
+			return fmt.Errorf("hotel %s: duplicate id", h.Id)
+		case h.Lat < MinLat || h.Lat > MaxLat || h.Lon < MinLon || h.Lon > MaxLon:
+			return fmt.Errorf("hotel %s: coordinates (%v, %v) out of the generated area", h.Id, h.Lat, h.Lon)
+		case h.Rooms <= 0:
+			return fmt.Errorf("hotel %s: no rooms", h.Id)
+		case h.RoomCode == "":
+			return fmt.Errorf("hotel %s: no rate plan", h.Id)
+		case h.Rate <= 0 || h.RateInclusive < h.Rate:
+			return fmt.Errorf("hotel %s: invalid rate %v (%v inclusive)", h.Id, h.Rate, h.RateInclusive)
+		case h.InDate >= h.OutDate:
+			return fmt.Errorf("hotel %s: stay from %s to %s", h.Id, h.InDate, h.OutDate)
+		}
+		seen[h.Id] = true
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package datagen
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestGenerateConsistent(t *testing.T) {
+	for seed := int64(1); seed <= 20; seed++ {
+		hotels := Generate(seed, 50)
+		if len(hotels) != 50 {
+			t.Fatalf("seed %d: got %d hotels, want 50", seed, len(hotels))
+		}
+		if err := Validate(hotels); err != nil {
+			t.Errorf("seed %d: %v", seed, err)
+		}
+		for i, h := range hotels {
+			if h.Id != strconv.Itoa(i+1) {
+				t.Errorf("seed %d: hotel %d has id %q", seed, i+1, h.Id)
+			}
+			if h.Region != Region(h.Lat, h.Lon) {
+				t.Errorf("seed %d: hotel %s in region %s, want %s", seed, h.Id, h.Region, Region(h.Lat, h.Lon))
+			}
+		}
+	}
+}
+
+func TestGenerateReproducible(t *testing.T) {
+	if a, b := Generate(42, 30), Generate(42, 30); !reflect.DeepEqual(a, b) {
+		t.Error("same seed yielded different hotels")
+	}
+	if a, b := Generate(42, 30), Generate(43, 30); reflect.DeepEqual(a, b) {
+		t.Error("different seeds yielded the same hotels")
+	}
+
+	// scaling up keeps the hotels of the smaller dataset
+	small, large := Generate(7, 10), Generate(7, 100)
+	if !reflect.DeepEqual(small, large[:10]) {
+		t.Error("the first 10 of 100 hotels differ from the 10 of the same seed")
+	}
+}
+
+func TestGenerateEmpty(t *testing.T) {
+	if hotels := Generate(1, 0); len(hotels) != 0 {
+		t.Errorf("got %d hotels, want none", len(hotels))
+	}
+}
+
+func TestRegion(t *testing.T) {
+	for _, tt := range []struct {
+		lat, lon float64
+		want     string
+	}{
+		{MinLat, MinLon, "south-west"},
+		{MaxLat, MinLon, "north-west"},
+		{MinLat, MaxLon, "south-east"},
+		{MaxLat, MaxLon, "north-east"},
+		{90, 0, "north-east"},
+		{0, -180, "south-west"},
+	} {
+		if got := Region(tt.lat, tt.lon); got != tt.want {
+			t.Errorf("Region(%v, %v) = %s, want %s", tt.lat, tt.lon, got, tt.want)
+		}
+	}
+}
+
+func TestValidateRejects(t *testing.T) {
+	for name, broken := range map[string]func(hotels []Hotel){
+		"no id":          func(hotels []Hotel) { hotels[1].Id = "" },
+		"duplicate id":   func(hotels []Hotel) { hotels[2].Id = hotels[0].Id },
+		"out of area":    func(hotels []Hotel) { hotels[0].Lat = MaxLat + 1 },
+		"no rooms":       func(hotels []Hotel) { hotels[0].Rooms = 0 },
+		"no rate plan":   func(hotels []Hotel) { hotels[0].RoomCode = "" },
+		"cheaper taxed":  func(hotels []Hotel) { hotels[0].RateInclusive = hotels[0].Rate - 1 },
+		"free":           func(hotels []Hotel) { hotels[0].Rate = 0 },
+		"stay backwards": func(hotels []Hotel) { hotels[0].OutDate = "2015-04-01" },
+	} {
+		hotels := Generate(1, 5)
+		broken(hotels)
+		if err := Validate(hotels); err == nil {
+			t.Errorf("%s: dataset validated", name)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package dialer
+
+import (
+	"math/rand"
+	"sync/atomic"
+
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/resolver"
+)
+
+// weightedRoundRobin is the name of the balancer spreading RPCs over the
+// resolved addresses in proportion to their weight.
+const weightedRoundRobin = "consul_weighted_round_robin"
+
+// weightedRoundRobinConfig selects the weightedRoundRobin balancer.
+const weightedRoundRobinConfig = `{"loadBalancingConfig": [{"` + weightedRoundRobin + `":{}}]}`
+
+// maxWeight bounds instance weights, and so the length of picker schedules.
+const maxWeight = 100
+
+func init() {
+	balancer.Register(wrrBuilder{})
+}
+
+// weightKey is the key of the weight of an address in its balancer
+// attributes. Balancer attributes don't take part in telling SubConns
+// apart, so a new weight reuses the connection to the address.
+type weightKey struct{}
+
+// withWeight returns addr carrying weight.
+func withWeight(addr resolver.Address, weight int) resolver.Address {
+	addr.BalancerAttributes = addr.BalancerAttributes.WithValue(weightKey{}, weight)
+	return addr
+}
+
+// addrWeight returns the weight addr carries, 1 if none.
+func addrWeight(addr resolver.Address) int {
+	if w, ok := addr.BalancerAttributes.Value(weightKey{}).(int); ok && w > 0 {
+		return w
+	}
+	return 1
+}
+
+type wrrBuilder struct{}
+
+// Build implements balancer.Builder. The balancer is the base one, only
+// remembering the weights of every resolution for its pickers: the base
+// balancer keeps the addresses its SubConns were created with, and so their
+// first weights.
+func (wrrBuilder) Build(cc balancer.ClientConn, opts balancer.BuildOptions) balancer.Balancer {
+	pb := &wrrPickerBuilder{}
+	return &wrrBalancer{
+		Balancer: base.NewBalancerBuilder(weightedRoundRobin, pb, base.Config{HealthCheck: true}).Build(cc, opts),
+		picker:   pb,
+	}
+}
+
+// Name implements balancer.Builder.
+func (wrrBuilder) Name() string { return weightedRoundRobin }
+
+type wrrBalancer struct {
+	balancer.Balancer
+	picker *wrrPickerBuilder
+}
+
+// UpdateClientConnState implements balancer.Balancer, rebuilding the picker
+// with the weights of the new addresses.
+func (b *wrrBalancer) UpdateClientConnState(s balancer.ClientConnState) error {
+	weights := make(map[string]int, len(s.ResolverState.Addresses))
+	for _, a := range s.ResolverState.Addresses {
+		weights[a.Addr] = addrWeight(a)
+	}
+	// balancer calls are serialized, as are the picker builds they cause
+	b.picker.weights = weights
+	return b.Balancer.UpdateClientConnState(s)
+}
+
+type wrrPickerBuilder struct {
+	// weights are the weights of the last resolved addresses.
+	weights map[string]int
+}
+
+// Build implements base.PickerBuilder.
+func (pb *wrrPickerBuilder) Build(info base.PickerBuildInfo) balancer.Picker {
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
+	}
+	scs := make([]balancer.SubConn, 0, len(info.ReadySCs))
+	weights := make([]int, 0, len(info.ReadySCs))
+	for sc, sci := range info.ReadySCs {
+		w, ok := pb.weights[sci.Address.Addr]
+		if !ok {
+			w = addrWeight(sci.Address)
+		}
+		scs = append(scs, sc)
+		weights = append(weights, w)
+	}
+	schedule := smoothSchedule(scs, weights)
+	// start anywhere in the cycle, as pickers are rebuilt whenever a
+	// SubConn changes state
+	return &wrrPicker{schedule: schedule, next: uint32(rand.Intn(len(schedule)))}
+}
+
+// smoothSchedule returns a cycle of picks of scs, each picked as many times
+// as its weight, interleaved as nginx's smooth weighted round-robin does so
+// that heavy instances don't get their picks in bursts.
+func smoothSchedule(scs []balancer.SubConn, weights []int) []balancer.SubConn {
+	total := 0
+	for _, w := range weights {
+		total += w
+	}
+	current := make([]int, len(scs))
+	schedule := make([]balancer.SubConn, 0, total)
+	for len(schedule) < total {
+		for i, w := range weights {
+			current[i] += w
+		}
+		best := 0
+		for i := range current {
+			if current[i] > current[best] {
+				best = i
+			}
+		}
+		current[best] -= total
+		schedule = append(schedule, scs[best])
+	}
+	return schedule
+}
+
+type wrrPicker struct {
+	// schedule is the immutable cycle of picks.
+	schedule []balancer.SubConn
+	next     uint32
+}
+
+// Pick implements balancer.Picker.
+func (p *wrrPicker) Pick(balancer.PickInfo) (balancer.PickResult, error) {
+	n := atomic.AddUint32(&p.next, 1)
+	return balancer.PickResult{SubConn: p.schedule[n%uint32(len(p.schedule))]}, nil
+}
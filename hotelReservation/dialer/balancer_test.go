@@ -0,0 +1,192 @@
+package dialer
+
+import (
+	"math"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/registry"
+	consul "github.com/hashicorp/consul/api"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/resolver"
+)
+
+// fakeSubConn is a SubConn told apart by its name.
+type fakeSubConn struct {
+	balancer.SubConn
+	name string
+}
+
+// subConns returns a SubConn named after each of names.
+func subConns(names ...string) []balancer.SubConn {
+	scs := make([]balancer.SubConn, len(names))
+	for i, name := range names {
+		scs[i] = &fakeSubConn{name: name}
+	}
+	return scs
+}
+
+// scheduleNames returns the names of the SubConns of schedule, joined.
+func scheduleNames(schedule []balancer.SubConn) string {
+	var b strings.Builder
+	for _, sc := range schedule {
+		b.WriteString(sc.(*fakeSubConn).name)
+	}
+	return b.String()
+}
+
+func TestSmoothSchedule(t *testing.T) {
+	for _, tt := range []struct {
+		weights []int
+		want    string
+	}{
+		{[]int{1}, "a"},
+		{[]int{1, 1, 1}, "abc"},
+		{[]int{2, 1}, "aba"},
+		// the heavy instance's picks are interleaved, not bunched up
+		{[]int{5, 1, 1}, "aabacaa"},
+		{[]int{3, 3, 1}, "abcabab"},
+	} {
+		schedule := smoothSchedule(subConns("a", "b", "c")[:len(tt.weights)], tt.weights)
+		if got := scheduleNames(schedule); got != tt.want {
+			t.Errorf("schedule of weights %v = %s, want %s", tt.weights, got, tt.want)
+		}
+	}
+}
+
+// picks returns how many of n picks of p go to each SubConn, by name.
+func picks(t *testing.T, p balancer.Picker, n int) map[string]int {
+	t.Helper()
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		res, err := p.Pick(balancer.PickInfo{})
+		if err != nil {
+			t.Fatalf("Pick: %v", err)
+		}
+		counts[res.SubConn.(*fakeSubConn).name]++
+	}
+	return counts
+}
+
+func TestWrrPickerWeights(t *testing.T) {
+	scs := subConns("a", "b", "c")
+	info := base.PickerBuildInfo{ReadySCs: map[balancer.SubConn]base.SubConnInfo{
+		scs[0]: {Address: withWeight(resolver.Address{Addr: "a:1"}, 3)},
+		scs[1]: {Address: withWeight(resolver.Address{Addr: "b:1"}, 2)},
+		scs[2]: {Address: resolver.Address{Addr: "c:1"}},
+	}}
+
+	// the weights of the addresses the SubConns were created with
+	counts := picks(t, (&wrrPickerBuilder{}).Build(info), 2*6)
+	if counts["a"] != 6 || counts["b"] != 4 || counts["c"] != 2 {
+		t.Errorf("got picks %v in two cycles, want a:6 b:4 c:2", counts)
+	}
+
+	// those of the last resolution win
+	pb := &wrrPickerBuilder{weights: map[string]int{"a:1": 1, "b:1": 5}}
+	counts = picks(t, pb.Build(info), 2*7)
+	if counts["a"] != 2 || counts["b"] != 10 || counts["c"] != 2 {
+		t.Errorf("got picks %v in two cycles, want a:2 b:10 c:2", counts)
+	}
+}
+
+func TestWrrPickerNoReadySubConn(t *testing.T) {
+	p := (&wrrPickerBuilder{}).Build(base.PickerBuildInfo{})
+	if _, err := p.Pick(balancer.PickInfo{}); err != balancer.ErrNoSubConnAvailable {
+		t.Errorf("Pick without SubConns: got %v, want ErrNoSubConnAvailable", err)
+	}
+}
+
+func TestResolverWeight(t *testing.T) {
+	r := &consulResolver{service: "geo"}
+	for _, tt := range []struct {
+		name string
+		meta map[string]string
+		tags []string
+		want int
+	}{
+		{"none", nil, nil, 1},
+		{"meta", map[string]string{registry.WeightMeta: "3"}, nil, 3},
+		{"tag", nil, []string{"primary", registry.WeightMeta + "=4"}, 4},
+		{"meta over tag", map[string]string{registry.WeightMeta: "2"}, []string{registry.WeightMeta + "=4"}, 2},
+		{"not a number", map[string]string{registry.WeightMeta: "heavy"}, nil, 1},
+		{"zero", map[string]string{registry.WeightMeta: "0"}, nil, 1},
+		{"negative", nil, []string{registry.WeightMeta + "=-2"}, 1},
+		{"capped", map[string]string{registry.WeightMeta: "100000"}, nil, maxWeight},
+	} {
+		svc := &consul.AgentService{ID: "geo-1", Meta: tt.meta, Tags: tt.tags}
+		if got := r.weight(svc); got != tt.want {
+			t.Errorf("%s: weight = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+// split makes n calls to client, returning the share of them each of
+// backends got.
+func split(t *testing.T, client healthpb.HealthClient, backends []*backend, n int) []float64 {
+	t.Helper()
+	resetCalls(backends...)
+	check(t, client, n)
+	shares := make([]float64, len(backends))
+	for i, b := range backends {
+		shares[i] = float64(atomic.LoadInt32(&b.calls)) / float64(n)
+	}
+	return shares
+}
+
+// splitWithin reports whether every share is within 0.05 of its want.
+func splitWithin(shares, want []float64) bool {
+	for i := range want {
+		if math.Abs(shares[i]-want[i]) > 0.05 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestWeightedRoundRobin(t *testing.T) {
+	// the last instance publishes no weight, weighing 1
+	backends := []*backend{startBackend(t, 3), startBackend(t, 1), startBackend(t, 0)}
+	cat := &fakeCatalog{}
+	cat.set(backends...)
+	client := dialCatalog(t, cat)
+	waitBalanced(t, client, backends)
+
+	want := []float64{0.6, 0.2, 0.2}
+	if shares := split(t, client, backends, 500); !splitWithin(shares, want) {
+		t.Errorf("got shares %v, want about %v", shares, want)
+	}
+}
+
+func TestWeightChangeKeepsConnections(t *testing.T) {
+	a, b := startBackend(t, 1), startBackend(t, 1)
+	backends := []*backend{a, b}
+	cat := &fakeCatalog{}
+	cat.set(backends...)
+	client := dialCatalog(t, cat)
+	waitBalanced(t, client, backends)
+	conns := []int32{atomic.LoadInt32(&a.conns), atomic.LoadInt32(&b.conns)}
+
+	a.weight = 4
+	cat.set(backends...)
+	want := []float64{0.8, 0.2}
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(50 * time.Millisecond) {
+		shares := split(t, client, backends, 100)
+		if splitWithin(shares, want) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got shares %v after the weight change, want about %v", shares, want)
+		}
+	}
+
+	for i, be := range backends {
+		if n := atomic.LoadInt32(&be.conns); n != conns[i] {
+			t.Errorf("instance %s accepted %d connections, %d before the weight change", be.addr, n, conns[i])
+		}
+	}
+}
@@ -5,38 +5,75 @@ import (
 	"time"
 
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tls"
-	"github.com/grpc-ecosystem/grpc-opentracing/go/otgrpc"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
 	consul "github.com/hashicorp/consul/api"
 	opentracing "github.com/opentracing/opentracing-go"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	_ "google.golang.org/grpc/encoding/gzip" // advertise gzip so servers can compress large responses
 	"google.golang.org/grpc/keepalive"
 )
 
 // DialOption allows optional config for dialer
 type DialOption func(name string) (grpc.DialOption, error)
 
-// WithTracer traces rpc calls
+// WithTracer traces rpc calls, with tracer or OpenTelemetry as selected by
+// TRACING_BACKEND
 func WithTracer(tracer opentracing.Tracer) DialOption {
 	return func(name string) (grpc.DialOption, error) {
-		return grpc.WithUnaryInterceptor(otgrpc.OpenTracingClientInterceptor(tracer)), nil
+		return grpc.WithChainUnaryInterceptor(tracing.ClientTracingInterceptors(tracer)...), nil
+	}
+}
+
+// WithUnaryInterceptors chains interceptors onto the unary calls of the conn,
+// after the tracing interceptor.
+func WithUnaryInterceptors(interceptors ...grpc.UnaryClientInterceptor) DialOption {
+	return func(name string) (grpc.DialOption, error) {
+		return grpc.WithChainUnaryInterceptor(interceptors...), nil
+	}
+}
+
+// WithStreamInterceptors chains interceptors onto the streams of the conn.
+func WithStreamInterceptors(interceptors ...grpc.StreamClientInterceptor) DialOption {
+	return func(name string) (grpc.DialOption, error) {
+		return grpc.WithChainStreamInterceptor(interceptors...), nil
 	}
 }
 
 // WithBalancer enables client side load balancing
 func WithBalancer(registry *consul.Client) DialOption {
 	return func(name string) (grpc.DialOption, error) {
-		return grpc.WithDefaultServiceConfig(`{"loadBalancingConfig": [{"round_robin":{}}]}`), nil
+		return grpc.WithDefaultServiceConfig(weightedRoundRobinConfig), nil
 	}
 }
 
-// Dial returns a load balanced grpc client conn with tracing interceptor
+// Dial returns a load balanced grpc client conn with tracing interceptor.
+// Idle connections are pinged to detect dead backends, and broken ones are
+// dialed again with a backoff capped low enough that they recover promptly
+// once the backend is back, both as tuned by the GRPC_KEEPALIVE_* and
+// GRPC_BACKOFF_* settings.
 func Dial(name string, opts ...DialOption) (*grpc.ClientConn, error) {
 
 	dialopts := []grpc.DialOption{
 		grpc.WithKeepaliveParams(keepalive.ClientParameters{
-			Timeout:             120 * time.Second,
-			PermitWithoutStream: true,
+			Time:                time.Duration(tune.GetKeepaliveTime()) * time.Millisecond,
+			Timeout:             time.Duration(tune.GetKeepaliveTimeout()) * time.Millisecond,
+			PermitWithoutStream: tune.GetKeepalivePermitWithoutStream(),
+		}),
+		grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff: backoff.Config{
+				BaseDelay:  time.Duration(tune.GetBackoffBaseDelay()) * time.Millisecond,
+				Multiplier: backoff.DefaultConfig.Multiplier,
+				Jitter:     backoff.DefaultConfig.Jitter,
+				MaxDelay:   time.Duration(tune.GetBackoffMaxDelay()) * time.Millisecond,
+			},
+			MinConnectTimeout: time.Duration(tune.GetMinConnectTimeout()) * time.Millisecond,
 		}),
+		grpc.WithDefaultCallOptions(
+			grpc.MaxCallRecvMsgSize(tune.GetMaxRecvMsgSize()),
+			grpc.MaxCallSendMsgSize(tune.GetMaxSendMsgSize()),
+		),
 	}
 	if tlsopt := tls.GetDialOpt(); tlsopt != nil {
 		dialopts = append(dialopts, tlsopt)
@@ -0,0 +1,91 @@
+package dialer
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/search/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// largeSearch returns as many hotels as asked by the limit of requests, of
+// 100 byte IDs each.
+type largeSearch struct {
+	pb.UnimplementedSearchServer
+}
+
+func (largeSearch) Nearby(ctx context.Context, req *pb.NearbyRequest) (*pb.SearchResult, error) {
+	res := &pb.SearchResult{HotelIds: make([]string, req.Limit)}
+	for i := range res.HotelIds {
+		res.HotelIds[i] = strings.Repeat("h", 100)
+	}
+	return res, nil
+}
+
+// dialLargeSearch serves largeSearch with the message size limits of the
+// services, and dials it as they do.
+func dialLargeSearch(t *testing.T) pb.SearchClient {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srv := grpc.NewServer(
+		grpc.MaxRecvMsgSize(tune.GetMaxRecvMsgSize()),
+		grpc.MaxSendMsgSize(tune.GetMaxSendMsgSize()),
+	)
+	pb.RegisterSearchServer(srv, largeSearch{})
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := Dial(lis.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewSearchClient(conn)
+}
+
+// searchLarge searches with client for hotels of 100 bytes each, with a
+// request of extra bytes.
+func searchLarge(client pb.SearchClient, hotels int32, extra int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := client.Nearby(ctx, &pb.NearbyRequest{Limit: hotels, InDate: strings.Repeat("d", extra)})
+	return err
+}
+
+func TestMaxMsgSizeDefault(t *testing.T) {
+	client := dialLargeSearch(t)
+	if err := searchLarge(client, 60000, 0); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("6MB response: got %v, want ResourceExhausted", err)
+	}
+	if err := searchLarge(client, 0, 6<<20); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("6MB request: got %v, want ResourceExhausted", err)
+	}
+}
+
+func TestMaxMsgSizeRaised(t *testing.T) {
+	t.Setenv("GRPC_MAX_RECV_MSG_BYTES", "16777216")
+	client := dialLargeSearch(t)
+	if err := searchLarge(client, 60000, 0); err != nil {
+		t.Errorf("6MB response: %v", err)
+	}
+	if err := searchLarge(client, 0, 6<<20); err != nil {
+		t.Errorf("6MB request: %v", err)
+	}
+}
+
+func TestMaxMsgSizeSendLimit(t *testing.T) {
+	t.Setenv("GRPC_MAX_SEND_MSG_BYTES", "1048576")
+	client := dialLargeSearch(t)
+	if err := searchLarge(client, 0, 2<<20); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("2MB request over the send limit: got %v, want ResourceExhausted", err)
+	}
+}
@@ -0,0 +1,125 @@
+package dialer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// Pool spreads RPCs round-robin over up to size client connections to the
+// same target, so bursts of concurrent calls are not all multiplexed on one
+// HTTP/2 connection. Every connection resolves the target on its own, so
+// the Consul resolver keeps discovering new backend instances.
+//
+// Connections are dialed lazily, on the first call that picks their slot.
+// A call skips connections in TRANSIENT_FAILURE as long as another one is
+// usable, and slots whose connection was shut down are dialed again.
+type Pool struct {
+	next uint64 // first for 64-bit alignment of atomic ops
+
+	name string
+	opts []DialOption
+
+	mu     sync.Mutex
+	conns  []*grpc.ClientConn
+	closed bool
+}
+
+// DialPool returns a pool of size connections to name, dialed with opts.
+// The first connection is dialed right away to catch configuration errors.
+func DialPool(name string, size int, opts ...DialOption) (*Pool, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("pool size must be at least 1, got %d", size)
+	}
+
+	p := &Pool{
+		name:  name,
+		opts:  opts,
+		conns: make([]*grpc.ClientConn, size),
+	}
+	if _, err := p.conn(0); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// conn returns the connection of slot i, dialing it if needed.
+func (p *Pool) conn(i int) (*grpc.ClientConn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil, fmt.Errorf("connection pool to %s is closed", p.name)
+	}
+	if c := p.conns[i]; c != nil && c.GetState() != connectivity.Shutdown {
+		return c, nil
+	}
+	c, err := Dial(p.name, p.opts...)
+	if err != nil {
+		return nil, err
+	}
+	p.conns[i] = c
+	return c, nil
+}
+
+// pick returns the next usable connection in round-robin order.
+func (p *Pool) pick() (*grpc.ClientConn, error) {
+	start := int(atomic.AddUint64(&p.next, 1) % uint64(len(p.conns)))
+
+	var first *grpc.ClientConn
+	for n := 0; n < len(p.conns); n++ {
+		c, err := p.conn((start + n) % len(p.conns))
+		if err != nil {
+			return nil, err
+		}
+		if first == nil {
+			first = c
+		}
+		if c.GetState() != connectivity.TransientFailure {
+			return c, nil
+		}
+	}
+	// nothing is healthy, let the RPC fail on the picked connection
+	return first, nil
+}
+
+// Invoke implements grpc.ClientConnInterface.
+func (p *Pool) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	c, err := p.pick()
+	if err != nil {
+		return err
+	}
+	return c.Invoke(ctx, method, args, reply, opts...)
+}
+
+// NewStream implements grpc.ClientConnInterface.
+func (p *Pool) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	c, err := p.pick()
+	if err != nil {
+		return nil, err
+	}
+	return c.NewStream(ctx, desc, method, opts...)
+}
+
+// Close closes every connection dialed by the pool.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	var firstErr error
+	for i, c := range p.conns {
+		if c == nil {
+			continue
+		}
+		if err := c.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		p.conns[i] = nil
+	}
+	return firstErr
+}
@@ -0,0 +1,141 @@
+package dialer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// serveHealth serves the health service on a loopback port for the
+// duration of tb, returning its address.
+func serveHealth(tb testing.TB) string {
+	tb.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatalf("listen: %v", err)
+	}
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(lis)
+	tb.Cleanup(srv.Stop)
+	return lis.Addr().String()
+}
+
+// connCounter counts the calls made on each connection.
+type connCounter struct {
+	mu    sync.Mutex
+	calls map[*grpc.ClientConn]int
+}
+
+func (c *connCounter) interceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	c.mu.Lock()
+	c.calls[cc]++
+	c.mu.Unlock()
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+func TestPoolRoundRobin(t *testing.T) {
+	addr := serveHealth(t)
+	counter := &connCounter{calls: make(map[*grpc.ClientConn]int)}
+	pool, err := DialPool(addr, 4, WithUnaryInterceptors(counter.interceptor))
+	if err != nil {
+		t.Fatalf("DialPool: %v", err)
+	}
+	defer pool.Close()
+
+	client := healthpb.NewHealthClient(pool)
+	const calls = 40
+	for i := 0; i < calls; i++ {
+		if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+			t.Fatalf("Check: %v", err)
+		}
+	}
+
+	if len(counter.calls) != 4 {
+		t.Fatalf("calls spread over %d connections, want 4", len(counter.calls))
+	}
+	for cc, n := range counter.calls {
+		if n != calls/4 {
+			t.Errorf("connection to %s got %d calls, want %d", cc.Target(), n, calls/4)
+		}
+	}
+}
+
+func TestPoolDialsLazily(t *testing.T) {
+	pool, err := DialPool(serveHealth(t), 3)
+	if err != nil {
+		t.Fatalf("DialPool: %v", err)
+	}
+	defer pool.Close()
+
+	dialed := func() int {
+		pool.mu.Lock()
+		defer pool.mu.Unlock()
+		n := 0
+		for _, c := range pool.conns {
+			if c != nil {
+				n++
+			}
+		}
+		return n
+	}
+	if n := dialed(); n != 1 {
+		t.Errorf("%d connections dialed before any call, want 1", n)
+	}
+	client := healthpb.NewHealthClient(pool)
+	if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if n := dialed(); n != 2 {
+		t.Errorf("%d connections dialed after one call, want 2", n)
+	}
+}
+
+func TestPoolClosed(t *testing.T) {
+	pool, err := DialPool(serveHealth(t), 2)
+	if err != nil {
+		t.Fatalf("DialPool: %v", err)
+	}
+	if err := pool.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	client := healthpb.NewHealthClient(pool)
+	if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}); err == nil {
+		t.Errorf("call on a closed pool succeeded")
+	}
+}
+
+func TestPoolSize(t *testing.T) {
+	if _, err := DialPool(serveHealth(t), 0); err == nil {
+		t.Errorf("DialPool of size 0 succeeded")
+	}
+}
+
+func BenchmarkPool(b *testing.B) {
+	addr := serveHealth(b)
+	for _, size := range []int{1, 4} {
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			pool, err := DialPool(addr, size)
+			if err != nil {
+				b.Fatalf("DialPool: %v", err)
+			}
+			defer pool.Close()
+			client := healthpb.NewHealthClient(pool)
+
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+						b.Errorf("Check: %v", err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
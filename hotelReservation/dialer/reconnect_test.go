@@ -0,0 +1,112 @@
+package dialer
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// restartableHealth serves the health service on addr until stopped.
+func restartableHealth(t *testing.T, addr string) (stop func()) {
+	t.Helper()
+	var lis net.Listener
+	var err error
+	// the port of a stopped server may take a moment to be free again
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if lis, err = net.Listen("tcp", addr); err == nil || time.Now().After(deadline) {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return srv.Stop
+}
+
+// captureLogs captures the logs of level info and up for the duration of t.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	logger, level := log.Logger, zerolog.GlobalLevel()
+	t.Cleanup(func() {
+		log.Logger = logger
+		zerolog.SetGlobalLevel(level)
+	})
+	buf := new(bytes.Buffer)
+	log.Logger = zerolog.New(buf)
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	return buf
+}
+
+func TestDialReconnectsAfterRestart(t *testing.T) {
+	t.Setenv("GRPC_BACKOFF_BASE_MS", "20")
+	t.Setenv("GRPC_BACKOFF_MAX_MS", "100")
+	t.Setenv("GRPC_MIN_CONNECT_TIMEOUT_MS", "200")
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := lis.Addr().String()
+	lis.Close()
+	stop := restartableHealth(t, addr)
+
+	conn, err := Dial(addr, WithUnaryInterceptors(tracing.ConnStateUnaryClientInterceptor()))
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+	check := func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+		return err
+	}
+	if err := check(); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	logs := captureLogs(t)
+
+	// the backend bounces, which the connection notices
+	stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if !conn.WaitForStateChange(ctx, connectivity.Ready) {
+		t.Fatalf("connection still %s after the backend stopped", conn.GetState())
+	}
+	if err := check(); status.Code(err) != codes.Unavailable {
+		t.Fatalf("Check with the backend down: got %v, want Unavailable", err)
+	}
+	if !strings.Contains(logs.String(), "call failed with connection to "+addr+" in state") {
+		t.Errorf("got logs %q, want the connection state of the failed call", logs)
+	}
+	restartableHealth(t, addr)
+
+	// calls succeed again once reconnected, without dialing again
+	deadline := time.Now().Add(5 * time.Second)
+	for err = check(); err != nil; err = check() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Check still failing 5s after the restart: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if !strings.Contains(logs.String(), "connection to "+addr+" recovered") {
+		t.Errorf("got logs %q, want the recovery", logs)
+	}
+}
@@ -0,0 +1,271 @@
+package dialer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/registry"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
+	consul "github.com/hashicorp/consul/api"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/resolver"
+)
+
+// Scheme is the target scheme resolved from the Consul catalog, as in
+// consul://<consul address>/<service name>.
+const Scheme = "consul"
+
+const (
+	// minResolveBackoff is the shortest pause between two catalog queries.
+	minResolveBackoff = 50 * time.Millisecond
+	// maxResolveBackoff bounds the wait between two failed catalog queries.
+	maxResolveBackoff = 5 * time.Second
+)
+
+func init() {
+	resolver.Register(&consulBuilder{
+		refresh: func() time.Duration {
+			return time.Second * time.Duration(tune.GetConsulRefreshInterval())
+		},
+		newCatalog: newConsulCatalog,
+	})
+}
+
+// catalog lists the healthy instances of a service. It is implemented by the
+// Consul health endpoint.
+type catalog interface {
+	Service(service, tag string, passingOnly bool, q *consul.QueryOptions) ([]*consul.ServiceEntry, *consul.QueryMeta, error)
+}
+
+func newConsulCatalog(addr string) (catalog, error) {
+	if addr == registry.InProcAddr {
+		return registry.InProc(), nil
+	}
+	cfg := consul.DefaultConfig()
+	cfg.Address = addr
+	c, err := consul.NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return c.Health(), nil
+}
+
+type consulBuilder struct {
+	refresh    func() time.Duration
+	newCatalog func(addr string) (catalog, error)
+}
+
+// Build implements resolver.Builder.
+func (b *consulBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	service := strings.TrimLeft(target.URL.Path, "/")
+	if target.URL.Host == "" || service == "" {
+		return nil, fmt.Errorf("malformed consul target %q, want consul://<consul address>/<service>", target.URL.String())
+	}
+	c, err := b.newCatalog(target.URL.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &consulResolver{
+		service: service,
+		catalog: c,
+		cc:      cc,
+		refresh: b.refresh(),
+		now:     make(chan struct{}, 1),
+		cancel:  cancel,
+	}
+	r.wg.Add(1)
+	go r.watch(ctx)
+	return r, nil
+}
+
+// Scheme implements resolver.Builder.
+func (b *consulBuilder) Scheme() string { return Scheme }
+
+// consulResolver keeps the addresses of a conn in sync with the healthy
+// instances of service. It long-polls the catalog, so registrations and
+// removals are picked up as soon as Consul sees them, and lists the
+// instances again at least every refresh.
+type consulResolver struct {
+	service string
+	catalog catalog
+	cc      resolver.ClientConn
+	refresh time.Duration
+
+	now    chan struct{}
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// ResolveNow implements resolver.Resolver, listing the instances right away.
+func (r *consulResolver) ResolveNow(resolver.ResolveNowOptions) {
+	select {
+	case r.now <- struct{}{}:
+	default:
+	}
+}
+
+// Close implements resolver.Resolver.
+func (r *consulResolver) Close() {
+	r.cancel()
+	r.wg.Wait()
+}
+
+func (r *consulResolver) watch(ctx context.Context) {
+	defer r.wg.Done()
+
+	var lastIndex uint64
+	var last []instance
+	resolved := false
+	backoff := minResolveBackoff
+	for {
+		q := (&consul.QueryOptions{WaitIndex: lastIndex, WaitTime: r.refresh}).WithContext(ctx)
+		entries, meta, err := r.catalog.Service(r.service, "", true, q)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Warn().Msgf("Failed to list instances of %s from consul: %v", r.service, err)
+			r.cc.ReportError(err)
+			if !r.sleep(ctx, backoff) {
+				return
+			}
+			backoff *= 2
+			if backoff > maxResolveBackoff {
+				backoff = maxResolveBackoff
+			}
+			continue
+		}
+		backoff = minResolveBackoff
+
+		// restart the long poll if the index went backwards, e.g. after
+		// consul lost its state
+		if meta.LastIndex < lastIndex {
+			lastIndex = 0
+		} else {
+			lastIndex = meta.LastIndex
+		}
+
+		instances := r.instances(entries)
+		if !resolved || !equalInstances(instances, last) {
+			r.update(instances)
+			last, resolved = instances, true
+		}
+
+		// don't spin if consul answers without blocking
+		if !r.sleep(ctx, minResolveBackoff) {
+			return
+		}
+	}
+}
+
+// sleep waits for d, for a ResolveNow or for ctx to be done, reporting
+// whether to go on.
+func (r *consulResolver) sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-r.now:
+	case <-t.C:
+	}
+	return true
+}
+
+// update hands instances to the conn, to be balanced by weight. An empty
+// list is handed over as well, so that RPCs fail instead of going to
+// instances that are gone.
+func (r *consulResolver) update(instances []instance) {
+	if len(instances) == 0 {
+		log.Warn().Msgf("No healthy instance of %s in consul", r.service)
+	} else {
+		log.Info().Msgf("Resolved %d instances of %s: %v", len(instances), r.service, instances)
+	}
+
+	// set by the resolver itself so that conns dialed without WithBalancer
+	// don't pin to the first instance
+	state := resolver.State{ServiceConfig: r.cc.ParseServiceConfig(weightedRoundRobinConfig)}
+	for _, in := range instances {
+		state.Addresses = append(state.Addresses, withWeight(resolver.Address{Addr: in.addr}, in.weight))
+	}
+	if err := r.cc.UpdateState(state); err != nil && len(instances) > 0 {
+		log.Warn().Msgf("Failed to update the addresses of %s: %v", r.service, err)
+	}
+}
+
+// instance is a resolved instance of a service.
+type instance struct {
+	addr   string
+	weight int
+}
+
+func (in instance) String() string {
+	if in.weight == 1 {
+		return in.addr
+	}
+	return fmt.Sprintf("%s*%d", in.addr, in.weight)
+}
+
+// instances returns the instances of entries, sorted by host:port. Instances
+// registered without an address are reached at the address of their node.
+func (r *consulResolver) instances(entries []*consul.ServiceEntry) []instance {
+	instances := make([]instance, 0, len(entries))
+	for _, e := range entries {
+		host := e.Service.Address
+		if host == "" && e.Node != nil {
+			host = e.Node.Address
+		}
+		instances = append(instances, instance{
+			addr:   net.JoinHostPort(host, strconv.Itoa(e.Service.Port)),
+			weight: r.weight(e.Service),
+		})
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].addr < instances[j].addr })
+	return instances
+}
+
+// weight returns the weight svc publishes in its meta, or else in its tags,
+// capped at maxWeight. Instances without a valid weight weigh 1.
+func (r *consulResolver) weight(svc *consul.AgentService) int {
+	val, ok := svc.Meta[registry.WeightMeta]
+	if !ok {
+		for _, tag := range svc.Tags {
+			if v := strings.TrimPrefix(tag, registry.WeightMeta+"="); v != tag {
+				val, ok = v, true
+				break
+			}
+		}
+	}
+	if !ok {
+		return 1
+	}
+	w, err := strconv.Atoi(val)
+	if err != nil || w <= 0 {
+		log.Debug().Msgf("Ignoring the invalid weight %q of %s instance %s", val, r.service, svc.ID)
+		return 1
+	}
+	if w > maxWeight {
+		return maxWeight
+	}
+	return w
+}
+
+func equalInstances(a, b []instance) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,253 @@
+package dialer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/registry"
+	consul "github.com/hashicorp/consul/api"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// fakeCatalog is a Consul catalog whose instances are set by the test. It
+// answers right away rather than long-polling.
+type fakeCatalog struct {
+	mu      sync.Mutex
+	index   uint64
+	entries []*consul.ServiceEntry
+	err     error
+}
+
+func (c *fakeCatalog) Service(service, tag string, passingOnly bool, q *consul.QueryOptions) ([]*consul.ServiceEntry, *consul.QueryMeta, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return nil, nil, c.err
+	}
+	return c.entries, &consul.QueryMeta{LastIndex: c.index}, nil
+}
+
+// set replaces the instances of the catalog with backends.
+func (c *fakeCatalog) set(backends ...*backend) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
+	for _, b := range backends {
+		c.entries = append(c.entries, b.entry())
+	}
+	c.err = nil
+	c.index++
+}
+
+// fail makes the catalog fail with err until set again.
+func (c *fakeCatalog) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err = err
+}
+
+// backend is an instance serving the health service, counting its calls
+// and the connections it accepts.
+type backend struct {
+	addr   string
+	weight int
+	calls  int32
+	conns  int32
+}
+
+// countingListener counts the connections it accepts.
+type countingListener struct {
+	net.Listener
+	accepted *int32
+}
+
+func (l countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		atomic.AddInt32(l.accepted, 1)
+	}
+	return conn, err
+}
+
+// startBackend starts an instance of weight for the duration of t.
+func startBackend(t *testing.T, weight int) *backend {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	b := &backend{addr: lis.Addr().String(), weight: weight}
+	srv := grpc.NewServer(grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		atomic.AddInt32(&b.calls, 1)
+		return handler(ctx, req)
+	}))
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(countingListener{lis, &b.conns})
+	t.Cleanup(srv.Stop)
+	return b
+}
+
+// entry returns the catalog entry of b, its weight in its meta.
+func (b *backend) entry() *consul.ServiceEntry {
+	host, port, _ := net.SplitHostPort(b.addr)
+	p, _ := strconv.Atoi(port)
+	svc := &consul.AgentService{ID: b.addr, Service: "geo", Address: host, Port: p}
+	if b.weight != 0 {
+		svc.Meta = map[string]string{registry.WeightMeta: strconv.Itoa(b.weight)}
+	}
+	return &consul.ServiceEntry{Service: svc}
+}
+
+// resetCalls zeroes the call counts of backends.
+func resetCalls(backends ...*backend) {
+	for _, b := range backends {
+		atomic.StoreInt32(&b.calls, 0)
+	}
+}
+
+// dialCatalog dials the geo service as resolved from cat.
+func dialCatalog(t *testing.T, cat catalog) healthpb.HealthClient {
+	t.Helper()
+	builder := &consulBuilder{
+		refresh:    func() time.Duration { return time.Second },
+		newCatalog: func(string) (catalog, error) { return cat, nil },
+	}
+	conn, err := grpc.Dial("consul://fake/geo", grpc.WithResolvers(builder), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return healthpb.NewHealthClient(conn)
+}
+
+// check makes n calls to client.
+func check(t *testing.T, client healthpb.HealthClient, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err := client.Check(ctx, &healthpb.HealthCheckRequest{}, grpc.WaitForReady(true))
+		cancel()
+		if err != nil {
+			t.Fatalf("Check: %v", err)
+		}
+	}
+}
+
+// waitBalanced calls client until every one of backends gets calls and none
+// of gone does, so that the balancer is up to date.
+func waitBalanced(t *testing.T, client healthpb.HealthClient, backends []*backend, gone ...*backend) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		resetCalls(append(backends, gone...)...)
+		check(t, client, 10*len(backends))
+		balanced := true
+		for _, b := range backends {
+			balanced = balanced && atomic.LoadInt32(&b.calls) > 0
+		}
+		for _, b := range gone {
+			balanced = balanced && atomic.LoadInt32(&b.calls) == 0
+		}
+		if balanced {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("calls never spread over the resolved instances only")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestResolverRoundRobin(t *testing.T) {
+	backends := []*backend{startBackend(t, 0), startBackend(t, 0), startBackend(t, 0)}
+	cat := &fakeCatalog{}
+	cat.set(backends...)
+	client := dialCatalog(t, cat)
+	waitBalanced(t, client, backends)
+
+	resetCalls(backends...)
+	check(t, client, 30)
+	for _, b := range backends {
+		if n := atomic.LoadInt32(&b.calls); n != 10 {
+			t.Errorf("instance %s got %d of 30 calls, want 10", b.addr, n)
+		}
+	}
+}
+
+func TestResolverFollowsCatalog(t *testing.T) {
+	a, b, c := startBackend(t, 0), startBackend(t, 0), startBackend(t, 0)
+	cat := &fakeCatalog{}
+	cat.set(a, b)
+	client := dialCatalog(t, cat)
+	waitBalanced(t, client, []*backend{a, b})
+
+	// a removed instance stops getting calls
+	cat.set(b)
+	waitBalanced(t, client, []*backend{b}, a)
+
+	// as do those of a new set
+	cat.set(c)
+	waitBalanced(t, client, []*backend{c}, a, b)
+
+	// a new instance gets its share
+	cat.set(a, c)
+	waitBalanced(t, client, []*backend{a, c}, b)
+}
+
+func TestResolverKeepsInstancesOnCatalogErrors(t *testing.T) {
+	a, b := startBackend(t, 0), startBackend(t, 0)
+	cat := &fakeCatalog{}
+	cat.set(a, b)
+	client := dialCatalog(t, cat)
+	waitBalanced(t, client, []*backend{a, b})
+
+	cat.fail(errors.New("consul is down"))
+	time.Sleep(200 * time.Millisecond)
+	waitBalanced(t, client, []*backend{a, b})
+}
+
+func TestResolverNoInstances(t *testing.T) {
+	a := startBackend(t, 0)
+	cat := &fakeCatalog{}
+	cat.set(a)
+	client := dialCatalog(t, cat)
+	waitBalanced(t, client, []*backend{a})
+
+	cat.set()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		_, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+		cancel()
+		if err != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("calls still go through with no instance left")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestResolverMalformedTarget(t *testing.T) {
+	builder := &consulBuilder{
+		refresh:    func() time.Duration { return time.Second },
+		newCatalog: func(string) (catalog, error) { return &fakeCatalog{}, nil },
+	}
+	for _, target := range []string{"consul:///geo", "consul://fake/"} {
+		conn, err := grpc.Dial(target, grpc.WithResolvers(builder), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err == nil {
+			conn.Close()
+			t.Errorf("dialed the malformed target %q", target)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package errdetails
+
+import (
+	"fmt"
+
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails/proto"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// InvalidArgument returns an InvalidArgument error rejecting field for
+// reason. The formatted message is both the status message, for clients
+// ignoring details, and the description of the attached ErrorDetail.
+func InvalidArgument(reason pb.ErrorDetail_Reason, field, format string, a ...interface{}) error {
+	msg := fmt.Sprintf(format, a...)
+	st := status.New(codes.InvalidArgument, msg)
+	if withDetail, err := st.WithDetails(&pb.ErrorDetail{Reason: reason, Field: field, Description: msg}); err == nil {
+		st = withDetail
+	}
+	return st.Err()
+}
+
+// Errorf returns an error of code with the formatted message and details
+// attached, e.g. one per rejected item of a batch request.
+func Errorf(code codes.Code, details []*pb.ErrorDetail, format string, a ...interface{}) error {
+	st := &spb.Status{Code: int32(code), Message: fmt.Sprintf(format, a...)}
+	for _, d := range details {
+		if detail, err := anypb.New(d); err == nil {
+			st.Details = append(st.Details, detail)
+		}
+	}
+	return status.FromProto(st).Err()
+}
+
+// FromError returns the ErrorDetails attached to the gRPC status of err.
+func FromError(err error) []*pb.ErrorDetail {
+	var details []*pb.ErrorDetail
+	for _, d := range status.Convert(err).Details() {
+		if detail, ok := d.(*pb.ErrorDetail); ok {
+			details = append(details, detail)
+		}
+	}
+	return details
+}
@@ -0,0 +1,90 @@
+package errdetails
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails/proto"
+	geo "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/geo/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// failingGeo fails every call with err.
+type failingGeo struct {
+	geo.UnimplementedGeoServer
+	err error
+}
+
+func (g failingGeo) Nearby(context.Context, *geo.Request) (*geo.Result, error) {
+	return nil, g.err
+}
+
+// callFailing returns the error a client gets from a gRPC server failing
+// with err.
+func callFailing(t *testing.T, err error) error {
+	t.Helper()
+	lis, lisErr := net.Listen("tcp", "127.0.0.1:0")
+	if lisErr != nil {
+		t.Fatal(lisErr)
+	}
+	srv := grpc.NewServer()
+	geo.RegisterGeoServer(srv, failingGeo{err: err})
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, dialErr := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if dialErr != nil {
+		t.Fatal(dialErr)
+	}
+	defer conn.Close()
+	_, callErr := geo.NewGeoClient(conn).Nearby(context.Background(), &geo.Request{})
+	return callErr
+}
+
+func TestInvalidArgumentRoundTrip(t *testing.T) {
+	err := callFailing(t, InvalidArgument(pb.ErrorDetail_UNSUPPORTED, "unit", "unsupported distance unit %d", 7))
+
+	// clients ignoring the details get the message
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument || st.Message() != "unsupported distance unit 7" {
+		t.Errorf("got %v, want InvalidArgument with the message", err)
+	}
+
+	details := FromError(err)
+	if len(details) != 1 {
+		t.Fatalf("got details %v, want 1", details)
+	}
+	d := details[0]
+	if d.Reason != pb.ErrorDetail_UNSUPPORTED || d.Field != "unit" || d.Description != "unsupported distance unit 7" {
+		t.Errorf("got detail %v, want the unsupported unit", d)
+	}
+}
+
+func TestErrorfRoundTrip(t *testing.T) {
+	err := callFailing(t, Errorf(codes.InvalidArgument, []*pb.ErrorDetail{
+		{Reason: pb.ErrorDetail_MISSING, Field: "hotelId", Description: "hotel 1: missing hotelId"},
+		{Reason: pb.ErrorDetail_MALFORMED, Field: "inDate", Description: "hotel 2: malformed inDate"},
+	}, "%d invalid items", 2))
+
+	if st := status.Convert(err); st.Code() != codes.InvalidArgument || st.Message() != "2 invalid items" {
+		t.Errorf("got %v, want InvalidArgument with the message", err)
+	}
+	details := FromError(err)
+	if len(details) != 2 || details[0].Field != "hotelId" || details[1].Reason != pb.ErrorDetail_MALFORMED {
+		t.Errorf("got details %v, want those of both items", details)
+	}
+}
+
+func TestFromErrorWithoutDetails(t *testing.T) {
+	err := callFailing(t, status.Error(codes.NotFound, "hotel 1 not found"))
+	if details := FromError(err); len(details) != 0 {
+		t.Errorf("got details %v, want none", details)
+	}
+	if details := FromError(nil); len(details) != 0 {
+		t.Errorf("got details %v of nil, want none", details)
+	}
+}
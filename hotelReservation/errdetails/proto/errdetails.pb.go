@@ -0,0 +1,244 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.31.0
+// 	protoc        v4.25.0
+// source: errdetails/proto/errdetails.proto
+
+package errdetails
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ErrorDetail_Reason int32
+
+const (
+	ErrorDetail_REASON_UNSPECIFIED ErrorDetail_Reason = 0
+	// The field is required but was not set.
+	ErrorDetail_MISSING ErrorDetail_Reason = 1
+	// The field could not be parsed.
+	ErrorDetail_MALFORMED ErrorDetail_Reason = 2
+	// The field is outside its allowed range, or inconsistent with another
+	// field.
+	ErrorDetail_OUT_OF_RANGE ErrorDetail_Reason = 3
+	// The field names something the service does not support.
+	ErrorDetail_UNSUPPORTED ErrorDetail_Reason = 4
+)
+
+// Enum value maps for ErrorDetail_Reason.
+var (
+	ErrorDetail_Reason_name = map[int32]string{
+		0: "REASON_UNSPECIFIED",
+		1: "MISSING",
+		2: "MALFORMED",
+		3: "OUT_OF_RANGE",
+		4: "UNSUPPORTED",
+	}
+	ErrorDetail_Reason_value = map[string]int32{
+		"REASON_UNSPECIFIED": 0,
+		"MISSING":            1,
+		"MALFORMED":          2,
+		"OUT_OF_RANGE":       3,
+		"UNSUPPORTED":        4,
+	}
+)
+
+func (x ErrorDetail_Reason) Enum() *ErrorDetail_Reason {
+	p := new(ErrorDetail_Reason)
+	*p = x
+	return p
+}
+
+func (x ErrorDetail_Reason) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ErrorDetail_Reason) Descriptor() protoreflect.EnumDescriptor {
+	return file_errdetails_proto_errdetails_proto_enumTypes[0].Descriptor()
+}
+
+func (ErrorDetail_Reason) Type() protoreflect.EnumType {
+	return &file_errdetails_proto_errdetails_proto_enumTypes[0]
+}
+
+func (x ErrorDetail_Reason) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ErrorDetail_Reason.Descriptor instead.
+func (ErrorDetail_Reason) EnumDescriptor() ([]byte, []int) {
+	return file_errdetails_proto_errdetails_proto_rawDescGZIP(), []int{0, 0}
+}
+
+// ErrorDetail explains why a request field was rejected. Services attach it
+// to the gRPC status of validation failures.
+type ErrorDetail struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Reason ErrorDetail_Reason `protobuf:"varint,1,opt,name=reason,proto3,enum=errdetails.ErrorDetail_Reason" json:"reason,omitempty"`
+	// field is the name of the rejected request field, empty if the request
+	// is rejected for a combination of fields.
+	Field string `protobuf:"bytes,2,opt,name=field,proto3" json:"field,omitempty"`
+	// description explains the rejection to a human.
+	Description string `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+}
+
+func (x *ErrorDetail) Reset() {
+	*x = ErrorDetail{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_errdetails_proto_errdetails_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ErrorDetail) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ErrorDetail) ProtoMessage() {}
+
+func (x *ErrorDetail) ProtoReflect() protoreflect.Message {
+	mi := &file_errdetails_proto_errdetails_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ErrorDetail.ProtoReflect.Descriptor instead.
+func (*ErrorDetail) Descriptor() ([]byte, []int) {
+	return file_errdetails_proto_errdetails_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ErrorDetail) GetReason() ErrorDetail_Reason {
+	if x != nil {
+		return x.Reason
+	}
+	return ErrorDetail_REASON_UNSPECIFIED
+}
+
+func (x *ErrorDetail) GetField() string {
+	if x != nil {
+		return x.Field
+	}
+	return ""
+}
+
+func (x *ErrorDetail) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+var File_errdetails_proto_errdetails_proto protoreflect.FileDescriptor
+
+var file_errdetails_proto_errdetails_proto_rawDesc = []byte{
+	0x0a, 0x21, 0x65, 0x72, 0x72, 0x64, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x73, 0x2f, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x2f, 0x65, 0x72, 0x72, 0x64, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x73, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x12, 0x0a, 0x65, 0x72, 0x72, 0x64, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x73, 0x22,
+	0xde, 0x01, 0x0a, 0x0b, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x44, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x12,
+	0x36, 0x0a, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32,
+	0x1e, 0x2e, 0x65, 0x72, 0x72, 0x64, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x73, 0x2e, 0x45, 0x72, 0x72,
+	0x6f, 0x72, 0x44, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x2e, 0x52, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x52,
+	0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x12, 0x14, 0x0a, 0x05, 0x66, 0x69, 0x65, 0x6c, 0x64,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x66, 0x69, 0x65, 0x6c, 0x64, 0x12, 0x20, 0x0a,
+	0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x22,
+	0x5f, 0x0a, 0x06, 0x52, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x12, 0x16, 0x0a, 0x12, 0x52, 0x45, 0x41,
+	0x53, 0x4f, 0x4e, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49, 0x45, 0x44, 0x10,
+	0x00, 0x12, 0x0b, 0x0a, 0x07, 0x4d, 0x49, 0x53, 0x53, 0x49, 0x4e, 0x47, 0x10, 0x01, 0x12, 0x0d,
+	0x0a, 0x09, 0x4d, 0x41, 0x4c, 0x46, 0x4f, 0x52, 0x4d, 0x45, 0x44, 0x10, 0x02, 0x12, 0x10, 0x0a,
+	0x0c, 0x4f, 0x55, 0x54, 0x5f, 0x4f, 0x46, 0x5f, 0x52, 0x41, 0x4e, 0x47, 0x45, 0x10, 0x03, 0x12,
+	0x0f, 0x0a, 0x0b, 0x55, 0x4e, 0x53, 0x55, 0x50, 0x50, 0x4f, 0x52, 0x54, 0x45, 0x44, 0x10, 0x04,
+	0x42, 0x4e, 0x5a, 0x4c, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x64,
+	0x65, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x72, 0x6f, 0x75, 0x2f, 0x44, 0x65, 0x61, 0x74, 0x68, 0x53,
+	0x74, 0x61, 0x72, 0x42, 0x65, 0x6e, 0x63, 0x68, 0x2f, 0x74, 0x72, 0x65, 0x65, 0x2f, 0x6d, 0x61,
+	0x73, 0x74, 0x65, 0x72, 0x2f, 0x68, 0x6f, 0x74, 0x65, 0x6c, 0x52, 0x65, 0x73, 0x65, 0x72, 0x76,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2f, 0x65, 0x72, 0x72, 0x64, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x73,
+	0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_errdetails_proto_errdetails_proto_rawDescOnce sync.Once
+	file_errdetails_proto_errdetails_proto_rawDescData = file_errdetails_proto_errdetails_proto_rawDesc
+)
+
+func file_errdetails_proto_errdetails_proto_rawDescGZIP() []byte {
+	file_errdetails_proto_errdetails_proto_rawDescOnce.Do(func() {
+		file_errdetails_proto_errdetails_proto_rawDescData = protoimpl.X.CompressGZIP(file_errdetails_proto_errdetails_proto_rawDescData)
+	})
+	return file_errdetails_proto_errdetails_proto_rawDescData
+}
+
+var file_errdetails_proto_errdetails_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_errdetails_proto_errdetails_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_errdetails_proto_errdetails_proto_goTypes = []interface{}{
+	(ErrorDetail_Reason)(0), // 0: errdetails.ErrorDetail.Reason
+	(*ErrorDetail)(nil),     // 1: errdetails.ErrorDetail
+}
+var file_errdetails_proto_errdetails_proto_depIdxs = []int32{
+	0, // 0: errdetails.ErrorDetail.reason:type_name -> errdetails.ErrorDetail.Reason
+	1, // [1:1] is the sub-list for method output_type
+	1, // [1:1] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_errdetails_proto_errdetails_proto_init() }
+func file_errdetails_proto_errdetails_proto_init() {
+	if File_errdetails_proto_errdetails_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_errdetails_proto_errdetails_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ErrorDetail); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_errdetails_proto_errdetails_proto_rawDesc,
+			NumEnums:      1,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_errdetails_proto_errdetails_proto_goTypes,
+		DependencyIndexes: file_errdetails_proto_errdetails_proto_depIdxs,
+		EnumInfos:         file_errdetails_proto_errdetails_proto_enumTypes,
+		MessageInfos:      file_errdetails_proto_errdetails_proto_msgTypes,
+	}.Build()
+	File_errdetails_proto_errdetails_proto = out.File
+	file_errdetails_proto_errdetails_proto_rawDesc = nil
+	file_errdetails_proto_errdetails_proto_goTypes = nil
+	file_errdetails_proto_errdetails_proto_depIdxs = nil
+}
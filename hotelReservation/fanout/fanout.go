@@ -0,0 +1,40 @@
+// Package fanout bounds the number of queries a request runs at once, so
+// that a large batch of cache misses doesn't flood the database behind it.
+package fanout
+
+import "sync"
+
+// Each calls fn with every index from 0 to n-1, running at most width calls
+// at once, 1 if less, and returns once they all returned. Callers keep their
+// results in input order by storing that of call i at index i.
+func Each(n, width int, fn func(i int)) {
+	if width < 1 {
+		width = 1
+	}
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, width)
+	for i := 0; i < n; i++ {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// Width returns the number of calls Each runs at once for n of them: width,
+// 1 if less, or n if fewer.
+func Width(n, width int) int {
+	if width < 1 {
+		width = 1
+	}
+	if n < width {
+		return n
+	}
+	return width
+}
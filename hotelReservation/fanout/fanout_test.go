@@ -0,0 +1,71 @@
+package fanout
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEach(t *testing.T) {
+	for _, width := range []int{-1, 0, 1, 3, 10, 50} {
+		var running, peak int32
+		calls := make([]int32, 20)
+		Each(len(calls), width, func(i int) {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&calls[i], 1)
+			atomic.AddInt32(&running, -1)
+		})
+		for i, n := range calls {
+			if n != 1 {
+				t.Errorf("width %d: call %d ran %d times, want once", width, i, n)
+			}
+		}
+		if want := Width(len(calls), width); int(peak) > want {
+			t.Errorf("width %d: %d calls ran at once, want at most %d", width, peak, want)
+		}
+	}
+}
+
+func TestEachInputOrder(t *testing.T) {
+	// later calls finish first
+	results := make([]int, 10)
+	var mu sync.Mutex
+	var finished []int
+	Each(len(results), len(results), func(i int) {
+		time.Sleep(time.Duration(len(results)-i) * 5 * time.Millisecond)
+		results[i] = i * i
+		mu.Lock()
+		finished = append(finished, i)
+		mu.Unlock()
+	})
+	for i, r := range results {
+		if r != i*i {
+			t.Errorf("result %d = %d, want %d", i, r, i*i)
+		}
+	}
+	if finished[0] == 0 {
+		t.Errorf("calls finished in order %v, want the last first", finished)
+	}
+}
+
+func TestWidth(t *testing.T) {
+	for _, tt := range []struct{ n, width, want int }{
+		{10, 3, 3},
+		{2, 3, 2},
+		{10, 0, 1},
+		{10, -2, 1},
+		{0, 3, 0},
+	} {
+		if got := Width(tt.n, tt.width); got != tt.want {
+			t.Errorf("Width(%d, %d) = %d, want %d", tt.n, tt.width, got, tt.want)
+		}
+	}
+}
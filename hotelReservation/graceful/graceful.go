@@ -0,0 +1,104 @@
+package graceful
+
+import (
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+)
+
+// Serve calls run, which is expected to block while serving, until it
+// returns or the process gets SIGINT or SIGTERM. On a signal, shutdown is
+// called and Serve waits for run to return.
+func Serve(run func() error, shutdown func()) error {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	errc := make(chan error, 1)
+	go func() { errc <- run() }()
+
+	select {
+	case err := <-errc:
+		return err
+	case sig := <-sigs:
+		log.Info().Msgf("Received %v, shutting down...", sig)
+		shutdown()
+		return <-errc
+	}
+}
+
+// Stop stops srv, letting in-flight RPCs finish for up to the drain timeout
+// before cancelling them.
+func Stop(srv *grpc.Server) {
+	if srv == nil {
+		return
+	}
+
+	timeout := time.Duration(tune.GetShutdownTimeout()) * time.Second
+	done := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Info().Msg("Drained in-flight RPCs")
+	case <-time.After(timeout):
+		log.Warn().Msgf("In-flight RPCs still running after %v, stopping", timeout)
+		srv.Stop()
+		<-done
+	}
+}
+
+// reportTimeout bounds how long Report holds up shutdown.
+const reportTimeout = 2 * time.Second
+
+// Report logs the summary of the traffic srv handled since the registry of
+// its metrics interceptor, tracing.DefaultMetrics, was at since, and writes
+// it to SHUTDOWN_REPORT_DIR if set. It is meant to be called once srv is
+// stopped, and gives up after reportTimeout.
+func Report(srv *grpc.Server, since tracing.MetricsMark) {
+	if srv == nil {
+		return
+	}
+
+	var services []string
+	for name := range srv.GetServiceInfo() {
+		// leave out the health and reflection services
+		if !strings.HasPrefix(name, "grpc.") {
+			services = append(services, name)
+		}
+	}
+	sort.Strings(services)
+	dir := tune.GetShutdownReportDir()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		summary := tracing.DefaultMetrics.Summary(since, services...)
+		summary.Log()
+		if dir == "" {
+			return
+		}
+		if path, err := summary.WriteFile(dir); err != nil {
+			log.Error().Msgf("Failed to write the traffic summary: %v", err)
+		} else {
+			log.Info().Msgf("Wrote the traffic summary to %s", path)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(reportTimeout):
+		log.Warn().Msgf("Traffic summary not reported after %v, shutting down anyway", reportTimeout)
+	}
+}
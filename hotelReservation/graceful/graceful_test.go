@@ -0,0 +1,169 @@
+package graceful
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// slowHealth answers Check once released, or when the call is cancelled.
+type slowHealth struct {
+	healthpb.UnimplementedHealthServer
+	started  chan struct{}
+	released chan struct{}
+}
+
+func (h *slowHealth) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	h.started <- struct{}{}
+	select {
+	case <-h.released:
+		return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// newSlowServer returns a gRPC server of slowHealth and a function serving
+// it on a loopback port, along with a client of it.
+func newSlowServer(t *testing.T) (*grpc.Server, func() error, *slowHealth, healthpb.HealthClient) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	h := &slowHealth{started: make(chan struct{}, 1), released: make(chan struct{})}
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, h)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return srv, func() error { return srv.Serve(lis) }, h, healthpb.NewHealthClient(conn)
+}
+
+// check starts a call of client, returning the channel of its error.
+func check(client healthpb.HealthClient) <-chan error {
+	errc := make(chan error, 1)
+	go func() {
+		_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		errc <- err
+	}()
+	return errc
+}
+
+func TestServeDrainsInFlightCallsOnSignal(t *testing.T) {
+	srv, run, h, client := newSlowServer(t)
+	served := make(chan error, 1)
+	go func() { served <- Serve(run, func() { Stop(srv) }) }()
+
+	call := check(client)
+	select {
+	case <-h.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("slow call not started")
+	}
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatalf("signalling: %v", err)
+	}
+	// draining, so Serve waits for the call
+	select {
+	case err := <-served:
+		t.Fatalf("Serve returned %v before the in-flight call completed", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(h.released)
+	if err := <-call; err != nil {
+		t.Errorf("in-flight call failed: %v", err)
+	}
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("Serve: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve didn't return once drained")
+	}
+}
+
+func TestStopAfterDrainTimeout(t *testing.T) {
+	t.Setenv("SHUTDOWN_TIMEOUT", "0")
+	srv, run, h, client := newSlowServer(t)
+	go run()
+
+	call := check(client)
+	select {
+	case <-h.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("slow call not started")
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		Stop(srv)
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop waited for the in-flight call past the drain timeout")
+	}
+	if err := <-call; status.Code(err) != codes.Unavailable && status.Code(err) != codes.Canceled {
+		t.Errorf("call cut by the hard stop: got %v, want Unavailable or Canceled", err)
+	}
+}
+
+func TestStopNil(t *testing.T) {
+	Stop(nil)
+}
+
+func TestReport(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SHUTDOWN_REPORT_DIR", dir)
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, &slowHealth{})
+	srv.RegisterService(&grpc.ServiceDesc{ServiceName: "report.Test", HandlerType: (*interface{})(nil)}, struct{}{})
+
+	// traffic of an earlier server of the process, and of other services
+	tracing.DefaultMetrics.Observe("/report.Test/Call", codes.OK, time.Millisecond)
+	mark := tracing.DefaultMetrics.Mark()
+	for i := 0; i < 3; i++ {
+		tracing.DefaultMetrics.Observe("/report.Test/Call", codes.OK, time.Millisecond)
+	}
+	tracing.DefaultMetrics.Observe("/report.Test/Call", codes.Internal, time.Millisecond)
+	tracing.DefaultMetrics.Observe("/report.Other/Call", codes.OK, time.Millisecond)
+
+	Report(srv, mark)
+	b, err := os.ReadFile(filepath.Join(dir, fmt.Sprintf("report.Test-%d.json", os.Getpid())))
+	if err != nil {
+		t.Fatalf("reading the report: %v", err)
+	}
+	var summary tracing.TrafficSummary
+	if err := json.Unmarshal(b, &summary); err != nil {
+		t.Fatalf("decoding %s: %v", b, err)
+	}
+	if len(summary.Services) != 1 || summary.Requests != 4 || summary.Errors != 1 || len(summary.Methods) != 1 {
+		t.Errorf("got report %s, want 4 calls of report.Test since the mark", b)
+	}
+}
+
+func TestReportNil(t *testing.T) {
+	Report(nil, tracing.MetricsMark{})
+}
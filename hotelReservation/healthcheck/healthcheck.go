@@ -0,0 +1,183 @@
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/store"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Probe checks that a dependency of a service is reachable.
+type Probe struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// Mongo returns a probe pinging the primary of client.
+func Mongo(client *mongo.Client) Probe {
+	return Probe{
+		Name: "mongo",
+		Check: func(ctx context.Context) error {
+			return client.Ping(ctx, readpref.Primary())
+		},
+	}
+}
+
+// Memcached returns a probe pinging every server of client.
+func Memcached(client store.Memcache) Probe {
+	return Probe{
+		Name: "memcached",
+		Check: func(ctx context.Context) error {
+			return client.Ping()
+		},
+	}
+}
+
+// Gate is a probe of the server itself rather than of a dependency: it
+// fails until opened, e.g. to report a server NOT_SERVING while it warms up.
+type Gate struct {
+	name string
+	open chan struct{}
+	once sync.Once
+}
+
+// NewGate returns a closed gate named name.
+func NewGate(name string) *Gate {
+	return &Gate{name: name, open: make(chan struct{})}
+}
+
+// Open opens g for good.
+func (g *Gate) Open() {
+	g.once.Do(func() { close(g.open) })
+}
+
+// Probe returns the probe failing until g is opened.
+func (g *Gate) Probe() Probe {
+	return Probe{
+		Name: g.name,
+		Check: func(ctx context.Context) error {
+			select {
+			case <-g.open:
+				return nil
+			default:
+				return fmt.Errorf("%s in progress", g.name)
+			}
+		},
+	}
+}
+
+// Checker serves the standard grpc.health.v1.Health service for a gRPC
+// server. The server is reported NOT_SERVING until all its probes pass, and
+// again whenever one of them fails.
+type Checker struct {
+	server   *health.Server
+	service  string
+	probes   []Probe
+	interval time.Duration
+
+	mu      sync.Mutex
+	serving bool
+	done    chan struct{}
+	stop    sync.Once
+}
+
+// Register adds the health service to srv, reporting the status of service
+// (the full name of the gRPC service, e.g. "geo.Geo") and of the server as a
+// whole. Probing starts with Start.
+func Register(srv *grpc.Server, service string, probes ...Probe) *Checker {
+	c := &Checker{
+		server:   health.NewServer(),
+		service:  service,
+		probes:   probes,
+		interval: time.Duration(tune.GetHealthCheckInterval()) * time.Second,
+		done:     make(chan struct{}),
+	}
+	c.setStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(srv, c.server)
+	return c
+}
+
+// Start probes the dependencies right away and then at every interval, until
+// Shutdown is called.
+func (c *Checker) Start() {
+	go func() {
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			c.Check()
+			select {
+			case <-c.done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Check runs every probe once and updates the reported status.
+func (c *Checker) Check() {
+	for _, p := range c.probes {
+		ctx, cancel := context.WithTimeout(context.Background(), c.interval)
+		err := p.Check(ctx)
+		cancel()
+		if err != nil {
+			if c.setServing(false) {
+				log.Warn().Msgf("Health: %s is NOT_SERVING, %s probe failed: %v", c.service, p.Name, err)
+			}
+			return
+		}
+	}
+	if c.setServing(true) {
+		log.Info().Msgf("Health: %s is SERVING", c.service)
+	}
+}
+
+// setServing records whether the server is serving, reporting whether that
+// changed.
+func (c *Checker) setServing(serving bool) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	if c.serving == serving {
+		return false
+	}
+	c.serving = serving
+	if serving {
+		c.setStatus(healthpb.HealthCheckResponse_SERVING)
+	} else {
+		c.setStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+	return true
+}
+
+func (c *Checker) setStatus(status healthpb.HealthCheckResponse_ServingStatus) {
+	c.server.SetServingStatus("", status)
+	c.server.SetServingStatus(c.service, status)
+}
+
+// Shutdown stops probing and reports the server NOT_SERVING for good.
+func (c *Checker) Shutdown() {
+	if c == nil {
+		return
+	}
+	c.stop.Do(func() {
+		c.mu.Lock()
+		close(c.done)
+		c.mu.Unlock()
+		c.server.Shutdown()
+	})
+}
@@ -0,0 +1,176 @@
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/inproc/fakestore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// dependency is a probe target that can be taken down.
+type dependency struct {
+	down int32
+}
+
+// setDown takes d down or brings it back up.
+func (d *dependency) setDown(down bool) {
+	v := int32(0)
+	if down {
+		v = 1
+	}
+	atomic.StoreInt32(&d.down, v)
+}
+
+func (d *dependency) probe() Probe {
+	return Probe{
+		Name: "dependency",
+		Check: func(ctx context.Context) error {
+			if atomic.LoadInt32(&d.down) == 1 {
+				return errors.New("connection refused")
+			}
+			return nil
+		},
+	}
+}
+
+// serve registers a checker of probes on a gRPC server on a loopback port,
+// returning it and a health client of the server.
+func serve(t *testing.T, probes ...Probe) (*Checker, healthpb.HealthClient) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srv := grpc.NewServer()
+	c := Register(srv, "test.Test", probes...)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	t.Cleanup(c.Shutdown)
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return c, healthpb.NewHealthClient(conn)
+}
+
+// statusOf returns the status client reports for service.
+func statusOf(t *testing.T, client healthpb.HealthClient, service string) healthpb.HealthCheckResponse_ServingStatus {
+	t.Helper()
+	res, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		t.Fatalf("Check(%q): %v", service, err)
+	}
+	return res.Status
+}
+
+// waitStatus waits for client to report want for the server as a whole.
+func waitStatus(t *testing.T, client healthpb.HealthClient, want healthpb.HealthCheckResponse_ServingStatus) {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); ; {
+		got := statusOf(t, client, "")
+		if got == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got status %v, want %v", got, want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDependencyOutage(t *testing.T) {
+	dep := &dependency{}
+	c, client := serve(t, dep.probe())
+
+	// NOT_SERVING until the first probe
+	for _, service := range []string{"", "test.Test"} {
+		if got := statusOf(t, client, service); got != healthpb.HealthCheckResponse_NOT_SERVING {
+			t.Errorf("%q before probing: got %v, want NOT_SERVING", service, got)
+		}
+	}
+
+	c.Check()
+	for _, service := range []string{"", "test.Test"} {
+		if got := statusOf(t, client, service); got != healthpb.HealthCheckResponse_SERVING {
+			t.Errorf("%q with the dependency up: got %v, want SERVING", service, got)
+		}
+	}
+
+	dep.setDown(true)
+	c.Check()
+	if got := statusOf(t, client, "test.Test"); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("with the dependency down: got %v, want NOT_SERVING", got)
+	}
+
+	dep.setDown(false)
+	c.Check()
+	if got := statusOf(t, client, "test.Test"); got != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("with the dependency back: got %v, want SERVING", got)
+	}
+}
+
+func TestBackgroundProbing(t *testing.T) {
+	dep := &dependency{}
+	c, client := serve(t, dep.probe())
+	c.interval = 10 * time.Millisecond
+	c.Start()
+	waitStatus(t, client, healthpb.HealthCheckResponse_SERVING)
+
+	dep.setDown(true)
+	waitStatus(t, client, healthpb.HealthCheckResponse_NOT_SERVING)
+	dep.setDown(false)
+	waitStatus(t, client, healthpb.HealthCheckResponse_SERVING)
+
+	// stays NOT_SERVING for good once shut down
+	c.Shutdown()
+	waitStatus(t, client, healthpb.HealthCheckResponse_NOT_SERVING)
+	time.Sleep(5 * c.interval)
+	if got := statusOf(t, client, ""); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("after shutdown: got %v, want NOT_SERVING", got)
+	}
+}
+
+func TestGate(t *testing.T) {
+	gate := NewGate("warmup")
+	c, client := serve(t, gate.Probe())
+	c.Check()
+	if got := statusOf(t, client, ""); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("gate closed: got %v, want NOT_SERVING", got)
+	}
+	gate.Open()
+	gate.Open()
+	c.Check()
+	if got := statusOf(t, client, ""); got != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("gate open: got %v, want SERVING", got)
+	}
+}
+
+func TestStoreProbes(t *testing.T) {
+	ctx := context.Background()
+	if err := Mongo(fakestore.Mongo(t)).Check(ctx); err != nil {
+		t.Errorf("mongo probe: %v", err)
+	}
+	if err := Memcached(fakestore.Memcached(t)).Check(ctx); err != nil {
+		t.Errorf("memcached probe: %v", err)
+	}
+
+	srv, err := fakestore.NewMemcServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("starting memcached: %v", err)
+	}
+	client := memcache.New(srv.Addr())
+	srv.Close()
+	if err := Memcached(client).Check(ctx); err == nil {
+		t.Errorf("memcached probe passed with memcached down")
+	}
+}
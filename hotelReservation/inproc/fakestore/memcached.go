@@ -0,0 +1,309 @@
+package fakestore
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRelativeExpiration is the largest expiration memcached takes as
+// relative to now, larger ones being unix timestamps.
+const maxRelativeExpiration = 30 * 24 * 60 * 60
+
+// MemcServer is an in-memory stand-in for memcached, speaking its text
+// protocol.
+type MemcServer struct {
+	lis net.Listener
+	wg  sync.WaitGroup
+
+	mu     sync.Mutex
+	items  map[string]*memcItem
+	cas    uint64
+	conns  map[net.Conn]bool
+	closed bool
+}
+
+type memcItem struct {
+	value   []byte
+	flags   uint32
+	cas     uint64
+	expires time.Time
+}
+
+// NewMemcServer starts a memcached stand-in listening on addr.
+func NewMemcServer(addr string) (*MemcServer, error) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &MemcServer{
+		lis:   lis,
+		items: make(map[string]*memcItem),
+		conns: make(map[net.Conn]bool),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr returns the host:port the server listens on.
+func (s *MemcServer) Addr() string {
+	return s.lis.Addr().String()
+}
+
+// Close stops the server, closing all the connections to it.
+func (s *MemcServer) Close() {
+	s.mu.Lock()
+	s.closed = true
+	s.lis.Close()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+func (s *MemcServer) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.lis.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = true
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+			conn.Close()
+		}()
+	}
+}
+
+// handle answers the commands sent on conn until it is closed.
+func (s *MemcServer) handle(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		args := strings.Fields(line)
+		if len(args) == 0 {
+			fmt.Fprint(w, "ERROR\r\n")
+		} else if !s.command(args, r, w) {
+			return
+		}
+		if w.Flush() != nil {
+			return
+		}
+	}
+}
+
+// command runs the command of args, reading its data from r and writing
+// the reply to w. It reports whether to keep the connection open. Data is
+// read before locking the items, so that slow clients don't hold others.
+func (s *MemcServer) command(args []string, r *bufio.Reader, w *bufio.Writer) bool {
+	switch verb := args[0]; verb {
+	case "set", "add", "replace", "append", "prepend", "cas":
+		if len(args) < 5 || (verb == "cas" && len(args) < 6) {
+			w.WriteString("ERROR\r\n")
+			return true
+		}
+		flags, err1 := strconv.ParseUint(args[2], 10, 32)
+		exp, err2 := strconv.ParseInt(args[3], 10, 64)
+		size, err3 := strconv.Atoi(args[4])
+		if err1 != nil || err2 != nil || err3 != nil || size < 0 {
+			w.WriteString("CLIENT_ERROR bad command line format\r\n")
+			return false
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return false
+		}
+		if string(data[size:]) != "\r\n" {
+			w.WriteString("CLIENT_ERROR bad data chunk\r\n")
+			return false
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		w.WriteString(s.store(verb, args, uint32(flags), exp, data[:size], time.Now()))
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+
+	switch verb := args[0]; verb {
+	case "get", "gets":
+		for _, key := range args[1:] {
+			it := s.get(key, now)
+			if it == nil {
+				continue
+			}
+			if verb == "gets" {
+				fmt.Fprintf(w, "VALUE %s %d %d %d\r\n", key, it.flags, len(it.value), it.cas)
+			} else {
+				fmt.Fprintf(w, "VALUE %s %d %d\r\n", key, it.flags, len(it.value))
+			}
+			w.Write(it.value)
+			w.WriteString("\r\n")
+		}
+		w.WriteString("END\r\n")
+
+	case "delete":
+		if len(args) < 2 {
+			w.WriteString("ERROR\r\n")
+		} else if s.get(args[1], now) == nil {
+			w.WriteString("NOT_FOUND\r\n")
+		} else {
+			delete(s.items, args[1])
+			w.WriteString("DELETED\r\n")
+		}
+
+	case "incr", "decr":
+		if len(args) < 3 {
+			w.WriteString("ERROR\r\n")
+			return true
+		}
+		delta, err := strconv.ParseUint(args[2], 10, 64)
+		if err != nil {
+			w.WriteString("CLIENT_ERROR invalid numeric delta argument\r\n")
+			return true
+		}
+		it := s.get(args[1], now)
+		if it == nil {
+			w.WriteString("NOT_FOUND\r\n")
+			return true
+		}
+		n, err := strconv.ParseUint(strings.TrimSpace(string(it.value)), 10, 64)
+		if err != nil {
+			w.WriteString("CLIENT_ERROR cannot increment or decrement non-numeric value\r\n")
+			return true
+		}
+		if verb == "incr" {
+			n += delta
+		} else if delta > n {
+			n = 0
+		} else {
+			n -= delta
+		}
+		it.value = []byte(strconv.FormatUint(n, 10))
+		s.cas++
+		it.cas = s.cas
+		fmt.Fprintf(w, "%d\r\n", n)
+
+	case "touch":
+		if len(args) < 3 {
+			w.WriteString("ERROR\r\n")
+			return true
+		}
+		exp, err := strconv.ParseInt(args[2], 10, 64)
+		it := s.get(args[1], now)
+		if err != nil || it == nil {
+			w.WriteString("NOT_FOUND\r\n")
+			return true
+		}
+		it.expires = expiration(exp, now)
+		w.WriteString("TOUCHED\r\n")
+
+	case "flush_all":
+		s.items = make(map[string]*memcItem)
+		w.WriteString("OK\r\n")
+
+	case "version":
+		w.WriteString("VERSION 1.6.0-inproc\r\n")
+
+	case "quit":
+		return false
+
+	default:
+		w.WriteString("ERROR\r\n")
+	}
+	return true
+}
+
+// store runs a storage command, returning its reply.
+func (s *MemcServer) store(verb string, args []string, flags uint32, exp int64, value []byte, now time.Time) string {
+	key := args[1]
+	it := s.get(key, now)
+	expires := expiration(exp, now)
+	switch verb {
+	case "add":
+		if it != nil {
+			return "NOT_STORED\r\n"
+		}
+	case "replace":
+		if it == nil {
+			return "NOT_STORED\r\n"
+		}
+	case "append", "prepend":
+		if it == nil {
+			return "NOT_STORED\r\n"
+		}
+		if verb == "append" {
+			value = append(append([]byte(nil), it.value...), value...)
+		} else {
+			value = append(append([]byte(nil), value...), it.value...)
+		}
+		// the item keeps its flags and expiration
+		flags, expires = it.flags, it.expires
+	case "cas":
+		if it == nil {
+			return "NOT_FOUND\r\n"
+		}
+		if cas, err := strconv.ParseUint(args[5], 10, 64); err != nil || cas != it.cas {
+			return "EXISTS\r\n"
+		}
+	}
+
+	s.cas++
+	s.items[key] = &memcItem{value: value, flags: flags, cas: s.cas, expires: expires}
+	return "STORED\r\n"
+}
+
+// get returns the item of key, nil if missing or expired.
+func (s *MemcServer) get(key string, now time.Time) *memcItem {
+	it, ok := s.items[key]
+	if !ok {
+		return nil
+	}
+	if !it.expires.IsZero() && !now.Before(it.expires) {
+		delete(s.items, key)
+		return nil
+	}
+	return it
+}
+
+// expiration returns when an item stored at now with expiration exp
+// expires, the zero time for never.
+func expiration(exp int64, now time.Time) time.Time {
+	switch {
+	case exp == 0:
+		return time.Time{}
+	case exp < 0:
+		return now
+	case exp > maxRelativeExpiration:
+		return time.Unix(exp, 0)
+	}
+	return now.Add(time.Duration(exp) * time.Second)
+}
@@ -0,0 +1,1147 @@
+// Package fakestore provides in-memory stand-ins for MongoDB and memcached
+// speaking their wire protocols, so that the real clients can be used
+// against them in tests and in-process runs.
+package fakestore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Opcodes of the MongoDB wire protocol.
+const (
+	opReply = 1
+	opQuery = 2004
+	opMsg   = 2013
+)
+
+// Error codes of the MongoDB server.
+const (
+	codeBadValue        = 2
+	codeCommandNotFound = 59
+	codeDuplicateKey    = 11000
+)
+
+// maxMongoMessage bounds the size of the messages read from clients.
+const maxMongoMessage = 48000000
+
+// MongoServer is an in-memory stand-in for a standalone MongoDB, speaking
+// enough of its wire protocol for the queries of the services: find,
+// insert, update, delete, findAndModify and createIndexes, with equality,
+// comparison, $in, $exists and logical filters and $set, $unset, $inc and
+// $setOnInsert updates. Unique indexes are enforced.
+type MongoServer struct {
+	lis net.Listener
+	wg  sync.WaitGroup
+
+	mu     sync.Mutex
+	colls  map[string]*mongoCollection // by namespace, db.collection
+	conns  map[net.Conn]bool
+	connID int32
+	closed bool
+}
+
+type mongoCollection struct {
+	docs []bson.D
+	// unique are the fields of the unique indexes, by index name.
+	unique map[string][]string
+	// indexes counts the indexes of the collection, _id included.
+	indexes int
+}
+
+// mongoError is a command failure reported to the client.
+type mongoError struct {
+	code int32
+	msg  string
+}
+
+func (e *mongoError) Error() string { return e.msg }
+
+// NewMongoServer starts a MongoDB stand-in listening on addr.
+func NewMongoServer(addr string) (*MongoServer, error) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &MongoServer{
+		lis:   lis,
+		colls: make(map[string]*mongoCollection),
+		conns: make(map[net.Conn]bool),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr returns the host:port the server listens on.
+func (s *MongoServer) Addr() string {
+	return s.lis.Addr().String()
+}
+
+// Close stops the server, closing all the connections to it.
+func (s *MongoServer) Close() {
+	s.mu.Lock()
+	s.closed = true
+	s.lis.Close()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+func (s *MongoServer) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.lis.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = true
+		s.connID++
+		id := s.connID
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn, id)
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+			conn.Close()
+		}()
+	}
+}
+
+// handle answers the requests sent on conn until it is closed.
+func (s *MongoServer) handle(conn net.Conn, id int32) {
+	r := bufio.NewReader(conn)
+	var header [16]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return
+		}
+		length := int32(binary.LittleEndian.Uint32(header[0:]))
+		requestID := int32(binary.LittleEndian.Uint32(header[4:]))
+		opcode := int32(binary.LittleEndian.Uint32(header[12:]))
+		if length < 16 || length > maxMongoMessage {
+			log.Error().Msgf("inproc mongo: invalid message length %d", length)
+			return
+		}
+		body := make([]byte, length-16)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return
+		}
+
+		var reply []byte
+		switch opcode {
+		case opMsg:
+			cmd, err := parseOpMsg(body)
+			if err != nil {
+				log.Error().Msgf("inproc mongo: %v", err)
+				return
+			}
+			reply = opMsgReply(requestID, s.run(cmd, "", id))
+		case opQuery:
+			cmd, db, err := parseOpQuery(body)
+			if err != nil {
+				log.Error().Msgf("inproc mongo: %v", err)
+				return
+			}
+			reply = opReplyReply(requestID, s.run(cmd, db, id))
+		default:
+			log.Error().Msgf("inproc mongo: unsupported opcode %d", opcode)
+			return
+		}
+		if _, err := conn.Write(reply); err != nil {
+			return
+		}
+	}
+}
+
+// parseOpMsg returns the command of an OP_MSG body, with its document
+// sequences added as arrays.
+func parseOpMsg(body []byte) (bson.D, error) {
+	if len(body) < 5 {
+		return nil, fmt.Errorf("truncated OP_MSG")
+	}
+	flags := binary.LittleEndian.Uint32(body)
+	body = body[4:]
+	if flags&1 != 0 {
+		// checksum present
+		body = body[:len(body)-4]
+	}
+
+	var cmd bson.D
+	var seqs bson.D
+	for len(body) > 0 {
+		kind := body[0]
+		body = body[1:]
+		switch kind {
+		case 0:
+			doc, rest, err := readDocument(body)
+			if err != nil {
+				return nil, err
+			}
+			cmd, body = doc, rest
+		case 1:
+			if len(body) < 4 {
+				return nil, fmt.Errorf("truncated document sequence")
+			}
+			size := int(binary.LittleEndian.Uint32(body))
+			if size < 4 || size > len(body) {
+				return nil, fmt.Errorf("invalid document sequence size %d", size)
+			}
+			seq := body[4:size]
+			body = body[size:]
+			end := bytes.IndexByte(seq, 0)
+			if end < 0 {
+				return nil, fmt.Errorf("invalid document sequence identifier")
+			}
+			ident := string(seq[:end])
+			seq = seq[end+1:]
+			var docs bson.A
+			for len(seq) > 0 {
+				doc, rest, err := readDocument(seq)
+				if err != nil {
+					return nil, err
+				}
+				docs, seq = append(docs, doc), rest
+			}
+			seqs = append(seqs, bson.E{Key: ident, Value: docs})
+		default:
+			return nil, fmt.Errorf("unknown OP_MSG section kind %d", kind)
+		}
+	}
+	return append(cmd, seqs...), nil
+}
+
+// parseOpQuery returns the command of an OP_QUERY body and its database.
+// Drivers only send commands, e.g. the connection handshake, that way.
+func parseOpQuery(body []byte) (bson.D, string, error) {
+	if len(body) < 4 {
+		return nil, "", fmt.Errorf("truncated OP_QUERY")
+	}
+	body = body[4:]
+	end := bytes.IndexByte(body, 0)
+	if end < 0 || len(body) < end+9 {
+		return nil, "", fmt.Errorf("truncated OP_QUERY")
+	}
+	ns := string(body[:end])
+	cmd, _, err := readDocument(body[end+9:])
+	if err != nil {
+		return nil, "", err
+	}
+	if q, ok := lookup(cmd, "$query"); ok {
+		if d, ok := q.(bson.D); ok {
+			cmd = d
+		}
+	}
+	return cmd, strings.TrimSuffix(ns, ".$cmd"), nil
+}
+
+// readDocument decodes the BSON document at the start of b, returning it
+// and the bytes after it.
+func readDocument(b []byte) (bson.D, []byte, error) {
+	if len(b) < 5 {
+		return nil, nil, fmt.Errorf("truncated document")
+	}
+	size := int(binary.LittleEndian.Uint32(b))
+	if size < 5 || size > len(b) {
+		return nil, nil, fmt.Errorf("invalid document size %d", size)
+	}
+	var doc bson.D
+	if err := bson.Unmarshal(b[:size], &doc); err != nil {
+		return nil, nil, err
+	}
+	return doc, b[size:], nil
+}
+
+func opMsgReply(responseTo int32, doc bson.D) []byte {
+	raw, _ := bson.Marshal(doc)
+	msg := make([]byte, 21, 21+len(raw))
+	msg = append(msg, raw...)
+	putHeader(msg, responseTo, opMsg)
+	// flags are zero, section 0 is the reply
+	msg[20] = 0
+	return msg
+}
+
+func opReplyReply(responseTo int32, doc bson.D) []byte {
+	raw, _ := bson.Marshal(doc)
+	msg := make([]byte, 36, 36+len(raw))
+	msg = append(msg, raw...)
+	putHeader(msg, responseTo, opReply)
+	// flags, cursor id and starting from are zero
+	binary.LittleEndian.PutUint32(msg[32:], 1)
+	return msg
+}
+
+func putHeader(msg []byte, responseTo, opcode int32) {
+	binary.LittleEndian.PutUint32(msg[0:], uint32(len(msg)))
+	binary.LittleEndian.PutUint32(msg[4:], 0)
+	binary.LittleEndian.PutUint32(msg[8:], uint32(responseTo))
+	binary.LittleEndian.PutUint32(msg[12:], uint32(opcode))
+}
+
+// run executes cmd on database db, given by cmd itself when empty.
+func (s *MongoServer) run(cmd bson.D, db string, connID int32) bson.D {
+	if len(cmd) == 0 {
+		return errorReply(&mongoError{codeBadValue, "empty command"})
+	}
+	if v, ok := lookup(cmd, "$db"); ok {
+		db, _ = v.(string)
+	}
+	name := cmd[0].Key
+	coll, _ := cmd[0].Value.(string)
+	ns := db + "." + coll
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var reply bson.D
+	var err error
+	switch strings.ToLower(name) {
+	case "hello", "ismaster":
+		reply = bson.D{
+			{Key: "ismaster", Value: true},
+			{Key: "isWritablePrimary", Value: true},
+			{Key: "helloOk", Value: true},
+			{Key: "maxBsonObjectSize", Value: int32(16 * 1024 * 1024)},
+			{Key: "maxMessageSizeBytes", Value: int32(maxMongoMessage)},
+			{Key: "maxWriteBatchSize", Value: int32(100000)},
+			{Key: "localTime", Value: primitive.NewDateTimeFromTime(time.Now())},
+			{Key: "connectionId", Value: connID},
+			{Key: "minWireVersion", Value: int32(0)},
+			{Key: "maxWireVersion", Value: int32(13)},
+			{Key: "readOnly", Value: false},
+		}
+	case "ping", "endsessions", "killcursors":
+	case "buildinfo":
+		reply = bson.D{{Key: "version", Value: "5.0.0"}}
+	case "find":
+		reply, err = s.find(ns, cmd)
+	case "insert":
+		reply, err = s.insert(ns, cmd)
+	case "update":
+		reply, err = s.update(ns, cmd)
+	case "delete":
+		reply, err = s.delete(ns, cmd)
+	case "findandmodify":
+		reply, err = s.findAndModify(ns, cmd)
+	case "createindexes":
+		reply, err = s.createIndexes(ns, cmd)
+	case "drop":
+		delete(s.colls, ns)
+	default:
+		err = &mongoError{codeCommandNotFound, fmt.Sprintf("no such command: '%s'", name)}
+	}
+	if err != nil {
+		return errorReply(err)
+	}
+	return append(reply, bson.E{Key: "ok", Value: 1.0})
+}
+
+func errorReply(err error) bson.D {
+	code := int32(codeBadValue)
+	if merr, ok := err.(*mongoError); ok {
+		code = merr.code
+	}
+	return bson.D{
+		{Key: "ok", Value: 0.0},
+		{Key: "errmsg", Value: err.Error()},
+		{Key: "code", Value: code},
+	}
+}
+
+// collection returns the collection of namespace ns, creating it if needed.
+func (s *MongoServer) collection(ns string) *mongoCollection {
+	c, ok := s.colls[ns]
+	if !ok {
+		c = &mongoCollection{unique: make(map[string][]string), indexes: 1}
+		s.colls[ns] = c
+	}
+	return c
+}
+
+func (s *MongoServer) find(ns string, cmd bson.D) (bson.D, error) {
+	filter := docValue(cmd, "filter")
+	c := s.collection(ns)
+	var docs bson.A
+	for _, doc := range c.docs {
+		ok, err := matches(doc, filter)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			docs = append(docs, doc)
+		}
+	}
+
+	if order := docValue(cmd, "sort"); len(order) > 0 {
+		sort.SliceStable(docs, func(i, j int) bool {
+			return less(docs[i].(bson.D), docs[j].(bson.D), order)
+		})
+	}
+	if skip := intValue(cmd, "skip"); skip > 0 {
+		if skip > len(docs) {
+			skip = len(docs)
+		}
+		docs = docs[skip:]
+	}
+	if limit := intValue(cmd, "limit"); limit != 0 {
+		if limit < 0 {
+			limit = -limit
+		}
+		if limit < len(docs) {
+			docs = docs[:limit]
+		}
+	}
+	if docs == nil {
+		docs = bson.A{}
+	}
+
+	return bson.D{{Key: "cursor", Value: bson.D{
+		{Key: "firstBatch", Value: docs},
+		{Key: "id", Value: int64(0)},
+		{Key: "ns", Value: ns},
+	}}}, nil
+}
+
+func (s *MongoServer) insert(ns string, cmd bson.D) (bson.D, error) {
+	c := s.collection(ns)
+	docs, _ := lookupArray(cmd, "documents")
+	ordered := boolValue(cmd, "ordered", true)
+
+	n := int32(0)
+	var writeErrors bson.A
+	for i, v := range docs {
+		doc, ok := v.(bson.D)
+		if !ok {
+			return nil, &mongoError{codeBadValue, "documents must be objects"}
+		}
+		if _, ok := lookup(doc, "_id"); !ok {
+			doc = append(bson.D{{Key: "_id", Value: primitive.NewObjectID()}}, doc...)
+		}
+		if err := c.checkUnique(ns, doc, -1); err != nil {
+			writeErrors = append(writeErrors, writeError(i, err))
+			if ordered {
+				break
+			}
+			continue
+		}
+		c.docs = append(c.docs, doc)
+		n++
+	}
+	return withWriteErrors(bson.D{{Key: "n", Value: n}}, writeErrors), nil
+}
+
+func (s *MongoServer) update(ns string, cmd bson.D) (bson.D, error) {
+	c := s.collection(ns)
+	updates, _ := lookupArray(cmd, "updates")
+	ordered := boolValue(cmd, "ordered", true)
+
+	n, modified := int32(0), int32(0)
+	var upserted, writeErrors bson.A
+	for i, v := range updates {
+		u, _ := v.(bson.D)
+		filter, update := docValue(u, "q"), docValue(u, "u")
+		multi := boolValue(u, "multi", false)
+
+		matched, err := c.match(filter, multi)
+		if err != nil {
+			return nil, err
+		}
+		if len(matched) == 0 && boolValue(u, "upsert", false) {
+			doc, err := c.upsert(ns, filter, update)
+			if err != nil {
+				writeErrors = append(writeErrors, writeError(i, err))
+				if ordered {
+					break
+				}
+				continue
+			}
+			id, _ := lookup(doc, "_id")
+			upserted = append(upserted, bson.D{{Key: "index", Value: int32(i)}, {Key: "_id", Value: id}})
+			n++
+			continue
+		}
+
+		for _, idx := range matched {
+			doc, err := applyUpdate(c.docs[idx], update, false)
+			if err == nil {
+				err = c.checkUnique(ns, doc, idx)
+			}
+			if err != nil {
+				writeErrors = append(writeErrors, writeError(i, err))
+				break
+			}
+			n++
+			if !equalDocs(doc, c.docs[idx]) {
+				c.docs[idx] = doc
+				modified++
+			}
+		}
+		if len(writeErrors) > 0 && ordered {
+			break
+		}
+	}
+
+	reply := bson.D{{Key: "n", Value: n}, {Key: "nModified", Value: modified}}
+	if len(upserted) > 0 {
+		reply = append(reply, bson.E{Key: "upserted", Value: upserted})
+	}
+	return withWriteErrors(reply, writeErrors), nil
+}
+
+func (s *MongoServer) delete(ns string, cmd bson.D) (bson.D, error) {
+	c := s.collection(ns)
+	deletes, _ := lookupArray(cmd, "deletes")
+
+	n := int32(0)
+	for _, v := range deletes {
+		d, _ := v.(bson.D)
+		matched, err := c.match(docValue(d, "q"), intValue(d, "limit") != 1)
+		if err != nil {
+			return nil, err
+		}
+		for i := len(matched) - 1; i >= 0; i-- {
+			idx := matched[i]
+			c.docs = append(c.docs[:idx], c.docs[idx+1:]...)
+			n++
+		}
+	}
+	return bson.D{{Key: "n", Value: n}}, nil
+}
+
+func (s *MongoServer) findAndModify(ns string, cmd bson.D) (bson.D, error) {
+	c := s.collection(ns)
+	filter, update := docValue(cmd, "query"), docValue(cmd, "update")
+	returnNew := boolValue(cmd, "new", false)
+
+	matched, err := c.match(filter, true)
+	if err != nil {
+		return nil, err
+	}
+	if order := docValue(cmd, "sort"); len(order) > 0 {
+		sort.SliceStable(matched, func(i, j int) bool {
+			return less(c.docs[matched[i]], c.docs[matched[j]], order)
+		})
+	}
+
+	if len(matched) == 0 {
+		if !boolValue(cmd, "upsert", false) || boolValue(cmd, "remove", false) {
+			return bson.D{
+				{Key: "lastErrorObject", Value: bson.D{{Key: "n", Value: int32(0)}, {Key: "updatedExisting", Value: false}}},
+				{Key: "value", Value: nil},
+			}, nil
+		}
+		doc, err := c.upsert(ns, filter, update)
+		if err != nil {
+			return nil, err
+		}
+		id, _ := lookup(doc, "_id")
+		var value interface{}
+		if returnNew {
+			value = doc
+		}
+		return bson.D{
+			{Key: "lastErrorObject", Value: bson.D{{Key: "n", Value: int32(1)}, {Key: "updatedExisting", Value: false}, {Key: "upserted", Value: id}}},
+			{Key: "value", Value: value},
+		}, nil
+	}
+
+	idx := matched[0]
+	old := c.docs[idx]
+	if boolValue(cmd, "remove", false) {
+		c.docs = append(c.docs[:idx], c.docs[idx+1:]...)
+		return bson.D{
+			{Key: "lastErrorObject", Value: bson.D{{Key: "n", Value: int32(1)}}},
+			{Key: "value", Value: old},
+		}, nil
+	}
+	doc, err := applyUpdate(old, update, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkUnique(ns, doc, idx); err != nil {
+		return nil, err
+	}
+	c.docs[idx] = doc
+	value := old
+	if returnNew {
+		value = doc
+	}
+	return bson.D{
+		{Key: "lastErrorObject", Value: bson.D{{Key: "n", Value: int32(1)}, {Key: "updatedExisting", Value: true}}},
+		{Key: "value", Value: value},
+	}, nil
+}
+
+func (s *MongoServer) createIndexes(ns string, cmd bson.D) (bson.D, error) {
+	c := s.collection(ns)
+	before := c.indexes
+	indexes, _ := lookupArray(cmd, "indexes")
+	for _, v := range indexes {
+		spec, _ := v.(bson.D)
+		name, _ := lookupString(spec, "name")
+		if _, ok := c.unique[name]; ok {
+			continue
+		}
+		c.indexes++
+		if !boolValue(spec, "unique", false) {
+			continue
+		}
+		var fields []string
+		for _, k := range docValue(spec, "key") {
+			fields = append(fields, k.Key)
+		}
+		c.unique[name] = fields
+		seen := make(map[string]bool, len(c.docs))
+		for _, doc := range c.docs {
+			key := indexKey(doc, fields)
+			if seen[key] {
+				delete(c.unique, name)
+				return nil, duplicateKey(ns, name, key)
+			}
+			seen[key] = true
+		}
+	}
+	return bson.D{
+		{Key: "createdCollectionAutomatically", Value: false},
+		{Key: "numIndexesBefore", Value: int32(before)},
+		{Key: "numIndexesAfter", Value: int32(c.indexes)},
+	}, nil
+}
+
+// match returns the positions of the documents matching filter, only the
+// first one unless multi.
+func (c *mongoCollection) match(filter bson.D, multi bool) ([]int, error) {
+	var matched []int
+	for i, doc := range c.docs {
+		ok, err := matches(doc, filter)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matched = append(matched, i)
+			if !multi {
+				break
+			}
+		}
+	}
+	return matched, nil
+}
+
+// upsert inserts the document made of the equality conditions of filter,
+// updated with update.
+func (c *mongoCollection) upsert(ns string, filter, update bson.D) (bson.D, error) {
+	var base bson.D
+	for _, e := range filter {
+		if strings.HasPrefix(e.Key, "$") {
+			continue
+		}
+		if ops, ok := e.Value.(bson.D); ok && isOperators(ops) {
+			if v, ok := lookup(ops, "$eq"); ok {
+				base = setPath(base, e.Key, v)
+			}
+			continue
+		}
+		base = setPath(base, e.Key, e.Value)
+	}
+	doc, err := applyUpdate(base, update, true)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := lookup(doc, "_id"); !ok {
+		doc = append(bson.D{{Key: "_id", Value: primitive.NewObjectID()}}, doc...)
+	}
+	if err := c.checkUnique(ns, doc, -1); err != nil {
+		return nil, err
+	}
+	c.docs = append(c.docs, doc)
+	return doc, nil
+}
+
+// checkUnique fails if doc would break a unique index of the collection,
+// ignoring the document at position self.
+func (c *mongoCollection) checkUnique(ns string, doc bson.D, self int) error {
+	id, _ := lookup(doc, "_id")
+	for i, other := range c.docs {
+		if i == self {
+			continue
+		}
+		if otherID, _ := lookup(other, "_id"); equals(otherID, id) {
+			return duplicateKey(ns, "_id_", indexKey(doc, []string{"_id"}))
+		}
+	}
+	for name, fields := range c.unique {
+		key := indexKey(doc, fields)
+		for i, other := range c.docs {
+			if i != self && indexKey(other, fields) == key {
+				return duplicateKey(ns, name, key)
+			}
+		}
+	}
+	return nil
+}
+
+func duplicateKey(ns, index, key string) error {
+	return &mongoError{codeDuplicateKey, fmt.Sprintf("E11000 duplicate key error collection: %s index: %s dup key: %s", ns, index, key)}
+}
+
+func writeError(index int, err error) bson.D {
+	code := int32(codeBadValue)
+	if merr, ok := err.(*mongoError); ok {
+		code = merr.code
+	}
+	return bson.D{{Key: "index", Value: int32(index)}, {Key: "code", Value: code}, {Key: "errmsg", Value: err.Error()}}
+}
+
+func withWriteErrors(reply bson.D, writeErrors bson.A) bson.D {
+	if len(writeErrors) > 0 {
+		reply = append(reply, bson.E{Key: "writeErrors", Value: writeErrors})
+	}
+	return reply
+}
+
+// indexKey returns the values of fields in doc as a string, equal for two
+// documents colliding on a unique index over fields.
+func indexKey(doc bson.D, fields []string) string {
+	var key bson.D
+	for _, f := range fields {
+		v, _ := lookup(doc, f)
+		if n, ok := toFloat(v); ok {
+			v = n
+		}
+		key = append(key, bson.E{Key: f, Value: v})
+	}
+	raw, _ := bson.MarshalExtJSON(key, false, false)
+	return string(raw)
+}
+
+// matches reports whether doc matches filter.
+func matches(doc bson.D, filter bson.D) (bool, error) {
+	for _, e := range filter {
+		switch e.Key {
+		case "$and", "$or", "$nor":
+			clauses, ok := e.Value.(bson.A)
+			if !ok || len(clauses) == 0 {
+				return false, &mongoError{codeBadValue, e.Key + " must be a nonempty array"}
+			}
+			matched := false
+			for _, clause := range clauses {
+				f, _ := clause.(bson.D)
+				ok, err := matches(doc, f)
+				if err != nil {
+					return false, err
+				}
+				if e.Key == "$and" && !ok {
+					return false, nil
+				}
+				matched = matched || ok
+			}
+			if (e.Key == "$or" && !matched) || (e.Key == "$nor" && matched) {
+				return false, nil
+			}
+			continue
+		}
+		if strings.HasPrefix(e.Key, "$") {
+			return false, &mongoError{codeBadValue, "unknown top level operator: " + e.Key}
+		}
+
+		v, found := lookup(doc, e.Key)
+		ok, err := matchesCondition(v, found, e.Value)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchesCondition(v interface{}, found bool, cond interface{}) (bool, error) {
+	ops, ok := cond.(bson.D)
+	if !ok || !isOperators(ops) {
+		return equals(v, cond), nil
+	}
+	for _, op := range ops {
+		var ok bool
+		switch op.Key {
+		case "$eq":
+			ok = equals(v, op.Value)
+		case "$ne":
+			ok = !equals(v, op.Value)
+		case "$gt", "$gte", "$lt", "$lte":
+			ok = found && sameClass(v, op.Value) && compareOp(op.Key, compare(v, op.Value))
+		case "$in", "$nin":
+			vals, isArray := op.Value.(bson.A)
+			if !isArray {
+				return false, &mongoError{codeBadValue, op.Key + " needs an array"}
+			}
+			for _, val := range vals {
+				if equals(v, val) {
+					ok = true
+					break
+				}
+			}
+			if op.Key == "$nin" {
+				ok = !ok
+			}
+		case "$exists":
+			ok = found == truthy(op.Value)
+		default:
+			return false, &mongoError{codeBadValue, "unknown operator: " + op.Key}
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func compareOp(op string, c int) bool {
+	switch op {
+	case "$gt":
+		return c > 0
+	case "$gte":
+		return c >= 0
+	case "$lt":
+		return c < 0
+	}
+	return c <= 0
+}
+
+// isOperators reports whether d is a document of query operators rather
+// than a value.
+func isOperators(d bson.D) bool {
+	return len(d) > 0 && strings.HasPrefix(d[0].Key, "$")
+}
+
+// equals reports whether a field of value v matches val. An array matches
+// the values it contains too, and a missing field matches null.
+func equals(v, val interface{}) bool {
+	if arr, ok := v.(bson.A); ok {
+		for _, elem := range arr {
+			if equals(elem, val) {
+				return true
+			}
+		}
+	}
+	return sameClass(v, val) && compare(v, val) == 0
+}
+
+// sameClass reports whether a and b are of types compared by value.
+func sameClass(a, b interface{}) bool {
+	return typeClass(a) == typeClass(b)
+}
+
+func typeClass(v interface{}) int {
+	switch v.(type) {
+	case nil, primitive.Null:
+		return 0
+	case int32, int64, float64, int:
+		return 1
+	case string:
+		return 2
+	case bson.D:
+		return 3
+	case bson.A:
+		return 4
+	case primitive.ObjectID:
+		return 5
+	case bool:
+		return 6
+	case primitive.DateTime:
+		return 7
+	}
+	return 8
+}
+
+// compare orders values of the same type class.
+func compare(a, b interface{}) int {
+	switch x := a.(type) {
+	case string:
+		y, _ := b.(string)
+		return strings.Compare(x, y)
+	case bool:
+		y, _ := b.(bool)
+		switch {
+		case x == y:
+			return 0
+		case !x:
+			return -1
+		}
+		return 1
+	case primitive.DateTime:
+		y, _ := b.(primitive.DateTime)
+		return compareFloats(float64(x), float64(y))
+	case primitive.ObjectID:
+		y, _ := b.(primitive.ObjectID)
+		return bytes.Compare(x[:], y[:])
+	}
+	if x, ok := toFloat(a); ok {
+		y, _ := toFloat(b)
+		return compareFloats(x, y)
+	}
+	if typeClass(a) == 0 && typeClass(b) == 0 {
+		return 0
+	}
+	ra, _ := bson.MarshalExtJSON(bson.D{{Key: "v", Value: a}}, true, false)
+	rb, _ := bson.MarshalExtJSON(bson.D{{Key: "v", Value: b}}, true, false)
+	return bytes.Compare(ra, rb)
+}
+
+func compareFloats(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func truthy(v interface{}) bool {
+	if b, ok := v.(bool); ok {
+		return b
+	}
+	n, ok := toFloat(v)
+	return !ok || n != 0
+}
+
+// less orders documents by the fields of order, 1 for ascending and -1 for
+// descending.
+func less(a, b bson.D, order bson.D) bool {
+	for _, o := range order {
+		va, _ := lookup(a, o.Key)
+		vb, _ := lookup(b, o.Key)
+		c := compareFloats(float64(typeClass(va)), float64(typeClass(vb)))
+		if c == 0 {
+			c = compare(va, vb)
+		}
+		if dir, _ := toFloat(o.Value); dir < 0 {
+			c = -c
+		}
+		if c != 0 {
+			return c < 0
+		}
+	}
+	return false
+}
+
+// applyUpdate returns a copy of doc with update applied, either update
+// operators or a replacement document. $setOnInsert only applies on insert.
+func applyUpdate(doc bson.D, update bson.D, insert bool) (bson.D, error) {
+	if !isOperators(update) {
+		replaced := make(bson.D, 0, len(update)+1)
+		if id, ok := lookup(doc, "_id"); ok {
+			replaced = append(replaced, bson.E{Key: "_id", Value: id})
+		}
+		for _, e := range update {
+			if e.Key != "_id" || len(replaced) == 0 {
+				replaced = append(replaced, e)
+			}
+		}
+		return replaced, nil
+	}
+
+	doc = copyDoc(doc)
+	for _, op := range update {
+		fields, ok := op.Value.(bson.D)
+		if !ok {
+			return nil, &mongoError{codeBadValue, op.Key + " needs a document"}
+		}
+		for _, f := range fields {
+			switch op.Key {
+			case "$set":
+				doc = setPath(doc, f.Key, f.Value)
+			case "$setOnInsert":
+				if insert {
+					doc = setPath(doc, f.Key, f.Value)
+				}
+			case "$unset":
+				doc = unsetPath(doc, f.Key)
+			case "$inc":
+				old, found := lookup(doc, f.Key)
+				if !found {
+					old = int32(0)
+				}
+				sum, err := add(old, f.Value)
+				if err != nil {
+					return nil, err
+				}
+				doc = setPath(doc, f.Key, sum)
+			default:
+				return nil, &mongoError{codeBadValue, "unknown update operator: " + op.Key}
+			}
+		}
+	}
+	return doc, nil
+}
+
+// add sums two numbers, keeping the narrowest type holding both.
+func add(a, b interface{}) (interface{}, error) {
+	switch x := a.(type) {
+	case int32:
+		switch y := b.(type) {
+		case int32:
+			return x + y, nil
+		case int64:
+			return int64(x) + y, nil
+		}
+	case int64:
+		switch y := b.(type) {
+		case int32:
+			return x + int64(y), nil
+		case int64:
+			return x + y, nil
+		}
+	}
+	x, ok := toFloat(a)
+	y, ok2 := toFloat(b)
+	if !ok || !ok2 {
+		return nil, &mongoError{codeBadValue, "cannot $inc a non-numeric value"}
+	}
+	return x + y, nil
+}
+
+// lookup returns the value at the dotted path in doc.
+func lookup(doc bson.D, path string) (interface{}, bool) {
+	key, rest, nested := strings.Cut(path, ".")
+	for _, e := range doc {
+		if e.Key != key {
+			continue
+		}
+		if !nested {
+			return e.Value, true
+		}
+		sub, ok := e.Value.(bson.D)
+		if !ok {
+			return nil, false
+		}
+		return lookup(sub, rest)
+	}
+	return nil, false
+}
+
+// setPath returns doc with the dotted path set to v.
+func setPath(doc bson.D, path string, v interface{}) bson.D {
+	key, rest, nested := strings.Cut(path, ".")
+	for i, e := range doc {
+		if e.Key != key {
+			continue
+		}
+		if nested {
+			sub, _ := e.Value.(bson.D)
+			v = setPath(sub, rest, v)
+		}
+		doc[i].Value = v
+		return doc
+	}
+	if nested {
+		v = setPath(nil, rest, v)
+	}
+	return append(doc, bson.E{Key: key, Value: v})
+}
+
+// unsetPath returns doc without the dotted path.
+func unsetPath(doc bson.D, path string) bson.D {
+	key, rest, nested := strings.Cut(path, ".")
+	for i, e := range doc {
+		if e.Key != key {
+			continue
+		}
+		if !nested {
+			return append(doc[:i:i], doc[i+1:]...)
+		}
+		if sub, ok := e.Value.(bson.D); ok {
+			doc[i].Value = unsetPath(sub, rest)
+		}
+		return doc
+	}
+	return doc
+}
+
+// copyDoc deep copies the nested documents of doc, so that updating the
+// copy leaves doc untouched.
+func copyDoc(doc bson.D) bson.D {
+	dup := make(bson.D, len(doc))
+	for i, e := range doc {
+		if sub, ok := e.Value.(bson.D); ok {
+			e.Value = copyDoc(sub)
+		}
+		dup[i] = e
+	}
+	return dup
+}
+
+func equalDocs(a, b bson.D) bool {
+	ra, _ := bson.Marshal(a)
+	rb, _ := bson.Marshal(b)
+	return bytes.Equal(ra, rb)
+}
+
+func docValue(d bson.D, key string) bson.D {
+	v, _ := lookup(d, key)
+	doc, _ := v.(bson.D)
+	return doc
+}
+
+func lookupArray(d bson.D, key string) (bson.A, bool) {
+	v, _ := lookup(d, key)
+	a, ok := v.(bson.A)
+	return a, ok
+}
+
+func lookupString(d bson.D, key string) (string, bool) {
+	v, _ := lookup(d, key)
+	str, ok := v.(string)
+	return str, ok
+}
+
+func intValue(d bson.D, key string) int {
+	v, _ := lookup(d, key)
+	n, _ := toFloat(v)
+	return int(n)
+}
+
+func boolValue(d bson.D, key string, def bool) bool {
+	v, ok := lookup(d, key)
+	if !ok {
+		return def
+	}
+	return truthy(v)
+}
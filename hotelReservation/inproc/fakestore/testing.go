@@ -0,0 +1,46 @@
+package fakestore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Mongo starts a MongoDB stand-in for the duration of t, returning a client
+// of it.
+func Mongo(t testing.TB) *mongo.Client {
+	t.Helper()
+	srv, err := NewMongoServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start mongo: %v", err)
+	}
+	t.Cleanup(srv.Close)
+
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://"+srv.Addr()))
+	if err != nil {
+		t.Fatalf("failed to connect to mongo: %v", err)
+	}
+	t.Cleanup(func() { client.Disconnect(context.Background()) })
+	if err := client.Ping(context.Background(), nil); err != nil {
+		t.Fatalf("failed to ping mongo: %v", err)
+	}
+	return client
+}
+
+// Memcached starts a memcached stand-in for the duration of t, returning a
+// client of it.
+func Memcached(t testing.TB) *memcache.Client {
+	t.Helper()
+	srv, err := NewMemcServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start memcached: %v", err)
+	}
+	t.Cleanup(srv.Close)
+
+	client := memcache.New(srv.Addr())
+	t.Cleanup(func() { client.Close() })
+	return client
+}
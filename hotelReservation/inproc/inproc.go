@@ -0,0 +1,409 @@
+// Package inproc runs every hotelReservation service in one process, for
+// tests and local experiments without containers.
+//
+// The services are the networked ones, run with the same handler code: they
+// listen on loopback ports, register in the in-process registry (see
+// registry.InProcAddr) and find one another through the consul:// resolver
+// as usual. MongoDB and memcached are replaced by the in-memory stand-ins
+// of the fakestore package, seeded with a dataset of the datagen
+// package.
+package inproc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/datagen"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/inproc/fakestore"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/registry"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/attractions"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/frontend"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/geo"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/profile"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/rate"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/recommendation"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/reservation"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/review"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/search"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/user"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/warmup"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// startTimeout bounds how long Start waits for the services to be up.
+const startTimeout = 30 * time.Second
+
+// backends are the services the frontend calls, by registered name.
+var backends = []string{
+	"srv-search", "srv-geo", "srv-profile", "srv-rate", "srv-recommendation",
+	"srv-reservation", "srv-review", "srv-attractions", "srv-user",
+}
+
+// Options configures a Cluster.
+type Options struct {
+	// Hotels is the number of hotels generated, 80 if zero.
+	Hotels int
+	// Seed is the random seed of the generated hotels.
+	Seed int64
+	// FrontendPort is the port of the HTTP frontend, a free one if zero.
+	FrontendPort int
+	// Tracer traces the services, no-op if nil.
+	Tracer opentracing.Tracer
+}
+
+// Cluster is a set of services running in the process.
+type Cluster struct {
+	// Frontend is the host:port of the HTTP frontend.
+	Frontend string
+	// Hotels are the hotels the services were seeded with.
+	Hotels []datagen.Hotel
+
+	mongo       *fakestore.MongoServer
+	mongoClient *mongo.Client
+	// memcs and memcClients are the caches of the services using one, one
+	// each since their keys collide
+	memcs       []*fakestore.MemcServer
+	memcClients []*memcache.Client
+	shutdowns   []func()
+	errs        chan error
+	done        chan struct{}
+	closeOnce   sync.Once
+}
+
+// server is what the services have in common.
+type server interface {
+	Run() error
+	Shutdown()
+}
+
+// Start seeds in-memory stores and starts all the services, returning once
+// the frontend is serving and every backend is registered.
+func Start(opts Options) (*Cluster, error) {
+	if opts.Hotels == 0 {
+		opts.Hotels = 80
+	}
+	if opts.Tracer == nil {
+		opts.Tracer = opentracing.NoopTracer{}
+	}
+	opts.Tracer = tracing.TenantTracer(opts.Tracer)
+	// the services span their memcached and MongoDB calls with the global
+	// tracer, which their commands set
+	opentracing.SetGlobalTracer(opts.Tracer)
+
+	c := &Cluster{
+		Hotels: datagen.Generate(opts.Seed, opts.Hotels),
+		errs:   make(chan error, len(backends)+1),
+		done:   make(chan struct{}),
+	}
+	started := false
+	defer func() {
+		if !started {
+			c.Close()
+		}
+	}()
+
+	var err error
+	if c.mongo, err = fakestore.NewMongoServer("127.0.0.1:0"); err != nil {
+		return nil, fmt.Errorf("failed to start mongo: %v", err)
+	}
+	if c.mongoClient, err = tune.NewMongoClient("mongodb://" + c.mongo.Addr()); err != nil {
+		return nil, err
+	}
+	var memc [4]*memcache.Client
+	for i := range memc {
+		if memc[i], err = c.newMemcClient(); err != nil {
+			return nil, fmt.Errorf("failed to start memcached: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), startTimeout)
+	defer cancel()
+	shards := tune.GetReservationShards()
+	if err := seed(ctx, c.mongoClient, c.Hotels, shards); err != nil {
+		return nil, err
+	}
+
+	reg, err := registry.NewClient(registry.InProcAddr)
+	if err != nil {
+		return nil, err
+	}
+	ports, err := freePorts(len(backends))
+	if err != nil {
+		return nil, err
+	}
+
+	timeouts := tune.GetDependencyTimeouts()
+
+	// backends first, so that the frontend finds them
+	servers := []server{
+		&search.Server{
+			Tracer:            opts.Tracer,
+			Port:              ports[0],
+			IpAddr:            "127.0.0.1",
+			ConsulAddr:        registry.InProcAddr,
+			Registry:          reg,
+			BudgetSlice:       float64(tune.GetBudgetWarnPercent()) / 100,
+			Timeouts:          timeouts,
+			CompressThreshold: tune.GetCompressThreshold(),
+			CacheEntries:      tune.GetSearchCacheEntries(),
+			CacheTTL:          time.Duration(tune.GetSearchCacheTTL()) * time.Millisecond,
+			CachePrecision:    tune.GetSearchCachePrecision(),
+			MissingData:       tune.GetSearchMissingData(),
+		},
+		&geo.Server{
+			Tracer:         opts.Tracer,
+			Port:           ports[1],
+			IpAddr:         "127.0.0.1",
+			Registry:       reg,
+			MongoClient:    c.mongoClient,
+			ReloadInterval: time.Duration(tune.GetGeoReloadInterval()) * time.Second,
+			AdminAllowlist: tune.GetAdminAllowlist(),
+		},
+		&profile.Server{
+			Tracer:         opts.Tracer,
+			Port:           ports[2],
+			IpAddr:         "127.0.0.1",
+			Registry:       reg,
+			MongoClient:    c.mongoClient,
+			MemcClient:     memc[0],
+			MemcTTL:        int32(tune.GetMemCTTL()),
+			NegativeTTL:    int32(tune.GetMemCNegativeTTL()),
+			MongoFanout:    tune.GetMongoFanout(),
+			BloomFPRate:    tune.GetBloomFPRate(),
+			BloomRefresh:   time.Duration(tune.GetBloomRefresh()) * time.Second,
+			Warmup:         warmup.Config{Mode: tune.GetWarmup(), Concurrency: tune.GetWarmupConcurrency()},
+			AdminAllowlist: tune.GetAdminAllowlist(),
+		},
+		&rate.Server{
+			Tracer:          opts.Tracer,
+			Port:            ports[3],
+			IpAddr:          "127.0.0.1",
+			Registry:        reg,
+			MongoClient:     c.mongoClient,
+			MemcClient:      memc[1],
+			DefaultRoomType: tune.GetDefaultRoomType(),
+			Warmup:          warmup.Config{Mode: tune.GetWarmup(), Concurrency: tune.GetWarmupConcurrency()},
+			MongoFanout:     tune.GetMongoFanout(),
+			AdminAllowlist:  tune.GetAdminAllowlist(),
+		},
+		&recommendation.Server{
+			Tracer:            opts.Tracer,
+			Port:              ports[4],
+			IpAddr:            "127.0.0.1",
+			ConsulAddr:        registry.InProcAddr,
+			Registry:          reg,
+			MongoClient:       c.mongoClient,
+			CompressThreshold: tune.GetCompressThreshold(),
+			MemoEntries:       tune.GetRecommendMemoEntries(),
+			MemoTTL:           time.Duration(tune.GetRecommendMemoTTL()) * time.Millisecond,
+			RatingRefresh:     time.Duration(tune.GetRatingRefresh()) * time.Second,
+		},
+		&reservation.Server{
+			Tracer:                 opts.Tracer,
+			Port:                   ports[5],
+			IpAddr:                 "127.0.0.1",
+			Registry:               reg,
+			MongoClient:            c.mongoClient,
+			Shards:                 reservation.ShardDatabases(c.mongoClient, shards),
+			MemcClient:             memc[2],
+			FreeCancellationWindow: time.Duration(tune.GetFreeCancellationHours()) * time.Hour,
+			DefaultRoomType:        tune.GetDefaultRoomType(),
+			IdempotencyWindow:      time.Duration(tune.GetIdempotencyHours()) * time.Hour,
+			AdminAllowlist:         tune.GetAdminAllowlist(),
+		},
+		&review.Server{
+			Tracer:      opts.Tracer,
+			Port:        ports[6],
+			IpAddr:      "127.0.0.1",
+			Registry:    reg,
+			MongoClient: c.mongoClient,
+			MemcClient:  memc[3],
+			RatingTTL:   int32(tune.GetRatingTTL()),
+		},
+		&attractions.Server{
+			Tracer:      opts.Tracer,
+			Port:        ports[7],
+			IpAddr:      "127.0.0.1",
+			Registry:    reg,
+			MongoClient: c.mongoClient,
+		},
+		&user.Server{
+			Tracer:            opts.Tracer,
+			Port:              ports[8],
+			IpAddr:            "127.0.0.1",
+			Registry:          reg,
+			MongoClient:       c.mongoClient,
+			BcryptCost:        tune.GetBcryptCost(),
+			MinPasswordLength: tune.GetMinPasswordLength(),
+		},
+	}
+	for _, srv := range servers {
+		c.run(srv)
+	}
+	if err := c.waitRegistered(ctx); err != nil {
+		return nil, err
+	}
+
+	port := opts.FrontendPort
+	if port == 0 {
+		free, err := freePorts(1)
+		if err != nil {
+			return nil, err
+		}
+		port = free[0]
+	}
+	c.run(&frontend.Server{
+		Tracer:            opts.Tracer,
+		Port:              port,
+		IpAddr:            "127.0.0.1",
+		ConsulAddr:        registry.InProcAddr,
+		Registry:          reg,
+		GrpcPoolSize:      tune.GetGrpcPoolSize(),
+		RequestBudget:     time.Duration(tune.GetRequestBudget()) * time.Millisecond,
+		BudgetSlice:       float64(tune.GetBudgetWarnPercent()) / 100,
+		TraceSampleTokens: tune.GetTraceSampleTokens(),
+		Timeouts:          timeouts,
+	})
+	c.Frontend = fmt.Sprintf("127.0.0.1:%d", port)
+	if err := c.waitServing(ctx, c.Frontend); err != nil {
+		return nil, err
+	}
+
+	log.Info().Msgf("In-process cluster of %d hotels serving on %s", len(c.Hotels), c.Frontend)
+	started = true
+	return c, nil
+}
+
+// Wait blocks until the cluster is closed, or until a service stops running
+// on its own, returning its error.
+func (c *Cluster) Wait() error {
+	select {
+	case err := <-c.errs:
+		return err
+	case <-c.done:
+		return nil
+	}
+}
+
+// Close shuts the services down, the frontend first, and stops the stores.
+func (c *Cluster) Close() {
+	c.closeOnce.Do(c.close)
+}
+
+func (c *Cluster) close() {
+	defer close(c.done)
+	for i := len(c.shutdowns) - 1; i >= 0; i-- {
+		c.shutdowns[i]()
+	}
+	if c.mongoClient != nil {
+		c.mongoClient.Disconnect(context.Background())
+	}
+	for _, client := range c.memcClients {
+		client.Close()
+	}
+	if c.mongo != nil {
+		c.mongo.Close()
+	}
+	for _, memc := range c.memcs {
+		memc.Close()
+	}
+}
+
+// newMemcClient starts a memcached stand-in and returns a client of it.
+func (c *Cluster) newMemcClient() (*memcache.Client, error) {
+	memc, err := fakestore.NewMemcServer("127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	c.memcs = append(c.memcs, memc)
+	client := tune.NewMemCClient(memc.Addr())
+	c.memcClients = append(c.memcClients, client)
+	return client, nil
+}
+
+// run runs srv in the background.
+func (c *Cluster) run(srv server) {
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		if err := srv.Run(); err != nil {
+			c.errs <- fmt.Errorf("%T: %v", srv, err)
+		}
+	}()
+	c.shutdowns = append(c.shutdowns, func() {
+		select {
+		case <-stopped:
+			// failed to run, there is nothing to shut down
+		default:
+			srv.Shutdown()
+			<-stopped
+		}
+	})
+}
+
+// waitRegistered waits for every backend to be in the in-process registry.
+func (c *Cluster) waitRegistered(ctx context.Context) error {
+	for _, name := range backends {
+		for {
+			entries, _, err := registry.InProc().Service(name, "", true, nil)
+			if err != nil {
+				return err
+			}
+			if len(entries) > 0 {
+				break
+			}
+			if err := c.pause(ctx); err != nil {
+				return fmt.Errorf("%s not registered: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// waitServing waits for addr to accept connections.
+func (c *Cluster) waitServing(ctx context.Context, addr string) error {
+	for {
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		if err := c.pause(ctx); err != nil {
+			return fmt.Errorf("frontend not serving on %s: %v", addr, err)
+		}
+	}
+}
+
+// pause waits a little, failing if ctx is done or a service stopped.
+func (c *Cluster) pause(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-c.errs:
+		return err
+	case <-time.After(20 * time.Millisecond):
+		return nil
+	}
+}
+
+// freePorts returns n ports free on the loopback interface.
+func freePorts(n int) ([]int, error) {
+	ports := make([]int, n)
+	for i := range ports {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, err
+		}
+		defer lis.Close()
+		ports[i] = lis.Addr().(*net.TCPAddr).Port
+	}
+	return ports, nil
+}
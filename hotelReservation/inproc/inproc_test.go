@@ -0,0 +1,217 @@
+package inproc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+// startCluster starts a cluster for the duration of t.
+func startCluster(t *testing.T) *Cluster {
+	t.Helper()
+	return startClusterWith(t, Options{})
+}
+
+// startClusterWith starts a cluster of opts for the duration of t.
+func startClusterWith(t *testing.T, opts Options) *Cluster {
+	t.Helper()
+	c, err := Start(opts)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(c.Close)
+	return c
+}
+
+// getJSON gets path from the frontend of c, decoding the JSON response into
+// v, and returns the response status.
+func getJSON(t *testing.T, c *Cluster, path string, query url.Values, v interface{}) int {
+	t.Helper()
+	resp, err := http.Get(fmt.Sprintf("http://%s%s?%s", c.Frontend, path, query.Encode()))
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK && v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("GET %s: decoding response: %v", path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestBookHotel(t *testing.T) {
+	c := startCluster(t)
+	hotel := c.Hotels[0]
+
+	var hotels struct {
+		Features []struct {
+			Id string `json:"id"`
+		} `json:"features"`
+	}
+	code := getJSON(t, c, "/hotels", url.Values{
+		"inDate":  {"2015-04-09"},
+		"outDate": {"2015-04-10"},
+		"lat":     {fmt.Sprint(hotel.Lat)},
+		"lon":     {fmt.Sprint(hotel.Lon)},
+	}, &hotels)
+	if code != http.StatusOK {
+		t.Fatalf("search: status %d", code)
+	}
+	found := false
+	for _, f := range hotels.Features {
+		found = found || f.Id == hotel.Id
+	}
+	if !found {
+		t.Fatalf("search around hotel %s: not in %+v", hotel.Id, hotels.Features)
+	}
+
+	// Cornell_<hex of "1"> has the password "1" repeated 10 times
+	var res struct {
+		Message string `json:"message"`
+	}
+	code = getJSON(t, c, "/reservation", url.Values{
+		"inDate":       {"2015-04-09"},
+		"outDate":      {"2015-04-10"},
+		"hotelId":      {hotel.Id},
+		"customerName": {"Cornell_31"},
+		"username":     {"Cornell_31"},
+		"password":     {"1111111111"},
+		"number":       {"1"},
+	}, &res)
+	if code != http.StatusOK {
+		t.Fatalf("reservation: status %d", code)
+	}
+	if res.Message != "Reserve successfully!" {
+		t.Errorf("reservation: got message %q, want %q", res.Message, "Reserve successfully!")
+	}
+}
+
+// reserveResult is the response to a /reserve, confirmed or not.
+type reserveResult struct {
+	ReservationId string  `json:"reservationId"`
+	TotalPrice    float64 `json:"totalPrice"`
+	Currency      string  `json:"currency"`
+	Code          string  `json:"code"`
+}
+
+// reserve books rooms of hotelId for the night of April 9 2015 through
+// /reserve, with requestID as X-Request-Id if not empty, and returns the
+// response status.
+func reserve(t *testing.T, c *Cluster, hotelId string, rooms int, requestID string, res *reserveResult) int {
+	t.Helper()
+	form := url.Values{
+		"inDate":       {"2015-04-09"},
+		"outDate":      {"2015-04-10"},
+		"hotelId":      {hotelId},
+		"customerName": {"Cornell_31"},
+		"username":     {"Cornell_31"},
+		"password":     {"1111111111"},
+		"number":       {strconv.Itoa(rooms)},
+	}
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("http://%s/reserve?%s", c.Frontend, form.Encode()), nil)
+	if err != nil {
+		t.Fatalf("POST /reserve: %v", err)
+	}
+	if requestID != "" {
+		req.Header.Set(tracing.RequestIDKey, requestID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Errorf("POST /reserve: %v", err)
+		return 0
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(res); err != nil {
+		t.Errorf("POST /reserve: decoding the response of status %d: %v", resp.StatusCode, err)
+	}
+	return resp.StatusCode
+}
+
+func TestReserve(t *testing.T) {
+	tracer := mocktracer.New()
+	c := startClusterWith(t, Options{Tracer: tracer})
+	hotel := c.Hotels[0]
+
+	var res reserveResult
+	if code := reserve(t, c, hotel.Id, 1, "reserve-1", &res); code != http.StatusOK {
+		t.Fatalf("reserve: status %d, %+v", code, res)
+	}
+	if res.ReservationId == "" || res.TotalPrice <= 0 || res.Currency == "" {
+		t.Errorf("got %+v, want the reservation ID and total price", res)
+	}
+
+	// the request ID reaches every service of the reservation
+	services := map[string]bool{}
+	for _, span := range tracer.FinishedSpans() {
+		if span.Tag("request.id") == "reserve-1" {
+			services[span.OperationName] = true
+		}
+	}
+	for _, method := range []string{"user.User/CheckUser", "rate.Rate/GetRates", "reservation.Reservation/MakeReservation"} {
+		if !services[method] {
+			t.Errorf("no span of %s tagged with the request ID, got %v", method, services)
+		}
+	}
+
+	var price reserveResult
+	if code := reserve(t, c, c.Hotels[1].Id, 2, "", &price); code != http.StatusOK {
+		t.Fatalf("reserving 2 rooms: status %d, %+v", code, price)
+	}
+	var single reserveResult
+	if code := reserve(t, c, c.Hotels[2].Id, 1, "", &single); code != http.StatusOK {
+		t.Fatalf("reserving 1 room: status %d, %+v", code, single)
+	}
+	if c.Hotels[1].Rate == c.Hotels[2].Rate && price.TotalPrice != 2*single.TotalPrice {
+		t.Errorf("2 rooms at %v, want twice the price of 1 at %v", price.TotalPrice, single.TotalPrice)
+	}
+}
+
+func TestReserveFilledUp(t *testing.T) {
+	c := startCluster(t)
+	hotel := c.Hotels[0]
+
+	// leave a single room
+	var res reserveResult
+	if code := reserve(t, c, hotel.Id, hotel.Rooms-1, "", &res); code != http.StatusOK {
+		t.Fatalf("reserving %d rooms: status %d, %+v", hotel.Rooms-1, code, res)
+	}
+
+	// clients that all saw it free race for the last room
+	const clients = 4
+	codes := make([]int, clients)
+	results := make([]reserveResult, clients)
+	var wg sync.WaitGroup
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes[i] = reserve(t, c, hotel.Id, 1, "", &results[i])
+		}(i)
+	}
+	wg.Wait()
+
+	reserved := 0
+	for i, code := range codes {
+		switch code {
+		case http.StatusOK:
+			reserved++
+		case http.StatusConflict:
+			if results[i].Code != "FailedPrecondition" || results[i].ReservationId != "" {
+				t.Errorf("got %+v for a client losing the race, want no longer available", results[i])
+			}
+		default:
+			t.Errorf("got status %d, %+v, want 409 for the clients losing the race", code, results[i])
+		}
+	}
+	if reserved != 1 {
+		t.Errorf("%d of %d clients reserved the last room, want 1", reserved, clients)
+	}
+}
@@ -0,0 +1,108 @@
+package inproc
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/registry"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+)
+
+// backendServices are the gRPC services of the backends, by registered name.
+var backendServices = map[string]string{
+	"srv-attractions":    "attractions.Attractions",
+	"srv-geo":            "geo.Geo",
+	"srv-profile":        "profile.Profile",
+	"srv-rate":           "rate.Rate",
+	"srv-recommendation": "recommendation.Recommendation",
+	"srv-reservation":    "reservation.Reservation",
+	"srv-review":         "review.Review",
+	"srv-search":         "search.Search",
+	"srv-user":           "user.User",
+}
+
+// dialBackend dials the instance of the backend registered as name.
+func dialBackend(t *testing.T, name string) *grpc.ClientConn {
+	t.Helper()
+	entries, _, err := registry.InProc().Service(name, "", true, nil)
+	if err != nil || len(entries) == 0 {
+		t.Fatalf("%s not registered: %v", name, err)
+	}
+	conn, err := grpc.Dial(fmt.Sprintf("%s:%d", entries[0].Service.Address, entries[0].Service.Port), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("dialing %s: %v", name, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// listServices lists the services conn serves through reflection, as
+// grpcurl list does.
+func listServices(ctx context.Context, conn *grpc.ClientConn) ([]string, error) {
+	stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.CloseSend()
+	if err := stream.Send(&rpb.ServerReflectionRequest{MessageRequest: &rpb.ServerReflectionRequest_ListServices{}}); err != nil {
+		return nil, err
+	}
+	res, err := stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	var services []string
+	for _, s := range res.GetListServicesResponse().GetService() {
+		services = append(services, s.Name)
+	}
+	sort.Strings(services)
+	return services, nil
+}
+
+func TestReflection(t *testing.T) {
+	t.Setenv("GRPC_REFLECTION", "true")
+	startCluster(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for name, service := range backendServices {
+		conn := dialBackend(t, name)
+		got, err := listServices(ctx, conn)
+		if err != nil {
+			t.Errorf("%s: listing services: %v", name, err)
+			continue
+		}
+		want := []string{service, "grpc.health.v1.Health", "grpc.reflection.v1alpha.ServerReflection"}
+		sort.Strings(want)
+		if strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("%s: got services %v, want %v", name, got, want)
+		}
+
+		// alongside the health service
+		res, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		if err != nil || res.Status != healthpb.HealthCheckResponse_SERVING {
+			t.Errorf("%s: health check got %v, %v, want SERVING", name, res, err)
+		}
+	}
+}
+
+func TestReflectionDisabled(t *testing.T) {
+	t.Setenv("GRPC_REFLECTION", "false")
+	startCluster(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for name := range backendServices {
+		if _, err := listServices(ctx, dialBackend(t, name)); status.Code(err) != codes.Unimplemented {
+			t.Errorf("%s: listing services got %v, want Unimplemented", name, err)
+		}
+	}
+}
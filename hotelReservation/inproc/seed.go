@@ -0,0 +1,119 @@
+package inproc
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strconv"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/datagen"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/reservation"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// seedUsers is the number of users seeded, as by the user service.
+const seedUsers = 500
+
+// seed fills the databases of the services with hotels, laid out as the
+// services seed them themselves with the reservations spread over shards,
+// and with the users Cornell_<i>.
+func seed(ctx context.Context, client *mongo.Client, hotels []datagen.Hotel, shards int) error {
+	var geo, profiles, rates, recommendations, numbers []interface{}
+	for _, h := range hotels {
+		geo = append(geo, bson.M{"hotelId": h.Id, "lat": h.Lat, "lon": h.Lon, "region": h.Region})
+		profiles = append(profiles, bson.M{
+			"id":          h.Id,
+			"name":        h.Name,
+			"phoneNumber": h.PhoneNumber,
+			"description": h.Description,
+			"address": bson.M{
+				"streetNumber": h.Number,
+				"streetName":   h.Street,
+				"city":         h.City,
+				"state":        h.State,
+				"country":      h.Country,
+				"postalCode":   h.PostalCode,
+				"lat":          float32(h.Lat),
+				"lon":          float32(h.Lon),
+			},
+			"stars": h.Stars,
+		})
+		rates = append(rates, bson.M{
+			"hotelId": h.Id,
+			"code":    "RACK",
+			"inDate":  h.InDate,
+			"outDate": h.OutDate,
+			"roomType": bson.M{
+				"bookableRate":       h.Rate,
+				"code":               h.RoomCode,
+				"roomDescription":    h.RoomDescription,
+				"totalRate":          h.Rate,
+				"totalRateInclusive": h.RateInclusive,
+			},
+		})
+		recommendations = append(recommendations, bson.M{
+			"hotelId": h.Id,
+			"lat":     h.Lat,
+			"lon":     h.Lon,
+			"rate":    h.Rate,
+			"price":   h.RateInclusive,
+		})
+		numbers = append(numbers, bson.M{"hotelId": h.Id, "numberOfRoom": h.Rooms})
+	}
+
+	var users []interface{}
+	for i := 0; i <= seedUsers; i++ {
+		suffix := strconv.Itoa(i)
+		password := ""
+		for j := 0; j < 10; j++ {
+			password += suffix
+		}
+		users = append(users, bson.M{
+			"username": fmt.Sprintf("Cornell_%x", suffix),
+			"password": fmt.Sprintf("%x", sha256.Sum256([]byte(password))),
+		})
+	}
+
+	unique := options.Index().SetUnique(true)
+	type index struct {
+		db, coll string
+		keys     bson.D
+	}
+	indexes := []index{
+		{"reservation-db", "idempotency", bson.D{{Key: "key", Value: 1}}},
+		{"user-db", "user", bson.D{{Key: "username", Value: 1}}},
+	}
+	for i := 0; i < shards; i++ {
+		indexes = append(indexes, index{reservation.ShardDatabaseName(i), "night", bson.D{{Key: "hotelId", Value: 1}, {Key: "roomType", Value: 1}, {Key: "date", Value: 1}}})
+	}
+	for _, idx := range indexes {
+		_, err := client.Database(idx.db).Collection(idx.coll).Indexes().CreateOne(ctx, mongo.IndexModel{Keys: idx.keys, Options: unique})
+		if err != nil {
+			return fmt.Errorf("failed to index %s.%s: %v", idx.db, idx.coll, err)
+		}
+	}
+
+	collections := []struct {
+		db, coll string
+		docs     []interface{}
+	}{
+		{"geo-db", "geo", geo},
+		{"attractions-db", "hotels", geo},
+		{"profile-db", "hotels", profiles},
+		{"rate-db", "inventory", rates},
+		{"recommendation-db", "recommendation", recommendations},
+		{"reservation-db", "number", numbers},
+		{"user-db", "user", users},
+	}
+	for _, c := range collections {
+		if len(c.docs) == 0 {
+			continue
+		}
+		if _, err := client.Database(c.db).Collection(c.coll).InsertMany(ctx, c.docs); err != nil {
+			return fmt.Errorf("failed to seed %s.%s: %v", c.db, c.coll, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package inproc
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/registry"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/frontend"
+	rate "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/rate/proto"
+	reservation "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/reservation/proto"
+	opentracing "github.com/opentracing/opentracing-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// selfTestFrontend returns a frontend with its clients dialing the backends
+// of the running cluster, as the -selftest flag of the frontend does.
+func selfTestFrontend(t *testing.T) *frontend.Server {
+	t.Helper()
+	reg, err := registry.NewClient(registry.InProcAddr)
+	if err != nil {
+		t.Fatalf("registry: %v", err)
+	}
+	srv := &frontend.Server{
+		Tracer:     opentracing.NoopTracer{},
+		ConsulAddr: registry.InProcAddr,
+		Registry:   reg,
+	}
+	if err := srv.InitClients(); err != nil {
+		t.Fatalf("InitClients: %v", err)
+	}
+	return srv
+}
+
+// runSelfTest runs the self-test of srv within a while.
+func runSelfTest(t *testing.T, srv *frontend.Server) frontend.SelfTestReport {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return srv.SelfTest(ctx)
+}
+
+// stepNames returns the names of the steps of report.
+func stepNames(report frontend.SelfTestReport) []string {
+	var names []string
+	for _, step := range report.Steps {
+		names = append(names, step.Name)
+	}
+	return names
+}
+
+var selfTestSteps = []string{"search", "profile", "rate", "reserve", "cancel"}
+
+func TestSelfTest(t *testing.T) {
+	startCluster(t)
+	srv := selfTestFrontend(t)
+
+	// twice, as operators may run it again against the same stack
+	for i := 0; i < 2; i++ {
+		report := runSelfTest(t, srv)
+		if !report.OK() {
+			t.Fatalf("self-test failed:\n%s", report)
+		}
+		if got := strings.Join(stepNames(report), " "); got != strings.Join(selfTestSteps, " ") {
+			t.Fatalf("got steps %s, want %v", got, selfTestSteps)
+		}
+		for _, step := range report.Steps {
+			if step.Code != codes.OK || step.Duration <= 0 {
+				t.Errorf("step %s: code %s in %v, want OK in some time", step.Name, step.Code, step.Duration)
+			}
+		}
+		if lines := strings.Count(report.String(), "PASS "); lines != len(selfTestSteps) {
+			t.Errorf("got report\n%s\nwant a PASS line per step", report)
+		}
+	}
+}
+
+// downRate is a rate client whose backend is unavailable.
+type downRate struct {
+	rate.RateClient
+}
+
+func (downRate) GetRates(ctx context.Context, req *rate.Request, opts ...grpc.CallOption) (*rate.Result, error) {
+	return nil, status.Error(codes.Unavailable, "connection refused")
+}
+
+func TestSelfTestFailingDependency(t *testing.T) {
+	startCluster(t)
+	srv := selfTestFrontend(t)
+	srv.RateClient = downRate{srv.RateClient}
+
+	report := runSelfTest(t, srv)
+	if report.OK() {
+		t.Fatalf("self-test passed with the rate service down:\n%s", report)
+	}
+	want := []struct {
+		outcome    string
+		dependency string
+	}{
+		{"PASS", "srv-search"},
+		{"PASS", "srv-profile"},
+		{"FAIL", "srv-rate"},
+		{"SKIP", "srv-reservation"},
+		{"SKIP", "srv-reservation"},
+	}
+	if len(report.Steps) != len(want) {
+		t.Fatalf("got steps %v, want %v", stepNames(report), selfTestSteps)
+	}
+	for i, step := range report.Steps {
+		outcome := "PASS"
+		switch {
+		case step.Skipped:
+			outcome = "SKIP"
+		case step.Err != nil:
+			outcome = "FAIL"
+		}
+		if outcome != want[i].outcome || step.Dependency != want[i].dependency {
+			t.Errorf("step %s: %s on %s, want %s on %s", step.Name, outcome, step.Dependency, want[i].outcome, want[i].dependency)
+		}
+	}
+	if rate := report.Steps[2]; rate.Code != codes.Unavailable {
+		t.Errorf("rate step failed with %s, want Unavailable", rate.Code)
+	}
+	if !strings.Contains(report.String(), "FAIL rate     srv-rate") || !strings.Contains(report.String(), "Unavailable: connection refused") {
+		t.Errorf("got report\n%s\nwant the failing dependency and status", report)
+	}
+}
+
+// lateReservation is a reservation client whose reservations are made as
+// the context of the caller runs out.
+type lateReservation struct {
+	reservation.ReservationClient
+	expire  context.CancelFunc
+	reserve *reservation.Result
+}
+
+func (r *lateReservation) MakeReservation(ctx context.Context, req *reservation.Request, opts ...grpc.CallOption) (*reservation.Result, error) {
+	res, err := r.ReservationClient.MakeReservation(ctx, req, opts...)
+	r.reserve = res
+	r.expire()
+	return res, err
+}
+
+func TestSelfTestCancelsAfterTimeout(t *testing.T) {
+	startCluster(t)
+	srv := selfTestFrontend(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	late := &lateReservation{ReservationClient: srv.ReservationClient, expire: cancel}
+	srv.ReservationClient = late
+
+	report := srv.SelfTest(ctx)
+	if late.reserve == nil || late.reserve.ReservationId == "" {
+		t.Fatalf("got no reservation, report:\n%s", report)
+	}
+	if step := report.Steps[len(report.Steps)-1]; step.Name != "cancel" || step.Err != nil || step.Skipped {
+		t.Fatalf("reservation not cancelled once the self-test ran out of time:\n%s", report)
+	}
+
+	// it was cancelled indeed
+	res, err := late.ReservationClient.CancelReservation(context.Background(), &reservation.CancelRequest{ReservationId: late.reserve.ReservationId})
+	if err != nil {
+		t.Fatalf("CancelReservation: %v", err)
+	}
+	if res.Status != reservation.CancelResult_ALREADY_CANCELLED {
+		t.Errorf("reservation %s %s, want it already cancelled by the self-test", late.reserve.ReservationId, res.Status)
+	}
+}
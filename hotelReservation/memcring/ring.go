@@ -0,0 +1,196 @@
+package memcring
+
+import (
+	"bufio"
+	"fmt"
+	"hash/crc32"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/rs/zerolog/log"
+)
+
+// pointsPerServer is the number of points each server has on the ring.
+// More points spread keys more evenly between servers.
+const pointsPerServer = 160
+
+// probeTimeout bounds each liveness probe of a server.
+const probeTimeout = 500 * time.Millisecond
+
+type point struct {
+	hash uint32
+	addr net.Addr
+}
+
+// Ring is a memcache.ServerSelector placing keys on a consistent hash ring.
+// Servers failing a liveness probe are taken off the ring, which only moves
+// the keys they held, and are put back once they answer again.
+type Ring struct {
+	addrs []net.Addr
+
+	mu     sync.RWMutex
+	live   map[string]bool
+	points []point
+
+	done chan struct{}
+	stop sync.Once
+}
+
+// New returns a ring over servers, given as host:port or as the path of a
+// unix socket, probing them every interval. All servers start on the ring.
+func New(servers []string, interval time.Duration) (*Ring, error) {
+	r := &Ring{
+		live: make(map[string]bool, len(servers)),
+		done: make(chan struct{}),
+	}
+	for _, server := range servers {
+		server = strings.TrimSpace(server)
+		if server == "" {
+			continue
+		}
+		var addr net.Addr
+		var err error
+		if strings.Contains(server, "/") {
+			addr, err = net.ResolveUnixAddr("unix", server)
+		} else {
+			addr, err = net.ResolveTCPAddr("tcp", server)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid memcached server %q: %v", server, err)
+		}
+		r.addrs = append(r.addrs, addr)
+		r.live[addr.String()] = true
+	}
+	if len(r.addrs) == 0 {
+		return nil, memcache.ErrNoServers
+	}
+	r.rebuild()
+
+	go r.probeLoop(interval)
+	return r, nil
+}
+
+// rebuild recomputes the ring from the live servers. Callers hold mu, or
+// own r exclusively.
+func (r *Ring) rebuild() {
+	points := make([]point, 0, len(r.addrs)*pointsPerServer)
+	for _, addr := range r.addrs {
+		if !r.live[addr.String()] {
+			continue
+		}
+		for i := 0; i < pointsPerServer; i++ {
+			h := crc32.ChecksumIEEE([]byte(addr.String() + "-" + strconv.Itoa(i)))
+			points = append(points, point{hash: h, addr: addr})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].hash < points[j].hash })
+	r.points = points
+}
+
+// PickServer implements memcache.ServerSelector. A key goes to the first
+// point at or after its hash, wrapping around the ring.
+func (r *Ring) PickServer(key string) (net.Addr, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.points) == 0 {
+		return nil, memcache.ErrNoServers
+	}
+	h := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i].hash >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].addr, nil
+}
+
+// Each implements memcache.ServerSelector, calling f on every live server.
+func (r *Ring) Each(f func(net.Addr) error) error {
+	r.mu.RLock()
+	var live []net.Addr
+	for _, addr := range r.addrs {
+		if r.live[addr.String()] {
+			live = append(live, addr)
+		}
+	}
+	r.mu.RUnlock()
+
+	if len(live) == 0 {
+		return memcache.ErrNoServers
+	}
+	for _, addr := range live {
+		if err := f(addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetLive puts addr on the ring or takes it off, reporting whether that
+// changed anything.
+func (r *Ring) SetLive(addr net.Addr, live bool) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := addr.String()
+	if was, ok := r.live[key]; !ok || was == live {
+		return false
+	}
+	r.live[key] = live
+	r.rebuild()
+	return true
+}
+
+// Close stops probing the servers.
+func (r *Ring) Close() {
+	r.stop.Do(func() { close(r.done) })
+}
+
+func (r *Ring) probeLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+		}
+		for _, addr := range r.addrs {
+			err := probe(addr)
+			if r.SetLive(addr, err == nil) {
+				if err != nil {
+					log.Warn().Msgf("memcached %s is down, taking it off the ring: %v", addr, err)
+				} else {
+					log.Info().Msgf("memcached %s is back, putting it on the ring", addr)
+				}
+			}
+		}
+	}
+}
+
+// probe checks that addr answers the memcached version command.
+func probe(addr net.Addr) error {
+	conn, err := net.DialTimeout(addr.Network(), addr.String(), probeTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(probeTimeout))
+
+	if _, err := conn.Write([]byte("version\r\n")); err != nil {
+		return err
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "VERSION ") {
+		return fmt.Errorf("unexpected reply %q", strings.TrimSpace(line))
+	}
+	return nil
+}
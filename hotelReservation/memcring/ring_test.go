@@ -0,0 +1,211 @@
+package memcring
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/inproc/fakestore"
+)
+
+// startServers starts n memcached stand-ins, returning them and their
+// addresses.
+func startServers(t *testing.T, n int) ([]*fakestore.MemcServer, []string) {
+	t.Helper()
+	var servers []*fakestore.MemcServer
+	var addrs []string
+	for i := 0; i < n; i++ {
+		srv, err := fakestore.NewMemcServer("127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("starting memcached: %v", err)
+		}
+		t.Cleanup(srv.Close)
+		servers = append(servers, srv)
+		addrs = append(addrs, srv.Addr())
+	}
+	return servers, addrs
+}
+
+// placement returns the server of each of keys.
+func placement(t *testing.T, r *Ring, keys []string) map[string]string {
+	t.Helper()
+	placed := make(map[string]string, len(keys))
+	for _, key := range keys {
+		addr, err := r.PickServer(key)
+		if err != nil {
+			t.Fatalf("PickServer(%q): %v", key, err)
+		}
+		placed[key] = addr.String()
+	}
+	return placed
+}
+
+// waitOffRing waits for the probes to take addr off r.
+func waitOffRing(t *testing.T, r *Ring, addr string, keys []string) {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); ; {
+		off := true
+		for _, server := range placement(t, r, keys) {
+			if server == addr {
+				off = false
+				break
+			}
+		}
+		if off {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s still on the ring", addr)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRingSpreadsKeys(t *testing.T) {
+	_, addrs := startServers(t, 3)
+	r, err := New(addrs, time.Hour)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer r.Close()
+
+	perServer := make(map[string]int)
+	for i := 0; i < 3000; i++ {
+		addr, err := r.PickServer(fmt.Sprintf("%d", i))
+		if err != nil {
+			t.Fatalf("PickServer: %v", err)
+		}
+		perServer[addr.String()]++
+	}
+	for _, addr := range addrs {
+		if n := perServer[addr]; n < 500 {
+			t.Errorf("server %s got %d of 3000 keys, want about a third", addr, n)
+		}
+	}
+}
+
+func TestRingDropsDeadServer(t *testing.T) {
+	servers, addrs := startServers(t, 3)
+	r, err := New(addrs, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer r.Close()
+	client := memcache.NewFromSelector(r)
+
+	var keys []string
+	for i := 0; i < 300; i++ {
+		key := fmt.Sprintf("%d", i)
+		keys = append(keys, key)
+		if err := client.Set(&memcache.Item{Key: key, Value: []byte(key)}); err != nil {
+			t.Fatalf("Set(%q): %v", key, err)
+		}
+	}
+	before := placement(t, r, keys)
+
+	servers[1].Close()
+	waitOffRing(t, r, addrs[1], keys)
+	after := placement(t, r, keys)
+
+	moved := 0
+	for _, key := range keys {
+		if before[key] == addrs[1] {
+			moved++
+			continue
+		}
+		// only the keys of the dead server remap, so the others are still
+		// cached
+		if after[key] != before[key] {
+			t.Errorf("key %s moved from %s to %s", key, before[key], after[key])
+		}
+		if _, err := client.Get(key); err != nil {
+			t.Errorf("Get(%q) of a live server: %v", key, err)
+		}
+	}
+	if moved == 0 {
+		t.Fatalf("no key was on the dead server")
+	}
+}
+
+func TestRingPutsBackRevivedServer(t *testing.T) {
+	_, addrs := startServers(t, 2)
+	r, err := New(addrs, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer r.Close()
+
+	var keys []string
+	for i := 0; i < 100; i++ {
+		keys = append(keys, fmt.Sprintf("%d", i))
+	}
+	before := placement(t, r, keys)
+
+	addr, _ := net.ResolveTCPAddr("tcp", addrs[0])
+	if !r.SetLive(addr, false) {
+		t.Fatalf("SetLive(%s, false) changed nothing", addr)
+	}
+	if r.SetLive(addr, false) {
+		t.Errorf("SetLive of a server already off the ring changed something")
+	}
+	// the probes find it alive again
+	for deadline := time.Now().Add(2 * time.Second); ; {
+		after := placement(t, r, keys)
+		if fmt.Sprint(after) == fmt.Sprint(before) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s not put back on the ring", addr)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRingAllServersDown(t *testing.T) {
+	servers, addrs := startServers(t, 1)
+	r, err := New(addrs, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer r.Close()
+
+	servers[0].Close()
+	for deadline := time.Now().Add(2 * time.Second); ; {
+		if _, err := r.PickServer("1"); errors.Is(err, memcache.ErrNoServers) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("PickServer still picks a server with all of them down")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := r.Each(func(net.Addr) error { return nil }); !errors.Is(err, memcache.ErrNoServers) {
+		t.Errorf("Each with all servers down: got %v, want ErrNoServers", err)
+	}
+}
+
+func TestNewWithoutServers(t *testing.T) {
+	if _, err := New([]string{"", " "}, time.Hour); !errors.Is(err, memcache.ErrNoServers) {
+		t.Errorf("got %v, want ErrNoServers", err)
+	}
+	if _, err := New([]string{"127.0.0.1:-1"}, time.Hour); err == nil {
+		t.Errorf("invalid address accepted")
+	}
+}
+
+func TestStats(t *testing.T) {
+	s := new(Stats)
+	s.Record(nil)
+	s.Record(memcache.ErrCacheMiss)
+	s.Record(errors.New("connection refused"))
+	s.RecordMulti(5, 3, memcache.ErrCacheMiss)
+	s.RecordMulti(4, 0, errors.New("connection refused"))
+	s.RecordMulti(2, 2, nil)
+
+	if s.Hits() != 6 || s.Misses() != 3 || s.Errors() != 2 {
+		t.Errorf("got %d hits, %d misses and %d errors, want 6, 3 and 2", s.Hits(), s.Misses(), s.Errors())
+	}
+}
@@ -0,0 +1,52 @@
+package memcring
+
+import (
+	"errors"
+	"sync/atomic"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// DefaultStats counts the cache lookups of the service.
+var DefaultStats = new(Stats)
+
+// Stats counts cache hits, misses and failed lookups. It is safe for
+// concurrent use.
+type Stats struct {
+	hits   uint64
+	misses uint64
+	errors uint64
+}
+
+// Record counts the result of a Get.
+func (s *Stats) Record(err error) {
+	switch {
+	case err == nil:
+		atomic.AddUint64(&s.hits, 1)
+	case errors.Is(err, memcache.ErrCacheMiss):
+		atomic.AddUint64(&s.misses, 1)
+	default:
+		atomic.AddUint64(&s.errors, 1)
+	}
+}
+
+// RecordMulti counts the result of a GetMulti of requested keys, found of
+// which were returned. A failed GetMulti counts as one error, and the keys
+// it did not return are not counted as misses.
+func (s *Stats) RecordMulti(requested, found int, err error) {
+	atomic.AddUint64(&s.hits, uint64(found))
+	if err != nil && !errors.Is(err, memcache.ErrCacheMiss) {
+		atomic.AddUint64(&s.errors, 1)
+		return
+	}
+	atomic.AddUint64(&s.misses, uint64(requested-found))
+}
+
+// Hits returns the number of keys found in the cache.
+func (s *Stats) Hits() uint64 { return atomic.LoadUint64(&s.hits) }
+
+// Misses returns the number of keys not found in the cache.
+func (s *Stats) Misses() uint64 { return atomic.LoadUint64(&s.misses) }
+
+// Errors returns the number of failed lookups.
+func (s *Stats) Errors() uint64 { return atomic.LoadUint64(&s.errors) }
@@ -0,0 +1,117 @@
+package registry
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	consul "github.com/hashicorp/consul/api"
+)
+
+// InProcAddr is the registry address standing for the in-process registry.
+// Services registered with a Client of that address are only known within
+// the process, where the consul:// resolver of the dialer package finds them
+// as it would in Consul.
+const InProcAddr = "inproc"
+
+// maxInProcWait bounds blocking queries made without a wait time, as Consul
+// does.
+const maxInProcWait = 5 * time.Minute
+
+var inProc = &InProcRegistry{
+	changed:  make(chan struct{}),
+	services: make(map[string]*consul.AgentServiceRegistration),
+}
+
+// InProc returns the in-process registry.
+func InProc() *InProcRegistry {
+	return inProc
+}
+
+// InProcRegistry keeps the services registered in the process. Registered
+// instances are always considered healthy.
+type InProcRegistry struct {
+	mu    sync.Mutex
+	index uint64
+	// changed is closed, and replaced, whenever the registry changes.
+	changed  chan struct{}
+	services map[string]*consul.AgentServiceRegistration
+}
+
+func (r *InProcRegistry) register(reg *consul.AgentServiceRegistration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.services[reg.ID] = reg
+	r.bump()
+}
+
+func (r *InProcRegistry) deregister(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.services[id]; ok {
+		delete(r.services, id)
+		r.bump()
+	}
+}
+
+func (r *InProcRegistry) bump() {
+	r.index++
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// Service lists the instances of service like the Consul health endpoint.
+// A query with a WaitIndex blocks until the registry changes past it, or
+// until its WaitTime is over.
+func (r *InProcRegistry) Service(service, tag string, passingOnly bool, q *consul.QueryOptions) ([]*consul.ServiceEntry, *consul.QueryMeta, error) {
+	ctx := context.Background()
+	if q != nil {
+		ctx = q.Context()
+	}
+
+	r.mu.Lock()
+	if q != nil && q.WaitIndex > 0 && r.index <= q.WaitIndex {
+		wait := q.WaitTime
+		if wait <= 0 || wait > maxInProcWait {
+			wait = maxInProcWait
+		}
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+	wait:
+		for r.index <= q.WaitIndex {
+			changed := r.changed
+			r.mu.Unlock()
+			select {
+			case <-ctx.Done():
+				return nil, nil, ctx.Err()
+			case <-timer.C:
+				r.mu.Lock()
+				break wait
+			case <-changed:
+			}
+			r.mu.Lock()
+		}
+	}
+	defer r.mu.Unlock()
+
+	var entries []*consul.ServiceEntry
+	for _, reg := range r.services {
+		if reg.Name != service {
+			continue
+		}
+		entries = append(entries, &consul.ServiceEntry{
+			Node: &consul.Node{Node: InProcAddr, Address: reg.Address},
+			Service: &consul.AgentService{
+				ID:      reg.ID,
+				Service: reg.Name,
+				Address: reg.Address,
+				Port:    reg.Port,
+				Tags:    reg.Tags,
+				Meta:    reg.Meta,
+			},
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Service.ID < entries[j].Service.ID })
+	return entries, &consul.QueryMeta{LastIndex: r.index}, nil
+}
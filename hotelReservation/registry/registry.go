@@ -1,16 +1,42 @@
 package registry
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"os"
+	"strconv"
+	"time"
 
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
 	consul "github.com/hashicorp/consul/api"
 	"github.com/rs/zerolog/log"
 )
 
-// NewClient returns a new Client with connection to consul
+const (
+	// baseRetryBackoff is the delay before the second attempt at a call to
+	// consul, doubling for each following attempt up to maxRetryBackoff.
+	baseRetryBackoff = 200 * time.Millisecond
+	maxRetryBackoff  = 5 * time.Second
+
+	// Deregistering is retried briefly, so that shutdowns are not held up
+	// by an unreachable consul.
+	deregisterAttempts = 3
+	deregisterTimeout  = 5 * time.Second
+)
+
+// WeightMeta is the service meta key, or the tag prefix followed by "=",
+// under which instances publish their weight: how many RPCs clients send
+// them for every one sent to an instance of weight 1.
+const WeightMeta = "weight"
+
+// NewClient returns a new Client with connection to consul, or to the
+// in-process registry if addr is InProcAddr.
 func NewClient(addr string) (*Client, error) {
+	if addr == InProcAddr {
+		return &Client{local: inProc, Weight: tune.GetServiceWeight()}, nil
+	}
+
 	cfg := consul.DefaultConfig()
 	cfg.Address = addr
 
@@ -19,12 +45,29 @@ func NewClient(addr string) (*Client, error) {
 		return nil, err
 	}
 
-	return &Client{c}, nil
+	return &Client{
+		Client:      c,
+		MaxAttempts: tune.GetRegisterAttempts(),
+		Timeout:     time.Duration(tune.GetRegisterTimeout()) * time.Second,
+		Weight:      tune.GetServiceWeight(),
+	}, nil
 }
 
 // Client provides an interface for communicating with registry
 type Client struct {
 	*consul.Client
+	// local is the in-process registry, nil when registering in consul.
+	local *InProcRegistry
+
+	// MaxAttempts is the number of attempts Register makes at registering
+	// before failing, once if zero.
+	MaxAttempts int
+	// Timeout bounds the time Register spends on all its attempts, no bound
+	// if zero.
+	Timeout time.Duration
+	// Weight is the weight Register publishes for the instance, none if
+	// zero, which clients take as 1.
+	Weight int
 }
 
 // Look for the network device being dedicated for gRPC traffic.
@@ -75,7 +118,9 @@ func getLocalIP() (string, error) {
 
 // Register a service with registry
 func (c *Client) Register(name string, id string, ip string, port int) error {
-	if ip == "" {
+	if ip == "" && c.local != nil {
+		ip = "127.0.0.1"
+	} else if ip == "" {
 		var err error
 		ip, err = getLocalIP()
 		if err != nil {
@@ -88,11 +133,73 @@ func (c *Client) Register(name string, id string, ip string, port int) error {
 		Port:    port,
 		Address: ip,
 	}
+	if c.Weight > 0 {
+		reg.Meta = map[string]string{WeightMeta: strconv.Itoa(c.Weight)}
+	}
 	log.Info().Msgf("Trying to register service [ name: %s, id: %s, address: %s:%d ]", name, id, ip, port)
-	return c.Agent().ServiceRegister(reg)
+	if c.local != nil {
+		c.local.register(reg)
+		return nil
+	}
+	return retry("register "+id, c.MaxAttempts, c.Timeout, func(ctx context.Context) error {
+		return c.Agent().ServiceRegisterOpts(reg, consul.ServiceRegisterOpts{}.WithContext(ctx))
+	})
 }
 
-// Deregister removes the service address from registry
+// Deregister removes the service address from registry, retrying briefly
+// on failure.
 func (c *Client) Deregister(id string) error {
-	return c.Agent().ServiceDeregister(id)
+	if c.local != nil {
+		c.local.deregister(id)
+		return nil
+	}
+	timeout := deregisterTimeout
+	if c.Timeout > 0 && c.Timeout < timeout {
+		timeout = c.Timeout
+	}
+	err := retry("deregister "+id, deregisterAttempts, timeout, func(ctx context.Context) error {
+		return c.Agent().ServiceDeregisterOpts(id, (&consul.QueryOptions{}).WithContext(ctx))
+	})
+	if err != nil {
+		log.Error().Msgf("Failed to deregister from consul, the entry may linger: %v", err)
+	}
+	return err
+}
+
+// retry calls call until it succeeds, at most attempts times and within
+// timeout, with an exponential backoff between attempts. Each failed attempt
+// is logged.
+func retry(what string, attempts int, timeout time.Duration, call func(ctx context.Context) error) error {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	backoff := baseRetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := call(ctx)
+		if err == nil {
+			if attempt > 1 {
+				log.Info().Msgf("Consul %s succeeded at attempt %d", what, attempt)
+			}
+			return nil
+		}
+		if attempt >= attempts {
+			return fmt.Errorf("consul %s failed after %d attempts: %v", what, attempt, err)
+		}
+		log.Warn().Msgf("Consul %s attempt %d of %d failed, retrying in %v: %v", what, attempt, attempts, backoff, err)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("consul %s timed out after %d attempts in %v: %v", what, attempt, timeout, err)
+		case <-timer.C:
+		}
+		if backoff *= 2; backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
 }
@@ -0,0 +1,157 @@
+package registry
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	consul "github.com/hashicorp/consul/api"
+)
+
+// fakeConsul is a Consul agent rejecting the first registrations and
+// deregistrations it gets.
+type fakeConsul struct {
+	mu sync.Mutex
+	// rejects is how many more calls are rejected, all of them if negative.
+	rejects        int
+	registers      int
+	deregisters    int
+	registered     *consul.AgentServiceRegistration
+	deregisteredID string
+}
+
+func (f *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.URL.Path == "/v1/agent/service/register":
+		f.registers++
+	case strings.HasPrefix(r.URL.Path, "/v1/agent/service/deregister/"):
+		f.deregisters++
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if f.rejects != 0 {
+		f.rejects--
+		http.Error(w, "No cluster leader", http.StatusInternalServerError)
+		return
+	}
+
+	if r.URL.Path == "/v1/agent/service/register" {
+		f.registered = new(consul.AgentServiceRegistration)
+		json.NewDecoder(r.Body).Decode(f.registered)
+	} else {
+		f.deregisteredID = strings.TrimPrefix(r.URL.Path, "/v1/agent/service/deregister/")
+	}
+}
+
+// newFakeClient returns a client of a fake Consul rejecting rejects calls,
+// making up to attempts attempts.
+func newFakeClient(t *testing.T, rejects, attempts int) (*Client, *fakeConsul) {
+	t.Helper()
+	fake := &fakeConsul{rejects: rejects}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	c, err := NewClient(srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	c.MaxAttempts, c.Timeout, c.Weight = attempts, 10*time.Second, 0
+	return c, fake
+}
+
+func TestRegisterRetries(t *testing.T) {
+	c, fake := newFakeClient(t, 2, 5)
+	c.Weight = 3
+	if err := c.Register("srv-geo", "geo-1", "10.0.0.5", 8083); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if fake.registers != 3 {
+		t.Errorf("made %d attempts, want 3", fake.registers)
+	}
+	reg := fake.registered
+	if reg == nil || reg.ID != "geo-1" || reg.Name != "srv-geo" || reg.Address != "10.0.0.5" || reg.Port != 8083 || reg.Meta[WeightMeta] != "3" {
+		t.Errorf("registered %+v, want geo-1 of srv-geo at 10.0.0.5:8083 of weight 3", reg)
+	}
+}
+
+func TestRegisterGivesUp(t *testing.T) {
+	c, fake := newFakeClient(t, -1, 3)
+	err := c.Register("srv-geo", "geo-1", "10.0.0.5", 8083)
+	if err == nil || !strings.Contains(err.Error(), "after 3 attempts") {
+		t.Errorf("got %v, want a failure after 3 attempts", err)
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if fake.registers != 3 {
+		t.Errorf("made %d attempts, want 3", fake.registers)
+	}
+}
+
+func TestRegisterTimeout(t *testing.T) {
+	c, _ := newFakeClient(t, -1, 100)
+	c.Timeout = 300 * time.Millisecond
+
+	start := time.Now()
+	err := c.Register("srv-geo", "geo-1", "10.0.0.5", 8083)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("got %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("gave up after %v, want about the 300ms timeout", elapsed)
+	}
+}
+
+func TestDeregisterRetries(t *testing.T) {
+	c, fake := newFakeClient(t, 1, 1)
+	if err := c.Deregister("geo-1"); err != nil {
+		t.Fatalf("Deregister: %v", err)
+	}
+	fake.mu.Lock()
+	if fake.deregisters != 2 || fake.deregisteredID != "geo-1" {
+		t.Errorf("made %d attempts deregistering %q, want 2 for geo-1", fake.deregisters, fake.deregisteredID)
+	}
+	fake.mu.Unlock()
+
+	// deregistering gives up after a few attempts, whatever MaxAttempts
+	c, fake = newFakeClient(t, -1, 100)
+	if err := c.Deregister("geo-1"); err == nil {
+		t.Error("deregistered from a failing consul")
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if fake.deregisters != deregisterAttempts {
+		t.Errorf("made %d attempts, want %d", fake.deregisters, deregisterAttempts)
+	}
+}
+
+func TestInProcRegistry(t *testing.T) {
+	c, err := NewClient(InProcAddr)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if err := c.Register("srv-test-registry", "test-registry-1", "", 8083); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	entries, _, err := InProc().Service("srv-test-registry", "", true, nil)
+	if err != nil || len(entries) != 1 || entries[0].Service.Address != "127.0.0.1" || entries[0].Service.Port != 8083 {
+		t.Fatalf("got %v, %v, want the instance at 127.0.0.1:8083", entries, err)
+	}
+
+	if err := c.Deregister("test-registry-1"); err != nil {
+		t.Fatalf("Deregister: %v", err)
+	}
+	if entries, _, _ := InProc().Service("srv-test-registry", "", true, nil); len(entries) != 0 {
+		t.Errorf("got %v after deregistering, want none", entries)
+	}
+}
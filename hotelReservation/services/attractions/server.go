@@ -6,18 +6,24 @@ import (
 	"net"
 	"time"
 
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/graceful"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/healthcheck"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/registry"
 	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/attractions/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/store"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tls"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
 	"github.com/google/uuid"
-	"github.com/grpc-ecosystem/grpc-opentracing/go/otgrpc"
 	"github.com/hailocab/go-geoindex"
 	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/reflection"
 )
 
 const (
@@ -30,17 +36,24 @@ const (
 type Server struct {
 	pb.UnimplementedAttractionsServer
 
-	indexH *geoindex.ClusteringIndex
-	indexR *geoindex.ClusteringIndex
-	indexM *geoindex.ClusteringIndex
-	indexC *geoindex.ClusteringIndex
-	uuid   string
+	indexH     *geoindex.ClusteringIndex
+	indexR     *geoindex.ClusteringIndex
+	indexM     *geoindex.ClusteringIndex
+	indexC     *geoindex.ClusteringIndex
+	uuid       string
+	health     *healthcheck.Checker
+	grpcServer *grpc.Server
+	traffic    tracing.MetricsMark
 
 	Registry    *registry.Client
 	Tracer      opentracing.Tracer
 	Port        int
 	IpAddr      string
 	MongoClient *mongo.Client
+	// DB is the attractions-db database, that of MongoClient if nil.
+	DB store.Database
+	// MetricsPort is the admin port serving /metrics, zero to disable it.
+	MetricsPort int
 }
 
 // Run starts the server
@@ -49,20 +62,24 @@ func (s *Server) Run() error {
 		return fmt.Errorf("server port must be set")
 	}
 
+	if s.DB == nil {
+		s.DB = store.MongoDatabase(s.MongoClient.Database("attractions-db"))
+	}
+
 	if s.indexH == nil {
-		s.indexH = newGeoIndex(s.MongoClient)
+		s.indexH = newGeoIndex(s.DB)
 	}
 
 	if s.indexR == nil {
-		s.indexR = newGeoIndexRest(s.MongoClient)
+		s.indexR = newGeoIndexRest(s.DB)
 	}
 
 	if s.indexM == nil {
-		s.indexM = newGeoIndexMus(s.MongoClient)
+		s.indexM = newGeoIndexMus(s.DB)
 	}
 
 	if s.indexC == nil {
-		s.indexC = newGeoIndexCinema(s.MongoClient)
+		s.indexC = newGeoIndexCinema(s.DB)
 	}
 
 	s.uuid = uuid.New().String()
@@ -72,11 +89,17 @@ func (s *Server) Run() error {
 			Timeout: 120 * time.Second,
 		}),
 		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             time.Duration(tune.GetKeepaliveTime()) * time.Millisecond,
 			PermitWithoutStream: true,
 		}),
-		grpc.UnaryInterceptor(
-			otgrpc.OpenTracingServerInterceptor(s.Tracer),
-		),
+		grpc.MaxRecvMsgSize(tune.GetMaxRecvMsgSize()),
+		grpc.MaxSendMsgSize(tune.GetMaxSendMsgSize()),
+		grpc.UnaryInterceptor(tracing.DefaultServerInterceptorChain(tracing.ServerChainOptions{
+			Tracer: s.Tracer,
+			Interceptors: []grpc.UnaryServerInterceptor{
+				tracing.ChaosUnaryServerInterceptor(tracing.DefaultChaos),
+			},
+		})),
 	}
 
 	if tlsopt := tls.GetServerOpt(); tlsopt != nil {
@@ -84,8 +107,23 @@ func (s *Server) Run() error {
 	}
 
 	srv := grpc.NewServer(opts...)
+	s.grpcServer = srv
+	s.traffic = tracing.DefaultMetrics.Mark()
 
 	pb.RegisterAttractionsServer(srv, s)
+	if tune.GetGrpcReflection() {
+		reflection.Register(srv)
+	}
+
+	s.health = healthcheck.Register(srv, pb.Attractions_ServiceDesc.ServiceName, healthcheck.Mongo(s.MongoClient))
+	s.health.Start()
+
+	if s.MetricsPort != 0 {
+		go tracing.ServeMetrics(s.MetricsPort, tracing.DefaultMetrics)
+	}
+	if path := tune.GetFeatureFlagsFile(); path != "" {
+		go tracing.DefaultFlags.WatchFile(path, time.Duration(tune.GetFeatureFlagsPollInterval())*time.Millisecond)
+	}
 
 	// listener
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", s.Port))
@@ -102,32 +140,49 @@ func (s *Server) Run() error {
 	return srv.Serve(lis)
 }
 
-// Shutdown cleans up any processes
+// Shutdown reports the server NOT_SERVING, deregisters it and drains
+// in-flight RPCs before stopping it, then reports the traffic it served.
 func (s *Server) Shutdown() {
+	s.health.Shutdown()
 	s.Registry.Deregister(s.uuid)
+	graceful.Stop(s.grpcServer)
+	graceful.Report(s.grpcServer, s.traffic)
 }
 
-// NearbyRest returns all restaurants close to the hotel.
-func (s *Server) NearbyRest(ctx context.Context, req *pb.Request) (*pb.Result, error) {
-	log.Trace().Msgf("In Attractions NearbyRest")
+// findHotel returns the location of hotelId, the zero point if it is
+// unknown, aborting if ctx is done.
+func (s *Server) findHotel(ctx context.Context, hotelId string) (point, error) {
+	c := s.DB.Collection("hotels")
 
-	mongoSpan, _ := opentracing.StartSpanFromContext(ctx, "mongo_restaurant")
-	mongoSpan.SetTag("span.kind", "client")
-
-	c := s.MongoClient.Database("attractions-db").Collection("hotels")
-
-	curr, err := c.Find(context.TODO(), bson.M{"hotelId": req.HotelId})
+	curr, err := c.Find(ctx, bson.M{"hotelId": hotelId})
 	if err != nil {
-		log.Error().Msgf("Failed get hotels: ", err)
+		return point{}, store.Error(ctx, err, codes.Unavailable, "failed to get hotel %s", hotelId)
 	}
 	var hotelReqs []point
-	curr.All(context.TODO(), &hotelReqs)
+	if err := curr.All(ctx, &hotelReqs); err != nil {
+		return point{}, store.Error(ctx, err, codes.Unavailable, "failed to get hotel %s", hotelId)
+	}
 
 	var hotelReq point
 
 	for _, hotelHelper := range hotelReqs {
 		hotelReq = hotelHelper
 	}
+	return hotelReq, nil
+}
+
+// NearbyRest returns all restaurants close to the hotel.
+func (s *Server) NearbyRest(ctx context.Context, req *pb.Request) (*pb.Result, error) {
+	log.Trace().Msgf("In Attractions NearbyRest")
+
+	mongoSpan, _ := opentracing.StartSpanFromContext(ctx, "mongo_restaurant")
+	mongoSpan.SetTag("span.kind", "client")
+
+	hotelReq, err := s.findHotel(ctx, req.HotelId)
+	mongoSpan.Finish()
+	if err != nil {
+		return nil, err
+	}
 
 	var (
 		points = s.getNearbyPointsRest(ctx, float64(hotelReq.Plat), float64(hotelReq.Plon))
@@ -151,19 +206,10 @@ func (s *Server) NearbyMus(ctx context.Context, req *pb.Request) (*pb.Result, er
 	mongoSpan, _ := opentracing.StartSpanFromContext(ctx, "mongo_museum")
 	mongoSpan.SetTag("span.kind", "client")
 
-	c := s.MongoClient.Database("attractions-db").Collection("hotels")
-
-	curr, err := c.Find(context.TODO(), bson.M{"hotelId": req.HotelId})
+	hotelReq, err := s.findHotel(ctx, req.HotelId)
+	mongoSpan.Finish()
 	if err != nil {
-		log.Error().Msgf("Failed get hotels: ", err)
-	}
-	var hotelReqs []point
-	curr.All(context.TODO(), &hotelReqs)
-
-	var hotelReq point
-
-	for _, hotelHelper := range hotelReqs {
-		hotelReq = hotelHelper
+		return nil, err
 	}
 
 	var (
@@ -188,19 +234,10 @@ func (s *Server) NearbyCinema(ctx context.Context, req *pb.Request) (*pb.Result,
 	mongoSpan, _ := opentracing.StartSpanFromContext(ctx, "mongo_cinema")
 	mongoSpan.SetTag("span.kind", "client")
 
-	c := s.MongoClient.Database("attractions-db").Collection("hotels")
-
-	curr, err := c.Find(context.TODO(), bson.M{"hotelId": req.HotelId})
+	hotelReq, err := s.findHotel(ctx, req.HotelId)
+	mongoSpan.Finish()
 	if err != nil {
-		log.Error().Msgf("Failed get hotels: ", err)
-	}
-	var hotelReqs []point
-	curr.All(context.TODO(), &hotelReqs)
-
-	var hotelReq point
-
-	for _, hotelHelper := range hotelReqs {
-		hotelReq = hotelHelper
+		return nil, err
 	}
 
 	var (
@@ -291,19 +328,17 @@ func (s *Server) getNearbyPointsCinema(ctx context.Context, lat, lon float64) []
 }
 
 // newGeoIndex returns a geo index with points loaded
-func newGeoIndex(client *mongo.Client) *geoindex.ClusteringIndex {
+func newGeoIndex(db store.Database) *geoindex.ClusteringIndex {
 	log.Trace().Msg("new geo newGeoIndex")
 
-	collection := client.Database("attractions-db").Collection("hotels")
+	collection := db.Collection("hotels")
+	var points []*point
 	curr, err := collection.Find(context.TODO(), bson.D{})
-	if err != nil {
-		log.Error().Msgf("Failed get hotels data: ", err)
+	if err == nil {
+		err = curr.All(context.TODO(), &points)
 	}
-
-	var points []*point
-	curr.All(context.TODO(), &points)
 	if err != nil {
-		log.Error().Msgf("Failed get hotels data: ", err)
+		log.Error().Msgf("Failed get hotels data: %v", err)
 	}
 
 	// add points to index
@@ -316,19 +351,17 @@ func newGeoIndex(client *mongo.Client) *geoindex.ClusteringIndex {
 }
 
 // newGeoIndexRest returns a geo index with points loaded
-func newGeoIndexRest(client *mongo.Client) *geoindex.ClusteringIndex {
+func newGeoIndexRest(db store.Database) *geoindex.ClusteringIndex {
 	log.Trace().Msg("new geo newGeoIndexRest")
 
-	collection := client.Database("attractions-db").Collection("restaurants")
+	collection := db.Collection("restaurants")
+	var points []*Restaurant
 	curr, err := collection.Find(context.TODO(), bson.D{})
-	if err != nil {
-		log.Error().Msgf("Failed get restaurant data: ", err)
+	if err == nil {
+		err = curr.All(context.TODO(), &points)
 	}
-
-	var points []*Restaurant
-	curr.All(context.TODO(), &points)
 	if err != nil {
-		log.Error().Msgf("Failed get restaurant data: ", err)
+		log.Error().Msgf("Failed get restaurant data: %v", err)
 	}
 
 	// add points to index
@@ -341,19 +374,17 @@ func newGeoIndexRest(client *mongo.Client) *geoindex.ClusteringIndex {
 }
 
 // newGeoIndexMus returns a geo index with points loaded
-func newGeoIndexMus(client *mongo.Client) *geoindex.ClusteringIndex {
+func newGeoIndexMus(db store.Database) *geoindex.ClusteringIndex {
 	log.Trace().Msg("new geo newGeoIndexMus")
 
-	collection := client.Database("attractions-db").Collection("museums")
+	collection := db.Collection("museums")
+	var points []*Museum
 	curr, err := collection.Find(context.TODO(), bson.D{})
-	if err != nil {
-		log.Error().Msgf("Failed get restaurant data: ", err)
+	if err == nil {
+		err = curr.All(context.TODO(), &points)
 	}
-
-	var points []*Museum
-	curr.All(context.TODO(), &points)
 	if err != nil {
-		log.Error().Msgf("Failed get restaurant data: ", err)
+		log.Error().Msgf("Failed get museum data: %v", err)
 	}
 
 	// add points to index
@@ -366,19 +397,17 @@ func newGeoIndexMus(client *mongo.Client) *geoindex.ClusteringIndex {
 }
 
 // newGeoIndexCinema returns a geo index with points loaded
-func newGeoIndexCinema(client *mongo.Client) *geoindex.ClusteringIndex {
+func newGeoIndexCinema(db store.Database) *geoindex.ClusteringIndex {
 	log.Trace().Msg("new geo newGeoIndexCinema")
 
-	collection := client.Database("attractions-db").Collection("cinemas")
+	collection := db.Collection("cinemas")
+	var points []*Cinema
 	curr, err := collection.Find(context.TODO(), bson.D{})
-	if err != nil {
-		log.Error().Msgf("Failed get cinema data: ", err)
+	if err == nil {
+		err = curr.All(context.TODO(), &points)
 	}
-
-	var points []*Cinema
-	curr.All(context.TODO(), &points)
 	if err != nil {
-		log.Error().Msgf("Failed get cinema data: ", err)
+		log.Error().Msgf("Failed get cinema data: %v", err)
 	}
 
 	// add points to index
@@ -0,0 +1,116 @@
+package frontend
+
+import (
+	"io"
+	"mime"
+	"net/http"
+
+	search "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/search/proto"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxGatewayBody caps the size of the JSON bodies accepted by the gateway.
+const maxGatewayBody = 1 << 20
+
+// writeGatewayError writes st as a JSON error body, with the HTTP status
+// matching its code.
+func writeGatewayError(w http.ResponseWriter, st *status.Status) {
+	body, err := protojson.Marshal(st.Proto())
+	if err != nil {
+		http.Error(w, st.Message(), httpStatusFromCode(st.Code()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatusFromCode(st.Code()))
+	w.Write(body)
+}
+
+// readGatewayRequest decodes the JSON body of r into req. It writes the
+// error response and returns false if r is not a POST of the protojson
+// encoding of req.
+func readGatewayRequest(w http.ResponseWriter, r *http.Request, req proto.Message) bool {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Use POST", http.StatusMethodNotAllowed)
+		return false
+	}
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+		http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+		return false
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxGatewayBody))
+	if err != nil {
+		writeGatewayError(w, status.Newf(codes.InvalidArgument, "failed to read request body: %v", err))
+		return false
+	}
+	if err := protojson.Unmarshal(body, req); err != nil {
+		writeGatewayError(w, status.Newf(codes.InvalidArgument, "malformed request body: %v", err))
+		return false
+	}
+	return true
+}
+
+// searchGatewayHandler serves the search Nearby RPC over HTTP. Clients POST
+// the JSON form of a NearbyRequest and get back the JSON form of the
+// SearchResult, or of the gRPC status on failure.
+func (s *Server) searchGatewayHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	req := new(search.NearbyRequest)
+	if !readGatewayRequest(w, r, req) {
+		return
+	}
+
+	res, err := s.SearchClient.Nearby(r.Context(), req)
+	if err != nil {
+		log.Error().Msgf("searchGatewayHandler Nearby failed: %v", err)
+		writeGatewayError(w, status.Convert(err))
+		return
+	}
+
+	body, err := protojson.Marshal(res)
+	if err != nil {
+		writeGatewayError(w, status.Newf(codes.Internal, "failed to encode response: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// hotelsGatewayHandler serves the search NearbyHotels RPC over HTTP, like
+// searchGatewayHandler: one POST returns the hotels found with their
+// profiles and rates.
+func (s *Server) hotelsGatewayHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	req := new(search.NearbyRequest)
+	if !readGatewayRequest(w, r, req) {
+		return
+	}
+
+	res, err := s.SearchClient.NearbyHotels(r.Context(), req)
+	if err != nil {
+		log.Error().Msgf("hotelsGatewayHandler NearbyHotels failed: %v", err)
+		writeGatewayError(w, status.Convert(err))
+		return
+	}
+	if res.RatesOmitted {
+		degraded(w, "rate")
+	}
+	if res.ProfilesOmitted {
+		degraded(w, "profile")
+	}
+
+	body, err := protojson.Marshal(res)
+	if err != nil {
+		writeGatewayError(w, status.Newf(codes.Internal, "failed to encode response: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
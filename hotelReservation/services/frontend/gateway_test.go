@@ -0,0 +1,206 @@
+package frontend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	search "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/search/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// fakeSearch answers Nearby with the result of nearby and NearbyHotels with
+// that of hotels, and records the last request.
+type fakeSearch struct {
+	search.SearchClient
+	nearby func(req *search.NearbyRequest) (*search.SearchResult, error)
+	hotels func(req *search.NearbyRequest) (*search.HotelsResult, error)
+	last   *search.NearbyRequest
+}
+
+func (f *fakeSearch) Nearby(ctx context.Context, req *search.NearbyRequest, opts ...grpc.CallOption) (*search.SearchResult, error) {
+	f.last = req
+	return f.nearby(req)
+}
+
+func (f *fakeSearch) NearbyHotels(ctx context.Context, req *search.NearbyRequest, opts ...grpc.CallOption) (*search.HotelsResult, error) {
+	f.last = req
+	return f.hotels(req)
+}
+
+// postSearch posts body to the search gateway of a frontend whose search
+// client is fake.
+func postSearch(fake *fakeSearch, contentType, body string) *httptest.ResponseRecorder {
+	s := &Server{SearchClient: fake}
+	r := httptest.NewRequest(http.MethodPost, "/v1/search", strings.NewReader(body))
+	r.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	s.searchGatewayHandler(w, r)
+	return w
+}
+
+func TestSearchGateway(t *testing.T) {
+	fake := &fakeSearch{nearby: func(req *search.NearbyRequest) (*search.SearchResult, error) {
+		return &search.SearchResult{HotelIds: []string{"1", "2"}, NextPageToken: "next"}, nil
+	}}
+	w := postSearch(fake, "application/json; charset=utf-8",
+		`{"lat": 37.7867, "lon": -122.4112, "inDate": "2015-04-09", "outDate": "2015-04-10", "limit": 2, "sort": [{"field": "PRICE"}]}`)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200: %s", w.Code, w.Body)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("got Content-Type %q, want application/json", ct)
+	}
+	want := &search.NearbyRequest{
+		Lat: 37.7867, Lon: -122.4112, InDate: "2015-04-09", OutDate: "2015-04-10", Limit: 2,
+		Sort: []*search.SortKey{{Field: search.SortKey_PRICE}},
+	}
+	if !proto.Equal(fake.last, want) {
+		t.Errorf("searched with %v, want %v", fake.last, want)
+	}
+
+	var res struct {
+		HotelIds      []string `json:"hotelIds"`
+		NextPageToken string   `json:"nextPageToken"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("decoding %s: %v", w.Body, err)
+	}
+	if strings.Join(res.HotelIds, ",") != "1,2" || res.NextPageToken != "next" {
+		t.Errorf("got %s, want hotels 1 and 2 and the next page token", w.Body)
+	}
+}
+
+func TestSearchGatewayBadRequests(t *testing.T) {
+	fake := &fakeSearch{nearby: func(req *search.NearbyRequest) (*search.SearchResult, error) {
+		t.Errorf("search called with %v", req)
+		return &search.SearchResult{}, nil
+	}}
+	tests := []struct {
+		name, contentType, body string
+		want                    int
+	}{
+		{"malformed json", "application/json", `{"lat": 37.7867,`, http.StatusBadRequest},
+		{"unknown field", "application/json", `{"latitude": 37.7867}`, http.StatusBadRequest},
+		{"wrong type", "application/json", `{"lat": "north"}`, http.StatusBadRequest},
+		{"not json", "text/plain", `lat=37.7867`, http.StatusUnsupportedMediaType},
+		{"no content type", "", `{}`, http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		if w := postSearch(fake, tt.contentType, tt.body); w.Code != tt.want {
+			t.Errorf("%s: got status %d, want %d: %s", tt.name, w.Code, tt.want, w.Body)
+		}
+	}
+
+	s := &Server{SearchClient: fake}
+	w := httptest.NewRecorder()
+	s.searchGatewayHandler(w, httptest.NewRequest(http.MethodGet, "/v1/search", nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != http.MethodPost {
+		t.Errorf("GET: got status %d, Allow %q, want 405 allowing POST", w.Code, w.Header().Get("Allow"))
+	}
+}
+
+func TestSearchGatewayErrors(t *testing.T) {
+	tests := []struct {
+		code codes.Code
+		want int
+	}{
+		{codes.InvalidArgument, http.StatusBadRequest},
+		{codes.NotFound, http.StatusNotFound},
+		{codes.Unavailable, http.StatusServiceUnavailable},
+		{codes.Internal, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		fake := &fakeSearch{nearby: func(req *search.NearbyRequest) (*search.SearchResult, error) {
+			return nil, status.Error(tt.code, "search failed")
+		}}
+		w := postSearch(fake, "application/json", `{}`)
+		if w.Code != tt.want {
+			t.Errorf("%v: got status %d, want %d", tt.code, w.Code, tt.want)
+		}
+
+		var st struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &st); err != nil {
+			t.Errorf("%v: decoding %s: %v", tt.code, w.Body, err)
+			continue
+		}
+		if codes.Code(st.Code) != tt.code || st.Message != "search failed" {
+			t.Errorf("%v: got body %s, want the status", tt.code, w.Body)
+		}
+	}
+}
+
+// postHotels posts body to the hotels gateway of a frontend whose search
+// client is fake.
+func postHotels(fake *fakeSearch, body string) *httptest.ResponseRecorder {
+	s := &Server{SearchClient: fake}
+	r := httptest.NewRequest(http.MethodPost, "/api/hotels", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.hotelsGatewayHandler(w, r)
+	return w
+}
+
+func TestHotelsGateway(t *testing.T) {
+	tests := []struct {
+		name                          string
+		ratesOmitted, profilesOmitted bool
+		want                          string
+	}{
+		{"complete", false, false, "[]"},
+		{"without rates", true, false, "[rate]"},
+		{"without profiles", false, true, "[profile]"},
+		{"without either", true, true, "[rate profile]"},
+	}
+	for _, tt := range tests {
+		fake := &fakeSearch{hotels: func(req *search.NearbyRequest) (*search.HotelsResult, error) {
+			return &search.HotelsResult{
+				Hotels:          []*search.HotelSummary{{Id: "1", Name: "Hotel 1", StayTotal: 120}},
+				RatesOmitted:    tt.ratesOmitted,
+				ProfilesOmitted: tt.profilesOmitted,
+			}, nil
+		}}
+		w := postHotels(fake, `{"lat": 37.7867, "lon": -122.4112, "minStars": 3}`)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: got status %d: %s", tt.name, w.Code, w.Body)
+			continue
+		}
+		if fake.last.MinStars != 3 {
+			t.Errorf("%s: searched with %v, want the request posted", tt.name, fake.last)
+		}
+		if got := fmt.Sprint(w.Header().Values("X-Degraded")); got != tt.want {
+			t.Errorf("%s: degraded %s, want %s", tt.name, got, tt.want)
+		}
+		var res struct {
+			Hotels []struct {
+				Id        string  `json:"id"`
+				Name      string  `json:"name"`
+				StayTotal float64 `json:"stayTotal"`
+			} `json:"hotels"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatalf("%s: decoding %s: %v", tt.name, w.Body, err)
+		}
+		if len(res.Hotels) != 1 || res.Hotels[0].Name != "Hotel 1" || res.Hotels[0].StayTotal != 120 {
+			t.Errorf("%s: got %s, want hotel 1 with its profile and rate", tt.name, w.Body)
+		}
+	}
+
+	fake := &fakeSearch{hotels: func(req *search.NearbyRequest) (*search.HotelsResult, error) {
+		return nil, status.Error(codes.Unavailable, "profile down")
+	}}
+	if w := postHotels(fake, `{}`); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("failing search: got status %d, want 503", w.Code)
+	}
+}
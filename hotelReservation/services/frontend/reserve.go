@@ -0,0 +1,160 @@
+package frontend
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	rate "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/rate/proto"
+	reservation "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/reservation/proto"
+	user "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/user/proto"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// reserveResponse is the JSON body of a confirmed /reserve.
+type reserveResponse struct {
+	ReservationId string  `json:"reservationId"`
+	HotelId       string  `json:"hotelId"`
+	RoomType      string  `json:"roomType"`
+	InDate        string  `json:"inDate"`
+	OutDate       string  `json:"outDate"`
+	Rooms         int     `json:"rooms"`
+	TotalPrice    float64 `json:"totalPrice"`
+	Currency      string  `json:"currency"`
+}
+
+// reserveHandler books rooms in a hotel picked from search results in a
+// single call. It authenticates the customer, prices the stay and makes the
+// reservation, which checks and claims the rooms atomically: if the hotel
+// filled up since the search, it fails with 409 Conflict and nothing is
+// booked.
+//
+// Clients POST hotelId, inDate, outDate, customerName, username, password
+// and the optional number (1 by default), roomType and currency, as query
+// or form parameters. An Idempotency-Key header makes the call safe to
+// retry: retries with the same key return the reservation first made.
+func (s *Server) reserveHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	ctx := r.Context()
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Use POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	inDate, outDate := r.FormValue("inDate"), r.FormValue("outDate")
+	if inDate == "" || outDate == "" {
+		http.Error(w, "Please specify inDate/outDate params", http.StatusBadRequest)
+		return
+	}
+	if !checkDataFormat(inDate) || !checkDataFormat(outDate) {
+		http.Error(w, "Please check inDate/outDate format (YYYY-MM-DD)", http.StatusBadRequest)
+		return
+	}
+
+	hotelId := r.FormValue("hotelId")
+	if hotelId == "" {
+		http.Error(w, "Please specify hotelId params", http.StatusBadRequest)
+		return
+	}
+
+	customerName := r.FormValue("customerName")
+	if customerName == "" {
+		http.Error(w, "Please specify customerName params", http.StatusBadRequest)
+		return
+	}
+
+	username, password := r.FormValue("username"), r.FormValue("password")
+	if username == "" || password == "" {
+		http.Error(w, "Please specify username and password", http.StatusBadRequest)
+		return
+	}
+
+	rooms := 1
+	if num := r.FormValue("number"); num != "" {
+		n, err := strconv.Atoi(num)
+		if err != nil || n <= 0 {
+			http.Error(w, "Please specify a positive number param", http.StatusBadRequest)
+			return
+		}
+		rooms = n
+	}
+	roomType, currency := r.FormValue("roomType"), r.FormValue("currency")
+
+	userResp, err := s.UserClient.CheckUser(ctx, &user.Request{
+		Username: username,
+		Password: password,
+	})
+	if err != nil {
+		writeGRPCError(w, err)
+		return
+	}
+	if !userResp.Correct {
+		http.Error(w, "Failed. Please check your username and password.", http.StatusUnauthorized)
+		return
+	}
+
+	// Price the stay before booking, so that a hotel that can't be priced
+	// is never booked.
+	rateResp, err := s.RateClient.GetRates(ctx, &rate.Request{
+		HotelIds: []string{hotelId},
+		InDate:   inDate,
+		OutDate:  outDate,
+		Currency: currency,
+		RoomType: roomType,
+	})
+	if err != nil {
+		writeGRPCError(w, err)
+		return
+	}
+	var plan *rate.RatePlan
+	for _, p := range rateResp.RatePlans {
+		if p.HotelId == hotelId && (plan == nil || p.StayTotal < plan.StayTotal) {
+			plan = p
+		}
+	}
+	if plan == nil {
+		http.Error(w, fmt.Sprintf("Hotel %s has no rate for these dates", hotelId), http.StatusNotFound)
+		return
+	}
+
+	resResp, err := s.ReservationClient.MakeReservation(ctx, &reservation.Request{
+		CustomerName:   customerName,
+		HotelId:        []string{hotelId},
+		InDate:         inDate,
+		OutDate:        outDate,
+		RoomNumber:     int32(rooms),
+		RoomType:       roomType,
+		IdempotencyKey: r.Header.Get("Idempotency-Key"),
+	})
+	if status.Code(err) == codes.FailedPrecondition {
+		log.Debug().Msgf("reserveHandler: hotel %s filled up: %v", hotelId, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(errorBody{
+			Code:    codes.FailedPrecondition.String(),
+			Message: fmt.Sprintf("Hotel %s is no longer available from %s to %s", hotelId, inDate, outDate),
+		})
+		return
+	}
+	if err != nil {
+		writeGRPCError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reserveResponse{
+		ReservationId: resResp.ReservationId,
+		HotelId:       hotelId,
+		RoomType:      resResp.RoomType,
+		InDate:        inDate,
+		OutDate:       outDate,
+		Rooms:         rooms,
+		TotalPrice:    plan.StayTotal * float64(rooms),
+		Currency:      rateResp.Currency,
+	})
+}
@@ -0,0 +1,189 @@
+package frontend
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/datagen"
+	profile "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/profile/proto"
+	rate "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/rate/proto"
+	reservation "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/reservation/proto"
+	search "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/search/proto"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The stay searched and booked by the self-test: one night at the center of
+// the area searched by the wrk2 workloads, where the seeded hotels are.
+const (
+	selfTestLat      = 38.0235
+	selfTestLon      = -122.095
+	selfTestInDate   = datagen.InDate
+	selfTestOutDate  = "2015-04-10"
+	selfTestCustomer = "frontend-selftest"
+)
+
+// SelfTestStep is the outcome of a step of SelfTest.
+type SelfTestStep struct {
+	// Name is the step, e.g. "search".
+	Name string
+	// Dependency is the backend the step called, e.g. "srv-search".
+	Dependency string
+	Duration   time.Duration
+	// Err is why the step failed, nil if it passed. Code is its status
+	// code, OK if it passed.
+	Err  error
+	Code codes.Code
+	// Skipped steps weren't run, an earlier step having failed.
+	Skipped bool
+}
+
+// SelfTestReport lists the steps of SelfTest in the order they run.
+type SelfTestReport struct {
+	Steps []SelfTestStep
+}
+
+// OK reports whether every step passed.
+func (r SelfTestReport) OK() bool {
+	for _, step := range r.Steps {
+		if step.Err != nil || step.Skipped {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns one line per step, with its outcome, dependency and time, and
+// the status code and error of failed steps.
+func (r SelfTestReport) String() string {
+	var b strings.Builder
+	for _, step := range r.Steps {
+		switch {
+		case step.Skipped:
+			fmt.Fprintf(&b, "SKIP %-8s %s\n", step.Name, step.Dependency)
+		case step.Err != nil:
+			fmt.Fprintf(&b, "FAIL %-8s %s %v: %s: %v\n", step.Name, step.Dependency, step.Duration.Round(time.Microsecond), step.Code, status.Convert(step.Err).Message())
+		default:
+			fmt.Fprintf(&b, "PASS %-8s %s %v\n", step.Name, step.Dependency, step.Duration.Round(time.Microsecond))
+		}
+	}
+	return b.String()
+}
+
+// SelfTest runs the flow of a customer against the backends, with the
+// clients of s: it searches hotels, gets their profiles and rates, books a
+// room in one of them and cancels that reservation. Each step must succeed
+// and return something for the next to run; those left are reported as
+// skipped. The reservation is cancelled whatever happens once it is made.
+// The clients must be set, e.g. by InitClients. Calls wait for their
+// backend to be reachable, so that a fresh deployment isn't failed while
+// its connections come up: a backend that is down fails its step with
+// DeadlineExceeded once ctx is done.
+func (s *Server) SelfTest(ctx context.Context) SelfTestReport {
+	ready := grpc.WaitForReady(true)
+	var (
+		report   SelfTestReport
+		failed   bool
+		hotelIds []string
+		hotelId  string
+		resId    string
+	)
+	run := func(name, dependency string, step func() error) {
+		if failed {
+			report.Steps = append(report.Steps, SelfTestStep{Name: name, Dependency: dependency, Skipped: true})
+			return
+		}
+		start := time.Now()
+		err := step()
+		res := SelfTestStep{Name: name, Dependency: dependency, Duration: time.Since(start), Err: err, Code: status.Code(err)}
+		if err != nil {
+			failed = true
+			log.Error().Msgf("Self-test: %s failed on %s: %s: %v", name, dependency, res.Code, err)
+		} else {
+			log.Info().Msgf("Self-test: %s passed in %v", name, res.Duration)
+		}
+		report.Steps = append(report.Steps, res)
+	}
+
+	run("search", "srv-search", func() error {
+		res, err := s.SearchClient.Nearby(ctx, &search.NearbyRequest{
+			Lat:     selfTestLat,
+			Lon:     selfTestLon,
+			InDate:  selfTestInDate,
+			OutDate: selfTestOutDate,
+		}, ready)
+		if err != nil {
+			return err
+		}
+		if len(res.HotelIds) == 0 {
+			return status.Errorf(codes.NotFound, "no hotels near %v, %v", selfTestLat, selfTestLon)
+		}
+		hotelIds = res.HotelIds
+		return nil
+	})
+
+	run("profile", "srv-profile", func() error {
+		res, err := s.ProfileClient.GetProfiles(ctx, &profile.Request{HotelIds: hotelIds}, ready)
+		if err != nil {
+			return err
+		}
+		if len(res.Hotels) == 0 {
+			return status.Errorf(codes.NotFound, "no profiles of hotels %v", hotelIds)
+		}
+		return nil
+	})
+
+	run("rate", "srv-rate", func() error {
+		res, err := s.RateClient.GetRates(ctx, &rate.Request{
+			HotelIds: hotelIds,
+			InDate:   selfTestInDate,
+			OutDate:  selfTestOutDate,
+		}, ready)
+		if err != nil {
+			return err
+		}
+		if len(res.RatePlans) == 0 {
+			return status.Errorf(codes.NotFound, "no rates of hotels %v", hotelIds)
+		}
+		hotelId = res.RatePlans[0].HotelId
+		return nil
+	})
+
+	run("reserve", "srv-reservation", func() error {
+		res, err := s.ReservationClient.MakeReservation(ctx, &reservation.Request{
+			CustomerName: selfTestCustomer,
+			HotelId:      []string{hotelId},
+			InDate:       selfTestInDate,
+			OutDate:      selfTestOutDate,
+			RoomNumber:   1,
+		}, ready)
+		if err != nil {
+			return err
+		}
+		resId = res.ReservationId
+		return nil
+	})
+
+	// Cancel even if the test ran out of time, so that no room stays booked.
+	cancelCtx := ctx
+	if resId != "" && ctx.Err() != nil {
+		var cancel context.CancelFunc
+		cancelCtx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+	}
+	run("cancel", "srv-reservation", func() error {
+		res, err := s.ReservationClient.CancelReservation(cancelCtx, &reservation.CancelRequest{ReservationId: resId}, ready)
+		if err != nil {
+			return err
+		}
+		if res.Status != reservation.CancelResult_CANCELLED {
+			return status.Errorf(codes.FailedPrecondition, "reservation %s not cancelled: %s", resId, res.Status)
+		}
+		return nil
+	})
+	return report
+}
@@ -1,17 +1,21 @@
 package frontend
 
 import (
+	"context"
 	"embed"
 	"encoding/json"
 	"fmt"
 	"io/fs"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/dialer"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/registry"
 	attractions "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/attractions/proto"
 	profile "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/profile/proto"
+	rate "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/rate/proto"
 	recommendation "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/recommendation/proto"
 	reservation "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/reservation/proto"
 	review "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/review/proto"
@@ -19,10 +23,13 @@ import (
 	user "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/user/proto"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tls"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
-	_ "github.com/mbobakov/grpc-consul-resolver"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
+	"github.com/grpc-ecosystem/grpc-opentracing/go/otgrpc"
 	"github.com/opentracing/opentracing-go"
 	"github.com/rs/zerolog/log"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var (
@@ -30,15 +37,25 @@ var (
 	content embed.FS
 )
 
+// idempotentMethods are the read-only calls to the backends, safe to retry
+// and to send backup requests for.
+var idempotentMethods = []string{
+	search.Search_Nearby_FullMethodName,
+	search.Search_NearbyHotels_FullMethodName,
+	profile.Profile_GetProfiles_FullMethodName,
+	rate.Rate_GetRates_FullMethodName,
+	recommendation.Recommendation_GetRecommendations_FullMethodName,
+	reservation.Reservation_CheckAvailability_FullMethodName,
+	reservation.Reservation_CheckAvailabilityMulti_FullMethodName,
+	review.Review_GetReviews_FullMethodName,
+	attractions.Attractions_NearbyRest_FullMethodName,
+	attractions.Attractions_NearbyMus_FullMethodName,
+	attractions.Attractions_NearbyCinema_FullMethodName,
+}
+
 // Server implements frontend service
 type Server struct {
-	searchClient         search.SearchClient
-	profileClient        profile.ProfileClient
-	recommendationClient recommendation.RecommendationClient
-	userClient           user.UserClient
-	reviewClient         review.ReviewClient
-	attractionsClient    attractions.AttractionsClient
-	reservationClient    reservation.ReservationClient
+	httpServer *http.Server
 
 	KnativeDns string
 	IpAddr     string
@@ -46,6 +63,36 @@ type Server struct {
 	Port       int
 	Tracer     opentracing.Tracer
 	Registry   *registry.Client
+	// GrpcPoolSize is the number of connections opened to each backend.
+	GrpcPoolSize int
+	// RequestBudget bounds each request and all its downstream calls, zero
+	// for no bound.
+	RequestBudget time.Duration
+	// BudgetSlice is the share of RequestBudget under which downstream
+	// calls are reported as starved.
+	BudgetSlice float64
+	// TraceSampleTokens are the tokens of the ForceSampleKey header forcing
+	// the trace of a request to be sampled.
+	TraceSampleTokens []string
+	// Timeouts bound each call to the backends, keyed by service name
+	// without the srv- prefix, e.g. "rate". Missing ones leave the calls
+	// unbounded.
+	Timeouts map[string]time.Duration
+	// HedgeDelay is the time after which a backup request is sent for a
+	// read-only call still waiting for its reply, up to MaxHedges times.
+	// Zero disables hedging.
+	HedgeDelay time.Duration
+	MaxHedges  int
+
+	// The clients of the backends, dialed through ConsulAddr by Run if nil.
+	SearchClient         search.SearchClient
+	ProfileClient        profile.ProfileClient
+	RecommendationClient recommendation.RecommendationClient
+	UserClient           user.UserClient
+	ReviewClient         review.ReviewClient
+	AttractionsClient    attractions.AttractionsClient
+	ReservationClient    reservation.ReservationClient
+	RateClient           rate.RateClient
 }
 
 // Run the server
@@ -60,6 +107,52 @@ func (s *Server) Run() error {
 		return err
 	}
 
+	if err := s.InitClients(); err != nil {
+		return err
+	}
+
+	log.Trace().Msg("frontend before mux")
+	mux := tracing.NewServeMux(s.Tracer)
+	mux.Handle("/", http.FileServer(http.FS(staticContent)))
+	mux.Handle("/debug/sizes", tracing.DefaultSizeHistograms)
+	s.handle(mux, "/hotels", s.searchHandler)
+	s.handle(mux, "/recommendations", s.recommendHandler)
+	s.handle(mux, "/user", s.userHandler)
+	s.handle(mux, "/review", s.reviewHandler)
+	s.handle(mux, "/restaurants", s.restaurantHandler)
+	s.handle(mux, "/museums", s.museumHandler)
+	s.handle(mux, "/cinema", s.cinemaHandler)
+	s.handle(mux, "/reservation", s.reservationHandler)
+	s.handle(mux, "/reserve", s.reserveHandler)
+	s.handle(mux, "/api/search", s.searchGatewayHandler)
+	s.handle(mux, "/api/hotels", s.hotelsGatewayHandler)
+	s.handle(mux, "/ws/hotels", s.hotelsStreamHandler)
+
+	log.Trace().Msg("frontend starts serving")
+
+	tlsconfig := tls.GetHttpsOpt()
+	srv := &http.Server{
+		Addr:    fmt.Sprintf(":%d", s.Port),
+		Handler: mux,
+	}
+	s.httpServer = srv
+	if tlsconfig != nil {
+		log.Info().Msg("Serving https")
+		srv.TLSConfig = tlsconfig
+		err = srv.ListenAndServeTLS("", "")
+	} else {
+		log.Info().Msg("Serving http")
+		err = srv.ListenAndServe()
+	}
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// InitClients dials the backends whose client isn't set, through
+// ConsulAddr.
+func (s *Server) InitClients() error {
 	log.Info().Msg("Initializing gRPC clients...")
 	if err := s.initSearchClient("srv-search"); err != nil {
 		return err
@@ -89,121 +182,180 @@ func (s *Server) Run() error {
 		return err
 	}
 
-	log.Info().Msg("Successful")
+	if err := s.initRateClient("srv-rate"); err != nil {
+		return err
+	}
 
-	log.Trace().Msg("frontend before mux")
-	mux := tracing.NewServeMux(s.Tracer)
-	mux.Handle("/", http.FileServer(http.FS(staticContent)))
-	mux.Handle("/hotels", http.HandlerFunc(s.searchHandler))
-	mux.Handle("/recommendations", http.HandlerFunc(s.recommendHandler))
-	mux.Handle("/user", http.HandlerFunc(s.userHandler))
-	mux.Handle("/review", http.HandlerFunc(s.reviewHandler))
-	mux.Handle("/restaurants", http.HandlerFunc(s.restaurantHandler))
-	mux.Handle("/museums", http.HandlerFunc(s.museumHandler))
-	mux.Handle("/cinema", http.HandlerFunc(s.cinemaHandler))
-	mux.Handle("/reservation", http.HandlerFunc(s.reservationHandler))
+	log.Info().Msg("Successful")
+	return nil
+}
 
-	log.Trace().Msg("frontend starts serving")
+// handle serves pattern with handler, bounded by the request budget,
+// sampled when forced and with the cost of its downstream calls tagged.
+func (s *Server) handle(mux *tracing.TracedServeMux, pattern string, handler http.HandlerFunc) {
+	mux.Handle(pattern, tracing.WithForcedSampling(tracing.WithCostAccounting(tracing.WithDeadlineBudget(handler, s.RequestBudget)), s.TraceSampleTokens))
+}
 
-	tlsconfig := tls.GetHttpsOpt()
-	srv := &http.Server{
-		Addr:    fmt.Sprintf(":%d", s.Port),
-		Handler: mux,
+// Shutdown stops accepting connections and waits for in-flight requests to
+// finish, closing the remaining connections after the shutdown timeout.
+func (s *Server) Shutdown() {
+	if s.httpServer == nil {
+		return
 	}
-	if tlsconfig != nil {
-		log.Info().Msg("Serving https")
-		srv.TLSConfig = tlsconfig
-		return srv.ListenAndServeTLS("x509/server_cert.pem", "x509/server_key.pem")
-	} else {
-		log.Info().Msg("Serving http")
-		return srv.ListenAndServe()
+
+	timeout := time.Duration(tune.GetShutdownTimeout()) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := s.httpServer.Shutdown(ctx); err != nil {
+		log.Warn().Msgf("In-flight requests still running after %v, closing: %v", timeout, err)
+		s.httpServer.Close()
 	}
 }
 
 func (s *Server) initSearchClient(name string) error {
+	if s.SearchClient != nil {
+		return nil
+	}
 	conn, err := s.getGprcConn(name)
 	if err != nil {
 		return fmt.Errorf("dialer error: %v", err)
 	}
-	s.searchClient = search.NewSearchClient(conn)
+	s.SearchClient = search.NewSearchClient(conn)
 	return nil
 }
 
 func (s *Server) initReviewClient(name string) error {
-	conn, err := dialer.Dial(
-		name,
-		dialer.WithTracer(s.Tracer),
-		dialer.WithBalancer(s.Registry.Client),
-	)
+	if s.ReviewClient != nil {
+		return nil
+	}
+	conn, err := s.getGprcConn(name)
 	if err != nil {
 		return fmt.Errorf("dialer error: %v", err)
 	}
-	s.reviewClient = review.NewReviewClient(conn)
+	s.ReviewClient = review.NewReviewClient(conn)
 	return nil
 }
 
 func (s *Server) initAttractionsClient(name string) error {
-	conn, err := dialer.Dial(
-		name,
-		dialer.WithTracer(s.Tracer),
-		dialer.WithBalancer(s.Registry.Client),
-	)
+	if s.AttractionsClient != nil {
+		return nil
+	}
+	conn, err := s.getGprcConn(name)
 	if err != nil {
 		return fmt.Errorf("dialer error: %v", err)
 	}
-	s.attractionsClient = attractions.NewAttractionsClient(conn)
+	s.AttractionsClient = attractions.NewAttractionsClient(conn)
 	return nil
 }
 
 func (s *Server) initProfileClient(name string) error {
+	if s.ProfileClient != nil {
+		return nil
+	}
 	conn, err := s.getGprcConn(name)
 	if err != nil {
 		return fmt.Errorf("dialer error: %v", err)
 	}
-	s.profileClient = profile.NewProfileClient(conn)
+	s.ProfileClient = profile.NewProfileClient(conn)
 	return nil
 }
 
 func (s *Server) initRecommendationClient(name string) error {
+	if s.RecommendationClient != nil {
+		return nil
+	}
 	conn, err := s.getGprcConn(name)
 	if err != nil {
 		return fmt.Errorf("dialer error: %v", err)
 	}
-	s.recommendationClient = recommendation.NewRecommendationClient(conn)
+	s.RecommendationClient = recommendation.NewRecommendationClient(conn)
 	return nil
 }
 
 func (s *Server) initUserClient(name string) error {
+	if s.UserClient != nil {
+		return nil
+	}
 	conn, err := s.getGprcConn(name)
 	if err != nil {
 		return fmt.Errorf("dialer error: %v", err)
 	}
-	s.userClient = user.NewUserClient(conn)
+	s.UserClient = user.NewUserClient(conn)
 	return nil
 }
 
 func (s *Server) initReservation(name string) error {
+	if s.ReservationClient != nil {
+		return nil
+	}
+	conn, err := s.getGprcConn(name)
+	if err != nil {
+		return fmt.Errorf("dialer error: %v", err)
+	}
+	s.ReservationClient = reservation.NewReservationClient(conn)
+	return nil
+}
+
+func (s *Server) initRateClient(name string) error {
+	if s.RateClient != nil {
+		return nil
+	}
 	conn, err := s.getGprcConn(name)
 	if err != nil {
 		return fmt.Errorf("dialer error: %v", err)
 	}
-	s.reservationClient = reservation.NewReservationClient(conn)
+	s.RateClient = rate.NewRateClient(conn)
 	return nil
 }
 
-func (s *Server) getGprcConn(name string) (*grpc.ClientConn, error) {
+func (s *Server) getGprcConn(name string) (grpc.ClientConnInterface, error) {
 	log.Info().Msg("get Grpc conn is :")
 	log.Info().Msg(s.KnativeDns)
 	log.Info().Msg(fmt.Sprintf("%s.%s", name, s.KnativeDns))
 
+	poolSize := s.GrpcPoolSize
+	if poolSize < 1 {
+		poolSize = 1
+	}
+
+	dep := strings.TrimPrefix(name, "srv-")
+	retry := tracing.RetryUnaryClientInterceptor(tune.GetRetryAttempts(),
+		time.Duration(tune.GetRetryBackoff())*time.Millisecond, codes.Unavailable)
+	interceptors := dialer.WithUnaryInterceptors(
+		tracing.BudgetUnaryClientInterceptor(s.BudgetSlice),
+		tracing.RequestIDUnaryClientInterceptor,
+		tracing.TenantUnaryClientInterceptor,
+		tracing.CostUnaryClientInterceptor,
+		tracing.StatusTaggingUnaryClientInterceptor,
+		tracing.LatencyTaggingUnaryClientInterceptor,
+		tracing.SizeTaggingUnaryClientInterceptor,
+		tracing.DeadlineWarningUnaryClientInterceptor(time.Duration(tune.GetDeadlineWarn())*time.Millisecond),
+		tracing.CircuitBreakerUnaryClientInterceptor(tune.GetBreakerThreshold(),
+			time.Duration(tune.GetBreakerCooldown())*time.Millisecond),
+		tracing.MethodsUnaryClientInterceptor(retry, idempotentMethods...),
+		tracing.TimeoutUnaryClientInterceptor(dep, s.Timeouts[dep]),
+		tracing.HedgingUnaryClientInterceptor(s.HedgeDelay, s.MaxHedges, idempotentMethods...),
+		tracing.ConnStateUnaryClientInterceptor(),
+	)
+	streamInterceptors := dialer.WithStreamInterceptors(
+		otgrpc.OpenTracingStreamClientInterceptor(s.Tracer),
+		tracing.SizeTaggingStreamClientInterceptor,
+		tracing.RequestIDStreamClientInterceptor,
+	)
 	if s.KnativeDns != "" {
-		return dialer.Dial(
+		return dialer.DialPool(
 			fmt.Sprintf("consul://%s/%s.%s", s.ConsulAddr, name, s.KnativeDns),
-			dialer.WithTracer(s.Tracer))
+			poolSize,
+			dialer.WithTracer(s.Tracer),
+			interceptors,
+			streamInterceptors)
 	} else {
-		return dialer.Dial(
+		return dialer.DialPool(
 			fmt.Sprintf("consul://%s/%s", s.ConsulAddr, name),
+			poolSize,
 			dialer.WithTracer(s.Tracer),
+			interceptors,
+			streamInterceptors,
 			dialer.WithBalancer(s.Registry.Client),
 		)
 	}
@@ -234,20 +386,65 @@ func (s *Server) searchHandler(w http.ResponseWriter, r *http.Request) {
 	Lon, _ := strconv.ParseFloat(sLon, 32)
 	lon := float32(Lon)
 
+	// optional pagination params
+	var limit int64
+	if sLimit := r.URL.Query().Get("limit"); sLimit != "" {
+		var err error
+		if limit, err = strconv.ParseInt(sLimit, 10, 32); err != nil || limit < 0 {
+			http.Error(w, "Please specify a non-negative limit param", http.StatusBadRequest)
+			return
+		}
+	}
+	pageToken := r.URL.Query().Get("pageToken")
+
+	// optional filter params, 0 means no bound
+	var minPrice, maxPrice, minStars float64
+	for param, v := range map[string]*float64{"minPrice": &minPrice, "maxPrice": &maxPrice, "minStars": &minStars} {
+		if str := r.URL.Query().Get(param); str != "" {
+			f, err := strconv.ParseFloat(str, 64)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Please specify a numeric %s param", param), http.StatusBadRequest)
+				return
+			}
+			*v = f
+		}
+	}
+
+	// optional sort keys, e.g. distance,-price for the cheapest among the
+	// nearest
+	sortKeys, err := parseSortKeys(r.URL.Query().Get("sort"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	log.Trace().Msg("starts searchHandler querying downstream")
 
 	log.Trace().Msgf("SEARCH [lat: %v, lon: %v, inDate: %v, outDate: %v", lat, lon, inDate, outDate)
 	// search for best hotels
-	searchResp, err := s.searchClient.Nearby(ctx, &search.NearbyRequest{
-		Lat:     lat,
-		Lon:     lon,
-		InDate:  inDate,
-		OutDate: outDate,
+	searchResp, err := s.SearchClient.Nearby(ctx, &search.NearbyRequest{
+		Lat:       lat,
+		Lon:       lon,
+		InDate:    inDate,
+		OutDate:   outDate,
+		Limit:     int32(limit),
+		PageToken: pageToken,
+		MinPrice:  minPrice,
+		MaxPrice:  maxPrice,
+		MinStars:  float32(minStars),
+		Sort:      sortKeys,
 	})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeGRPCError(w, err)
 		return
 	}
+	if searchResp.NextPageToken != "" {
+		w.Header().Set("X-Next-Page-Token", searchResp.NextPageToken)
+		w.Header().Add("Access-Control-Expose-Headers", "X-Next-Page-Token")
+	}
+	if searchResp.RatesOmitted {
+		degraded(w, "rate")
+	}
 
 	log.Trace().Msg("SearchHandler gets searchResp")
 	//for _, hid := range searchResp.HotelIds {
@@ -260,30 +457,48 @@ func (s *Server) searchHandler(w http.ResponseWriter, r *http.Request) {
 		locale = "en"
 	}
 
-	reservationResp, err := s.reservationClient.CheckAvailability(ctx, &reservation.Request{
+	reservationResp, err := s.ReservationClient.CheckAvailability(ctx, &reservation.Request{
 		CustomerName: "",
 		HotelId:      searchResp.HotelIds,
 		InDate:       inDate,
 		OutDate:      outDate,
 		RoomNumber:   1,
 	})
+	if tracing.DependencyTimedOut(ctx, err) {
+		// list the hotels found, whether they have rooms left or not
+		log.Warn().Msgf("SearchHandler skipping availability: %v", err)
+		degraded(w, "reservation")
+		reservationResp, err = &reservation.Result{HotelId: searchResp.HotelIds}, nil
+	}
 	if err != nil {
 		log.Error().Msg("SearchHandler CheckAvailability failed")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeGRPCError(w, err)
 		return
 	}
 
 	log.Trace().Msgf("searchHandler gets reserveResp")
 	log.Trace().Msgf("searchHandler gets reserveResp.HotelId = %s", reservationResp.HotelId)
 
-	// hotel profiles
-	profileResp, err := s.profileClient.GetProfiles(ctx, &profile.Request{
-		HotelIds: reservationResp.HotelId,
+	// CheckAvailability doesn't keep the ranking of the search, restore it
+	available := make(map[string]bool, len(reservationResp.HotelId))
+	for _, id := range reservationResp.HotelId {
+		available[id] = true
+	}
+	hotelIds := make([]string, 0, len(reservationResp.HotelId))
+	for _, id := range searchResp.HotelIds {
+		if available[id] {
+			hotelIds = append(hotelIds, id)
+		}
+	}
+
+	// hotel profiles, in the order of hotelIds
+	profileResp, err := s.ProfileClient.GetProfiles(ctx, &profile.Request{
+		HotelIds: hotelIds,
 		Locale:   locale,
 	})
 	if err != nil {
 		log.Error().Msg("SearchHandler GetProfiles failed")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeGRPCError(w, err)
 		return
 	}
 
@@ -306,20 +521,38 @@ func (s *Server) recommendHandler(w http.ResponseWriter, r *http.Request) {
 	Lon, _ := strconv.ParseFloat(sLon, 64)
 	lon := float64(Lon)
 
-	require := r.URL.Query().Get("require")
-	if require != "dis" && require != "rate" && require != "price" {
+	// strategy selects any registered scoring strategy and overrides require
+	require, strategy := r.URL.Query().Get("require"), r.URL.Query().Get("strategy")
+	if strategy == "" && require != "dis" && require != "rate" && require != "price" {
 		http.Error(w, "Please specify require params", http.StatusBadRequest)
 		return
 	}
 
+	// weights of the hybrid strategy, unset weights are 0
+	var weights [3]float64
+	for i, name := range []string{"distanceWeight", "priceWeight", "rateWeight"} {
+		if v := r.URL.Query().Get(name); v != "" {
+			weight, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				http.Error(w, "Please specify a numeric "+name, http.StatusBadRequest)
+				return
+			}
+			weights[i] = weight
+		}
+	}
+
 	// recommend hotels
-	recResp, err := s.recommendationClient.GetRecommendations(ctx, &recommendation.Request{
-		Require: require,
-		Lat:     float64(lat),
-		Lon:     float64(lon),
+	recResp, err := s.RecommendationClient.GetRecommendations(ctx, &recommendation.Request{
+		Require:        require,
+		Lat:            float64(lat),
+		Lon:            float64(lon),
+		Strategy:       strategy,
+		DistanceWeight: weights[0],
+		PriceWeight:    weights[1],
+		RateWeight:     weights[2],
 	})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeGRPCError(w, err)
 		return
 	}
 
@@ -330,12 +563,12 @@ func (s *Server) recommendHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// hotel profiles
-	profileResp, err := s.profileClient.GetProfiles(ctx, &profile.Request{
+	profileResp, err := s.ProfileClient.GetProfiles(ctx, &profile.Request{
 		HotelIds: recResp.HotelIds,
 		Locale:   locale,
 	})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeGRPCError(w, err)
 		return
 	}
 
@@ -353,12 +586,12 @@ func (s *Server) reviewHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check username and password
-	recResp, err := s.userClient.CheckUser(ctx, &user.Request{
+	recResp, err := s.UserClient.CheckUser(ctx, &user.Request{
 		Username: username,
 		Password: password,
 	})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeGRPCError(w, err)
 		return
 	}
 
@@ -375,18 +608,17 @@ func (s *Server) reviewHandler(w http.ResponseWriter, r *http.Request) {
 
 	revInput := review.Request{HotelId: hotelId}
 
-	revResp, err := s.reviewClient.GetReviews(ctx, &revInput)
+	revResp, err := s.ReviewClient.GetReviews(ctx, &revInput)
+	if err != nil {
+		writeGRPCError(w, err)
+		return
+	}
 
 	str = "Have reviews = " + strconv.Itoa(len(revResp.Reviews))
 	if len(revResp.Reviews) == 0 {
 		str = "Failed. No Reviews. "
 	}
 
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
 	res := map[string]interface{}{
 		"message": str,
 	}
@@ -405,12 +637,12 @@ func (s *Server) restaurantHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check username and password
-	recResp, err := s.userClient.CheckUser(ctx, &user.Request{
+	recResp, err := s.UserClient.CheckUser(ctx, &user.Request{
 		Username: username,
 		Password: password,
 	})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeGRPCError(w, err)
 		return
 	}
 
@@ -427,18 +659,17 @@ func (s *Server) restaurantHandler(w http.ResponseWriter, r *http.Request) {
 
 	revInput := attractions.Request{HotelId: hotelId}
 
-	revResp, err := s.attractionsClient.NearbyRest(ctx, &revInput)
+	revResp, err := s.AttractionsClient.NearbyRest(ctx, &revInput)
+	if err != nil {
+		writeGRPCError(w, err)
+		return
+	}
 
 	str = "Have restaurants = " + strconv.Itoa(len(revResp.AttractionIds))
 	if len(revResp.AttractionIds) == 0 {
 		str = "Failed. No Restaurants. "
 	}
 
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
 	res := map[string]interface{}{
 		"message": str,
 	}
@@ -457,12 +688,12 @@ func (s *Server) museumHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check username and password
-	recResp, err := s.userClient.CheckUser(ctx, &user.Request{
+	recResp, err := s.UserClient.CheckUser(ctx, &user.Request{
 		Username: username,
 		Password: password,
 	})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeGRPCError(w, err)
 		return
 	}
 
@@ -479,18 +710,17 @@ func (s *Server) museumHandler(w http.ResponseWriter, r *http.Request) {
 
 	revInput := attractions.Request{HotelId: hotelId}
 
-	revResp, err := s.attractionsClient.NearbyMus(ctx, &revInput)
+	revResp, err := s.AttractionsClient.NearbyMus(ctx, &revInput)
+	if err != nil {
+		writeGRPCError(w, err)
+		return
+	}
 
 	str = "Have museums = " + strconv.Itoa(len(revResp.AttractionIds))
 	if len(revResp.AttractionIds) == 0 {
 		str = "Failed. No Museums. "
 	}
 
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
 	res := map[string]interface{}{
 		"message": str,
 	}
@@ -509,12 +739,12 @@ func (s *Server) cinemaHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check username and password
-	recResp, err := s.userClient.CheckUser(ctx, &user.Request{
+	recResp, err := s.UserClient.CheckUser(ctx, &user.Request{
 		Username: username,
 		Password: password,
 	})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeGRPCError(w, err)
 		return
 	}
 
@@ -531,18 +761,17 @@ func (s *Server) cinemaHandler(w http.ResponseWriter, r *http.Request) {
 
 	revInput := attractions.Request{HotelId: hotelId}
 
-	revResp, err := s.attractionsClient.NearbyCinema(ctx, &revInput)
+	revResp, err := s.AttractionsClient.NearbyCinema(ctx, &revInput)
+	if err != nil {
+		writeGRPCError(w, err)
+		return
+	}
 
 	str = "Have cinemas = " + strconv.Itoa(len(revResp.AttractionIds))
 	if len(revResp.AttractionIds) == 0 {
 		str = "Failed. No Cinemas. "
 	}
 
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
 	res := map[string]interface{}{
 		"message": str,
 	}
@@ -561,12 +790,12 @@ func (s *Server) userHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check username and password
-	recResp, err := s.userClient.CheckUser(ctx, &user.Request{
+	recResp, err := s.UserClient.CheckUser(ctx, &user.Request{
 		Username: username,
 		Password: password,
 	})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeGRPCError(w, err)
 		return
 	}
 
@@ -621,13 +850,16 @@ func (s *Server) reservationHandler(w http.ResponseWriter, r *http.Request) {
 		numberOfRoom, _ = strconv.Atoi(num)
 	}
 
+	// the reservation service picks the default room type if unset
+	roomType := r.URL.Query().Get("roomType")
+
 	// Check username and password
-	recResp, err := s.userClient.CheckUser(ctx, &user.Request{
+	recResp, err := s.UserClient.CheckUser(ctx, &user.Request{
 		Username: username,
 		Password: password,
 	})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeGRPCError(w, err)
 		return
 	}
 
@@ -637,18 +869,20 @@ func (s *Server) reservationHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Make reservation
-	resResp, err := s.reservationClient.MakeReservation(ctx, &reservation.Request{
+	resResp, err := s.ReservationClient.MakeReservation(ctx, &reservation.Request{
 		CustomerName: customerName,
 		HotelId:      []string{hotelId},
 		InDate:       inDate,
 		OutDate:      outDate,
 		RoomNumber:   int32(numberOfRoom),
+		RoomType:     roomType,
 	})
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if status.Code(err) == codes.FailedPrecondition {
+		str = "Failed. Already reserved. "
+	} else if err != nil {
+		writeGRPCError(w, err)
 		return
-	}
-	if len(resResp.HotelId) == 0 {
+	} else if len(resResp.HotelId) == 0 {
 		str = "Failed. Already reserved. "
 	}
 
@@ -688,6 +922,45 @@ func geoJSONResponse(hs []*profile.Hotel) map[string]interface{} {
 	}
 }
 
+// sortFields maps the names of the sort keys of searches to their field.
+var sortFields = map[string]search.SortKey_Field{
+	"distance": search.SortKey_DISTANCE,
+	"price":    search.SortKey_PRICE,
+	"rating":   search.SortKey_RATING,
+	"reviews":  search.SortKey_REVIEWS,
+}
+
+// parseSortKeys parses a comma-separated list of sort key names, each
+// prefixed with "-" to sort by descending values, none if param is empty.
+func parseSortKeys(param string) ([]*search.SortKey, error) {
+	if param == "" {
+		return nil, nil
+	}
+	var keys []*search.SortKey
+	for _, name := range strings.Split(param, ",") {
+		key := &search.SortKey{}
+		if strings.HasPrefix(name, "-") {
+			name, key.Descending = name[1:], true
+		}
+		field, ok := sortFields[name]
+		if !ok {
+			return nil, fmt.Errorf("Please specify sort keys among distance, price, rating and reviews, got %q", name)
+		}
+		key.Field = field
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// degraded names dep in the X-Degraded header of the response, as a
+// backend the response was served without.
+func degraded(w http.ResponseWriter, dep string) {
+	if len(w.Header().Values("X-Degraded")) == 0 {
+		w.Header().Add("Access-Control-Expose-Headers", "X-Degraded")
+	}
+	w.Header().Add("X-Degraded", dep)
+}
+
 func checkDataFormat(date string) bool {
 	if len(date) != 10 {
 		return false
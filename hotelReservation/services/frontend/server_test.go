@@ -0,0 +1,238 @@
+package frontend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	attractions "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/attractions/proto"
+	profile "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/profile/proto"
+	reservation "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/reservation/proto"
+	review "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/review/proto"
+	search "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/search/proto"
+	user "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/user/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeReservation answers CheckAvailability with the result of check.
+type fakeReservation struct {
+	reservation.ReservationClient
+	check func(req *reservation.Request) (*reservation.Result, error)
+}
+
+func (f *fakeReservation) CheckAvailability(ctx context.Context, req *reservation.Request, opts ...grpc.CallOption) (*reservation.Result, error) {
+	return f.check(req)
+}
+
+// fakeProfile answers GetProfiles with a profile of every hotel requested,
+// and records the last request.
+type fakeProfile struct {
+	profile.ProfileClient
+	last *profile.Request
+}
+
+func (f *fakeProfile) GetProfiles(ctx context.Context, req *profile.Request, opts ...grpc.CallOption) (*profile.Result, error) {
+	f.last = req
+	res := &profile.Result{}
+	for _, id := range req.HotelIds {
+		res.Hotels = append(res.Hotels, &profile.Hotel{Id: id, Name: "Hotel " + id, Address: &profile.Address{}})
+	}
+	return res, nil
+}
+
+// getHotels gets /hotels from s, returning the response and the hotel ids
+// it lists.
+func getHotels(t *testing.T, s *Server) (*httptest.ResponseRecorder, []string) {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "/hotels?inDate=2015-04-09&outDate=2015-04-10&lat=37.7867&lon=-122.4112", nil)
+	w := httptest.NewRecorder()
+	s.searchHandler(w, r)
+	if w.Code != http.StatusOK {
+		return w, nil
+	}
+	var res struct {
+		Features []struct {
+			Id string `json:"id"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("decoding %s: %v", w.Body, err)
+	}
+	ids := make([]string, len(res.Features))
+	for i, f := range res.Features {
+		ids[i] = f.Id
+	}
+	return w, ids
+}
+
+func TestSearchKeepsRanking(t *testing.T) {
+	profiles := &fakeProfile{}
+	s := &Server{
+		SearchClient: &fakeSearch{nearby: func(req *search.NearbyRequest) (*search.SearchResult, error) {
+			return &search.SearchResult{HotelIds: []string{"3", "1", "4", "2"}}, nil
+		}},
+		// available in no particular order, hotel 4 full
+		ReservationClient: &fakeReservation{check: func(req *reservation.Request) (*reservation.Result, error) {
+			return &reservation.Result{HotelId: []string{"1", "2", "3"}}, nil
+		}},
+		ProfileClient: profiles,
+	}
+
+	w, ids := getHotels(t, s)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", w.Code, w.Body)
+	}
+	if fmt.Sprint(profiles.last.HotelIds) != "[3 1 2]" {
+		t.Errorf("got profiles of %v, want of the available hotels as ranked, [3 1 2]", profiles.last.HotelIds)
+	}
+	if fmt.Sprint(ids) != "[3 1 2]" {
+		t.Errorf("listed hotels %v, want [3 1 2]", ids)
+	}
+}
+
+func TestSearchDegraded(t *testing.T) {
+	tests := []struct {
+		name         string
+		ratesOmitted bool
+		checkErr     error
+		want         string
+		wantCode     int
+	}{
+		{"complete", false, nil, "[]", http.StatusOK},
+		{"without rates", true, nil, "[rate]", http.StatusOK},
+		{"availability timed out", false, status.Error(codes.DeadlineExceeded, "reservation timed out"), "[reservation]", http.StatusOK},
+		{"both", true, status.Error(codes.DeadlineExceeded, "reservation timed out"), "[rate reservation]", http.StatusOK},
+		{"availability failed", false, status.Error(codes.Unavailable, "down"), "[]", http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		s := &Server{
+			SearchClient: &fakeSearch{nearby: func(req *search.NearbyRequest) (*search.SearchResult, error) {
+				return &search.SearchResult{HotelIds: []string{"1", "2"}, RatesOmitted: tt.ratesOmitted}, nil
+			}},
+			ReservationClient: &fakeReservation{check: func(req *reservation.Request) (*reservation.Result, error) {
+				if tt.checkErr != nil {
+					return nil, tt.checkErr
+				}
+				return &reservation.Result{HotelId: []string{"2"}}, nil
+			}},
+			ProfileClient: &fakeProfile{},
+		}
+
+		w, ids := getHotels(t, s)
+		if w.Code != tt.wantCode {
+			t.Errorf("%s: got status %d, want %d: %s", tt.name, w.Code, tt.wantCode, w.Body)
+			continue
+		}
+		if got := fmt.Sprint(w.Header().Values("X-Degraded")); got != tt.want {
+			t.Errorf("%s: degraded %s, want %s", tt.name, got, tt.want)
+		}
+		// without availability, every hotel found is listed
+		want := "[2]"
+		if status.Code(tt.checkErr) == codes.DeadlineExceeded {
+			want = "[1 2]"
+		}
+		if tt.wantCode == http.StatusOK && fmt.Sprint(ids) != want {
+			t.Errorf("%s: listed %v, want %s", tt.name, ids, want)
+		}
+	}
+}
+
+// fakeUser accepts any user.
+type fakeUser struct {
+	user.UserClient
+}
+
+func (fakeUser) CheckUser(ctx context.Context, req *user.Request, opts ...grpc.CallOption) (*user.Result, error) {
+	return &user.Result{Correct: true}, nil
+}
+
+// fakeReview answers GetReviews with reviews, or fails with err.
+type fakeReview struct {
+	review.ReviewClient
+	reviews []*review.ReviewComm
+	err     error
+}
+
+func (f *fakeReview) GetReviews(ctx context.Context, req *review.Request, opts ...grpc.CallOption) (*review.Result, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &review.Result{Reviews: f.reviews}, nil
+}
+
+// fakeAttractions answers every kind of attraction with ids, or fails with
+// err.
+type fakeAttractions struct {
+	attractions.AttractionsClient
+	ids []string
+	err error
+}
+
+func (f *fakeAttractions) nearby() (*attractions.Result, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &attractions.Result{AttractionIds: f.ids}, nil
+}
+
+func (f *fakeAttractions) NearbyRest(ctx context.Context, req *attractions.Request, opts ...grpc.CallOption) (*attractions.Result, error) {
+	return f.nearby()
+}
+
+func (f *fakeAttractions) NearbyMus(ctx context.Context, req *attractions.Request, opts ...grpc.CallOption) (*attractions.Result, error) {
+	return f.nearby()
+}
+
+func (f *fakeAttractions) NearbyCinema(ctx context.Context, req *attractions.Request, opts ...grpc.CallOption) (*attractions.Result, error) {
+	return f.nearby()
+}
+
+// getInfo gets path with handler of s, for hotel 1 as a valid user.
+func getInfo(s *Server, handler func(*Server, http.ResponseWriter, *http.Request), path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler(s, w, httptest.NewRequest(http.MethodGet, path+"?username=Cornell_1&password=1111111111&hotelId=1", nil))
+	return w
+}
+
+func TestHotelInfoHandlers(t *testing.T) {
+	tests := []struct {
+		path    string
+		handler func(*Server, http.ResponseWriter, *http.Request)
+		want    string
+	}{
+		{"/review", (*Server).reviewHandler, "Have reviews = 2"},
+		{"/restaurants", (*Server).restaurantHandler, "Have restaurants = 2"},
+		{"/museums", (*Server).museumHandler, "Have museums = 2"},
+		{"/cinema", (*Server).cinemaHandler, "Have cinemas = 2"},
+	}
+	for _, tt := range tests {
+		s := &Server{
+			UserClient:        fakeUser{},
+			ReviewClient:      &fakeReview{reviews: []*review.ReviewComm{{ReviewId: "1"}, {ReviewId: "2"}}},
+			AttractionsClient: &fakeAttractions{ids: []string{"1", "2"}},
+		}
+		w := getInfo(s, tt.handler, tt.path)
+		var res struct {
+			Message string `json:"message"`
+		}
+		if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &res) != nil || res.Message != tt.want {
+			t.Errorf("%s: got status %d: %s, want %q", tt.path, w.Code, w.Body, tt.want)
+		}
+
+		// a failing backend fails the request with its status, not a panic
+		down := status.Error(codes.Unavailable, "down")
+		s = &Server{
+			UserClient:        fakeUser{},
+			ReviewClient:      &fakeReview{err: down},
+			AttractionsClient: &fakeAttractions{err: down},
+		}
+		if w := getInfo(s, tt.handler, tt.path); w.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s with the backend down: got status %d, want %d: %s", tt.path, w.Code, http.StatusServiceUnavailable, w.Body)
+		}
+	}
+}
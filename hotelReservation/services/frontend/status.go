@@ -0,0 +1,95 @@
+package frontend
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// httpStatusFromCode maps a gRPC status code to the conventional HTTP
+// status, as grpc-gateway does.
+func httpStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499 // client closed request
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.FailedPrecondition:
+		return http.StatusPreconditionFailed
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// httpStatusFromGRPC returns the HTTP status for an error returned by a gRPC
+// client. Errors without a gRPC status, or with an OK one, map to 500.
+func httpStatusFromGRPC(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	code := status.Code(err)
+	if code == codes.OK {
+		return http.StatusInternalServerError
+	}
+	return httpStatusFromCode(code)
+}
+
+// errorBody is the JSON body of errors carrying ErrorDetails.
+type errorBody struct {
+	Code    string         `json:"code"`
+	Message string         `json:"message"`
+	Details []errorDetails `json:"details,omitempty"`
+}
+
+type errorDetails struct {
+	Reason      string `json:"reason"`
+	Field       string `json:"field,omitempty"`
+	Description string `json:"description"`
+}
+
+// writeGRPCError replies to the request with the gRPC error err and its HTTP
+// status. Errors carrying ErrorDetails are written as a JSON errorBody, the
+// others as their plain-text message.
+func writeGRPCError(w http.ResponseWriter, err error) {
+	code := httpStatusFromGRPC(err)
+	st := status.Convert(err)
+	details := errdetails.FromError(err)
+	if len(details) == 0 {
+		http.Error(w, st.Message(), code)
+		return
+	}
+
+	body := errorBody{Code: st.Code().String(), Message: st.Message()}
+	for _, d := range details {
+		body.Details = append(body.Details, errorDetails{
+			Reason:      d.Reason.String(),
+			Field:       d.Field,
+			Description: d.Description,
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
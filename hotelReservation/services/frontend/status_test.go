@@ -0,0 +1,88 @@
+package frontend
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails"
+	errpb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// okError is an error carrying an OK status.
+type okError struct{}
+
+func (okError) Error() string              { return "ok" }
+func (okError) GRPCStatus() *status.Status { return status.New(codes.OK, "") }
+
+func TestHTTPStatusFromGRPC(t *testing.T) {
+	tests := []struct {
+		code codes.Code
+		want int
+	}{
+		{codes.Canceled, 499},
+		{codes.Unknown, http.StatusInternalServerError},
+		{codes.InvalidArgument, http.StatusBadRequest},
+		{codes.DeadlineExceeded, http.StatusGatewayTimeout},
+		{codes.NotFound, http.StatusNotFound},
+		{codes.AlreadyExists, http.StatusConflict},
+		{codes.PermissionDenied, http.StatusForbidden},
+		{codes.ResourceExhausted, http.StatusTooManyRequests},
+		{codes.FailedPrecondition, http.StatusPreconditionFailed},
+		{codes.Aborted, http.StatusConflict},
+		{codes.OutOfRange, http.StatusBadRequest},
+		{codes.Unimplemented, http.StatusNotImplemented},
+		{codes.Internal, http.StatusInternalServerError},
+		{codes.Unavailable, http.StatusServiceUnavailable},
+		{codes.DataLoss, http.StatusInternalServerError},
+		{codes.Unauthenticated, http.StatusUnauthorized},
+		{codes.Code(100), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := httpStatusFromGRPC(status.Error(tt.code, "failed")); got != tt.want {
+			t.Errorf("httpStatusFromGRPC(%v) = %d, want %d", tt.code, got, tt.want)
+		}
+	}
+
+	if got := httpStatusFromGRPC(nil); got != http.StatusOK {
+		t.Errorf("httpStatusFromGRPC(nil) = %d, want 200", got)
+	}
+	if got := httpStatusFromGRPC(errors.New("not a status")); got != http.StatusInternalServerError {
+		t.Errorf("httpStatusFromGRPC of a plain error = %d, want 500", got)
+	}
+	if got := httpStatusFromGRPC(okError{}); got != http.StatusInternalServerError {
+		t.Errorf("httpStatusFromGRPC of an OK error = %d, want 500", got)
+	}
+}
+
+func TestWriteGRPCError(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeGRPCError(w, status.Error(codes.NotFound, "hotel 1 not found"))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("got status %d, want 404", w.Code)
+	}
+	if got := strings.TrimSpace(w.Body.String()); got != "hotel 1 not found" {
+		t.Errorf("got body %q, want the message", got)
+	}
+}
+
+func TestWriteGRPCErrorDetails(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeGRPCError(w, errdetails.InvalidArgument(errpb.ErrorDetail_MALFORMED, "inDate", "invalid inDate %q", "tomorrow"))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("got status %d, want 400", w.Code)
+	}
+
+	var body errorBody
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding %s: %v", w.Body, err)
+	}
+	if body.Code != "InvalidArgument" || len(body.Details) != 1 || body.Details[0].Field != "inDate" || body.Details[0].Reason != "MALFORMED" {
+		t.Errorf("got body %s, want the InvalidArgument details of inDate", w.Body)
+	}
+}
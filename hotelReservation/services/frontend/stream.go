@@ -0,0 +1,91 @@
+package frontend
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	search "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/search/proto"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/net/websocket"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// hotelsStreamHandler serves the search StreamHotels RPC over a WebSocket.
+// Clients send the JSON form of a NearbyRequest as their first message and
+// get back one text message per hotel, the JSON form of its HotelSummary, as
+// the hotels arrive. The socket is closed once all hotels were sent, after
+// a last message with the JSON form of the gRPC status if the search failed.
+// The search is cancelled if the client closes the socket or goes away.
+//
+// Like the other handlers, any origin is allowed.
+func (s *Server) hotelsStreamHandler(w http.ResponseWriter, r *http.Request) {
+	websocket.Server{Handler: s.streamHotels}.ServeHTTP(w, r)
+}
+
+func (s *Server) streamHotels(ws *websocket.Conn) {
+	defer ws.Close()
+	ctx, cancel := context.WithCancel(ws.Request().Context())
+	defer cancel()
+
+	ws.MaxPayloadBytes = maxGatewayBody
+	var body string
+	if err := websocket.Message.Receive(ws, &body); err != nil {
+		log.Debug().Msgf("streamHotels: failed to read request: %v", err)
+		return
+	}
+	req := new(search.NearbyRequest)
+	if err := protojson.Unmarshal([]byte(body), req); err != nil {
+		sendStreamError(ws, status.Newf(codes.InvalidArgument, "malformed request: %v", err))
+		return
+	}
+
+	// The client has nothing more to send: the next read only returns once
+	// it closes the socket or goes away.
+	go func() {
+		var discard string
+		for websocket.Message.Receive(ws, &discard) == nil {
+		}
+		cancel()
+	}()
+
+	stream, err := s.SearchClient.StreamHotels(ctx, req)
+	if err != nil {
+		log.Error().Msgf("streamHotels StreamHotels failed: %v", err)
+		sendStreamError(ws, status.Convert(err))
+		return
+	}
+	for {
+		hotel, err := stream.Recv()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Error().Msgf("streamHotels StreamHotels failed: %v", err)
+				sendStreamError(ws, status.Convert(err))
+			}
+			return
+		}
+		frame, err := protojson.Marshal(hotel)
+		if err != nil {
+			sendStreamError(ws, status.Newf(codes.Internal, "failed to encode hotel: %v", err))
+			return
+		}
+		if err := websocket.Message.Send(ws, string(frame)); err != nil {
+			log.Debug().Msgf("streamHotels: client went away: %v", err)
+			return
+		}
+	}
+}
+
+// sendStreamError sends st to the client of ws as its last message.
+func sendStreamError(ws *websocket.Conn, st *status.Status) {
+	frame, err := protojson.Marshal(st.Proto())
+	if err != nil {
+		frame = []byte(`{"code":13,"message":"failed to encode status"}`)
+	}
+	websocket.Message.Send(ws, string(frame))
+}
@@ -0,0 +1,189 @@
+package frontend
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	search "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/search/proto"
+	"golang.org/x/net/websocket"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// streamingSearch streams the hotels sent on hotels, then fails with err
+// if set once hotels is closed. cancelled is closed if the caller cancels
+// the stream.
+type streamingSearch struct {
+	search.UnimplementedSearchServer
+	hotels    chan *search.HotelSummary
+	err       error
+	last      chan *search.NearbyRequest
+	cancelled chan struct{}
+}
+
+func newStreamingSearch() *streamingSearch {
+	return &streamingSearch{
+		hotels:    make(chan *search.HotelSummary),
+		last:      make(chan *search.NearbyRequest, 1),
+		cancelled: make(chan struct{}),
+	}
+}
+
+func (f *streamingSearch) StreamHotels(req *search.NearbyRequest, stream search.Search_StreamHotelsServer) error {
+	f.last <- req
+	for {
+		select {
+		case <-stream.Context().Done():
+			close(f.cancelled)
+			return stream.Context().Err()
+		case hotel, ok := <-f.hotels:
+			if !ok {
+				return f.err
+			}
+			if err := stream.Send(hotel); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// dialHotelsStream serves backend as the search service of a frontend for
+// the duration of t and returns a WebSocket connected to the hotels stream
+// of the frontend.
+func dialHotelsStream(t *testing.T, backend search.SearchServer) *websocket.Conn {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srv := grpc.NewServer()
+	search.RegisterSearchServer(srv, backend)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	s := &Server{SearchClient: search.NewSearchClient(conn)}
+	front := httptest.NewServer(http.HandlerFunc(s.hotelsStreamHandler))
+	t.Cleanup(front.Close)
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(front.URL, "http"), "", front.URL)
+	if err != nil {
+		t.Fatalf("websocket dial: %v", err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	return ws
+}
+
+// receiveFrame returns the next message of ws, failing t if there is none
+// within a second.
+func receiveFrame(t *testing.T, ws *websocket.Conn) (string, error) {
+	t.Helper()
+	ws.SetReadDeadline(time.Now().Add(time.Second))
+	var frame string
+	err := websocket.Message.Receive(ws, &frame)
+	return frame, err
+}
+
+func TestStreamHotels(t *testing.T) {
+	backend := newStreamingSearch()
+	ws := dialHotelsStream(t, backend)
+	if err := websocket.Message.Send(ws, `{"lat": 37.7867, "lon": -122.4112, "inDate": "2015-04-09", "outDate": "2015-04-10"}`); err != nil {
+		t.Fatalf("sending request: %v", err)
+	}
+	if req := <-backend.last; req.Lat != 37.7867 || req.InDate != "2015-04-09" {
+		t.Errorf("searched with %v, want the request sent", req)
+	}
+
+	// every hotel is forwarded as it arrives, before the next is found
+	for _, id := range []string{"1", "2"} {
+		backend.hotels <- &search.HotelSummary{Id: id, Name: "Hotel " + id}
+		frame, err := receiveFrame(t, ws)
+		if err != nil {
+			t.Fatalf("receiving hotel %s: %v", id, err)
+		}
+		var hotel struct{ Id, Name string }
+		if err := json.Unmarshal([]byte(frame), &hotel); err != nil {
+			t.Fatalf("decoding %s: %v", frame, err)
+		}
+		if hotel.Id != id || hotel.Name != "Hotel "+id {
+			t.Errorf("got frame %s, want hotel %s", frame, id)
+		}
+	}
+	close(backend.hotels)
+	if frame, err := receiveFrame(t, ws); err != io.EOF {
+		t.Errorf("search done: got frame %q, error %v, want the socket closed", frame, err)
+	}
+}
+
+// statusFrame decodes the status of frame.
+func statusFrame(t *testing.T, frame string) codes.Code {
+	t.Helper()
+	var st struct{ Code codes.Code }
+	if err := json.Unmarshal([]byte(frame), &st); err != nil {
+		t.Fatalf("decoding %s: %v", frame, err)
+	}
+	return st.Code
+}
+
+func TestStreamHotelsFailing(t *testing.T) {
+	backend := newStreamingSearch()
+	backend.err = status.Error(codes.Unavailable, "geo down")
+	ws := dialHotelsStream(t, backend)
+	websocket.Message.Send(ws, `{"lat": 37.7867, "lon": -122.4112}`)
+	backend.hotels <- &search.HotelSummary{Id: "1"}
+	close(backend.hotels)
+
+	if _, err := receiveFrame(t, ws); err != nil {
+		t.Fatalf("receiving hotel: %v", err)
+	}
+	frame, err := receiveFrame(t, ws)
+	if err != nil {
+		t.Fatalf("receiving status: %v", err)
+	}
+	if code := statusFrame(t, frame); code != codes.Unavailable || !strings.Contains(frame, "geo down") {
+		t.Errorf("got last frame %s, want the Unavailable status", frame)
+	}
+	if _, err := receiveFrame(t, ws); err != io.EOF {
+		t.Errorf("after the status: got error %v, want the socket closed", err)
+	}
+}
+
+func TestStreamHotelsMalformedRequest(t *testing.T) {
+	ws := dialHotelsStream(t, newStreamingSearch())
+	websocket.Message.Send(ws, `{"lat": "north"}`)
+	frame, err := receiveFrame(t, ws)
+	if err != nil {
+		t.Fatalf("receiving status: %v", err)
+	}
+	if code := statusFrame(t, frame); code != codes.InvalidArgument {
+		t.Errorf("got frame %s, want InvalidArgument", frame)
+	}
+}
+
+func TestStreamHotelsClientGone(t *testing.T) {
+	backend := newStreamingSearch()
+	ws := dialHotelsStream(t, backend)
+	websocket.Message.Send(ws, `{"lat": 37.7867, "lon": -122.4112}`)
+	backend.hotels <- &search.HotelSummary{Id: "1"}
+	if _, err := receiveFrame(t, ws); err != nil {
+		t.Fatalf("receiving hotel: %v", err)
+	}
+
+	ws.Close()
+	select {
+	case <-backend.cancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("search not cancelled when the client went away")
+	}
+}
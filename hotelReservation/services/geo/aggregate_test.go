@@ -0,0 +1,132 @@
+package geo
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/geo/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// clusteredHotels are 6 hotels in 3 cells of 0.1 degree.
+var clusteredHotels = []*point{
+	{Pid: "a1", Plat: 37.71, Plon: -122.41},
+	{Pid: "a2", Plat: 37.75, Plon: -122.45},
+	{Pid: "a3", Plat: 37.79, Plon: -122.49},
+	{Pid: "b1", Plat: 37.85, Plon: -122.25},
+	{Pid: "b2", Plat: 37.86, Plon: -122.26},
+	{Pid: "c1", Plat: 37.65, Plon: -122.35},
+}
+
+// aggregate aggregates the hotels of s by cells of size degrees, as served.
+func aggregate(s *Server, latSize, lonSize float32) (*pb.CellResult, error) {
+	validate := tracing.ValidationUnaryServerInterceptor(validators)
+	res, err := validate(context.Background(), &pb.CellRequest{LatCellSize: latSize, LonCellSize: lonSize},
+		&grpc.UnaryServerInfo{FullMethod: pb.Geo_AggregateByCell_FullMethodName},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return s.AggregateByCell(ctx, req.(*pb.CellRequest))
+		})
+	if err != nil {
+		return nil, err
+	}
+	return res.(*pb.CellResult), nil
+}
+
+// cellCounts returns the cells of res as "row,col:hotels".
+func cellCounts(res *pb.CellResult) []string {
+	var cells []string
+	for _, c := range res.Cells {
+		cells = append(cells, fmt.Sprintf("%d,%d:%d", c.Row, c.Col, c.Hotels))
+	}
+	return cells
+}
+
+func TestAggregateByCell(t *testing.T) {
+	s := newTestServer(t, clusteredHotels...)
+
+	res, err := aggregate(s, 0.1, 0.1)
+	if err != nil {
+		t.Fatalf("AggregateByCell: %v", err)
+	}
+	want := []string{"376,-1224:1", "377,-1225:3", "378,-1223:2"}
+	if got := cellCounts(res); !equalOrder(got, want) {
+		t.Fatalf("got cells %v, want %v", got, want)
+	}
+	if c := res.Cells[1]; math.Abs(float64(c.MinLat)-37.7) > 1e-4 || math.Abs(float64(c.MinLon)+122.5) > 1e-4 {
+		t.Errorf("got cell %v, want its corner at 37.7, -122.5", c)
+	}
+
+	// other sizes for latitudes and longitudes
+	res, err = aggregate(s, 1, 0.2)
+	if err != nil {
+		t.Fatalf("AggregateByCell: %v", err)
+	}
+	if got, want := cellCounts(res), []string{"37,-613:3", "37,-612:3"}; !equalOrder(got, want) {
+		t.Errorf("got cells %v, want %v", got, want)
+	}
+}
+
+func TestAggregateByCellDeterministic(t *testing.T) {
+	reversed := make([]*point, len(clusteredHotels))
+	for i, p := range clusteredHotels {
+		reversed[len(reversed)-1-i] = p
+	}
+	first, err := aggregate(newTestServer(t, clusteredHotels...), 0.1, 0.1)
+	if err != nil {
+		t.Fatalf("AggregateByCell: %v", err)
+	}
+	s := newTestServer(t, reversed...)
+	for i := 0; i < 5; i++ {
+		res, err := aggregate(s, 0.1, 0.1)
+		if err != nil {
+			t.Fatalf("AggregateByCell: %v", err)
+		}
+		if !proto.Equal(res, first) {
+			t.Fatalf("got %v, then %v", first, res)
+		}
+	}
+}
+
+func TestAggregateByCellEmpty(t *testing.T) {
+	res, err := aggregate(newTestServer(t), 0.1, 0.1)
+	if err != nil || len(res.Cells) != 0 {
+		t.Errorf("got %v, %v, want no cells", res, err)
+	}
+}
+
+func TestAggregateByCellInvalidSize(t *testing.T) {
+	s := newTestServer(t, clusteredHotels...)
+	for _, size := range [][2]float32{{0, 0.1}, {0.1, 0}, {-0.1, 0.1}, {0.1, -1}, {1e-5, 0.1}, {0.1, 1e-5}} {
+		if _, err := aggregate(s, size[0], size[1]); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("cells of %v: got %v, want InvalidArgument", size, err)
+		}
+	}
+}
+
+func TestAggregateByCellTooManyCells(t *testing.T) {
+	s := newTestServer(t, append([]*point{{Pid: "sydney", Plat: -33.87, Plon: 151.21}}, clusteredHotels...)...)
+
+	// a grid of about 700 by 2700 cells
+	_, err := aggregate(s, 0.1, 0.1)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("fine cells over the globe: got %v, want InvalidArgument", err)
+	}
+	if msg := status.Convert(err).Message(); !strings.Contains(msg, "over the limit of 10000 cells") {
+		t.Errorf("got %q, want the limit in the error", msg)
+	}
+
+	res, err := aggregate(s, 10, 10)
+	if err != nil {
+		t.Fatalf("AggregateByCell: %v", err)
+	}
+	if got, want := cellCounts(res), []string{"-4,15:1", "3,-13:6"}; !equalOrder(got, want) {
+		t.Errorf("got cells %v, want %v", got, want)
+	}
+}
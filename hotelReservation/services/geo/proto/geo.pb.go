@@ -20,33 +20,522 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// The unit of the distances in requests and results.
+type Unit int32
+
+const (
+	Unit_KM    Unit = 0
+	Unit_MILES Unit = 1
+)
+
+// Enum value maps for Unit.
+var (
+	Unit_name = map[int32]string{
+		0: "KM",
+		1: "MILES",
+	}
+	Unit_value = map[string]int32{
+		"KM":    0,
+		"MILES": 1,
+	}
+)
+
+func (x Unit) Enum() *Unit {
+	p := new(Unit)
+	*p = x
+	return p
+}
+
+func (x Unit) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Unit) Descriptor() protoreflect.EnumDescriptor {
+	return file_services_geo_proto_geo_proto_enumTypes[0].Descriptor()
+}
+
+func (Unit) Type() protoreflect.EnumType {
+	return &file_services_geo_proto_geo_proto_enumTypes[0]
+}
+
+func (x Unit) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Unit.Descriptor instead.
+func (Unit) EnumDescriptor() ([]byte, []int) {
+	return file_services_geo_proto_geo_proto_rawDescGZIP(), []int{0}
+}
+
 // The latitude and longitude of the current location.
 type Request struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Lat float32 `protobuf:"fixed32,1,opt,name=lat,proto3" json:"lat,omitempty"`
-	Lon float32 `protobuf:"fixed32,2,opt,name=lon,proto3" json:"lon,omitempty"`
+	Lat float32 `protobuf:"fixed32,1,opt,name=lat,proto3" json:"lat,omitempty"`
+	Lon float32 `protobuf:"fixed32,2,opt,name=lon,proto3" json:"lon,omitempty"`
+	// Unit of the distances in the result, KM if unset.
+	Unit Unit `protobuf:"varint,3,opt,name=unit,proto3,enum=geo.Unit" json:"unit,omitempty"`
+}
+
+func (x *Request) Reset() {
+	*x = Request{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_services_geo_proto_geo_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Request) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Request) ProtoMessage() {}
+
+func (x *Request) ProtoReflect() protoreflect.Message {
+	mi := &file_services_geo_proto_geo_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Request.ProtoReflect.Descriptor instead.
+func (*Request) Descriptor() ([]byte, []int) {
+	return file_services_geo_proto_geo_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Request) GetLat() float32 {
+	if x != nil {
+		return x.Lat
+	}
+	return 0
+}
+
+func (x *Request) GetLon() float32 {
+	if x != nil {
+		return x.Lon
+	}
+	return 0
+}
+
+func (x *Request) GetUnit() Unit {
+	if x != nil {
+		return x.Unit
+	}
+	return Unit_KM
+}
+
+// The latitude and longitude of the current location, and the region tag
+// of the hotels to find.
+type RegionRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Lat    float32 `protobuf:"fixed32,1,opt,name=lat,proto3" json:"lat,omitempty"`
+	Lon    float32 `protobuf:"fixed32,2,opt,name=lon,proto3" json:"lon,omitempty"`
+	Region string  `protobuf:"bytes,3,opt,name=region,proto3" json:"region,omitempty"`
+	// Unit of the distances in the result, KM if unset.
+	Unit Unit `protobuf:"varint,4,opt,name=unit,proto3,enum=geo.Unit" json:"unit,omitempty"`
+}
+
+func (x *RegionRequest) Reset() {
+	*x = RegionRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_services_geo_proto_geo_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RegionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RegionRequest) ProtoMessage() {}
+
+func (x *RegionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_services_geo_proto_geo_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RegionRequest.ProtoReflect.Descriptor instead.
+func (*RegionRequest) Descriptor() ([]byte, []int) {
+	return file_services_geo_proto_geo_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *RegionRequest) GetLat() float32 {
+	if x != nil {
+		return x.Lat
+	}
+	return 0
+}
+
+func (x *RegionRequest) GetLon() float32 {
+	if x != nil {
+		return x.Lon
+	}
+	return 0
+}
+
+func (x *RegionRequest) GetRegion() string {
+	if x != nil {
+		return x.Region
+	}
+	return ""
+}
+
+func (x *RegionRequest) GetUnit() Unit {
+	if x != nil {
+		return x.Unit
+	}
+	return Unit_KM
+}
+
+// The corners of a lat/lon bounding box. minLon may be greater than maxLon
+// for a box crossing the antimeridian.
+type BoxRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	MinLat float32 `protobuf:"fixed32,1,opt,name=minLat,proto3" json:"minLat,omitempty"`
+	MinLon float32 `protobuf:"fixed32,2,opt,name=minLon,proto3" json:"minLon,omitempty"`
+	MaxLat float32 `protobuf:"fixed32,3,opt,name=maxLat,proto3" json:"maxLat,omitempty"`
+	MaxLon float32 `protobuf:"fixed32,4,opt,name=maxLon,proto3" json:"maxLon,omitempty"`
+}
+
+func (x *BoxRequest) Reset() {
+	*x = BoxRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_services_geo_proto_geo_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BoxRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BoxRequest) ProtoMessage() {}
+
+func (x *BoxRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_services_geo_proto_geo_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BoxRequest.ProtoReflect.Descriptor instead.
+func (*BoxRequest) Descriptor() ([]byte, []int) {
+	return file_services_geo_proto_geo_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *BoxRequest) GetMinLat() float32 {
+	if x != nil {
+		return x.MinLat
+	}
+	return 0
+}
+
+func (x *BoxRequest) GetMinLon() float32 {
+	if x != nil {
+		return x.MinLon
+	}
+	return 0
+}
+
+func (x *BoxRequest) GetMaxLat() float32 {
+	if x != nil {
+		return x.MaxLat
+	}
+	return 0
+}
+
+func (x *BoxRequest) GetMaxLon() float32 {
+	if x != nil {
+		return x.MaxLon
+	}
+	return 0
+}
+
+type Result struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	HotelIds []string `protobuf:"bytes,1,rep,name=hotelIds,proto3" json:"hotelIds,omitempty"`
+	// Distance of each hotel from the requested lat/lon, in unit. Set by
+	// Nearby and NearbyInRegion only, a box having no center to measure from.
+	Distances []float32 `protobuf:"fixed32,2,rep,packed,name=distances,proto3" json:"distances,omitempty"`
+	Unit      Unit      `protobuf:"varint,3,opt,name=unit,proto3,enum=geo.Unit" json:"unit,omitempty"`
+}
+
+func (x *Result) Reset() {
+	*x = Result{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_services_geo_proto_geo_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Result) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Result) ProtoMessage() {}
+
+func (x *Result) ProtoReflect() protoreflect.Message {
+	mi := &file_services_geo_proto_geo_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Result.ProtoReflect.Descriptor instead.
+func (*Result) Descriptor() ([]byte, []int) {
+	return file_services_geo_proto_geo_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Result) GetHotelIds() []string {
+	if x != nil {
+		return x.HotelIds
+	}
+	return nil
+}
+
+func (x *Result) GetDistances() []float32 {
+	if x != nil {
+		return x.Distances
+	}
+	return nil
+}
+
+func (x *Result) GetUnit() Unit {
+	if x != nil {
+		return x.Unit
+	}
+	return Unit_KM
+}
+
+type NearestRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Lat float32 `protobuf:"fixed32,1,opt,name=lat,proto3" json:"lat,omitempty"`
+	Lon float32 `protobuf:"fixed32,2,opt,name=lon,proto3" json:"lon,omitempty"`
+	K   int32   `protobuf:"varint,3,opt,name=k,proto3" json:"k,omitempty"`
+	// Unit of the distances in the result, KM if unset.
+	Unit Unit `protobuf:"varint,4,opt,name=unit,proto3,enum=geo.Unit" json:"unit,omitempty"`
+}
+
+func (x *NearestRequest) Reset() {
+	*x = NearestRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_services_geo_proto_geo_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *NearestRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NearestRequest) ProtoMessage() {}
+
+func (x *NearestRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_services_geo_proto_geo_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NearestRequest.ProtoReflect.Descriptor instead.
+func (*NearestRequest) Descriptor() ([]byte, []int) {
+	return file_services_geo_proto_geo_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *NearestRequest) GetLat() float32 {
+	if x != nil {
+		return x.Lat
+	}
+	return 0
+}
+
+func (x *NearestRequest) GetLon() float32 {
+	if x != nil {
+		return x.Lon
+	}
+	return 0
+}
+
+func (x *NearestRequest) GetK() int32 {
+	if x != nil {
+		return x.K
+	}
+	return 0
+}
+
+func (x *NearestRequest) GetUnit() Unit {
+	if x != nil {
+		return x.Unit
+	}
+	return Unit_KM
+}
+
+// The hotels sorted by ascending distance, ties broken by hotel ID.
+type NearestResult struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Neighbors []*NearestResult_Neighbor `protobuf:"bytes,1,rep,name=neighbors,proto3" json:"neighbors,omitempty"`
+	Unit      Unit                      `protobuf:"varint,2,opt,name=unit,proto3,enum=geo.Unit" json:"unit,omitempty"`
+}
+
+func (x *NearestResult) Reset() {
+	*x = NearestResult{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_services_geo_proto_geo_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *NearestResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NearestResult) ProtoMessage() {}
+
+func (x *NearestResult) ProtoReflect() protoreflect.Message {
+	mi := &file_services_geo_proto_geo_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NearestResult.ProtoReflect.Descriptor instead.
+func (*NearestResult) Descriptor() ([]byte, []int) {
+	return file_services_geo_proto_geo_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *NearestResult) GetNeighbors() []*NearestResult_Neighbor {
+	if x != nil {
+		return x.Neighbors
+	}
+	return nil
+}
+
+func (x *NearestResult) GetUnit() Unit {
+	if x != nil {
+		return x.Unit
+	}
+	return Unit_KM
+}
+
+type ReloadRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *ReloadRequest) Reset() {
+	*x = ReloadRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_services_geo_proto_geo_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ReloadRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReloadRequest) ProtoMessage() {}
+
+func (x *ReloadRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_services_geo_proto_geo_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReloadRequest.ProtoReflect.Descriptor instead.
+func (*ReloadRequest) Descriptor() ([]byte, []int) {
+	return file_services_geo_proto_geo_proto_rawDescGZIP(), []int{6}
+}
+
+type ReloadResult struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Number of hotels in the rebuilt index.
+	Hotels int32 `protobuf:"varint,1,opt,name=hotels,proto3" json:"hotels,omitempty"`
 }
 
-func (x *Request) Reset() {
-	*x = Request{}
+func (x *ReloadResult) Reset() {
+	*x = ReloadResult{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_services_geo_proto_geo_proto_msgTypes[0]
+		mi := &file_services_geo_proto_geo_proto_msgTypes[7]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *Request) String() string {
+func (x *ReloadResult) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Request) ProtoMessage() {}
+func (*ReloadResult) ProtoMessage() {}
 
-func (x *Request) ProtoReflect() protoreflect.Message {
-	mi := &file_services_geo_proto_geo_proto_msgTypes[0]
+func (x *ReloadResult) ProtoReflect() protoreflect.Message {
+	mi := &file_services_geo_proto_geo_proto_msgTypes[7]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -57,50 +546,100 @@ func (x *Request) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Request.ProtoReflect.Descriptor instead.
-func (*Request) Descriptor() ([]byte, []int) {
-	return file_services_geo_proto_geo_proto_rawDescGZIP(), []int{0}
+// Deprecated: Use ReloadResult.ProtoReflect.Descriptor instead.
+func (*ReloadResult) Descriptor() ([]byte, []int) {
+	return file_services_geo_proto_geo_proto_rawDescGZIP(), []int{7}
 }
 
-func (x *Request) GetLat() float32 {
+func (x *ReloadResult) GetHotels() int32 {
 	if x != nil {
-		return x.Lat
+		return x.Hotels
 	}
 	return 0
 }
 
-func (x *Request) GetLon() float32 {
+// The size in degrees of the cells of a lat/lon grid aligned on 0, 0.
+type CellRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	LatCellSize float32 `protobuf:"fixed32,1,opt,name=latCellSize,proto3" json:"latCellSize,omitempty"`
+	LonCellSize float32 `protobuf:"fixed32,2,opt,name=lonCellSize,proto3" json:"lonCellSize,omitempty"`
+}
+
+func (x *CellRequest) Reset() {
+	*x = CellRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_services_geo_proto_geo_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CellRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CellRequest) ProtoMessage() {}
+
+func (x *CellRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_services_geo_proto_geo_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CellRequest.ProtoReflect.Descriptor instead.
+func (*CellRequest) Descriptor() ([]byte, []int) {
+	return file_services_geo_proto_geo_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *CellRequest) GetLatCellSize() float32 {
 	if x != nil {
-		return x.Lon
+		return x.LatCellSize
 	}
 	return 0
 }
 
-type Result struct {
+func (x *CellRequest) GetLonCellSize() float32 {
+	if x != nil {
+		return x.LonCellSize
+	}
+	return 0
+}
+
+// The cells holding hotels, sorted by row and then column.
+type CellResult struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	HotelIds []string `protobuf:"bytes,1,rep,name=hotelIds,proto3" json:"hotelIds,omitempty"`
+	Cells []*CellResult_Cell `protobuf:"bytes,1,rep,name=cells,proto3" json:"cells,omitempty"`
 }
 
-func (x *Result) Reset() {
-	*x = Result{}
+func (x *CellResult) Reset() {
+	*x = CellResult{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_services_geo_proto_geo_proto_msgTypes[1]
+		mi := &file_services_geo_proto_geo_proto_msgTypes[9]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *Result) String() string {
+func (x *CellResult) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Result) ProtoMessage() {}
+func (*CellResult) ProtoMessage() {}
 
-func (x *Result) ProtoReflect() protoreflect.Message {
-	mi := &file_services_geo_proto_geo_proto_msgTypes[1]
+func (x *CellResult) ProtoReflect() protoreflect.Message {
+	mi := &file_services_geo_proto_geo_proto_msgTypes[9]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -111,37 +650,249 @@ func (x *Result) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Result.ProtoReflect.Descriptor instead.
-func (*Result) Descriptor() ([]byte, []int) {
-	return file_services_geo_proto_geo_proto_rawDescGZIP(), []int{1}
+// Deprecated: Use CellResult.ProtoReflect.Descriptor instead.
+func (*CellResult) Descriptor() ([]byte, []int) {
+	return file_services_geo_proto_geo_proto_rawDescGZIP(), []int{9}
 }
 
-func (x *Result) GetHotelIds() []string {
+func (x *CellResult) GetCells() []*CellResult_Cell {
 	if x != nil {
-		return x.HotelIds
+		return x.Cells
 	}
 	return nil
 }
 
+type NearestResult_Neighbor struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	HotelId string `protobuf:"bytes,1,opt,name=hotelId,proto3" json:"hotelId,omitempty"`
+	// Distance from the requested lat/lon, in unit.
+	Distance float32 `protobuf:"fixed32,2,opt,name=distance,proto3" json:"distance,omitempty"`
+}
+
+func (x *NearestResult_Neighbor) Reset() {
+	*x = NearestResult_Neighbor{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_services_geo_proto_geo_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *NearestResult_Neighbor) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NearestResult_Neighbor) ProtoMessage() {}
+
+func (x *NearestResult_Neighbor) ProtoReflect() protoreflect.Message {
+	mi := &file_services_geo_proto_geo_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NearestResult_Neighbor.ProtoReflect.Descriptor instead.
+func (*NearestResult_Neighbor) Descriptor() ([]byte, []int) {
+	return file_services_geo_proto_geo_proto_rawDescGZIP(), []int{5, 0}
+}
+
+func (x *NearestResult_Neighbor) GetHotelId() string {
+	if x != nil {
+		return x.HotelId
+	}
+	return ""
+}
+
+func (x *NearestResult_Neighbor) GetDistance() float32 {
+	if x != nil {
+		return x.Distance
+	}
+	return 0
+}
+
+type CellResult_Cell struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Cell row spans latitudes [row * latCellSize, (row + 1) * latCellSize),
+	// and column col longitudes [col * lonCellSize, (col + 1) * lonCellSize).
+	Row int32 `protobuf:"varint,1,opt,name=row,proto3" json:"row,omitempty"`
+	Col int32 `protobuf:"varint,2,opt,name=col,proto3" json:"col,omitempty"`
+	// The southwest corner of the cell.
+	MinLat float32 `protobuf:"fixed32,3,opt,name=minLat,proto3" json:"minLat,omitempty"`
+	MinLon float32 `protobuf:"fixed32,4,opt,name=minLon,proto3" json:"minLon,omitempty"`
+	Hotels int32   `protobuf:"varint,5,opt,name=hotels,proto3" json:"hotels,omitempty"`
+}
+
+func (x *CellResult_Cell) Reset() {
+	*x = CellResult_Cell{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_services_geo_proto_geo_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CellResult_Cell) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CellResult_Cell) ProtoMessage() {}
+
+func (x *CellResult_Cell) ProtoReflect() protoreflect.Message {
+	mi := &file_services_geo_proto_geo_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CellResult_Cell.ProtoReflect.Descriptor instead.
+func (*CellResult_Cell) Descriptor() ([]byte, []int) {
+	return file_services_geo_proto_geo_proto_rawDescGZIP(), []int{9, 0}
+}
+
+func (x *CellResult_Cell) GetRow() int32 {
+	if x != nil {
+		return x.Row
+	}
+	return 0
+}
+
+func (x *CellResult_Cell) GetCol() int32 {
+	if x != nil {
+		return x.Col
+	}
+	return 0
+}
+
+func (x *CellResult_Cell) GetMinLat() float32 {
+	if x != nil {
+		return x.MinLat
+	}
+	return 0
+}
+
+func (x *CellResult_Cell) GetMinLon() float32 {
+	if x != nil {
+		return x.MinLon
+	}
+	return 0
+}
+
+func (x *CellResult_Cell) GetHotels() int32 {
+	if x != nil {
+		return x.Hotels
+	}
+	return 0
+}
+
 var File_services_geo_proto_geo_proto protoreflect.FileDescriptor
 
 var file_services_geo_proto_geo_proto_rawDesc = []byte{
 	0x0a, 0x1c, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x2f, 0x67, 0x65, 0x6f, 0x2f, 0x70,
 	0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x67, 0x65, 0x6f, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x03,
-	0x67, 0x65, 0x6f, 0x22, 0x2d, 0x0a, 0x07, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x10,
+	0x67, 0x65, 0x6f, 0x22, 0x4c, 0x0a, 0x07, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x10,
 	0x0a, 0x03, 0x6c, 0x61, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x02, 0x52, 0x03, 0x6c, 0x61, 0x74,
 	0x12, 0x10, 0x0a, 0x03, 0x6c, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x02, 0x52, 0x03, 0x6c,
-	0x6f, 0x6e, 0x22, 0x24, 0x0a, 0x06, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x1a, 0x0a, 0x08,
-	0x68, 0x6f, 0x74, 0x65, 0x6c, 0x49, 0x64, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x08,
-	0x68, 0x6f, 0x74, 0x65, 0x6c, 0x49, 0x64, 0x73, 0x32, 0x2a, 0x0a, 0x03, 0x47, 0x65, 0x6f, 0x12,
-	0x23, 0x0a, 0x06, 0x4e, 0x65, 0x61, 0x72, 0x62, 0x79, 0x12, 0x0c, 0x2e, 0x67, 0x65, 0x6f, 0x2e,
-	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x0b, 0x2e, 0x67, 0x65, 0x6f, 0x2e, 0x52, 0x65,
-	0x73, 0x75, 0x6c, 0x74, 0x42, 0x44, 0x5a, 0x42, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63,
-	0x6f, 0x6d, 0x2f, 0x64, 0x65, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x72, 0x6f, 0x75, 0x2f, 0x44, 0x65,
-	0x61, 0x74, 0x68, 0x53, 0x74, 0x61, 0x72, 0x42, 0x65, 0x6e, 0x63, 0x68, 0x2f, 0x68, 0x6f, 0x74,
-	0x65, 0x6c, 0x72, 0x65, 0x73, 0x65, 0x72, 0x76, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2f, 0x73, 0x65,
-	0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x2f, 0x67, 0x65, 0x6f, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74,
-	0x6f, 0x33,
+	0x6f, 0x6e, 0x12, 0x1d, 0x0a, 0x04, 0x75, 0x6e, 0x69, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0e,
+	0x32, 0x09, 0x2e, 0x67, 0x65, 0x6f, 0x2e, 0x55, 0x6e, 0x69, 0x74, 0x52, 0x04, 0x75, 0x6e, 0x69,
+	0x74, 0x22, 0x6a, 0x0a, 0x0d, 0x52, 0x65, 0x67, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x6c, 0x61, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x02, 0x52,
+	0x03, 0x6c, 0x61, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x6c, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x02, 0x52, 0x03, 0x6c, 0x6f, 0x6e, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x65, 0x67, 0x69, 0x6f, 0x6e,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x72, 0x65, 0x67, 0x69, 0x6f, 0x6e, 0x12, 0x1d,
+	0x0a, 0x04, 0x75, 0x6e, 0x69, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x09, 0x2e, 0x67,
+	0x65, 0x6f, 0x2e, 0x55, 0x6e, 0x69, 0x74, 0x52, 0x04, 0x75, 0x6e, 0x69, 0x74, 0x22, 0x6c, 0x0a,
+	0x0a, 0x42, 0x6f, 0x78, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x6d,
+	0x69, 0x6e, 0x4c, 0x61, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x02, 0x52, 0x06, 0x6d, 0x69, 0x6e,
+	0x4c, 0x61, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x6d, 0x69, 0x6e, 0x4c, 0x6f, 0x6e, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x02, 0x52, 0x06, 0x6d, 0x69, 0x6e, 0x4c, 0x6f, 0x6e, 0x12, 0x16, 0x0a, 0x06, 0x6d,
+	0x61, 0x78, 0x4c, 0x61, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x02, 0x52, 0x06, 0x6d, 0x61, 0x78,
+	0x4c, 0x61, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x6d, 0x61, 0x78, 0x4c, 0x6f, 0x6e, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x02, 0x52, 0x06, 0x6d, 0x61, 0x78, 0x4c, 0x6f, 0x6e, 0x22, 0x61, 0x0a, 0x06, 0x52,
+	0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x68, 0x6f, 0x74, 0x65, 0x6c, 0x49, 0x64,
+	0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x08, 0x68, 0x6f, 0x74, 0x65, 0x6c, 0x49, 0x64,
+	0x73, 0x12, 0x1c, 0x0a, 0x09, 0x64, 0x69, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x73, 0x18, 0x02,
+	0x20, 0x03, 0x28, 0x02, 0x52, 0x09, 0x64, 0x69, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x73, 0x12,
+	0x1d, 0x0a, 0x04, 0x75, 0x6e, 0x69, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x09, 0x2e,
+	0x67, 0x65, 0x6f, 0x2e, 0x55, 0x6e, 0x69, 0x74, 0x52, 0x04, 0x75, 0x6e, 0x69, 0x74, 0x22, 0x61,
+	0x0a, 0x0e, 0x4e, 0x65, 0x61, 0x72, 0x65, 0x73, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x12, 0x10, 0x0a, 0x03, 0x6c, 0x61, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x02, 0x52, 0x03, 0x6c,
+	0x61, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x6c, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x02, 0x52,
+	0x03, 0x6c, 0x6f, 0x6e, 0x12, 0x0c, 0x0a, 0x01, 0x6b, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52,
+	0x01, 0x6b, 0x12, 0x1d, 0x0a, 0x04, 0x75, 0x6e, 0x69, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0e,
+	0x32, 0x09, 0x2e, 0x67, 0x65, 0x6f, 0x2e, 0x55, 0x6e, 0x69, 0x74, 0x52, 0x04, 0x75, 0x6e, 0x69,
+	0x74, 0x22, 0xab, 0x01, 0x0a, 0x0d, 0x4e, 0x65, 0x61, 0x72, 0x65, 0x73, 0x74, 0x52, 0x65, 0x73,
+	0x75, 0x6c, 0x74, 0x12, 0x39, 0x0a, 0x09, 0x6e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x73,
+	0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x67, 0x65, 0x6f, 0x2e, 0x4e, 0x65, 0x61,
+	0x72, 0x65, 0x73, 0x74, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x2e, 0x4e, 0x65, 0x69, 0x67, 0x68,
+	0x62, 0x6f, 0x72, 0x52, 0x09, 0x6e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x73, 0x12, 0x1d,
+	0x0a, 0x04, 0x75, 0x6e, 0x69, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x09, 0x2e, 0x67,
+	0x65, 0x6f, 0x2e, 0x55, 0x6e, 0x69, 0x74, 0x52, 0x04, 0x75, 0x6e, 0x69, 0x74, 0x1a, 0x40, 0x0a,
+	0x08, 0x4e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x12, 0x18, 0x0a, 0x07, 0x68, 0x6f, 0x74,
+	0x65, 0x6c, 0x49, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x68, 0x6f, 0x74, 0x65,
+	0x6c, 0x49, 0x64, 0x12, 0x1a, 0x0a, 0x08, 0x64, 0x69, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x02, 0x52, 0x08, 0x64, 0x69, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x22,
+	0x0f, 0x0a, 0x0d, 0x52, 0x65, 0x6c, 0x6f, 0x61, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x22, 0x26, 0x0a, 0x0c, 0x52, 0x65, 0x6c, 0x6f, 0x61, 0x64, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74,
+	0x12, 0x16, 0x0a, 0x06, 0x68, 0x6f, 0x74, 0x65, 0x6c, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05,
+	0x52, 0x06, 0x68, 0x6f, 0x74, 0x65, 0x6c, 0x73, 0x22, 0x51, 0x0a, 0x0b, 0x43, 0x65, 0x6c, 0x6c,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x20, 0x0a, 0x0b, 0x6c, 0x61, 0x74, 0x43, 0x65,
+	0x6c, 0x6c, 0x53, 0x69, 0x7a, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x02, 0x52, 0x0b, 0x6c, 0x61,
+	0x74, 0x43, 0x65, 0x6c, 0x6c, 0x53, 0x69, 0x7a, 0x65, 0x12, 0x20, 0x0a, 0x0b, 0x6c, 0x6f, 0x6e,
+	0x43, 0x65, 0x6c, 0x6c, 0x53, 0x69, 0x7a, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x02, 0x52, 0x0b,
+	0x6c, 0x6f, 0x6e, 0x43, 0x65, 0x6c, 0x6c, 0x53, 0x69, 0x7a, 0x65, 0x22, 0xac, 0x01, 0x0a, 0x0a,
+	0x43, 0x65, 0x6c, 0x6c, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x2a, 0x0a, 0x05, 0x63, 0x65,
+	0x6c, 0x6c, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x67, 0x65, 0x6f, 0x2e,
+	0x43, 0x65, 0x6c, 0x6c, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x2e, 0x43, 0x65, 0x6c, 0x6c, 0x52,
+	0x05, 0x63, 0x65, 0x6c, 0x6c, 0x73, 0x1a, 0x72, 0x0a, 0x04, 0x43, 0x65, 0x6c, 0x6c, 0x12, 0x10,
+	0x0a, 0x03, 0x72, 0x6f, 0x77, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x03, 0x72, 0x6f, 0x77,
+	0x12, 0x10, 0x0a, 0x03, 0x63, 0x6f, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x03, 0x63,
+	0x6f, 0x6c, 0x12, 0x16, 0x0a, 0x06, 0x6d, 0x69, 0x6e, 0x4c, 0x61, 0x74, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x02, 0x52, 0x06, 0x6d, 0x69, 0x6e, 0x4c, 0x61, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x6d, 0x69,
+	0x6e, 0x4c, 0x6f, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x02, 0x52, 0x06, 0x6d, 0x69, 0x6e, 0x4c,
+	0x6f, 0x6e, 0x12, 0x16, 0x0a, 0x06, 0x68, 0x6f, 0x74, 0x65, 0x6c, 0x73, 0x18, 0x05, 0x20, 0x01,
+	0x28, 0x05, 0x52, 0x06, 0x68, 0x6f, 0x74, 0x65, 0x6c, 0x73, 0x2a, 0x19, 0x0a, 0x04, 0x55, 0x6e,
+	0x69, 0x74, 0x12, 0x06, 0x0a, 0x02, 0x4b, 0x4d, 0x10, 0x00, 0x12, 0x09, 0x0a, 0x05, 0x4d, 0x49,
+	0x4c, 0x45, 0x53, 0x10, 0x01, 0x32, 0xa9, 0x02, 0x0a, 0x03, 0x47, 0x65, 0x6f, 0x12, 0x23, 0x0a,
+	0x06, 0x4e, 0x65, 0x61, 0x72, 0x62, 0x79, 0x12, 0x0c, 0x2e, 0x67, 0x65, 0x6f, 0x2e, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x0b, 0x2e, 0x67, 0x65, 0x6f, 0x2e, 0x52, 0x65, 0x73, 0x75,
+	0x6c, 0x74, 0x12, 0x29, 0x0a, 0x09, 0x4e, 0x65, 0x61, 0x72, 0x62, 0x79, 0x42, 0x6f, 0x78, 0x12,
+	0x0f, 0x2e, 0x67, 0x65, 0x6f, 0x2e, 0x42, 0x6f, 0x78, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x1a, 0x0b, 0x2e, 0x67, 0x65, 0x6f, 0x2e, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x31, 0x0a,
+	0x0e, 0x4e, 0x65, 0x61, 0x72, 0x62, 0x79, 0x49, 0x6e, 0x52, 0x65, 0x67, 0x69, 0x6f, 0x6e, 0x12,
+	0x12, 0x2e, 0x67, 0x65, 0x6f, 0x2e, 0x52, 0x65, 0x67, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x1a, 0x0b, 0x2e, 0x67, 0x65, 0x6f, 0x2e, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74,
+	0x12, 0x33, 0x0a, 0x08, 0x4e, 0x65, 0x61, 0x72, 0x65, 0x73, 0x74, 0x4b, 0x12, 0x13, 0x2e, 0x67,
+	0x65, 0x6f, 0x2e, 0x4e, 0x65, 0x61, 0x72, 0x65, 0x73, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x12, 0x2e, 0x67, 0x65, 0x6f, 0x2e, 0x4e, 0x65, 0x61, 0x72, 0x65, 0x73, 0x74, 0x52,
+	0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x34, 0x0a, 0x0b, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x52, 0x65,
+	0x6c, 0x6f, 0x61, 0x64, 0x12, 0x12, 0x2e, 0x67, 0x65, 0x6f, 0x2e, 0x52, 0x65, 0x6c, 0x6f, 0x61,
+	0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x11, 0x2e, 0x67, 0x65, 0x6f, 0x2e, 0x52,
+	0x65, 0x6c, 0x6f, 0x61, 0x64, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x34, 0x0a, 0x0f, 0x41,
+	0x67, 0x67, 0x72, 0x65, 0x67, 0x61, 0x74, 0x65, 0x42, 0x79, 0x43, 0x65, 0x6c, 0x6c, 0x12, 0x10,
+	0x2e, 0x67, 0x65, 0x6f, 0x2e, 0x43, 0x65, 0x6c, 0x6c, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x1a, 0x0f, 0x2e, 0x67, 0x65, 0x6f, 0x2e, 0x43, 0x65, 0x6c, 0x6c, 0x52, 0x65, 0x73, 0x75, 0x6c,
+	0x74, 0x42, 0x50, 0x5a, 0x4e, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f,
+	0x64, 0x65, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x72, 0x6f, 0x75, 0x2f, 0x44, 0x65, 0x61, 0x74, 0x68,
+	0x53, 0x74, 0x61, 0x72, 0x42, 0x65, 0x6e, 0x63, 0x68, 0x2f, 0x74, 0x72, 0x65, 0x65, 0x2f, 0x6d,
+	0x61, 0x73, 0x74, 0x65, 0x72, 0x2f, 0x68, 0x6f, 0x74, 0x65, 0x6c, 0x52, 0x65, 0x73, 0x65, 0x72,
+	0x76, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2f, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x2f,
+	0x67, 0x65, 0x6f, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -156,19 +907,48 @@ func file_services_geo_proto_geo_proto_rawDescGZIP() []byte {
 	return file_services_geo_proto_geo_proto_rawDescData
 }
 
-var file_services_geo_proto_geo_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_services_geo_proto_geo_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_services_geo_proto_geo_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
 var file_services_geo_proto_geo_proto_goTypes = []interface{}{
-	(*Request)(nil), // 0: geo.Request
-	(*Result)(nil),  // 1: geo.Result
+	(Unit)(0),                      // 0: geo.Unit
+	(*Request)(nil),                // 1: geo.Request
+	(*RegionRequest)(nil),          // 2: geo.RegionRequest
+	(*BoxRequest)(nil),             // 3: geo.BoxRequest
+	(*Result)(nil),                 // 4: geo.Result
+	(*NearestRequest)(nil),         // 5: geo.NearestRequest
+	(*NearestResult)(nil),          // 6: geo.NearestResult
+	(*ReloadRequest)(nil),          // 7: geo.ReloadRequest
+	(*ReloadResult)(nil),           // 8: geo.ReloadResult
+	(*CellRequest)(nil),            // 9: geo.CellRequest
+	(*CellResult)(nil),             // 10: geo.CellResult
+	(*NearestResult_Neighbor)(nil), // 11: geo.NearestResult.Neighbor
+	(*CellResult_Cell)(nil),        // 12: geo.CellResult.Cell
 }
 var file_services_geo_proto_geo_proto_depIdxs = []int32{
-	0, // 0: geo.Geo.Nearby:input_type -> geo.Request
-	1, // 1: geo.Geo.Nearby:output_type -> geo.Result
-	1, // [1:2] is the sub-list for method output_type
-	0, // [0:1] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	0,  // 0: geo.Request.unit:type_name -> geo.Unit
+	0,  // 1: geo.RegionRequest.unit:type_name -> geo.Unit
+	0,  // 2: geo.Result.unit:type_name -> geo.Unit
+	0,  // 3: geo.NearestRequest.unit:type_name -> geo.Unit
+	11, // 4: geo.NearestResult.neighbors:type_name -> geo.NearestResult.Neighbor
+	0,  // 5: geo.NearestResult.unit:type_name -> geo.Unit
+	12, // 6: geo.CellResult.cells:type_name -> geo.CellResult.Cell
+	1,  // 7: geo.Geo.Nearby:input_type -> geo.Request
+	3,  // 8: geo.Geo.NearbyBox:input_type -> geo.BoxRequest
+	2,  // 9: geo.Geo.NearbyInRegion:input_type -> geo.RegionRequest
+	5,  // 10: geo.Geo.NearestK:input_type -> geo.NearestRequest
+	7,  // 11: geo.Geo.IndexReload:input_type -> geo.ReloadRequest
+	9,  // 12: geo.Geo.AggregateByCell:input_type -> geo.CellRequest
+	4,  // 13: geo.Geo.Nearby:output_type -> geo.Result
+	4,  // 14: geo.Geo.NearbyBox:output_type -> geo.Result
+	4,  // 15: geo.Geo.NearbyInRegion:output_type -> geo.Result
+	6,  // 16: geo.Geo.NearestK:output_type -> geo.NearestResult
+	8,  // 17: geo.Geo.IndexReload:output_type -> geo.ReloadResult
+	10, // 18: geo.Geo.AggregateByCell:output_type -> geo.CellResult
+	13, // [13:19] is the sub-list for method output_type
+	7,  // [7:13] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
 }
 
 func init() { file_services_geo_proto_geo_proto_init() }
@@ -190,6 +970,30 @@ func file_services_geo_proto_geo_proto_init() {
 			}
 		}
 		file_services_geo_proto_geo_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RegionRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_services_geo_proto_geo_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BoxRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_services_geo_proto_geo_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*Result); i {
 			case 0:
 				return &v.state
@@ -201,19 +1005,116 @@ func file_services_geo_proto_geo_proto_init() {
 				return nil
 			}
 		}
+		file_services_geo_proto_geo_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*NearestRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_services_geo_proto_geo_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*NearestResult); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_services_geo_proto_geo_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ReloadRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_services_geo_proto_geo_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ReloadResult); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_services_geo_proto_geo_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CellRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_services_geo_proto_geo_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CellResult); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_services_geo_proto_geo_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*NearestResult_Neighbor); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_services_geo_proto_geo_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CellResult_Cell); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_services_geo_proto_geo_proto_rawDesc,
-			NumEnums:      0,
-			NumMessages:   2,
+			NumEnums:      1,
+			NumMessages:   12,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_services_geo_proto_geo_proto_goTypes,
 		DependencyIndexes: file_services_geo_proto_geo_proto_depIdxs,
+		EnumInfos:         file_services_geo_proto_geo_proto_enumTypes,
 		MessageInfos:      file_services_geo_proto_geo_proto_msgTypes,
 	}.Build()
 	File_services_geo_proto_geo_proto = out.File
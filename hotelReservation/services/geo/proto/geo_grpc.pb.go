@@ -19,7 +19,12 @@ import (
 const _ = grpc.SupportPackageIsVersion7
 
 const (
-	Geo_Nearby_FullMethodName = "/geo.Geo/Nearby"
+	Geo_Nearby_FullMethodName          = "/geo.Geo/Nearby"
+	Geo_NearbyBox_FullMethodName       = "/geo.Geo/NearbyBox"
+	Geo_NearbyInRegion_FullMethodName  = "/geo.Geo/NearbyInRegion"
+	Geo_NearestK_FullMethodName        = "/geo.Geo/NearestK"
+	Geo_IndexReload_FullMethodName     = "/geo.Geo/IndexReload"
+	Geo_AggregateByCell_FullMethodName = "/geo.Geo/AggregateByCell"
 )
 
 // GeoClient is the client API for Geo service.
@@ -28,6 +33,17 @@ const (
 type GeoClient interface {
 	// Finds the hotels contained nearby the current lat/lon.
 	Nearby(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Result, error)
+	// Finds the hotels contained in the lat/lon bounding box.
+	NearbyBox(ctx context.Context, in *BoxRequest, opts ...grpc.CallOption) (*Result, error)
+	// Finds the hotels of a region contained nearby the current lat/lon.
+	NearbyInRegion(ctx context.Context, in *RegionRequest, opts ...grpc.CallOption) (*Result, error)
+	// Finds the k hotels closest to the current lat/lon.
+	NearestK(ctx context.Context, in *NearestRequest, opts ...grpc.CallOption) (*NearestResult, error)
+	// Rebuilds the index from the database, keeping the current one if that
+	// fails. Admin only.
+	IndexReload(ctx context.Context, in *ReloadRequest, opts ...grpc.CallOption) (*ReloadResult, error)
+	// Counts the hotels in each cell of a lat/lon grid.
+	AggregateByCell(ctx context.Context, in *CellRequest, opts ...grpc.CallOption) (*CellResult, error)
 }
 
 type geoClient struct {
@@ -47,12 +63,68 @@ func (c *geoClient) Nearby(ctx context.Context, in *Request, opts ...grpc.CallOp
 	return out, nil
 }
 
+func (c *geoClient) NearbyBox(ctx context.Context, in *BoxRequest, opts ...grpc.CallOption) (*Result, error) {
+	out := new(Result)
+	err := c.cc.Invoke(ctx, Geo_NearbyBox_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *geoClient) NearbyInRegion(ctx context.Context, in *RegionRequest, opts ...grpc.CallOption) (*Result, error) {
+	out := new(Result)
+	err := c.cc.Invoke(ctx, Geo_NearbyInRegion_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *geoClient) NearestK(ctx context.Context, in *NearestRequest, opts ...grpc.CallOption) (*NearestResult, error) {
+	out := new(NearestResult)
+	err := c.cc.Invoke(ctx, Geo_NearestK_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *geoClient) IndexReload(ctx context.Context, in *ReloadRequest, opts ...grpc.CallOption) (*ReloadResult, error) {
+	out := new(ReloadResult)
+	err := c.cc.Invoke(ctx, Geo_IndexReload_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *geoClient) AggregateByCell(ctx context.Context, in *CellRequest, opts ...grpc.CallOption) (*CellResult, error) {
+	out := new(CellResult)
+	err := c.cc.Invoke(ctx, Geo_AggregateByCell_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // GeoServer is the server API for Geo service.
 // All implementations must embed UnimplementedGeoServer
 // for forward compatibility
 type GeoServer interface {
 	// Finds the hotels contained nearby the current lat/lon.
 	Nearby(context.Context, *Request) (*Result, error)
+	// Finds the hotels contained in the lat/lon bounding box.
+	NearbyBox(context.Context, *BoxRequest) (*Result, error)
+	// Finds the hotels of a region contained nearby the current lat/lon.
+	NearbyInRegion(context.Context, *RegionRequest) (*Result, error)
+	// Finds the k hotels closest to the current lat/lon.
+	NearestK(context.Context, *NearestRequest) (*NearestResult, error)
+	// Rebuilds the index from the database, keeping the current one if that
+	// fails. Admin only.
+	IndexReload(context.Context, *ReloadRequest) (*ReloadResult, error)
+	// Counts the hotels in each cell of a lat/lon grid.
+	AggregateByCell(context.Context, *CellRequest) (*CellResult, error)
 	mustEmbedUnimplementedGeoServer()
 }
 
@@ -63,6 +135,21 @@ type UnimplementedGeoServer struct {
 func (UnimplementedGeoServer) Nearby(context.Context, *Request) (*Result, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Nearby not implemented")
 }
+func (UnimplementedGeoServer) NearbyBox(context.Context, *BoxRequest) (*Result, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method NearbyBox not implemented")
+}
+func (UnimplementedGeoServer) NearbyInRegion(context.Context, *RegionRequest) (*Result, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method NearbyInRegion not implemented")
+}
+func (UnimplementedGeoServer) NearestK(context.Context, *NearestRequest) (*NearestResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method NearestK not implemented")
+}
+func (UnimplementedGeoServer) IndexReload(context.Context, *ReloadRequest) (*ReloadResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method IndexReload not implemented")
+}
+func (UnimplementedGeoServer) AggregateByCell(context.Context, *CellRequest) (*CellResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AggregateByCell not implemented")
+}
 func (UnimplementedGeoServer) mustEmbedUnimplementedGeoServer() {}
 
 // UnsafeGeoServer may be embedded to opt out of forward compatibility for this service.
@@ -94,6 +181,96 @@ func _Geo_Nearby_Handler(srv interface{}, ctx context.Context, dec func(interfac
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Geo_NearbyBox_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BoxRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GeoServer).NearbyBox(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Geo_NearbyBox_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GeoServer).NearbyBox(ctx, req.(*BoxRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Geo_NearbyInRegion_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GeoServer).NearbyInRegion(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Geo_NearbyInRegion_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GeoServer).NearbyInRegion(ctx, req.(*RegionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Geo_NearestK_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NearestRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GeoServer).NearestK(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Geo_NearestK_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GeoServer).NearestK(ctx, req.(*NearestRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Geo_IndexReload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReloadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GeoServer).IndexReload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Geo_IndexReload_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GeoServer).IndexReload(ctx, req.(*ReloadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Geo_AggregateByCell_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CellRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GeoServer).AggregateByCell(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Geo_AggregateByCell_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GeoServer).AggregateByCell(ctx, req.(*CellRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Geo_ServiceDesc is the grpc.ServiceDesc for Geo service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -105,6 +282,26 @@ var Geo_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Nearby",
 			Handler:    _Geo_Nearby_Handler,
 		},
+		{
+			MethodName: "NearbyBox",
+			Handler:    _Geo_NearbyBox_Handler,
+		},
+		{
+			MethodName: "NearbyInRegion",
+			Handler:    _Geo_NearbyInRegion_Handler,
+		},
+		{
+			MethodName: "NearestK",
+			Handler:    _Geo_NearestK_Handler,
+		},
+		{
+			MethodName: "IndexReload",
+			Handler:    _Geo_IndexReload_Handler,
+		},
+		{
+			MethodName: "AggregateByCell",
+			Handler:    _Geo_AggregateByCell_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "services/geo/proto/geo.proto",
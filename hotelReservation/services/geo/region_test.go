@@ -0,0 +1,88 @@
+package geo
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/geo/proto"
+)
+
+// nearbyInRegion returns the IDs of the hotels of region s finds near 37.7,
+// -122.4.
+func nearbyInRegion(t *testing.T, s *Server, region string) []string {
+	t.Helper()
+	res, err := s.NearbyInRegion(context.Background(), &pb.RegionRequest{Lat: 37.7, Lon: -122.4, Region: region})
+	if err != nil {
+		t.Fatalf("NearbyInRegion(%q): %v", region, err)
+	}
+	if len(res.Distances) != len(res.HotelIds) {
+		t.Errorf("NearbyInRegion(%q): %d distances for %d hotels", region, len(res.Distances), len(res.HotelIds))
+	}
+	return res.HotelIds
+}
+
+func TestNearbyInRegion(t *testing.T) {
+	s := newTestServer(t,
+		&point{Pid: "downtown-1", Plat: 37.71, Plon: -122.4, Region: "downtown"},
+		&point{Pid: "downtown-2", Plat: 37.73, Plon: -122.4, Region: "downtown"},
+		&point{Pid: "mission-1", Plat: 37.705, Plon: -122.4, Region: "mission"},
+		&point{Pid: "mission-2", Plat: 37.72, Plon: -122.4, Region: "mission"},
+		&point{Pid: "untagged", Plat: 37.702, Plon: -122.4},
+		// out of the radius
+		&point{Pid: "downtown-far", Plat: 38.5, Plon: -122.4, Region: "downtown"},
+	)
+
+	for _, tt := range []struct {
+		region string
+		want   []string
+	}{
+		{"downtown", []string{"downtown-1", "downtown-2"}},
+		{"mission", []string{"mission-1", "mission-2"}},
+		{"unknown", nil},
+		{"", nil},
+	} {
+		if got := nearbyInRegion(t, s, tt.region); !equalOrder(got, tt.want) {
+			t.Errorf("region %q: got %v, want %v", tt.region, got, tt.want)
+		}
+	}
+}
+
+func TestNearbyInRegionFillsResults(t *testing.T) {
+	// the hotels closest to the center are all out of the region
+	var points []*point
+	var want []string
+	for i := 0; i < maxSearchResults; i++ {
+		points = append(points, &point{Pid: fmt.Sprintf("other-%d", i), Plat: 37.7 + float64(i+1)*0.001, Plon: -122.4, Region: "other"})
+	}
+	for i := 0; i < maxSearchResults+2; i++ {
+		id := fmt.Sprintf("region-%d", i)
+		points = append(points, &point{Pid: id, Plat: 37.71 + float64(i)*0.001, Plon: -122.4, Region: "region"})
+		if i < maxSearchResults {
+			want = append(want, id)
+		}
+	}
+	s := newTestServer(t, points...)
+
+	if got := nearbyInRegion(t, s, "region"); !equalOrder(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestNearbyInRegionReload(t *testing.T) {
+	s := newTestServer(t, &point{Pid: "1", Plat: 37.71, Plon: -122.4, Region: "downtown"})
+	insertPoints(t, s, &point{Pid: "2", Plat: 37.72, Plon: -122.4, Region: "uptown"})
+	if got := nearbyInRegion(t, s, "uptown"); len(got) != 0 {
+		t.Fatalf("got %v in a region loaded since, want none", got)
+	}
+
+	if _, err := s.IndexReload(context.Background(), &pb.ReloadRequest{}); err != nil {
+		t.Fatalf("IndexReload: %v", err)
+	}
+	if got := nearbyInRegion(t, s, "uptown"); !equalOrder(got, []string{"2"}) {
+		t.Errorf("got %v once reloaded, want [2]", got)
+	}
+	if got := nearbyInRegion(t, s, "downtown"); !equalOrder(got, []string{"1"}) {
+		t.Errorf("got %v once reloaded, want [1]", got)
+	}
+}
@@ -2,42 +2,106 @@ package geo
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"math"
 	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/coalesce"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails"
+	errpb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/graceful"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/healthcheck"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/registry"
 	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/geo/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/store"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tls"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
 	"github.com/google/uuid"
-	"github.com/grpc-ecosystem/grpc-opentracing/go/otgrpc"
 	"github.com/hailocab/go-geoindex"
 	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 )
 
 const (
 	name             = "srv-geo"
 	maxSearchRadius  = 10
 	maxSearchResults = 5
+
+	// Boxes spanning more degrees than this are answered by scanning all
+	// points instead of walking the cells of the street-level index.
+	maxIndexedBoxSpan = 1.0
+
+	// NearestK widens its index search from maxSearchRadius up to this
+	// radius in km before scanning all points.
+	maxNearestRadius = 160
+
+	// metersPerMile converts distances to pb.Unit_MILES.
+	metersPerMile = 1609.344
+
+	// AggregateByCell rejects cells smaller than this in degrees, about 11m
+	// of latitude, and grids with more cells than maxAggregateCells over the
+	// area spanned by the hotels.
+	minAggregateCellSize = 1e-4
+	maxAggregateCells    = 10000
 )
 
+// validators check requests before their handler runs.
+var validators = map[string]tracing.Validator{
+	pb.Geo_Nearby_FullMethodName: tracing.LatLon("lat", "lon"),
+	pb.Geo_NearbyBox_FullMethodName: tracing.ValidateAll(
+		tracing.LatLon("minLat", "minLon"),
+		tracing.LatLon("maxLat", "maxLon"),
+	),
+	pb.Geo_NearbyInRegion_FullMethodName:  tracing.ValidateAll(tracing.Required("region"), tracing.LatLon("lat", "lon")),
+	pb.Geo_NearestK_FullMethodName:        tracing.ValidateAll(tracing.Positive("k"), tracing.LatLon("lat", "lon")),
+	pb.Geo_AggregateByCell_FullMethodName: tracing.Positive("latCellSize", "lonCellSize"),
+}
+
 // Server implements the geo service
 type Server struct {
 	pb.UnimplementedGeoServer
 
-	index *geoindex.ClusteringIndex
-	uuid  string
+	// index holds the current *geoIndex, swapped whole on reloads so that
+	// queries read it without locking.
+	index    atomic.Value
+	reloadMu sync.Mutex
+	// flights collapses the concurrent mongo loads of the hotels.
+	flights     coalesce.Group
+	stopWatcher context.CancelFunc
+	uuid        string
+	health      *healthcheck.Checker
+	grpcServer  *grpc.Server
+	traffic     tracing.MetricsMark
 
 	Registry    *registry.Client
 	Tracer      opentracing.Tracer
 	Port        int
 	IpAddr      string
 	MongoClient *mongo.Client
+	// DB is the geo-db database, that of MongoClient if nil.
+	DB store.Database
+	// MetricsPort is the admin port serving /metrics, zero to disable it.
+	MetricsPort int
+	// ReloadInterval is how often the database is checked for changed
+	// hotels, zero to only rebuild the index on IndexReload.
+	ReloadInterval time.Duration
+	// AdminAllowlist are the IPs and CIDRs of the callers allowed to call
+	// IndexReload.
+	AdminAllowlist []string
 }
 
 // Run starts the server
@@ -46,8 +110,19 @@ func (s *Server) Run() error {
 		return fmt.Errorf("server port must be set")
 	}
 
-	if s.index == nil {
-		s.index = newGeoIndex(s.MongoClient)
+	if s.DB == nil {
+		s.DB = store.MongoDatabase(s.MongoClient.Database("geo-db"))
+	}
+
+	if s.index.Load() == nil {
+		if _, err := s.reload(context.Background(), true); err != nil {
+			return fmt.Errorf("failed to load geo index: %v", err)
+		}
+	}
+
+	admin, err := tracing.AdminUnaryServerInterceptor(s.AdminAllowlist, "/"+pb.Geo_ServiceDesc.ServiceName+"/IndexReload")
+	if err != nil {
+		return err
 	}
 
 	s.uuid = uuid.New().String()
@@ -57,11 +132,20 @@ func (s *Server) Run() error {
 			Timeout: 120 * time.Second,
 		}),
 		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             time.Duration(tune.GetKeepaliveTime()) * time.Millisecond,
 			PermitWithoutStream: true,
 		}),
-		grpc.UnaryInterceptor(
-			otgrpc.OpenTracingServerInterceptor(s.Tracer),
-		),
+		grpc.MaxRecvMsgSize(tune.GetMaxRecvMsgSize()),
+		grpc.MaxSendMsgSize(tune.GetMaxSendMsgSize()),
+		grpc.UnaryInterceptor(tracing.DefaultServerInterceptorChain(tracing.ServerChainOptions{
+			Tracer: s.Tracer,
+			Interceptors: []grpc.UnaryServerInterceptor{
+				queryTaggingUnaryServerInterceptor,
+				tracing.ChaosUnaryServerInterceptor(tracing.DefaultChaos),
+				admin,
+				tracing.ValidationUnaryServerInterceptor(validators),
+			},
+		})),
 	}
 
 	if tlsopt := tls.GetServerOpt(); tlsopt != nil {
@@ -69,8 +153,29 @@ func (s *Server) Run() error {
 	}
 
 	srv := grpc.NewServer(opts...)
+	s.grpcServer = srv
+	s.traffic = tracing.DefaultMetrics.Mark()
 
 	pb.RegisterGeoServer(srv, s)
+	if tune.GetGrpcReflection() {
+		reflection.Register(srv)
+	}
+
+	s.health = healthcheck.Register(srv, pb.Geo_ServiceDesc.ServiceName, healthcheck.Mongo(s.MongoClient))
+	s.health.Start()
+
+	if s.MetricsPort != 0 {
+		go tracing.ServeMetrics(s.MetricsPort, tracing.DefaultMetrics)
+	}
+	if path := tune.GetFeatureFlagsFile(); path != "" {
+		go tracing.DefaultFlags.WatchFile(path, time.Duration(tune.GetFeatureFlagsPollInterval())*time.Millisecond)
+	}
+
+	if s.ReloadInterval > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		s.stopWatcher = cancel
+		go s.watch(ctx)
+	}
 
 	// listener
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", s.Port))
@@ -87,31 +192,227 @@ func (s *Server) Run() error {
 	return srv.Serve(lis)
 }
 
-// Shutdown cleans up any processes
+// Shutdown reports the server NOT_SERVING, deregisters it and drains
+// in-flight RPCs before stopping it, then reports the traffic it served.
 func (s *Server) Shutdown() {
+	if s.stopWatcher != nil {
+		s.stopWatcher()
+	}
+	s.health.Shutdown()
 	s.Registry.Deregister(s.uuid)
+	graceful.Stop(s.grpcServer)
+	graceful.Report(s.grpcServer, s.traffic)
 }
 
-// Nearby returns all hotels within a given distance.
+// Nearby returns all hotels within a given distance, with their distance in
+// the requested unit.
 func (s *Server) Nearby(ctx context.Context, req *pb.Request) (*pb.Result, error) {
 	log.Trace().Msgf("In geo Nearby")
 
+	if err := checkUnit(req.Unit); err != nil {
+		return nil, err
+	}
+
 	var (
-		points = s.getNearbyPoints(ctx, float64(req.Lat), float64(req.Lon))
-		res    = &pb.Result{}
+		center = geoindex.NewGeoPoint("", float64(req.Lat), float64(req.Lon))
+		points = s.getNearbyPoints(ctx, s.geoIndex(), center.Lat(), center.Lon(), nil)
 	)
 
 	log.Trace().Msgf("geo after getNearbyPoints, len = %d", len(points))
 
+	return nearbyResult(center, points, req.Unit), nil
+}
+
+// NearbyInRegion returns the hotels of a region within the distance of
+// Nearby, with their distance in the requested unit. Hotels of other regions
+// are skipped by the search itself, so that up to as many hotels of the
+// region are returned as Nearby returns overall. Regions without hotels
+// return no hotels.
+func (s *Server) NearbyInRegion(ctx context.Context, req *pb.RegionRequest) (*pb.Result, error) {
+	log.Trace().Msgf("In geo NearbyInRegion")
+
+	if err := checkUnit(req.Unit); err != nil {
+		return nil, err
+	}
+
+	idx := s.geoIndex()
+	hotels, ok := idx.regions[req.Region]
+	if !ok {
+		tracing.Logger(ctx).Debug().Msgf("No hotels in region %q", req.Region)
+		return &pb.Result{Unit: req.Unit}, nil
+	}
+
+	var (
+		center = geoindex.NewGeoPoint("", float64(req.Lat), float64(req.Lon))
+		points = s.getNearbyPoints(ctx, idx, center.Lat(), center.Lon(), func(p geoindex.Point) bool {
+			return hotels[p.Id()]
+		})
+	)
+
+	log.Trace().Msgf("geo after getNearbyPoints in region %s, len = %d", req.Region, len(points))
+
+	return nearbyResult(center, points, req.Unit), nil
+}
+
+// nearbyResult returns the result listing points, with their distance to
+// center in unit.
+func nearbyResult(center geoindex.Point, points []geoindex.Point, unit pb.Unit) *pb.Result {
+	res := &pb.Result{Unit: unit}
 	for _, p := range points {
 		log.Trace().Msgf("In geo Nearby return hotelId = %s", p.Id())
 		res.HotelIds = append(res.HotelIds, p.Id())
+		res.Distances = append(res.Distances, inUnit(geoindex.Distance(center, p), unit))
+	}
+	return res
+}
+
+// NearbyBox returns all hotels within a lat/lon bounding box. A box with
+// minLon > maxLon crosses the antimeridian. A box reduced to a single point
+// falls back to the radius search of Nearby.
+func (s *Server) NearbyBox(ctx context.Context, req *pb.BoxRequest) (*pb.Result, error) {
+	log.Trace().Msgf("In geo NearbyBox")
+
+	if req.MinLat > req.MaxLat {
+		return nil, errdetails.InvalidArgument(errpb.ErrorDetail_OUT_OF_RANGE, "minLat", "minLat %f is greater than maxLat %f", req.MinLat, req.MaxLat)
+	}
+
+	if req.MinLat == req.MaxLat && req.MinLon == req.MaxLon {
+		return s.Nearby(ctx, &pb.Request{Lat: req.MinLat, Lon: req.MinLon})
+	}
+
+	var (
+		points = s.getBoxPoints(ctx, s.geoIndex(), float64(req.MinLat), float64(req.MinLon), float64(req.MaxLat), float64(req.MaxLon))
+		res    = &pb.Result{}
+	)
+
+	log.Trace().Msgf("geo after getBoxPoints, len = %d", len(points))
+
+	for _, p := range points {
+		res.HotelIds = append(res.HotelIds, p.Id())
+	}
+
+	return res, nil
+}
+
+func (s *Server) getBoxPoints(ctx context.Context, idx *geoIndex, minLat, minLon, maxLat, maxLon float64) []geoindex.Point {
+	log.Trace().Msgf("In geo getBoxPoints, lat = [%f, %f], lon = [%f, %f]", minLat, maxLat, minLon, maxLon)
+
+	if minLon > maxLon {
+		// Split the box at the antimeridian.
+		return append(
+			s.getBoxPoints(ctx, idx, minLat, minLon, maxLat, 180),
+			s.getBoxPoints(ctx, idx, minLat, -180, maxLat, maxLon)...,
+		)
+	}
+
+	if maxLat-minLat <= maxIndexedBoxSpan && maxLon-minLon <= maxIndexedBoxSpan {
+		return idx.points.Range(
+			geoindex.NewGeoPoint("", maxLat, minLon),
+			geoindex.NewGeoPoint("", minLat, maxLon),
+		)
+	}
+
+	var points []geoindex.Point
+	for _, p := range idx.points.GetAll() {
+		if p.Lat() >= minLat && p.Lat() <= maxLat && p.Lon() >= minLon && p.Lon() <= maxLon {
+			points = append(points, p)
+		}
+	}
+	return points
+}
+
+// NearestK returns the k hotels closest to a lat/lon with their distance,
+// sorted by ascending distance and then hotel ID. Fewer than k hotels are
+// returned if the index holds fewer points.
+func (s *Server) NearestK(ctx context.Context, req *pb.NearestRequest) (*pb.NearestResult, error) {
+	log.Trace().Msgf("In geo NearestK")
+
+	if err := checkUnit(req.Unit); err != nil {
+		return nil, err
+	}
+
+	center := geoindex.NewGeoPoint("", float64(req.Lat), float64(req.Lon))
+	neighbors := s.getNearestPoints(ctx, s.geoIndex(), center, int(req.K))
+
+	res := &pb.NearestResult{
+		Neighbors: make([]*pb.NearestResult_Neighbor, 0, len(neighbors)),
+		Unit:      req.Unit,
+	}
+	for _, n := range neighbors {
+		res.Neighbors = append(res.Neighbors, &pb.NearestResult_Neighbor{
+			HotelId:  n.Id(),
+			Distance: inUnit(n.distance, req.Unit),
+		})
 	}
 
 	return res, nil
 }
 
-func (s *Server) getNearbyPoints(ctx context.Context, lat, lon float64) []geoindex.Point {
+// checkUnit fails with InvalidArgument on units other than KM and MILES.
+func checkUnit(unit pb.Unit) error {
+	if _, ok := pb.Unit_name[int32(unit)]; !ok {
+		return errdetails.InvalidArgument(errpb.ErrorDetail_UNSUPPORTED, "unit", "unsupported distance unit %d, want KM or MILES", unit)
+	}
+	return nil
+}
+
+// inUnit converts d to unit.
+func inUnit(d geoindex.Meters, unit pb.Unit) float32 {
+	if unit == pb.Unit_MILES {
+		return float32(float64(d) / metersPerMile)
+	}
+	return float32(d / 1000)
+}
+
+type neighbor struct {
+	geoindex.Point
+	distance geoindex.Meters
+}
+
+// getNearestPoints returns the k points closest to center. It searches the
+// index within a radius doubling from maxSearchRadius, which finds every
+// point closer than the radius, and scans all points once the radius exceeds
+// maxNearestRadius.
+func (s *Server) getNearestPoints(ctx context.Context, idx *geoIndex, center geoindex.Point, k int) []neighbor {
+	log.Trace().Msgf("In geo getNearestPoints, lat = %f, lon = %f, k = %d", center.Lat(), center.Lon(), k)
+
+	var candidates []geoindex.Point
+	for radius := float64(maxSearchRadius); ; radius *= 2 {
+		if radius > maxNearestRadius {
+			candidates = candidates[:0]
+			for _, p := range idx.points.GetAll() {
+				candidates = append(candidates, p)
+			}
+			break
+		}
+		candidates = idx.points.PointsWithin(center, geoindex.Km(radius), func(p geoindex.Point) bool {
+			return true
+		})
+		if len(candidates) >= k {
+			break
+		}
+	}
+
+	neighbors := make([]neighbor, 0, len(candidates))
+	for _, p := range candidates {
+		neighbors = append(neighbors, neighbor{Point: p, distance: geoindex.Distance(center, p)})
+	}
+	sort.Slice(neighbors, func(i, j int) bool {
+		if neighbors[i].distance != neighbors[j].distance {
+			return neighbors[i].distance < neighbors[j].distance
+		}
+		return neighbors[i].Id() < neighbors[j].Id()
+	})
+
+	if len(neighbors) > k {
+		neighbors = neighbors[:k]
+	}
+	return neighbors
+}
+
+// getNearbyPoints returns the maxSearchResults points of idx closest to lat,
+// lon within maxSearchRadius, among those accepted by accept, if not nil.
+func (s *Server) getNearbyPoints(ctx context.Context, idx *geoIndex, lat, lon float64, accept func(geoindex.Point) bool) []geoindex.Point {
 	log.Trace().Msgf("In geo getNearbyPoints, lat = %f, lon = %f", lat, lon)
 
 	center := &geoindex.GeoPoint{
@@ -120,44 +421,257 @@ func (s *Server) getNearbyPoints(ctx context.Context, lat, lon float64) []geoind
 		Plon: lon,
 	}
 
-	return s.index.KNearest(
+	return idx.clustering.KNearest(
 		center,
 		maxSearchResults,
 		geoindex.Km(maxSearchRadius), func(p geoindex.Point) bool {
-			return true
+			return accept == nil || accept(p)
 		},
 	)
 }
 
-// newGeoIndex returns a geo index with points loaded
-func newGeoIndex(client *mongo.Client) *geoindex.ClusteringIndex {
-	log.Trace().Msg("new geo newGeoIndex")
+// IndexReload rebuilds the index from the database and swaps it in, leaving
+// queries in flight on the previous one. A failed rebuild keeps the current
+// index.
+func (s *Server) IndexReload(ctx context.Context, req *pb.ReloadRequest) (*pb.ReloadResult, error) {
+	log.Trace().Msgf("In geo IndexReload")
 
-	collection := client.Database("geo-db").Collection("geo")
-	curr, err := collection.Find(context.TODO(), bson.D{})
+	idx, err := s.reload(ctx, true)
 	if err != nil {
-		log.Error().Msgf("Failed get geo data: ", err)
+		tracing.Logger(ctx).Error().Msgf("Failed to reload geo index, keeping the current one: %v", err)
+		return nil, err
+	}
+	return &pb.ReloadResult{Hotels: int32(idx.hotels)}, nil
+}
+
+// AggregateByCell returns the number of hotels in each cell of the grid of
+// the requested cell size, leaving out empty cells. Cells are sorted by row
+// and column so that identical indexes give identical results. Cells smaller
+// than minAggregateCellSize, and grids of more than maxAggregateCells cells
+// over the area spanned by the hotels, are rejected as too fine.
+func (s *Server) AggregateByCell(ctx context.Context, req *pb.CellRequest) (*pb.CellResult, error) {
+	log.Trace().Msgf("In geo AggregateByCell")
+
+	if req.LatCellSize < minAggregateCellSize {
+		return nil, errdetails.InvalidArgument(errpb.ErrorDetail_OUT_OF_RANGE, "latCellSize", "latCellSize must be at least %v degrees, got %v", minAggregateCellSize, req.LatCellSize)
+	}
+	if req.LonCellSize < minAggregateCellSize {
+		return nil, errdetails.InvalidArgument(errpb.ErrorDetail_OUT_OF_RANGE, "lonCellSize", "lonCellSize must be at least %v degrees, got %v", minAggregateCellSize, req.LonCellSize)
 	}
 
-	var points []*point
-	curr.All(context.TODO(), &points)
+	latSize, lonSize := float64(req.LatCellSize), float64(req.LonCellSize)
+	points := s.geoIndex().points.GetAll()
+
+	type cell struct{ row, col int32 }
+	counts := make(map[cell]int32)
+	var minCell, maxCell cell
+	for _, p := range points {
+		c := cell{int32(math.Floor(p.Lat() / latSize)), int32(math.Floor(p.Lon() / lonSize))}
+		if len(counts) == 0 {
+			minCell, maxCell = c, c
+		}
+		counts[c]++
+		if c.row < minCell.row {
+			minCell.row = c.row
+		}
+		if c.row > maxCell.row {
+			maxCell.row = c.row
+		}
+		if c.col < minCell.col {
+			minCell.col = c.col
+		}
+		if c.col > maxCell.col {
+			maxCell.col = c.col
+		}
+	}
+
+	if len(counts) > 0 {
+		rows := int64(maxCell.row-minCell.row) + 1
+		cols := int64(maxCell.col-minCell.col) + 1
+		if rows*cols > maxAggregateCells {
+			return nil, errdetails.InvalidArgument(errpb.ErrorDetail_OUT_OF_RANGE, "latCellSize",
+				"cells of %v by %v degrees split the hotels into a %d by %d grid, over the limit of %d cells", req.LatCellSize, req.LonCellSize, rows, cols, maxAggregateCells)
+		}
+	}
+
+	res := &pb.CellResult{Cells: make([]*pb.CellResult_Cell, 0, len(counts))}
+	for c, n := range counts {
+		res.Cells = append(res.Cells, &pb.CellResult_Cell{
+			Row:    c.row,
+			Col:    c.col,
+			MinLat: float32(float64(c.row) * latSize),
+			MinLon: float32(float64(c.col) * lonSize),
+			Hotels: n,
+		})
+	}
+	sort.Slice(res.Cells, func(i, j int) bool {
+		if res.Cells[i].Row != res.Cells[j].Row {
+			return res.Cells[i].Row < res.Cells[j].Row
+		}
+		return res.Cells[i].Col < res.Cells[j].Col
+	})
+
+	return res, nil
+}
+
+// geoIndex is a snapshot of the hotels, indexed for the queries.
+type geoIndex struct {
+	clustering *geoindex.ClusteringIndex
+	points     *geoindex.PointsIndex
+	// regions holds the IDs of the hotels of each region tag.
+	regions map[string]map[string]bool
+	hotels  int
+	// digest identifies the hotels the index was built from.
+	digest uint64
+}
+
+// geoIndex returns the current index.
+func (s *Server) geoIndex() *geoIndex {
+	return s.index.Load().(*geoIndex)
+}
+
+// reload loads the hotels from the database and swaps in a new index of
+// them, unless force is false and they are those of the current index. The
+// current index is kept on errors. Concurrent reloads share one load.
+func (s *Server) reload(ctx context.Context, force bool) (*geoIndex, error) {
+	points, err := s.loadPoints(ctx)
+	if isContextError(err) && ctx.Err() == nil {
+		// The load was led by another reload, cancelled since.
+		points, err = s.loadPoints(ctx)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to load hotels: %v", err)
+	}
+
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	cur, _ := s.index.Load().(*geoIndex)
+	if !force && cur != nil && cur.digest == digestPoints(points) {
+		return cur, nil
+	}
+	idx, err := newGeoIndex(points)
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "invalid hotels: %v", err)
+	}
+	s.index.Store(idx)
+	log.Info().Msgf("Geo index built with %d hotels", idx.hotels)
+	return idx, nil
+}
+
+// watch rebuilds the index every ReloadInterval if the hotels changed, until
+// ctx is done.
+func (s *Server) watch(ctx context.Context) {
+	ticker := time.NewTicker(s.ReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		reloadCtx, cancel := context.WithTimeout(ctx, s.ReloadInterval)
+		if _, err := s.reload(reloadCtx, false); err != nil && ctx.Err() == nil {
+			log.Error().Msgf("Failed to reload geo index, keeping the current one: %v", err)
+		}
+		cancel()
+	}
+}
+
+// pointsKey is the key of the loads of the hotels in Server.flights.
+const pointsKey = "geo"
+
+// loadPoints returns the hotels of the database, sorted by ID, as loaded
+// once for concurrent calls, see Server.flights.
+func (s *Server) loadPoints(ctx context.Context) ([]*point, error) {
+	loaded, err := s.flights.Load([]string{pointsKey}, func(keys []string) (map[string]interface{}, error) {
+		points, err := loadPoints(ctx, s.DB)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{pointsKey: points}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loaded[pointsKey].([]*point), nil
+}
+
+// isContextError reports whether err is that of a cancelled or expired
+// context.
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// loadPoints returns the hotels of the database, sorted by ID.
+func loadPoints(ctx context.Context, db store.Database) ([]*point, error) {
+	collection := db.Collection("geo")
+	curr, err := collection.Find(ctx, bson.D{})
 	if err != nil {
-		log.Error().Msgf("Failed get geo data: ", err)
+		return nil, err
+	}
+
+	var points []*point
+	if err := curr.All(ctx, &points); err != nil {
+		return nil, err
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Pid < points[j].Pid })
+	return points, nil
+}
+
+// digestPoints hashes points, sorted by ID.
+func digestPoints(points []*point) uint64 {
+	h := fnv.New64a()
+	for _, p := range points {
+		fmt.Fprintf(h, "%s %v %v %q\n", p.Pid, p.Plat, p.Plon, p.Region)
+	}
+	return h.Sum64()
+}
+
+// newGeoIndex returns a geo index, a street-level points index and a region
+// index of points, sorted by ID, failing on hotels without an ID, with duplicate IDs
+// or out of range coordinates.
+func newGeoIndex(points []*point) (*geoIndex, error) {
+	log.Trace().Msg("new geo newGeoIndex")
+
+	for i, p := range points {
+		switch {
+		case p.Pid == "":
+			return nil, fmt.Errorf("hotel without an ID at %f, %f", p.Plat, p.Plon)
+		case i > 0 && points[i-1].Pid == p.Pid:
+			return nil, fmt.Errorf("duplicate hotel %s", p.Pid)
+		case p.Plat < -90 || p.Plat > 90 || p.Plon < -180 || p.Plon > 180:
+			return nil, fmt.Errorf("hotel %s has invalid coordinates %f, %f", p.Pid, p.Plat, p.Plon)
+		}
 	}
 
 	// add points to index
-	index := geoindex.NewClusteringIndex()
+	idx := &geoIndex{
+		clustering: geoindex.NewClusteringIndex(),
+		points:     geoindex.NewPointsIndex(geoindex.Km(0.5)),
+		regions:    make(map[string]map[string]bool),
+		hotels:     len(points),
+		digest:     digestPoints(points),
+	}
 	for _, point := range points {
-		index.Add(point)
+		idx.clustering.Add(point)
+		idx.points.Add(point)
+		if point.Region != "" {
+			if idx.regions[point.Region] == nil {
+				idx.regions[point.Region] = make(map[string]bool)
+			}
+			idx.regions[point.Region][point.Pid] = true
+		}
 	}
 
-	return index
+	return idx, nil
 }
 
 type point struct {
 	Pid  string  `bson:"hotelId"`
 	Plat float64 `bson:"lat"`
 	Plon float64 `bson:"lon"`
+	// Region is the region tag of the hotel, if any.
+	Region string `bson:"region"`
 }
 
 // Implement Point interface
@@ -0,0 +1,451 @@
+package geo
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/inproc/fakestore"
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/geo/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/store"
+	"github.com/hailocab/go-geoindex"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newTestServer returns a server with its index built from points, stored
+// in a stand-in of MongoDB.
+func newTestServer(t *testing.T, points ...*point) *Server {
+	t.Helper()
+	s := &Server{DB: store.MongoDatabase(fakestore.Mongo(t).Database("geo-db"))}
+	insertPoints(t, s, points...)
+	if _, err := s.reload(context.Background(), true); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	return s
+}
+
+// insertPoints adds points to the database of s.
+func insertPoints(t *testing.T, s *Server, points ...*point) {
+	t.Helper()
+	if len(points) == 0 {
+		return
+	}
+	docs := make([]interface{}, len(points))
+	for i, p := range points {
+		docs[i] = p
+	}
+	if _, err := s.DB.Collection("geo").InsertMany(context.Background(), docs); err != nil {
+		t.Fatalf("inserting hotels: %v", err)
+	}
+}
+
+// sorted returns ids sorted.
+func sorted(ids []string) []string {
+	ids = append([]string(nil), ids...)
+	sort.Strings(ids)
+	return ids
+}
+
+// equalOrder reports whether a and b hold the same IDs in the same order.
+func equalOrder(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// equalIds reports whether a and b hold the same IDs in any order.
+func equalIds(a, b []string) bool {
+	return equalOrder(sorted(a), sorted(b))
+}
+
+func TestNearbyBox(t *testing.T) {
+	s := newTestServer(t,
+		&point{Pid: "inside", Plat: 37.7, Plon: -122.2},
+		&point{Pid: "inside-south-west", Plat: 37.501, Plon: -122.499},
+		&point{Pid: "inside-north-east", Plat: 37.999, Plon: -122.001},
+		&point{Pid: "south", Plat: 37.499, Plon: -122.2},
+		&point{Pid: "north", Plat: 38.001, Plon: -122.2},
+		&point{Pid: "west", Plat: 37.7, Plon: -122.501},
+		&point{Pid: "east", Plat: 37.7, Plon: -121.999},
+	)
+
+	for _, tt := range []struct {
+		name string
+		req  *pb.BoxRequest
+		want []string
+	}{
+		{
+			"indexed",
+			&pb.BoxRequest{MinLat: 37.5, MinLon: -122.5, MaxLat: 38, MaxLon: -122},
+			[]string{"inside", "inside-south-west", "inside-north-east"},
+		},
+		{
+			"scanned",
+			&pb.BoxRequest{MinLat: 36, MinLon: -122.5, MaxLat: 38, MaxLon: -122},
+			[]string{"inside", "inside-south-west", "inside-north-east", "south"},
+		},
+		{
+			"empty",
+			&pb.BoxRequest{MinLat: 10, MinLon: 10, MaxLat: 11, MaxLon: 11},
+			nil,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := s.NearbyBox(context.Background(), tt.req)
+			if err != nil {
+				t.Fatalf("NearbyBox: %v", err)
+			}
+			if !equalIds(res.HotelIds, tt.want) {
+				t.Errorf("got %v, want %v", sorted(res.HotelIds), sorted(tt.want))
+			}
+		})
+	}
+}
+
+func TestNearbyBoxAntimeridian(t *testing.T) {
+	s := newTestServer(t,
+		&point{Pid: "east-of-it", Plat: 10, Plon: 179.9},
+		&point{Pid: "west-of-it", Plat: 10, Plon: -179.9},
+		&point{Pid: "far", Plat: 10, Plon: 170},
+	)
+	res, err := s.NearbyBox(context.Background(), &pb.BoxRequest{MinLat: 9.5, MinLon: 179.5, MaxLat: 10.5, MaxLon: -179.5})
+	if err != nil {
+		t.Fatalf("NearbyBox: %v", err)
+	}
+	if want := []string{"east-of-it", "west-of-it"}; !equalIds(res.HotelIds, want) {
+		t.Errorf("got %v, want %v", sorted(res.HotelIds), want)
+	}
+}
+
+func TestNearbyBoxInverted(t *testing.T) {
+	s := newTestServer(t)
+	_, err := s.NearbyBox(context.Background(), &pb.BoxRequest{MinLat: 38, MinLon: -122.5, MaxLat: 37.5, MaxLon: -122})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("got %v, want InvalidArgument", err)
+	}
+}
+
+func TestNearestK(t *testing.T) {
+	s := newTestServer(t,
+		&point{Pid: "3", Plat: 37.73, Plon: -122.4},
+		&point{Pid: "1", Plat: 37.71, Plon: -122.4},
+		&point{Pid: "far", Plat: 39, Plon: -122.4},
+		&point{Pid: "2", Plat: 37.72, Plon: -122.4},
+		// as close as 2, ordered after it by ID
+		&point{Pid: "2b", Plat: 37.72, Plon: -122.4},
+	)
+
+	res, err := s.NearestK(context.Background(), &pb.NearestRequest{Lat: 37.7, Lon: -122.4, K: 3})
+	if err != nil {
+		t.Fatalf("NearestK: %v", err)
+	}
+	var got []string
+	for i, n := range res.Neighbors {
+		got = append(got, n.HotelId)
+		if i > 0 && n.Distance < res.Neighbors[i-1].Distance {
+			t.Errorf("neighbor %s at %v km is closer than the one before it", n.HotelId, n.Distance)
+		}
+	}
+	if want := []string{"1", "2", "2b"}; !equalOrder(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestNearestKFewerThanK(t *testing.T) {
+	s := newTestServer(t,
+		&point{Pid: "near", Plat: 37.71, Plon: -122.4},
+		// beyond every search radius, found by the final scan
+		&point{Pid: "far", Plat: 45, Plon: -100},
+	)
+	res, err := s.NearestK(context.Background(), &pb.NearestRequest{Lat: 37.7, Lon: -122.4, K: 10})
+	if err != nil {
+		t.Fatalf("NearestK: %v", err)
+	}
+	var got []string
+	for _, n := range res.Neighbors {
+		got = append(got, n.HotelId)
+	}
+	if want := []string{"near", "far"}; !equalOrder(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// nearby returns the IDs of the hotels s finds near 37.7, -122.4.
+func nearby(t *testing.T, s *Server) []string {
+	t.Helper()
+	res, err := s.Nearby(context.Background(), &pb.Request{Lat: 37.7, Lon: -122.4})
+	if err != nil {
+		t.Fatalf("Nearby: %v", err)
+	}
+	return res.HotelIds
+}
+
+func TestIndexReload(t *testing.T) {
+	s := newTestServer(t, &point{Pid: "1", Plat: 37.71, Plon: -122.4})
+	insertPoints(t, s, &point{Pid: "2", Plat: 37.72, Plon: -122.4})
+	if got := nearby(t, s); !equalIds(got, []string{"1"}) {
+		t.Fatalf("got %v before the reload, want [1]", got)
+	}
+
+	res, err := s.IndexReload(context.Background(), &pb.ReloadRequest{})
+	if err != nil {
+		t.Fatalf("IndexReload: %v", err)
+	}
+	if res.Hotels != 2 {
+		t.Errorf("reloaded %d hotels, want 2", res.Hotels)
+	}
+	if got := nearby(t, s); !equalIds(got, []string{"1", "2"}) {
+		t.Errorf("got %v after the reload, want [1 2]", got)
+	}
+}
+
+func TestIndexReloadInvalidHotels(t *testing.T) {
+	s := newTestServer(t, &point{Pid: "1", Plat: 37.71, Plon: -122.4})
+	insertPoints(t, s,
+		&point{Pid: "2", Plat: 37.72, Plon: -122.4},
+		&point{Pid: "bad", Plat: 137.7, Plon: -122.4},
+	)
+
+	if _, err := s.IndexReload(context.Background(), &pb.ReloadRequest{}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("got %v, want FailedPrecondition", err)
+	}
+	if got := nearby(t, s); !equalIds(got, []string{"1"}) {
+		t.Errorf("got %v after the failed reload, want the prior [1]", got)
+	}
+}
+
+// failingDB is a database whose queries fail.
+type failingDB struct{}
+
+func (failingDB) Collection(name string) store.Collection { return failingCollection{} }
+
+type failingCollection struct {
+	store.Collection
+}
+
+func (failingCollection) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	return nil, errors.New("mongo is down")
+}
+
+func TestIndexReloadMongoDown(t *testing.T) {
+	s := newTestServer(t, &point{Pid: "1", Plat: 37.71, Plon: -122.4})
+	s.DB = failingDB{}
+	if _, err := s.IndexReload(context.Background(), &pb.ReloadRequest{}); status.Code(err) != codes.Unavailable {
+		t.Errorf("got %v, want Unavailable", err)
+	}
+	if got := nearby(t, s); !equalIds(got, []string{"1"}) {
+		t.Errorf("got %v after the failed reload, want the prior [1]", got)
+	}
+}
+
+// countingDB is a database counting the queries of its hotels, which take
+// a while.
+type countingDB struct {
+	store.Database
+	finds int32
+}
+
+func (db *countingDB) Collection(name string) store.Collection {
+	return countingCollection{db.Database.Collection(name), &db.finds}
+}
+
+type countingCollection struct {
+	store.Collection
+	finds *int32
+}
+
+func (c countingCollection) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	atomic.AddInt32(c.finds, 1)
+	time.Sleep(50 * time.Millisecond)
+	return c.Collection.Find(ctx, filter, opts...)
+}
+
+func TestConcurrentIndexReloadsShareOneLoad(t *testing.T) {
+	s := newTestServer(t, &point{Pid: "1", Plat: 37.71, Plon: -122.4})
+	insertPoints(t, s, &point{Pid: "2", Plat: 37.72, Plon: -122.4})
+	db := &countingDB{Database: s.DB}
+	s.DB = db
+
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			res, err := s.IndexReload(context.Background(), &pb.ReloadRequest{})
+			if err != nil || res.Hotels != 2 {
+				t.Errorf("IndexReload: got %v, %v, want 2 hotels", res, err)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if db.finds != 1 {
+		t.Errorf("mongo queried %d times for 20 concurrent reloads, want 1", db.finds)
+	}
+	if got := nearby(t, s); !equalIds(got, []string{"1", "2"}) {
+		t.Errorf("got %v after the reloads, want [1 2]", got)
+	}
+}
+
+func TestIndexReloadDuringQueries(t *testing.T) {
+	s := newTestServer(t, &point{Pid: "1", Plat: 37.71, Plon: -122.4})
+	insertPoints(t, s, &point{Pid: "2", Plat: 37.72, Plon: -122.4})
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				// queries see either index, whole
+				if got := nearby(t, s); !equalIds(got, []string{"1"}) && !equalIds(got, []string{"1", "2"}) {
+					t.Errorf("got %v during the reloads", got)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 20; i++ {
+		if _, err := s.IndexReload(context.Background(), &pb.ReloadRequest{}); err != nil {
+			t.Errorf("IndexReload: %v", err)
+		}
+	}
+	close(done)
+	wg.Wait()
+}
+
+func TestWatchReloadsChangedHotels(t *testing.T) {
+	s := newTestServer(t, &point{Pid: "1", Plat: 37.71, Plon: -122.4})
+	s.ReloadInterval = 20 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.watch(ctx)
+
+	// unchanged hotels keep the index
+	idx := s.geoIndex()
+	time.Sleep(100 * time.Millisecond)
+	if s.geoIndex() != idx {
+		t.Error("index rebuilt without changes")
+	}
+
+	insertPoints(t, s, &point{Pid: "2", Plat: 37.72, Plon: -122.4})
+	deadline := time.Now().Add(5 * time.Second)
+	for !equalIds(nearby(t, s), []string{"1", "2"}) {
+		if time.Now().After(deadline) {
+			t.Fatal("new hotel never picked up by the watcher")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestInUnit(t *testing.T) {
+	for _, tt := range []struct {
+		meters geoindex.Meters
+		unit   pb.Unit
+		want   float32
+	}{
+		{1000, pb.Unit_KM, 1},
+		{2500, pb.Unit_KM, 2.5},
+		{1609.344, pb.Unit_MILES, 1},
+		{16093.44, pb.Unit_MILES, 10},
+		{0, pb.Unit_MILES, 0},
+	} {
+		if got := inUnit(tt.meters, tt.unit); math.Abs(float64(got-tt.want)) > 1e-6 {
+			t.Errorf("inUnit(%v, %v) = %v, want %v", tt.meters, tt.unit, got, tt.want)
+		}
+	}
+}
+
+func TestDistanceUnits(t *testing.T) {
+	s := newTestServer(t,
+		&point{Pid: "1", Plat: 37.71, Plon: -122.4, Region: "downtown"},
+		&point{Pid: "2", Plat: 37.75, Plon: -122.4, Region: "downtown"},
+	)
+	ctx := context.Background()
+
+	km, err := s.Nearby(ctx, &pb.Request{Lat: 37.7, Lon: -122.4})
+	if err != nil {
+		t.Fatalf("Nearby: %v", err)
+	}
+	if km.Unit != pb.Unit_KM || len(km.Distances) != 2 {
+		t.Fatalf("got %v, want two distances in KM by default", km)
+	}
+	// 0.01 degree of latitude is about 1.11 km
+	want := map[string]float32{"1": 1.11, "2": 5.56}
+	for i, id := range km.HotelIds {
+		if math.Abs(float64(km.Distances[i]-want[id])) > 0.01 {
+			t.Errorf("hotel %s at %v km, want about %v", id, km.Distances[i], want[id])
+		}
+	}
+
+	miles, err := s.Nearby(ctx, &pb.Request{Lat: 37.7, Lon: -122.4, Unit: pb.Unit_MILES})
+	if err != nil {
+		t.Fatalf("Nearby: %v", err)
+	}
+	if miles.Unit != pb.Unit_MILES || !equalOrder(miles.HotelIds, km.HotelIds) {
+		t.Fatalf("got %v in miles, want the hotels of %v", miles, km)
+	}
+	for i := range km.Distances {
+		if ratio := km.Distances[i] / miles.Distances[i]; math.Abs(float64(ratio)-1.609344) > 1e-4 {
+			t.Errorf("hotel %s: km/miles = %v, want 1.609344", km.HotelIds[i], ratio)
+		}
+	}
+
+	region, err := s.NearbyInRegion(ctx, &pb.RegionRequest{Lat: 37.7, Lon: -122.4, Region: "downtown", Unit: pb.Unit_MILES})
+	if err != nil {
+		t.Fatalf("NearbyInRegion: %v", err)
+	}
+	if region.Unit != pb.Unit_MILES || !equalOrder(region.HotelIds, miles.HotelIds) || region.Distances[0] != miles.Distances[0] {
+		t.Errorf("got %v in region, want %v", region, miles)
+	}
+
+	nearest, err := s.NearestK(ctx, &pb.NearestRequest{Lat: 37.7, Lon: -122.4, K: 1, Unit: pb.Unit_MILES})
+	if err != nil {
+		t.Fatalf("NearestK: %v", err)
+	}
+	if nearest.Unit != pb.Unit_MILES || nearest.Neighbors[0].HotelId != "1" || math.Abs(float64(nearest.Neighbors[0].Distance)-1.11/1.609344) > 0.01 {
+		t.Errorf("got %v, want hotel 1 about %v miles away", nearest, 1.11/1.609344)
+	}
+}
+
+func TestInvalidUnit(t *testing.T) {
+	s := newTestServer(t, &point{Pid: "1", Plat: 37.71, Plon: -122.4})
+	ctx := context.Background()
+	bad := pb.Unit(7)
+
+	_, err := s.Nearby(ctx, &pb.Request{Lat: 37.7, Lon: -122.4, Unit: bad})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Nearby: got %v, want InvalidArgument", err)
+	}
+	_, err = s.NearbyInRegion(ctx, &pb.RegionRequest{Lat: 37.7, Lon: -122.4, Unit: bad})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("NearbyInRegion: got %v, want InvalidArgument", err)
+	}
+	_, err = s.NearestK(ctx, &pb.NearestRequest{Lat: 37.7, Lon: -122.4, K: 1, Unit: bad})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("NearestK: got %v, want InvalidArgument", err)
+	}
+}
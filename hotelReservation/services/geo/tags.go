@@ -0,0 +1,47 @@
+package geo
+
+import (
+	"context"
+
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/geo/proto"
+	opentracing "github.com/opentracing/opentracing-go"
+	"google.golang.org/grpc"
+)
+
+// queryTaggingUnaryServerInterceptor tags the span in ctx with the
+// coordinates queried, as numbers: geo.lat, geo.lon and geo.radius in km for
+// Nearby, the same and the geo.region string for NearbyInRegion, geo.lat,
+// geo.lon and geo.k for NearestK, the corners geo.min_lat, geo.min_lon,
+// geo.max_lat and geo.max_lon for NearbyBox, and geo.lat_cell_size and
+// geo.lon_cell_size for AggregateByCell. Other methods are left untagged.
+func queryTaggingUnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	span := opentracing.SpanFromContext(ctx)
+	if span == nil {
+		return handler(ctx, req)
+	}
+
+	switch r := req.(type) {
+	case *pb.Request:
+		span.SetTag("geo.lat", r.Lat)
+		span.SetTag("geo.lon", r.Lon)
+		span.SetTag("geo.radius", maxSearchRadius)
+	case *pb.RegionRequest:
+		span.SetTag("geo.lat", r.Lat)
+		span.SetTag("geo.lon", r.Lon)
+		span.SetTag("geo.radius", maxSearchRadius)
+		span.SetTag("geo.region", r.Region)
+	case *pb.NearestRequest:
+		span.SetTag("geo.lat", r.Lat)
+		span.SetTag("geo.lon", r.Lon)
+		span.SetTag("geo.k", r.K)
+	case *pb.BoxRequest:
+		span.SetTag("geo.min_lat", r.MinLat)
+		span.SetTag("geo.min_lon", r.MinLon)
+		span.SetTag("geo.max_lat", r.MaxLat)
+		span.SetTag("geo.max_lon", r.MaxLon)
+	case *pb.CellRequest:
+		span.SetTag("geo.lat_cell_size", r.LatCellSize)
+		span.SetTag("geo.lon_cell_size", r.LonCellSize)
+	}
+	return handler(ctx, req)
+}
@@ -0,0 +1,73 @@
+package geo
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/geo/proto"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestQueryTagging(t *testing.T) {
+	for _, tt := range []struct {
+		method string
+		req    interface{}
+		want   map[string]interface{}
+	}{
+		{
+			"/geo.Geo/Nearby",
+			&pb.Request{Lat: 37.7, Lon: -122.4},
+			map[string]interface{}{"geo.lat": float32(37.7), "geo.lon": float32(-122.4), "geo.radius": maxSearchRadius},
+		},
+		{
+			"/geo.Geo/NearbyInRegion",
+			&pb.RegionRequest{Lat: 37.7, Lon: -122.4, Region: "north-east"},
+			map[string]interface{}{"geo.lat": float32(37.7), "geo.lon": float32(-122.4), "geo.radius": maxSearchRadius, "geo.region": "north-east"},
+		},
+		{
+			"/geo.Geo/NearestK",
+			&pb.NearestRequest{Lat: 37.7, Lon: -122.4, K: 3},
+			map[string]interface{}{"geo.lat": float32(37.7), "geo.lon": float32(-122.4), "geo.k": int32(3)},
+		},
+		{
+			"/geo.Geo/NearbyBox",
+			&pb.BoxRequest{MinLat: 37.5, MinLon: -122.5, MaxLat: 38, MaxLon: -122},
+			map[string]interface{}{"geo.min_lat": float32(37.5), "geo.min_lon": float32(-122.5), "geo.max_lat": float32(38), "geo.max_lon": float32(-122)},
+		},
+		{
+			"/geo.Geo/AggregateByCell",
+			&pb.CellRequest{LatCellSize: 0.1, LonCellSize: 0.2},
+			map[string]interface{}{"geo.lat_cell_size": float32(0.1), "geo.lon_cell_size": float32(0.2)},
+		},
+		{"/geo.Geo/IndexReload", &pb.ReloadRequest{}, map[string]interface{}{}},
+		{"/grpc.health.v1.Health/Check", &healthpb.HealthCheckRequest{}, map[string]interface{}{}},
+	} {
+		span := mocktracer.New().StartSpan(tt.method).(*mocktracer.MockSpan)
+		called := false
+		queryTaggingUnaryServerInterceptor(opentracing.ContextWithSpan(context.Background(), span), tt.req, &grpc.UnaryServerInfo{FullMethod: tt.method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			called = true
+			return nil, nil
+		})
+		if !called {
+			t.Errorf("%s: handler not called", tt.method)
+		}
+		if got := span.Tags(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got tags %v, want %v", tt.method, got, tt.want)
+		}
+	}
+}
+
+func TestQueryTaggingWithoutSpan(t *testing.T) {
+	called := false
+	queryTaggingUnaryServerInterceptor(context.Background(), &pb.Request{}, &grpc.UnaryServerInfo{FullMethod: "/geo.Geo/Nearby"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		called = true
+		return nil, nil
+	})
+	if !called {
+		t.Error("handler not called")
+	}
+}
@@ -0,0 +1,100 @@
+package profile
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/bloom"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxVerified bounds the number of IDs ruled out by a bloom filter whose
+// lookup is remembered until the next build.
+const maxVerified = 4096
+
+// hotelFilter is a bloom filter of the hotel IDs, along with what the
+// lookups of the IDs it rules out found since it was built: hotels inserted
+// since aren't in the filter, so that the first lookup of an ID it rules out
+// still goes on to memcached and mongo.
+type hotelFilter struct {
+	filter *bloom.Filter
+
+	mu sync.Mutex
+	// verified tells, for the IDs ruled out by the filter and looked up
+	// since, whether they had a profile.
+	verified map[string]bool
+}
+
+// ruledOut reports whether hotelId is known to have no profile: the filter
+// rules it out and, if it was looked up since the build, it wasn't found.
+func (f *hotelFilter) ruledOut(hotelId string) (ruledOut, verified bool) {
+	if f.filter.MayContain(hotelId) {
+		return false, true
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	found, ok := f.verified[hotelId]
+	return ok && !found, ok
+}
+
+// verify remembers whether hotelId, ruled out by the filter, has a profile,
+// up to maxVerified IDs.
+func (f *hotelFilter) verify(hotelId string, found bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.verified[hotelId]; ok || len(f.verified) < maxVerified {
+		f.verified[hotelId] = found
+	}
+}
+
+// buildBloom loads the IDs of every hotel and swaps in a filter of them,
+// sized for s.BloomFPRate. The current filter is kept on errors.
+func (s *Server) buildBloom(ctx context.Context) error {
+	curr, err := s.DB.Collection("hotels").Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"id": 1}))
+	if err != nil {
+		return err
+	}
+	var hotels []struct {
+		Id string `bson:"id"`
+	}
+	if err := curr.All(ctx, &hotels); err != nil {
+		return err
+	}
+
+	f := bloom.New(len(hotels), s.BloomFPRate)
+	for _, h := range hotels {
+		f.Add(h.Id)
+	}
+	s.bloom.Store(&hotelFilter{filter: f, verified: make(map[string]bool)})
+	log.Debug().Msgf("Profile bloom filter built with %d hotels", len(hotels))
+	return nil
+}
+
+// hotelFilter returns the bloom filter of the hotel IDs, nil if disabled or
+// not built.
+func (s *Server) hotelFilter() *hotelFilter {
+	f, _ := s.bloom.Load().(*hotelFilter)
+	return f
+}
+
+// refreshBloom rebuilds the bloom filter every BloomRefresh, so that hotels
+// inserted since the last build are found, until ctx is done.
+func (s *Server) refreshBloom(ctx context.Context) {
+	ticker := time.NewTicker(s.BloomRefresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		buildCtx, cancel := context.WithTimeout(ctx, s.BloomRefresh)
+		if err := s.buildBloom(buildCtx); err != nil && ctx.Err() == nil {
+			log.Error().Msgf("Failed to rebuild the profile bloom filter, keeping the current one: %v", err)
+		}
+		cancel()
+	}
+}
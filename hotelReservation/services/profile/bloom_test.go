@@ -0,0 +1,124 @@
+package profile
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/store"
+)
+
+// lookupMemcache is a memcached recording the keys looked up.
+type lookupMemcache struct {
+	store.Memcache
+	mu      sync.Mutex
+	lookups map[string]int
+}
+
+func (m *lookupMemcache) GetMulti(keys []string) (map[string]*memcache.Item, error) {
+	m.mu.Lock()
+	for _, key := range keys {
+		m.lookups[key]++
+	}
+	m.mu.Unlock()
+	return m.Memcache.GetMulti(keys)
+}
+
+// newBloomServer returns a test server of hotelIds with its bloom filter
+// built, recording its memcached lookups and mongo queries.
+func newBloomServer(t *testing.T, hotelIds ...string) (*Server, *lookupMemcache, *countingDB) {
+	t.Helper()
+	s := newTestServer(t, hotelIds...)
+	s.BloomFPRate = 0.01
+	if err := s.buildBloom(context.Background()); err != nil {
+		t.Fatalf("buildBloom: %v", err)
+	}
+	memc := &lookupMemcache{Memcache: s.MemcClient, lookups: make(map[string]int)}
+	db := &countingDB{Database: s.DB}
+	s.MemcClient, s.DB = memc, db
+	return s, memc, db
+}
+
+func TestBloomShortCircuitsAbsentHotels(t *testing.T) {
+	s, memc, db := newBloomServer(t, "1", "2")
+	cacheProfile(t, s, "2", "Hotel 2")
+
+	// the first lookup goes on, the hotel could have been inserted since
+	got, span := getProfiles(t, s, "1", "absent")
+	if !equalIds(got, []string{"1"}) {
+		t.Fatalf("got hotels %v, want [1]", got)
+	}
+	if span.Tag("profile.bloom_absent") != 0 || memc.lookups["absent"] != 1 || db.finds != 1 {
+		t.Fatalf("bloom absent %v, %d lookups and %d queries, want 0, 1 and 1", span.Tag("profile.bloom_absent"), memc.lookups["absent"], db.finds)
+	}
+
+	// later ones stop at the filter
+	for i := 0; i < 3; i++ {
+		got, span = getProfiles(t, s, "absent", "2")
+		if !equalIds(got, []string{"2"}) {
+			t.Fatalf("got hotels %v, want [2]", got)
+		}
+		if span.Tag("profile.bloom_absent") != 1 || span.Tag("bloom") != "absent" {
+			t.Errorf("bloom absent %v, bloom %v, want 1 and absent", span.Tag("profile.bloom_absent"), span.Tag("bloom"))
+		}
+	}
+	if memc.lookups["absent"] != 1 {
+		t.Errorf("absent hotel looked up %d times in memcached, want once", memc.lookups["absent"])
+	}
+	if db.finds != 1 {
+		t.Errorf("mongo queried %d times, want once", db.finds)
+	}
+}
+
+func TestBloomPassesPresentHotels(t *testing.T) {
+	var ids []string
+	for i := 0; i < 200; i++ {
+		ids = append(ids, fmt.Sprintf("h%03d", i))
+	}
+	s, _, _ := newBloomServer(t, ids...)
+
+	for i := 0; i < 2; i++ {
+		got, span := getProfiles(t, s, ids...)
+		if !equalIds(got, ids) {
+			t.Fatalf("got %d of %d hotels", len(got), len(ids))
+		}
+		if span.Tag("profile.bloom_absent") != 0 || span.Tag("bloom") != nil {
+			t.Errorf("bloom absent %v, bloom %v, want 0 and no tag", span.Tag("profile.bloom_absent"), span.Tag("bloom"))
+		}
+	}
+}
+
+func TestBloomHotelsInsertedSince(t *testing.T) {
+	s, _, _ := newBloomServer(t, "1")
+	insertProfiles(t, s, "new")
+
+	// found by the lookup the filter lets through, then remembered
+	for i := 0; i < 2; i++ {
+		if got, _ := getProfiles(t, s, "new"); !equalIds(got, []string{"new"}) {
+			t.Fatalf("lookup %d: got hotels %v, want [new]", i, got)
+		}
+	}
+
+	// and part of the filter once rebuilt
+	if err := s.buildBloom(context.Background()); err != nil {
+		t.Fatalf("buildBloom: %v", err)
+	}
+	if !s.hotelFilter().filter.MayContain("new") {
+		t.Errorf("rebuilt filter rules out the hotel inserted since")
+	}
+}
+
+func TestBloomDisabled(t *testing.T) {
+	s := newTestServer(t, "1")
+	if s.hotelFilter() != nil {
+		t.Fatalf("got a filter without building one")
+	}
+	for i := 0; i < 2; i++ {
+		_, span := getProfiles(t, s, "absent")
+		if span.Tag("profile.bloom_absent") != 0 || span.Tag("profile.mongo_fallbacks") != 1 {
+			t.Errorf("bloom absent %v, mongo fallbacks %v, want 0 and 1", span.Tag("profile.bloom_absent"), span.Tag("profile.mongo_fallbacks"))
+		}
+	}
+}
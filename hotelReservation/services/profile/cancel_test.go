@@ -0,0 +1,124 @@
+package profile
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/profile/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/store"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// blockingDB is a database whose queries run until their context is done,
+// reporting when they start and the context error they end with.
+type blockingDB struct {
+	store.Database
+	started chan struct{}
+	ended   chan error
+}
+
+func newBlockingDB(db store.Database) *blockingDB {
+	return &blockingDB{Database: db, started: make(chan struct{}, 16), ended: make(chan error, 16)}
+}
+
+func (db *blockingDB) Collection(name string) store.Collection {
+	return blockingCollection{db.Database.Collection(name), db}
+}
+
+type blockingCollection struct {
+	store.Collection
+	db *blockingDB
+}
+
+func (c blockingCollection) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	c.db.started <- struct{}{}
+	<-ctx.Done()
+	c.db.ended <- ctx.Err()
+	return nil, ctx.Err()
+}
+
+func TestGetProfilesClientDisconnect(t *testing.T) {
+	s := newTestServer(t, "1", "2")
+	db := newBlockingDB(s.DB)
+	s.DB = db
+
+	span := mocktracer.New().StartSpan("GetProfiles").(*mocktracer.MockSpan)
+	ctx, cancel := context.WithCancel(opentracing.ContextWithSpan(context.Background(), span))
+	defer cancel()
+	info := &grpc.UnaryServerInfo{FullMethod: pb.Profile_GetProfiles_FullMethodName}
+	errs := make(chan error, 1)
+	go func() {
+		_, err := tracing.CancellationTaggingUnaryServerInterceptor(ctx, &pb.Request{HotelIds: []string{"1", "2"}}, info,
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return s.GetProfiles(ctx, req.(*pb.Request))
+			})
+		errs <- err
+	}()
+
+	select {
+	case <-db.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("profiles never queried")
+	}
+	cancel()
+
+	select {
+	case err := <-db.ended:
+		if err != context.Canceled {
+			t.Errorf("query ended with %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("query not cancelled")
+	}
+	select {
+	case err := <-errs:
+		if status.Code(err) != codes.Canceled {
+			t.Errorf("got %v, want Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("GetProfiles still running after the cancellation")
+	}
+	if span.Tag("cancelled") != true {
+		t.Errorf("cancelled = %v, want true", span.Tag("cancelled"))
+	}
+
+	// the aborted load caches nothing, not even tombstones
+	for _, id := range []string{"1", "2"} {
+		if item, err := s.MemcClient.Get(id); err == nil {
+			t.Errorf("cached %q for hotel %s after the cancellation", item.Value, id)
+		}
+	}
+
+	// and the next request loads the profiles afresh
+	s.DB = db.Database
+	if got, _ := getProfiles(t, s, "1", "2"); !equalIds(got, []string{"1", "2"}) {
+		t.Errorf("got hotels %v, want [1 2]", got)
+	}
+}
+
+func TestGetProfilesUntaggedFailure(t *testing.T) {
+	s := newTestServer(t)
+	s.DB = failingDB{}
+
+	span := mocktracer.New().StartSpan("GetProfiles").(*mocktracer.MockSpan)
+	ctx := opentracing.ContextWithSpan(context.Background(), span)
+	info := &grpc.UnaryServerInfo{FullMethod: pb.Profile_GetProfiles_FullMethodName}
+	_, err := tracing.CancellationTaggingUnaryServerInterceptor(ctx, &pb.Request{HotelIds: []string{"1"}}, info,
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return s.GetProfiles(ctx, req.(*pb.Request))
+		})
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("got %v, want Unavailable", err)
+	}
+	if tag := span.Tag("cancelled"); tag != nil {
+		t.Errorf("cancelled = %v on a failure of mongo, want no tag", tag)
+	}
+}
@@ -0,0 +1,24 @@
+package profile
+
+import (
+	"context"
+
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/profile/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/store"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// EvictCache deletes the cached profiles of the requested hotels, and the
+// tombstones of those without one, which share their key, so that the next
+// GetProfiles reads them from mongo. Hotels without a cache entry are
+// skipped, and only those with one are counted.
+func (s *Server) EvictCache(ctx context.Context, req *pb.EvictRequest) (*pb.EvictResult, error) {
+	evicted, err := store.Evict(s.MemcClient, req.HotelIds)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "evicted %d profiles, then failed: %v", evicted, err)
+	}
+	tracing.Logger(ctx).Info().Msgf("Evicted %d of %d cached profiles", evicted, len(req.HotelIds))
+	return &pb.EvictResult{Evicted: int32(evicted)}, nil
+}
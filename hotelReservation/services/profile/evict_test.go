@@ -0,0 +1,109 @@
+package profile
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/profile/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// evict evicts the cached profiles of hotelIds from s.
+func evict(t *testing.T, s *Server, hotelIds ...string) int32 {
+	t.Helper()
+	res, err := s.EvictCache(context.Background(), &pb.EvictRequest{HotelIds: hotelIds})
+	if err != nil {
+		t.Fatalf("EvictCache(%v): %v", hotelIds, err)
+	}
+	return res.Evicted
+}
+
+func TestEvictCacheReloads(t *testing.T) {
+	s := newTestServer(t, "1", "2")
+	db := &countingDB{Database: s.DB}
+	s.DB = db
+	getProfiles(t, s, "1", "2")
+	waitCached(t, s, "1")
+	waitCached(t, s, "2")
+	atomic.StoreInt32(&db.finds, 0)
+
+	if n := evict(t, s, "1"); n != 1 {
+		t.Errorf("evicted %d profiles, want 1", n)
+	}
+	got, span := getProfiles(t, s, "1", "2")
+	if !equalIds(got, []string{"1", "2"}) {
+		t.Errorf("got hotels %v, want [1 2]", got)
+	}
+	if finds := atomic.LoadInt32(&db.finds); finds != 1 {
+		t.Errorf("mongo queried %d times after the eviction, want 1", finds)
+	}
+	if span.Tag("profile.mongo_fallbacks") != 1 || span.Tag("profile.cache_hits") != 1 {
+		t.Errorf("mongo fallbacks %v, cache hits %v, want 1 each", span.Tag("profile.mongo_fallbacks"), span.Tag("profile.cache_hits"))
+	}
+}
+
+func TestEvictCacheTombstones(t *testing.T) {
+	s := newTestServer(t)
+	s.NegativeTTL = 60
+
+	getProfiles(t, s, "new")
+	if item := waitCached(t, s, "new"); string(item.Value) != tombstone {
+		t.Fatalf("cached %q for the miss, want the tombstone", item.Value)
+	}
+	insertProfiles(t, s, "new")
+	if got, _ := getProfiles(t, s, "new"); len(got) != 0 {
+		t.Fatalf("got hotels %v while the tombstone is cached, want none", got)
+	}
+
+	if n := evict(t, s, "new"); n != 1 {
+		t.Errorf("evicted %d profiles, want the tombstone", n)
+	}
+	if got, _ := getProfiles(t, s, "new"); !equalIds(got, []string{"new"}) {
+		t.Errorf("got hotels %v after the eviction, want [new]", got)
+	}
+}
+
+func TestEvictCacheAbsent(t *testing.T) {
+	s := newTestServer(t, "1")
+	getProfiles(t, s, "1")
+	waitCached(t, s, "1")
+
+	if n := evict(t, s, "1", "unknown"); n != 1 {
+		t.Errorf("evicted %d profiles, want 1", n)
+	}
+	// evicting again is harmless
+	if n := evict(t, s, "1", "unknown"); n != 0 {
+		t.Errorf("evicted %d profiles again, want 0", n)
+	}
+}
+
+func TestEvictCacheAdminOnly(t *testing.T) {
+	s := newTestServer(t, "1")
+	admin, err := tracing.AdminUnaryServerInterceptor([]string{"10.0.0.0/8"}, pb.Profile_EvictCache_FullMethodName)
+	if err != nil {
+		t.Fatalf("AdminUnaryServerInterceptor: %v", err)
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: pb.Profile_EvictCache_FullMethodName}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return s.EvictCache(ctx, req.(*pb.EvictRequest))
+	}
+
+	for _, tt := range []struct {
+		ip   string
+		want codes.Code
+	}{
+		{"192.168.1.5", codes.PermissionDenied},
+		{"10.1.2.3", codes.OK},
+	} {
+		ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(tt.ip), Port: 50000}})
+		if _, err := admin(ctx, &pb.EvictRequest{HotelIds: []string{"1"}}, info, handler); status.Code(err) != tt.want {
+			t.Errorf("caller %s: got %v, want %v", tt.ip, err, tt.want)
+		}
+	}
+}
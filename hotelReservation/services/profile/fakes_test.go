@@ -0,0 +1,145 @@
+package profile
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/profile/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mapMemcache is a memcached of a map, ignoring expirations.
+type mapMemcache struct {
+	mu    sync.Mutex
+	items map[string]*memcache.Item
+}
+
+func newMapMemcache() *mapMemcache {
+	return &mapMemcache{items: make(map[string]*memcache.Item)}
+}
+
+func (m *mapMemcache) Get(key string) (*memcache.Item, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item, ok := m.items[key]
+	if !ok {
+		return nil, memcache.ErrCacheMiss
+	}
+	return item, nil
+}
+
+func (m *mapMemcache) GetMulti(keys []string) (map[string]*memcache.Item, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	items := make(map[string]*memcache.Item)
+	for _, key := range keys {
+		if item, ok := m.items[key]; ok {
+			items[key] = item
+		}
+	}
+	return items, nil
+}
+
+func (m *mapMemcache) Set(item *memcache.Item) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.items[item.Key] = item
+	return nil
+}
+
+func (m *mapMemcache) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.items[key]; !ok {
+		return memcache.ErrCacheMiss
+	}
+	delete(m.items, key)
+	return nil
+}
+
+func (m *mapMemcache) Ping() error { return nil }
+
+// docDB is a database of documents in memory, whose hotels collection is
+// queried by ID.
+type docDB []bson.M
+
+func (db docDB) Collection(name string) store.Collection { return docCollection{docs: db} }
+
+type docCollection struct {
+	store.Collection
+	docs []bson.M
+}
+
+// Find returns the documents whose id is in the $in of filter, or all
+// documents for other filters.
+func (c docCollection) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	var ids map[string]bool
+	if f, ok := filter.(bson.M); ok {
+		if in, ok := f["id"].(bson.M); ok {
+			ids = make(map[string]bool)
+			for _, id := range in["$in"].([]string) {
+				ids[id] = true
+			}
+		}
+	}
+	var found []interface{}
+	for _, doc := range c.docs {
+		if ids == nil || ids[doc["id"].(string)] {
+			found = append(found, doc)
+		}
+	}
+	return mongo.NewCursorFromDocuments(found, nil, nil)
+}
+
+func TestServerOfFakes(t *testing.T) {
+	memc := newMapMemcache()
+	s := &Server{
+		DB: docDB{
+			{"id": "1", "name": "Clift Hotel", "address": bson.M{"city": "San Francisco"}},
+			{"id": "2", "name": "W San Francisco", "address": bson.M{"city": "San Francisco"}},
+		},
+		MemcClient:  memc,
+		MongoFanout: 1,
+	}
+
+	res, err := s.GetProfiles(context.Background(), &pb.Request{HotelIds: []string{"1", "2", "3"}})
+	if err != nil {
+		t.Fatalf("GetProfiles: %v", err)
+	}
+	if got := idsOf(res.Hotels); !equalIds(got, []string{"1", "2"}) {
+		t.Fatalf("got hotels %v, want [1 2]", got)
+	}
+	for _, h := range res.Hotels {
+		if h.Id == "1" && (h.Name != "Clift Hotel" || h.Address.GetCity() != "San Francisco") {
+			t.Errorf("got hotel %v, want the Clift Hotel of the database", h)
+		}
+	}
+
+	// the profiles found are cached, and served from the cache from then on
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, err1 := memc.Get("1")
+		_, err2 := memc.Get("2")
+		if err1 == nil && err2 == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("profiles never cached")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.DB = failingDB{}
+	res, err = s.GetProfiles(context.Background(), &pb.Request{HotelIds: []string{"2", "1"}})
+	if err != nil {
+		t.Fatalf("GetProfiles with mongo down: %v", err)
+	}
+	if got := idsOf(res.Hotels); !equalIds(got, []string{"2", "1"}) {
+		t.Errorf("got cached hotels %v, want [2 1]", got)
+	}
+}
@@ -0,0 +1,81 @@
+package profile
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/store"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// peakDB is a database recording the most queries of its hotels running at
+// once, each taking a while.
+type peakDB struct {
+	store.Database
+	running, peak int32
+}
+
+func (db *peakDB) Collection(name string) store.Collection {
+	return peakCollection{db.Database.Collection(name), db}
+}
+
+type peakCollection struct {
+	store.Collection
+	db *peakDB
+}
+
+func (c peakCollection) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	n := atomic.AddInt32(&c.db.running, 1)
+	defer atomic.AddInt32(&c.db.running, -1)
+	for {
+		peak := atomic.LoadInt32(&c.db.peak)
+		if n <= peak || atomic.CompareAndSwapInt32(&c.db.peak, peak, n) {
+			break
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	return c.Collection.Find(ctx, filter, opts...)
+}
+
+func TestGetProfilesFanoutBounded(t *testing.T) {
+	// in reverse order, so that the batches don't complete in input order
+	var ids []string
+	for i := 6*mongoBatchSize - 1; i >= 0; i-- {
+		ids = append(ids, fmt.Sprintf("h%03d", i))
+	}
+	for _, fanout := range []int{1, 2, 4} {
+		s := newTestServer(t, ids...)
+		s.MongoFanout = fanout
+		db := &peakDB{Database: s.DB}
+		s.DB = db
+
+		got, span := getProfiles(t, s, ids...)
+		if !equalIds(got, ids) {
+			t.Errorf("fan-out %d: got hotels %v, want %v", fanout, got, ids)
+		}
+		if db.peak > int32(fanout) {
+			t.Errorf("fan-out %d: %d queries ran at once", fanout, db.peak)
+		}
+		if span.Tag("profile.fanout_width") != fanout {
+			t.Errorf("fan-out %d: profile.fanout_width = %v", fanout, span.Tag("profile.fanout_width"))
+		}
+		if span.Tag("profile.fanout_misses") != len(ids) {
+			t.Errorf("fan-out %d: profile.fanout_misses = %v, want %d", fanout, span.Tag("profile.fanout_misses"), len(ids))
+		}
+	}
+}
+
+func TestGetProfilesFanoutFewMisses(t *testing.T) {
+	s := newTestServer(t, "1", "2")
+	s.MongoFanout = 8
+	cacheProfile(t, s, "1", "Cached 1")
+
+	_, span := getProfiles(t, s, "1", "2")
+	if span.Tag("profile.fanout_width") != 1 || span.Tag("profile.fanout_misses") != 1 {
+		t.Errorf("fan-out width %v of %v misses, want 1 of 1", span.Tag("profile.fanout_width"), span.Tag("profile.fanout_misses"))
+	}
+}
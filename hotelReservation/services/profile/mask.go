@@ -0,0 +1,88 @@
+package profile
+
+import (
+	"strings"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails"
+	errpb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails/proto"
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/profile/proto"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// fieldMask holds the fields of a message to keep by name, with the mask of
+// their own fields, nil to keep them whole.
+type fieldMask map[protoreflect.Name]fieldMask
+
+// newFieldMask returns the mask of the hotel fields of paths, nil if there
+// are none. The id is always kept. Unknown fields and paths through fields
+// that aren't messages are rejected with InvalidArgument.
+func newFieldMask(paths []string) (fieldMask, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	mask := fieldMask{"id": nil}
+	for _, path := range paths {
+		desc := (&pb.Hotel{}).ProtoReflect().Descriptor()
+		node := mask
+		names := strings.Split(path, ".")
+		for i, name := range names {
+			fd := desc.Fields().ByName(protoreflect.Name(name))
+			if fd == nil {
+				return nil, errdetails.InvalidArgument(errpb.ErrorDetail_UNSUPPORTED, "fieldMask", "unknown field %q in field mask path %q", name, path)
+			}
+			sub, seen := node[fd.Name()]
+			if i == len(names)-1 {
+				node[fd.Name()] = nil
+				break
+			}
+			if fd.Message() == nil {
+				return nil, errdetails.InvalidArgument(errpb.ErrorDetail_UNSUPPORTED, "fieldMask", "field %q in field mask path %q has no fields", name, path)
+			}
+			if seen && sub == nil {
+				// already kept whole
+				break
+			}
+			if sub == nil {
+				sub = fieldMask{}
+				node[fd.Name()] = sub
+			}
+			desc, node = fd.Message(), sub
+		}
+	}
+	return mask, nil
+}
+
+// apply returns a copy of h with only the fields of m set, h itself if m is
+// nil. Hotels may be shared with concurrent requests, so h is left as is.
+func (m fieldMask) apply(h *pb.Hotel) *pb.Hotel {
+	if m == nil {
+		return h
+	}
+	masked := proto.Clone(h).(*pb.Hotel)
+	m.clear(masked.ProtoReflect())
+	return masked
+}
+
+// clear clears the fields of msg that m doesn't keep, recursively.
+func (m fieldMask) clear(msg protoreflect.Message) {
+	var cleared []protoreflect.FieldDescriptor
+	msg.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		sub, ok := m[fd.Name()]
+		switch {
+		case !ok:
+			cleared = append(cleared, fd)
+		case sub == nil:
+		case fd.IsList():
+			for i := 0; i < v.List().Len(); i++ {
+				sub.clear(v.List().Get(i).Message())
+			}
+		default:
+			sub.clear(v.Message())
+		}
+		return true
+	})
+	for _, fd := range cleared {
+		msg.Clear(fd)
+	}
+}
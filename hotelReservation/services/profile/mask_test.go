@@ -0,0 +1,99 @@
+package profile
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/profile/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// fullHotel returns a hotel with every field set.
+func fullHotel() *pb.Hotel {
+	return &pb.Hotel{
+		Id:          "1",
+		Name:        "Hotel 1",
+		PhoneNumber: "555-0100",
+		Description: "By the bay",
+		Address:     &pb.Address{StreetNumber: "1", StreetName: "Market St", City: "San Francisco", Lat: 37.7, Lon: -122.4},
+		Images:      []*pb.Image{{Url: "a.jpg", Default: true}, {Url: "b.jpg"}},
+		Stars:       4,
+	}
+}
+
+func TestFieldMask(t *testing.T) {
+	for _, tt := range []struct {
+		paths []string
+		want  *pb.Hotel
+	}{
+		{nil, fullHotel()},
+		{[]string{"name", "stars"}, &pb.Hotel{Id: "1", Name: "Hotel 1", Stars: 4}},
+		{[]string{"address.city"}, &pb.Hotel{Id: "1", Address: &pb.Address{City: "San Francisco"}}},
+		{[]string{"address.city", "address"}, &pb.Hotel{Id: "1", Address: fullHotel().Address}},
+		{[]string{"address", "address.city"}, &pb.Hotel{Id: "1", Address: fullHotel().Address}},
+		{[]string{"images.url"}, &pb.Hotel{Id: "1", Images: []*pb.Image{{Url: "a.jpg"}, {Url: "b.jpg"}}}},
+		{[]string{"description"}, &pb.Hotel{Id: "1", Description: "By the bay"}},
+	} {
+		mask, err := newFieldMask(tt.paths)
+		if err != nil {
+			t.Errorf("newFieldMask(%q): %v", tt.paths, err)
+			continue
+		}
+		hotel := fullHotel()
+		if got := mask.apply(hotel); !proto.Equal(got, tt.want) {
+			t.Errorf("mask %q: got %v, want %v", tt.paths, got, tt.want)
+		}
+		if !proto.Equal(hotel, fullHotel()) {
+			t.Errorf("mask %q changed the hotel masked to %v", tt.paths, hotel)
+		}
+	}
+}
+
+func TestFieldMaskInvalid(t *testing.T) {
+	for _, paths := range [][]string{
+		{"rating"},
+		{"name", "Name"},
+		{"address.zip"},
+		{"name.first"},
+		{""},
+	} {
+		if _, err := newFieldMask(paths); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("newFieldMask(%q): got %v, want InvalidArgument", paths, err)
+		}
+	}
+}
+
+func TestGetProfilesFieldMask(t *testing.T) {
+	s := newTestServer(t, "1", "2")
+	cacheProfile(t, s, "1", "Cached 1")
+
+	res, err := s.GetProfiles(context.Background(), &pb.Request{HotelIds: []string{"1", "2"}, FieldMask: []string{"name"}})
+	if err != nil {
+		t.Fatalf("GetProfiles: %v", err)
+	}
+	want := []*pb.Hotel{{Id: "1", Name: "Cached 1"}, {Id: "2", Name: "Hotel 2"}}
+	if len(res.Hotels) != len(want) {
+		t.Fatalf("got hotels %v, want %v", res.Hotels, want)
+	}
+	for i, h := range res.Hotels {
+		if !proto.Equal(h, want[i]) {
+			t.Errorf("got hotel %v, want %v", h, want[i])
+		}
+	}
+
+	// the hotels masked for the first request are whole for the next
+	res, err = s.GetProfiles(context.Background(), &pb.Request{HotelIds: []string{"2"}})
+	if err != nil {
+		t.Fatalf("GetProfiles: %v", err)
+	}
+	if len(res.Hotels) != 1 || res.Hotels[0].Address.GetCity() != "San Francisco" || res.Hotels[0].PhoneNumber == "" {
+		t.Errorf("got hotels %v without a mask, want the full profile", res.Hotels)
+	}
+
+	_, err = s.GetProfiles(context.Background(), &pb.Request{HotelIds: []string{"1"}, FieldMask: []string{"name", "rating"}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("GetProfiles with an unknown field: got %v, want InvalidArgument", err)
+	}
+}
@@ -20,6 +20,7 @@ const _ = grpc.SupportPackageIsVersion7
 
 const (
 	Profile_GetProfiles_FullMethodName = "/profile.Profile/GetProfiles"
+	Profile_EvictCache_FullMethodName  = "/profile.Profile/EvictCache"
 )
 
 // ProfileClient is the client API for Profile service.
@@ -27,6 +28,9 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type ProfileClient interface {
 	GetProfiles(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Result, error)
+	// Deletes the cached profiles of hotels, and their cached misses, so that
+	// they are read from the database again. Admin only.
+	EvictCache(ctx context.Context, in *EvictRequest, opts ...grpc.CallOption) (*EvictResult, error)
 }
 
 type profileClient struct {
@@ -46,11 +50,23 @@ func (c *profileClient) GetProfiles(ctx context.Context, in *Request, opts ...gr
 	return out, nil
 }
 
+func (c *profileClient) EvictCache(ctx context.Context, in *EvictRequest, opts ...grpc.CallOption) (*EvictResult, error) {
+	out := new(EvictResult)
+	err := c.cc.Invoke(ctx, Profile_EvictCache_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ProfileServer is the server API for Profile service.
 // All implementations must embed UnimplementedProfileServer
 // for forward compatibility
 type ProfileServer interface {
 	GetProfiles(context.Context, *Request) (*Result, error)
+	// Deletes the cached profiles of hotels, and their cached misses, so that
+	// they are read from the database again. Admin only.
+	EvictCache(context.Context, *EvictRequest) (*EvictResult, error)
 	mustEmbedUnimplementedProfileServer()
 }
 
@@ -61,6 +77,9 @@ type UnimplementedProfileServer struct {
 func (UnimplementedProfileServer) GetProfiles(context.Context, *Request) (*Result, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetProfiles not implemented")
 }
+func (UnimplementedProfileServer) EvictCache(context.Context, *EvictRequest) (*EvictResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EvictCache not implemented")
+}
 func (UnimplementedProfileServer) mustEmbedUnimplementedProfileServer() {}
 
 // UnsafeProfileServer may be embedded to opt out of forward compatibility for this service.
@@ -92,6 +111,24 @@ func _Profile_GetProfiles_Handler(srv interface{}, ctx context.Context, dec func
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Profile_EvictCache_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EvictRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProfileServer).EvictCache(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Profile_EvictCache_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProfileServer).EvictCache(ctx, req.(*EvictRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Profile_ServiceDesc is the grpc.ServiceDesc for Profile service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -103,6 +140,10 @@ var Profile_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetProfiles",
 			Handler:    _Profile_GetProfiles_Handler,
 		},
+		{
+			MethodName: "EvictCache",
+			Handler:    _Profile_EvictCache_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "services/profile/proto/profile.proto",
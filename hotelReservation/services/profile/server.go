@@ -3,63 +3,154 @@ package profile
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
-	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/coalesce"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/fanout"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/graceful"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/healthcheck"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/memcring"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/registry"
 	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/profile/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/store"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tls"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/warmup"
 	"github.com/google/uuid"
-	"github.com/grpc-ecosystem/grpc-opentracing/go/otgrpc"
 	"github.com/opentracing/opentracing-go"
 	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 )
 
 const name = "srv-profile"
 
+// tombstone is cached in place of a profile for hotel IDs that have none.
+const tombstone = "\x00no-profile"
+
+// mongoBatchSize is the most hotels missing from the cache read from mongo by
+// a single query.
+const mongoBatchSize = 50
+
+// validators check requests before their handler runs.
+var validators = map[string]tracing.Validator{
+	pb.Profile_EvictCache_FullMethodName: tracing.Required("hotelIds"),
+}
+
 // Server implements the profile service
 type Server struct {
 	pb.UnimplementedProfileServer
 
-	uuid string
+	uuid       string
+	health     *healthcheck.Checker
+	grpcServer *grpc.Server
+	traffic    tracing.MetricsMark
+	// flights collapses concurrent mongo loads of the same profiles.
+	flights coalesce.Group
+	// bloom holds the *hotelFilter of the hotel IDs, if built.
+	bloom     atomic.Value
+	stopBloom context.CancelFunc
 
 	Tracer      opentracing.Tracer
 	Port        int
 	IpAddr      string
 	MongoClient *mongo.Client
-	Registry    *registry.Client
-	MemcClient  *memcache.Client
+	// DB is the profile-db database, that of MongoClient if nil.
+	DB         store.Database
+	Registry   *registry.Client
+	MemcClient store.Memcache
+	// MemcTTL is the expiration in seconds of cached profiles, zero for none.
+	MemcTTL int32
+	// NegativeTTL is the expiration in seconds of cached misses. Zero
+	// disables negative caching.
+	NegativeTTL int32
+	// Warmup configures the caching of every profile at startup, before
+	// the server reports SERVING and registers in Consul.
+	Warmup warmup.Config
+	// MongoFanout is how many batches of hotels missing from the cache are
+	// read from mongo at once, 1 if less.
+	MongoFanout int
+	// BloomFPRate is the false positive rate of the bloom filter of hotel
+	// IDs that lookups of hotels without a profile stop at, once one lookup
+	// of the hotel since the filter was built found no profile. Zero
+	// disables the filter.
+	BloomFPRate float64
+	// BloomRefresh is how often the bloom filter is rebuilt to add the
+	// hotels inserted since, zero to only build it at startup.
+	BloomRefresh time.Duration
+	// MetricsPort is the admin port serving /metrics, zero to disable it.
+	MetricsPort int
+	// AdminAllowlist are the IPs and CIDRs of the callers allowed to call
+	// EvictCache.
+	AdminAllowlist []string
 }
 
 // Run starts the server
 func (s *Server) Run() error {
-	opentracing.SetGlobalTracer(s.Tracer)
-
 	if s.Port == 0 {
 		return fmt.Errorf("server port must be set")
 	}
 
+	if s.DB == nil {
+		s.DB = store.MongoDatabase(s.MongoClient.Database("profile-db"))
+	}
+
+	admin, err := tracing.AdminUnaryServerInterceptor(s.AdminAllowlist, pb.Profile_EvictCache_FullMethodName)
+	if err != nil {
+		return err
+	}
+
 	s.uuid = uuid.New().String()
 
+	if s.BloomFPRate > 0 {
+		// Without a filter every lookup goes on to memcached and mongo.
+		if err := s.buildBloom(context.Background()); err != nil {
+			log.Warn().Msgf("Failed to build the profile bloom filter, looking every hotel up: %v", err)
+		}
+		if s.BloomRefresh > 0 {
+			ctx, cancel := context.WithCancel(context.Background())
+			s.stopBloom = cancel
+			go s.refreshBloom(ctx)
+		}
+	}
+
 	log.Trace().Msgf("in run s.IpAddr = %s, port = %d", s.IpAddr, s.Port)
 
+	shed, limiter := tracing.ConcurrencyLimitUnaryServerInterceptor(tune.GetMaxInflight())
+	tracing.DefaultMetrics.RegisterGauge("grpc_server_inflight_requests", "Number of requests being handled.", func() uint64 {
+		return uint64(limiter.InFlight())
+	})
+
 	opts := []grpc.ServerOption{
 		grpc.KeepaliveParams(keepalive.ServerParameters{
 			Timeout: 120 * time.Second,
 		}),
 		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             time.Duration(tune.GetKeepaliveTime()) * time.Millisecond,
 			PermitWithoutStream: true,
 		}),
-		grpc.UnaryInterceptor(
-			otgrpc.OpenTracingServerInterceptor(s.Tracer),
-		),
+		grpc.MaxRecvMsgSize(tune.GetMaxRecvMsgSize()),
+		grpc.MaxSendMsgSize(tune.GetMaxSendMsgSize()),
+		grpc.UnaryInterceptor(tracing.DefaultServerInterceptorChain(tracing.ServerChainOptions{
+			Tracer: s.Tracer,
+			Interceptors: []grpc.UnaryServerInterceptor{
+				shed,
+				tracing.ChaosUnaryServerInterceptor(tracing.DefaultChaos),
+				admin,
+				tracing.ValidationUnaryServerInterceptor(validators),
+			},
+		})),
 	}
 
 	if tlsopt := tls.GetServerOpt(); tlsopt != nil {
@@ -67,99 +158,307 @@ func (s *Server) Run() error {
 	}
 
 	srv := grpc.NewServer(opts...)
+	s.grpcServer = srv
+	s.traffic = tracing.DefaultMetrics.Mark()
 
 	pb.RegisterProfileServer(srv, s)
+	if tune.GetGrpcReflection() {
+		reflection.Register(srv)
+	}
+
+	probes := []healthcheck.Probe{healthcheck.Mongo(s.MongoClient), healthcheck.Memcached(s.MemcClient)}
+	warm := healthcheck.NewGate("warmup")
+	if s.Warmup.Enabled() {
+		probes = append(probes, warm.Probe())
+	}
+	s.health = healthcheck.Register(srv, pb.Profile_ServiceDesc.ServiceName, probes...)
+	s.health.Start()
+
+	tracing.DefaultMetrics.RegisterCounter("memcached_hits_total", "Total number of keys found in memcached.", memcring.DefaultStats.Hits)
+	tracing.DefaultMetrics.RegisterCounter("memcached_misses_total", "Total number of keys not found in memcached.", memcring.DefaultStats.Misses)
+	tracing.DefaultMetrics.RegisterCounter("memcached_errors_total", "Total number of failed memcached lookups.", memcring.DefaultStats.Errors)
+	if s.MetricsPort != 0 {
+		go tracing.ServeMetrics(s.MetricsPort, tracing.DefaultMetrics)
+	}
+	if path := tune.GetFeatureFlagsFile(); path != "" {
+		go tracing.DefaultFlags.WatchFile(path, time.Duration(tune.GetFeatureFlagsPollInterval())*time.Millisecond)
+	}
 
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", s.Port))
 	if err != nil {
 		log.Fatal().Msgf("failed to configure listener: %v", err)
 	}
 
+	// Serve while warming up, so that health checks get NOT_SERVING rather
+	// than no answer.
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(lis) }()
+	if err := s.warmUp(warm); err != nil {
+		srv.Stop()
+		return err
+	}
+
 	err = s.Registry.Register(name, s.uuid, s.IpAddr, s.Port)
 	if err != nil {
 		return fmt.Errorf("failed register: %v", err)
 	}
 	log.Info().Msg("Successfully registered in consul")
 
-	return srv.Serve(lis)
+	return <-served
 }
 
-// Shutdown cleans up any processes
+// Shutdown reports the server NOT_SERVING, deregisters it and drains
+// in-flight RPCs before stopping it, then reports the traffic it served.
 func (s *Server) Shutdown() {
+	if s.stopBloom != nil {
+		s.stopBloom()
+	}
+	s.health.Shutdown()
 	s.Registry.Deregister(s.uuid)
+	graceful.Stop(s.grpcServer)
+	graceful.Report(s.grpcServer, s.traffic)
 }
 
-// GetProfiles returns hotel profiles for requested IDs
+// GetProfiles returns hotel profiles for requested IDs, in the order of the
+// request, with only the fields of req.FieldMask if any. Duplicate IDs are
+// returned once and IDs without a profile are omitted, without looking up
+// those the bloom filter rules out. Cache misses are fetched from mongo in
+// batches.
 func (s *Server) GetProfiles(ctx context.Context, req *pb.Request) (*pb.Result, error) {
 	log.Trace().Msgf("In GetProfiles")
 
-	var wg sync.WaitGroup
-	var mutex sync.Mutex
+	mask, err := newFieldMask(req.FieldMask)
+	if err != nil {
+		return nil, err
+	}
 
 	// one hotel should only have one profile
-	hotelIds := make([]string, 0)
-	profileMap := make(map[string]struct{})
+	hotelIds := make([]string, 0, len(req.HotelIds))
+	seen := make(map[string]struct{}, len(req.HotelIds))
 	for _, hotelId := range req.HotelIds {
+		if _, ok := seen[hotelId]; ok {
+			continue
+		}
+		seen[hotelId] = struct{}{}
 		hotelIds = append(hotelIds, hotelId)
-		profileMap[hotelId] = struct{}{}
 	}
 
+	// hotels the bloom filter rules out have no profile, nor lookups, once
+	// a lookup since the filter was built confirmed it
+	filter := s.hotelFilter()
+	lookupIds := make([]string, 0, len(hotelIds))
+	var unverified []string
+	for _, hotelId := range hotelIds {
+		if filter == nil {
+			lookupIds = append(lookupIds, hotelId)
+			continue
+		}
+		ruledOut, verified := filter.ruledOut(hotelId)
+		if ruledOut {
+			log.Trace().Msgf("bloom filter rules out hotelId = %s", hotelId)
+			continue
+		}
+		if !verified {
+			unverified = append(unverified, hotelId)
+		}
+		lookupIds = append(lookupIds, hotelId)
+	}
+	bloomAbsent := len(hotelIds) - len(lookupIds)
+
 	memSpan, _ := opentracing.StartSpanFromContext(ctx, "memcached_get_profile")
 	memSpan.SetTag("span.kind", "client")
-	resMap, err := s.MemcClient.GetMulti(hotelIds)
+	resMap, err := s.MemcClient.GetMulti(lookupIds)
 	memSpan.Finish()
-
-	res := new(pb.Result)
-	hotels := make([]*pb.Hotel, 0)
+	memcring.DefaultStats.RecordMulti(len(lookupIds), len(resMap), err)
 
 	if err != nil && err != memcache.ErrCacheMiss {
-		log.Panic().Msgf("Tried to get hotelIds [%v], but got memmcached error = %s", hotelIds, err)
-	} else {
-		for hotelId, item := range resMap {
-			profileStr := string(item.Value)
-			log.Trace().Msgf("memc hit with %v", profileStr)
+		// the profiles held by a failing cache node are read from mongo
+		log.Warn().Msgf("Tried to get hotelIds [%v], but got memmcached error = %s", lookupIds, err)
+	}
 
-			hotelProf := new(pb.Hotel)
-			json.Unmarshal(item.Value, hotelProf)
-			hotels = append(hotels, hotelProf)
-			delete(profileMap, hotelId)
+	profiles := make(map[string]*pb.Hotel, len(lookupIds))
+	negativeHits := make(map[string]struct{})
+	for hotelId, item := range resMap {
+		if string(item.Value) == tombstone {
+			log.Trace().Msgf("memc negative hit for hotelId = %s", hotelId)
+			negativeHits[hotelId] = struct{}{}
+			continue
 		}
+		log.Trace().Msgf("memc hit with %v", string(item.Value))
 
-		wg.Add(len(profileMap))
-		for hotelId := range profileMap {
-			go func(hotelId string) {
-				var hotelProf *pb.Hotel
-
-				collection := s.MongoClient.Database("profile-db").Collection("hotels")
-
-				mongoSpan, _ := opentracing.StartSpanFromContext(ctx, "mongo_profile")
-				mongoSpan.SetTag("span.kind", "client")
-				err := collection.FindOne(context.TODO(), bson.D{{"id", hotelId}}).Decode(&hotelProf)
-				mongoSpan.Finish()
+		hotelProf := new(pb.Hotel)
+		if err := json.Unmarshal(item.Value, hotelProf); err != nil {
+			log.Error().Msgf("Failed to unmarshal hotel [id: %v] with err: %v", hotelId, err)
+			continue
+		}
+		profiles[hotelId] = hotelProf
+	}
+	cacheHits := len(profiles)
+
+	missIds := make([]string, 0, len(lookupIds)-cacheHits-len(negativeHits))
+	for _, hotelId := range lookupIds {
+		_, found := profiles[hotelId]
+		_, missing := negativeHits[hotelId]
+		if !found && !missing {
+			missIds = append(missIds, hotelId)
+		}
+	}
 
-				if err != nil {
-					log.Error().Msgf("Failed get hotels data: ", err)
-				}
+	// the misses are read in batches, at most s.MongoFanout at once
+	batches := (len(missIds) + mongoBatchSize - 1) / mongoBatchSize
+	loaded := make([]map[string]interface{}, batches)
+	loadErrs := make([]error, batches)
+	fanout.Each(batches, s.MongoFanout, func(i int) {
+		batch := missIds[i*mongoBatchSize:]
+		if len(batch) > mongoBatchSize {
+			batch = batch[:mongoBatchSize]
+		}
+		load := func(ids []string) (map[string]interface{}, error) {
+			return s.loadMongoProfiles(ctx, ids)
+		}
+		var err error
+		loaded[i], err = s.flights.Load(batch, load)
+		if errors.Is(err, context.Canceled) && ctx.Err() == nil {
+			// Some loads were led by another request, cancelled since.
+			loaded[i], err = s.flights.Load(batch, load)
+		}
+		if err != nil {
+			log.Error().Msgf("Failed get hotels data [ids: %v]: %v", batch, err)
+			loadErrs[i] = err
+		}
+	})
+	for _, err := range loadErrs {
+		if err != nil {
+			return nil, store.Error(ctx, err, codes.Unavailable, "failed to get hotel profiles")
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	mongoHits := 0
+	for i, hotelId := range missIds {
+		if hotelProf, ok := loaded[i/mongoBatchSize][hotelId]; ok {
+			profiles[hotelId] = hotelProf.(*pb.Hotel)
+			mongoHits++
+		}
+	}
 
-				mutex.Lock()
-				hotels = append(hotels, hotelProf)
-				mutex.Unlock()
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		span.SetTag("profile.cache_hits", cacheHits)
+		span.SetTag("profile.mongo_fallbacks", len(missIds))
+		span.SetTag("profile.mongo_hits", mongoHits)
+		span.SetTag("profile.negative_hits", len(negativeHits))
+		span.SetTag("profile.fanout_width", fanout.Width(batches, s.MongoFanout))
+		span.SetTag("profile.fanout_misses", len(missIds))
+		span.SetTag("profile.bloom_absent", bloomAbsent)
+		if bloomAbsent > 0 {
+			span.SetTag("bloom", "absent")
+		}
+	}
 
-				profJson, err := json.Marshal(hotelProf)
-				if err != nil {
-					log.Error().Msgf("Failed to marshal hotel [id: %v] with err:", hotelProf.Id, err)
-				}
-				memcStr := string(profJson)
+	for _, hotelId := range unverified {
+		_, found := profiles[hotelId]
+		filter.verify(hotelId, found)
+	}
 
-				// write to memcached
-				go s.MemcClient.Set(&memcache.Item{Key: hotelId, Value: []byte(memcStr)})
-				defer wg.Done()
-			}(hotelId)
+	res := new(pb.Result)
+	res.Hotels = make([]*pb.Hotel, 0, len(profiles))
+	for _, hotelId := range hotelIds {
+		if hotelProf, ok := profiles[hotelId]; ok {
+			res.Hotels = append(res.Hotels, mask.apply(hotelProf))
 		}
 	}
-	wg.Wait()
 
-	res.Hotels = hotels
 	log.Trace().Msgf("In GetProfiles after getting resp")
 	return res, nil
 }
+
+// loadMongoProfiles fetches the profiles of hotelIds from mongo and caches
+// them, along with tombstones for the hotels without a profile. It runs once
+// per hotel for concurrent misses, see Server.flights.
+func (s *Server) loadMongoProfiles(ctx context.Context, hotelIds []string) (map[string]interface{}, error) {
+	hotels, err := s.getMongoProfiles(ctx, hotelIds)
+	if err != nil {
+		return nil, err
+	}
+
+	loaded := make(map[string]interface{}, len(hotels))
+	for _, hotelProf := range hotels {
+		loaded[hotelProf.Id] = hotelProf
+
+		// write to memcached
+		go func(h *pb.Hotel) {
+			if err := s.cacheProfile(h); err != nil {
+				log.Error().Msgf("Failed to cache hotel [id: %v] with err: %v", h.Id, err)
+			}
+		}(hotelProf)
+	}
+
+	// Only cache misses of a successful query.
+	if s.NegativeTTL > 0 {
+		for _, hotelId := range hotelIds {
+			if _, ok := loaded[hotelId]; !ok {
+				go s.MemcClient.Set(&memcache.Item{Key: hotelId, Value: []byte(tombstone), Expiration: s.NegativeTTL})
+			}
+		}
+	}
+	return loaded, nil
+}
+
+// cacheProfile writes the profile of a hotel to memcached.
+func (s *Server) cacheProfile(h *pb.Hotel) error {
+	profJson, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	return s.MemcClient.Set(&memcache.Item{Key: h.Id, Value: profJson, Expiration: s.MemcTTL})
+}
+
+// warmUp warms the cache up as configured by Warmup, then opens warm and
+// has the health checker report the server SERVING unless a dependency is
+// down. It fails if warming up fails in strict mode, leaving warm closed.
+func (s *Server) warmUp(warm *healthcheck.Gate) error {
+	if err := s.Warmup.Run("profiles", s.warmup); err != nil {
+		return err
+	}
+	warm.Open()
+	s.health.Check()
+	return nil
+}
+
+// warmup caches every profile, with at most concurrency writes at once.
+func (s *Server) warmup(concurrency int) (int, error) {
+	var hotels []*pb.Hotel
+	curr, err := s.DB.Collection("hotels").Find(context.TODO(), bson.M{})
+	if err != nil {
+		return 0, err
+	}
+	if err := curr.All(context.TODO(), &hotels); err != nil {
+		return 0, err
+	}
+	errs := make([]error, len(hotels))
+	fanout.Each(len(hotels), concurrency, func(i int) {
+		errs[i] = s.cacheProfile(hotels[i])
+	})
+	return warmup.Tally(errs)
+}
+
+// getMongoProfiles fetches the profiles of hotelIds from mongo in one query,
+// aborting if ctx is done.
+func (s *Server) getMongoProfiles(ctx context.Context, hotelIds []string) ([]*pb.Hotel, error) {
+	collection := s.DB.Collection("hotels")
+
+	mongoSpan, _ := opentracing.StartSpanFromContext(ctx, "mongo_profile")
+	mongoSpan.SetTag("span.kind", "client")
+	defer mongoSpan.Finish()
+
+	var hotels []*pb.Hotel
+	curr, err := collection.Find(ctx, bson.M{"id": bson.M{"$in": hotelIds}})
+	if err != nil {
+		return nil, err
+	}
+	if err := curr.All(ctx, &hotels); err != nil {
+		return nil, err
+	}
+	return hotels, nil
+}
@@ -0,0 +1,290 @@
+package profile
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/inproc/fakestore"
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/profile/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/store"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newTestServer returns a server backed by stand-ins of MongoDB and
+// memcached, with a profile for each of hotelIds in the database.
+func newTestServer(t *testing.T, hotelIds ...string) *Server {
+	t.Helper()
+	s := &Server{
+		DB:          store.MongoDatabase(fakestore.Mongo(t).Database("profile-db")),
+		MemcClient:  fakestore.Memcached(t),
+		MongoFanout: 1,
+	}
+	insertProfiles(t, s, hotelIds...)
+	return s
+}
+
+// insertProfiles adds a profile for each of hotelIds to the database of s.
+func insertProfiles(t *testing.T, s *Server, hotelIds ...string) {
+	t.Helper()
+	for _, id := range hotelIds {
+		_, err := s.DB.Collection("hotels").InsertOne(context.Background(), bson.M{
+			"id":          id,
+			"name":        "Hotel " + id,
+			"phoneNumber": "555-0100",
+			"address":     bson.M{"city": "San Francisco", "lat": float32(37.7), "lon": float32(-122.4)},
+			"stars":       float32(4),
+		})
+		if err != nil {
+			t.Fatalf("inserting hotel %s: %v", id, err)
+		}
+	}
+}
+
+// cacheProfile caches a profile named name for hotelId in s.
+func cacheProfile(t *testing.T, s *Server, hotelId, name string) {
+	t.Helper()
+	b, _ := json.Marshal(&pb.Hotel{Id: hotelId, Name: name})
+	if err := s.MemcClient.Set(&memcache.Item{Key: hotelId, Value: b}); err != nil {
+		t.Fatalf("caching hotel %s: %v", hotelId, err)
+	}
+}
+
+// idsOf returns the IDs of hotels.
+func idsOf(hotels []*pb.Hotel) []string {
+	ids := make([]string, 0, len(hotels))
+	for _, h := range hotels {
+		ids = append(ids, h.Id)
+	}
+	return ids
+}
+
+func equalIds(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// waitCached waits for hotelId to be cached in s, as profiles loaded from
+// mongo are cached in the background.
+func waitCached(t *testing.T, s *Server, hotelId string) *memcache.Item {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); ; {
+		item, err := s.MemcClient.Get(hotelId)
+		if err == nil {
+			return item
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("hotel %s not cached: %v", hotelId, err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestGetProfilesPartialMisses(t *testing.T) {
+	s := newTestServer(t, "1", "2", "3")
+	// cached under another name, to tell where profiles came from
+	cacheProfile(t, s, "2", "Cached 2")
+
+	res, err := s.GetProfiles(context.Background(), &pb.Request{HotelIds: []string{"3", "missing", "2", "1"}})
+	if err != nil {
+		t.Fatalf("GetProfiles: %v", err)
+	}
+	if got, want := idsOf(res.Hotels), []string{"3", "2", "1"}; !equalIds(got, want) {
+		t.Fatalf("got hotels %v, want %v", got, want)
+	}
+	for _, h := range res.Hotels {
+		want := "Hotel " + h.Id
+		if h.Id == "2" {
+			want = "Cached 2"
+		}
+		if h.Name != want {
+			t.Errorf("hotel %s named %q, want %q", h.Id, h.Name, want)
+		}
+	}
+
+	// the misses are cached
+	var cached pb.Hotel
+	json.Unmarshal(waitCached(t, s, "1").Value, &cached)
+	if cached.Name != "Hotel 1" {
+		t.Errorf("cached hotel 1 named %q, want %q", cached.Name, "Hotel 1")
+	}
+}
+
+func TestGetProfilesDuplicates(t *testing.T) {
+	s := newTestServer(t, "1", "2")
+	res, err := s.GetProfiles(context.Background(), &pb.Request{HotelIds: []string{"2", "1", "2", "2", "1"}})
+	if err != nil {
+		t.Fatalf("GetProfiles: %v", err)
+	}
+	if got, want := idsOf(res.Hotels), []string{"2", "1"}; !equalIds(got, want) {
+		t.Errorf("got hotels %v, want %v", got, want)
+	}
+}
+
+func TestGetProfilesManyBatches(t *testing.T) {
+	var ids []string
+	for i := 0; i < 2*mongoBatchSize+1; i++ {
+		ids = append(ids, fmt.Sprintf("h%03d", i))
+	}
+	s := newTestServer(t, ids...)
+	s.MongoFanout = 2
+	res, err := s.GetProfiles(context.Background(), &pb.Request{HotelIds: ids})
+	if err != nil {
+		t.Fatalf("GetProfiles: %v", err)
+	}
+	if got := idsOf(res.Hotels); !equalIds(got, ids) {
+		t.Errorf("got hotels %v, want %v", got, ids)
+	}
+}
+
+// failingDB is a database whose queries fail.
+type failingDB struct{}
+
+func (failingDB) Collection(name string) store.Collection { return failingCollection{} }
+
+type failingCollection struct {
+	store.Collection
+}
+
+func (failingCollection) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	return nil, errors.New("mongo is down")
+}
+
+func TestGetProfilesMongoDown(t *testing.T) {
+	s := newTestServer(t)
+	s.DB = failingDB{}
+	cacheProfile(t, s, "1", "Cached 1")
+
+	_, err := s.GetProfiles(context.Background(), &pb.Request{HotelIds: []string{"1", "2"}})
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("got %v, want Unavailable", err)
+	}
+}
+
+// getProfiles gets the profiles of hotelIds from s, returning their IDs and
+// the span of the request.
+func getProfiles(t *testing.T, s *Server, hotelIds ...string) ([]string, *mocktracer.MockSpan) {
+	t.Helper()
+	span := mocktracer.New().StartSpan("GetProfiles").(*mocktracer.MockSpan)
+	res, err := s.GetProfiles(opentracing.ContextWithSpan(context.Background(), span), &pb.Request{HotelIds: hotelIds})
+	if err != nil {
+		t.Fatalf("GetProfiles: %v", err)
+	}
+	return idsOf(res.Hotels), span
+}
+
+func TestGetProfilesNegativeCaching(t *testing.T) {
+	s := newTestServer(t)
+	s.NegativeTTL = 1
+
+	// the miss is looked up in mongo, and cached as such
+	got, span := getProfiles(t, s, "new")
+	if len(got) != 0 {
+		t.Fatalf("got hotels %v, want none", got)
+	}
+	if span.Tag("profile.mongo_fallbacks") != 1 {
+		t.Errorf("profile.mongo_fallbacks = %v, want 1", span.Tag("profile.mongo_fallbacks"))
+	}
+	if item := waitCached(t, s, "new"); string(item.Value) != tombstone {
+		t.Fatalf("cached %q for the miss, want the tombstone", item.Value)
+	}
+
+	// until it expires, the hotel added since isn't found
+	insertProfiles(t, s, "new")
+	got, span = getProfiles(t, s, "new")
+	if len(got) != 0 {
+		t.Errorf("got hotels %v before the tombstone expired, want none", got)
+	}
+	if span.Tag("profile.negative_hits") != 1 || span.Tag("profile.mongo_fallbacks") != 0 {
+		t.Errorf("negative hits %v, mongo fallbacks %v, want 1 and 0", span.Tag("profile.negative_hits"), span.Tag("profile.mongo_fallbacks"))
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+	if got, _ = getProfiles(t, s, "new"); !equalIds(got, []string{"new"}) {
+		t.Errorf("got hotels %v once the tombstone expired, want [new]", got)
+	}
+}
+
+func TestGetProfilesNegativeCachingDisabled(t *testing.T) {
+	s := newTestServer(t)
+	getProfiles(t, s, "new")
+	insertProfiles(t, s, "new")
+	if got, _ := getProfiles(t, s, "new"); !equalIds(got, []string{"new"}) {
+		t.Errorf("got hotels %v, want [new]", got)
+	}
+}
+
+// countingDB is a database counting the queries of its hotels, which take
+// a while.
+type countingDB struct {
+	store.Database
+	finds int32
+}
+
+func (db *countingDB) Collection(name string) store.Collection {
+	return countingCollection{db.Database.Collection(name), &db.finds}
+}
+
+type countingCollection struct {
+	store.Collection
+	finds *int32
+}
+
+func (c countingCollection) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	atomic.AddInt32(c.finds, 1)
+	time.Sleep(50 * time.Millisecond)
+	return c.Collection.Find(ctx, filter, opts...)
+}
+
+func TestGetProfilesCoalescesMisses(t *testing.T) {
+	s := newTestServer(t, "1")
+	db := &countingDB{Database: s.DB}
+	s.DB = db
+
+	start := make(chan struct{})
+	errs := make(chan error, 50)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			res, err := s.GetProfiles(context.Background(), &pb.Request{HotelIds: []string{"1"}})
+			if err == nil && !equalIds(idsOf(res.Hotels), []string{"1"}) {
+				err = fmt.Errorf("got hotels %v, want [1]", idsOf(res.Hotels))
+			}
+			errs <- err
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if db.finds != 1 {
+		t.Errorf("mongo queried %d times for 50 concurrent misses, want 1", db.finds)
+	}
+}
@@ -0,0 +1,146 @@
+package profile
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/healthcheck"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/store"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/warmup"
+	"go.mongodb.org/mongo-driver/bson"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// blockingMemcache is a memcached whose writes wait for release, failing
+// with err once released if set.
+type blockingMemcache struct {
+	*mapMemcache
+	release chan struct{}
+	err     error
+}
+
+func (m *blockingMemcache) Set(item *memcache.Item) error {
+	<-m.release
+	if m.err != nil {
+		return m.err
+	}
+	return m.mapMemcache.Set(item)
+}
+
+// newWarmupServer returns a server of the profiles of hotelIds caching them
+// in memc, reporting its health on a gRPC server gated by the returned gate
+// as Run does, and a health client of it.
+func newWarmupServer(t *testing.T, memc store.Memcache, mode string, hotelIds ...string) (*Server, *healthcheck.Gate, healthpb.HealthClient) {
+	t.Helper()
+	var db docDB
+	for _, id := range hotelIds {
+		db = append(db, bson.M{"id": id, "name": "Hotel " + id})
+	}
+	s := &Server{
+		DB:          db,
+		MemcClient:  memc,
+		MongoFanout: 1,
+		Warmup:      warmup.Config{Mode: mode, Concurrency: 2},
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srv := grpc.NewServer()
+	warm := healthcheck.NewGate("warmup")
+	s.health = healthcheck.Register(srv, "profile.Profile", warm.Probe())
+	s.health.Check()
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	t.Cleanup(s.health.Shutdown)
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return s, warm, healthpb.NewHealthClient(conn)
+}
+
+// healthOf returns the status client reports for the server.
+func healthOf(t *testing.T, client healthpb.HealthClient) healthpb.HealthCheckResponse_ServingStatus {
+	t.Helper()
+	res, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("health check: %v", err)
+	}
+	return res.Status
+}
+
+func TestWarmupCachesEveryProfile(t *testing.T) {
+	memc := newMapMemcache()
+	s, warm, client := newWarmupServer(t, memc, warmup.On, "1", "2", "3", "4", "5")
+	if err := s.warmUp(warm); err != nil {
+		t.Fatalf("warmUp: %v", err)
+	}
+	for _, id := range []string{"1", "2", "3", "4", "5"} {
+		if _, err := memc.Get(id); err != nil {
+			t.Errorf("profile %s not cached: %v", id, err)
+		}
+	}
+	if got := healthOf(t, client); got != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("warmed up: got %v, want SERVING", got)
+	}
+}
+
+func TestWarmupGatesHealth(t *testing.T) {
+	memc := &blockingMemcache{mapMemcache: newMapMemcache(), release: make(chan struct{})}
+	s, warm, client := newWarmupServer(t, memc, warmup.On, "1", "2", "3")
+	done := make(chan error, 1)
+	go func() { done <- s.warmUp(warm) }()
+
+	// the server answers NOT_SERVING while warming up
+	time.Sleep(20 * time.Millisecond)
+	if got := healthOf(t, client); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("warming up: got %v, want NOT_SERVING", got)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("warmUp returned %v before caching", err)
+	default:
+	}
+
+	close(memc.release)
+	if err := <-done; err != nil {
+		t.Fatalf("warmUp: %v", err)
+	}
+	if got := healthOf(t, client); got != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("warmed up: got %v, want SERVING", got)
+	}
+}
+
+func TestWarmupFailing(t *testing.T) {
+	for _, tt := range []struct {
+		mode       string
+		wantErr    bool
+		wantStatus healthpb.HealthCheckResponse_ServingStatus
+	}{
+		// the server starts with whatever was cached
+		{warmup.On, false, healthpb.HealthCheckResponse_SERVING},
+		{warmup.Strict, true, healthpb.HealthCheckResponse_NOT_SERVING},
+	} {
+		release := make(chan struct{})
+		close(release)
+		memc := &blockingMemcache{mapMemcache: newMapMemcache(), release: release, err: errors.New("memcached down")}
+		s, warm, client := newWarmupServer(t, memc, tt.mode, "1", "2")
+
+		if err := s.warmUp(warm); (err != nil) != tt.wantErr {
+			t.Errorf("%s: got error %v, want error %v", tt.mode, err, tt.wantErr)
+		}
+		if got := healthOf(t, client); got != tt.wantStatus {
+			t.Errorf("%s: got %v, want %v", tt.mode, got, tt.wantStatus)
+		}
+	}
+}
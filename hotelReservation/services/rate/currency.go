@@ -0,0 +1,81 @@
+package rate
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails"
+	errpb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails/proto"
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/rate/proto"
+)
+
+// baseCurrency is the currency rates are stored in.
+const baseCurrency = "USD"
+
+// ParseExchangeRates parses a comma-separated list of CODE:RATE pairs, where
+// RATE is the number of units of CODE per USD, e.g. "EUR:0.92,GBP:0.79".
+func ParseExchangeRates(s string) (map[string]float64, error) {
+	rates := map[string]float64{baseCurrency: 1}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		code, rateStr, ok := strings.Cut(pair, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid exchange rate %q, want CODE:RATE", pair)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(rateStr), 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("invalid exchange rate for %s: %q", code, rateStr)
+		}
+		rates[strings.ToUpper(strings.TrimSpace(code))] = rate
+	}
+	return rates, nil
+}
+
+// exchangeRate returns the rate converting USD to currency. An empty currency
+// means USD.
+func (s *Server) exchangeRate(currency string) (string, float64, error) {
+	currency = strings.ToUpper(currency)
+	if currency == "" || currency == baseCurrency {
+		return baseCurrency, 1, nil
+	}
+	rate, ok := s.ExchangeRates[currency]
+	if !ok {
+		return "", 0, errdetails.InvalidArgument(errpb.ErrorDetail_UNSUPPORTED, "currency", "unsupported currency %q", currency)
+	}
+	return currency, rate, nil
+}
+
+// convertAmount converts a USD amount and rounds it to two decimal places,
+// half away from zero.
+func convertAmount(usd, rate float64) float64 {
+	return math.Round(usd*rate*100) / 100
+}
+
+// convertPlan expresses the prices of plan in currency. USD plans are left
+// untouched.
+func convertPlan(plan *pb.RatePlan, currency string, rate float64) {
+	if plan.RoomType != nil {
+		plan.RoomType.Currency = currency
+	}
+	if currency == baseCurrency {
+		return
+	}
+
+	if rt := plan.RoomType; rt != nil {
+		rt.BookableRate = convertAmount(rt.BookableRate, rate)
+		rt.TotalRate = convertAmount(rt.TotalRate, rate)
+		rt.TotalRateInclusive = convertAmount(rt.TotalRateInclusive, rate)
+	}
+	// Sum the rounded nights so the breakdown adds up to the total.
+	plan.StayTotal = 0
+	for _, night := range plan.NightlyRates {
+		night.Rate = convertAmount(night.Rate, rate)
+		plan.StayTotal += night.Rate
+	}
+	plan.StayTotal = math.Round(plan.StayTotal*100) / 100
+}
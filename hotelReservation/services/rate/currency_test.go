@@ -0,0 +1,73 @@
+package rate
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/rate/proto"
+	"go.mongodb.org/mongo-driver/bson"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseExchangeRates(t *testing.T) {
+	rates, err := ParseExchangeRates(" eur:0.5, JPY:150 ,")
+	if err != nil {
+		t.Fatalf("ParseExchangeRates: %v", err)
+	}
+	for code, want := range map[string]float64{"USD": 1, "EUR": 0.5, "JPY": 150} {
+		if rates[code] != want {
+			t.Errorf("rate of %s = %v, want %v", code, rates[code], want)
+		}
+	}
+
+	for _, bad := range []string{"EUR", "EUR:abc", "EUR:0", "EUR:-1"} {
+		if _, err := ParseExchangeRates(bad); err == nil {
+			t.Errorf("ParseExchangeRates(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestGetRatesCurrency(t *testing.T) {
+	s := newTestServer(t, []bson.M{rackPlan("1", 100.25)}, []bson.M{
+		{"hotelId": "1", "code": "RACK", "date": "2015-04-10", "rate": 150.5},
+	})
+	s.ExchangeRates, _ = ParseExchangeRates("EUR:0.5,JPY:150")
+	get := func(currency string) (*pb.Result, error) {
+		return s.GetRates(context.Background(), &pb.Request{HotelIds: []string{"1"}, InDate: "2015-04-09", OutDate: "2015-04-11", Currency: currency})
+	}
+
+	for _, tt := range []struct {
+		currency, want string
+		total          float64
+		nights         []float64
+		totalRate      float64
+	}{
+		{"", "USD", 250.75, []float64{100.25, 150.5}, 100.25},
+		{"usd", "USD", 250.75, []float64{100.25, 150.5}, 100.25},
+		// rounded half away from zero, night by night
+		{"EUR", "EUR", 125.38, []float64{50.13, 75.25}, 50.13},
+		{"JPY", "JPY", 37612.5, []float64{15037.5, 22575}, 15037.5},
+	} {
+		res, err := get(tt.currency)
+		if err != nil {
+			t.Fatalf("currency %q: %v", tt.currency, err)
+		}
+		plan := res.RatePlans[0]
+		if res.Currency != tt.want || plan.RoomType.Currency != tt.want {
+			t.Errorf("currency %q: got %s, plan in %s, want %s", tt.currency, res.Currency, plan.RoomType.Currency, tt.want)
+		}
+		if plan.StayTotal != tt.total || plan.RoomType.TotalRate != tt.totalRate {
+			t.Errorf("currency %q: stay total %v, total rate %v, want %v and %v", tt.currency, plan.StayTotal, plan.RoomType.TotalRate, tt.total, tt.totalRate)
+		}
+		for i, n := range plan.NightlyRates {
+			if n.Rate != tt.nights[i] {
+				t.Errorf("currency %q: night %s at %v, want %v", tt.currency, n.Date, n.Rate, tt.nights[i])
+			}
+		}
+	}
+
+	if _, err := get("XYZ"); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("unknown currency: got %v, want InvalidArgument", err)
+	}
+}
@@ -0,0 +1,24 @@
+package rate
+
+import (
+	"context"
+
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/rate/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/store"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// EvictCache deletes the cached rate plans of the requested hotels, so that
+// the next GetRates reads them from mongo. Hotels without a cache entry are
+// skipped, and only those with one are counted. Nightly rates, cached by
+// stay, are left to expire.
+func (s *Server) EvictCache(ctx context.Context, req *pb.EvictRequest) (*pb.EvictResult, error) {
+	evicted, err := store.Evict(s.MemcClient, req.HotelIds)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "evicted %d rate plans, then failed: %v", evicted, err)
+	}
+	tracing.Logger(ctx).Info().Msgf("Evicted the cached rate plans of %d of %d hotels", evicted, len(req.HotelIds))
+	return &pb.EvictResult{Evicted: int32(evicted)}, nil
+}
@@ -0,0 +1,106 @@
+package rate
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/rate/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
+	"go.mongodb.org/mongo-driver/bson"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// waitCached waits for the rate plans of hotelId to be cached, as GetRates
+// caches them asynchronously.
+func waitCached(t *testing.T, s *Server, hotelId string) {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); ; time.Sleep(5 * time.Millisecond) {
+		if _, err := s.MemcClient.Get(hotelId); err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("rate plans of hotel %s not cached", hotelId)
+		}
+	}
+}
+
+// evict evicts the cached rate plans of hotelIds from s.
+func evict(t *testing.T, s *Server, hotelIds ...string) int32 {
+	t.Helper()
+	res, err := s.EvictCache(context.Background(), &pb.EvictRequest{HotelIds: hotelIds})
+	if err != nil {
+		t.Fatalf("EvictCache(%v): %v", hotelIds, err)
+	}
+	return res.Evicted
+}
+
+func TestEvictCacheReloads(t *testing.T) {
+	s := newTestServer(t, []bson.M{rackPlan("1", 100), rackPlan("2", 80)}, nil)
+	stayOf(t, s, "1")
+	stayOf(t, s, "2")
+	waitCached(t, s, "1")
+	waitCached(t, s, "2")
+
+	// the rate changes out of band, unseen while cached
+	if _, err := s.DB.Collection("inventory").DeleteMany(context.Background(), bson.M{"hotelId": "1"}); err != nil {
+		t.Fatalf("deleting the plan of hotel 1: %v", err)
+	}
+	insert(t, s, "inventory", []bson.M{rackPlan("1", 140)})
+	if got := stayOf(t, s, "1").RoomType.BookableRate; got != 100 {
+		t.Fatalf("got rate %v before the eviction, want the cached 100", got)
+	}
+
+	if n := evict(t, s, "1"); n != 1 {
+		t.Errorf("evicted %d hotels, want 1", n)
+	}
+	if got := stayOf(t, s, "1").RoomType.BookableRate; got != 140 {
+		t.Errorf("got rate %v after the eviction, want 140 from mongo", got)
+	}
+	if _, err := s.MemcClient.Get("2"); err != nil {
+		t.Errorf("rate plans of hotel 2 evicted along: %v", err)
+	}
+}
+
+func TestEvictCacheAbsent(t *testing.T) {
+	s := newTestServer(t, []bson.M{rackPlan("1", 100)}, nil)
+	stayOf(t, s, "1")
+	waitCached(t, s, "1")
+
+	if n := evict(t, s, "1", "unknown"); n != 1 {
+		t.Errorf("evicted %d hotels, want 1", n)
+	}
+	// evicting again is harmless
+	if n := evict(t, s, "1", "unknown"); n != 0 {
+		t.Errorf("evicted %d hotels again, want 0", n)
+	}
+}
+
+func TestEvictCacheAdminOnly(t *testing.T) {
+	s := newTestServer(t, []bson.M{rackPlan("1", 100)}, nil)
+	admin, err := tracing.AdminUnaryServerInterceptor([]string{"10.0.0.0/8"}, pb.Rate_SetSurge_FullMethodName, pb.Rate_EvictCache_FullMethodName)
+	if err != nil {
+		t.Fatalf("AdminUnaryServerInterceptor: %v", err)
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: pb.Rate_EvictCache_FullMethodName}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return s.EvictCache(ctx, req.(*pb.EvictRequest))
+	}
+
+	for _, tt := range []struct {
+		ip   string
+		want codes.Code
+	}{
+		{"192.168.1.5", codes.PermissionDenied},
+		{"10.1.2.3", codes.OK},
+	} {
+		ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(tt.ip), Port: 50000}})
+		if _, err := admin(ctx, &pb.EvictRequest{HotelIds: []string{"1"}}, info, handler); status.Code(err) != tt.want {
+			t.Errorf("caller %s: got %v, want %v", tt.ip, err, tt.want)
+		}
+	}
+}
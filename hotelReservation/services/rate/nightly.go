@@ -0,0 +1,166 @@
+package rate
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails"
+	errpb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/memcring"
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/rate/proto"
+	"github.com/opentracing/opentracing-go"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const dateLayout = "2006-01-02"
+
+// maxStayNights bounds the length of a stay, and so the nightly rates a
+// request looks up.
+const maxStayNights = 30
+
+// nightlyRate is the price of one night of a rate plan, stored in the
+// rate-db.nightly collection. Nights without an entry are priced at the
+// plan's total rate, and nights without a room type are of the default one.
+type nightlyRate struct {
+	HotelId  string  `bson:"hotelId" json:"hotelId"`
+	RoomType string  `bson:"roomType,omitempty" json:"roomType,omitempty"`
+	Code     string  `bson:"code" json:"code"`
+	Date     string  `bson:"date" json:"date"`
+	Rate     float64 `bson:"rate" json:"rate"`
+}
+
+func nightlyKey(hotelId, roomType, code, date string) string {
+	return hotelId + "_" + roomType + "_" + code + "_" + date
+}
+
+// parseStay parses the check-in and check-out dates of a request and returns
+// the dates of the nights in between.
+func parseStay(inDate, outDate string) ([]string, error) {
+	in, err := time.Parse(dateLayout, inDate)
+	if err != nil {
+		return nil, errdetails.InvalidArgument(errpb.ErrorDetail_MALFORMED, "inDate", "invalid inDate %q: %v", inDate, err)
+	}
+	out, err := time.Parse(dateLayout, outDate)
+	if err != nil {
+		return nil, errdetails.InvalidArgument(errpb.ErrorDetail_MALFORMED, "outDate", "invalid outDate %q: %v", outDate, err)
+	}
+	if !out.After(in) {
+		return nil, errdetails.InvalidArgument(errpb.ErrorDetail_OUT_OF_RANGE, "outDate", "outDate %s must be after inDate %s", outDate, inDate)
+	}
+	if out.Sub(in) > maxStayNights*24*time.Hour {
+		return nil, errdetails.InvalidArgument(errpb.ErrorDetail_OUT_OF_RANGE, "outDate", "stay from %s to %s is longer than %d nights", inDate, outDate, maxStayNights)
+	}
+
+	var nights []string
+	for d := in; d.Before(out); d = d.AddDate(0, 0, 1) {
+		nights = append(nights, d.Format(dateLayout))
+	}
+	return nights, nil
+}
+
+// getNightlyRates returns the nightly rates of the hotels for the stay
+// starting on inDate and ending on outDate, of all room types, keyed by
+// nightlyKey.
+func (s *Server) getNightlyRates(ctx context.Context, hotelIds []string, inDate, outDate string) map[string]float64 {
+	rates := make(map[string]float64)
+
+	memcKeys := make([]string, 0, len(hotelIds))
+	missing := make(map[string]string, len(hotelIds))
+	for _, id := range hotelIds {
+		key := "nightly_" + id + "_" + inDate + "_" + outDate
+		memcKeys = append(memcKeys, key)
+		missing[key] = id
+	}
+
+	memSpan, _ := opentracing.StartSpanFromContext(ctx, "memcached_get_multi_nightly_rate")
+	memSpan.SetTag("span.kind", "client")
+	resMap, err := s.MemcClient.GetMulti(memcKeys)
+	memSpan.Finish()
+	memcring.DefaultStats.RecordMulti(len(memcKeys), len(resMap), err)
+	if err != nil && err != memcache.ErrCacheMiss {
+		log.Error().Msgf("Memcached error while trying to get nightly rates [ids: %v]: %s", hotelIds, err)
+	}
+
+	for key, item := range resMap {
+		var nights []nightlyRate
+		if err := json.Unmarshal(item.Value, &nights); err != nil {
+			log.Error().Msgf("Failed to unmarshal nightly rates [key: %s]: %s", key, err)
+			continue
+		}
+		for _, n := range nights {
+			rates[nightlyKey(n.HotelId, s.roomType(n.RoomType), n.Code, n.Date)] = n.Rate
+		}
+		delete(missing, key)
+	}
+
+	if len(missing) == 0 {
+		return rates
+	}
+
+	missingIds := make([]string, 0, len(missing))
+	for _, id := range missing {
+		missingIds = append(missingIds, id)
+	}
+
+	mongoSpan, _ := opentracing.StartSpanFromContext(ctx, "mongo_nightly_rate")
+	mongoSpan.SetTag("span.kind", "client")
+	collection := s.DB.Collection("nightly")
+	filter := bson.M{
+		"hotelId": bson.M{"$in": missingIds},
+		"date":    bson.M{"$gte": inDate, "$lt": outDate},
+	}
+	var nights []nightlyRate
+	curr, err := collection.Find(ctx, filter)
+	if err == nil {
+		err = curr.All(ctx, &nights)
+	}
+	mongoSpan.Finish()
+	if err != nil {
+		log.Error().Msgf("Failed get nightly rate data [ids: %v]: %s", missingIds, err)
+		return rates
+	}
+
+	byHotel := make(map[string][]nightlyRate, len(missingIds))
+	for _, n := range nights {
+		rates[nightlyKey(n.HotelId, s.roomType(n.RoomType), n.Code, n.Date)] = n.Rate
+		byHotel[n.HotelId] = append(byHotel[n.HotelId], n)
+	}
+	for key, id := range missing {
+		nightsJson, err := json.Marshal(byHotel[id])
+		if err != nil {
+			log.Error().Msgf("Failed to marshal nightly rates [id: %s]: %s", id, err)
+			continue
+		}
+		go s.MemcClient.Set(&memcache.Item{Key: key, Value: nightsJson})
+	}
+
+	return rates
+}
+
+// priceStay fills the stay total and per-night breakdown of plan. Nights
+// without a nightly rate are priced at the room's total rate.
+func priceStay(plan *pb.RatePlan, nights []string, rates map[string]float64) {
+	defaultRate, roomType := 0.0, ""
+	if plan.RoomType != nil {
+		defaultRate, roomType = plan.RoomType.TotalRate, plan.RoomType.Id
+	}
+
+	plan.StayTotal = 0
+	plan.NightlyRates = make([]*pb.NightlyRate, 0, len(nights))
+	plan.UsesDefaultRate = false
+	for _, date := range nights {
+		night := &pb.NightlyRate{Date: date}
+		if rate, ok := rates[nightlyKey(plan.HotelId, roomType, plan.Code, date)]; ok {
+			night.Rate = rate
+		} else {
+			night.Rate = defaultRate
+			night.IsDefault = true
+			plan.UsesDefaultRate = true
+		}
+		plan.StayTotal += night.Rate
+		plan.NightlyRates = append(plan.NightlyRates, night)
+	}
+}
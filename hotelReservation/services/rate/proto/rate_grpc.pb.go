@@ -19,7 +19,9 @@ import (
 const _ = grpc.SupportPackageIsVersion7
 
 const (
-	Rate_GetRates_FullMethodName = "/rate.Rate/GetRates"
+	Rate_GetRates_FullMethodName   = "/rate.Rate/GetRates"
+	Rate_SetSurge_FullMethodName   = "/rate.Rate/SetSurge"
+	Rate_EvictCache_FullMethodName = "/rate.Rate/EvictCache"
 )
 
 // RateClient is the client API for Rate service.
@@ -28,6 +30,12 @@ const (
 type RateClient interface {
 	// GetRates returns rate codes for hotels for a given date range
 	GetRates(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Result, error)
+	// Sets the surge multiplier applied to the rates of a hotel, 1 to price
+	// it at its base rates again. Admin only.
+	SetSurge(ctx context.Context, in *SurgeRequest, opts ...grpc.CallOption) (*SurgeResult, error)
+	// Deletes the cached rate plans of hotels, so that they are read from the
+	// database again. Admin only.
+	EvictCache(ctx context.Context, in *EvictRequest, opts ...grpc.CallOption) (*EvictResult, error)
 }
 
 type rateClient struct {
@@ -47,12 +55,36 @@ func (c *rateClient) GetRates(ctx context.Context, in *Request, opts ...grpc.Cal
 	return out, nil
 }
 
+func (c *rateClient) SetSurge(ctx context.Context, in *SurgeRequest, opts ...grpc.CallOption) (*SurgeResult, error) {
+	out := new(SurgeResult)
+	err := c.cc.Invoke(ctx, Rate_SetSurge_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rateClient) EvictCache(ctx context.Context, in *EvictRequest, opts ...grpc.CallOption) (*EvictResult, error) {
+	out := new(EvictResult)
+	err := c.cc.Invoke(ctx, Rate_EvictCache_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // RateServer is the server API for Rate service.
 // All implementations must embed UnimplementedRateServer
 // for forward compatibility
 type RateServer interface {
 	// GetRates returns rate codes for hotels for a given date range
 	GetRates(context.Context, *Request) (*Result, error)
+	// Sets the surge multiplier applied to the rates of a hotel, 1 to price
+	// it at its base rates again. Admin only.
+	SetSurge(context.Context, *SurgeRequest) (*SurgeResult, error)
+	// Deletes the cached rate plans of hotels, so that they are read from the
+	// database again. Admin only.
+	EvictCache(context.Context, *EvictRequest) (*EvictResult, error)
 	mustEmbedUnimplementedRateServer()
 }
 
@@ -63,6 +95,12 @@ type UnimplementedRateServer struct {
 func (UnimplementedRateServer) GetRates(context.Context, *Request) (*Result, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetRates not implemented")
 }
+func (UnimplementedRateServer) SetSurge(context.Context, *SurgeRequest) (*SurgeResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetSurge not implemented")
+}
+func (UnimplementedRateServer) EvictCache(context.Context, *EvictRequest) (*EvictResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EvictCache not implemented")
+}
 func (UnimplementedRateServer) mustEmbedUnimplementedRateServer() {}
 
 // UnsafeRateServer may be embedded to opt out of forward compatibility for this service.
@@ -94,6 +132,42 @@ func _Rate_GetRates_Handler(srv interface{}, ctx context.Context, dec func(inter
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Rate_SetSurge_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SurgeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RateServer).SetSurge(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Rate_SetSurge_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RateServer).SetSurge(ctx, req.(*SurgeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Rate_EvictCache_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EvictRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RateServer).EvictCache(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Rate_EvictCache_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RateServer).EvictCache(ctx, req.(*EvictRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Rate_ServiceDesc is the grpc.ServiceDesc for Rate service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -105,6 +179,14 @@ var Rate_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetRates",
 			Handler:    _Rate_GetRates_Handler,
 		},
+		{
+			MethodName: "SetSurge",
+			Handler:    _Rate_SetSurge_Handler,
+		},
+		{
+			MethodName: "EvictCache",
+			Handler:    _Rate_EvictCache_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "services/rate/proto/rate.proto",
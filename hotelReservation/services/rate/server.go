@@ -3,6 +3,7 @@ package rate
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"sort"
@@ -11,43 +12,103 @@ import (
 	"time"
 
 	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/coalesce"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/fanout"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/graceful"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/healthcheck"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/memcring"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/registry"
 	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/rate/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/store"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tls"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/warmup"
 	"github.com/google/uuid"
-	"github.com/grpc-ecosystem/grpc-opentracing/go/otgrpc"
 	"github.com/opentracing/opentracing-go"
 	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/protobuf/proto"
 )
 
 const name = "srv-rate"
 
+// validators check requests before their handler runs.
+var validators = map[string]tracing.Validator{
+	pb.Rate_SetSurge_FullMethodName:   tracing.ValidateAll(tracing.Required("hotelId"), tracing.Positive("multiplier")),
+	pb.Rate_EvictCache_FullMethodName: tracing.Required("hotelIds"),
+}
+
 // Server implements the rate service
 type Server struct {
 	pb.UnimplementedRateServer
 
-	uuid string
+	uuid       string
+	health     *healthcheck.Checker
+	grpcServer *grpc.Server
+	traffic    tracing.MetricsMark
+	// flights collapses concurrent mongo loads of the same rates.
+	flights coalesce.Group
+	// surge holds the surge multipliers by hotel, starting from Surge.
+	surgeMu sync.RWMutex
+	surge   map[string]float64
 
 	Tracer      opentracing.Tracer
 	Port        int
 	IpAddr      string
 	MongoClient *mongo.Client
-	Registry    *registry.Client
-	MemcClient  *memcache.Client
+	// DB is the rate-db database, that of MongoClient if nil.
+	DB         store.Database
+	Registry   *registry.Client
+	MemcClient store.Memcache
+	// ExchangeRates maps currency codes to units per USD.
+	ExchangeRates map[string]float64
+	// Surge maps hotel ids to the multiplier applied to their base rates,
+	// 1 for hotels it doesn't have. SetSurge changes them once running.
+	Surge map[string]float64
+	// DefaultRoomType is the room type of requests and rate plans that
+	// don't name one.
+	DefaultRoomType string
+	// Warmup configures the caching of every rate plan at startup, before
+	// the server reports SERVING and registers in Consul.
+	Warmup warmup.Config
+	// MongoFanout is how many hotels missing from the cache are read from
+	// mongo at once, 1 if less.
+	MongoFanout int
+	// MetricsPort is the admin port serving /metrics, zero to disable it.
+	MetricsPort int
+	// AdminAllowlist are the IPs and CIDRs of the callers allowed to call
+	// SetSurge and EvictCache.
+	AdminAllowlist []string
 }
 
 // Run starts the server
 func (s *Server) Run() error {
-	opentracing.SetGlobalTracer(s.Tracer)
-
 	if s.Port == 0 {
 		return fmt.Errorf("server port must be set")
 	}
 
+	if s.DB == nil {
+		s.DB = store.MongoDatabase(s.MongoClient.Database("rate-db"))
+	}
+
+	admin, err := tracing.AdminUnaryServerInterceptor(s.AdminAllowlist, pb.Rate_SetSurge_FullMethodName, pb.Rate_EvictCache_FullMethodName)
+	if err != nil {
+		return err
+	}
+
+	s.surgeMu.Lock()
+	s.surge = make(map[string]float64, len(s.Surge))
+	for hotelID, m := range s.Surge {
+		s.surge[hotelID] = m
+	}
+	s.surgeMu.Unlock()
+
 	s.uuid = uuid.New().String()
 
 	opts := []grpc.ServerOption{
@@ -55,11 +116,19 @@ func (s *Server) Run() error {
 			Timeout: 120 * time.Second,
 		}),
 		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             time.Duration(tune.GetKeepaliveTime()) * time.Millisecond,
 			PermitWithoutStream: true,
 		}),
-		grpc.UnaryInterceptor(
-			otgrpc.OpenTracingServerInterceptor(s.Tracer),
-		),
+		grpc.MaxRecvMsgSize(tune.GetMaxRecvMsgSize()),
+		grpc.MaxSendMsgSize(tune.GetMaxSendMsgSize()),
+		grpc.UnaryInterceptor(tracing.DefaultServerInterceptorChain(tracing.ServerChainOptions{
+			Tracer: s.Tracer,
+			Interceptors: []grpc.UnaryServerInterceptor{
+				tracing.ChaosUnaryServerInterceptor(tracing.DefaultChaos),
+				admin,
+				tracing.ValidationUnaryServerInterceptor(validators),
+			},
+		})),
 	}
 
 	if tlsopt := tls.GetServerOpt(); tlsopt != nil {
@@ -67,31 +136,79 @@ func (s *Server) Run() error {
 	}
 
 	srv := grpc.NewServer(opts...)
+	s.grpcServer = srv
+	s.traffic = tracing.DefaultMetrics.Mark()
 
 	pb.RegisterRateServer(srv, s)
+	if tune.GetGrpcReflection() {
+		reflection.Register(srv)
+	}
+
+	probes := []healthcheck.Probe{healthcheck.Mongo(s.MongoClient), healthcheck.Memcached(s.MemcClient)}
+	warm := healthcheck.NewGate("warmup")
+	if s.Warmup.Enabled() {
+		probes = append(probes, warm.Probe())
+	}
+	s.health = healthcheck.Register(srv, pb.Rate_ServiceDesc.ServiceName, probes...)
+	s.health.Start()
+
+	tracing.DefaultMetrics.RegisterCounter("memcached_hits_total", "Total number of keys found in memcached.", memcring.DefaultStats.Hits)
+	tracing.DefaultMetrics.RegisterCounter("memcached_misses_total", "Total number of keys not found in memcached.", memcring.DefaultStats.Misses)
+	tracing.DefaultMetrics.RegisterCounter("memcached_errors_total", "Total number of failed memcached lookups.", memcring.DefaultStats.Errors)
+	if s.MetricsPort != 0 {
+		go tracing.ServeMetrics(s.MetricsPort, tracing.DefaultMetrics)
+	}
+	if path := tune.GetFeatureFlagsFile(); path != "" {
+		go tracing.DefaultFlags.WatchFile(path, time.Duration(tune.GetFeatureFlagsPollInterval())*time.Millisecond)
+	}
 
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", s.Port))
 	if err != nil {
 		log.Fatal().Msgf("failed to listen: %v", err)
 	}
 
+	// Serve while warming up, so that health checks get NOT_SERVING rather
+	// than no answer.
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(lis) }()
+	if err := s.warmUp(warm); err != nil {
+		srv.Stop()
+		return err
+	}
+
 	err = s.Registry.Register(name, s.uuid, s.IpAddr, s.Port)
 	if err != nil {
 		return fmt.Errorf("failed register: %v", err)
 	}
 	log.Info().Msg("Successfully registered in consul")
 
-	return srv.Serve(lis)
+	return <-served
 }
 
-// Shutdown cleans up any processes
+// Shutdown reports the server NOT_SERVING, deregisters it and drains
+// in-flight RPCs before stopping it, then reports the traffic it served.
 func (s *Server) Shutdown() {
+	s.health.Shutdown()
 	s.Registry.Deregister(s.uuid)
+	graceful.Stop(s.grpcServer)
+	graceful.Report(s.grpcServer, s.traffic)
 }
 
-// GetRates gets rates for hotels for specific date range.
+// GetRates gets rates for hotels for specific date range, priced night by
+// night over the stay and surged by the multiplier of their hotel. Only the
+// plans of the requested room type are returned.
 func (s *Server) GetRates(ctx context.Context, req *pb.Request) (*pb.Result, error) {
 	res := new(pb.Result)
+	roomType := s.roomType(req.RoomType)
+
+	nights, err := parseStay(req.InDate, req.OutDate)
+	if err != nil {
+		return nil, err
+	}
+	currency, exchangeRate, err := s.exchangeRate(req.Currency)
+	if err != nil {
+		return nil, err
+	}
 
 	ratePlans := make(RatePlans, 0)
 
@@ -107,80 +224,195 @@ func (s *Server) GetRates(ctx context.Context, req *pb.Request) (*pb.Result, err
 
 	resMap, err := s.MemcClient.GetMulti(hotelIds)
 	memSpan.Finish()
+	memcring.DefaultStats.RecordMulti(len(hotelIds), len(resMap), err)
 
-	var wg sync.WaitGroup
-	var mutex sync.Mutex
 	if err != nil && err != memcache.ErrCacheMiss {
-		log.Panic().Msgf("Memmcached error while trying to get hotel [id: %v]= %s", hotelIds, err)
-	} else {
-		for hotelId, item := range resMap {
-			rateStrs := strings.Split(string(item.Value), "\n")
-			log.Trace().Msgf("memc hit, hotelId = %s,rate strings: %v", hotelId, rateStrs)
-
-			for _, rateStr := range rateStrs {
-				if len(rateStr) != 0 {
-					rateP := new(pb.RatePlan)
-					json.Unmarshal([]byte(rateStr), rateP)
-					ratePlans = append(ratePlans, rateP)
-				}
+		// the rates held by a failing cache node are read from mongo
+		log.Warn().Msgf("Memcached error while trying to get hotel [id: %v]= %s", hotelIds, err)
+	}
+	for hotelId, item := range resMap {
+		rateStrs := strings.Split(string(item.Value), "\n")
+		log.Trace().Msgf("memc hit, hotelId = %s,rate strings: %v", hotelId, rateStrs)
+
+		for _, rateStr := range rateStrs {
+			if len(rateStr) != 0 {
+				rateP := new(pb.RatePlan)
+				json.Unmarshal([]byte(rateStr), rateP)
+				ratePlans = append(ratePlans, rateP)
 			}
+		}
+
+		delete(rateMap, hotelId)
+	}
 
+	// the misses are loaded in the order of the request
+	missIds := make([]string, 0, len(rateMap))
+	for _, hotelId := range hotelIds {
+		if _, ok := rateMap[hotelId]; ok {
+			missIds = append(missIds, hotelId)
 			delete(rateMap, hotelId)
 		}
+	}
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		span.SetTag("rate.fanout_width", fanout.Width(len(missIds), s.MongoFanout))
+		span.SetTag("rate.fanout_misses", len(missIds))
+	}
+
+	missPlans := make([]RatePlans, len(missIds))
+	loadErrs := make([]error, len(missIds))
+	fanout.Each(len(missIds), s.MongoFanout, func(i int) {
+		id := missIds[i]
+		log.Trace().Msgf("memc miss, hotelId = %s", id)
+
+		load := func(ids []string) (map[string]interface{}, error) {
+			return s.loadMongoRates(ctx, ids[0])
+		}
+		loaded, err := s.flights.Load([]string{id}, load)
+		if errors.Is(err, context.Canceled) && ctx.Err() == nil {
+			// The load was led by another request, cancelled since.
+			loaded, err = s.flights.Load([]string{id}, load)
+		}
+		if err != nil {
+			log.Error().Msgf("Failed get rate data [id: %v]: %v", id, err)
+			loadErrs[i] = err
+			return
+		}
+		if plans, ok := loaded[id]; ok {
+			missPlans[i] = plans.(RatePlans)
+		}
+	})
+	var loadErr error
+	for i, plans := range missPlans {
+		if loadErr == nil {
+			loadErr = loadErrs[i]
+		}
+		// loads are shared by the coalesced requests, each pricing its own
+		// copy of the plans
+		for _, plan := range plans {
+			ratePlans = append(ratePlans, proto.Clone(plan).(*pb.RatePlan))
+		}
+	}
+	if loadErr != nil {
+		return nil, store.Error(ctx, loadErr, codes.Unavailable, "failed to get rates")
+	}
 
-		wg.Add(len(rateMap))
-		for hotelId := range rateMap {
-			go func(id string) {
-				log.Trace().Msgf("memc miss, hotelId = %s", id)
-				log.Trace().Msg("memcached miss, set up mongo connection")
-
-				mongoSpan, _ := opentracing.StartSpanFromContext(ctx, "mongo_rate")
-				mongoSpan.SetTag("span.kind", "client")
-
-				// memcached miss, set up mongo connection
-				collection := s.MongoClient.Database("rate-db").Collection("inventory")
-				curr, err := collection.Find(context.TODO(), bson.D{})
-				if err != nil {
-					log.Error().Msgf("Failed get rate data: ", err)
-				}
-
-				tmpRatePlans := make(RatePlans, 0)
-				curr.All(context.TODO(), &tmpRatePlans)
-				if err != nil {
-					log.Error().Msgf("Failed get rate data: ", err)
-				}
-
-				mongoSpan.Finish()
-
-				memcStr := ""
-				if err != nil {
-					log.Panic().Msgf("Tried to find hotelId [%v], but got error", id, err.Error())
-				} else {
-					for _, r := range tmpRatePlans {
-						mutex.Lock()
-						ratePlans = append(ratePlans, r)
-						mutex.Unlock()
-						rateJson, err := json.Marshal(r)
-						if err != nil {
-							log.Error().Msgf("Failed to marshal plan [Code: %v] with error: %s", r.Code, err)
-						}
-						memcStr = memcStr + string(rateJson) + "\n"
-					}
-				}
-				go s.MemcClient.Set(&memcache.Item{Key: id, Value: []byte(memcStr)})
-
-				defer wg.Done()
-			}(hotelId)
+	typePlans := make(RatePlans, 0, len(ratePlans))
+	for _, plan := range ratePlans {
+		if plan.RoomType == nil {
+			plan.RoomType = new(pb.RoomType)
+		}
+		plan.RoomType.Id = s.roomType(plan.RoomType.Id)
+		if plan.RoomType.Id == roomType {
+			typePlans = append(typePlans, plan)
 		}
 	}
-	wg.Wait()
 
-	sort.Sort(ratePlans)
-	res.RatePlans = ratePlans
+	nightlyRates := s.getNightlyRates(ctx, hotelIds, req.InDate, req.OutDate)
+	for _, plan := range typePlans {
+		priceStay(plan, nights, nightlyRates)
+		convertPlan(plan, currency, exchangeRate)
+		applySurge(plan, s.surgeMultiplier(plan.HotelId))
+	}
+
+	sort.Sort(typePlans)
+	res.RatePlans = typePlans
+	res.Currency = currency
 
 	return res, nil
 }
 
+// roomType returns id, or the default room type if id is empty.
+func (s *Server) roomType(id string) string {
+	if id == "" {
+		return s.DefaultRoomType
+	}
+	return id
+}
+
+// loadMongoRates fetches the rate plans of hotelId from mongo and caches
+// them, aborting if ctx is done. It runs once for concurrent misses of a
+// hotel, see Server.flights.
+func (s *Server) loadMongoRates(ctx context.Context, hotelId string) (map[string]interface{}, error) {
+	mongoSpan, _ := opentracing.StartSpanFromContext(ctx, "mongo_rate")
+	mongoSpan.SetTag("span.kind", "client")
+	defer mongoSpan.Finish()
+
+	collection := s.DB.Collection("inventory")
+	curr, err := collection.Find(ctx, bson.M{"hotelId": hotelId})
+	if err != nil {
+		return nil, err
+	}
+	ratePlans := make(RatePlans, 0)
+	if err := curr.All(ctx, &ratePlans); err != nil {
+		return nil, err
+	}
+
+	// marshalled before returning, as the plans are priced in place once
+	// returned, only the write being left to the background
+	item := &memcache.Item{Key: hotelId, Value: encodeRates(ratePlans)}
+	go s.MemcClient.Set(item)
+
+	return map[string]interface{}{hotelId: ratePlans}, nil
+}
+
+// cacheRates writes the rate plans of hotelId to memcached.
+func (s *Server) cacheRates(hotelId string, ratePlans RatePlans) error {
+	return s.MemcClient.Set(&memcache.Item{Key: hotelId, Value: encodeRates(ratePlans)})
+}
+
+// encodeRates returns ratePlans as cached, one JSON plan per line.
+func encodeRates(ratePlans RatePlans) []byte {
+	memcStr := ""
+	for _, r := range ratePlans {
+		rateJson, err := json.Marshal(r)
+		if err != nil {
+			log.Error().Msgf("Failed to marshal plan [Code: %v] with error: %s", r.Code, err)
+			continue
+		}
+		memcStr = memcStr + string(rateJson) + "\n"
+	}
+	return []byte(memcStr)
+}
+
+// warmUp warms the cache up as configured by Warmup, then opens warm and
+// has the health checker report the server SERVING unless a dependency is
+// down. It fails if warming up fails in strict mode, leaving warm closed.
+func (s *Server) warmUp(warm *healthcheck.Gate) error {
+	if err := s.Warmup.Run("hotel rates", s.warmup); err != nil {
+		return err
+	}
+	warm.Open()
+	s.health.Check()
+	return nil
+}
+
+// warmup caches the rate plans of every hotel, with at most concurrency
+// writes at once.
+func (s *Server) warmup(concurrency int) (int, error) {
+	var ratePlans RatePlans
+	curr, err := s.DB.Collection("inventory").Find(context.TODO(), bson.M{})
+	if err != nil {
+		return 0, err
+	}
+	if err := curr.All(context.TODO(), &ratePlans); err != nil {
+		return 0, err
+	}
+
+	var hotelIds []string
+	byHotel := make(map[string]RatePlans)
+	for _, plan := range ratePlans {
+		if _, ok := byHotel[plan.HotelId]; !ok {
+			hotelIds = append(hotelIds, plan.HotelId)
+		}
+		byHotel[plan.HotelId] = append(byHotel[plan.HotelId], plan)
+	}
+	errs := make([]error, len(hotelIds))
+	fanout.Each(len(hotelIds), concurrency, func(i int) {
+		errs[i] = s.cacheRates(hotelIds[i], byHotel[hotelIds[i]])
+	})
+	return warmup.Tally(errs)
+}
+
 type RatePlans []*pb.RatePlan
 
 func (r RatePlans) Len() int {
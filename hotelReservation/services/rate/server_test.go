@@ -0,0 +1,188 @@
+package rate
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/inproc/fakestore"
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/rate/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newTestServer returns a server backed by stand-ins of MongoDB and
+// memcached, with the rate plans of plans, as stored by the seeding, and
+// the nightly rates of nights.
+func newTestServer(t *testing.T, plans []bson.M, nights []bson.M) *Server {
+	t.Helper()
+	s := &Server{
+		DB:              store.MongoDatabase(fakestore.Mongo(t).Database("rate-db")),
+		MemcClient:      fakestore.Memcached(t),
+		DefaultRoomType: "standard",
+	}
+	insert(t, s, "inventory", plans)
+	insert(t, s, "nightly", nights)
+	return s
+}
+
+func insert(t *testing.T, s *Server, collection string, docs []bson.M) {
+	t.Helper()
+	if len(docs) == 0 {
+		return
+	}
+	many := make([]interface{}, len(docs))
+	for i, d := range docs {
+		many[i] = d
+	}
+	if _, err := s.DB.Collection(collection).InsertMany(context.Background(), many); err != nil {
+		t.Fatalf("inserting into %s: %v", collection, err)
+	}
+}
+
+// rackPlan is a RACK plan of hotelId at rate a night.
+func rackPlan(hotelId string, rate float64) bson.M {
+	return bson.M{
+		"hotelId": hotelId,
+		"code":    "RACK",
+		"roomType": bson.M{
+			"bookableRate":       rate,
+			"totalRate":          rate,
+			"totalRateInclusive": rate,
+			"code":               "KNG",
+		},
+	}
+}
+
+func TestGetRatesMultiNight(t *testing.T) {
+	s := newTestServer(t, []bson.M{rackPlan("1", 100)}, []bson.M{
+		// the weekend of April 10 2015 is dearer
+		{"hotelId": "1", "code": "RACK", "date": "2015-04-10", "rate": 150.0},
+		{"hotelId": "1", "code": "RACK", "date": "2015-04-11", "rate": 180.0},
+		// outside the stay
+		{"hotelId": "1", "code": "RACK", "date": "2015-04-13", "rate": 500.0},
+	})
+
+	res, err := s.GetRates(context.Background(), &pb.Request{HotelIds: []string{"1"}, InDate: "2015-04-09", OutDate: "2015-04-13"})
+	if err != nil {
+		t.Fatalf("GetRates: %v", err)
+	}
+	if len(res.RatePlans) != 1 {
+		t.Fatalf("got %d rate plans, want 1", len(res.RatePlans))
+	}
+	plan := res.RatePlans[0]
+	if plan.StayTotal != 530 {
+		t.Errorf("stay total = %v, want 530", plan.StayTotal)
+	}
+	if !plan.UsesDefaultRate {
+		t.Errorf("usesDefaultRate not set with weeknights at the default rate")
+	}
+	want := []struct {
+		date      string
+		rate      float64
+		isDefault bool
+	}{
+		{"2015-04-09", 100, true},
+		{"2015-04-10", 150, false},
+		{"2015-04-11", 180, false},
+		{"2015-04-12", 100, true},
+	}
+	if len(plan.NightlyRates) != len(want) {
+		t.Fatalf("got %d nights, want %d", len(plan.NightlyRates), len(want))
+	}
+	for i, w := range want {
+		n := plan.NightlyRates[i]
+		if n.Date != w.date || n.Rate != w.rate || n.IsDefault != w.isDefault {
+			t.Errorf("night %d = %s at %v (default %v), want %s at %v (default %v)", i, n.Date, n.Rate, n.IsDefault, w.date, w.rate, w.isDefault)
+		}
+	}
+}
+
+func TestGetRatesInvalidStay(t *testing.T) {
+	s := newTestServer(t, []bson.M{rackPlan("1", 100)}, nil)
+	for _, tt := range []struct{ inDate, outDate string }{
+		{"2015-04-09", "2015-04-09"},
+		{"2015-04-10", "2015-04-09"},
+		{"2015-04-09", "not a date"},
+		// 31 nights
+		{"2015-04-01", "2015-05-02"},
+	} {
+		_, err := s.GetRates(context.Background(), &pb.Request{HotelIds: []string{"1"}, InDate: tt.inDate, OutDate: tt.outDate})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("stay from %s to %s: got %v, want InvalidArgument", tt.inDate, tt.outDate, err)
+		}
+	}
+
+	// 30 nights are fine
+	if _, err := s.GetRates(context.Background(), &pb.Request{HotelIds: []string{"1"}, InDate: "2015-04-01", OutDate: "2015-05-01"}); err != nil {
+		t.Errorf("30 nights: %v", err)
+	}
+}
+
+// typePlan is rackPlan for rooms of roomType.
+func typePlan(hotelId, roomType string, rate float64) bson.M {
+	plan := rackPlan(hotelId, rate)
+	plan["roomType"].(bson.M)["id"] = roomType
+	return plan
+}
+
+func TestGetRatesRoomTypes(t *testing.T) {
+	s := newTestServer(t, []bson.M{
+		rackPlan("1", 100),
+		typePlan("1", "deluxe", 200),
+		typePlan("1", "suite", 400),
+	}, []bson.M{
+		{"hotelId": "1", "code": "RACK", "date": "2015-04-10", "rate": 150.0},
+		{"hotelId": "1", "roomType": "deluxe", "code": "RACK", "date": "2015-04-10", "rate": 250.0},
+	})
+
+	for _, tt := range []struct {
+		roomType, want string
+		total          float64
+	}{
+		{"", "standard", 250},
+		{"standard", "standard", 250},
+		{"deluxe", "deluxe", 450},
+		{"suite", "suite", 800},
+	} {
+		res, err := s.GetRates(context.Background(), &pb.Request{HotelIds: []string{"1"}, InDate: "2015-04-09", OutDate: "2015-04-11", RoomType: tt.roomType})
+		if err != nil {
+			t.Fatalf("GetRates of %q rooms: %v", tt.roomType, err)
+		}
+		if len(res.RatePlans) != 1 {
+			t.Fatalf("got %d rate plans of %q rooms, want 1", len(res.RatePlans), tt.roomType)
+		}
+		plan := res.RatePlans[0]
+		if plan.RoomType.Id != tt.want || plan.StayTotal != tt.total {
+			t.Errorf("%q rooms: got %s rooms at %v, want %s rooms at %v", tt.roomType, plan.RoomType.Id, plan.StayTotal, tt.want, tt.total)
+		}
+	}
+
+	res, err := s.GetRates(context.Background(), &pb.Request{HotelIds: []string{"1"}, InDate: "2015-04-09", OutDate: "2015-04-11", RoomType: "penthouse"})
+	if err != nil || len(res.RatePlans) != 0 {
+		t.Errorf("got %v, %v for rooms the hotel doesn't have, want no plans", res, err)
+	}
+}
+
+func TestWarmup(t *testing.T) {
+	s := newTestServer(t, []bson.M{rackPlan("1", 100), rackPlan("2", 120), rackPlan("2", 90), rackPlan("3", 80)}, nil)
+	n, err := s.warmup(2)
+	if err != nil {
+		t.Fatalf("warmup: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("cached the rates of %d hotels, want 3", n)
+	}
+	for id, plans := range map[string]int{"1": 1, "2": 2, "3": 1} {
+		item, err := s.MemcClient.Get(id)
+		if err != nil {
+			t.Errorf("rates of hotel %s not cached: %v", id, err)
+			continue
+		}
+		if got := strings.Count(string(item.Value), "\n"); got != plans {
+			t.Errorf("cached %d rate plans of hotel %s, want %d", got, id, plans)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package rate
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails"
+	errpb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails/proto"
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/rate/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
+)
+
+// ParseSurge parses a comma-separated list of HOTEL:MULTIPLIER pairs, e.g.
+// "1:1.5,7:0.8". Multipliers must be positive.
+func ParseSurge(s string) (map[string]float64, error) {
+	surge := make(map[string]float64)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		hotelID, multStr, ok := strings.Cut(pair, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid surge %q, want HOTEL:MULTIPLIER", pair)
+		}
+		mult, err := strconv.ParseFloat(strings.TrimSpace(multStr), 64)
+		if err != nil || !validMultiplier(mult) {
+			return nil, fmt.Errorf("invalid surge multiplier for hotel %s: %q", hotelID, multStr)
+		}
+		surge[strings.TrimSpace(hotelID)] = mult
+	}
+	return surge, nil
+}
+
+// validMultiplier reports whether m is a usable surge multiplier: positive
+// and finite.
+func validMultiplier(m float64) bool {
+	return m > 0 && !math.IsInf(m, 1)
+}
+
+// surgeMultiplier returns the surge multiplier of hotelID, 1 if it has none.
+func (s *Server) surgeMultiplier(hotelID string) float64 {
+	s.surgeMu.RLock()
+	defer s.surgeMu.RUnlock()
+	if m, ok := s.surge[hotelID]; ok {
+		return m
+	}
+	return 1
+}
+
+// SetSurge sets the surge multiplier of a hotel, applied to the rates
+// GetRates returns for it from then on. A multiplier of 1 removes it.
+func (s *Server) SetSurge(ctx context.Context, req *pb.SurgeRequest) (*pb.SurgeResult, error) {
+	if !validMultiplier(req.Multiplier) {
+		return nil, errdetails.InvalidArgument(errpb.ErrorDetail_OUT_OF_RANGE, "multiplier", "multiplier must be positive and finite, got %v", req.Multiplier)
+	}
+
+	s.surgeMu.Lock()
+	previous, ok := s.surge[req.HotelId]
+	if !ok {
+		previous = 1
+	}
+	if req.Multiplier == 1 {
+		delete(s.surge, req.HotelId)
+	} else {
+		if s.surge == nil {
+			s.surge = make(map[string]float64)
+		}
+		s.surge[req.HotelId] = req.Multiplier
+	}
+	s.surgeMu.Unlock()
+
+	tracing.Logger(ctx).Info().Msgf("Surge of hotel %s set to %v, was %v", req.HotelId, req.Multiplier, previous)
+	return &pb.SurgeResult{HotelId: req.HotelId, Multiplier: req.Multiplier, Previous: previous}, nil
+}
+
+// applySurge multiplies the prices of the priced plan by mult, keeping its
+// stay total before surge in baseStayTotal. Surged prices are rounded to two
+// decimal places, and the stay total is the sum of the surged nights. A
+// multiplier of 1 leaves the prices untouched.
+func applySurge(plan *pb.RatePlan, mult float64) {
+	plan.BaseStayTotal = plan.StayTotal
+	plan.SurgeMultiplier = mult
+	if mult == 1 {
+		return
+	}
+
+	if rt := plan.RoomType; rt != nil {
+		rt.BookableRate = convertAmount(rt.BookableRate, mult)
+		rt.TotalRate = convertAmount(rt.TotalRate, mult)
+		rt.TotalRateInclusive = convertAmount(rt.TotalRateInclusive, mult)
+	}
+	plan.StayTotal = 0
+	for _, night := range plan.NightlyRates {
+		night.Rate = convertAmount(night.Rate, mult)
+		plan.StayTotal += night.Rate
+	}
+	plan.StayTotal = math.Round(plan.StayTotal*100) / 100
+}
@@ -0,0 +1,119 @@
+package rate
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/rate/proto"
+	"go.mongodb.org/mongo-driver/bson"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseSurge(t *testing.T) {
+	got, err := ParseSurge(" 1:1.5, 7 : 0.8,,")
+	if err != nil {
+		t.Fatalf("ParseSurge: %v", err)
+	}
+	if want := map[string]float64{"1": 1.5, "7": 0.8}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, err := ParseSurge(""); err != nil || len(got) != 0 {
+		t.Errorf("ParseSurge(\"\") = %v, %v, want no surge", got, err)
+	}
+
+	for _, s := range []string{"1", "1:", "1:high", "1:0", "1:-2", "1:+Inf", "1:NaN"} {
+		if got, err := ParseSurge(s); err == nil {
+			t.Errorf("ParseSurge(%q) = %v, want an error", s, got)
+		}
+	}
+}
+
+// stayOf returns the rate plan of hotelId for the nights of April 9 and 10
+// 2015.
+func stayOf(t *testing.T, s *Server, hotelId string) *pb.RatePlan {
+	t.Helper()
+	res, err := s.GetRates(context.Background(), &pb.Request{HotelIds: []string{hotelId}, InDate: "2015-04-09", OutDate: "2015-04-11"})
+	if err != nil {
+		t.Fatalf("GetRates of hotel %s: %v", hotelId, err)
+	}
+	if len(res.RatePlans) != 1 {
+		t.Fatalf("got %d rate plans of hotel %s, want 1", len(res.RatePlans), hotelId)
+	}
+	return res.RatePlans[0]
+}
+
+// setSurge sets the surge multiplier of hotelId.
+func setSurge(s *Server, hotelId string, mult float64) (*pb.SurgeResult, error) {
+	return s.SetSurge(context.Background(), &pb.SurgeRequest{HotelId: hotelId, Multiplier: mult})
+}
+
+func TestSurgeDefault(t *testing.T) {
+	s := newTestServer(t, []bson.M{rackPlan("1", 100)}, []bson.M{
+		{"hotelId": "1", "code": "RACK", "date": "2015-04-10", "rate": 150.0},
+	})
+	plan := stayOf(t, s, "1")
+	if plan.SurgeMultiplier != 1 || plan.StayTotal != 250 || plan.BaseStayTotal != 250 {
+		t.Errorf("got a stay of %v (base %v) with multiplier %v, want 250 unsurged", plan.StayTotal, plan.BaseStayTotal, plan.SurgeMultiplier)
+	}
+	if plan.RoomType.BookableRate != 100 || plan.NightlyRates[1].Rate != 150 {
+		t.Errorf("got rate %v and second night at %v, want the base 100 and 150", plan.RoomType.BookableRate, plan.NightlyRates[1].Rate)
+	}
+}
+
+func TestSetSurge(t *testing.T) {
+	s := newTestServer(t, []bson.M{rackPlan("1", 100), rackPlan("2", 80)}, []bson.M{
+		{"hotelId": "1", "code": "RACK", "date": "2015-04-10", "rate": 150.0},
+	})
+	stayOf(t, s, "1")
+
+	res, err := setSurge(s, "1", 1.5)
+	if err != nil {
+		t.Fatalf("SetSurge: %v", err)
+	}
+	if res.Multiplier != 1.5 || res.Previous != 1 {
+		t.Errorf("got multiplier %v, previously %v, want 1.5, previously 1", res.Multiplier, res.Previous)
+	}
+	// surged once, even when the rates are cached
+	for i := 0; i < 2; i++ {
+		plan := stayOf(t, s, "1")
+		if plan.SurgeMultiplier != 1.5 || plan.StayTotal != 375 || plan.BaseStayTotal != 250 {
+			t.Errorf("got a stay of %v (base %v) with multiplier %v, want 375 (base 250) with 1.5", plan.StayTotal, plan.BaseStayTotal, plan.SurgeMultiplier)
+		}
+		if plan.RoomType.BookableRate != 150 || plan.NightlyRates[0].Rate != 150 || plan.NightlyRates[1].Rate != 225 {
+			t.Errorf("got rate %v and nights at %v, want 150 and 150, 225", plan.RoomType.BookableRate, plan.NightlyRates)
+		}
+	}
+	if plan := stayOf(t, s, "2"); plan.SurgeMultiplier != 1 || plan.StayTotal != 160 {
+		t.Errorf("other hotel: got a stay of %v with multiplier %v, want 160 unsurged", plan.StayTotal, plan.SurgeMultiplier)
+	}
+
+	// prices are rounded to the cent
+	setSurge(s, "1", 1.0/3)
+	if plan := stayOf(t, s, "1"); plan.NightlyRates[0].Rate != 33.33 || plan.StayTotal != 83.33 {
+		t.Errorf("got nights at %v for a stay of %v, want 33.33 and 50 for 83.33", plan.NightlyRates, plan.StayTotal)
+	}
+
+	// a multiplier of 1 removes the surge
+	if res, err := setSurge(s, "1", 1); err != nil || res.Previous != 1.0/3 {
+		t.Errorf("got %v, %v, want the surge removed", res, err)
+	}
+	if plan := stayOf(t, s, "1"); plan.SurgeMultiplier != 1 || plan.StayTotal != 250 {
+		t.Errorf("surge removed: got a stay of %v with multiplier %v, want 250 unsurged", plan.StayTotal, plan.SurgeMultiplier)
+	}
+}
+
+func TestSetSurgeInvalid(t *testing.T) {
+	s := newTestServer(t, []bson.M{rackPlan("1", 100)}, nil)
+	setSurge(s, "1", 2)
+	for _, mult := range []float64{0, -1.5, math.Inf(1), math.NaN()} {
+		if _, err := setSurge(s, "1", mult); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("multiplier %v: got %v, want InvalidArgument", mult, err)
+		}
+	}
+	if plan := stayOf(t, s, "1"); plan.SurgeMultiplier != 2 {
+		t.Errorf("got multiplier %v, want the 2 set before", plan.SurgeMultiplier)
+	}
+}
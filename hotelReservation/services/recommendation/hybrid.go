@@ -0,0 +1,86 @@
+package recommendation
+
+import (
+	"sort"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails"
+	errpb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails/proto"
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/recommendation/proto"
+)
+
+// hybridStrategy blends the distance, price and rate scores with the
+// weights given in the request.
+const hybridStrategy = "hybrid"
+
+type weightedScorer struct {
+	scorer Scorer
+	weight float64
+	field  string
+}
+
+// rankHybrid returns every hotel ranked by its weighted score, highest first
+// and ties broken by hotel ID, along with the scores. Each factor is min-max
+// normalized to [0, 1] over the hotels before weighting, and the weighted sum
+// is divided by the total weight, so scores are in [0, 1] too. A factor
+// taking the same value for all hotels contributes 0.
+func rankHybrid(hotels map[string]Hotel, req *pb.Request) ([]string, []float64, error) {
+	factors := []weightedScorer{
+		{ScorerFunc(distanceScore), req.DistanceWeight, "distanceWeight"},
+		{ScorerFunc(priceScore), req.PriceWeight, "priceWeight"},
+		{ScorerFunc(rateScore), req.RateWeight, "rateWeight"},
+	}
+
+	total := 0.0
+	for _, f := range factors {
+		if f.weight < 0 {
+			return nil, nil, errdetails.InvalidArgument(errpb.ErrorDetail_OUT_OF_RANGE, f.field, "%s must not be negative, got %v", f.field, f.weight)
+		}
+		total += f.weight
+	}
+	if total == 0 {
+		return nil, nil, errdetails.InvalidArgument(errpb.ErrorDetail_OUT_OF_RANGE, "", "hybrid weights must not sum to zero")
+	}
+
+	ids := make([]string, 0, len(hotels))
+	for id := range hotels {
+		ids = append(ids, id)
+	}
+
+	combined := make(map[string]float64, len(ids))
+	raw := make([]float64, len(ids))
+	for _, f := range factors {
+		if f.weight == 0 {
+			continue
+		}
+
+		min, max := 0.0, 0.0
+		for i, id := range ids {
+			raw[i] = f.scorer.Score(hotels[id], req)
+			if i == 0 || raw[i] < min {
+				min = raw[i]
+			}
+			if i == 0 || raw[i] > max {
+				max = raw[i]
+			}
+		}
+		if max == min {
+			continue
+		}
+		for i, id := range ids {
+			combined[id] += f.weight * (raw[i] - min) / (max - min)
+		}
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		if combined[ids[i]] != combined[ids[j]] {
+			return combined[ids[i]] > combined[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+
+	scores := make([]float64, len(ids))
+	for i, id := range ids {
+		scores[i] = combined[id] / total
+	}
+	return ids, scores, nil
+}
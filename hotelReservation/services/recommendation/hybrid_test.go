@@ -0,0 +1,101 @@
+package recommendation
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/recommendation/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// hybrid returns the hotels ranked with the weights, and their scores.
+func hybrid(t *testing.T, s *Server, distance, price, rate float64) ([]string, []float64) {
+	t.Helper()
+	res, err := recommend(t, s, &pb.Request{Strategy: hybridStrategy, DistanceWeight: distance, PriceWeight: price, RateWeight: rate})
+	if err != nil {
+		t.Fatalf("GetRecommendations: %v", err)
+	}
+	if len(res.Scores) != len(res.HotelIds) {
+		t.Fatalf("got %d scores for %d hotels", len(res.Scores), len(res.HotelIds))
+	}
+	return res.HotelIds, res.Scores
+}
+
+func TestHybridSingleFactor(t *testing.T) {
+	tests := []struct {
+		name                  string
+		distance, price, rate float64
+		want                  []string
+	}{
+		{"distance", 1, 0, 0, []string{"1", "2", "4", "3"}},
+		{"price", 0, 1, 0, []string{"3", "1", "4", "2"}},
+		// the ties on the rate are broken by id
+		{"rate", 0, 0, 1, []string{"2", "4", "1", "3"}},
+	}
+	s := newTestServer(testHotels)
+	for _, tt := range tests {
+		ids, scores := hybrid(t, s, tt.distance, tt.price, tt.rate)
+		if fmt.Sprint(ids) != fmt.Sprint(tt.want) {
+			t.Errorf("%s only: got hotels %v, want %v", tt.name, ids, tt.want)
+		}
+		// min-max normalized, the best scores 1 and the worst 0
+		if scores[0] != 1 || scores[len(scores)-1] != 0 {
+			t.Errorf("%s only: got scores %v, want from 1 down to 0", tt.name, scores)
+		}
+	}
+}
+
+func TestHybridWeightSensitivity(t *testing.T) {
+	s := newTestServer(testHotels)
+	// shifting the weight from the price to the rate raises the hotels
+	// rated best over the cheapest
+	prev := math.Inf(-1)
+	for _, rate := range []float64{0, 1, 2, 4, 8} {
+		ids, scores := hybrid(t, s, 0, 1, rate)
+		byID := make(map[string]float64, len(ids))
+		for i, id := range ids {
+			byID[id] = scores[i]
+		}
+		lead := byID["2"] - byID["3"]
+		if lead <= prev {
+			t.Errorf("rate weight %v: hotel 2 leads hotel 3 by %v, not more than the %v of a lower weight", rate, lead, prev)
+		}
+		prev = lead
+	}
+
+	if ids, _ := hybrid(t, s, 0, 1, 0.1); ids[0] != "3" {
+		t.Errorf("mostly by price: got hotel %s first, want 3", ids[0])
+	}
+	// hotel 4 is rated as well as hotel 2 but cheaper
+	if ids, _ := hybrid(t, s, 0, 0.1, 1); ids[0] != "4" {
+		t.Errorf("mostly by rate: got hotel %s first, want 4", ids[0])
+	}
+}
+
+func TestHybridScaleInvariant(t *testing.T) {
+	s := newTestServer(testHotels)
+	ids, scores := hybrid(t, s, 1, 2, 3)
+	scaledIds, scaledScores := hybrid(t, s, 10, 20, 30)
+	if fmt.Sprint(ids) != fmt.Sprint(scaledIds) {
+		t.Errorf("scaling the weights changed the ranking from %v to %v", ids, scaledIds)
+	}
+	for i := range scores {
+		if d := scores[i] - scaledScores[i]; d > 1e-9 || d < -1e-9 {
+			t.Errorf("scaling the weights changed the score of hotel %s from %v to %v", ids[i], scores[i], scaledScores[i])
+		}
+	}
+}
+
+func TestHybridInvalidWeights(t *testing.T) {
+	s := newTestServer(testHotels)
+	for _, req := range []*pb.Request{
+		{Strategy: hybridStrategy},
+		{Strategy: hybridStrategy, DistanceWeight: -1, PriceWeight: 1},
+	} {
+		if _, err := recommend(t, s, req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("weights %v, %v, %v: got %v, want InvalidArgument", req.DistanceWeight, req.PriceWeight, req.RateWeight, err)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package recommendation
+
+import (
+	"crypto/sha256"
+
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/recommendation/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+// memoKey hashes the deterministic encoding of req normalized for strategy,
+// the strategy it resolves to: the legacy require field is folded into the
+// strategy, unknown fields are left out and -0 is encoded as 0. The
+// coordinates, the strategy and every weight are part of the key, so that
+// requests differing in any of them don't share results.
+func memoKey(req *pb.Request, strategy string) (string, error) {
+	norm := &pb.Request{
+		Lat:            req.Lat,
+		Lon:            req.Lon,
+		Strategy:       strategy,
+		DistanceWeight: req.DistanceWeight,
+		PriceWeight:    req.PriceWeight,
+		RateWeight:     req.RateWeight,
+	}
+	for _, f := range []*float64{&norm.Lat, &norm.Lon, &norm.DistanceWeight, &norm.PriceWeight, &norm.RateWeight} {
+		if *f == 0 {
+			*f = 0
+		}
+	}
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(norm)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return string(sum[:]), nil
+}
@@ -0,0 +1,121 @@
+package recommendation
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/cache"
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/recommendation/proto"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+// newMemoServer returns a server recommending hotels, memoizing the
+// recommendations for ttl.
+func newMemoServer(hotels map[string]Hotel, ttl time.Duration) *Server {
+	s := newTestServer(hotels)
+	s.memo = cache.New(16, ttl)
+	return s
+}
+
+// memoized returns the recommendations of s for req and the memo tag of
+// their span.
+func memoized(t *testing.T, s *Server, req *pb.Request) (*pb.Result, interface{}) {
+	t.Helper()
+	span := mocktracer.New().StartSpan("GetRecommendations").(*mocktracer.MockSpan)
+	res, err := s.GetRecommendations(opentracing.ContextWithSpan(context.Background(), span), req)
+	if err != nil {
+		t.Fatalf("GetRecommendations(%v): %v", req, err)
+	}
+	return res, span.Tag("memo")
+}
+
+func TestMemoHits(t *testing.T) {
+	hotels := make(map[string]Hotel, len(testHotels))
+	for id, h := range testHotels {
+		hotels[id] = h
+	}
+	s := newMemoServer(hotels, time.Minute)
+	req := &pb.Request{Lat: unionSquare.Lat, Lon: unionSquare.Lon, Strategy: "distance"}
+	first, tag := memoized(t, s, req)
+	if tag != "miss" {
+		t.Errorf("first request: got memo=%v, want miss", tag)
+	}
+
+	// the memoized recommendation is served, not ranked again
+	delete(hotels, "1")
+	tests := []struct {
+		name string
+		req  *pb.Request
+	}{
+		{"identical", &pb.Request{Lat: unionSquare.Lat, Lon: unionSquare.Lon, Strategy: "distance"}},
+		{"legacy require", &pb.Request{Lat: unionSquare.Lat, Lon: unionSquare.Lon, Require: "dis"}},
+		{"require overridden", &pb.Request{Lat: unionSquare.Lat, Lon: unionSquare.Lon, Strategy: "distance", Require: "price"}},
+	}
+	for _, tt := range tests {
+		res, tag := memoized(t, s, tt.req)
+		if tag != "hit" {
+			t.Errorf("%s: got memo=%v, want hit", tt.name, tag)
+		}
+		if fmt.Sprint(res.HotelIds) != fmt.Sprint(first.HotelIds) {
+			t.Errorf("%s: got hotels %v, want the memoized %v", tt.name, res.HotelIds, first.HotelIds)
+		}
+	}
+
+	// at 0 and -0 alike
+	memoized(t, s, &pb.Request{Strategy: "rate", RateWeight: 0})
+	if _, tag := memoized(t, s, &pb.Request{Strategy: "rate", RateWeight: math.Copysign(0, -1)}); tag != "hit" {
+		t.Errorf("-0 weight: got memo=%v, want hit", tag)
+	}
+}
+
+func TestMemoMisses(t *testing.T) {
+	base := func() *pb.Request {
+		return &pb.Request{Lat: unionSquare.Lat, Lon: unionSquare.Lon, Strategy: hybridStrategy, DistanceWeight: 1, PriceWeight: 2, RateWeight: 3}
+	}
+	tests := []struct {
+		name   string
+		change func(*pb.Request)
+	}{
+		{"lat", func(r *pb.Request) { r.Lat += 0.001 }},
+		{"lon", func(r *pb.Request) { r.Lon += 0.001 }},
+		{"strategy", func(r *pb.Request) { r.Strategy = "price" }},
+		{"distance weight", func(r *pb.Request) { r.DistanceWeight = 2 }},
+		{"price weight", func(r *pb.Request) { r.PriceWeight = 3 }},
+		{"rate weight", func(r *pb.Request) { r.RateWeight = 4 }},
+	}
+	s := newMemoServer(testHotels, time.Minute)
+	memoized(t, s, base())
+	for _, tt := range tests {
+		req := base()
+		tt.change(req)
+		if _, tag := memoized(t, s, req); tag != "miss" {
+			t.Errorf("%s changed: got memo=%v, want miss", tt.name, tag)
+		}
+	}
+	if _, tag := memoized(t, s, base()); tag != "hit" {
+		t.Errorf("base request again: got memo=%v, want hit", tag)
+	}
+}
+
+func TestMemoExpiry(t *testing.T) {
+	s := newMemoServer(testHotels, 20*time.Millisecond)
+	req := &pb.Request{Lat: unionSquare.Lat, Lon: unionSquare.Lon, Strategy: "price"}
+	memoized(t, s, req)
+	time.Sleep(40 * time.Millisecond)
+	if _, tag := memoized(t, s, req); tag != "miss" {
+		t.Errorf("after the TTL: got memo=%v, want miss", tag)
+	}
+}
+
+func TestMemoDisabled(t *testing.T) {
+	s := newTestServer(testHotels)
+	req := &pb.Request{Lat: unionSquare.Lat, Lon: unionSquare.Lon, Strategy: "price"}
+	memoized(t, s, req)
+	if _, tag := memoized(t, s, req); tag != nil {
+		t.Errorf("without memoization: got memo=%v, want no tag", tag)
+	}
+}
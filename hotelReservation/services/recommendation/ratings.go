@@ -0,0 +1,112 @@
+package recommendation
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/dialer"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/fanout"
+	review "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/review/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
+	"github.com/rs/zerolog/log"
+)
+
+// ratingFanout is how many aggregate ratings are fetched from the review
+// service at once.
+const ratingFanout = 8
+
+// initReviewClient dials the review service through ConsulAddr unless
+// ReviewClient is set.
+func (s *Server) initReviewClient(name string) error {
+	if s.ReviewClient != nil {
+		return nil
+	}
+	target := fmt.Sprintf("consul://%s/%s", s.ConsulAddr, name)
+	opts := []dialer.DialOption{
+		dialer.WithTracer(s.Tracer),
+		dialer.WithUnaryInterceptors(
+			tracing.StatusTaggingUnaryClientInterceptor,
+			tracing.LatencyTaggingUnaryClientInterceptor,
+		),
+	}
+	if s.KnativeDns != "" {
+		target += "." + s.KnativeDns
+	} else {
+		opts = append(opts, dialer.WithBalancer(s.Registry.Client))
+	}
+	conn, err := dialer.Dial(target, opts...)
+	if err != nil {
+		return fmt.Errorf("dialer error: %v", err)
+	}
+	s.ReviewClient = review.NewReviewClient(conn)
+	return nil
+}
+
+// refreshRatings replaces the rating of the reviewed hotels with their
+// average review rating every RatingRefresh, until ctx is done.
+func (s *Server) refreshRatings(ctx context.Context) {
+	ticker := time.NewTicker(s.RatingRefresh)
+	defer ticker.Stop()
+	for {
+		loadCtx, cancel := context.WithTimeout(ctx, s.RatingRefresh)
+		if err := s.loadRatings(loadCtx); err != nil && ctx.Err() == nil {
+			log.Error().Msgf("Failed to refresh the hotel ratings, keeping the current ones: %v", err)
+		}
+		cancel()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// loadRatings gets the aggregate rating of every hotel from the review
+// service and ranks the reviewed hotels by it from then on, the others
+// keeping the rating of the recommendation database rather than the
+// neutral one. The ratings are left as they were if any can't be had.
+func (s *Server) loadRatings(ctx context.Context) error {
+	ids := make([]string, 0, len(s.hotels))
+	for id := range s.hotels {
+		ids = append(ids, id)
+	}
+
+	ratings := make([]*review.AggregateRating, len(ids))
+	errs := make([]error, len(ids))
+	fanout.Each(len(ids), ratingFanout, func(i int) {
+		res, err := s.ReviewClient.GetAggregateRating(ctx, &review.Request{HotelId: ids[i]})
+		if err != nil {
+			errs[i] = err
+			return
+		}
+		ratings[i] = res
+	})
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("failed to get the rating of hotel %s: %v", ids[i], err)
+		}
+	}
+
+	rated := make(map[string]Hotel, len(ids))
+	for i, id := range ids {
+		hotel := s.hotels[id]
+		if ratings[i].Count > 0 {
+			hotel.HRate = float64(ratings[i].Average)
+		}
+		rated[id] = hotel
+	}
+	s.rated.Store(rated)
+	log.Debug().Msgf("Loaded the review ratings of %d hotels", len(rated))
+	return nil
+}
+
+// rankedHotels returns the hotels to rank, rated by their reviews once
+// loaded and by the recommendation database until then.
+func (s *Server) rankedHotels() map[string]Hotel {
+	if rated, ok := s.rated.Load().(map[string]Hotel); ok {
+		return rated
+	}
+	return s.hotels
+}
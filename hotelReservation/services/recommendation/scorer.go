@@ -0,0 +1,106 @@
+package recommendation
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails"
+	errpb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails/proto"
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/recommendation/proto"
+	"github.com/hailocab/go-geoindex"
+)
+
+// Scorer scores a hotel for a recommendation request. Hotels sharing the
+// highest score are recommended.
+type Scorer interface {
+	Score(hotel Hotel, req *pb.Request) float64
+}
+
+// ScorerFunc adapts a function to the Scorer interface.
+type ScorerFunc func(hotel Hotel, req *pb.Request) float64
+
+// Score calls f(hotel, req).
+func (f ScorerFunc) Score(hotel Hotel, req *pb.Request) float64 {
+	return f(hotel, req)
+}
+
+var (
+	scorersMu sync.RWMutex
+	scorers   = map[string]Scorer{
+		"distance": ScorerFunc(distanceScore),
+		"rate":     ScorerFunc(rateScore),
+		"price":    ScorerFunc(priceScore),
+	}
+)
+
+// RegisterScorer makes a scoring strategy available under name, replacing
+// any strategy already registered with that name. The "hybrid" name is
+// reserved for the weighted blend of the built-in strategies.
+func RegisterScorer(name string, scorer Scorer) {
+	scorersMu.Lock()
+	defer scorersMu.Unlock()
+	scorers[name] = scorer
+}
+
+// lookupScorer returns the scorer registered under name, or an
+// InvalidArgument error listing the valid strategies.
+func lookupScorer(name string) (Scorer, error) {
+	scorersMu.RLock()
+	defer scorersMu.RUnlock()
+
+	if scorer, ok := scorers[name]; ok {
+		return scorer, nil
+	}
+
+	names := make([]string, 0, len(scorers)+1)
+	names = append(names, hybridStrategy)
+	for n := range scorers {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return nil, errdetails.InvalidArgument(errpb.ErrorDetail_UNSUPPORTED, "strategy", "unknown strategy %q, valid strategies are: %s", name, strings.Join(names, ", "))
+}
+
+// distanceScore favours the hotels closest to the requested location.
+func distanceScore(hotel Hotel, req *pb.Request) float64 {
+	p1 := &geoindex.GeoPoint{
+		Pid:  "",
+		Plat: req.Lat,
+		Plon: req.Lon,
+	}
+	return -float64(geoindex.Distance(p1, &geoindex.GeoPoint{
+		Pid:  "",
+		Plat: hotel.HLat,
+		Plon: hotel.HLon,
+	})) / 1000
+}
+
+// rateScore favours the best rated hotels, by their reviews once the
+// ratings are loaded.
+func rateScore(hotel Hotel, req *pb.Request) float64 {
+	return hotel.HRate
+}
+
+// priceScore favours the cheapest hotels.
+func priceScore(hotel Hotel, req *pb.Request) float64 {
+	return -hotel.HPrice
+}
+
+// bestHotels returns the IDs of the hotels with the highest score, sorted by
+// hotel ID.
+func bestHotels(hotels map[string]Hotel, scorer Scorer, req *pb.Request) []string {
+	var best []string
+	var max float64
+	for _, hotel := range hotels {
+		score := scorer.Score(hotel, req)
+		switch {
+		case best == nil || score > max:
+			best, max = []string{hotel.HId}, score
+		case score == max:
+			best = append(best, hotel.HId)
+		}
+	}
+	sort.Strings(best)
+	return best
+}
@@ -0,0 +1,113 @@
+package recommendation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/recommendation/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testHotels are around Union Square: hotel 1 is on it, hotel 2 is the best
+// rated along with hotel 4 and hotel 3 is the cheapest.
+var testHotels = map[string]Hotel{
+	"1": {HId: "1", HLat: 37.7879, HLon: -122.4075, HRate: 3.5, HPrice: 180},
+	"2": {HId: "2", HLat: 37.7936, HLon: -122.3965, HRate: 4.5, HPrice: 250},
+	"3": {HId: "3", HLat: 37.7599, HLon: -122.4148, HRate: 2.0, HPrice: 90},
+	"4": {HId: "4", HLat: 37.8024, HLon: -122.4058, HRate: 4.5, HPrice: 210},
+}
+
+// unionSquare is where the test requests are made from.
+var unionSquare = pb.Request{Lat: 37.7880, Lon: -122.4074}
+
+// newTestServer returns a server recommending hotels, without memoization.
+func newTestServer(hotels map[string]Hotel) *Server {
+	return &Server{hotels: hotels}
+}
+
+// recommend returns the recommendations of s for req made from Union
+// Square.
+func recommend(t *testing.T, s *Server, req *pb.Request) (*pb.Result, error) {
+	t.Helper()
+	req.Lat, req.Lon = unionSquare.Lat, unionSquare.Lon
+	return s.GetRecommendations(context.Background(), req)
+}
+
+func TestStrategies(t *testing.T) {
+	tests := []struct {
+		strategy string
+		want     []string
+	}{
+		{"distance", []string{"1"}},
+		{"rate", []string{"2", "4"}},
+		{"price", []string{"3"}},
+	}
+	s := newTestServer(testHotels)
+	for _, tt := range tests {
+		res, err := recommend(t, s, &pb.Request{Strategy: tt.strategy})
+		if err != nil {
+			t.Errorf("%s: %v", tt.strategy, err)
+			continue
+		}
+		if fmt.Sprint(res.HotelIds) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: got hotels %v, want %v", tt.strategy, res.HotelIds, tt.want)
+		}
+	}
+}
+
+func TestLegacyRequire(t *testing.T) {
+	tests := []struct {
+		require string
+		want    []string
+	}{
+		{"dis", []string{"1"}},
+		{"rate", []string{"2", "4"}},
+		{"price", []string{"3"}},
+		{"unknown", nil},
+	}
+	s := newTestServer(testHotels)
+	for _, tt := range tests {
+		res, err := recommend(t, s, &pb.Request{Require: tt.require})
+		if err != nil {
+			t.Errorf("%s: %v", tt.require, err)
+			continue
+		}
+		if fmt.Sprint(res.HotelIds) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: got hotels %v, want %v", tt.require, res.HotelIds, tt.want)
+		}
+	}
+}
+
+func TestUnknownStrategy(t *testing.T) {
+	_, err := recommend(t, newTestServer(testHotels), &pb.Request{Strategy: "stars"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("got %v, want InvalidArgument", err)
+	}
+	for _, name := range []string{"distance", "hybrid", "price", "rate"} {
+		if !strings.Contains(status.Convert(err).Message(), name) {
+			t.Errorf("error %q doesn't list the %s strategy", status.Convert(err).Message(), name)
+		}
+	}
+}
+
+func TestRegisterScorer(t *testing.T) {
+	RegisterScorer("northernmost", ScorerFunc(func(hotel Hotel, req *pb.Request) float64 {
+		return hotel.HLat
+	}))
+	defer func() {
+		scorersMu.Lock()
+		delete(scorers, "northernmost")
+		scorersMu.Unlock()
+	}()
+
+	res, err := recommend(t, newTestServer(testHotels), &pb.Request{Strategy: "northernmost"})
+	if err != nil {
+		t.Fatalf("GetRecommendations: %v", err)
+	}
+	if fmt.Sprint(res.HotelIds) != "[4]" {
+		t.Errorf("got hotels %v, want [4]", res.HotelIds)
+	}
+}
@@ -3,38 +3,77 @@ package recommendation
 import (
 	"context"
 	"fmt"
-	"math"
 	"net"
+	"sync/atomic"
 	"time"
 
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/cache"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/graceful"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/healthcheck"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/registry"
 	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/recommendation/proto"
+	review "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/review/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/store"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tls"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
 	"github.com/google/uuid"
-	"github.com/grpc-ecosystem/grpc-opentracing/go/otgrpc"
-	"github.com/hailocab/go-geoindex"
 	"github.com/opentracing/opentracing-go"
 	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/reflection"
 )
 
 const name = "srv-recommendation"
 
+// validators check requests before their handler runs.
+var validators = map[string]tracing.Validator{
+	pb.Recommendation_GetRecommendations_FullMethodName: tracing.LatLon("lat", "lon"),
+}
+
 // Server implements the recommendation service
 type Server struct {
 	pb.UnimplementedRecommendationServer
 
-	hotels map[string]Hotel
-	uuid   string
+	hotels     map[string]Hotel
+	memo       *cache.LRU
+	uuid       string
+	health     *healthcheck.Checker
+	grpcServer *grpc.Server
+	traffic    tracing.MetricsMark
+	// rated holds the hotels rated by their reviews, a map[string]Hotel,
+	// once loaded.
+	rated       atomic.Value
+	stopRatings context.CancelFunc
 
 	Tracer      opentracing.Tracer
 	Port        int
 	IpAddr      string
+	ConsulAddr  string
+	KnativeDns  string
 	MongoClient *mongo.Client
-	Registry    *registry.Client
+	// DB is the recommendation-db database, that of MongoClient if nil.
+	DB       store.Database
+	Registry *registry.Client
+	// MetricsPort is the admin port serving /metrics, zero to disable it.
+	MetricsPort int
+	// CompressThreshold is the size in bytes from which responses are sent
+	// gzip-compressed.
+	CompressThreshold int
+	// MemoEntries is the number of recommendations of identical requests
+	// memoized for MemoTTL. Either being zero disables memoization.
+	MemoEntries int
+	MemoTTL     time.Duration
+	// RatingRefresh is how often the hotels are rated again by their
+	// reviews. Zero keeps the ratings of the recommendation database.
+	RatingRefresh time.Duration
+
+	// ReviewClient is the client of the review service, dialed through
+	// ConsulAddr by Run if nil and RatingRefresh is set.
+	ReviewClient review.ReviewClient
 }
 
 // Run starts the server
@@ -43,22 +82,44 @@ func (s *Server) Run() error {
 		return fmt.Errorf("server port must be set")
 	}
 
+	if s.DB == nil {
+		s.DB = store.MongoDatabase(s.MongoClient.Database("recommendation-db"))
+	}
+
 	if s.hotels == nil {
-		s.hotels = loadRecommendations(s.MongoClient)
+		s.hotels = loadRecommendations(s.DB)
 	}
 
 	s.uuid = uuid.New().String()
+	s.memo = cache.New(s.MemoEntries, s.MemoTTL)
+
+	if s.RatingRefresh > 0 {
+		if err := s.initReviewClient("srv-review"); err != nil {
+			return err
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		s.stopRatings = cancel
+		go s.refreshRatings(ctx)
+	}
 
 	opts := []grpc.ServerOption{
 		grpc.KeepaliveParams(keepalive.ServerParameters{
 			Timeout: 120 * time.Second,
 		}),
 		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             time.Duration(tune.GetKeepaliveTime()) * time.Millisecond,
 			PermitWithoutStream: true,
 		}),
-		grpc.UnaryInterceptor(
-			otgrpc.OpenTracingServerInterceptor(s.Tracer),
-		),
+		grpc.MaxRecvMsgSize(tune.GetMaxRecvMsgSize()),
+		grpc.MaxSendMsgSize(tune.GetMaxSendMsgSize()),
+		grpc.UnaryInterceptor(tracing.DefaultServerInterceptorChain(tracing.ServerChainOptions{
+			Tracer: s.Tracer,
+			Interceptors: []grpc.UnaryServerInterceptor{
+				tracing.ChaosUnaryServerInterceptor(tracing.DefaultChaos),
+				tracing.CompressionUnaryServerInterceptor(s.CompressThreshold),
+				tracing.ValidationUnaryServerInterceptor(validators),
+			},
+		})),
 	}
 
 	if tlsopt := tls.GetServerOpt(); tlsopt != nil {
@@ -66,8 +127,23 @@ func (s *Server) Run() error {
 	}
 
 	srv := grpc.NewServer(opts...)
+	s.grpcServer = srv
+	s.traffic = tracing.DefaultMetrics.Mark()
 
 	pb.RegisterRecommendationServer(srv, s)
+	if tune.GetGrpcReflection() {
+		reflection.Register(srv)
+	}
+
+	s.health = healthcheck.Register(srv, pb.Recommendation_ServiceDesc.ServiceName, healthcheck.Mongo(s.MongoClient))
+	s.health.Start()
+
+	if s.MetricsPort != 0 {
+		go tracing.ServeMetrics(s.MetricsPort, tracing.DefaultMetrics)
+	}
+	if path := tune.GetFeatureFlagsFile(); path != "" {
+		go tracing.DefaultFlags.WatchFile(path, time.Duration(tune.GetFeatureFlagsPollInterval())*time.Millisecond)
+	}
 
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", s.Port))
 	if err != nil {
@@ -83,86 +159,98 @@ func (s *Server) Run() error {
 	return srv.Serve(lis)
 }
 
-// Shutdown cleans up any processes
+// Shutdown reports the server NOT_SERVING, deregisters it and drains
+// in-flight RPCs before stopping it, then reports the traffic it served.
 func (s *Server) Shutdown() {
+	if s.stopRatings != nil {
+		s.stopRatings()
+	}
+	s.health.Shutdown()
 	s.Registry.Deregister(s.uuid)
+	graceful.Stop(s.grpcServer)
+	graceful.Report(s.grpcServer, s.traffic)
 }
 
 // GiveRecommendation returns recommendations within a given requirement.
+// Recommendations are memoized by normalized request, so that bursts of
+// identical requests are ranked once until the memoized result expires.
 func (s *Server) GetRecommendations(ctx context.Context, req *pb.Request) (*pb.Result, error) {
-	res := new(pb.Result)
 	log.Trace().Msgf("GetRecommendations")
-	require := req.Require
-	if require == "dis" {
-		p1 := &geoindex.GeoPoint{
-			Pid:  "",
-			Plat: req.Lat,
-			Plon: req.Lon,
-		}
-		min := math.MaxFloat64
-		for _, hotel := range s.hotels {
-			tmp := float64(geoindex.Distance(p1, &geoindex.GeoPoint{
-				Pid:  "",
-				Plat: hotel.HLat,
-				Plon: hotel.HLon,
-			})) / 1000
-			if tmp < min {
-				min = tmp
-			}
-		}
-		for _, hotel := range s.hotels {
-			tmp := float64(geoindex.Distance(p1, &geoindex.GeoPoint{
-				Pid:  "",
-				Plat: hotel.HLat,
-				Plon: hotel.HLon,
-			})) / 1000
-			if tmp == min {
-				res.HotelIds = append(res.HotelIds, hotel.HId)
-			}
-		}
-	} else if require == "rate" {
-		max := 0.0
-		for _, hotel := range s.hotels {
-			if hotel.HRate > max {
-				max = hotel.HRate
-			}
-		}
-		for _, hotel := range s.hotels {
-			if hotel.HRate == max {
-				res.HotelIds = append(res.HotelIds, hotel.HId)
-			}
+	strategy := req.Strategy
+	if strategy == "" {
+		// legacy requirement names
+		switch req.Require {
+		case "dis":
+			strategy = "distance"
+		case "rate", "price":
+			strategy = req.Require
+		default:
+			log.Warn().Msgf("Wrong require parameter: %v", req.Require)
+			return new(pb.Result), nil
 		}
-	} else if require == "price" {
-		min := math.MaxFloat64
-		for _, hotel := range s.hotels {
-			if hotel.HPrice < min {
-				min = hotel.HPrice
-			}
+	}
+
+	if s.memo == nil {
+		return s.recommend(req, strategy)
+	}
+	key, err := memoKey(req, strategy)
+	if err != nil {
+		return s.recommend(req, strategy)
+	}
+
+	span := opentracing.SpanFromContext(ctx)
+	if res, ok := s.memo.Get(key, time.Now()); ok {
+		if span != nil {
+			span.SetTag("memo", "hit")
 		}
-		for _, hotel := range s.hotels {
-			if hotel.HPrice == min {
-				res.HotelIds = append(res.HotelIds, hotel.HId)
-			}
+		return res.(*pb.Result), nil
+	}
+	if span != nil {
+		span.SetTag("memo", "miss")
+	}
+
+	res, err := s.recommend(req, strategy)
+	if err != nil {
+		return nil, err
+	}
+	s.memo.Put(key, res, time.Now())
+	return res, nil
+}
+
+// recommend ranks the hotels for req with strategy.
+func (s *Server) recommend(req *pb.Request, strategy string) (*pb.Result, error) {
+	res := new(pb.Result)
+	hotels := s.rankedHotels()
+	if strategy == hybridStrategy {
+		ids, scores, err := rankHybrid(hotels, req)
+		if err != nil {
+			return nil, err
 		}
-	} else {
-		log.Warn().Msgf("Wrong require parameter: %v", require)
+		res.HotelIds, res.Scores = ids, scores
+		return res, nil
+	}
+
+	scorer, err := lookupScorer(strategy)
+	if err != nil {
+		return nil, err
 	}
+	res.HotelIds = bestHotels(hotels, scorer, req)
 
 	return res, nil
 }
 
 // loadRecommendations loads hotel recommendations from mongodb.
-func loadRecommendations(client *mongo.Client) map[string]Hotel {
-	collection := client.Database("recommendation-db").Collection("recommendation")
+func loadRecommendations(db store.Database) map[string]Hotel {
+	collection := db.Collection("recommendation")
 	curr, err := collection.Find(context.TODO(), bson.D{})
 	if err != nil {
-		log.Error().Msgf("Failed get hotels data: ", err)
+		log.Error().Msgf("Failed get hotels data: %v", err)
 	}
 
 	var hotels []Hotel
 	curr.All(context.TODO(), &hotels)
 	if err != nil {
-		log.Error().Msgf("Failed get hotels data: ", err)
+		log.Error().Msgf("Failed get hotels data: %v", err)
 	}
 
 	profiles := make(map[string]Hotel)
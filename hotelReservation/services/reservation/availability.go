@@ -0,0 +1,118 @@
+package reservation
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails"
+	errpb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails/proto"
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/reservation/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/store"
+	"github.com/opentracing/opentracing-go"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxAvailabilityRanges caps the number of ranges checked at once.
+const maxAvailabilityRanges = 100
+
+// CheckAvailabilityMulti checks whether req.RoomNumber rooms of the hotel
+// are left on every night of each range of req. The capacity and the nights
+// reserved over all the ranges are read once, then each range is evaluated
+// on its own, so overlapping ranges don't affect each other. A hotel without
+// rooms of the type is unavailable for every range.
+func (s *Server) CheckAvailabilityMulti(ctx context.Context, req *pb.MultiAvailabilityRequest) (*pb.MultiAvailabilityResult, error) {
+	if len(req.Ranges) > maxAvailabilityRanges {
+		return nil, status.Errorf(codes.InvalidArgument, "cannot check more than %d ranges at once, got %d", maxAvailabilityRanges, len(req.Ranges))
+	}
+	roomType := s.roomType(req.RoomType)
+
+	ranges := make([][]string, len(req.Ranges))
+	var allDates []string
+	seen := make(map[string]bool)
+	for i, r := range req.Ranges {
+		inDate, err := parseDate(r.InDate)
+		if err != nil {
+			return nil, errdetails.InvalidArgument(errpb.ErrorDetail_MALFORMED, fmt.Sprintf("ranges[%d].inDate", i), "invalid inDate %q", r.InDate)
+		}
+		outDate, err := parseDate(r.OutDate)
+		if err != nil {
+			return nil, errdetails.InvalidArgument(errpb.ErrorDetail_MALFORMED, fmt.Sprintf("ranges[%d].outDate", i), "invalid outDate %q", r.OutDate)
+		}
+		if !outDate.After(inDate) {
+			return nil, errdetails.InvalidArgument(errpb.ErrorDetail_OUT_OF_RANGE, fmt.Sprintf("ranges[%d].outDate", i), "outDate %s must be after inDate %s", r.OutDate, r.InDate)
+		}
+		if stayTooLong(inDate, outDate) {
+			return nil, errdetails.InvalidArgument(errpb.ErrorDetail_OUT_OF_RANGE, fmt.Sprintf("ranges[%d].outDate", i), "stay from %s to %s is longer than %d nights", r.InDate, r.OutDate, maxStayNights)
+		}
+		ranges[i] = stayDates(inDate, outDate)
+		for _, date := range ranges[i] {
+			if !seen[date] {
+				seen[date] = true
+				allDates = append(allDates, date)
+			}
+		}
+	}
+
+	capacity, reserved, err := s.nightsReserved(ctx, req.HotelId, roomType, allDates)
+	if err != nil {
+		return nil, store.Error(ctx, err, codes.Internal, "failed to check availability of hotel %s", req.HotelId)
+	}
+
+	res := &pb.MultiAvailabilityResult{
+		HotelId:  req.HotelId,
+		RoomType: roomType,
+		Ranges:   make([]*pb.RangeAvailability, 0, len(req.Ranges)),
+	}
+	for i, dates := range ranges {
+		left := capacity
+		for _, date := range dates {
+			if n := capacity - reserved[date]; n < left {
+				left = n
+			}
+		}
+		if left < 0 {
+			left = 0
+		}
+		res.Ranges = append(res.Ranges, &pb.RangeAvailability{
+			Range:     req.Ranges[i],
+			Available: left >= int(req.RoomNumber),
+			RoomsLeft: int32(left),
+		})
+	}
+	return res, nil
+}
+
+// nightsReserved returns the number of rooms of roomType in hotelId, 0 if it
+// has none, and the number reserved on each of dates that has any, in one
+// query each, the latter on the shard of hotelId.
+func (s *Server) nightsReserved(ctx context.Context, hotelId, roomType string, dates []string) (int, map[string]int, error) {
+	mongoSpan, _ := opentracing.StartSpanFromContext(ctx, "mongo_nights_reserved")
+	mongoSpan.SetTag("span.kind", "client")
+	defer mongoSpan.Finish()
+
+	var num number
+	err := s.DB.Collection("number").FindOne(ctx, bson.D{{Key: "hotelId", Value: hotelId}, s.roomTypeFilter(roomType)}).Decode(&num)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil, nil
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var nights []night
+	curr, err := s.hotelDB(hotelId).Collection("night").Find(ctx, bson.M{"hotelId": hotelId, "roomType": roomType, "date": bson.M{"$in": dates}})
+	if err == nil {
+		err = curr.All(ctx, &nights)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	reserved := make(map[string]int, len(nights))
+	for _, n := range nights {
+		reserved[n.Date] = n.Reserved
+	}
+	return num.Number, reserved, nil
+}
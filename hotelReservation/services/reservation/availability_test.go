@@ -0,0 +1,120 @@
+package reservation
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/reservation/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// checkRanges checks whether rooms rooms of hotelId are left over each of
+// ranges, given as pairs of in and out dates, through the validation of
+// the service.
+func checkRanges(s *Server, hotelId string, rooms int32, ranges ...string) (*pb.MultiAvailabilityResult, error) {
+	req := &pb.MultiAvailabilityRequest{HotelId: hotelId, RoomNumber: rooms}
+	for i := 0; i+1 < len(ranges); i += 2 {
+		req.Ranges = append(req.Ranges, &pb.DateRange{InDate: ranges[i], OutDate: ranges[i+1]})
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: pb.Reservation_CheckAvailabilityMulti_FullMethodName}
+	res, err := tracing.ValidationUnaryServerInterceptor(validators)(context.Background(), req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return s.CheckAvailabilityMulti(ctx, req.(*pb.MultiAvailabilityRequest))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res.(*pb.MultiAvailabilityResult), nil
+}
+
+func TestCheckAvailabilityMulti(t *testing.T) {
+	s := newTestServer(t, map[string]int{"1": 3})
+	// 2 rooms reserved on the night of April 10, 3 on the 11th, 1 on the 12th
+	if _, err := book(s, "1", "2015-04-10", "2015-04-12", 2); err != nil {
+		t.Fatalf("booking: %v", err)
+	}
+	if _, err := book(s, "1", "2015-04-11", "2015-04-13", 1); err != nil {
+		t.Fatalf("booking: %v", err)
+	}
+
+	ranges := []string{
+		"2015-04-08", "2015-04-10",
+		"2015-04-09", "2015-04-11",
+		"2015-04-10", "2015-04-12",
+		// overlapping the one before, and the same as it
+		"2015-04-11", "2015-04-12",
+		"2015-04-10", "2015-04-12",
+		"2015-04-12", "2015-04-14",
+	}
+	want := []struct {
+		left      int32
+		available bool
+	}{{3, true}, {1, true}, {0, false}, {0, false}, {0, false}, {2, true}}
+
+	res, err := checkRanges(s, "1", 1, ranges...)
+	if err != nil {
+		t.Fatalf("CheckAvailabilityMulti: %v", err)
+	}
+	if res.HotelId != "1" || res.RoomType != "standard" || len(res.Ranges) != len(want) {
+		t.Fatalf("got %v, want %d ranges of the standard rooms of hotel 1", res, len(want))
+	}
+	for i, w := range want {
+		r := res.Ranges[i]
+		if r.Range.InDate != ranges[2*i] || r.Range.OutDate != ranges[2*i+1] {
+			t.Errorf("range %d is %v, want from %s to %s", i, r.Range, ranges[2*i], ranges[2*i+1])
+		}
+		if r.RoomsLeft != w.left || r.Available != w.available {
+			t.Errorf("range %d: got %d rooms left (available %v), want %d (%v)", i, r.RoomsLeft, r.Available, w.left, w.available)
+		}
+		// as CheckAvailability has it
+		if single := available(t, s, ranges[2*i], ranges[2*i+1], 1, "1")["1"]; single != r.Available {
+			t.Errorf("range %d: available %v, CheckAvailability says %v", i, r.Available, single)
+		}
+	}
+
+	// more rooms than left in any range
+	res, err = checkRanges(s, "1", 3, ranges...)
+	if err != nil {
+		t.Fatalf("CheckAvailabilityMulti of 3 rooms: %v", err)
+	}
+	for i, r := range res.Ranges {
+		if r.Available != (i == 0) {
+			t.Errorf("3 rooms over range %d: available %v, want %v", i, r.Available, i == 0)
+		}
+	}
+}
+
+func TestCheckAvailabilityMultiUnknownHotel(t *testing.T) {
+	s := newTestServer(t, map[string]int{"1": 3})
+	res, err := checkRanges(s, "2", 1, "2015-04-09", "2015-04-10", "2015-04-10", "2015-04-11")
+	if err != nil {
+		t.Fatalf("CheckAvailabilityMulti: %v", err)
+	}
+	for i, r := range res.Ranges {
+		if r.Available || r.RoomsLeft != 0 {
+			t.Errorf("range %d: got %d rooms left (available %v), want none", i, r.RoomsLeft, r.Available)
+		}
+	}
+}
+
+func TestCheckAvailabilityMultiInvalid(t *testing.T) {
+	s := newTestServer(t, map[string]int{"1": 3})
+	tests := []struct {
+		name   string
+		rooms  int32
+		ranges []string
+	}{
+		{"no ranges", 1, nil},
+		{"no rooms", 0, []string{"2015-04-09", "2015-04-10"}},
+		{"malformed date", 1, []string{"2015-04-09", "2015-04-10", "2015-04-10", "tomorrow"}},
+		{"empty range", 1, []string{"2015-04-09", "2015-04-09"}},
+		{"too long", 1, []string{"2015-04-01", "2015-05-02"}},
+	}
+	for _, tt := range tests {
+		if res, err := checkRanges(s, "1", tt.rooms, tt.ranges...); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%s: got %v, %v, want InvalidArgument", tt.name, res, err)
+		}
+	}
+}
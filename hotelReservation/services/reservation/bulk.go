@@ -0,0 +1,270 @@
+package reservation
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails"
+	errpb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails/proto"
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/reservation/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/store"
+	"github.com/google/uuid"
+	"github.com/opentracing/opentracing-go"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxBulkReservations caps the number of reservations inserted at once.
+const maxBulkReservations = 10000
+
+// bulkRecord is a validated ReservationRecord.
+type bulkRecord struct {
+	id       string
+	hotelId  string
+	roomType string
+	customer string
+	rooms    int
+	// nights of the stay, by check-in date
+	dates []string
+}
+
+// roomsKey identifies the rooms of a type in a hotel.
+type roomsKey struct {
+	hotelId  string
+	roomType string
+}
+
+// BulkInsertReservations inserts the reservations of req in one InsertMany
+// per shard, holding their rooms like MakeReservation does. Every record is
+// checked first, for its dates and for the capacity of its room type given
+// the reservations already made and those of the records before it. If any
+// record fails, nothing is inserted and the error has an ErrorDetail per
+// failed record naming its index, e.g. field "reservations[3].outDate": it
+// is InvalidArgument if a record is malformed, and FailedPrecondition if
+// records only exceed capacity.
+func (s *Server) BulkInsertReservations(ctx context.Context, req *pb.BulkInsertRequest) (*pb.BulkInsertResult, error) {
+	if len(req.Reservations) > maxBulkReservations {
+		return nil, status.Errorf(codes.InvalidArgument, "cannot insert more than %d reservations at once, got %d", maxBulkReservations, len(req.Reservations))
+	}
+
+	records, invalid := s.parseBulkRecords(req.Reservations)
+	if len(invalid) > 0 {
+		return nil, bulkError(codes.InvalidArgument, invalid)
+	}
+	capacities, failed, err := s.checkBulkCapacity(ctx, records)
+	if err != nil {
+		return nil, store.Error(ctx, err, codes.Internal, "failed to check capacity")
+	}
+	if len(failed) > 0 {
+		return nil, bulkError(codes.FailedPrecondition, failed)
+	}
+
+	// The check above reads counts that concurrent bookings may change, so
+	// hold the rooms with conditional updates before inserting, like
+	// MakeReservation.
+	for i, r := range records {
+		claimed, err := s.claimNights(ctx, r.hotelId, r.roomType, r.dates, r.rooms, capacities[roomsKey{r.hotelId, r.roomType}])
+		if err != nil || !claimed {
+			s.releaseBulkNights(ctx, records[:i])
+			if err != nil {
+				return nil, store.Error(ctx, err, codes.Internal, "failed to reserve hotel %s", r.hotelId)
+			}
+			return nil, status.Errorf(codes.Aborted, "reservations[%d]: concurrent bookings filled hotel %s, retry", i, r.hotelId)
+		}
+	}
+
+	// one InsertMany per shard, with the nights of its hotels
+	docs := make(map[int][]interface{})
+	ids := make([]string, 0, len(records))
+	for _, r := range records {
+		shard := s.ring.Shard(r.hotelId)
+		for _, date := range r.dates {
+			in, _ := parseDate(date)
+			docs[shard] = append(docs[shard], reservation{
+				ReservationId: r.id,
+				HotelId:       r.hotelId,
+				RoomType:      r.roomType,
+				CustomerName:  r.customer,
+				InDate:        date,
+				OutDate:       in.AddDate(0, 0, 1).String()[0:10],
+				Number:        r.rooms,
+			})
+		}
+		ids = append(ids, r.id)
+	}
+
+	mongoSpan, _ := opentracing.StartSpanFromContext(ctx, "mongo_bulk_insert_reservations")
+	mongoSpan.SetTag("span.kind", "client")
+	var inserted []int
+	for shard, shardDocs := range docs {
+		inserted = append(inserted, shard)
+		if _, err = s.Shards[shard].Collection("reservation").InsertMany(ctx, shardDocs); err != nil {
+			break
+		}
+	}
+	mongoSpan.Finish()
+	if err != nil {
+		// The documents before the failing one were inserted. Remove them
+		// even if ctx is cancelled, which may be why the insert failed.
+		for _, shard := range inserted {
+			if _, delErr := s.Shards[shard].Collection("reservation").DeleteMany(context.Background(), bson.M{"reservationId": bson.M{"$in": ids}}); delErr != nil {
+				log.Error().Msgf("Failed to remove partially inserted reservations: %v", delErr)
+			}
+		}
+		s.releaseBulkNights(ctx, records)
+		return nil, store.Error(ctx, err, codes.Internal, "failed to insert reservations")
+	}
+
+	log.Info().Msgf("Inserted %d reservations in bulk", len(records))
+	return &pb.BulkInsertResult{ReservationIds: ids}, nil
+}
+
+// parseBulkRecords validates records, returning them parsed along with the
+// details of the malformed ones.
+func (s *Server) parseBulkRecords(records []*pb.ReservationRecord) ([]bulkRecord, []*errpb.ErrorDetail) {
+	var (
+		parsed  = make([]bulkRecord, 0, len(records))
+		invalid []*errpb.ErrorDetail
+	)
+	fail := func(i int, reason errpb.ErrorDetail_Reason, field, format string, a ...interface{}) {
+		invalid = append(invalid, &errpb.ErrorDetail{
+			Reason:      reason,
+			Field:       fmt.Sprintf("reservations[%d].%s", i, field),
+			Description: fmt.Sprintf(format, a...),
+		})
+	}
+
+	for i, rec := range records {
+		if rec.HotelId == "" {
+			fail(i, errpb.ErrorDetail_MISSING, "hotelId", "hotelId is required")
+			continue
+		}
+		if rec.RoomNumber <= 0 {
+			fail(i, errpb.ErrorDetail_OUT_OF_RANGE, "roomNumber", "roomNumber must be positive, got %d", rec.RoomNumber)
+			continue
+		}
+		inDate, err := parseDate(rec.InDate)
+		if err != nil {
+			fail(i, errpb.ErrorDetail_MALFORMED, "inDate", "invalid inDate %q", rec.InDate)
+			continue
+		}
+		outDate, err := parseDate(rec.OutDate)
+		if err != nil {
+			fail(i, errpb.ErrorDetail_MALFORMED, "outDate", "invalid outDate %q", rec.OutDate)
+			continue
+		}
+		if !outDate.After(inDate) {
+			fail(i, errpb.ErrorDetail_OUT_OF_RANGE, "outDate", "outDate %s must be after inDate %s", rec.OutDate, rec.InDate)
+			continue
+		}
+		if stayTooLong(inDate, outDate) {
+			fail(i, errpb.ErrorDetail_OUT_OF_RANGE, "outDate", "stay from %s to %s is longer than %d nights", rec.InDate, rec.OutDate, maxStayNights)
+			continue
+		}
+
+		parsed = append(parsed, bulkRecord{
+			id:       uuid.New().String(),
+			hotelId:  rec.HotelId,
+			roomType: s.roomType(rec.RoomType),
+			customer: rec.CustomerName,
+			rooms:    int(rec.RoomNumber),
+			dates:    stayDates(inDate, outDate),
+		})
+	}
+	return parsed, invalid
+}
+
+// checkBulkCapacity checks that every night of records has rooms left for
+// it, once the rooms already reserved and those of the records before it
+// are taken. It returns the capacity of every room type of records, and the
+// details of the records that don't fit.
+func (s *Server) checkBulkCapacity(ctx context.Context, records []bulkRecord) (map[roomsKey]int, []*errpb.ErrorDetail, error) {
+	dates := make(map[roomsKey][]string)
+	for _, r := range records {
+		k := roomsKey{r.hotelId, r.roomType}
+		dates[k] = append(dates[k], r.dates...)
+	}
+
+	mongoSpan, _ := opentracing.StartSpanFromContext(ctx, "mongo_bulk_check_capacity")
+	mongoSpan.SetTag("span.kind", "client")
+	defer mongoSpan.Finish()
+
+	numCollection := s.DB.Collection("number")
+	capacities := make(map[roomsKey]int, len(dates))
+	reserved := make(map[roomsKey]map[string]int, len(dates))
+	for k, ds := range dates {
+		var num number
+		err := numCollection.FindOne(ctx, bson.D{{Key: "hotelId", Value: k.hotelId}, s.roomTypeFilter(k.roomType)}).Decode(&num)
+		if err == mongo.ErrNoDocuments {
+			// no rooms, which fails the records below
+			capacities[k] = -1
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		capacities[k] = num.Number
+
+		var nights []night
+		curr, err := s.hotelDB(k.hotelId).Collection("night").Find(ctx, bson.M{"hotelId": k.hotelId, "roomType": k.roomType, "date": bson.M{"$in": ds}})
+		if err == nil {
+			err = curr.All(ctx, &nights)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		reserved[k] = make(map[string]int, len(nights))
+		for _, n := range nights {
+			reserved[k][n.Date] = n.Reserved
+		}
+	}
+
+	var failed []*errpb.ErrorDetail
+next:
+	for i, r := range records {
+		k := roomsKey{r.hotelId, r.roomType}
+		capacity := capacities[k]
+		if capacity < 0 {
+			failed = append(failed, &errpb.ErrorDetail{
+				Reason:      errpb.ErrorDetail_UNSUPPORTED,
+				Field:       fmt.Sprintf("reservations[%d].roomType", i),
+				Description: fmt.Sprintf("hotel %s has no %s rooms", r.hotelId, r.roomType),
+			})
+			continue
+		}
+		for _, date := range r.dates {
+			if n := reserved[k][date]; n+r.rooms > capacity {
+				failed = append(failed, &errpb.ErrorDetail{
+					Reason:      errpb.ErrorDetail_OUT_OF_RANGE,
+					Field:       fmt.Sprintf("reservations[%d].roomNumber", i),
+					Description: fmt.Sprintf("hotel %s has %d of %d %s rooms reserved on %s, cannot reserve %d more", r.hotelId, n, capacity, r.roomType, date, r.rooms),
+				})
+				continue next
+			}
+		}
+		for _, date := range r.dates {
+			reserved[k][date] += r.rooms
+		}
+	}
+	if len(failed) > 0 {
+		return nil, failed, nil
+	}
+	return capacities, nil, nil
+}
+
+// releaseBulkNights gives back the rooms held for records.
+func (s *Server) releaseBulkNights(ctx context.Context, records []bulkRecord) {
+	for _, r := range records {
+		s.releaseNights(ctx, r.hotelId, r.roomType, r.dates, r.rooms)
+	}
+}
+
+// bulkError returns an error of code listing the records failing for
+// details.
+func bulkError(code codes.Code, details []*errpb.ErrorDetail) error {
+	return errdetails.Errorf(code, details, "%d reservations failed validation, none were inserted; first %s: %s",
+		len(details), details[0].Field, details[0].Description)
+}
@@ -0,0 +1,139 @@
+package reservation
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails"
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/reservation/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// record is a reservation of rooms of hotelId from inDate to outDate.
+func record(hotelId, inDate, outDate string, rooms int32) *pb.ReservationRecord {
+	return &pb.ReservationRecord{CustomerName: "Cornell_1", HotelId: hotelId, InDate: inDate, OutDate: outDate, RoomNumber: rooms}
+}
+
+// bulkInsert inserts records in bulk.
+func bulkInsert(s *Server, records ...*pb.ReservationRecord) (*pb.BulkInsertResult, error) {
+	return s.BulkInsertReservations(context.Background(), &pb.BulkInsertRequest{Reservations: records})
+}
+
+// failedFields returns the fields of the records err lists as failed.
+func failedFields(err error) []string {
+	var fields []string
+	for _, d := range errdetails.FromError(err) {
+		fields = append(fields, d.Field)
+	}
+	return fields
+}
+
+func TestBulkInsert(t *testing.T) {
+	s := newTestServer(t, map[string]int{"1": 2, "2": 3})
+	res, err := bulkInsert(s,
+		record("1", "2015-04-09", "2015-04-11", 1),
+		record("1", "2015-04-10", "2015-04-12", 1),
+		record("2", "2015-04-09", "2015-04-10", 3),
+	)
+	if err != nil {
+		t.Fatalf("BulkInsertReservations: %v", err)
+	}
+	if len(res.ReservationIds) != 3 {
+		t.Errorf("got %d reservation IDs, want 3", len(res.ReservationIds))
+	}
+	// a reservation per night
+	if n := reservations(t, s, "1"); n != 4 {
+		t.Errorf("got %d reservations of hotel 1, want 4", n)
+	}
+	if n := reservations(t, s, "2"); n != 1 {
+		t.Errorf("got %d reservations of hotel 2, want 1", n)
+	}
+
+	// the rooms are held like bookings hold them
+	if _, err := book(s, "1", "2015-04-10", "2015-04-11", 1); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("booking hotel 1 filled up in bulk: got %v, want FailedPrecondition", err)
+	}
+	if _, err := book(s, "1", "2015-04-09", "2015-04-10", 1); err != nil {
+		t.Errorf("booking the room left in hotel 1: %v", err)
+	}
+}
+
+func TestBulkInsertInvalidRecord(t *testing.T) {
+	s := newTestServer(t, map[string]int{"1": 2})
+	_, err := bulkInsert(s,
+		record("1", "2015-04-09", "2015-04-11", 1),
+		record("1", "2015-04-12", "2015-04-10", 1),
+		record("1", "2015-04-09", "2015-04-10", 1),
+	)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("got %v, want InvalidArgument", err)
+	}
+	if fields := failedFields(err); len(fields) != 1 || fields[0] != "reservations[1].outDate" {
+		t.Errorf("got failed fields %v, want reservations[1].outDate", fields)
+	}
+	// none of the valid records were inserted
+	if n := reservations(t, s, "1"); n != 0 {
+		t.Errorf("got %d reservations, want none", n)
+	}
+	if _, err := book(s, "1", "2015-04-09", "2015-04-11", 2); err != nil {
+		t.Errorf("booking every room after the failed batch: %v", err)
+	}
+}
+
+func TestBulkInsertOverCapacity(t *testing.T) {
+	s := newTestServer(t, map[string]int{"1": 2})
+	if _, err := book(s, "1", "2015-04-10", "2015-04-11", 1); err != nil {
+		t.Fatalf("booking: %v", err)
+	}
+
+	// the second record fits alone, not after the first and the booking
+	_, err := bulkInsert(s,
+		record("1", "2015-04-09", "2015-04-11", 1),
+		record("1", "2015-04-10", "2015-04-12", 1),
+		record("2", "2015-04-09", "2015-04-10", 1),
+	)
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("got %v, want FailedPrecondition", err)
+	}
+	want := []string{"reservations[1].roomNumber", "reservations[2].roomType"}
+	if fields := failedFields(err); len(fields) != 2 || fields[0] != want[0] || fields[1] != want[1] {
+		t.Errorf("got failed fields %v, want %v", fields, want)
+	}
+	if n := reservations(t, s, "1"); n != 1 {
+		t.Errorf("got %d reservations, want the booking only", n)
+	}
+}
+
+func TestBulkInsertAdminOnly(t *testing.T) {
+	s := newTestServer(t, map[string]int{"1": 2})
+	admin, err := tracing.AdminUnaryServerInterceptor([]string{"10.0.0.0/8"}, pb.Reservation_BulkInsertReservations_FullMethodName)
+	if err != nil {
+		t.Fatalf("AdminUnaryServerInterceptor: %v", err)
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: pb.Reservation_BulkInsertReservations_FullMethodName}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return s.BulkInsertReservations(ctx, req.(*pb.BulkInsertRequest))
+	}
+	req := &pb.BulkInsertRequest{Reservations: []*pb.ReservationRecord{record("1", "2015-04-09", "2015-04-10", 1)}}
+
+	for _, tt := range []struct {
+		ip   string
+		want codes.Code
+	}{
+		{"192.168.1.5", codes.PermissionDenied},
+		{"10.1.2.3", codes.OK},
+	} {
+		ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(tt.ip), Port: 50000}})
+		if _, err := admin(ctx, req, info, handler); status.Code(err) != tt.want {
+			t.Errorf("caller %s: got %v, want %v", tt.ip, err, tt.want)
+		}
+	}
+	if n := reservations(t, s, "1"); n != 1 {
+		t.Errorf("got %d reservations, want the allowlisted caller's only", n)
+	}
+}
@@ -0,0 +1,151 @@
+package reservation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/reservation/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/store"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// blockingDB is a database whose queries of one collection run until their
+// context is done, reporting when they start and the context error they
+// end with.
+type blockingDB struct {
+	store.Database
+	collection string
+	started    chan struct{}
+	ended      chan error
+}
+
+func newBlockingDB(db store.Database, collection string) *blockingDB {
+	return &blockingDB{Database: db, collection: collection, started: make(chan struct{}, 16), ended: make(chan error, 16)}
+}
+
+func (db *blockingDB) Collection(name string) store.Collection {
+	if name != db.collection {
+		return db.Database.Collection(name)
+	}
+	return blockingCollection{db.Database.Collection(name), db}
+}
+
+type blockingCollection struct {
+	store.Collection
+	db *blockingDB
+}
+
+func (c blockingCollection) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	c.db.started <- struct{}{}
+	<-ctx.Done()
+	c.db.ended <- ctx.Err()
+	return nil, ctx.Err()
+}
+
+// checkCancelled calls CheckAvailability of s for req through the
+// cancellation tagging, cancelling it once a query of db starts. It checks
+// that the query sees the cancellation and that the call fails as Canceled
+// with its span tagged cancelled=true.
+func checkCancelled(t *testing.T, s *Server, db *blockingDB, req *pb.Request) {
+	t.Helper()
+	span := mocktracer.New().StartSpan("CheckAvailability").(*mocktracer.MockSpan)
+	ctx, cancel := context.WithCancel(opentracing.ContextWithSpan(context.Background(), span))
+	defer cancel()
+	info := &grpc.UnaryServerInfo{FullMethod: pb.Reservation_CheckAvailability_FullMethodName}
+	errs := make(chan error, 1)
+	go func() {
+		_, err := tracing.CancellationTaggingUnaryServerInterceptor(ctx, req, info,
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return s.CheckAvailability(ctx, req.(*pb.Request))
+			})
+		errs <- err
+	}()
+
+	select {
+	case <-db.started:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s never queried", db.collection)
+	}
+	cancel()
+
+	select {
+	case err := <-db.ended:
+		if err != context.Canceled {
+			t.Errorf("query of %s ended with %v, want context.Canceled", db.collection, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("query of %s not cancelled", db.collection)
+	}
+	select {
+	case err := <-errs:
+		if status.Code(err) != codes.Canceled {
+			t.Errorf("got %v, want Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("CheckAvailability still running after the cancellation")
+	}
+	if span.Tag("cancelled") != true {
+		t.Errorf("cancelled = %v, want true", span.Tag("cancelled"))
+	}
+}
+
+func TestCheckAvailabilityClientDisconnectCapacity(t *testing.T) {
+	s := newTestServer(t, map[string]int{"1": 2})
+	db := newBlockingDB(s.DB, "number")
+	s.DB = db
+
+	checkCancelled(t, s, db, &pb.Request{HotelId: []string{"1"}, InDate: "2015-04-09", OutDate: "2015-04-10", RoomNumber: 1})
+
+	s.DB = db.Database
+	if got := available(t, s, "2015-04-09", "2015-04-10", 1, "1"); !got["1"] {
+		t.Errorf("hotel 1 unavailable once the capacity is queried uncancelled")
+	}
+}
+
+func TestCheckAvailabilityClientDisconnectReservations(t *testing.T) {
+	s := newTestServer(t, map[string]int{"1": 2})
+	// cache the capacity, leaving the reservations of the stay to query
+	available(t, s, "2015-03-01", "2015-03-02", 1, "1")
+	db := newBlockingDB(s.Shards[0], "reservation")
+	s.Shards = []store.Database{db}
+
+	checkCancelled(t, s, db, &pb.Request{HotelId: []string{"1"}, InDate: "2015-04-09", OutDate: "2015-04-12", RoomNumber: 1})
+	// every night of the stay is queried at once, each query aborted
+	for i := 1; i < 3; i++ {
+		select {
+		case err := <-db.ended:
+			if err != context.Canceled {
+				t.Errorf("query ended with %v, want context.Canceled", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%d of the 3 nightly queries cancelled", i)
+		}
+	}
+}
+
+func TestCheckAvailabilityUntaggedFailure(t *testing.T) {
+	s := newTestServer(t, map[string]int{"1": 2})
+
+	span := mocktracer.New().StartSpan("CheckAvailability").(*mocktracer.MockSpan)
+	ctx := opentracing.ContextWithSpan(context.Background(), span)
+	info := &grpc.UnaryServerInfo{FullMethod: pb.Reservation_CheckAvailability_FullMethodName}
+	// a stay too long fails without any cancellation
+	_, err := tracing.CancellationTaggingUnaryServerInterceptor(ctx, &pb.Request{HotelId: []string{"1"}, InDate: "2015-04-09", OutDate: "2016-04-09", RoomNumber: 1}, info,
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return s.CheckAvailability(ctx, req.(*pb.Request))
+		})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("got %v, want InvalidArgument", err)
+	}
+	if tag := span.Tag("cancelled"); tag != nil {
+		t.Errorf("cancelled = %v on an invalid request, want no tag", tag)
+	}
+}
@@ -0,0 +1,130 @@
+package reservation
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/reservation/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/store"
+	"github.com/opentracing/opentracing-go"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// idempotencyKey records a MakeReservation made with an idempotency key,
+// stored in the reservation-db.idempotency collection with a unique index on
+// key. It is claimed before booking, without a reservationId, and completed
+// with the result once booked. RequestHash tells retries of the request
+// apart from other requests reusing the key.
+type idempotencyKey struct {
+	Key           string    `bson:"key"`
+	RequestHash   string    `bson:"requestHash"`
+	CreatedAt     time.Time `bson:"createdAt"`
+	ReservationId string    `bson:"reservationId,omitempty"`
+	HotelId       string    `bson:"hotelId,omitempty"`
+	RoomType      string    `bson:"roomType,omitempty"`
+}
+
+// requestHash hashes what req books, its idempotency key aside.
+func (s *Server) requestHash(req *pb.Request) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%q %q %q %q %d %q", req.CustomerName, req.HotelId, req.InDate, req.OutDate, req.RoomNumber, s.roomType(req.RoomType))
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// claimIdempotencyKey claims the idempotency key of req before booking. It
+// returns the result of the reservation first made with the key if req is a
+// retry of it, or nil if the key is new or expired and now claimed by req.
+// It fails with AlreadyExists if the key was used for a different request,
+// and with Aborted while the first request is still booking.
+func (s *Server) claimIdempotencyKey(ctx context.Context, req *pb.Request) (*pb.Result, error) {
+	keyCollection := s.DB.Collection("idempotency")
+	key, hash := req.IdempotencyKey, s.requestHash(req)
+	now := time.Now().UTC()
+
+	mongoSpan, _ := opentracing.StartSpanFromContext(ctx, "mongo_claim_idempotency_key")
+	mongoSpan.SetTag("span.kind", "client")
+	defer mongoSpan.Finish()
+
+	_, err := keyCollection.InsertOne(ctx, idempotencyKey{Key: key, RequestHash: hash, CreatedAt: now})
+	if err == nil {
+		return nil, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return nil, store.Error(ctx, err, codes.Internal, "failed to claim idempotency key %q", key)
+	}
+
+	// The key is taken: take it over if it expired, else replay it.
+	var prior idempotencyKey
+	filter := bson.M{"key": key, "createdAt": bson.M{"$lte": now.Add(-s.IdempotencyWindow)}}
+	update := bson.M{
+		"$set":   bson.M{"requestHash": hash, "createdAt": now},
+		"$unset": bson.M{"reservationId": "", "hotelId": "", "roomType": ""},
+	}
+	err = keyCollection.FindOneAndUpdate(ctx, filter, update).Decode(&prior)
+	if err == nil {
+		log.Debug().Msgf("Idempotency key %q expired, reusing it", key)
+		return nil, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, store.Error(ctx, err, codes.Internal, "failed to claim idempotency key %q", key)
+	}
+
+	err = keyCollection.FindOne(ctx, bson.M{"key": key}).Decode(&prior)
+	if err == mongo.ErrNoDocuments {
+		// released by a failed booking since the insert
+		return nil, status.Errorf(codes.Aborted, "idempotency key %q was just released, retry", key)
+	}
+	if err != nil {
+		return nil, store.Error(ctx, err, codes.Internal, "failed to find idempotency key %q", key)
+	}
+	if prior.RequestHash != hash {
+		return nil, status.Errorf(codes.AlreadyExists, "idempotency key %q was used for a different reservation", key)
+	}
+	if prior.ReservationId == "" {
+		return nil, status.Errorf(codes.Aborted, "reservation with idempotency key %q is in progress, retry", key)
+	}
+
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		span.SetTag("reservation.replayed", true)
+	}
+	log.Debug().Msgf("Replaying reservation %s for idempotency key %q", prior.ReservationId, key)
+	return &pb.Result{
+		HotelId:       []string{prior.HotelId},
+		ReservationId: prior.ReservationId,
+		RoomType:      prior.RoomType,
+	}, nil
+}
+
+// completeIdempotencyKey records res as the result of the reservation made
+// with key, for retries to replay. The booking is made by then, so the
+// record outlives ctx: without it, the retries of a caller that gave up
+// would fail as Aborted until the key expires, then book again.
+func (s *Server) completeIdempotencyKey(ctx context.Context, key string, res *pb.Result) {
+	keyCollection := s.DB.Collection("idempotency")
+	update := bson.M{"$set": bson.M{
+		"reservationId": res.ReservationId,
+		"hotelId":       res.HotelId[0],
+		"roomType":      res.RoomType,
+	}}
+	if _, err := keyCollection.UpdateOne(context.Background(), bson.M{"key": key}, update); err != nil {
+		log.Error().Msgf("Failed to record reservation %s for idempotency key %q: %v", res.ReservationId, key, err)
+	}
+}
+
+// releaseIdempotencyKey gives up the claim on key of a booking that failed,
+// so that it can be retried. It outlives ctx, as a booking failing is often
+// due to ctx being done, and a key left claimed fails the retries as Aborted
+// until it expires.
+func (s *Server) releaseIdempotencyKey(ctx context.Context, key string) {
+	keyCollection := s.DB.Collection("idempotency")
+	filter := bson.M{"key": key, "reservationId": bson.M{"$exists": false}}
+	if _, err := keyCollection.DeleteOne(context.Background(), filter); err != nil {
+		log.Error().Msgf("Failed to release idempotency key %q: %v", key, err)
+	}
+}
@@ -0,0 +1,159 @@
+package reservation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/reservation/proto"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"go.mongodb.org/mongo-driver/bson"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newIdempotentTestServer is newTestServer keeping idempotency keys for
+// window.
+func newIdempotentTestServer(t *testing.T, capacities map[string]int, window time.Duration) *Server {
+	t.Helper()
+	s := newTestServer(t, capacities)
+	s.IdempotencyWindow = window
+	return s
+}
+
+// bookOnce is book with an idempotency key, returning the span of the call.
+func bookOnce(s *Server, key, hotelId string, rooms int32) (*pb.Result, *mocktracer.MockSpan, error) {
+	span := mocktracer.New().StartSpan("test").(*mocktracer.MockSpan)
+	res, err := s.MakeReservation(opentracing.ContextWithSpan(context.Background(), span), &pb.Request{
+		CustomerName:   "Cornell_1",
+		HotelId:        []string{hotelId},
+		InDate:         "2015-04-09",
+		OutDate:        "2015-04-10",
+		RoomNumber:     rooms,
+		IdempotencyKey: key,
+	})
+	return res, span, err
+}
+
+// reservations returns the number of reservations of hotelId.
+func reservations(t *testing.T, s *Server, hotelId string) int {
+	t.Helper()
+	var res []reservation
+	cur, err := s.DB.Collection("reservation").Find(context.Background(), bson.M{"hotelId": hotelId})
+	if err == nil {
+		err = cur.All(context.Background(), &res)
+	}
+	if err != nil {
+		t.Fatalf("finding reservations: %v", err)
+	}
+	return len(res)
+}
+
+func TestIdempotentRetry(t *testing.T) {
+	s := newIdempotentTestServer(t, map[string]int{"1": 2}, time.Hour)
+	first, span, err := bookOnce(s, "key-1", "1", 1)
+	if err != nil {
+		t.Fatalf("booking: %v", err)
+	}
+	if span.Tag("reservation.replayed") != nil {
+		t.Errorf("first booking tagged replayed")
+	}
+
+	for i := 0; i < 3; i++ {
+		retry, span, err := bookOnce(s, "key-1", "1", 1)
+		if err != nil {
+			t.Fatalf("retrying: %v", err)
+		}
+		if retry.ReservationId != first.ReservationId || retry.HotelId[0] != "1" || retry.RoomType != first.RoomType {
+			t.Errorf("retry got %v, want the first reservation %v", retry, first)
+		}
+		if span.Tag("reservation.replayed") != true {
+			t.Errorf("retry not tagged replayed")
+		}
+	}
+	if n := reservations(t, s, "1"); n != 1 {
+		t.Errorf("%d reservations after retries, want 1", n)
+	}
+	// the retries took no rooms
+	if _, _, err := bookOnce(s, "key-2", "1", 1); err != nil {
+		t.Errorf("booking the other room: %v", err)
+	}
+}
+
+func TestIdempotencyKeyReused(t *testing.T) {
+	s := newIdempotentTestServer(t, map[string]int{"1": 2, "2": 2}, time.Hour)
+	if _, _, err := bookOnce(s, "key-1", "1", 1); err != nil {
+		t.Fatalf("booking: %v", err)
+	}
+	for _, other := range []struct {
+		hotelId string
+		rooms   int32
+	}{{"2", 1}, {"1", 2}} {
+		if _, _, err := bookOnce(s, "key-1", other.hotelId, other.rooms); status.Code(err) != codes.AlreadyExists {
+			t.Errorf("reusing the key for %d rooms of hotel %s: got %v, want AlreadyExists", other.rooms, other.hotelId, err)
+		}
+	}
+	if n := reservations(t, s, "2"); n != 0 {
+		t.Errorf("%d reservations of hotel 2, want none", n)
+	}
+}
+
+func TestIdempotencyKeyExpiry(t *testing.T) {
+	s := newIdempotentTestServer(t, map[string]int{"1": 2}, time.Hour)
+	first, _, err := bookOnce(s, "key-1", "1", 1)
+	if err != nil {
+		t.Fatalf("booking: %v", err)
+	}
+
+	// the key was used longer than the window ago
+	_, err = s.DB.Collection("idempotency").UpdateOne(context.Background(), bson.M{"key": "key-1"},
+		bson.M{"$set": bson.M{"createdAt": time.Now().UTC().Add(-2 * time.Hour)}})
+	if err != nil {
+		t.Fatalf("aging the key: %v", err)
+	}
+	second, span, err := bookOnce(s, "key-1", "1", 1)
+	if err != nil {
+		t.Fatalf("booking with the expired key: %v", err)
+	}
+	if second.ReservationId == first.ReservationId || span.Tag("reservation.replayed") != nil {
+		t.Errorf("booking with the expired key replayed %s", first.ReservationId)
+	}
+
+	// and replays the new reservation from then on
+	retry, _, err := bookOnce(s, "key-1", "1", 1)
+	if err != nil || retry.ReservationId != second.ReservationId {
+		t.Errorf("retrying: got %v, %v, want reservation %s", retry, err, second.ReservationId)
+	}
+	if n := reservations(t, s, "1"); n != 2 {
+		t.Errorf("%d reservations, want 2", n)
+	}
+}
+
+func TestIdempotencyKeyOfFailedBooking(t *testing.T) {
+	s := newIdempotentTestServer(t, map[string]int{"1": 1}, time.Hour)
+	if _, _, err := bookOnce(s, "key-1", "1", 2); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("booking more rooms than the hotel has: got %v, want FailedPrecondition", err)
+	}
+	// the failed booking doesn't hold the key
+	if _, _, err := bookOnce(s, "key-1", "1", 1); err != nil {
+		t.Errorf("booking with the key of a failed booking: %v", err)
+	}
+}
+
+func TestIdempotencyKeyInProgress(t *testing.T) {
+	s := newIdempotentTestServer(t, map[string]int{"1": 1}, time.Hour)
+	// claimed by a booking still running
+	claimed := idempotencyKey{Key: "key-1", RequestHash: s.requestHash(&pb.Request{
+		CustomerName: "Cornell_1", HotelId: []string{"1"}, InDate: "2015-04-09", OutDate: "2015-04-10", RoomNumber: 1,
+	}), CreatedAt: time.Now().UTC()}
+	if _, err := s.DB.Collection("idempotency").InsertOne(context.Background(), claimed); err != nil {
+		t.Fatalf("claiming the key: %v", err)
+	}
+	if _, _, err := bookOnce(s, "key-1", "1", 1); status.Code(err) != codes.Aborted {
+		t.Errorf("got %v, want Aborted", err)
+	}
+	if n := reservations(t, s, "1"); n != 0 {
+		t.Errorf("%d reservations, want none", n)
+	}
+}
@@ -0,0 +1,145 @@
+package reservation
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/opentracing/opentracing-go"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// night counts the rooms of a type reserved in a hotel for one night, stored
+// in the reservation-db.night collection with a unique index on hotelId,
+// roomType and date. It is only ever changed with conditional $inc updates,
+// so concurrent bookings cannot reserve more rooms than the hotel has.
+type night struct {
+	HotelId  string `bson:"hotelId"`
+	RoomType string `bson:"roomType"`
+	Date     string `bson:"date"`
+	Reserved int    `bson:"reserved"`
+}
+
+// parseDate parses a reservation date, at noon like the rest of the service.
+func parseDate(date string) (time.Time, error) {
+	return time.Parse(time.RFC3339, date+"T12:00:00+00:00")
+}
+
+// maxStayNights bounds the length of a stay, and so the nights a request
+// looks up and claims.
+const maxStayNights = 30
+
+// stayTooLong reports whether the stay from inDate to outDate lasts more
+// than maxStayNights nights.
+func stayTooLong(inDate, outDate time.Time) bool {
+	return outDate.Sub(inDate) > maxStayNights*24*time.Hour
+}
+
+// stayDates returns the dates of the nights from inDate to outDate, by
+// check-in date.
+func stayDates(inDate, outDate time.Time) []string {
+	var dates []string
+	for day := inDate; day.Before(outDate); day = day.AddDate(0, 0, 1) {
+		dates = append(dates, day.String()[0:10])
+	}
+	return dates
+}
+
+// nightMemcKey returns the memcached key caching the reservation count of
+// roomType for the night starting on date, which is keyed by the following
+// day.
+func nightMemcKey(hotelId, roomType, nextDate string) string {
+	return hotelId + "_" + roomType + "_" + nextDate + "_" + nextDate
+}
+
+// claimNights atomically reserves rooms of roomType in hotelId for each date,
+// as long as no night goes above capacity. If a night is full, the nights
+// already claimed are released and claimNights returns false.
+//
+// A cancelled update may still be applied, leaving claimNights unsure which
+// nights to release, so ctx is only checked between nights and the updates
+// themselves run to completion.
+func (s *Server) claimNights(ctx context.Context, hotelId, roomType string, dates []string, rooms, capacity int) (bool, error) {
+	if rooms > capacity {
+		return false, nil
+	}
+
+	nightCollection := s.hotelDB(hotelId).Collection("night")
+
+	mongoSpan, _ := opentracing.StartSpanFromContext(ctx, "mongo_claim_nights")
+	mongoSpan.SetTag("span.kind", "client")
+	defer mongoSpan.Finish()
+
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	for i, date := range dates {
+		// A missing night is upserted. A full one fails the filter, and the
+		// upsert then collides with it on the unique index.
+		filter := bson.M{"hotelId": hotelId, "roomType": roomType, "date": date, "reserved": bson.M{"$lte": capacity - rooms}}
+		update := bson.M{"$inc": bson.M{"reserved": rooms}}
+
+		if err := ctx.Err(); err != nil {
+			s.releaseNights(ctx, hotelId, roomType, dates[:i], rooms)
+			return false, err
+		}
+
+		var n night
+		err := nightCollection.FindOneAndUpdate(context.Background(), filter, update, opts).Decode(&n)
+		if mongo.IsDuplicateKeyError(err) {
+			s.releaseNights(ctx, hotelId, roomType, dates[:i], rooms)
+			return false, nil
+		}
+		if err != nil {
+			s.releaseNights(ctx, hotelId, roomType, dates[:i], rooms)
+			return false, err
+		}
+
+		s.cacheNight(n)
+	}
+	return true, nil
+}
+
+// releaseNights gives back rooms of roomType in hotelId for each date. It
+// runs to completion even if ctx is cancelled, or the rooms would stay held.
+func (s *Server) releaseNights(ctx context.Context, hotelId, roomType string, dates []string, rooms int) {
+	nightCollection := s.hotelDB(hotelId).Collection("night")
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	for _, date := range dates {
+		filter := bson.M{"hotelId": hotelId, "roomType": roomType, "date": date}
+		update := bson.M{"$inc": bson.M{"reserved": -rooms}}
+
+		var n night
+		if err := nightCollection.FindOneAndUpdate(context.Background(), filter, update, opts).Decode(&n); err != nil {
+			log.Error().Msgf("Failed to release %d %s rooms of hotel %s on %s: %v", rooms, roomType, hotelId, date, err)
+			continue
+		}
+		s.cacheNight(n)
+	}
+}
+
+// dropReservation undoes a booking that failed part way through inserting
+// its nights: it deletes those of reservationId inserted so far and gives
+// back the rooms claimed for dates. Like releaseNights, it runs to
+// completion even if ctx is cancelled.
+func (s *Server) dropReservation(ctx context.Context, hotelId, roomType, reservationId string, dates []string, rooms int) {
+	resCollection := s.hotelDB(hotelId).Collection("reservation")
+	if _, err := resCollection.DeleteMany(context.Background(), bson.M{"reservationId": reservationId}); err != nil {
+		log.Error().Msgf("Failed to delete the nights of failed reservation %s of hotel %s: %v", reservationId, hotelId, err)
+	}
+	s.releaseNights(ctx, hotelId, roomType, dates, rooms)
+}
+
+// cacheNight refreshes the cached reservation count of n.
+func (s *Server) cacheNight(n night) {
+	date, err := parseDate(n.Date)
+	if err != nil {
+		log.Error().Msgf("Invalid night date %q of hotel %s: %v", n.Date, n.HotelId, err)
+		return
+	}
+	key := nightMemcKey(n.HotelId, n.RoomType, date.AddDate(0, 0, 1).String()[0:10])
+	go s.MemcClient.Set(&memcache.Item{Key: key, Value: []byte(strconv.Itoa(n.Reserved))})
+}
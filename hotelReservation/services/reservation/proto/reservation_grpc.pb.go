@@ -19,8 +19,11 @@ import (
 const _ = grpc.SupportPackageIsVersion7
 
 const (
-	Reservation_MakeReservation_FullMethodName   = "/reservation.Reservation/MakeReservation"
-	Reservation_CheckAvailability_FullMethodName = "/reservation.Reservation/CheckAvailability"
+	Reservation_MakeReservation_FullMethodName        = "/reservation.Reservation/MakeReservation"
+	Reservation_CheckAvailability_FullMethodName      = "/reservation.Reservation/CheckAvailability"
+	Reservation_CancelReservation_FullMethodName      = "/reservation.Reservation/CancelReservation"
+	Reservation_BulkInsertReservations_FullMethodName = "/reservation.Reservation/BulkInsertReservations"
+	Reservation_CheckAvailabilityMulti_FullMethodName = "/reservation.Reservation/CheckAvailabilityMulti"
 )
 
 // ReservationClient is the client API for Reservation service.
@@ -31,6 +34,16 @@ type ReservationClient interface {
 	MakeReservation(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Result, error)
 	// CheckAvailability checks if given information is available
 	CheckAvailability(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Result, error)
+	// CancelReservation cancels a reservation made by MakeReservation
+	CancelReservation(ctx context.Context, in *CancelRequest, opts ...grpc.CallOption) (*CancelResult, error)
+	// BulkInsertReservations inserts a batch of reservations at once, e.g. to
+	// seed the state of a load test. Either all of them are inserted, or none
+	// if any fails validation. Admin only.
+	BulkInsertReservations(ctx context.Context, in *BulkInsertRequest, opts ...grpc.CallOption) (*BulkInsertResult, error)
+	// CheckAvailabilityMulti checks the availability of one hotel for each of
+	// several date ranges, e.g. candidate dates of a trip, evaluating each
+	// range on its own.
+	CheckAvailabilityMulti(ctx context.Context, in *MultiAvailabilityRequest, opts ...grpc.CallOption) (*MultiAvailabilityResult, error)
 }
 
 type reservationClient struct {
@@ -59,6 +72,33 @@ func (c *reservationClient) CheckAvailability(ctx context.Context, in *Request,
 	return out, nil
 }
 
+func (c *reservationClient) CancelReservation(ctx context.Context, in *CancelRequest, opts ...grpc.CallOption) (*CancelResult, error) {
+	out := new(CancelResult)
+	err := c.cc.Invoke(ctx, Reservation_CancelReservation_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *reservationClient) BulkInsertReservations(ctx context.Context, in *BulkInsertRequest, opts ...grpc.CallOption) (*BulkInsertResult, error) {
+	out := new(BulkInsertResult)
+	err := c.cc.Invoke(ctx, Reservation_BulkInsertReservations_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *reservationClient) CheckAvailabilityMulti(ctx context.Context, in *MultiAvailabilityRequest, opts ...grpc.CallOption) (*MultiAvailabilityResult, error) {
+	out := new(MultiAvailabilityResult)
+	err := c.cc.Invoke(ctx, Reservation_CheckAvailabilityMulti_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ReservationServer is the server API for Reservation service.
 // All implementations must embed UnimplementedReservationServer
 // for forward compatibility
@@ -67,6 +107,16 @@ type ReservationServer interface {
 	MakeReservation(context.Context, *Request) (*Result, error)
 	// CheckAvailability checks if given information is available
 	CheckAvailability(context.Context, *Request) (*Result, error)
+	// CancelReservation cancels a reservation made by MakeReservation
+	CancelReservation(context.Context, *CancelRequest) (*CancelResult, error)
+	// BulkInsertReservations inserts a batch of reservations at once, e.g. to
+	// seed the state of a load test. Either all of them are inserted, or none
+	// if any fails validation. Admin only.
+	BulkInsertReservations(context.Context, *BulkInsertRequest) (*BulkInsertResult, error)
+	// CheckAvailabilityMulti checks the availability of one hotel for each of
+	// several date ranges, e.g. candidate dates of a trip, evaluating each
+	// range on its own.
+	CheckAvailabilityMulti(context.Context, *MultiAvailabilityRequest) (*MultiAvailabilityResult, error)
 	mustEmbedUnimplementedReservationServer()
 }
 
@@ -80,6 +130,15 @@ func (UnimplementedReservationServer) MakeReservation(context.Context, *Request)
 func (UnimplementedReservationServer) CheckAvailability(context.Context, *Request) (*Result, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CheckAvailability not implemented")
 }
+func (UnimplementedReservationServer) CancelReservation(context.Context, *CancelRequest) (*CancelResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelReservation not implemented")
+}
+func (UnimplementedReservationServer) BulkInsertReservations(context.Context, *BulkInsertRequest) (*BulkInsertResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BulkInsertReservations not implemented")
+}
+func (UnimplementedReservationServer) CheckAvailabilityMulti(context.Context, *MultiAvailabilityRequest) (*MultiAvailabilityResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CheckAvailabilityMulti not implemented")
+}
 func (UnimplementedReservationServer) mustEmbedUnimplementedReservationServer() {}
 
 // UnsafeReservationServer may be embedded to opt out of forward compatibility for this service.
@@ -129,6 +188,60 @@ func _Reservation_CheckAvailability_Handler(srv interface{}, ctx context.Context
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Reservation_CancelReservation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReservationServer).CancelReservation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Reservation_CancelReservation_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReservationServer).CancelReservation(ctx, req.(*CancelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Reservation_BulkInsertReservations_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BulkInsertRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReservationServer).BulkInsertReservations(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Reservation_BulkInsertReservations_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReservationServer).BulkInsertReservations(ctx, req.(*BulkInsertRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Reservation_CheckAvailabilityMulti_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MultiAvailabilityRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReservationServer).CheckAvailabilityMulti(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Reservation_CheckAvailabilityMulti_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReservationServer).CheckAvailabilityMulti(ctx, req.(*MultiAvailabilityRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Reservation_ServiceDesc is the grpc.ServiceDesc for Reservation service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -144,6 +257,18 @@ var Reservation_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "CheckAvailability",
 			Handler:    _Reservation_CheckAvailability_Handler,
 		},
+		{
+			MethodName: "CancelReservation",
+			Handler:    _Reservation_CancelReservation_Handler,
+		},
+		{
+			MethodName: "BulkInsertReservations",
+			Handler:    _Reservation_BulkInsertReservations_Handler,
+		},
+		{
+			MethodName: "CheckAvailabilityMulti",
+			Handler:    _Reservation_CheckAvailabilityMulti_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "services/reservation/proto/reservation.proto",
@@ -10,43 +10,96 @@ import (
 	"time"
 
 	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails"
+	errpb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/graceful"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/healthcheck"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/registry"
 	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/reservation/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/store"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tls"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
 	"github.com/google/uuid"
-	"github.com/grpc-ecosystem/grpc-opentracing/go/otgrpc"
 	"github.com/opentracing/opentracing-go"
 	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 )
 
 const name = "srv-reservation"
 
+// validators check requests before their handler runs.
+var validators = map[string]tracing.Validator{
+	pb.Reservation_MakeReservation_FullMethodName:        tracing.ValidateAll(tracing.Required("hotelId"), tracing.Positive("roomNumber")),
+	pb.Reservation_CancelReservation_FullMethodName:      tracing.Required("reservationId"),
+	pb.Reservation_BulkInsertReservations_FullMethodName: tracing.Required("reservations"),
+	pb.Reservation_CheckAvailabilityMulti_FullMethodName: tracing.ValidateAll(tracing.Required("hotelId", "ranges"), tracing.Positive("roomNumber")),
+}
+
 // Server implements the user service
 type Server struct {
 	pb.UnimplementedReservationServer
 
-	uuid string
+	uuid       string
+	health     *healthcheck.Checker
+	grpcServer *grpc.Server
+	traffic    tracing.MetricsMark
+	ring       *ShardRing
 
 	Tracer      opentracing.Tracer
 	Port        int
 	IpAddr      string
 	MongoClient *mongo.Client
-	Registry    *registry.Client
-	MemcClient  *memcache.Client
+	// DB is the reservation-db database, that of MongoClient if nil.
+	DB store.Database
+	// Shards are the databases the reservations and nights are spread over
+	// by hotel ID, placed by a ShardRing, DB alone if empty. Capacities and
+	// idempotency keys stay in DB.
+	Shards     []store.Database
+	Registry   *registry.Client
+	MemcClient store.Memcache
+	// FreeCancellationWindow is how long before check-in a reservation can
+	// be cancelled for free.
+	FreeCancellationWindow time.Duration
+	// DefaultRoomType is the room type of requests, capacities and
+	// reservations that don't name one.
+	DefaultRoomType string
+	// IdempotencyWindow is how long an idempotency key replays the
+	// reservation first made with it before it can be reused.
+	IdempotencyWindow time.Duration
+	// MetricsPort is the admin port serving /metrics, zero to disable it.
+	MetricsPort int
+	// AdminAllowlist are the IPs and CIDRs of the callers allowed to call
+	// BulkInsertReservations.
+	AdminAllowlist []string
 }
 
 // Run starts the server
 func (s *Server) Run() error {
-	opentracing.SetGlobalTracer(s.Tracer)
-
 	if s.Port == 0 {
 		return fmt.Errorf("server port must be set")
 	}
 
+	if s.DB == nil {
+		s.DB = store.MongoDatabase(s.MongoClient.Database("reservation-db"))
+	}
+	if len(s.Shards) == 0 {
+		s.Shards = []store.Database{s.DB}
+	}
+	s.ring = NewShardRing(len(s.Shards))
+	s.checkShardCount(context.Background())
+
+	admin, err := tracing.AdminUnaryServerInterceptor(s.AdminAllowlist, pb.Reservation_BulkInsertReservations_FullMethodName)
+	if err != nil {
+		return err
+	}
+
 	s.uuid = uuid.New().String()
 
 	opts := []grpc.ServerOption{
@@ -54,11 +107,19 @@ func (s *Server) Run() error {
 			Timeout: 120 * time.Second,
 		}),
 		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             time.Duration(tune.GetKeepaliveTime()) * time.Millisecond,
 			PermitWithoutStream: true,
 		}),
-		grpc.UnaryInterceptor(
-			otgrpc.OpenTracingServerInterceptor(s.Tracer),
-		),
+		grpc.MaxRecvMsgSize(tune.GetMaxRecvMsgSize()),
+		grpc.MaxSendMsgSize(tune.GetMaxSendMsgSize()),
+		grpc.UnaryInterceptor(tracing.DefaultServerInterceptorChain(tracing.ServerChainOptions{
+			Tracer: s.Tracer,
+			Interceptors: []grpc.UnaryServerInterceptor{
+				tracing.ChaosUnaryServerInterceptor(tracing.DefaultChaos),
+				admin,
+				tracing.ValidationUnaryServerInterceptor(validators),
+			},
+		})),
 	}
 
 	if tlsopt := tls.GetServerOpt(); tlsopt != nil {
@@ -66,8 +127,23 @@ func (s *Server) Run() error {
 	}
 
 	srv := grpc.NewServer(opts...)
+	s.grpcServer = srv
+	s.traffic = tracing.DefaultMetrics.Mark()
 
 	pb.RegisterReservationServer(srv, s)
+	if tune.GetGrpcReflection() {
+		reflection.Register(srv)
+	}
+
+	s.health = healthcheck.Register(srv, pb.Reservation_ServiceDesc.ServiceName, healthcheck.Mongo(s.MongoClient), healthcheck.Memcached(s.MemcClient))
+	s.health.Start()
+
+	if s.MetricsPort != 0 {
+		go tracing.ServeMetrics(s.MetricsPort, tracing.DefaultMetrics)
+	}
+	if path := tune.GetFeatureFlagsFile(); path != "" {
+		go tracing.DefaultFlags.WatchFile(path, time.Duration(tune.GetFeatureFlagsPollInterval())*time.Millisecond)
+	}
 
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", s.Port))
 	if err != nil {
@@ -85,32 +161,85 @@ func (s *Server) Run() error {
 	return srv.Serve(lis)
 }
 
-// Shutdown cleans up any processes
+// Shutdown reports the server NOT_SERVING, deregisters it and drains
+// in-flight RPCs before stopping it, then reports the traffic it served.
 func (s *Server) Shutdown() {
+	s.health.Shutdown()
 	s.Registry.Deregister(s.uuid)
+	graceful.Stop(s.grpcServer)
+	graceful.Report(s.grpcServer, s.traffic)
 }
 
-// MakeReservation makes a reservation based on given information
+// MakeReservation makes a reservation based on given information. Every
+// night of the stay is checked against the capacity of the room type in the
+// hotel first, and the booking fails with FailedPrecondition if any night is
+// full, or NotFound if the hotel has no such rooms. Reservations are counted
+// per night and room type, so a stay checking out on the day another checks
+// in doesn't conflict with it, nor do stays in different room types.
+//
+// Requests with an idempotency key can be retried safely: a retry returns
+// the reservation first made with the key rather than booking again.
 func (s *Server) MakeReservation(ctx context.Context, req *pb.Request) (*pb.Result, error) {
+	if req.IdempotencyKey == "" {
+		return s.makeReservation(ctx, req)
+	}
+
+	prior, err := s.claimIdempotencyKey(ctx, req)
+	if err != nil || prior != nil {
+		return prior, err
+	}
+	booked := false
+	defer func() {
+		// also on panic, so that the booking can be retried
+		if !booked {
+			s.releaseIdempotencyKey(ctx, req.IdempotencyKey)
+		}
+	}()
+
+	res, err := s.makeReservation(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	s.completeIdempotencyKey(ctx, req.IdempotencyKey, res)
+	booked = true
+	return res, nil
+}
+
+func (s *Server) makeReservation(ctx context.Context, req *pb.Request) (*pb.Result, error) {
 	res := new(pb.Result)
 	res.HotelId = make([]string, 0)
+	roomType := s.roomType(req.RoomType)
+	res.RoomType = roomType
 
-	database := s.MongoClient.Database("reservation-db")
-	resCollection := database.Collection("reservation")
-	numCollection := database.Collection("number")
+	numCollection := s.DB.Collection("number")
 
-	inDate, _ := time.Parse(
+	inDate, err := time.Parse(
 		time.RFC3339,
 		req.InDate+"T12:00:00+00:00")
+	if err != nil {
+		return nil, errdetails.InvalidArgument(errpb.ErrorDetail_MALFORMED, "inDate", "invalid inDate %q", req.InDate)
+	}
 
-	outDate, _ := time.Parse(
+	outDate, err := time.Parse(
 		time.RFC3339,
 		req.OutDate+"T12:00:00+00:00")
+	if err != nil {
+		return nil, errdetails.InvalidArgument(errpb.ErrorDetail_MALFORMED, "outDate", "invalid outDate %q", req.OutDate)
+	}
+	if !outDate.After(inDate) {
+		return nil, errdetails.InvalidArgument(errpb.ErrorDetail_OUT_OF_RANGE, "outDate", "outDate %s must be after inDate %s", req.OutDate, req.InDate)
+	}
+	if stayTooLong(inDate, outDate) {
+		return nil, errdetails.InvalidArgument(errpb.ErrorDetail_OUT_OF_RANGE, "outDate", "stay from %s to %s is longer than %d nights", req.InDate, req.OutDate, maxStayNights)
+	}
 	hotelId := req.HotelId[0]
+	resCollection := s.hotelDB(hotelId).Collection("reservation")
 
 	indate := inDate.String()[0:10]
 
-	memc_date_num_map := make(map[string]int)
+	// nights of the stay, and the room type capacity they are checked against
+	var dates []string
+	capacity := 0
 
 	for inDate.Before(outDate) {
 		// check reservations
@@ -119,42 +248,38 @@ func (s *Server) MakeReservation(ctx context.Context, req *pb.Request) (*pb.Resu
 		outdate := inDate.String()[0:10]
 
 		// first check memc
-		memc_key := hotelId + "_" + inDate.String()[0:10] + "_" + outdate
+		memc_key := nightMemcKey(hotelId, roomType, outdate)
 		item, err := s.MemcClient.Get(memc_key)
 		if err == nil {
 			// memcached hit
 			count, _ = strconv.Atoi(string(item.Value))
 			log.Trace().Msgf("memcached hit %s = %d", memc_key, count)
-			memc_date_num_map[memc_key] = count + int(req.RoomNumber)
 
 		} else if err == memcache.ErrCacheMiss {
 			// memcached miss
 			log.Trace().Msgf("memcached miss")
 			var reserve []reservation
 
-			filter := bson.D{{"hotelId", hotelId}, {"inDate", indate}, {"outDate", outdate}}
-			curr, err := resCollection.Find(context.TODO(), filter)
-			if err != nil {
-				log.Error().Msgf("Failed get reservation data: ", err)
+			filter := bson.D{{Key: "hotelId", Value: hotelId}, s.roomTypeFilter(roomType), {Key: "inDate", Value: indate}, {Key: "outDate", Value: outdate}, notCancelled}
+			curr, err := resCollection.Find(ctx, filter)
+			if err == nil {
+				err = curr.All(ctx, &reserve)
 			}
-			curr.All(context.TODO(), &reserve)
 			if err != nil {
-				log.Panic().Msgf("Tried to find hotelId [%v] from date [%v] to date [%v], but got error", hotelId, indate, outdate, err.Error())
+				return nil, store.Error(ctx, err, codes.Internal, "failed to find reservations of hotel %s on %s", hotelId, indate)
 			}
 
 			for _, r := range reserve {
 				count += r.Number
 			}
 
-			memc_date_num_map[memc_key] = count + int(req.RoomNumber)
-
 		} else {
 			log.Panic().Msgf("Tried to get memc_key [%v], but got memmcached error = %s", memc_key, err)
 		}
 
 		// check capacity
 		// check memc capacity
-		memc_cap_key := hotelId + "_cap"
+		memc_cap_key := capMemcKey(hotelId, roomType)
 		item, err = s.MemcClient.Get(memc_cap_key)
 		hotel_cap := 0
 		if err == nil {
@@ -164,9 +289,12 @@ func (s *Server) MakeReservation(ctx context.Context, req *pb.Request) (*pb.Resu
 		} else if err == memcache.ErrCacheMiss {
 			// memcached miss
 			var num number
-			err = numCollection.FindOne(context.TODO(), &bson.D{{"hotelId", hotelId}}).Decode(&num)
+			err = numCollection.FindOne(ctx, &bson.D{{Key: "hotelId", Value: hotelId}, s.roomTypeFilter(roomType)}).Decode(&num)
+			if err == mongo.ErrNoDocuments {
+				return nil, status.Errorf(codes.NotFound, "hotel %s has no %s rooms", hotelId, roomType)
+			}
 			if err != nil {
-				log.Panic().Msgf("Tried to find hotelId [%v], but got error", hotelId, err.Error())
+				return nil, store.Error(ctx, err, codes.Internal, "failed to find the capacity of hotel %s", hotelId)
 			}
 			hotel_cap = int(num.Number)
 
@@ -177,14 +305,36 @@ func (s *Server) MakeReservation(ctx context.Context, req *pb.Request) (*pb.Resu
 		}
 
 		if count+int(req.RoomNumber) > hotel_cap {
-			return res, nil
+			return nil, status.Errorf(codes.FailedPrecondition,
+				"hotel %s has %d of %d %s rooms reserved on %s, cannot reserve %d more",
+				hotelId, count, hotel_cap, roomType, indate, req.RoomNumber)
 		}
+		dates = append(dates, indate)
+		capacity = hotel_cap
 		indate = outdate
 	}
 
-	// only update reservation number cache after check succeeds
-	for key, val := range memc_date_num_map {
-		s.MemcClient.Set(&memcache.Item{Key: key, Value: []byte(strconv.Itoa(val))})
+	// The check above reads counts that concurrent bookings may be about to
+	// change, so claim the rooms with conditional updates before inserting.
+	// claimNights also refreshes the cached counts.
+	claimed, err := s.claimNights(ctx, hotelId, roomType, dates, int(req.RoomNumber), capacity)
+	if err != nil {
+		return nil, store.Error(ctx, err, codes.Internal, "failed to reserve hotel %s", hotelId)
+	}
+	if !claimed {
+		if span := opentracing.SpanFromContext(ctx); span != nil {
+			span.SetTag("reservation.contended", true)
+		}
+		log.Debug().Msgf("Lost the race for %s rooms of hotel %s from %s to %s", roomType, hotelId, req.InDate, req.OutDate)
+		return nil, status.Errorf(codes.FailedPrecondition,
+			"hotel %s has no %d %s rooms left from %s to %s", hotelId, req.RoomNumber, roomType, req.InDate, req.OutDate)
+	}
+
+	// Past this point the reservation holds the rooms, so it is inserted
+	// even if ctx is cancelled in the meantime.
+	if err := ctx.Err(); err != nil {
+		s.releaseNights(ctx, hotelId, roomType, dates, int(req.RoomNumber))
+		return nil, status.FromContextError(err).Err()
 	}
 
 	inDate, _ = time.Parse(
@@ -193,43 +343,136 @@ func (s *Server) MakeReservation(ctx context.Context, req *pb.Request) (*pb.Resu
 
 	indate = inDate.String()[0:10]
 
+	reservationId := uuid.New().String()
 	for inDate.Before(outDate) {
 		inDate = inDate.AddDate(0, 0, 1)
 		outdate := inDate.String()[0:10]
+		// Not ctx: a cancelled insert may still be applied, so giving up
+		// on the booking here would race its own rollback. A caller gone
+		// meanwhile finds the booking by retrying with its idempotency key.
 		_, err := resCollection.InsertOne(
-			context.TODO(),
+			context.Background(),
 			reservation{
-				HotelId:      hotelId,
-				CustomerName: req.CustomerName,
-				InDate:       indate,
-				OutDate:      outdate,
-				Number:       int(req.RoomNumber),
+				ReservationId: reservationId,
+				HotelId:       hotelId,
+				RoomType:      roomType,
+				CustomerName:  req.CustomerName,
+				InDate:        indate,
+				OutDate:       outdate,
+				Number:        int(req.RoomNumber),
 			},
 		)
 		if err != nil {
-			log.Panic().Msgf("Tried to insert hotel [hotelId %v], but got error", hotelId, err.Error())
+			s.dropReservation(ctx, hotelId, roomType, reservationId, dates, int(req.RoomNumber))
+			return nil, store.Error(ctx, err, codes.Internal, "failed to reserve hotel %s", hotelId)
 		}
 		indate = outdate
 	}
 
 	res.HotelId = append(res.HotelId, hotelId)
+	res.ReservationId = reservationId
+
+	return res, nil
+}
+
+// CancelReservation marks every night of a reservation as cancelled and
+// frees the rooms it held. Cancelling is idempotent: an unknown or already
+// cancelled reservation is reported in the result status, not as an error.
+func (s *Server) CancelReservation(ctx context.Context, req *pb.CancelRequest) (*pb.CancelResult, error) {
+	filter := bson.D{{Key: "reservationId", Value: req.ReservationId}}
+
+	mongoSpan, _ := opentracing.StartSpanFromContext(ctx, "mongo_cancel_reservation")
+	mongoSpan.SetTag("span.kind", "client")
+	defer mongoSpan.Finish()
+
+	// The ID doesn't tell the hotel, so look for the reservation on every
+	// shard.
+	var (
+		resCollection store.Collection
+		nights        []reservation
+	)
+	for _, shard := range s.Shards {
+		resCollection = shard.Collection("reservation")
+		curr, err := resCollection.Find(ctx, filter)
+		if err == nil {
+			err = curr.All(ctx, &nights)
+		}
+		if err != nil {
+			return nil, store.Error(ctx, err, codes.Internal, "failed to find reservation %s", req.ReservationId)
+		}
+		if len(nights) > 0 {
+			break
+		}
+	}
+	if len(nights) == 0 {
+		return &pb.CancelResult{Status: pb.CancelResult_NOT_FOUND}, nil
+	}
+
+	// A cancelled update may still be applied, leaving the rooms held by a
+	// cancelled reservation, so ctx is not checked past this point.
+	if err := ctx.Err(); err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	now := time.Now().UTC()
+	update := bson.M{"$set": bson.M{"cancelledAt": now}}
+	updateRes, err := resCollection.UpdateMany(context.Background(), append(filter, notCancelled), update)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to cancel reservation %s: %v", req.ReservationId, err)
+	}
+
+	res := &pb.CancelResult{Status: pb.CancelResult_CANCELLED}
+	cancelledAt := now
+	if updateRes.ModifiedCount == 0 {
+		res.Status = pb.CancelResult_ALREADY_CANCELLED
+		if nights[0].CancelledAt != nil {
+			cancelledAt = *nights[0].CancelledAt
+		}
+	}
+	res.CancelledAt = cancelledAt.Format(time.RFC3339)
+
+	// The stay starts on the earliest night, at noon like in MakeReservation.
+	checkIn := nights[0].InDate
+	for _, night := range nights {
+		if night.InDate < checkIn {
+			checkIn = night.InDate
+		}
+	}
+	if inDate, err := parseDate(checkIn); err == nil {
+		res.FreeCancellation = !cancelledAt.Add(s.FreeCancellationWindow).After(inDate)
+	}
+
+	if res.Status == pb.CancelResult_CANCELLED {
+		// Give the rooms back, which also refreshes the cached counts.
+		for _, night := range nights {
+			s.releaseNights(ctx, night.HotelId, s.roomType(night.RoomType), []string{night.InDate}, night.Number)
+		}
+	}
 
 	return res, nil
 }
 
-// CheckAvailability checks if given information is available
+// CheckAvailability checks if given information is available, returning the
+// hotels having enough rooms of the requested type left for the whole stay.
 func (s *Server) CheckAvailability(ctx context.Context, req *pb.Request) (*pb.Result, error) {
+	if inDate, err := parseDate(req.InDate); err == nil {
+		if outDate, err := parseDate(req.OutDate); err == nil && stayTooLong(inDate, outDate) {
+			return nil, errdetails.InvalidArgument(errpb.ErrorDetail_OUT_OF_RANGE, "outDate", "stay from %s to %s is longer than %d nights", req.InDate, req.OutDate, maxStayNights)
+		}
+	}
+
 	res := new(pb.Result)
 	res.HotelId = make([]string, 0)
+	roomType := s.roomType(req.RoomType)
+	res.RoomType = roomType
 
 	hotelMemKeys := []string{}
 	keysMap := make(map[string]struct{})
 	resMap := make(map[string]bool)
 	// cache capacity since it will not change
 	for _, hotelId := range req.HotelId {
-		hotelMemKeys = append(hotelMemKeys, hotelId+"_cap")
+		hotelMemKeys = append(hotelMemKeys, capMemcKey(hotelId, roomType))
 		resMap[hotelId] = true
-		keysMap[hotelId+"_cap"] = struct{}{}
+		keysMap[capMemcKey(hotelId, roomType)] = struct{}{}
 	}
 
 	capMemSpan, _ := opentracing.StartSpanFromContext(ctx, "memcached_capacity_get_multi_number")
@@ -237,24 +480,24 @@ func (s *Server) CheckAvailability(ctx context.Context, req *pb.Request) (*pb.Re
 	cacheMemRes, err := s.MemcClient.GetMulti(hotelMemKeys)
 	capMemSpan.Finish()
 
-	numCollection := s.MongoClient.Database("reservation-db").Collection("number")
+	numCollection := s.DB.Collection("number")
 
+	if err != nil && err != memcache.ErrCacheMiss {
+		log.Panic().Msgf("Tried to get memc_cap_key [%v], but got memmcached error = %s", hotelMemKeys, err)
+	}
 	misKeys := []string{}
-	// gather cache miss key to query in mongodb
-	if err == memcache.ErrCacheMiss {
-		for key := range keysMap {
-			if _, ok := cacheMemRes[key]; !ok {
-				misKeys = append(misKeys, key)
-			}
+	// gather cache miss key to query in mongodb, GetMulti leaving them out
+	// of its result rather than failing
+	for key := range keysMap {
+		if _, ok := cacheMemRes[key]; !ok {
+			misKeys = append(misKeys, key)
 		}
-	} else if err != nil {
-		log.Panic().Msgf("Tried to get memc_cap_key [%v], but got memmcached error = %s", hotelMemKeys, err)
 	}
 	// store whole capacity result in cacheCap
 	cacheCap := make(map[string]int)
 	for k, v := range cacheMemRes {
 		hotelCap, _ := strconv.Atoi(string(v.Value))
-		cacheCap[k] = hotelCap
+		cacheCap[strings.Split(k, "_")[0]] = hotelCap
 	}
 	if len(misKeys) > 0 {
 		queryMissKeys := []string{}
@@ -264,22 +507,19 @@ func (s *Server) CheckAvailability(ctx context.Context, req *pb.Request) (*pb.Re
 		var nums []number
 		capMongoSpan, _ := opentracing.StartSpanFromContext(ctx, "mongodb_capacity_get_multi_number")
 		capMongoSpan.SetTag("span.kind", "client")
-		curr, err := numCollection.Find(context.TODO(), bson.D{{"$in", queryMissKeys}})
-		if err != nil {
-			log.Error().Msgf("Failed get reservation number data: ", err)
-		}
-		curr.All(context.TODO(), &nums)
-		if err != nil {
-			log.Error().Msgf("Failed get reservation number data: ", err)
+		filter := bson.D{{Key: "hotelId", Value: bson.M{"$in": queryMissKeys}}, s.roomTypeFilter(roomType)}
+		curr, err := numCollection.Find(ctx, filter)
+		if err == nil {
+			err = curr.All(ctx, &nums)
 		}
 		capMongoSpan.Finish()
 		if err != nil {
-			log.Panic().Msgf("Tried to find hotelId [%v], but got error", misKeys, err.Error())
+			return nil, store.Error(ctx, err, codes.Internal, "failed to find the capacity of hotels %v", queryMissKeys)
 		}
 		for _, num := range nums {
 			cacheCap[num.HotelId] = num.Number
 			// we don't care set successfully or not
-			go s.MemcClient.Set(&memcache.Item{Key: num.HotelId + "_cap", Value: []byte(strconv.Itoa(num.Number))})
+			go s.MemcClient.Set(&memcache.Item{Key: capMemcKey(num.HotelId, roomType), Value: []byte(strconv.Itoa(num.Number))})
 		}
 	}
 
@@ -297,7 +537,7 @@ func (s *Server) CheckAvailability(ctx context.Context, req *pb.Request) (*pb.Re
 			indate := inDate.String()[:10]
 			inDate = inDate.AddDate(0, 0, 1)
 			outDate := inDate.String()[:10]
-			memcKey := hotelId + "_" + outDate + "_" + outDate
+			memcKey := nightMemcKey(hotelId, roomType, outDate)
 			reqCommand = append(reqCommand, memcKey)
 			queryMap[memcKey] = map[string]string{
 				"hotelId":   hotelId,
@@ -310,97 +550,103 @@ func (s *Server) CheckAvailability(ctx context.Context, req *pb.Request) (*pb.Re
 	type taskRes struct {
 		hotelId  string
 		checkRes bool
+		err      error
 	}
 	reserveMemSpan, _ := opentracing.StartSpanFromContext(ctx, "memcached_reserve_get_multi_number")
 	ch := make(chan taskRes)
 	reserveMemSpan.SetTag("span.kind", "client")
 	// check capacity in memcached and mongodb
-	if itemsMap, err := s.MemcClient.GetMulti(reqCommand); err != nil && err != memcache.ErrCacheMiss {
-		reserveMemSpan.Finish()
+	itemsMap, err := s.MemcClient.GetMulti(reqCommand)
+	reserveMemSpan.Finish()
+	if err != nil && err != memcache.ErrCacheMiss {
 		log.Panic().Msgf("Tried to get memc_key [%v], but got memmcached error = %s", reqCommand, err)
-	} else {
-		reserveMemSpan.Finish()
-		// go through reservation count from memcached
-		go func() {
-			for k, v := range itemsMap {
-				id := strings.Split(k, "_")[0]
-				val, _ := strconv.Atoi(string(v.Value))
-				var res bool
-				if val+int(req.RoomNumber) <= cacheCap[id] {
-					res = true
-				}
+	}
+	// use miss reservation to get data from mongo, GetMulti leaving them
+	// out of its result rather than failing
+	for k := range itemsMap {
+		delete(queryMap, k)
+	}
+	var wg sync.WaitGroup
+	wg.Add(1 + len(queryMap))
+	go func() {
+		wg.Wait()
+		close(ch)
+	}()
+	// go through reservation count from memcached
+	go func() {
+		defer wg.Done()
+		for k, v := range itemsMap {
+			id := strings.Split(k, "_")[0]
+			val, _ := strconv.Atoi(string(v.Value))
+			var res bool
+			if val+int(req.RoomNumber) <= cacheCap[id] {
+				res = true
+			}
+			ch <- taskRes{
+				hotelId:  id,
+				checkRes: res,
+			}
+		}
+	}()
+	// rever string to indata and outdate
+	for command := range queryMap {
+		go func(comm string) {
+			defer wg.Done()
+
+			var reserve []reservation
+
+			queryItem := queryMap[comm]
+			resCollection := s.hotelDB(queryItem["hotelId"]).Collection("reservation")
+			filter := bson.D{{Key: "hotelId", Value: queryItem["hotelId"]}, s.roomTypeFilter(roomType), {Key: "inDate", Value: queryItem["startDate"]}, {Key: "outDate", Value: queryItem["endDate"]}, notCancelled}
+
+			reserveMongoSpan, _ := opentracing.StartSpanFromContext(ctx, "mongodb_capacity_get_multi_number"+comm)
+			reserveMongoSpan.SetTag("span.kind", "client")
+			curr, err := resCollection.Find(ctx, filter)
+			if err == nil {
+				err = curr.All(ctx, &reserve)
+			}
+			reserveMongoSpan.Finish()
+
+			if err != nil {
 				ch <- taskRes{
-					hotelId:  id,
-					checkRes: res,
+					hotelId: queryItem["hotelId"],
+					err:     fmt.Errorf("failed to find reservations of hotel %s on %s: %w", queryItem["hotelId"], queryItem["startDate"], err),
 				}
+				return
 			}
-			if err == nil {
-				close(ch)
+			var count int
+			for _, r := range reserve {
+				log.Trace().Msgf("reservation check reservation number = %d", r.Number)
+				count += r.Number
 			}
-		}()
-		// use miss reservation to get data from mongo
-		// rever string to indata and outdate
-		if err == memcache.ErrCacheMiss {
-			var wg sync.WaitGroup
-			for k := range itemsMap {
-				delete(queryMap, k)
+			// update memcached
+			go s.MemcClient.Set(&memcache.Item{Key: comm, Value: []byte(strconv.Itoa(count))})
+			var res bool
+			if count+int(req.RoomNumber) <= cacheCap[queryItem["hotelId"]] {
+				res = true
 			}
-			wg.Add(len(queryMap))
-			go func() {
-				wg.Wait()
-				close(ch)
-			}()
-			for command := range queryMap {
-				go func(comm string) {
-					defer wg.Done()
-
-					var reserve []reservation
-
-					queryItem := queryMap[comm]
-					resCollection := s.MongoClient.Database("reservation-db").Collection("reservation")
-					filter := bson.D{{"hotelId", queryItem["hotelId"]}, {"inDate", queryItem["startDate"]}, {"outDate", queryItem["endDate"]}}
-
-					reserveMongoSpan, _ := opentracing.StartSpanFromContext(ctx, "mongodb_capacity_get_multi_number"+comm)
-					reserveMongoSpan.SetTag("span.kind", "client")
-					curr, err := resCollection.Find(context.TODO(), filter)
-					if err != nil {
-						log.Error().Msgf("Failed get reservation data: ", err)
-					}
-					curr.All(context.TODO(), &reserve)
-					if err != nil {
-						log.Error().Msgf("Failed get reservation data: ", err)
-					}
-					reserveMongoSpan.Finish()
-
-					if err != nil {
-						log.Panic().Msgf("Tried to find hotelId [%v] from date [%v] to date [%v], but got error",
-							queryItem["hotelId"], queryItem["startDate"], queryItem["endDate"], err.Error())
-					}
-					var count int
-					for _, r := range reserve {
-						log.Trace().Msgf("reservation check reservation number = %d", queryItem["hotelId"])
-						count += r.Number
-					}
-					// update memcached
-					go s.MemcClient.Set(&memcache.Item{Key: comm, Value: []byte(strconv.Itoa(count))})
-					var res bool
-					if count+int(req.RoomNumber) <= cacheCap[queryItem["hotelId"]] {
-						res = true
-					}
-					ch <- taskRes{
-						hotelId:  queryItem["hotelId"],
-						checkRes: res,
-					}
-				}(command)
+			ch <- taskRes{
+				hotelId:  queryItem["hotelId"],
+				checkRes: res,
 			}
-		}
+		}(command)
 	}
 
+	var taskErr error
 	for task := range ch {
+		if task.err != nil {
+			if taskErr == nil {
+				taskErr = task.err
+			}
+			continue
+		}
 		if !task.checkRes {
 			resMap[task.hotelId] = false
 		}
 	}
+	if taskErr != nil {
+		return nil, store.Error(ctx, taskErr, codes.Internal, "failed to check availability")
+	}
 	for k, v := range resMap {
 		if v {
 			res.HotelId = append(res.HotelId, k)
@@ -410,15 +656,49 @@ func (s *Server) CheckAvailability(ctx context.Context, req *pb.Request) (*pb.Re
 	return res, nil
 }
 
+// notCancelled filters out cancelled reservations.
+var notCancelled = bson.E{Key: "cancelledAt", Value: bson.M{"$exists": false}}
+
+// roomType returns roomType, or the default room type if it is empty.
+func (s *Server) roomType(roomType string) string {
+	if roomType == "" {
+		return s.DefaultRoomType
+	}
+	return roomType
+}
+
+// roomTypeFilter filters the reservations and capacities of roomType. Those
+// stored without a room type are of the default one.
+func (s *Server) roomTypeFilter(roomType string) bson.E {
+	if roomType == s.DefaultRoomType {
+		return bson.E{Key: "roomType", Value: bson.M{"$in": bson.A{roomType, nil}}}
+	}
+	return bson.E{Key: "roomType", Value: roomType}
+}
+
+// capMemcKey returns the memcached key caching the number of rooms of
+// roomType in a hotel.
+func capMemcKey(hotelId, roomType string) string {
+	return hotelId + "_" + roomType + "_cap"
+}
+
+// reservation is one night of a reservation. A reservation made by
+// MakeReservation is stored as one document per night sharing a
+// reservationId.
 type reservation struct {
-	HotelId      string `bson:"hotelId"`
-	CustomerName string `bson:"customerName"`
-	InDate       string `bson:"inDate"`
-	OutDate      string `bson:"outDate"`
-	Number       int    `bson:"number"`
+	ReservationId string     `bson:"reservationId,omitempty"`
+	HotelId       string     `bson:"hotelId"`
+	RoomType      string     `bson:"roomType,omitempty"`
+	CustomerName  string     `bson:"customerName"`
+	InDate        string     `bson:"inDate"`
+	OutDate       string     `bson:"outDate"`
+	Number        int        `bson:"number"`
+	CancelledAt   *time.Time `bson:"cancelledAt,omitempty"`
 }
 
+// number is the number of rooms of a type in a hotel.
 type number struct {
-	HotelId string `bson:"hotelId"`
-	Number  int    `bson:"numberOfRoom"`
+	HotelId  string `bson:"hotelId"`
+	RoomType string `bson:"roomType,omitempty"`
+	Number   int    `bson:"numberOfRoom"`
 }
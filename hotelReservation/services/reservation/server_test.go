@@ -0,0 +1,594 @@
+package reservation
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/inproc/fakestore"
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/reservation/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/store"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newTestServer returns a server on a single shard backed by stand-ins of
+// MongoDB and memcached, indexed as the seeding does, each hotel of
+// capacities having that many rooms of the default room type.
+func newTestServer(t *testing.T, capacities map[string]int) *Server {
+	t.Helper()
+	db := fakestore.Mongo(t).Database("reservation-db")
+	_, err := db.Collection("night").Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "hotelId", Value: 1}, {Key: "roomType", Value: 1}, {Key: "date", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		t.Fatalf("creating the night index: %v", err)
+	}
+	_, err = db.Collection("idempotency").Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "key", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		t.Fatalf("creating the idempotency index: %v", err)
+	}
+	for id, rooms := range capacities {
+		if _, err := db.Collection("number").InsertOne(context.Background(), bson.M{"hotelId": id, "numberOfRoom": rooms}); err != nil {
+			t.Fatalf("inserting the capacity of hotel %s: %v", id, err)
+		}
+	}
+
+	s := &Server{
+		DB:              store.MongoDatabase(db),
+		MemcClient:      fakestore.Memcached(t),
+		DefaultRoomType: "standard",
+	}
+	s.Shards = []store.Database{s.DB}
+	s.ring = NewShardRing(len(s.Shards))
+	return s
+}
+
+// book reserves rooms of hotelId from inDate to outDate.
+func book(s *Server, hotelId, inDate, outDate string, rooms int32) (*pb.Result, error) {
+	return s.MakeReservation(context.Background(), &pb.Request{
+		CustomerName: "Cornell_1",
+		HotelId:      []string{hotelId},
+		InDate:       inDate,
+		OutDate:      outDate,
+		RoomNumber:   rooms,
+	})
+}
+
+// available returns the hotels of hotelIds with rooms left from inDate to
+// outDate.
+func available(t *testing.T, s *Server, inDate, outDate string, rooms int32, hotelIds ...string) map[string]bool {
+	t.Helper()
+	res, err := s.CheckAvailability(context.Background(), &pb.Request{
+		HotelId:    hotelIds,
+		InDate:     inDate,
+		OutDate:    outDate,
+		RoomNumber: rooms,
+	})
+	if err != nil {
+		t.Fatalf("CheckAvailability: %v", err)
+	}
+	ids := make(map[string]bool, len(res.HotelId))
+	for _, id := range res.HotelId {
+		ids[id] = true
+	}
+	return ids
+}
+
+// waitCachedCount waits for memcached to cache count reserved rooms of the
+// default room type in hotelId for the night before nextDate, as bookings
+// and cancellations cache the counts asynchronously.
+func waitCachedCount(t *testing.T, s *Server, hotelId, nextDate string, count int) {
+	t.Helper()
+	key := nightMemcKey(hotelId, "standard", nextDate)
+	for deadline := time.Now().Add(2 * time.Second); ; {
+		item, err := s.MemcClient.Get(key)
+		if err == nil && string(item.Value) == strconv.Itoa(count) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("count of %s not cached as %d: %v", key, count, err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestMakeReservationOverlap(t *testing.T) {
+	s := newTestServer(t, map[string]int{"1": 1})
+	if _, err := book(s, "1", "2015-04-09", "2015-04-11", 1); err != nil {
+		t.Fatalf("booking the first stay: %v", err)
+	}
+
+	tests := []struct {
+		name, inDate, outDate string
+		want                  codes.Code
+	}{
+		{"checks in on the last night", "2015-04-10", "2015-04-12", codes.FailedPrecondition},
+		{"checks out after the first night", "2015-04-08", "2015-04-10", codes.FailedPrecondition},
+		{"within the stay", "2015-04-09", "2015-04-10", codes.FailedPrecondition},
+		{"around the stay", "2015-04-08", "2015-04-12", codes.FailedPrecondition},
+		{"checks in on check-out", "2015-04-11", "2015-04-13", codes.OK},
+		{"checks out on check-in", "2015-04-07", "2015-04-09", codes.OK},
+	}
+	for _, tt := range tests {
+		if _, err := book(s, "1", tt.inDate, tt.outDate, 1); status.Code(err) != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestFullyBookedHotel(t *testing.T) {
+	s := newTestServer(t, map[string]int{"1": 2, "2": 2})
+	if _, err := book(s, "1", "2015-04-09", "2015-04-12", 2); err != nil {
+		t.Fatalf("booking every room: %v", err)
+	}
+
+	if _, err := book(s, "1", "2015-04-10", "2015-04-11", 1); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("booking a full hotel: got %v, want FailedPrecondition", err)
+	}
+	if got := available(t, s, "2015-04-10", "2015-04-11", 1, "1", "2"); got["1"] || !got["2"] {
+		t.Errorf("got available hotels %v, want hotel 2 only", got)
+	}
+	if got := available(t, s, "2015-04-12", "2015-04-14", 2, "1"); !got["1"] {
+		t.Errorf("hotel 1 unavailable from its check-out day, want it available")
+	}
+	if _, err := book(s, "2", "2015-04-10", "2015-04-11", 3); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("booking more rooms than the hotel has: got %v, want FailedPrecondition", err)
+	}
+}
+
+func TestMakeReservationUnknownHotel(t *testing.T) {
+	s := newTestServer(t, map[string]int{"1": 1})
+	if _, err := book(s, "2", "2015-04-09", "2015-04-10", 1); status.Code(err) != codes.NotFound {
+		t.Errorf("got %v, want NotFound", err)
+	}
+}
+
+func TestStayLengthCapped(t *testing.T) {
+	s := newTestServer(t, map[string]int{"1": 1})
+	if _, err := book(s, "1", "2015-04-01", "2015-05-02", 1); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("booking 31 nights: got %v, want InvalidArgument", err)
+	}
+	_, err := s.CheckAvailability(context.Background(), &pb.Request{
+		HotelId:    []string{"1"},
+		InDate:     "2015-04-01",
+		OutDate:    "2015-05-02",
+		RoomNumber: 1,
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("checking 31 nights: got %v, want InvalidArgument", err)
+	}
+	if _, err := book(s, "1", "2015-04-01", "2015-05-01", 1); err != nil {
+		t.Errorf("booking 30 nights: %v", err)
+	}
+}
+
+func TestCancelReservation(t *testing.T) {
+	s := newTestServer(t, map[string]int{"1": 1})
+	s.FreeCancellationWindow = 24 * time.Hour
+	res, err := book(s, "1", "2015-04-09", "2015-04-11", 1)
+	if err != nil {
+		t.Fatalf("booking: %v", err)
+	}
+	if got := available(t, s, "2015-04-10", "2015-04-11", 1, "1"); got["1"] {
+		t.Fatalf("hotel 1 available while booked")
+	}
+
+	cancel := func() *pb.CancelResult {
+		t.Helper()
+		c, err := s.CancelReservation(context.Background(), &pb.CancelRequest{ReservationId: res.ReservationId})
+		if err != nil {
+			t.Fatalf("CancelReservation: %v", err)
+		}
+		return c
+	}
+	first := cancel()
+	if first.Status != pb.CancelResult_CANCELLED {
+		t.Errorf("got status %v, want CANCELLED", first.Status)
+	}
+	if first.FreeCancellation {
+		t.Errorf("cancelling a past stay was free")
+	}
+
+	// the rooms are back, both for checks and for bookings
+	waitCachedCount(t, s, "1", "2015-04-10", 0)
+	waitCachedCount(t, s, "1", "2015-04-11", 0)
+	if got := available(t, s, "2015-04-09", "2015-04-11", 1, "1"); !got["1"] {
+		t.Errorf("hotel 1 unavailable after the cancellation")
+	}
+	if _, err := book(s, "1", "2015-04-09", "2015-04-11", 1); err != nil {
+		t.Fatalf("booking the cancelled stay again: %v", err)
+	}
+
+	second := cancel()
+	if second.Status != pb.CancelResult_ALREADY_CANCELLED {
+		t.Errorf("cancelling again: got status %v, want ALREADY_CANCELLED", second.Status)
+	}
+	if second.CancelledAt != first.CancelledAt {
+		t.Errorf("cancelling again: cancelled at %s, want %s", second.CancelledAt, first.CancelledAt)
+	}
+	// cancelling again doesn't free the rooms of the new booking
+	if _, err := book(s, "1", "2015-04-10", "2015-04-11", 1); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("booking after cancelling twice: got %v, want FailedPrecondition", err)
+	}
+}
+
+func TestCancelUnknownReservation(t *testing.T) {
+	s := newTestServer(t, map[string]int{"1": 1})
+	res, err := s.CancelReservation(context.Background(), &pb.CancelRequest{ReservationId: "unknown"})
+	if err != nil {
+		t.Fatalf("CancelReservation: %v", err)
+	}
+	if res.Status != pb.CancelResult_NOT_FOUND {
+		t.Errorf("got status %v, want NOT_FOUND", res.Status)
+	}
+}
+
+func TestFreeCancellationWindow(t *testing.T) {
+	s := newTestServer(t, map[string]int{"1": 1, "2": 1})
+	inDate := time.Now().UTC().AddDate(0, 0, 2)
+	outDate := inDate.AddDate(0, 0, 1)
+	tests := []struct {
+		hotelId string
+		window  time.Duration
+		want    bool
+	}{
+		{"1", 24 * time.Hour, true},
+		{"2", 7 * 24 * time.Hour, false},
+	}
+	for _, tt := range tests {
+		s.FreeCancellationWindow = tt.window
+		booked, err := book(s, tt.hotelId, inDate.Format("2006-01-02"), outDate.Format("2006-01-02"), 1)
+		if err != nil {
+			t.Fatalf("booking: %v", err)
+		}
+		res, err := s.CancelReservation(context.Background(), &pb.CancelRequest{ReservationId: booked.ReservationId})
+		if err != nil {
+			t.Fatalf("CancelReservation: %v", err)
+		}
+		if res.FreeCancellation != tt.want {
+			t.Errorf("window of %v two days before check-in: got free cancellation %v, want %v", tt.window, res.FreeCancellation, tt.want)
+		}
+	}
+}
+
+func TestConcurrentBookingsOfTheLastRoom(t *testing.T) {
+	s := newTestServer(t, map[string]int{"1": 1})
+	const bookings = 32
+	errs := make([]error, bookings)
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			_, errs[i] = book(s, "1", "2015-04-09", "2015-04-11", 1)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		switch status.Code(err) {
+		case codes.OK:
+			succeeded++
+		case codes.FailedPrecondition:
+		default:
+			t.Errorf("got %v, want FailedPrecondition for the bookings losing the race", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("%d of %d concurrent bookings of the last room succeeded, want 1", succeeded, bookings)
+	}
+
+	for _, date := range []string{"2015-04-09", "2015-04-10"} {
+		var n night
+		err := s.DB.Collection("night").FindOne(context.Background(), bson.M{"hotelId": "1", "date": date}).Decode(&n)
+		if err != nil {
+			t.Fatalf("finding the night of %s: %v", date, err)
+		}
+		if n.Reserved != 1 {
+			t.Errorf("%d rooms reserved on %s, want 1", n.Reserved, date)
+		}
+	}
+}
+
+func TestStaleCountLosesTheRace(t *testing.T) {
+	s := newTestServer(t, map[string]int{"1": 1})
+	if _, err := book(s, "1", "2015-04-09", "2015-04-10", 1); err != nil {
+		t.Fatalf("booking: %v", err)
+	}
+	// a concurrent booking read the count before the first one claimed it
+	waitCachedCount(t, s, "1", "2015-04-10", 1)
+	key := nightMemcKey("1", "standard", "2015-04-10")
+	if err := s.MemcClient.Set(&memcache.Item{Key: key, Value: []byte("0")}); err != nil {
+		t.Fatalf("caching a stale count: %v", err)
+	}
+
+	span := mocktracer.New().StartSpan("test").(*mocktracer.MockSpan)
+	ctx := opentracing.ContextWithSpan(context.Background(), span)
+	_, err := s.MakeReservation(ctx, &pb.Request{HotelId: []string{"1"}, InDate: "2015-04-09", OutDate: "2015-04-10", RoomNumber: 1})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("got %v, want FailedPrecondition", err)
+	}
+	if span.Tag("reservation.contended") != true {
+		t.Errorf("span of the booking losing the race not tagged contended")
+	}
+}
+
+// failingInsertDB is a database whose reservation inserts fail once ok more
+// of them succeeded.
+type failingInsertDB struct {
+	store.Database
+	ok int32
+}
+
+func (db *failingInsertDB) Collection(name string) store.Collection {
+	if name != "reservation" {
+		return db.Database.Collection(name)
+	}
+	return failingInsertCollection{db.Database.Collection(name), db}
+}
+
+type failingInsertCollection struct {
+	store.Collection
+	db *failingInsertDB
+}
+
+func (c failingInsertCollection) InsertOne(ctx context.Context, document interface{}, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
+	if atomic.AddInt32(&c.db.ok, -1) < 0 {
+		return nil, errors.New("disk full")
+	}
+	return c.Collection.InsertOne(ctx, document, opts...)
+}
+
+func TestMakeReservationFailedInsert(t *testing.T) {
+	s := newTestServer(t, map[string]int{"1": 2})
+	// the second of the three nights fails to insert
+	s.Shards = []store.Database{&failingInsertDB{Database: s.DB, ok: 1}}
+	if _, err := book(s, "1", "2015-04-09", "2015-04-12", 2); status.Code(err) != codes.Internal {
+		t.Fatalf("got %v, want Internal", err)
+	}
+	if n := reservations(t, s, "1"); n != 0 {
+		t.Errorf("%d nights of the failed booking left", n)
+	}
+
+	// the rooms it claimed are free again
+	for _, date := range []string{"2015-04-09", "2015-04-10", "2015-04-11"} {
+		var n night
+		err := s.DB.Collection("night").FindOne(context.Background(), bson.M{"hotelId": "1", "date": date}).Decode(&n)
+		if err != nil {
+			t.Fatalf("finding the night of %s: %v", date, err)
+		}
+		if n.Reserved != 0 {
+			t.Errorf("%d rooms reserved on %s, want 0", n.Reserved, date)
+		}
+	}
+	for _, next := range []string{"2015-04-10", "2015-04-11", "2015-04-12"} {
+		waitCachedCount(t, s, "1", next, 0)
+	}
+	s.Shards = []store.Database{s.DB}
+	if _, err := book(s, "1", "2015-04-09", "2015-04-12", 2); err != nil {
+		t.Errorf("booking the rooms of the failed booking: %v", err)
+	}
+}
+
+// cancellingDB is a database cancelling the booking once its first night
+// is inserted, and then failing the inserts if fail is set. Its writes fail,
+// as MongoDB's would, once their context is done.
+type cancellingDB struct {
+	store.Database
+	cancel context.CancelFunc
+	fail   bool
+}
+
+func (db *cancellingDB) Collection(name string) store.Collection {
+	return cancellingCollection{db.Database.Collection(name), db, name}
+}
+
+type cancellingCollection struct {
+	store.Collection
+	db   *cancellingDB
+	name string
+}
+
+func (c cancellingCollection) InsertOne(ctx context.Context, document interface{}, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
+	if c.name == "reservation" {
+		c.db.cancel()
+		if c.db.fail {
+			return nil, errors.New("disk full")
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.Collection.InsertOne(ctx, document, opts...)
+}
+
+func (c cancellingCollection) UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.Collection.UpdateOne(ctx, filter, update, opts...)
+}
+
+func (c cancellingCollection) DeleteOne(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.Collection.DeleteOne(ctx, filter, opts...)
+}
+
+func (c cancellingCollection) DeleteMany(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.Collection.DeleteMany(ctx, filter, opts...)
+}
+
+// twoNights books two nights of hotel 1 with an idempotency key.
+func twoNights() *pb.Request {
+	return &pb.Request{
+		CustomerName:   "Cornell_1",
+		HotelId:        []string{"1"},
+		InDate:         "2015-04-09",
+		OutDate:        "2015-04-11",
+		RoomNumber:     1,
+		IdempotencyKey: "key-1",
+	}
+}
+
+// bookCancelled books twoNights through db, which cancels the call once the
+// rooms are claimed.
+func bookCancelled(s *Server, db *cancellingDB) (*pb.Result, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	db.cancel = cancel
+	base := s.DB
+	s.DB, s.Shards = db, []store.Database{db}
+	defer func() { s.DB, s.Shards = base, []store.Database{base} }()
+	return s.MakeReservation(ctx, twoNights())
+}
+
+func TestMakeReservationOutlivesCancellation(t *testing.T) {
+	s := newIdempotentTestServer(t, map[string]int{"1": 1}, time.Hour)
+	first, err := bookCancelled(s, &cancellingDB{Database: s.DB})
+	if err != nil {
+		t.Fatalf("booking cancelled once the rooms were claimed: %v", err)
+	}
+	if n := reservations(t, s, "1"); n != 2 {
+		t.Errorf("%d nights booked, want 2", n)
+	}
+
+	// the key records the booking, so a retry replays it rather than
+	// failing as in progress
+	retry, err := s.MakeReservation(context.Background(), twoNights())
+	if err != nil || retry.ReservationId != first.ReservationId {
+		t.Errorf("retry: got %v, %v, want the reservation %s", retry, err, first.ReservationId)
+	}
+	if n := reservations(t, s, "1"); n != 2 {
+		t.Errorf("%d nights booked after the retry, want 2", n)
+	}
+}
+
+func TestFailedReservationOutlivesCancellation(t *testing.T) {
+	s := newIdempotentTestServer(t, map[string]int{"1": 1}, time.Hour)
+	if _, err := bookCancelled(s, &cancellingDB{Database: s.DB, fail: true}); status.Code(err) != codes.Canceled {
+		t.Fatalf("got %v, want Canceled", err)
+	}
+
+	// the rooms and the key are released all the same, so a retry books
+	for _, next := range []string{"2015-04-10", "2015-04-11"} {
+		waitCachedCount(t, s, "1", next, 0)
+	}
+	if _, err := s.MakeReservation(context.Background(), twoNights()); err != nil {
+		t.Fatalf("retry of the failed booking: %v", err)
+	}
+	if n := reservations(t, s, "1"); n != 2 {
+		t.Errorf("%d nights booked by the retry, want 2", n)
+	}
+}
+
+// addRoomType gives hotelId rooms of roomType on top of those of newTestServer.
+func addRoomType(t *testing.T, s *Server, hotelId, roomType string, rooms int) {
+	t.Helper()
+	if _, err := s.DB.Collection("number").InsertOne(context.Background(), bson.M{"hotelId": hotelId, "roomType": roomType, "numberOfRoom": rooms}); err != nil {
+		t.Fatalf("inserting the %s capacity of hotel %s: %v", roomType, hotelId, err)
+	}
+}
+
+// bookType reserves rooms of roomType in hotelId from inDate to outDate.
+func bookType(s *Server, hotelId, roomType, inDate, outDate string, rooms int32) (*pb.Result, error) {
+	return s.MakeReservation(context.Background(), &pb.Request{
+		CustomerName: "Cornell_1",
+		HotelId:      []string{hotelId},
+		InDate:       inDate,
+		OutDate:      outDate,
+		RoomNumber:   rooms,
+		RoomType:     roomType,
+	})
+}
+
+// availableType reports whether hotelId has rooms of roomType left from
+// inDate to outDate.
+func availableType(t *testing.T, s *Server, hotelId, roomType, inDate, outDate string, rooms int32) bool {
+	t.Helper()
+	res, err := s.CheckAvailability(context.Background(), &pb.Request{
+		HotelId:    []string{hotelId},
+		InDate:     inDate,
+		OutDate:    outDate,
+		RoomNumber: rooms,
+		RoomType:   roomType,
+	})
+	if err != nil {
+		t.Fatalf("CheckAvailability of %s rooms: %v", roomType, err)
+	}
+	if res.RoomType != s.roomType(roomType) {
+		t.Errorf("checked %s rooms, want %s", res.RoomType, s.roomType(roomType))
+	}
+	return len(res.HotelId) == 1
+}
+
+func TestRoomTypesHaveTheirOwnCapacity(t *testing.T) {
+	s := newTestServer(t, map[string]int{"1": 1})
+	addRoomType(t, s, "1", "deluxe", 2)
+	addRoomType(t, s, "1", "suite", 1)
+
+	res, err := bookType(s, "1", "suite", "2015-04-09", "2015-04-11", 1)
+	if err != nil {
+		t.Fatalf("booking the suite: %v", err)
+	}
+	if res.RoomType != "suite" {
+		t.Errorf("booked %s rooms, want suite", res.RoomType)
+	}
+	if _, err := bookType(s, "1", "suite", "2015-04-10", "2015-04-11", 1); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("booking a second suite: got %v, want FailedPrecondition", err)
+	}
+
+	// the suite being taken leaves the other rooms
+	if !availableType(t, s, "1", "deluxe", "2015-04-09", "2015-04-11", 2) {
+		t.Errorf("deluxe rooms unavailable with the suite booked")
+	}
+	if _, err := bookType(s, "1", "deluxe", "2015-04-09", "2015-04-11", 2); err != nil {
+		t.Fatalf("booking both deluxe rooms: %v", err)
+	}
+	if res, err := book(s, "1", "2015-04-09", "2015-04-11", 1); err != nil || res.RoomType != "standard" {
+		t.Fatalf("booking the standard room without a room type: got %v, %v", res, err)
+	}
+
+	for _, roomType := range []string{"", "standard", "deluxe", "suite"} {
+		if availableType(t, s, "1", roomType, "2015-04-09", "2015-04-11", 1) {
+			t.Errorf("%q rooms available with every room booked", roomType)
+		}
+		if !availableType(t, s, "1", roomType, "2015-04-11", "2015-04-12", 1) {
+			t.Errorf("%q rooms unavailable after the stays", roomType)
+		}
+	}
+}
+
+func TestUnknownRoomType(t *testing.T) {
+	s := newTestServer(t, map[string]int{"1": 1})
+	addRoomType(t, s, "1", "deluxe", 1)
+	if _, err := bookType(s, "1", "suite", "2015-04-09", "2015-04-10", 1); status.Code(err) != codes.NotFound {
+		t.Errorf("booking a room type the hotel doesn't have: got %v, want NotFound", err)
+	}
+	if availableType(t, s, "1", "suite", "2015-04-09", "2015-04-10", 1) {
+		t.Errorf("suites available in a hotel without any")
+	}
+}
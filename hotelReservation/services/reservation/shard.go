@@ -0,0 +1,121 @@
+package reservation
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+	"strconv"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/store"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// pointsPerShard is the number of points each shard has on the ring. More
+// points spread hotels more evenly between shards.
+const pointsPerShard = 160
+
+// ShardRing places hotels on shards with consistent hashing of their ID,
+// so that adding a shard only moves the hotels it takes over. The placement
+// of a hotel depends on the number of shards only.
+type ShardRing struct {
+	points []shardPoint
+}
+
+type shardPoint struct {
+	hash  uint32
+	shard int
+}
+
+// NewShardRing returns a ring over n shards, 1 if less.
+func NewShardRing(n int) *ShardRing {
+	if n < 1 {
+		n = 1
+	}
+	r := &ShardRing{points: make([]shardPoint, 0, n*pointsPerShard)}
+	for shard := 0; shard < n; shard++ {
+		for i := 0; i < pointsPerShard; i++ {
+			h := ringHash("shard-" + strconv.Itoa(shard) + "-" + strconv.Itoa(i))
+			r.points = append(r.points, shardPoint{hash: h, shard: shard})
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i].hash < r.points[j].hash })
+	return r
+}
+
+// Shard returns the shard of hotelId: that of the first point at or after
+// its hash, wrapping around the ring.
+func (r *ShardRing) Shard(hotelId string) int {
+	h := ringHash(hotelId)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i].hash >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].shard
+}
+
+// ringHash places key on the ring. Hotel IDs are short and alike, which a
+// checksum spreads unevenly, so it takes the first bytes of their SHA-256.
+func ringHash(key string) uint32 {
+	sum := sha256.Sum256([]byte(key))
+	return binary.BigEndian.Uint32(sum[:4])
+}
+
+// ShardDatabaseName returns the name of the database of shard i:
+// reservation-db for the first, so that a single shard is the unsharded
+// layout, and reservation-db-<i> for the others.
+func ShardDatabaseName(i int) string {
+	if i == 0 {
+		return "reservation-db"
+	}
+	return "reservation-db-" + strconv.Itoa(i)
+}
+
+// ShardDatabases returns the databases of n shards, 1 if less, of client.
+func ShardDatabases(client *mongo.Client, n int) []store.Database {
+	if n < 1 {
+		n = 1
+	}
+	dbs := make([]store.Database, n)
+	for i := range dbs {
+		dbs[i] = store.MongoDatabase(client.Database(ShardDatabaseName(i)))
+	}
+	return dbs
+}
+
+// hotelDB returns the shard holding the reservations and nights of hotelId.
+// Every read and write of them goes through it, so that availability is
+// checked where bookings are counted.
+func (s *Server) hotelDB(hotelId string) store.Database {
+	return s.Shards[s.ring.Shard(hotelId)]
+}
+
+// shardPlacement records the number of shards hotels were placed on, in the
+// reservation-db.shards collection.
+type shardPlacement struct {
+	ID     string `bson:"_id"`
+	Shards int    `bson:"shards"`
+}
+
+// checkShardCount records the number of shards the service runs with, and
+// warns if it changed since the last run: the reservations of the hotels
+// that moved are then on a shard that isn't read anymore.
+func (s *Server) checkShardCount(ctx context.Context) {
+	n := len(s.Shards)
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before)
+	update := bson.M{"$set": bson.M{"shards": n}}
+
+	var prior shardPlacement
+	err := s.DB.Collection("shards").FindOneAndUpdate(ctx, bson.M{"_id": "placement"}, update, opts).Decode(&prior)
+	switch {
+	case err == mongo.ErrNoDocuments:
+		log.Info().Msgf("Placing reservations on %d shards", n)
+	case err != nil:
+		log.Error().Msgf("Failed to check the reservation shard count: %v", err)
+	case prior.Shards != n:
+		log.Warn().Msgf("Reservation shard count changed from %d to %d: hotels moved to another shard lose their reservations until they are migrated", prior.Shards, n)
+	}
+}
@@ -0,0 +1,215 @@
+package reservation
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/inproc/fakestore"
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/reservation/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/store"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// hotelIds returns the IDs of n hotels.
+func hotelIds(n int) []string {
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = strconv.Itoa(i + 1)
+	}
+	return ids
+}
+
+func TestShardRingDeterministic(t *testing.T) {
+	ids := hotelIds(2000)
+	a, b := NewShardRing(4), NewShardRing(4)
+	counts := make([]int, 4)
+	for _, id := range ids {
+		shard := a.Shard(id)
+		if other := b.Shard(id); other != shard {
+			t.Fatalf("hotel %s placed on shard %d and %d", id, shard, other)
+		}
+		counts[shard]++
+	}
+	// spread evenly enough, every shard taking from 15% to 35% of the hotels
+	for shard, n := range counts {
+		if n < len(ids)*15/100 || n > len(ids)*35/100 {
+			t.Errorf("shard %d holds %d of %d hotels", shard, n, len(ids))
+		}
+	}
+
+	for _, n := range []int{-1, 0, 1} {
+		r := NewShardRing(n)
+		for _, id := range ids[:100] {
+			if shard := r.Shard(id); shard != 0 {
+				t.Fatalf("ring of %d shards placed hotel %s on shard %d", n, id, shard)
+			}
+		}
+	}
+}
+
+func TestShardRingGrowing(t *testing.T) {
+	ids := hotelIds(2000)
+	before, after := NewShardRing(4), NewShardRing(5)
+	moved := 0
+	for _, id := range ids {
+		from, to := before.Shard(id), after.Shard(id)
+		if from == to {
+			continue
+		}
+		// only to the new shard
+		if to != 4 {
+			t.Errorf("hotel %s moved from shard %d to %d, want to the new shard 4", id, from, to)
+		}
+		moved++
+	}
+	// about a fifth of the hotels move
+	if moved < len(ids)*10/100 || moved > len(ids)*30/100 {
+		t.Errorf("%d of %d hotels moved", moved, len(ids))
+	}
+}
+
+// newShardedTestServer is newTestServer over n shards, each on a MongoDB
+// stand-in of its own.
+func newShardedTestServer(t *testing.T, n int, capacities map[string]int) *Server {
+	t.Helper()
+	s := newTestServer(t, capacities)
+	for i := 1; i < n; i++ {
+		db := fakestore.Mongo(t).Database(ShardDatabaseName(i))
+		_, err := db.Collection("night").Indexes().CreateOne(context.Background(), mongo.IndexModel{
+			Keys:    bson.D{{Key: "hotelId", Value: 1}, {Key: "roomType", Value: 1}, {Key: "date", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		})
+		if err != nil {
+			t.Fatalf("creating the night index of shard %d: %v", i, err)
+		}
+		s.Shards = append(s.Shards, store.MongoDatabase(db))
+	}
+	s.ring = NewShardRing(n)
+	return s
+}
+
+// countOn returns the number of documents of hotelId in collection of shard.
+func countOn(t *testing.T, s *Server, shard int, collection, hotelId string) int {
+	t.Helper()
+	var docs []bson.M
+	cur, err := s.Shards[shard].Collection(collection).Find(context.Background(), bson.M{"hotelId": hotelId})
+	if err == nil {
+		err = cur.All(context.Background(), &docs)
+	}
+	if err != nil {
+		t.Fatalf("finding the %s of hotel %s on shard %d: %v", collection, hotelId, shard, err)
+	}
+	return len(docs)
+}
+
+func TestShardIsolation(t *testing.T) {
+	const shards = 3
+	// a hotel on each shard
+	ring := NewShardRing(shards)
+	byShard := make(map[int]string)
+	for _, id := range hotelIds(100) {
+		if _, ok := byShard[ring.Shard(id)]; !ok {
+			byShard[ring.Shard(id)] = id
+		}
+	}
+	if len(byShard) != shards {
+		t.Fatalf("got hotels on %d shards, want %d", len(byShard), shards)
+	}
+	capacities := make(map[string]int)
+	for _, id := range byShard {
+		capacities[id] = 1
+	}
+	s := newShardedTestServer(t, shards, capacities)
+
+	booked := make(map[string]string)
+	for shard := 0; shard < shards; shard++ {
+		id := byShard[shard]
+		res, err := book(s, id, "2015-04-09", "2015-04-11", 1)
+		if err != nil {
+			t.Fatalf("booking hotel %s: %v", id, err)
+		}
+		booked[id] = res.ReservationId
+	}
+
+	// the reservations and nights of a hotel are on its shard only
+	for shard, id := range byShard {
+		for other := 0; other < shards; other++ {
+			wantReservations, wantNights := 0, 0
+			if other == shard {
+				wantReservations, wantNights = 2, 2
+			}
+			if n := countOn(t, s, other, "reservation", id); n != wantReservations {
+				t.Errorf("hotel %s of shard %d: got %d reservations on shard %d, want %d", id, shard, n, other, wantReservations)
+			}
+			if n := countOn(t, s, other, "night", id); n != wantNights {
+				t.Errorf("hotel %s of shard %d: got %d nights on shard %d, want %d", id, shard, n, other, wantNights)
+			}
+		}
+	}
+
+	// availability is read where the bookings were counted
+	for _, id := range byShard {
+		if got := available(t, s, "2015-04-10", "2015-04-11", 1, id); got[id] {
+			t.Errorf("hotel %s available while booked", id)
+		}
+	}
+
+	// cancelling frees the rooms of its hotel only
+	cancelled := byShard[1]
+	if _, err := s.CancelReservation(context.Background(), &pb.CancelRequest{ReservationId: booked[cancelled]}); err != nil {
+		t.Fatalf("cancelling the booking of hotel %s: %v", cancelled, err)
+	}
+	waitCachedCount(t, s, cancelled, "2015-04-10", 0)
+	waitCachedCount(t, s, cancelled, "2015-04-11", 0)
+	for _, id := range byShard {
+		if got := available(t, s, "2015-04-10", "2015-04-11", 1, id)[id]; got != (id == cancelled) {
+			t.Errorf("hotel %s available %v, want %v", id, got, id == cancelled)
+		}
+	}
+	if _, err := book(s, cancelled, "2015-04-09", "2015-04-11", 1); err != nil {
+		t.Errorf("booking hotel %s again after cancelling: %v", cancelled, err)
+	}
+}
+
+func TestCheckShardCount(t *testing.T) {
+	logger, globalLevel := log.Logger, zerolog.GlobalLevel()
+	t.Cleanup(func() {
+		log.Logger = logger
+		zerolog.SetGlobalLevel(globalLevel)
+	})
+	logs := new(bytes.Buffer)
+	log.Logger = zerolog.New(logs)
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+
+	s := newTestServer(t, nil)
+	s.Shards = append(s.Shards, s.DB)
+	steps := []struct {
+		shards int
+		logged string
+		warned bool
+	}{
+		{2, "Placing reservations on 2 shards", false},
+		{2, "", false},
+		{3, "Reservation shard count changed from 2 to 3", true},
+	}
+	for _, step := range steps {
+		logs.Reset()
+		for len(s.Shards) < step.shards {
+			s.Shards = append(s.Shards, s.DB)
+		}
+		s.checkShardCount(context.Background())
+		if !strings.Contains(logs.String(), step.logged) {
+			t.Errorf("%d shards: %q not logged in %s", step.shards, step.logged, logs)
+		}
+		if warned := strings.Contains(logs.String(), `"level":"warn"`); warned != step.warned {
+			t.Errorf("%d shards: warned %v, want %v: %s", step.shards, warned, step.warned, logs)
+		}
+	}
+}
@@ -19,7 +19,9 @@ import (
 const _ = grpc.SupportPackageIsVersion7
 
 const (
-	Review_GetReviews_FullMethodName = "/review.Review/GetReviews"
+	Review_GetReviews_FullMethodName         = "/review.Review/GetReviews"
+	Review_GetAggregateRating_FullMethodName = "/review.Review/GetAggregateRating"
+	Review_AddReview_FullMethodName          = "/review.Review/AddReview"
 )
 
 // ReviewClient is the client API for Review service.
@@ -27,6 +29,10 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type ReviewClient interface {
 	GetReviews(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Result, error)
+	// Returns the average rating of a hotel over its reviews.
+	GetAggregateRating(ctx context.Context, in *Request, opts ...grpc.CallOption) (*AggregateRating, error)
+	// Adds a review, updating the aggregate rating of its hotel.
+	AddReview(ctx context.Context, in *ReviewComm, opts ...grpc.CallOption) (*AggregateRating, error)
 }
 
 type reviewClient struct {
@@ -46,11 +52,33 @@ func (c *reviewClient) GetReviews(ctx context.Context, in *Request, opts ...grpc
 	return out, nil
 }
 
+func (c *reviewClient) GetAggregateRating(ctx context.Context, in *Request, opts ...grpc.CallOption) (*AggregateRating, error) {
+	out := new(AggregateRating)
+	err := c.cc.Invoke(ctx, Review_GetAggregateRating_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *reviewClient) AddReview(ctx context.Context, in *ReviewComm, opts ...grpc.CallOption) (*AggregateRating, error) {
+	out := new(AggregateRating)
+	err := c.cc.Invoke(ctx, Review_AddReview_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ReviewServer is the server API for Review service.
 // All implementations must embed UnimplementedReviewServer
 // for forward compatibility
 type ReviewServer interface {
 	GetReviews(context.Context, *Request) (*Result, error)
+	// Returns the average rating of a hotel over its reviews.
+	GetAggregateRating(context.Context, *Request) (*AggregateRating, error)
+	// Adds a review, updating the aggregate rating of its hotel.
+	AddReview(context.Context, *ReviewComm) (*AggregateRating, error)
 	mustEmbedUnimplementedReviewServer()
 }
 
@@ -61,6 +89,12 @@ type UnimplementedReviewServer struct {
 func (UnimplementedReviewServer) GetReviews(context.Context, *Request) (*Result, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetReviews not implemented")
 }
+func (UnimplementedReviewServer) GetAggregateRating(context.Context, *Request) (*AggregateRating, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAggregateRating not implemented")
+}
+func (UnimplementedReviewServer) AddReview(context.Context, *ReviewComm) (*AggregateRating, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddReview not implemented")
+}
 func (UnimplementedReviewServer) mustEmbedUnimplementedReviewServer() {}
 
 // UnsafeReviewServer may be embedded to opt out of forward compatibility for this service.
@@ -92,6 +126,42 @@ func _Review_GetReviews_Handler(srv interface{}, ctx context.Context, dec func(i
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Review_GetAggregateRating_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReviewServer).GetAggregateRating(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Review_GetAggregateRating_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReviewServer).GetAggregateRating(ctx, req.(*Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Review_AddReview_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReviewComm)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReviewServer).AddReview(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Review_AddReview_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReviewServer).AddReview(ctx, req.(*ReviewComm))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Review_ServiceDesc is the grpc.ServiceDesc for Review service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -103,6 +173,14 @@ var Review_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetReviews",
 			Handler:    _Review_GetReviews_Handler,
 		},
+		{
+			MethodName: "GetAggregateRating",
+			Handler:    _Review_GetAggregateRating_Handler,
+		},
+		{
+			MethodName: "AddReview",
+			Handler:    _Review_AddReview_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "services/review/proto/review.proto",
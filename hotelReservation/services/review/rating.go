@@ -0,0 +1,142 @@
+package review
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails"
+	errpb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails/proto"
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/review/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/store"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
+	"github.com/google/uuid"
+	"github.com/opentracing/opentracing-go"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	// neutralRating is the average rating of hotels without reviews, the
+	// middle of the [0, maxRating] scale.
+	neutralRating = 2.5
+	maxRating     = 5
+
+	// ratingKeyPrefix prefixes the hotel ID in the memcached key of its
+	// aggregate rating, the bare ID keying its reviews.
+	ratingKeyPrefix = "rating_"
+)
+
+// aggregate is the cached aggregate rating of a hotel.
+type aggregate struct {
+	Sum   float64 `json:"sum"`
+	Count int32   `json:"count"`
+}
+
+// toProto returns a as the aggregate rating of hotelId, neutralRating on
+// average if a counts no reviews.
+func (a aggregate) toProto(hotelId string) *pb.AggregateRating {
+	res := &pb.AggregateRating{HotelId: hotelId, Average: neutralRating, Count: a.Count}
+	if a.Count > 0 {
+		res.Average = float32(a.Sum / float64(a.Count))
+	}
+	return res
+}
+
+// GetAggregateRating returns the average rating of a hotel over its reviews
+// and their number, cached in memcached until a review of the hotel is
+// added, or for RatingTTL seconds at most. Hotels without reviews get the
+// neutral rating.
+func (s *Server) GetAggregateRating(ctx context.Context, req *pb.Request) (*pb.AggregateRating, error) {
+	key := ratingKeyPrefix + req.HotelId
+
+	memSpan, _ := opentracing.StartSpanFromContext(ctx, "memcached_get_rating")
+	memSpan.SetTag("span.kind", "client")
+	item, err := s.MemcClient.Get(key)
+	memSpan.Finish()
+
+	var agg aggregate
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(item.Value, &agg); err == nil {
+			return agg.toProto(req.HotelId), nil
+		}
+		tracing.Logger(ctx).Warn().Msgf("Ignoring malformed cached rating of hotel %s: %v", req.HotelId, err)
+	case err != memcache.ErrCacheMiss:
+		tracing.Logger(ctx).Warn().Msgf("Failed to get the cached rating of hotel %s: %v", req.HotelId, err)
+	}
+
+	agg, err = s.aggregateRating(ctx, req.HotelId)
+	if err != nil {
+		return nil, err
+	}
+	if b, err := json.Marshal(agg); err == nil {
+		if err := s.MemcClient.Set(&memcache.Item{Key: key, Value: b, Expiration: s.RatingTTL}); err != nil {
+			tracing.Logger(ctx).Warn().Msgf("Failed to cache the rating of hotel %s: %v", req.HotelId, err)
+		}
+	}
+	return agg.toProto(req.HotelId), nil
+}
+
+// aggregateRating sums the ratings of the reviews of hotelId in mongo.
+func (s *Server) aggregateRating(ctx context.Context, hotelId string) (aggregate, error) {
+	mongoSpan, _ := opentracing.StartSpanFromContext(ctx, "mongo_rating")
+	mongoSpan.SetTag("span.kind", "client")
+	defer mongoSpan.Finish()
+
+	c := s.DB.Collection("reviews")
+	curr, err := c.Find(ctx, bson.M{"hotelId": hotelId}, options.Find().SetProjection(bson.M{"rating": 1}))
+	if err != nil {
+		return aggregate{}, store.Error(ctx, err, codes.Unavailable, "failed to get the reviews of hotel %s", hotelId)
+	}
+	var reviews []ReviewHelper
+	if err := curr.All(ctx, &reviews); err != nil {
+		return aggregate{}, store.Error(ctx, err, codes.Unavailable, "failed to get the reviews of hotel %s", hotelId)
+	}
+
+	var agg aggregate
+	for _, r := range reviews {
+		agg.Sum += float64(r.Rating)
+		agg.Count++
+	}
+	return agg, nil
+}
+
+// AddReview stores a review, with a new ID unless it has one, and returns
+// the updated aggregate rating of its hotel. The cached reviews and rating
+// of the hotel are invalidated. Ratings must be in [0, maxRating].
+func (s *Server) AddReview(ctx context.Context, req *pb.ReviewComm) (*pb.AggregateRating, error) {
+	if !(req.Rating >= 0 && req.Rating <= maxRating) {
+		return nil, errdetails.InvalidArgument(errpb.ErrorDetail_OUT_OF_RANGE, "rating", "rating must be in [0, %d], got %v", maxRating, req.Rating)
+	}
+
+	review := ReviewHelper{
+		ReviewId:    req.ReviewId,
+		HotelId:     req.HotelId,
+		Name:        req.Name,
+		Rating:      req.Rating,
+		Description: req.Description,
+		Image:       req.Images,
+	}
+	if review.ReviewId == "" {
+		review.ReviewId = uuid.New().String()
+	}
+
+	mongoSpan, _ := opentracing.StartSpanFromContext(ctx, "mongo_insert_review")
+	mongoSpan.SetTag("span.kind", "client")
+	_, err := s.DB.Collection("reviews").InsertOne(ctx, &review)
+	mongoSpan.Finish()
+	if err != nil {
+		return nil, store.Error(ctx, err, codes.Unavailable, "failed to store the review")
+	}
+
+	for _, key := range []string{req.HotelId, ratingKeyPrefix + req.HotelId} {
+		if err := s.MemcClient.Delete(key); err != nil && err != memcache.ErrCacheMiss {
+			tracing.Logger(ctx).Warn().Msgf("Failed to invalidate cached %s: %v", key, err)
+		}
+	}
+	tracing.Logger(ctx).Debug().Msgf("Added review %s of hotel %s", review.ReviewId, req.HotelId)
+
+	return s.GetAggregateRating(ctx, &pb.Request{HotelId: req.HotelId})
+}
@@ -0,0 +1,133 @@
+package review
+
+import (
+	"context"
+	"testing"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/inproc/fakestore"
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/review/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/store"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newTestServer returns a server backed by stand-ins of MongoDB and
+// memcached, without reviews.
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	return &Server{
+		DB:         store.MongoDatabase(fakestore.Mongo(t).Database("review-db")),
+		MemcClient: fakestore.Memcached(t),
+	}
+}
+
+// addReview adds a review of hotelId rated rating through s.
+func addReview(t *testing.T, s *Server, hotelId string, rating float32) *pb.AggregateRating {
+	t.Helper()
+	res, err := s.AddReview(context.Background(), &pb.ReviewComm{HotelId: hotelId, Name: "guest", Rating: rating})
+	if err != nil {
+		t.Fatalf("AddReview(%s, %v): %v", hotelId, rating, err)
+	}
+	return res
+}
+
+// getRating returns the aggregate rating of hotelId from s.
+func getRating(t *testing.T, s *Server, hotelId string) *pb.AggregateRating {
+	t.Helper()
+	res, err := s.GetAggregateRating(context.Background(), &pb.Request{HotelId: hotelId})
+	if err != nil {
+		t.Fatalf("GetAggregateRating(%s): %v", hotelId, err)
+	}
+	return res
+}
+
+func checkRating(t *testing.T, got *pb.AggregateRating, hotelId string, average float32, count int32) {
+	t.Helper()
+	if got.HotelId != hotelId || got.Average != average || got.Count != count {
+		t.Errorf("rating = {%s %v %d}, want {%s %v %d}", got.HotelId, got.Average, got.Count, hotelId, average, count)
+	}
+}
+
+func TestGetAggregateRatingNoReviews(t *testing.T) {
+	s := newTestServer(t)
+	checkRating(t, getRating(t, s, "1"), "1", neutralRating, 0)
+	// again, from the cache
+	checkRating(t, getRating(t, s, "1"), "1", neutralRating, 0)
+}
+
+func TestAddReviewAggregates(t *testing.T) {
+	s := newTestServer(t)
+	// cache the neutral rating, which the reviews must invalidate
+	checkRating(t, getRating(t, s, "1"), "1", neutralRating, 0)
+
+	checkRating(t, addReview(t, s, "1", 4), "1", 4, 1)
+	checkRating(t, addReview(t, s, "1", 5), "1", 4.5, 2)
+	checkRating(t, addReview(t, s, "1", 0), "1", 3, 3)
+	checkRating(t, addReview(t, s, "2", 1), "2", 1, 1)
+
+	checkRating(t, getRating(t, s, "1"), "1", 3, 3)
+	checkRating(t, getRating(t, s, "2"), "2", 1, 1)
+	checkRating(t, getRating(t, s, "3"), "3", neutralRating, 0)
+}
+
+func TestAddReviewInvalidatesCache(t *testing.T) {
+	s := newTestServer(t)
+	addReview(t, s, "1", 4)
+
+	// a review stored behind the server's back goes unseen while cached
+	if _, err := s.DB.Collection("reviews").InsertOne(context.Background(), &ReviewHelper{ReviewId: "direct", HotelId: "1", Rating: 2}); err != nil {
+		t.Fatalf("inserting a review: %v", err)
+	}
+	checkRating(t, getRating(t, s, "1"), "1", 4, 1)
+
+	res, err := s.GetReviews(context.Background(), &pb.Request{HotelId: "1"})
+	if err != nil {
+		t.Fatalf("GetReviews: %v", err)
+	}
+	if len(res.Reviews) != 2 {
+		t.Fatalf("got %d reviews, want 2", len(res.Reviews))
+	}
+
+	// adding one drops both cached entries
+	checkRating(t, addReview(t, s, "1", 3), "1", 3, 3)
+	if res, err = s.GetReviews(context.Background(), &pb.Request{HotelId: "1"}); err != nil {
+		t.Fatalf("GetReviews: %v", err)
+	}
+	if len(res.Reviews) != 3 {
+		t.Errorf("got %d reviews after adding one, want 3", len(res.Reviews))
+	}
+}
+
+func TestAddReviewKeepsId(t *testing.T) {
+	s := newTestServer(t)
+	if _, err := s.AddReview(context.Background(), &pb.ReviewComm{ReviewId: "r1", HotelId: "1", Rating: 4}); err != nil {
+		t.Fatalf("AddReview: %v", err)
+	}
+	addReview(t, s, "1", 5)
+
+	res, err := s.GetReviews(context.Background(), &pb.Request{HotelId: "1"})
+	if err != nil {
+		t.Fatalf("GetReviews: %v", err)
+	}
+	ids := map[string]bool{}
+	for _, r := range res.Reviews {
+		if r.ReviewId == "" {
+			t.Errorf("review rated %v has no ID", r.Rating)
+		}
+		ids[r.ReviewId] = true
+	}
+	if !ids["r1"] || len(ids) != 2 {
+		t.Errorf("review IDs = %v, want r1 and a new one", ids)
+	}
+}
+
+func TestAddReviewOutOfRange(t *testing.T) {
+	s := newTestServer(t)
+	for _, rating := range []float32{-1, maxRating + 0.5} {
+		_, err := s.AddReview(context.Background(), &pb.ReviewComm{HotelId: "1", Rating: rating})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("AddReview rated %v: got %v, want InvalidArgument", rating, err)
+		}
+	}
+	checkRating(t, getRating(t, s, "1"), "1", neutralRating, 0)
+}
@@ -16,23 +16,35 @@ import (
 
 	"github.com/rs/zerolog/log"
 
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/graceful"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/healthcheck"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/registry"
 	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/review/proto"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tls"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
 	"github.com/google/uuid"
-	"github.com/grpc-ecosystem/grpc-opentracing/go/otgrpc"
 	"github.com/opentracing/opentracing-go"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/keepalive"
 
 	// "strings"
 
 	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/store"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
+	"google.golang.org/grpc/reflection"
 )
 
 const name = "srv-review"
 
+// validators check requests before their handler runs.
+var validators = map[string]tracing.Validator{
+	pb.Review_GetAggregateRating_FullMethodName: tracing.Required("hotelId"),
+	pb.Review_AddReview_FullMethodName:          tracing.Required("hotelId"),
+}
+
 // Server implements the rate service
 type Server struct {
 	pb.UnimplementedReviewServer
@@ -41,19 +53,31 @@ type Server struct {
 	Port        int
 	IpAddr      string
 	MongoClient *mongo.Client
-	Registry    *registry.Client
-	MemcClient  *memcache.Client
+	// DB is the review-db database, that of MongoClient if nil.
+	DB         store.Database
+	Registry   *registry.Client
+	MemcClient store.Memcache
+	// RatingTTL is the expiration in seconds of cached aggregate ratings,
+	// zero for none.
+	RatingTTL int32
+	// MetricsPort is the admin port serving /metrics, zero to disable it.
+	MetricsPort int
 	uuid        string
+	health      *healthcheck.Checker
+	grpcServer  *grpc.Server
+	traffic     tracing.MetricsMark
 }
 
 // Run starts the server
 func (s *Server) Run() error {
-	opentracing.SetGlobalTracer(s.Tracer)
-
 	if s.Port == 0 {
 		return fmt.Errorf("server port must be set")
 	}
 
+	if s.DB == nil {
+		s.DB = store.MongoDatabase(s.MongoClient.Database("review-db"))
+	}
+
 	s.uuid = uuid.New().String()
 
 	opts := []grpc.ServerOption{
@@ -61,11 +85,18 @@ func (s *Server) Run() error {
 			Timeout: 120 * time.Second,
 		}),
 		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             time.Duration(tune.GetKeepaliveTime()) * time.Millisecond,
 			PermitWithoutStream: true,
 		}),
-		grpc.UnaryInterceptor(
-			otgrpc.OpenTracingServerInterceptor(s.Tracer),
-		),
+		grpc.MaxRecvMsgSize(tune.GetMaxRecvMsgSize()),
+		grpc.MaxSendMsgSize(tune.GetMaxSendMsgSize()),
+		grpc.UnaryInterceptor(tracing.DefaultServerInterceptorChain(tracing.ServerChainOptions{
+			Tracer: s.Tracer,
+			Interceptors: []grpc.UnaryServerInterceptor{
+				tracing.ChaosUnaryServerInterceptor(tracing.DefaultChaos),
+				tracing.ValidationUnaryServerInterceptor(validators),
+			},
+		})),
 	}
 
 	if tlsopt := tls.GetServerOpt(); tlsopt != nil {
@@ -73,8 +104,23 @@ func (s *Server) Run() error {
 	}
 
 	srv := grpc.NewServer(opts...)
+	s.grpcServer = srv
+	s.traffic = tracing.DefaultMetrics.Mark()
 
 	pb.RegisterReviewServer(srv, s)
+	if tune.GetGrpcReflection() {
+		reflection.Register(srv)
+	}
+
+	s.health = healthcheck.Register(srv, pb.Review_ServiceDesc.ServiceName, healthcheck.Mongo(s.MongoClient), healthcheck.Memcached(s.MemcClient))
+	s.health.Start()
+
+	if s.MetricsPort != 0 {
+		go tracing.ServeMetrics(s.MetricsPort, tracing.DefaultMetrics)
+	}
+	if path := tune.GetFeatureFlagsFile(); path != "" {
+		go tracing.DefaultFlags.WatchFile(path, time.Duration(tune.GetFeatureFlagsPollInterval())*time.Millisecond)
+	}
 
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", s.Port))
 	if err != nil {
@@ -90,9 +136,13 @@ func (s *Server) Run() error {
 	return srv.Serve(lis)
 }
 
-// Shutdown cleans up any processes
+// Shutdown reports the server NOT_SERVING, deregisters it and drains
+// in-flight RPCs before stopping it, then reports the traffic it served.
 func (s *Server) Shutdown() {
+	s.health.Shutdown()
 	s.Registry.Deregister(s.uuid)
+	graceful.Stop(s.grpcServer)
+	graceful.Report(s.grpcServer, s.traffic)
 }
 
 type ReviewHelper struct {
@@ -130,18 +180,20 @@ func (s *Server) GetReviews(ctx context.Context, req *pb.Request) (*pb.Result, e
 			//session := s.MongoSession.Copy()
 			//defer session.Close()
 			//c := session.DB("review-db").C("reviews")
-			c := s.MongoClient.Database("review-db").Collection("reviews")
+			c := s.DB.Collection("reviews")
 
-			curr, err := c.Find(context.TODO(), bson.M{"hotelId": hotelId})
+			curr, err := c.Find(ctx, bson.M{"hotelId": hotelId})
 			if err != nil {
-				log.Error().Msgf("Failed get reviews: ", err)
+				mongoSpan.Finish()
+				return nil, store.Error(ctx, err, codes.Unavailable, "failed to get the reviews of hotel %s", hotelId)
 			}
 
 			var reviewHelpers []ReviewHelper
 			//err = c.Find(bson.M{"hotelId": hotelId}).All(&reviewHelpers)
-			curr.All(context.TODO(), &reviewHelpers)
+			err = curr.All(ctx, &reviewHelpers)
+			mongoSpan.Finish()
 			if err != nil {
-				log.Error().Msgf("Failed get hotels data: ", err)
+				return nil, store.Error(ctx, err, codes.Unavailable, "failed to get the reviews of hotel %s", hotelId)
 			}
 
 			for _, reviewHelper := range reviewHelpers {
@@ -156,7 +208,7 @@ func (s *Server) GetReviews(ctx context.Context, req *pb.Request) (*pb.Result, e
 
 			reviewJson, err := json.Marshal(reviews)
 			if err != nil {
-				log.Error().Msgf("Failed to marshal hotel [id: %v] with err:", hotelId, err)
+				log.Error().Msgf("Failed to marshal hotel [id: %v] with err: %v", hotelId, err)
 			}
 			memcStr := string(reviewJson)
 
@@ -0,0 +1,49 @@
+package search
+
+import (
+	"crypto/sha256"
+	"math"
+
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/search/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+// quantize returns a copy of req with its coordinates rounded to precision
+// decimal places, req itself if precision is negative. Searches of the same
+// cell then share a key, and are all answered for the rounded coordinates,
+// not only those that searched first.
+func quantize(req *pb.NearbyRequest, precision int) *pb.NearbyRequest {
+	if precision < 0 {
+		return req
+	}
+	scale := math.Pow10(precision)
+	q := proto.Clone(req).(*pb.NearbyRequest)
+	q.Lat = float32(math.Round(float64(req.Lat)*scale) / scale)
+	q.Lon = float32(math.Round(float64(req.Lon)*scale) / scale)
+	return q
+}
+
+// cacheKey hashes the deterministic encoding of req, in which fields are
+// ordered by number whatever the order they were sent in, and unknown fields
+// are left out. Every field of the request is part of the key; -0 is
+// encoded as 0.
+func cacheKey(req *pb.NearbyRequest) (string, error) {
+	norm := proto.Clone(req).(*pb.NearbyRequest)
+	norm.ProtoReflect().SetUnknown(nil)
+	for _, f := range []*float32{&norm.Lat, &norm.Lon, &norm.MinStars} {
+		if *f == 0 {
+			*f = 0
+		}
+	}
+	for _, f := range []*float64{&norm.MinPrice, &norm.MaxPrice} {
+		if *f == 0 {
+			*f = 0
+		}
+	}
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(norm)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return string(sum[:]), nil
+}
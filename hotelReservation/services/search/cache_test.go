@@ -0,0 +1,226 @@
+package search
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/cache"
+	geo "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/geo/proto"
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/search/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// mustKey returns the cache key of req.
+func mustKey(t *testing.T, req *pb.NearbyRequest) string {
+	t.Helper()
+	key, err := cacheKey(req)
+	if err != nil {
+		t.Fatalf("cacheKey: %v", err)
+	}
+	return key
+}
+
+func TestCacheKeyFieldOrder(t *testing.T) {
+	req := &pb.NearbyRequest{Lat: 37.7, Lon: -122.4, InDate: "2015-04-09", OutDate: "2015-04-10", MinStars: 3}
+
+	// the same fields sent in reverse order, with an unknown one
+	var b []byte
+	b = protowire.AppendTag(b, 9, protowire.Fixed32Type)
+	b = protowire.AppendFixed32(b, math.Float32bits(3))
+	b = protowire.AppendTag(b, 99, protowire.VarintType)
+	b = protowire.AppendVarint(b, 1)
+	b = protowire.AppendTag(b, 4, protowire.BytesType)
+	b = protowire.AppendString(b, "2015-04-10")
+	b = protowire.AppendTag(b, 3, protowire.BytesType)
+	b = protowire.AppendString(b, "2015-04-09")
+	b = protowire.AppendTag(b, 2, protowire.Fixed32Type)
+	b = protowire.AppendFixed32(b, math.Float32bits(-122.4))
+	b = protowire.AppendTag(b, 1, protowire.Fixed32Type)
+	b = protowire.AppendFixed32(b, math.Float32bits(37.7))
+	reordered := new(pb.NearbyRequest)
+	if err := proto.Unmarshal(b, reordered); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	if mustKey(t, req) != mustKey(t, reordered) {
+		t.Error("same request sent in another order has another key")
+	}
+	if mustKey(t, &pb.NearbyRequest{Lat: float32(math.Copysign(0, -1))}) != mustKey(t, &pb.NearbyRequest{}) {
+		t.Error("-0 and 0 have different keys")
+	}
+}
+
+func TestCacheKeyFields(t *testing.T) {
+	base := &pb.NearbyRequest{Lat: 37.7, Lon: -122.4, InDate: "2015-04-09", OutDate: "2015-04-10"}
+	keys := map[string]string{"base": mustKey(t, base)}
+	for name, change := range map[string]func(r *pb.NearbyRequest){
+		"lat":       func(r *pb.NearbyRequest) { r.Lat = 37.8 },
+		"lon":       func(r *pb.NearbyRequest) { r.Lon = -122.5 },
+		"inDate":    func(r *pb.NearbyRequest) { r.InDate = "2015-04-08" },
+		"outDate":   func(r *pb.NearbyRequest) { r.OutDate = "2015-04-11" },
+		"limit":     func(r *pb.NearbyRequest) { r.Limit = 5 },
+		"pageToken": func(r *pb.NearbyRequest) { r.PageToken = "next" },
+		"minPrice":  func(r *pb.NearbyRequest) { r.MinPrice = 100 },
+		"maxPrice":  func(r *pb.NearbyRequest) { r.MaxPrice = 200 },
+		"minStars":  func(r *pb.NearbyRequest) { r.MinStars = 3 },
+		"locale":    func(r *pb.NearbyRequest) { r.Locale = "fr" },
+		"sort": func(r *pb.NearbyRequest) {
+			r.Sort = []*pb.SortKey{{Field: pb.SortKey_PRICE}}
+		},
+	} {
+		req := proto.Clone(base).(*pb.NearbyRequest)
+		change(req)
+		key := mustKey(t, req)
+		for other, k := range keys {
+			if k == key {
+				t.Errorf("changing %s gives the key of %s", name, other)
+			}
+		}
+		keys[name] = key
+	}
+}
+
+func TestQuantize(t *testing.T) {
+	req := &pb.NearbyRequest{Lat: 37.78412, Lon: -122.40679, InDate: "2015-04-09"}
+	q := quantize(req, 3)
+	if q.Lat != 37.784 || q.Lon != -122.407 || q.InDate != req.InDate {
+		t.Errorf("got %v, want the coordinates rounded to 3 places", q)
+	}
+	if req.Lat != 37.78412 {
+		t.Error("quantize changed the request")
+	}
+	if quantize(req, -1) != req {
+		t.Error("negative precision rounded the request")
+	}
+	if mustKey(t, quantize(&pb.NearbyRequest{Lat: 37.7841}, 3)) != mustKey(t, quantize(&pb.NearbyRequest{Lat: 37.7839}, 3)) {
+		t.Error("searches of the same cell have different keys")
+	}
+}
+
+// newCachedTestServer returns a search server caching results for ttl.
+func newCachedTestServer(ttl time.Duration, hotels ...fakeHotel) (*Server, *backends) {
+	s, b := newTestServer(hotels...)
+	s.CachePrecision = -1
+	s.cache = cache.New(10, ttl)
+	return s, b
+}
+
+func TestNearbyCache(t *testing.T) {
+	s, b := newCachedTestServer(100*time.Millisecond, filterHotels...)
+	req := &pb.NearbyRequest{Lat: 37.7, Lon: -122.4, InDate: "2015-04-09", OutDate: "2015-04-10"}
+
+	for i, want := range []string{"miss", "hit", "hit"} {
+		ctx, span := withMockSpan(context.Background())
+		res, err := s.Nearby(ctx, req)
+		if err != nil {
+			t.Fatalf("Nearby: %v", err)
+		}
+		if span.Tag("cache") != want {
+			t.Errorf("search %d: cache = %v, want %s", i+1, span.Tag("cache"), want)
+		}
+		if len(res.HotelIds) != len(filterHotels) {
+			t.Errorf("search %d: got hotels %v, want all", i+1, res.HotelIds)
+		}
+		// callers may change the results they get
+		res.HotelIds = nil
+	}
+	if n := b.callCount("geo"); n != 1 {
+		t.Errorf("geo called %d times, want once", n)
+	}
+
+	// another search misses
+	ctx, span := withMockSpan(context.Background())
+	if _, err := s.Nearby(ctx, &pb.NearbyRequest{Lat: 37.7, Lon: -122.4, InDate: "2015-04-09", OutDate: "2015-04-10", MinStars: 3}); err != nil {
+		t.Fatalf("Nearby: %v", err)
+	}
+	if span.Tag("cache") != "miss" {
+		t.Errorf("other search: cache = %v, want miss", span.Tag("cache"))
+	}
+
+	// as does the first once expired
+	time.Sleep(100 * time.Millisecond)
+	ctx, span = withMockSpan(context.Background())
+	if _, err := s.Nearby(ctx, req); err != nil {
+		t.Fatalf("Nearby: %v", err)
+	}
+	if span.Tag("cache") != "miss" {
+		t.Errorf("expired search: cache = %v, want miss", span.Tag("cache"))
+	}
+	if n := b.callCount("geo"); n != 3 {
+		t.Errorf("geo called %d times, want 3", n)
+	}
+}
+
+// centerGeo records the centers geo is searched around.
+type centerGeo struct {
+	geo.GeoClient
+	centers *[][2]float32
+}
+
+func (g centerGeo) Nearby(ctx context.Context, req *geo.Request, opts ...grpc.CallOption) (*geo.Result, error) {
+	*g.centers = append(*g.centers, [2]float32{req.Lat, req.Lon})
+	return g.GeoClient.Nearby(ctx, req, opts...)
+}
+
+func TestNearbyCacheQuantized(t *testing.T) {
+	s, b := newCachedTestServer(time.Minute, filterHotels...)
+	// 3 places are cells of about 110m
+	s.CachePrecision = 3
+	var centers [][2]float32
+	s.GeoClient = centerGeo{s.GeoClient, &centers}
+
+	search := func(lat, lon float32) interface{} {
+		t.Helper()
+		ctx, span := withMockSpan(context.Background())
+		res, err := s.Nearby(ctx, &pb.NearbyRequest{Lat: lat, Lon: lon, InDate: "2015-04-09", OutDate: "2015-04-10"})
+		if err != nil {
+			t.Fatalf("Nearby(%v, %v): %v", lat, lon, err)
+		}
+		if len(res.HotelIds) != len(filterHotels) {
+			t.Errorf("Nearby(%v, %v): got hotels %v, want all", lat, lon, res.HotelIds)
+		}
+		return span.Tag("cache")
+	}
+
+	// replays jittered within the cell of 37.784, -122.407
+	for i, c := range [][2]float32{
+		{37.78412, -122.40679},
+		{37.78401, -122.40702},
+		{37.7836, -122.4074},
+		{37.784449, -122.406551},
+	} {
+		want := "hit"
+		if i == 0 {
+			want = "miss"
+		}
+		if got := search(c[0], c[1]); got != want {
+			t.Errorf("search at %v: cache = %v, want %s", c, got, want)
+		}
+	}
+	// and across cells
+	for _, c := range [][2]float32{
+		{37.7846, -122.4068},
+		{37.7841, -122.4076},
+		{37.7834, -122.4068},
+	} {
+		if got := search(c[0], c[1]); got != "miss" {
+			t.Errorf("search at %v: cache = %v, want miss", c, got)
+		}
+	}
+
+	if n := b.callCount("geo"); n != 4 {
+		t.Errorf("geo called %d times, want 4", n)
+	}
+	// every cell is searched around its center, whichever search came first
+	want := [][2]float32{{37.784, -122.407}, {37.785, -122.407}, {37.784, -122.408}, {37.783, -122.407}}
+	for i, c := range centers {
+		if i >= len(want) || c != want[i] {
+			t.Errorf("geo searched around %v, want %v", centers, want)
+			break
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package search
+
+import (
+	"context"
+
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+// batchIDs returns the hotel ids to send to rpc in a single batch call:
+// ids without duplicates, in the order they first appear. It tags the span
+// in ctx with their number as <rpc>.batch_ids, and with the calls a
+// per-hotel fan-out would have made on top of the batch as
+// <rpc>.calls_saved.
+func batchIDs(ctx context.Context, rpc string, ids []string) []string {
+	batch := make([]string, 0, len(ids))
+	seen := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		batch = append(batch, id)
+	}
+
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		saved := len(batch) - 1
+		if saved < 0 {
+			saved = 0
+		}
+		span.SetTag(rpc+".batch_ids", len(batch))
+		span.SetTag(rpc+".calls_saved", saved)
+	}
+	return batch
+}
@@ -0,0 +1,76 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/search/proto"
+)
+
+func TestBatchIDs(t *testing.T) {
+	tests := []struct {
+		ids, want []string
+		saved     int
+	}{
+		{nil, []string{}, 0},
+		{[]string{"1"}, []string{"1"}, 0},
+		{[]string{"3", "1", "2"}, []string{"3", "1", "2"}, 2},
+		{[]string{"2", "1", "2", "3", "1"}, []string{"2", "1", "3"}, 2},
+	}
+	for _, tt := range tests {
+		ctx, span := withMockSpan(context.Background())
+		got := batchIDs(ctx, "rate", tt.ids)
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("batchIDs(%v) = %v, want %v", tt.ids, got, tt.want)
+		}
+		if span.Tag("rate.batch_ids") != len(tt.want) || span.Tag("rate.calls_saved") != tt.saved {
+			t.Errorf("batchIDs(%v): got tags %v, want %d ids saving %d calls", tt.ids, span.Tags(), len(tt.want), tt.saved)
+		}
+	}
+
+	// without a span
+	if got := batchIDs(context.Background(), "rate", []string{"1", "1"}); len(got) != 1 {
+		t.Errorf("got %v, want 1 id", got)
+	}
+}
+
+func TestNearbyBatchesFanout(t *testing.T) {
+	// geo finds every hotel twice, the cheapest first
+	const n = 20
+	var hotels []fakeHotel
+	for i := 1; i <= n; i++ {
+		hotels = append(hotels, fakeHotel{id: strconv.Itoa(i), rate: float64(i), stars: 3})
+	}
+	hotels = append(hotels, hotels...)
+
+	s, b := newTestServer(hotels...)
+	ctx, span := withMockSpan(context.Background())
+	res, err := s.Nearby(ctx, &pb.NearbyRequest{MinStars: 1})
+	if err != nil {
+		t.Fatalf("Nearby: %v", err)
+	}
+
+	// a fan-out per hotel would call rate and profile once per hotel
+	for _, rpc := range []string{"geo", "rate", "profile"} {
+		if got := b.callCount(rpc); got != 1 {
+			t.Errorf("%s called %d times, want 1 for %d hotels", rpc, got, n)
+		}
+	}
+	for _, rpc := range []string{"rate", "profile"} {
+		if span.Tag(rpc+".batch_ids") != n || span.Tag(rpc+".calls_saved") != n-1 {
+			t.Errorf("got tags %v, want %d %s ids saving %d calls", span.Tags(), n, rpc, n-1)
+		}
+	}
+
+	// ranked by rate, dearest first, each once
+	if len(res.HotelIds) != n {
+		t.Fatalf("got hotels %v, want %d", res.HotelIds, n)
+	}
+	for i, id := range res.HotelIds {
+		if want := strconv.Itoa(n - i); id != want {
+			t.Errorf("hotel %d is %s, want %s", i, id, want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package search
+
+import (
+	"context"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails"
+	errpb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/fanout"
+	profile "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/profile/proto"
+	rate "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/rate/proto"
+	review "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/review/proto"
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/search/proto"
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+// reviewFanout is how many review ratings are fetched at once.
+const reviewFanout = 8
+
+// validateFilters checks the price and star filters of req. Zero values
+// mean no bound.
+func validateFilters(req *pb.NearbyRequest) error {
+	bounds := []struct {
+		field string
+		value float64
+	}{
+		{"minPrice", req.MinPrice},
+		{"maxPrice", req.MaxPrice},
+		{"minStars", float64(req.MinStars)},
+	}
+	for _, b := range bounds {
+		if b.value < 0 {
+			return errdetails.InvalidArgument(errpb.ErrorDetail_OUT_OF_RANGE, b.field, "%s must not be negative, got %v", b.field, b.value)
+		}
+	}
+	if req.MaxPrice > 0 && req.MinPrice > req.MaxPrice {
+		return errdetails.InvalidArgument(errpb.ErrorDetail_OUT_OF_RANGE, "minPrice", "minPrice %v is greater than maxPrice %v", req.MinPrice, req.MaxPrice)
+	}
+	return nil
+}
+
+// filterRatePlans returns the plans whose nightly rate lies within the price
+// bounds of req and whose hotel has at least req.MinStars stars, along with
+// the star ratings of the hotels. Star ratings are only fetched from the
+// profile service if the star filter is set or req sorts by rating, in a
+// single call for all the hotels.
+func (s *Server) filterRatePlans(ctx context.Context, req *pb.NearbyRequest, ratePlans []*rate.RatePlan) ([]*rate.RatePlan, map[string]float32, error) {
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		span.SetTag("search.filter.min_price", req.MinPrice)
+		span.SetTag("search.filter.max_price", req.MaxPrice)
+		span.SetTag("search.filter.min_stars", req.MinStars)
+	}
+
+	var stars map[string]float32
+	if (req.MinStars > 0 || order(req.Sort).has(pb.SortKey_RATING)) && len(ratePlans) > 0 {
+		hotelIds := make([]string, 0, len(ratePlans))
+		for _, plan := range ratePlans {
+			hotelIds = append(hotelIds, plan.HotelId)
+		}
+		profiles, err := s.ProfileClient.GetProfiles(ctx, &profile.Request{HotelIds: batchIDs(ctx, "profile", hotelIds)})
+		if err != nil {
+			return nil, nil, err
+		}
+		stars = starRatings(profiles.Hotels)
+	}
+	return filterPlans(req, ratePlans, stars), stars, nil
+}
+
+// starRatings maps the ids of hotels to their star rating.
+func starRatings(hotels []*profile.Hotel) map[string]float32 {
+	stars := make(map[string]float32, len(hotels))
+	for _, h := range hotels {
+		stars[h.Id] = h.Stars
+	}
+	return stars
+}
+
+// filterPlans returns the plans within the price bounds of req whose hotel
+// has at least req.MinStars stars according to stars.
+func filterPlans(req *pb.NearbyRequest, ratePlans []*rate.RatePlan, stars map[string]float32) []*rate.RatePlan {
+	filtered := make([]*rate.RatePlan, 0, len(ratePlans))
+	for _, plan := range ratePlans {
+		var price float64
+		if plan.RoomType != nil {
+			price = plan.RoomType.TotalRate
+		}
+		if req.MinPrice > 0 && price < req.MinPrice {
+			continue
+		}
+		if req.MaxPrice > 0 && price > req.MaxPrice {
+			continue
+		}
+		if req.MinStars > 0 && stars[plan.HotelId] < req.MinStars {
+			continue
+		}
+		filtered = append(filtered, plan)
+	}
+	return filtered
+}
+
+// unpricedRates stands in for the rates of hotelIds when the rate service
+// timed out or failed, with one plan without a room type per hotel, so that the hotels
+// rank by id.
+func unpricedRates(hotelIds []string) *rate.Result {
+	res := &rate.Result{RatePlans: make([]*rate.RatePlan, 0, len(hotelIds))}
+	for _, id := range hotelIds {
+		res.RatePlans = append(res.RatePlans, &rate.RatePlan{HotelId: id})
+	}
+	return res
+}
+
+// reviewRatings returns the average review ratings of the hotels of
+// ratePlans if o sorts by them, nil otherwise, fetched from the review
+// service at most reviewFanout at once.
+func (s *Server) reviewRatings(ctx context.Context, o order, ratePlans []*rate.RatePlan) (map[string]float32, error) {
+	if !o.has(pb.SortKey_REVIEWS) || len(ratePlans) == 0 {
+		return nil, nil
+	}
+	seen := make(map[string]bool, len(ratePlans))
+	hotelIds := make([]string, 0, len(ratePlans))
+	for _, plan := range ratePlans {
+		if !seen[plan.HotelId] {
+			seen[plan.HotelId] = true
+			hotelIds = append(hotelIds, plan.HotelId)
+		}
+	}
+
+	ratings := make([]float32, len(hotelIds))
+	errs := make([]error, len(hotelIds))
+	fanout.Each(len(hotelIds), reviewFanout, func(i int) {
+		res, err := s.ReviewClient.GetAggregateRating(ctx, &review.Request{HotelId: hotelIds[i]})
+		if err != nil {
+			errs[i] = err
+			return
+		}
+		ratings[i] = res.Average
+	})
+	reviews := make(map[string]float32, len(hotelIds))
+	for i, id := range hotelIds {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		reviews[id] = ratings[i]
+	}
+	return reviews, nil
+}
@@ -0,0 +1,171 @@
+package search
+
+import (
+	"context"
+	"sync"
+
+	geo "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/geo/proto"
+	profile "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/profile/proto"
+	rate "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/rate/proto"
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/search/proto"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// NearbyHotels returns the nearby hotels ordered like Nearby, each joined
+// with its profile and the rate plan it ranks by, sparing clients a call to
+// the rate and profile services per page. Rates and profiles are fetched in
+// parallel, with one batch call each.
+//
+// Results are degraded rather than failed when a single dependency fails:
+// without rates the hotels are listed unpriced and ratesOmitted is set,
+// unless a price filter or sort key needs them, and without profiles the
+// hotels only have their rate and profilesOmitted is set, unless the star
+// filter or a rating sort key needs them. Hotels missing from the rates or
+// profiles returned are dropped, or listed without their profile, as set by
+// MissingData. Results are not cached.
+func (s *Server) NearbyHotels(ctx context.Context, req *pb.NearbyRequest) (*pb.HotelsResult, error) {
+	if err := validateFilters(req); err != nil {
+		return nil, err
+	}
+	if err := validateSort(req); err != nil {
+		return nil, err
+	}
+	ord := order(req.Sort)
+
+	nearby, err := s.GeoClient.Nearby(ctx, &geo.Request{
+		Lat: req.Lat,
+		Lon: req.Lon,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		wg                   sync.WaitGroup
+		rates                *rate.Result
+		profiles             *profile.Result
+		ratesErr, profileErr error
+	)
+	hotelIds := batchIDs(ctx, "rate", nearby.HotelIds)
+	profileIds := batchIDs(ctx, "profile", nearby.HotelIds)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		rates, ratesErr = s.RateClient.GetRates(ctx, &rate.Request{
+			HotelIds: hotelIds,
+			InDate:   req.InDate,
+			OutDate:  req.OutDate,
+		})
+	}()
+	go func() {
+		defer wg.Done()
+		profiles, profileErr = s.ProfileClient.GetProfiles(ctx, &profile.Request{
+			HotelIds: profileIds,
+			Locale:   req.Locale,
+		})
+	}()
+	wg.Wait()
+
+	res := new(pb.HotelsResult)
+	span := opentracing.SpanFromContext(ctx)
+	if ratesErr != nil {
+		if !degradable(ctx, ratesErr) || req.MinPrice > 0 || req.MaxPrice > 0 || ord.has(pb.SortKey_PRICE) {
+			return nil, ratesErr
+		}
+		log.Warn().Msgf("Listing hotels without rates: %v", ratesErr)
+		rates, res.RatesOmitted = unpricedRates(hotelIds), true
+		if span != nil {
+			span.SetTag("search.rates_omitted", true)
+		}
+	}
+	if profileErr != nil {
+		if !degradable(ctx, profileErr) || req.MinStars > 0 || ord.has(pb.SortKey_RATING) {
+			return nil, profileErr
+		}
+		log.Warn().Msgf("Listing hotels without profiles: %v", profileErr)
+		profiles, res.ProfilesOmitted = new(profile.Result), true
+		if span != nil {
+			span.SetTag("search.profiles_omitted", true)
+		}
+	}
+
+	complete := s.completePlans(ctx, hotelIds, rates.RatePlans, profiles.Hotels, res.ProfilesOmitted)
+	stars := starRatings(profiles.Hotels)
+	ratePlans := filterPlans(req, complete, stars)
+	reviews, err := s.reviewRatings(ctx, ord, ratePlans)
+	if err != nil {
+		return nil, err
+	}
+	ranked := rankHotels(ratePlans, ord, nearbyDistances(nearby.HotelIds, nearby.Distances), stars, reviews)
+	page, nextPageToken, err := paginate(ranked, ord, req.Limit, req.PageToken)
+	if err != nil {
+		return nil, err
+	}
+	res.NextPageToken = nextPageToken
+
+	hotels := make(map[string]*profile.Hotel, len(profiles.Hotels))
+	for _, h := range profiles.Hotels {
+		hotels[h.Id] = h
+	}
+	for _, h := range page {
+		res.Hotels = append(res.Hotels, hotelSummary(h, hotels[h.id], rates.Currency))
+	}
+	return res, nil
+}
+
+// StreamHotels streams the hotels NearbyHotels returns for req one message
+// each, so that clients can show the first ones while the others are sent.
+// Hotels are streamed without their data for a failed dependency, like
+// NearbyHotels returns them, and sending stops when the client goes away.
+func (s *Server) StreamHotels(req *pb.NearbyRequest, stream pb.Search_StreamHotelsServer) error {
+	ctx := stream.Context()
+	res, err := s.NearbyHotels(ctx, req)
+	if err != nil {
+		return err
+	}
+	for _, h := range res.Hotels {
+		if err := stream.Send(h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// degradable reports whether a dependency failing with err still leaves a
+// result worth returning without its data: the caller is still waiting for
+// it, and the request itself was not at fault.
+func degradable(ctx context.Context, err error) bool {
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.OutOfRange, codes.Canceled:
+		return false
+	}
+	return ctx.Err() == nil
+}
+
+// hotelSummary joins the ranked hotel h with its profile p, nil if it has
+// none, and its rate plan priced in currency.
+func hotelSummary(h rankedHotel, p *profile.Hotel, currency string) *pb.HotelSummary {
+	summary := &pb.HotelSummary{Id: h.id}
+	if p != nil {
+		summary.Name = p.Name
+		summary.PhoneNumber = p.PhoneNumber
+		summary.Description = p.Description
+		summary.Stars = p.Stars
+		if p.Address != nil {
+			summary.City = p.Address.City
+			summary.Lat = p.Address.Lat
+			summary.Lon = p.Address.Lon
+		}
+	}
+	if h.plan != nil && h.plan.RoomType != nil {
+		summary.RateCode = h.plan.Code
+		summary.RoomType = h.plan.RoomType.Id
+		summary.TotalRate = h.plan.RoomType.TotalRate
+		summary.StayTotal = h.plan.StayTotal
+		summary.Currency = currency
+	}
+	return summary
+}
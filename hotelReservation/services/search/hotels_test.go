@@ -0,0 +1,155 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	profile "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/profile/proto"
+	rate "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/rate/proto"
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/search/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// failingRate is a rate service failing with err.
+type failingRate struct {
+	rate.RateClient
+	err error
+}
+
+func (r failingRate) GetRates(ctx context.Context, req *rate.Request, opts ...grpc.CallOption) (*rate.Result, error) {
+	return nil, r.err
+}
+
+// failingProfile is a profile service failing with err.
+type failingProfile struct {
+	profile.ProfileClient
+	err error
+}
+
+func (p failingProfile) GetProfiles(ctx context.Context, req *profile.Request, opts ...grpc.CallOption) (*profile.Result, error) {
+	return nil, p.err
+}
+
+// summaryIds returns the ids of hotels.
+func summaryIds(hotels []*pb.HotelSummary) []string {
+	ids := make([]string, len(hotels))
+	for i, h := range hotels {
+		ids[i] = h.Id
+	}
+	return ids
+}
+
+func TestNearbyHotels(t *testing.T) {
+	s, b := newTestServer(filterHotels...)
+	res, err := s.NearbyHotels(context.Background(), &pb.NearbyRequest{MinPrice: 100})
+	if err != nil {
+		t.Fatalf("NearbyHotels: %v", err)
+	}
+	if res.RatesOmitted || res.ProfilesOmitted {
+		t.Errorf("got a degraded result %v", res)
+	}
+	if got := fmt.Sprint(summaryIds(res.Hotels)); got != "[5 4 3 2]" {
+		t.Errorf("got hotels %s, want [5 4 3 2]", got)
+	}
+	for _, h := range res.Hotels {
+		want, _ := b.hotel(h.Id)
+		if h.Name != "Hotel "+h.Id || h.Stars != want.stars || h.TotalRate != want.rate || h.RateCode != "RACK" {
+			t.Errorf("got %v, want hotel %s joined with its profile and rate", h, h.Id)
+		}
+	}
+	// one call each, whatever the number of hotels
+	for _, rpc := range []string{"geo", "rate", "profile"} {
+		if n := b.callCount(rpc); n != 1 {
+			t.Errorf("%s called %d times, want once", rpc, n)
+		}
+	}
+}
+
+func TestNearbyHotelsPartialFailure(t *testing.T) {
+	down := status.Error(codes.Unavailable, "down")
+	tests := []struct {
+		name                          string
+		rateErr, profileErr           error
+		req                           *pb.NearbyRequest
+		want                          string
+		ratesOmitted, profilesOmitted bool
+	}{
+		{"without profiles", nil, down, &pb.NearbyRequest{}, "[5 4 3 2 1]", false, true},
+		{"without rates", down, nil, &pb.NearbyRequest{}, "[1 2 3 4 5]", true, false},
+		{"without either", down, down, &pb.NearbyRequest{}, "[1 2 3 4 5]", true, true},
+		{"without rates, filtering by stars", down, nil, &pb.NearbyRequest{MinStars: 4}, "[4 5]", true, false},
+	}
+	for _, tt := range tests {
+		s, b := newTestServer(filterHotels...)
+		if tt.rateErr != nil {
+			s.RateClient = failingRate{err: tt.rateErr}
+		}
+		if tt.profileErr != nil {
+			s.ProfileClient = failingProfile{err: tt.profileErr}
+		}
+		ctx, span := withMockSpan(context.Background())
+		res, err := s.NearbyHotels(ctx, tt.req)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := fmt.Sprint(summaryIds(res.Hotels)); got != tt.want {
+			t.Errorf("%s: got hotels %s, want %s", tt.name, got, tt.want)
+		}
+		if res.RatesOmitted != tt.ratesOmitted || res.ProfilesOmitted != tt.profilesOmitted {
+			t.Errorf("%s: got rates omitted %v and profiles omitted %v, want %v and %v", tt.name, res.RatesOmitted, res.ProfilesOmitted, tt.ratesOmitted, tt.profilesOmitted)
+		}
+		if (span.Tag("search.rates_omitted") == true) != tt.ratesOmitted || (span.Tag("search.profiles_omitted") == true) != tt.profilesOmitted {
+			t.Errorf("%s: got tags %v", tt.name, span.Tags())
+		}
+		for _, h := range res.Hotels {
+			want, _ := b.hotel(h.Id)
+			if hasRate := h.TotalRate == want.rate; hasRate == tt.ratesOmitted {
+				t.Errorf("%s: got %v, want the rate unless omitted", tt.name, h)
+			}
+			if hasProfile := h.Name != ""; hasProfile == tt.profilesOmitted {
+				t.Errorf("%s: got %v, want the profile unless omitted", tt.name, h)
+			}
+		}
+	}
+}
+
+func TestNearbyHotelsFailing(t *testing.T) {
+	down := status.Error(codes.Unavailable, "down")
+	tests := []struct {
+		name                string
+		rateErr, profileErr error
+		req                 *pb.NearbyRequest
+		want                codes.Code
+	}{
+		{"rates needed for a price filter", down, nil, &pb.NearbyRequest{MaxPrice: 100}, codes.Unavailable},
+		{"rates needed to sort by price", down, nil, &pb.NearbyRequest{Sort: []*pb.SortKey{{Field: pb.SortKey_PRICE}}}, codes.Unavailable},
+		{"profiles needed for a star filter", nil, down, &pb.NearbyRequest{MinStars: 3}, codes.Unavailable},
+		{"profiles needed to sort by rating", nil, down, &pb.NearbyRequest{Sort: []*pb.SortKey{{Field: pb.SortKey_RATING}}}, codes.Unavailable},
+		{"request at fault", status.Error(codes.InvalidArgument, "bad dates"), nil, &pb.NearbyRequest{}, codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		s, _ := newTestServer(filterHotels...)
+		if tt.rateErr != nil {
+			s.RateClient = failingRate{err: tt.rateErr}
+		}
+		if tt.profileErr != nil {
+			s.ProfileClient = failingProfile{err: tt.profileErr}
+		}
+		if _, err := s.NearbyHotels(context.Background(), tt.req); status.Code(err) != tt.want {
+			t.Errorf("%s: got %v, want %s", tt.name, err, tt.want)
+		}
+	}
+
+	// the caller going away
+	s, _ := newTestServer(filterHotels...)
+	s.RateClient = failingRate{err: status.Error(codes.Canceled, "canceled")}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.NearbyHotels(ctx, &pb.NearbyRequest{}); status.Code(err) != codes.Canceled {
+		t.Errorf("caller gone: got %v, want Canceled", err)
+	}
+}
@@ -0,0 +1,66 @@
+package search
+
+import (
+	"context"
+
+	profile "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/profile/proto"
+	rate "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/rate/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+// Handling of the nearby hotels geo knows of but profile doesn't, e.g. after
+// a partial seeding.
+const (
+	// DropMissing leaves them out of the results.
+	DropMissing = "drop"
+	// IncludeMissing lists them with their rate only.
+	IncludeMissing = "partial"
+)
+
+// completePlans returns the plans of the hotels of hotelIds that have all
+// the data NearbyHotels joins. Hotels without rates are always dropped,
+// since they can't be ranked, and hotels without a profile are dropped
+// unless s.MissingData is IncludeMissing or profiles were omitted. Each is
+// logged with the dependency that lacked its data, and the span in ctx is
+// tagged with the number dropped as search.dropped_count.
+func (s *Server) completePlans(ctx context.Context, hotelIds []string, plans []*rate.RatePlan, profiles []*profile.Hotel, profilesOmitted bool) []*rate.RatePlan {
+	priced := make(map[string]bool, len(plans))
+	for _, plan := range plans {
+		priced[plan.HotelId] = true
+	}
+	profiled := make(map[string]bool, len(profiles))
+	for _, h := range profiles {
+		profiled[h.Id] = true
+	}
+
+	logger := tracing.Logger(ctx)
+	dropped := make(map[string]bool)
+	for _, id := range hotelIds {
+		switch {
+		case !priced[id]:
+			logger.Warn().Msgf("Dropping hotel %s from the results: rate has no rates for it", id)
+			dropped[id] = true
+		case profilesOmitted || profiled[id]:
+		case s.MissingData == IncludeMissing:
+			logger.Warn().Msgf("Listing hotel %s without its profile: profile has none", id)
+		default:
+			logger.Warn().Msgf("Dropping hotel %s from the results: profile has none", id)
+			dropped[id] = true
+		}
+	}
+
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		span.SetTag("search.dropped_count", len(dropped))
+	}
+	if len(dropped) == 0 {
+		return plans
+	}
+	complete := make([]*rate.RatePlan, 0, len(plans))
+	for _, plan := range plans {
+		if !dropped[plan.HotelId] {
+			complete = append(complete, plan)
+		}
+	}
+	return complete
+}
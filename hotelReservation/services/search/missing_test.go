@@ -0,0 +1,144 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	profile "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/profile/proto"
+	rate "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/rate/proto"
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/search/proto"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+)
+
+// missingRate is rate without the rates of the hotels of missing.
+type missingRate struct {
+	fakeRate
+	missing map[string]bool
+}
+
+func (r missingRate) GetRates(ctx context.Context, req *rate.Request, opts ...grpc.CallOption) (*rate.Result, error) {
+	res, err := r.fakeRate.GetRates(ctx, req, opts...)
+	if err != nil {
+		return nil, err
+	}
+	plans := res.RatePlans[:0]
+	for _, plan := range res.RatePlans {
+		if !r.missing[plan.HotelId] {
+			plans = append(plans, plan)
+		}
+	}
+	res.RatePlans = plans
+	return res, nil
+}
+
+// missingProfile is profile without the profiles of the hotels of missing.
+type missingProfile struct {
+	fakeProfile
+	missing map[string]bool
+}
+
+func (p missingProfile) GetProfiles(ctx context.Context, req *profile.Request, opts ...grpc.CallOption) (*profile.Result, error) {
+	res, err := p.fakeProfile.GetProfiles(ctx, req, opts...)
+	if err != nil {
+		return nil, err
+	}
+	hotels := res.Hotels[:0]
+	for _, h := range res.Hotels {
+		if !p.missing[h.Id] {
+			hotels = append(hotels, h)
+		}
+	}
+	res.Hotels = hotels
+	return res, nil
+}
+
+// newInconsistentServer returns a search server handling missing data as
+// missingData, knowing of filterHotels but of neither the profile of hotel 2
+// nor the rates of hotel 4, as after a partial seeding.
+func newInconsistentServer(missingData string) *Server {
+	s, b := newTestServer(filterHotels...)
+	s.RateClient = missingRate{fakeRate{backends: b}, map[string]bool{"4": true}}
+	s.ProfileClient = missingProfile{fakeProfile{backends: b}, map[string]bool{"2": true}}
+	s.MissingData = missingData
+	return s
+}
+
+// captureLog returns the buffer the global logger writes warnings and above
+// to for the duration of t.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	logger, globalLevel := log.Logger, zerolog.GlobalLevel()
+	t.Cleanup(func() {
+		log.Logger = logger
+		zerolog.SetGlobalLevel(globalLevel)
+	})
+	buf := new(bytes.Buffer)
+	log.Logger = zerolog.New(buf)
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+	return buf
+}
+
+func TestNearbyHotelsMissingData(t *testing.T) {
+	tests := []struct {
+		missingData string
+		want        string
+		dropped     int
+		logged      []string
+	}{
+		{DropMissing, "[5 3 1]", 2, []string{
+			"Dropping hotel 4 from the results: rate has no rates for it",
+			"Dropping hotel 2 from the results: profile has none",
+		}},
+		// the default
+		{"", "[5 3 1]", 2, nil},
+		{IncludeMissing, "[5 3 2 1]", 1, []string{
+			"Dropping hotel 4 from the results: rate has no rates for it",
+			"Listing hotel 2 without its profile: profile has none",
+		}},
+	}
+	for _, tt := range tests {
+		logs := captureLog(t)
+		ctx, span := withMockSpan(context.Background())
+		res, err := newInconsistentServer(tt.missingData).NearbyHotels(ctx, &pb.NearbyRequest{})
+		if err != nil {
+			t.Fatalf("%q: NearbyHotels: %v", tt.missingData, err)
+		}
+		if got := fmt.Sprint(summaryIds(res.Hotels)); got != tt.want {
+			t.Errorf("%q: got hotels %s, want %s", tt.missingData, got, tt.want)
+		}
+		if got := span.Tag("search.dropped_count"); got != tt.dropped {
+			t.Errorf("%q: got search.dropped_count=%v, want %d", tt.missingData, got, tt.dropped)
+		}
+		for _, msg := range tt.logged {
+			if !strings.Contains(logs.String(), msg) {
+				t.Errorf("%q: %q not logged in %s", tt.missingData, msg, logs)
+			}
+		}
+		for _, h := range res.Hotels {
+			if h.Id == "2" && (h.Name != "" || h.TotalRate != 100) {
+				t.Errorf("%q: got hotel 2 as %v, want its rate without a profile", tt.missingData, h)
+			}
+		}
+	}
+}
+
+func TestNearbyHotelsConsistentData(t *testing.T) {
+	s, _ := newTestServer(filterHotels...)
+	s.MissingData = DropMissing
+	logs := captureLog(t)
+	ctx, span := withMockSpan(context.Background())
+	if _, err := s.NearbyHotels(ctx, &pb.NearbyRequest{}); err != nil {
+		t.Fatalf("NearbyHotels: %v", err)
+	}
+	if got := span.Tag("search.dropped_count"); got != 0 {
+		t.Errorf("got search.dropped_count=%v, want 0", got)
+	}
+	if logs.Len() != 0 {
+		t.Errorf("got warnings %s, want none", logs)
+	}
+}
@@ -0,0 +1,118 @@
+package search
+
+import (
+	"encoding/base64"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails"
+	errpb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails/proto"
+	rate "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/rate/proto"
+)
+
+// rankedHotel is a hotel with the values it is ordered by in search results.
+type rankedHotel struct {
+	id       string
+	rate     float64
+	distance float64
+	stars    float64
+	reviews  float64
+	// plan is the plan the hotel ranks by, nil if decoded from a page token.
+	plan *rate.RatePlan
+}
+
+// rankHotels returns the hotels of ratePlans ordered by o, each hotel ranked
+// by its plan ordered first, at the distance and with the star and review
+// ratings it has in distances, stars and reviews.
+func rankHotels(ratePlans []*rate.RatePlan, o order, distances, stars, reviews map[string]float32) []rankedHotel {
+	best := make(map[string]rankedHotel, len(ratePlans))
+	for _, plan := range ratePlans {
+		h := rankedHotel{
+			id:       plan.HotelId,
+			distance: float64(distances[plan.HotelId]),
+			stars:    float64(stars[plan.HotelId]),
+			reviews:  float64(reviews[plan.HotelId]),
+			plan:     plan,
+		}
+		if plan.RoomType != nil {
+			h.rate = plan.RoomType.TotalRate
+		}
+		if cur, ok := best[h.id]; !ok || o.before(h, cur) {
+			best[h.id] = h
+		}
+	}
+
+	hotels := make([]rankedHotel, 0, len(best))
+	for _, h := range best {
+		hotels = append(hotels, h)
+	}
+	sort.Slice(hotels, func(i, j int) bool { return o.before(hotels[i], hotels[j]) })
+	return hotels
+}
+
+// encodePageToken returns an opaque token for the position right after h.
+func encodePageToken(h rankedHotel) string {
+	raw := strings.Join([]string{
+		strconv.FormatFloat(h.rate, 'g', -1, 64),
+		strconv.FormatFloat(h.distance, 'g', -1, 64),
+		strconv.FormatFloat(h.stars, 'g', -1, 64),
+		strconv.FormatFloat(h.reviews, 'g', -1, 64),
+		h.id,
+	}, "|")
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodePageToken(token string) (rankedHotel, error) {
+	malformed := errdetails.InvalidArgument(errpb.ErrorDetail_MALFORMED, "pageToken", "malformed page token")
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return rankedHotel{}, malformed
+	}
+	parts := strings.SplitN(string(raw), "|", 5)
+	switch len(parts) {
+	case 2:
+		// issued before sort keys, with the rate and id only
+		parts = []string{parts[0], "0", "0", "0", parts[1]}
+	case 4:
+		// issued before the review sort key
+		parts = []string{parts[0], parts[1], parts[2], "0", parts[3]}
+	}
+	if len(parts) != 5 {
+		return rankedHotel{}, malformed
+	}
+	var values [4]float64
+	for i := range values {
+		if values[i], err = strconv.ParseFloat(parts[i], 64); err != nil {
+			return rankedHotel{}, malformed
+		}
+	}
+	return rankedHotel{id: parts[4], rate: values[0], distance: values[1], stars: values[2], reviews: values[3]}, nil
+}
+
+// paginate returns the page of hotels, ordered by o, following the position
+// encoded in token, holding at most limit hotels (all of them if limit is
+// 0), and the token of the next page. Since the token encodes a sort key
+// rather than an offset, hotels appearing or disappearing before it don't
+// shift the page.
+func paginate(hotels []rankedHotel, o order, limit int32, token string) ([]rankedHotel, string, error) {
+	if limit < 0 {
+		return nil, "", errdetails.InvalidArgument(errpb.ErrorDetail_OUT_OF_RANGE, "limit", "limit must not be negative, got %d", limit)
+	}
+
+	start := 0
+	if token != "" {
+		after, err := decodePageToken(token)
+		if err != nil {
+			return nil, "", err
+		}
+		start = sort.Search(len(hotels), func(i int) bool { return o.before(after, hotels[i]) })
+	}
+
+	hotels = hotels[start:]
+	if limit == 0 || int(limit) >= len(hotels) {
+		return hotels, "", nil
+	}
+	page := hotels[:limit]
+	return page, encodePageToken(page[len(page)-1]), nil
+}
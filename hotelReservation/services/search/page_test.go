@@ -0,0 +1,137 @@
+package search
+
+import (
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	rate "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/rate/proto"
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/search/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// plans returns one plan per hotel, hotel i at rates[i] and i km away.
+func plans(rates ...float64) ([]*rate.RatePlan, map[string]float32) {
+	ratePlans := make([]*rate.RatePlan, 0, len(rates))
+	distances := make(map[string]float32, len(rates))
+	for i, r := range rates {
+		id := fmt.Sprintf("%d", i+1)
+		ratePlans = append(ratePlans, &rate.RatePlan{HotelId: id, RoomType: &rate.RoomType{TotalRate: r}})
+		distances[id] = float32(i)
+	}
+	return ratePlans, distances
+}
+
+// pageAll pages through hotels limit at a time, returning the ids of every
+// page in order.
+func pageAll(t *testing.T, hotels []rankedHotel, o order, limit int32) []string {
+	t.Helper()
+	var ids []string
+	token := ""
+	for pages := 0; ; pages++ {
+		if pages > len(hotels) {
+			t.Fatalf("paging didn't end after %d pages", pages)
+		}
+		page, next, err := paginate(hotels, o, limit, token)
+		if err != nil {
+			t.Fatalf("paginate: %v", err)
+		}
+		if next != "" && len(page) != int(limit) {
+			t.Errorf("got a short page of %d hotels before the last one", len(page))
+		}
+		for _, h := range page {
+			ids = append(ids, h.id)
+		}
+		if next == "" {
+			return ids
+		}
+		token = next
+	}
+}
+
+func TestPaginateNoDuplicatesOrGaps(t *testing.T) {
+	// ties on the rate are broken by id
+	ratePlans, distances := plans(120, 80, 120, 95, 80, 150, 60, 120, 95, 110, 80)
+	orders := map[string]order{
+		"default":  nil,
+		"price":    {{Field: pb.SortKey_PRICE}},
+		"distance": {{Field: pb.SortKey_DISTANCE, Descending: true}},
+	}
+	for name, o := range orders {
+		hotels := rankHotels(ratePlans, o, distances, nil, nil)
+		var want []string
+		for _, h := range hotels {
+			want = append(want, h.id)
+		}
+		for _, limit := range []int32{1, 2, 3, 4, 10, 11, 20} {
+			got := pageAll(t, hotels, o, limit)
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("%s order, limit %d: paged through %v, want %v", name, limit, got, want)
+			}
+		}
+	}
+}
+
+func TestPaginateAllWithoutLimit(t *testing.T) {
+	ratePlans, distances := plans(100, 90, 80)
+	hotels := rankHotels(ratePlans, nil, distances, nil, nil)
+	page, next, err := paginate(hotels, nil, 0, "")
+	if err != nil {
+		t.Fatalf("paginate: %v", err)
+	}
+	if len(page) != len(hotels) || next != "" {
+		t.Errorf("got %d hotels and token %q, want all %d and no token", len(page), next, len(hotels))
+	}
+}
+
+func TestPaginateStableWhenHotelsChange(t *testing.T) {
+	ratePlans, distances := plans(100, 90, 80, 70, 60)
+	hotels := rankHotels(ratePlans, nil, distances, nil, nil)
+	first, token, err := paginate(hotels, nil, 2, "")
+	if err != nil {
+		t.Fatalf("paginate: %v", err)
+	}
+
+	// the first hotel of the first page is gone by the second one
+	second, _, err := paginate(hotels[1:], nil, 2, token)
+	if err != nil {
+		t.Fatalf("paginate: %v", err)
+	}
+	if second[0].id == first[1].id || second[0].id != hotels[2].id {
+		t.Errorf("second page starts at hotel %s, want %s", second[0].id, hotels[2].id)
+	}
+}
+
+func TestDecodeLegacyPageTokens(t *testing.T) {
+	for _, raw := range []string{"95.5|7", "95.5|0|0|7", "95.5|0|0|0|7"} {
+		token := base64.RawURLEncoding.EncodeToString([]byte(raw))
+		h, err := decodePageToken(token)
+		if err != nil {
+			t.Errorf("decodePageToken(%q): %v", raw, err)
+			continue
+		}
+		if h.id != "7" || h.rate != 95.5 {
+			t.Errorf("decodePageToken(%q) = hotel %s at %v, want hotel 7 at 95.5", raw, h.id, h.rate)
+		}
+	}
+}
+
+func TestPaginateInvalid(t *testing.T) {
+	ratePlans, distances := plans(100, 90)
+	hotels := rankHotels(ratePlans, nil, distances, nil, nil)
+	tests := map[string]struct {
+		limit int32
+		token string
+	}{
+		"negative limit": {-1, ""},
+		"not base64":     {1, "!!"},
+		"too few parts":  {1, base64.RawURLEncoding.EncodeToString([]byte("7"))},
+		"not a number":   {1, base64.RawURLEncoding.EncodeToString([]byte("cheap|7"))},
+	}
+	for name, tt := range tests {
+		if _, _, err := paginate(hotels, nil, tt.limit, tt.token); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%s: got %v, want InvalidArgument", name, err)
+		}
+	}
+}
@@ -20,6 +20,65 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type SortKey_Field int32
+
+const (
+	SortKey_FIELD_UNSPECIFIED SortKey_Field = 0
+	// Distance from the requested lat/lon.
+	SortKey_DISTANCE SortKey_Field = 1
+	// Nightly rate of the rate plan the hotel ranks by.
+	SortKey_PRICE SortKey_Field = 2
+	// Star rating, from the profile service.
+	SortKey_RATING SortKey_Field = 3
+	// Average review rating, from the review service.
+	SortKey_REVIEWS SortKey_Field = 4
+)
+
+// Enum value maps for SortKey_Field.
+var (
+	SortKey_Field_name = map[int32]string{
+		0: "FIELD_UNSPECIFIED",
+		1: "DISTANCE",
+		2: "PRICE",
+		3: "RATING",
+		4: "REVIEWS",
+	}
+	SortKey_Field_value = map[string]int32{
+		"FIELD_UNSPECIFIED": 0,
+		"DISTANCE":          1,
+		"PRICE":             2,
+		"RATING":            3,
+		"REVIEWS":           4,
+	}
+)
+
+func (x SortKey_Field) Enum() *SortKey_Field {
+	p := new(SortKey_Field)
+	*p = x
+	return p
+}
+
+func (x SortKey_Field) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (SortKey_Field) Descriptor() protoreflect.EnumDescriptor {
+	return file_services_search_proto_search_proto_enumTypes[0].Descriptor()
+}
+
+func (SortKey_Field) Type() protoreflect.EnumType {
+	return &file_services_search_proto_search_proto_enumTypes[0]
+}
+
+func (x SortKey_Field) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use SortKey_Field.Descriptor instead.
+func (SortKey_Field) EnumDescriptor() ([]byte, []int) {
+	return file_services_search_proto_search_proto_rawDescGZIP(), []int{1, 0}
+}
+
 type NearbyRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -29,6 +88,22 @@ type NearbyRequest struct {
 	Lon     float32 `protobuf:"fixed32,2,opt,name=lon,proto3" json:"lon,omitempty"`
 	InDate  string  `protobuf:"bytes,3,opt,name=inDate,proto3" json:"inDate,omitempty"`
 	OutDate string  `protobuf:"bytes,4,opt,name=outDate,proto3" json:"outDate,omitempty"`
+	// Maximum number of hotels to return, 0 for all.
+	Limit int32 `protobuf:"varint,5,opt,name=limit,proto3" json:"limit,omitempty"`
+	// Token from a previous SearchResult to resume from, empty for the first
+	// page.
+	PageToken string `protobuf:"bytes,6,opt,name=pageToken,proto3" json:"pageToken,omitempty"`
+	// Bounds of the nightly rate, 0 for no bound.
+	MinPrice float64 `protobuf:"fixed64,7,opt,name=minPrice,proto3" json:"minPrice,omitempty"`
+	MaxPrice float64 `protobuf:"fixed64,8,opt,name=maxPrice,proto3" json:"maxPrice,omitempty"`
+	// Minimum star rating, 0 for no bound.
+	MinStars float32 `protobuf:"fixed32,9,opt,name=minStars,proto3" json:"minStars,omitempty"`
+	// Locale of the profiles returned by NearbyHotels.
+	Locale string `protobuf:"bytes,10,opt,name=locale,proto3" json:"locale,omitempty"`
+	// Keys to order the hotels by, the first one first, ties broken by the
+	// next ones and finally by the default order: descending rate, then hotel
+	// id. Each field can be used once. Empty for the default order.
+	Sort []*SortKey `protobuf:"bytes,11,rep,name=sort,proto3" json:"sort,omitempty"`
 }
 
 func (x *NearbyRequest) Reset() {
@@ -91,18 +166,129 @@ func (x *NearbyRequest) GetOutDate() string {
 	return ""
 }
 
+func (x *NearbyRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *NearbyRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+func (x *NearbyRequest) GetMinPrice() float64 {
+	if x != nil {
+		return x.MinPrice
+	}
+	return 0
+}
+
+func (x *NearbyRequest) GetMaxPrice() float64 {
+	if x != nil {
+		return x.MaxPrice
+	}
+	return 0
+}
+
+func (x *NearbyRequest) GetMinStars() float32 {
+	if x != nil {
+		return x.MinStars
+	}
+	return 0
+}
+
+func (x *NearbyRequest) GetLocale() string {
+	if x != nil {
+		return x.Locale
+	}
+	return ""
+}
+
+func (x *NearbyRequest) GetSort() []*SortKey {
+	if x != nil {
+		return x.Sort
+	}
+	return nil
+}
+
+// SortKey orders search results by one field.
+type SortKey struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Field SortKey_Field `protobuf:"varint,1,opt,name=field,proto3,enum=search.SortKey_Field" json:"field,omitempty"`
+	// Orders by descending values, ascending if unset.
+	Descending bool `protobuf:"varint,2,opt,name=descending,proto3" json:"descending,omitempty"`
+}
+
+func (x *SortKey) Reset() {
+	*x = SortKey{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_services_search_proto_search_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SortKey) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SortKey) ProtoMessage() {}
+
+func (x *SortKey) ProtoReflect() protoreflect.Message {
+	mi := &file_services_search_proto_search_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SortKey.ProtoReflect.Descriptor instead.
+func (*SortKey) Descriptor() ([]byte, []int) {
+	return file_services_search_proto_search_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SortKey) GetField() SortKey_Field {
+	if x != nil {
+		return x.Field
+	}
+	return SortKey_FIELD_UNSPECIFIED
+}
+
+func (x *SortKey) GetDescending() bool {
+	if x != nil {
+		return x.Descending
+	}
+	return false
+}
+
 type SearchResult struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
 	HotelIds []string `protobuf:"bytes,1,rep,name=hotelIds,proto3" json:"hotelIds,omitempty"`
+	// Token for the next page, empty if there are no more results.
+	NextPageToken string `protobuf:"bytes,2,opt,name=nextPageToken,proto3" json:"nextPageToken,omitempty"`
+	// Set if the rate service timed out: the hotels were then neither priced
+	// nor filtered by price, and are ordered by id.
+	RatesOmitted bool `protobuf:"varint,3,opt,name=ratesOmitted,proto3" json:"ratesOmitted,omitempty"`
 }
 
 func (x *SearchResult) Reset() {
 	*x = SearchResult{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_services_search_proto_search_proto_msgTypes[1]
+		mi := &file_services_search_proto_search_proto_msgTypes[2]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -115,7 +301,7 @@ func (x *SearchResult) String() string {
 func (*SearchResult) ProtoMessage() {}
 
 func (x *SearchResult) ProtoReflect() protoreflect.Message {
-	mi := &file_services_search_proto_search_proto_msgTypes[1]
+	mi := &file_services_search_proto_search_proto_msgTypes[2]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -128,7 +314,7 @@ func (x *SearchResult) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SearchResult.ProtoReflect.Descriptor instead.
 func (*SearchResult) Descriptor() ([]byte, []int) {
-	return file_services_search_proto_search_proto_rawDescGZIP(), []int{1}
+	return file_services_search_proto_search_proto_rawDescGZIP(), []int{2}
 }
 
 func (x *SearchResult) GetHotelIds() []string {
@@ -138,30 +324,337 @@ func (x *SearchResult) GetHotelIds() []string {
 	return nil
 }
 
+func (x *SearchResult) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+func (x *SearchResult) GetRatesOmitted() bool {
+	if x != nil {
+		return x.RatesOmitted
+	}
+	return false
+}
+
+// HotelSummary is a hotel found by NearbyHotels, with its profile and the
+// rate plan it ranks by.
+type HotelSummary struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// From the profile service, unset if profilesOmitted.
+	Name        string  `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	PhoneNumber string  `protobuf:"bytes,3,opt,name=phoneNumber,proto3" json:"phoneNumber,omitempty"`
+	Description string  `protobuf:"bytes,4,opt,name=description,proto3" json:"description,omitempty"`
+	City        string  `protobuf:"bytes,5,opt,name=city,proto3" json:"city,omitempty"`
+	Lat         float32 `protobuf:"fixed32,6,opt,name=lat,proto3" json:"lat,omitempty"`
+	Lon         float32 `protobuf:"fixed32,7,opt,name=lon,proto3" json:"lon,omitempty"`
+	Stars       float32 `protobuf:"fixed32,8,opt,name=stars,proto3" json:"stars,omitempty"`
+	// From the rate service, unset if ratesOmitted.
+	RateCode string `protobuf:"bytes,9,opt,name=rateCode,proto3" json:"rateCode,omitempty"`
+	RoomType string `protobuf:"bytes,10,opt,name=roomType,proto3" json:"roomType,omitempty"`
+	// Nightly rate and total over the stay.
+	TotalRate float64 `protobuf:"fixed64,11,opt,name=totalRate,proto3" json:"totalRate,omitempty"`
+	StayTotal float64 `protobuf:"fixed64,12,opt,name=stayTotal,proto3" json:"stayTotal,omitempty"`
+	Currency  string  `protobuf:"bytes,13,opt,name=currency,proto3" json:"currency,omitempty"`
+}
+
+func (x *HotelSummary) Reset() {
+	*x = HotelSummary{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_services_search_proto_search_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *HotelSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HotelSummary) ProtoMessage() {}
+
+func (x *HotelSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_services_search_proto_search_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HotelSummary.ProtoReflect.Descriptor instead.
+func (*HotelSummary) Descriptor() ([]byte, []int) {
+	return file_services_search_proto_search_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *HotelSummary) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *HotelSummary) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *HotelSummary) GetPhoneNumber() string {
+	if x != nil {
+		return x.PhoneNumber
+	}
+	return ""
+}
+
+func (x *HotelSummary) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *HotelSummary) GetCity() string {
+	if x != nil {
+		return x.City
+	}
+	return ""
+}
+
+func (x *HotelSummary) GetLat() float32 {
+	if x != nil {
+		return x.Lat
+	}
+	return 0
+}
+
+func (x *HotelSummary) GetLon() float32 {
+	if x != nil {
+		return x.Lon
+	}
+	return 0
+}
+
+func (x *HotelSummary) GetStars() float32 {
+	if x != nil {
+		return x.Stars
+	}
+	return 0
+}
+
+func (x *HotelSummary) GetRateCode() string {
+	if x != nil {
+		return x.RateCode
+	}
+	return ""
+}
+
+func (x *HotelSummary) GetRoomType() string {
+	if x != nil {
+		return x.RoomType
+	}
+	return ""
+}
+
+func (x *HotelSummary) GetTotalRate() float64 {
+	if x != nil {
+		return x.TotalRate
+	}
+	return 0
+}
+
+func (x *HotelSummary) GetStayTotal() float64 {
+	if x != nil {
+		return x.StayTotal
+	}
+	return 0
+}
+
+func (x *HotelSummary) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+type HotelsResult struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Hotels []*HotelSummary `protobuf:"bytes,1,rep,name=hotels,proto3" json:"hotels,omitempty"`
+	// Token for the next page, empty if there are no more results.
+	NextPageToken string `protobuf:"bytes,2,opt,name=nextPageToken,proto3" json:"nextPageToken,omitempty"`
+	// Set if the rate service failed: the hotels were then neither priced nor
+	// filtered by price, and are ordered by id.
+	RatesOmitted bool `protobuf:"varint,3,opt,name=ratesOmitted,proto3" json:"ratesOmitted,omitempty"`
+	// Set if the profile service failed: the hotels then only have their id
+	// and rate.
+	ProfilesOmitted bool `protobuf:"varint,4,opt,name=profilesOmitted,proto3" json:"profilesOmitted,omitempty"`
+}
+
+func (x *HotelsResult) Reset() {
+	*x = HotelsResult{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_services_search_proto_search_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *HotelsResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HotelsResult) ProtoMessage() {}
+
+func (x *HotelsResult) ProtoReflect() protoreflect.Message {
+	mi := &file_services_search_proto_search_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HotelsResult.ProtoReflect.Descriptor instead.
+func (*HotelsResult) Descriptor() ([]byte, []int) {
+	return file_services_search_proto_search_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *HotelsResult) GetHotels() []*HotelSummary {
+	if x != nil {
+		return x.Hotels
+	}
+	return nil
+}
+
+func (x *HotelsResult) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+func (x *HotelsResult) GetRatesOmitted() bool {
+	if x != nil {
+		return x.RatesOmitted
+	}
+	return false
+}
+
+func (x *HotelsResult) GetProfilesOmitted() bool {
+	if x != nil {
+		return x.ProfilesOmitted
+	}
+	return false
+}
+
 var File_services_search_proto_search_proto protoreflect.FileDescriptor
 
 var file_services_search_proto_search_proto_rawDesc = []byte{
 	0x0a, 0x22, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x2f, 0x73, 0x65, 0x61, 0x72, 0x63,
 	0x68, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x73, 0x65, 0x61, 0x72, 0x63, 0x68, 0x2e, 0x70,
-	0x72, 0x6f, 0x74, 0x6f, 0x12, 0x06, 0x73, 0x65, 0x61, 0x72, 0x63, 0x68, 0x22, 0x65, 0x0a, 0x0d,
-	0x4e, 0x65, 0x61, 0x72, 0x62, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x10, 0x0a,
-	0x03, 0x6c, 0x61, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x02, 0x52, 0x03, 0x6c, 0x61, 0x74, 0x12,
-	0x10, 0x0a, 0x03, 0x6c, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x02, 0x52, 0x03, 0x6c, 0x6f,
-	0x6e, 0x12, 0x16, 0x0a, 0x06, 0x69, 0x6e, 0x44, 0x61, 0x74, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28,
-	0x09, 0x52, 0x06, 0x69, 0x6e, 0x44, 0x61, 0x74, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x6f, 0x75, 0x74,
-	0x44, 0x61, 0x74, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6f, 0x75, 0x74, 0x44,
-	0x61, 0x74, 0x65, 0x22, 0x2a, 0x0a, 0x0c, 0x53, 0x65, 0x61, 0x72, 0x63, 0x68, 0x52, 0x65, 0x73,
-	0x75, 0x6c, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x68, 0x6f, 0x74, 0x65, 0x6c, 0x49, 0x64, 0x73, 0x18,
-	0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x08, 0x68, 0x6f, 0x74, 0x65, 0x6c, 0x49, 0x64, 0x73, 0x32,
-	0x3f, 0x0a, 0x06, 0x53, 0x65, 0x61, 0x72, 0x63, 0x68, 0x12, 0x35, 0x0a, 0x06, 0x4e, 0x65, 0x61,
-	0x72, 0x62, 0x79, 0x12, 0x15, 0x2e, 0x73, 0x65, 0x61, 0x72, 0x63, 0x68, 0x2e, 0x4e, 0x65, 0x61,
+	0x72, 0x6f, 0x74, 0x6f, 0x12, 0x06, 0x73, 0x65, 0x61, 0x72, 0x63, 0x68, 0x22, 0xaa, 0x02, 0x0a,
+	0x0d, 0x4e, 0x65, 0x61, 0x72, 0x62, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x10,
+	0x0a, 0x03, 0x6c, 0x61, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x02, 0x52, 0x03, 0x6c, 0x61, 0x74,
+	0x12, 0x10, 0x0a, 0x03, 0x6c, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x02, 0x52, 0x03, 0x6c,
+	0x6f, 0x6e, 0x12, 0x16, 0x0a, 0x06, 0x69, 0x6e, 0x44, 0x61, 0x74, 0x65, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x06, 0x69, 0x6e, 0x44, 0x61, 0x74, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x6f, 0x75,
+	0x74, 0x44, 0x61, 0x74, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6f, 0x75, 0x74,
+	0x44, 0x61, 0x74, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x18, 0x05, 0x20,
+	0x01, 0x28, 0x05, 0x52, 0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x12, 0x1c, 0x0a, 0x09, 0x70, 0x61,
+	0x67, 0x65, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x70,
+	0x61, 0x67, 0x65, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x12, 0x1a, 0x0a, 0x08, 0x6d, 0x69, 0x6e, 0x50,
+	0x72, 0x69, 0x63, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x01, 0x52, 0x08, 0x6d, 0x69, 0x6e, 0x50,
+	0x72, 0x69, 0x63, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x6d, 0x61, 0x78, 0x50, 0x72, 0x69, 0x63, 0x65,
+	0x18, 0x08, 0x20, 0x01, 0x28, 0x01, 0x52, 0x08, 0x6d, 0x61, 0x78, 0x50, 0x72, 0x69, 0x63, 0x65,
+	0x12, 0x1a, 0x0a, 0x08, 0x6d, 0x69, 0x6e, 0x53, 0x74, 0x61, 0x72, 0x73, 0x18, 0x09, 0x20, 0x01,
+	0x28, 0x02, 0x52, 0x08, 0x6d, 0x69, 0x6e, 0x53, 0x74, 0x61, 0x72, 0x73, 0x12, 0x16, 0x0a, 0x06,
+	0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x65, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x6c, 0x6f,
+	0x63, 0x61, 0x6c, 0x65, 0x12, 0x23, 0x0a, 0x04, 0x73, 0x6f, 0x72, 0x74, 0x18, 0x0b, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x0f, 0x2e, 0x73, 0x65, 0x61, 0x72, 0x63, 0x68, 0x2e, 0x53, 0x6f, 0x72, 0x74,
+	0x4b, 0x65, 0x79, 0x52, 0x04, 0x73, 0x6f, 0x72, 0x74, 0x22, 0xa8, 0x01, 0x0a, 0x07, 0x53, 0x6f,
+	0x72, 0x74, 0x4b, 0x65, 0x79, 0x12, 0x2b, 0x0a, 0x05, 0x66, 0x69, 0x65, 0x6c, 0x64, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x0e, 0x32, 0x15, 0x2e, 0x73, 0x65, 0x61, 0x72, 0x63, 0x68, 0x2e, 0x53, 0x6f,
+	0x72, 0x74, 0x4b, 0x65, 0x79, 0x2e, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x52, 0x05, 0x66, 0x69, 0x65,
+	0x6c, 0x64, 0x12, 0x1e, 0x0a, 0x0a, 0x64, 0x65, 0x73, 0x63, 0x65, 0x6e, 0x64, 0x69, 0x6e, 0x67,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0a, 0x64, 0x65, 0x73, 0x63, 0x65, 0x6e, 0x64, 0x69,
+	0x6e, 0x67, 0x22, 0x50, 0x0a, 0x05, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x12, 0x15, 0x0a, 0x11, 0x46,
+	0x49, 0x45, 0x4c, 0x44, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49, 0x45, 0x44,
+	0x10, 0x00, 0x12, 0x0c, 0x0a, 0x08, 0x44, 0x49, 0x53, 0x54, 0x41, 0x4e, 0x43, 0x45, 0x10, 0x01,
+	0x12, 0x09, 0x0a, 0x05, 0x50, 0x52, 0x49, 0x43, 0x45, 0x10, 0x02, 0x12, 0x0a, 0x0a, 0x06, 0x52,
+	0x41, 0x54, 0x49, 0x4e, 0x47, 0x10, 0x03, 0x12, 0x0b, 0x0a, 0x07, 0x52, 0x45, 0x56, 0x49, 0x45,
+	0x57, 0x53, 0x10, 0x04, 0x22, 0x74, 0x0a, 0x0c, 0x53, 0x65, 0x61, 0x72, 0x63, 0x68, 0x52, 0x65,
+	0x73, 0x75, 0x6c, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x68, 0x6f, 0x74, 0x65, 0x6c, 0x49, 0x64, 0x73,
+	0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x08, 0x68, 0x6f, 0x74, 0x65, 0x6c, 0x49, 0x64, 0x73,
+	0x12, 0x24, 0x0a, 0x0d, 0x6e, 0x65, 0x78, 0x74, 0x50, 0x61, 0x67, 0x65, 0x54, 0x6f, 0x6b, 0x65,
+	0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0d, 0x6e, 0x65, 0x78, 0x74, 0x50, 0x61, 0x67,
+	0x65, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x12, 0x22, 0x0a, 0x0c, 0x72, 0x61, 0x74, 0x65, 0x73, 0x4f,
+	0x6d, 0x69, 0x74, 0x74, 0x65, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0c, 0x72, 0x61,
+	0x74, 0x65, 0x73, 0x4f, 0x6d, 0x69, 0x74, 0x74, 0x65, 0x64, 0x22, 0xd4, 0x02, 0x0a, 0x0c, 0x48,
+	0x6f, 0x74, 0x65, 0x6c, 0x53, 0x75, 0x6d, 0x6d, 0x61, 0x72, 0x79, 0x12, 0x0e, 0x0a, 0x02, 0x69,
+	0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x6e,
+	0x61, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12,
+	0x20, 0x0a, 0x0b, 0x70, 0x68, 0x6f, 0x6e, 0x65, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x70, 0x68, 0x6f, 0x6e, 0x65, 0x4e, 0x75, 0x6d, 0x62, 0x65,
+	0x72, 0x12, 0x20, 0x0a, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74,
+	0x69, 0x6f, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x63, 0x69, 0x74, 0x79, 0x18, 0x05, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x04, 0x63, 0x69, 0x74, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6c, 0x61, 0x74, 0x18, 0x06,
+	0x20, 0x01, 0x28, 0x02, 0x52, 0x03, 0x6c, 0x61, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x6c, 0x6f, 0x6e,
+	0x18, 0x07, 0x20, 0x01, 0x28, 0x02, 0x52, 0x03, 0x6c, 0x6f, 0x6e, 0x12, 0x14, 0x0a, 0x05, 0x73,
+	0x74, 0x61, 0x72, 0x73, 0x18, 0x08, 0x20, 0x01, 0x28, 0x02, 0x52, 0x05, 0x73, 0x74, 0x61, 0x72,
+	0x73, 0x12, 0x1a, 0x0a, 0x08, 0x72, 0x61, 0x74, 0x65, 0x43, 0x6f, 0x64, 0x65, 0x18, 0x09, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x08, 0x72, 0x61, 0x74, 0x65, 0x43, 0x6f, 0x64, 0x65, 0x12, 0x1a, 0x0a,
+	0x08, 0x72, 0x6f, 0x6f, 0x6d, 0x54, 0x79, 0x70, 0x65, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x08, 0x72, 0x6f, 0x6f, 0x6d, 0x54, 0x79, 0x70, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x74, 0x6f, 0x74,
+	0x61, 0x6c, 0x52, 0x61, 0x74, 0x65, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x01, 0x52, 0x09, 0x74, 0x6f,
+	0x74, 0x61, 0x6c, 0x52, 0x61, 0x74, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x73, 0x74, 0x61, 0x79, 0x54,
+	0x6f, 0x74, 0x61, 0x6c, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x01, 0x52, 0x09, 0x73, 0x74, 0x61, 0x79,
+	0x54, 0x6f, 0x74, 0x61, 0x6c, 0x12, 0x1a, 0x0a, 0x08, 0x63, 0x75, 0x72, 0x72, 0x65, 0x6e, 0x63,
+	0x79, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x63, 0x75, 0x72, 0x72, 0x65, 0x6e, 0x63,
+	0x79, 0x22, 0xb0, 0x01, 0x0a, 0x0c, 0x48, 0x6f, 0x74, 0x65, 0x6c, 0x73, 0x52, 0x65, 0x73, 0x75,
+	0x6c, 0x74, 0x12, 0x2c, 0x0a, 0x06, 0x68, 0x6f, 0x74, 0x65, 0x6c, 0x73, 0x18, 0x01, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x14, 0x2e, 0x73, 0x65, 0x61, 0x72, 0x63, 0x68, 0x2e, 0x48, 0x6f, 0x74, 0x65,
+	0x6c, 0x53, 0x75, 0x6d, 0x6d, 0x61, 0x72, 0x79, 0x52, 0x06, 0x68, 0x6f, 0x74, 0x65, 0x6c, 0x73,
+	0x12, 0x24, 0x0a, 0x0d, 0x6e, 0x65, 0x78, 0x74, 0x50, 0x61, 0x67, 0x65, 0x54, 0x6f, 0x6b, 0x65,
+	0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0d, 0x6e, 0x65, 0x78, 0x74, 0x50, 0x61, 0x67,
+	0x65, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x12, 0x22, 0x0a, 0x0c, 0x72, 0x61, 0x74, 0x65, 0x73, 0x4f,
+	0x6d, 0x69, 0x74, 0x74, 0x65, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0c, 0x72, 0x61,
+	0x74, 0x65, 0x73, 0x4f, 0x6d, 0x69, 0x74, 0x74, 0x65, 0x64, 0x12, 0x28, 0x0a, 0x0f, 0x70, 0x72,
+	0x6f, 0x66, 0x69, 0x6c, 0x65, 0x73, 0x4f, 0x6d, 0x69, 0x74, 0x74, 0x65, 0x64, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x0f, 0x70, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x73, 0x4f, 0x6d, 0x69,
+	0x74, 0x74, 0x65, 0x64, 0x32, 0xbb, 0x01, 0x0a, 0x06, 0x53, 0x65, 0x61, 0x72, 0x63, 0x68, 0x12,
+	0x35, 0x0a, 0x06, 0x4e, 0x65, 0x61, 0x72, 0x62, 0x79, 0x12, 0x15, 0x2e, 0x73, 0x65, 0x61, 0x72,
+	0x63, 0x68, 0x2e, 0x4e, 0x65, 0x61, 0x72, 0x62, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x1a, 0x14, 0x2e, 0x73, 0x65, 0x61, 0x72, 0x63, 0x68, 0x2e, 0x53, 0x65, 0x61, 0x72, 0x63, 0x68,
+	0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x3b, 0x0a, 0x0c, 0x4e, 0x65, 0x61, 0x72, 0x62, 0x79,
+	0x48, 0x6f, 0x74, 0x65, 0x6c, 0x73, 0x12, 0x15, 0x2e, 0x73, 0x65, 0x61, 0x72, 0x63, 0x68, 0x2e,
+	0x4e, 0x65, 0x61, 0x72, 0x62, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x14, 0x2e,
+	0x73, 0x65, 0x61, 0x72, 0x63, 0x68, 0x2e, 0x48, 0x6f, 0x74, 0x65, 0x6c, 0x73, 0x52, 0x65, 0x73,
+	0x75, 0x6c, 0x74, 0x12, 0x3d, 0x0a, 0x0c, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x48, 0x6f, 0x74,
+	0x65, 0x6c, 0x73, 0x12, 0x15, 0x2e, 0x73, 0x65, 0x61, 0x72, 0x63, 0x68, 0x2e, 0x4e, 0x65, 0x61,
 	0x72, 0x62, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x14, 0x2e, 0x73, 0x65, 0x61,
-	0x72, 0x63, 0x68, 0x2e, 0x53, 0x65, 0x61, 0x72, 0x63, 0x68, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74,
-	0x42, 0x46, 0x5a, 0x44, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x64,
-	0x65, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x72, 0x6f, 0x75, 0x2f, 0x44, 0x65, 0x61, 0x74, 0x68, 0x53,
-	0x74, 0x61, 0x72, 0x42, 0x65, 0x6e, 0x63, 0x68, 0x2f, 0x68, 0x6f, 0x74, 0x65, 0x6c, 0x72, 0x65,
-	0x73, 0x65, 0x72, 0x76, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2f, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63,
-	0x65, 0x73, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x72, 0x63, 0x68, 0x2e, 0x48, 0x6f, 0x74, 0x65, 0x6c, 0x53, 0x75, 0x6d, 0x6d, 0x61, 0x72, 0x79,
+	0x30, 0x01, 0x42, 0x52, 0x5a, 0x50, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d,
+	0x2f, 0x64, 0x65, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x72, 0x6f, 0x75, 0x2f, 0x44, 0x65, 0x61, 0x74,
+	0x68, 0x53, 0x74, 0x61, 0x72, 0x42, 0x65, 0x6e, 0x63, 0x68, 0x2f, 0x74, 0x72, 0x65, 0x65, 0x2f,
+	0x6d, 0x61, 0x73, 0x74, 0x65, 0x72, 0x2f, 0x68, 0x6f, 0x74, 0x65, 0x6c, 0x52, 0x65, 0x73, 0x65,
+	0x72, 0x76, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2f, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73,
+	0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -176,19 +669,31 @@ func file_services_search_proto_search_proto_rawDescGZIP() []byte {
 	return file_services_search_proto_search_proto_rawDescData
 }
 
-var file_services_search_proto_search_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_services_search_proto_search_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_services_search_proto_search_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
 var file_services_search_proto_search_proto_goTypes = []interface{}{
-	(*NearbyRequest)(nil), // 0: search.NearbyRequest
-	(*SearchResult)(nil),  // 1: search.SearchResult
+	(SortKey_Field)(0),    // 0: search.SortKey.Field
+	(*NearbyRequest)(nil), // 1: search.NearbyRequest
+	(*SortKey)(nil),       // 2: search.SortKey
+	(*SearchResult)(nil),  // 3: search.SearchResult
+	(*HotelSummary)(nil),  // 4: search.HotelSummary
+	(*HotelsResult)(nil),  // 5: search.HotelsResult
 }
 var file_services_search_proto_search_proto_depIdxs = []int32{
-	0, // 0: search.Search.Nearby:input_type -> search.NearbyRequest
-	1, // 1: search.Search.Nearby:output_type -> search.SearchResult
-	1, // [1:2] is the sub-list for method output_type
-	0, // [0:1] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	2, // 0: search.NearbyRequest.sort:type_name -> search.SortKey
+	0, // 1: search.SortKey.field:type_name -> search.SortKey.Field
+	4, // 2: search.HotelsResult.hotels:type_name -> search.HotelSummary
+	1, // 3: search.Search.Nearby:input_type -> search.NearbyRequest
+	1, // 4: search.Search.NearbyHotels:input_type -> search.NearbyRequest
+	1, // 5: search.Search.StreamHotels:input_type -> search.NearbyRequest
+	3, // 6: search.Search.Nearby:output_type -> search.SearchResult
+	5, // 7: search.Search.NearbyHotels:output_type -> search.HotelsResult
+	4, // 8: search.Search.StreamHotels:output_type -> search.HotelSummary
+	6, // [6:9] is the sub-list for method output_type
+	3, // [3:6] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
 }
 
 func init() { file_services_search_proto_search_proto_init() }
@@ -210,6 +715,18 @@ func file_services_search_proto_search_proto_init() {
 			}
 		}
 		file_services_search_proto_search_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SortKey); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_services_search_proto_search_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*SearchResult); i {
 			case 0:
 				return &v.state
@@ -221,19 +738,44 @@ func file_services_search_proto_search_proto_init() {
 				return nil
 			}
 		}
+		file_services_search_proto_search_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*HotelSummary); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_services_search_proto_search_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*HotelsResult); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_services_search_proto_search_proto_rawDesc,
-			NumEnums:      0,
-			NumMessages:   2,
+			NumEnums:      1,
+			NumMessages:   5,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_services_search_proto_search_proto_goTypes,
 		DependencyIndexes: file_services_search_proto_search_proto_depIdxs,
+		EnumInfos:         file_services_search_proto_search_proto_enumTypes,
 		MessageInfos:      file_services_search_proto_search_proto_msgTypes,
 	}.Build()
 	File_services_search_proto_search_proto = out.File
@@ -19,7 +19,9 @@ import (
 const _ = grpc.SupportPackageIsVersion7
 
 const (
-	Search_Nearby_FullMethodName = "/search.Search/Nearby"
+	Search_Nearby_FullMethodName       = "/search.Search/Nearby"
+	Search_NearbyHotels_FullMethodName = "/search.Search/NearbyHotels"
+	Search_StreamHotels_FullMethodName = "/search.Search/StreamHotels"
 )
 
 // SearchClient is the client API for Search service.
@@ -27,6 +29,12 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type SearchClient interface {
 	Nearby(ctx context.Context, in *NearbyRequest, opts ...grpc.CallOption) (*SearchResult, error)
+	// NearbyHotels searches like Nearby, and returns the hotels found joined
+	// with their profiles and rates.
+	NearbyHotels(ctx context.Context, in *NearbyRequest, opts ...grpc.CallOption) (*HotelsResult, error)
+	// StreamHotels searches like NearbyHotels, and streams the hotels found
+	// one message each, in ranking order.
+	StreamHotels(ctx context.Context, in *NearbyRequest, opts ...grpc.CallOption) (Search_StreamHotelsClient, error)
 }
 
 type searchClient struct {
@@ -46,11 +54,58 @@ func (c *searchClient) Nearby(ctx context.Context, in *NearbyRequest, opts ...gr
 	return out, nil
 }
 
+func (c *searchClient) NearbyHotels(ctx context.Context, in *NearbyRequest, opts ...grpc.CallOption) (*HotelsResult, error) {
+	out := new(HotelsResult)
+	err := c.cc.Invoke(ctx, Search_NearbyHotels_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *searchClient) StreamHotels(ctx context.Context, in *NearbyRequest, opts ...grpc.CallOption) (Search_StreamHotelsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Search_ServiceDesc.Streams[0], Search_StreamHotels_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &searchStreamHotelsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Search_StreamHotelsClient interface {
+	Recv() (*HotelSummary, error)
+	grpc.ClientStream
+}
+
+type searchStreamHotelsClient struct {
+	grpc.ClientStream
+}
+
+func (x *searchStreamHotelsClient) Recv() (*HotelSummary, error) {
+	m := new(HotelSummary)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // SearchServer is the server API for Search service.
 // All implementations must embed UnimplementedSearchServer
 // for forward compatibility
 type SearchServer interface {
 	Nearby(context.Context, *NearbyRequest) (*SearchResult, error)
+	// NearbyHotels searches like Nearby, and returns the hotels found joined
+	// with their profiles and rates.
+	NearbyHotels(context.Context, *NearbyRequest) (*HotelsResult, error)
+	// StreamHotels searches like NearbyHotels, and streams the hotels found
+	// one message each, in ranking order.
+	StreamHotels(*NearbyRequest, Search_StreamHotelsServer) error
 	mustEmbedUnimplementedSearchServer()
 }
 
@@ -61,6 +116,12 @@ type UnimplementedSearchServer struct {
 func (UnimplementedSearchServer) Nearby(context.Context, *NearbyRequest) (*SearchResult, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Nearby not implemented")
 }
+func (UnimplementedSearchServer) NearbyHotels(context.Context, *NearbyRequest) (*HotelsResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method NearbyHotels not implemented")
+}
+func (UnimplementedSearchServer) StreamHotels(*NearbyRequest, Search_StreamHotelsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamHotels not implemented")
+}
 func (UnimplementedSearchServer) mustEmbedUnimplementedSearchServer() {}
 
 // UnsafeSearchServer may be embedded to opt out of forward compatibility for this service.
@@ -92,6 +153,45 @@ func _Search_Nearby_Handler(srv interface{}, ctx context.Context, dec func(inter
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Search_NearbyHotels_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NearbyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SearchServer).NearbyHotels(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Search_NearbyHotels_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SearchServer).NearbyHotels(ctx, req.(*NearbyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Search_StreamHotels_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(NearbyRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SearchServer).StreamHotels(m, &searchStreamHotelsServer{stream})
+}
+
+type Search_StreamHotelsServer interface {
+	Send(*HotelSummary) error
+	grpc.ServerStream
+}
+
+type searchStreamHotelsServer struct {
+	grpc.ServerStream
+}
+
+func (x *searchStreamHotelsServer) Send(m *HotelSummary) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 // Search_ServiceDesc is the grpc.ServiceDesc for Search service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -103,7 +203,17 @@ var Search_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Nearby",
 			Handler:    _Search_Nearby_Handler,
 		},
+		{
+			MethodName: "NearbyHotels",
+			Handler:    _Search_NearbyHotels_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamHotels",
+			Handler:       _Search_StreamHotels_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "services/search/proto/search.proto",
 }
@@ -3,33 +3,60 @@ package search
 import (
 	"fmt"
 	"net"
+	"strings"
 	"time"
 
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/cache"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/dialer"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/graceful"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/healthcheck"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/registry"
 	geo "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/geo/proto"
+	profile "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/profile/proto"
 	rate "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/rate/proto"
+	review "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/review/proto"
 	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/search/proto"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tls"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
 	"github.com/google/uuid"
 	"github.com/grpc-ecosystem/grpc-opentracing/go/otgrpc"
-	_ "github.com/mbobakov/grpc-consul-resolver"
 	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/rs/zerolog/log"
 	context "golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/reflection"
 )
 
 const name = "srv-search"
 
+// validators check requests before their handler runs.
+var validators = map[string]tracing.Validator{
+	pb.Search_Nearby_FullMethodName:       tracing.LatLon("lat", "lon"),
+	pb.Search_NearbyHotels_FullMethodName: tracing.LatLon("lat", "lon"),
+	pb.Search_StreamHotels_FullMethodName: tracing.LatLon("lat", "lon"),
+}
+
+// idempotentMethods are the read-only calls to geo, rate, profile and
+// review, safe to retry and to send backup requests for.
+var idempotentMethods = []string{
+	geo.Geo_Nearby_FullMethodName,
+	rate.Rate_GetRates_FullMethodName,
+	profile.Profile_GetProfiles_FullMethodName,
+	review.Review_GetAggregateRating_FullMethodName,
+}
+
 // Server implments the search service
 type Server struct {
 	pb.UnimplementedSearchServer
 
-	geoClient  geo.GeoClient
-	rateClient rate.RateClient
+	cache      *cache.LRU
 	uuid       string
+	health     *healthcheck.Checker
+	grpcServer *grpc.Server
+	traffic    tracing.MetricsMark
 
 	Tracer     opentracing.Tracer
 	Port       int
@@ -37,6 +64,40 @@ type Server struct {
 	ConsulAddr string
 	KnativeDns string
 	Registry   *registry.Client
+	// MetricsPort is the admin port serving /metrics, zero to disable it.
+	MetricsPort int
+	// BudgetSlice is the share of the request budget under which calls to
+	// geo, rate and profile are reported as starved.
+	BudgetSlice float64
+	// CompressThreshold is the size in bytes from which responses are sent
+	// gzip-compressed.
+	CompressThreshold int
+	// CacheEntries is the number of results of identical searches cached
+	// for CacheTTL. Either being zero disables the cache.
+	CacheEntries int
+	CacheTTL     time.Duration
+	// CachePrecision is the number of decimal places the coordinates of
+	// cached searches are rounded to, so that searches a few meters apart
+	// share a result. Negative keeps them exact.
+	CachePrecision int
+	// Timeouts bound each call to geo, rate and profile, keyed by service
+	// name without the srv- prefix. Missing ones leave the calls unbounded.
+	Timeouts map[string]time.Duration
+	// MissingData is DropMissing or IncludeMissing, what NearbyHotels does
+	// with the hotels profile has no data for. Unknown values drop them.
+	MissingData string
+	// HedgeDelay is the time after which a backup request is sent for a
+	// read-only call still waiting for its reply, up to MaxHedges times.
+	// Zero disables hedging.
+	HedgeDelay time.Duration
+	MaxHedges  int
+
+	// The clients of geo, rate, profile and review, dialed through
+	// ConsulAddr by Run if nil.
+	GeoClient     geo.GeoClient
+	RateClient    rate.RateClient
+	ProfileClient profile.ProfileClient
+	ReviewClient  review.ReviewClient
 }
 
 // Run starts the server
@@ -46,17 +107,42 @@ func (s *Server) Run() error {
 	}
 
 	s.uuid = uuid.New().String()
+	s.cache = cache.New(s.CacheEntries, s.CacheTTL)
+
+	shed, limiter := tracing.ConcurrencyLimitUnaryServerInterceptor(tune.GetMaxInflight())
+	tracing.DefaultMetrics.RegisterGauge("grpc_server_inflight_requests", "Number of requests being handled.", func() uint64 {
+		return uint64(limiter.InFlight())
+	})
 
 	opts := []grpc.ServerOption{
 		grpc.KeepaliveParams(keepalive.ServerParameters{
 			Timeout: 120 * time.Second,
 		}),
 		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             time.Duration(tune.GetKeepaliveTime()) * time.Millisecond,
 			PermitWithoutStream: true,
 		}),
-		grpc.UnaryInterceptor(
-			otgrpc.OpenTracingServerInterceptor(s.Tracer),
-		),
+		grpc.MaxRecvMsgSize(tune.GetMaxRecvMsgSize()),
+		grpc.MaxSendMsgSize(tune.GetMaxSendMsgSize()),
+		grpc.UnaryInterceptor(tracing.DefaultServerInterceptorChain(tracing.ServerChainOptions{
+			Tracer: s.Tracer,
+			Interceptors: []grpc.UnaryServerInterceptor{
+				shed,
+				tracing.ChaosUnaryServerInterceptor(tracing.DefaultChaos),
+				tracing.CompressionUnaryServerInterceptor(s.CompressThreshold),
+				tracing.BudgetUnaryServerInterceptor,
+				tracing.CostUnaryServerInterceptor,
+				tracing.ValidationUnaryServerInterceptor(validators),
+			},
+		})),
+		grpc.StreamInterceptor(tracing.ChainStreamServerInterceptors(
+			tracing.RecoveryStreamServerInterceptor,
+			otgrpc.OpenTracingStreamServerInterceptor(s.Tracer),
+			tracing.SpanNamingStreamServerInterceptor,
+			tracing.RequestIDStreamServerInterceptor,
+			tracing.SizeTaggingStreamServerInterceptor,
+			tracing.ValidationStreamServerInterceptor(validators),
+		)),
 	}
 
 	if tlsopt := tls.GetServerOpt(); tlsopt != nil {
@@ -64,7 +150,22 @@ func (s *Server) Run() error {
 	}
 
 	srv := grpc.NewServer(opts...)
+	s.grpcServer = srv
+	s.traffic = tracing.DefaultMetrics.Mark()
 	pb.RegisterSearchServer(srv, s)
+	if tune.GetGrpcReflection() {
+		reflection.Register(srv)
+	}
+
+	s.health = healthcheck.Register(srv, pb.Search_ServiceDesc.ServiceName)
+	s.health.Start()
+
+	if s.MetricsPort != 0 {
+		go tracing.ServeMetrics(s.MetricsPort, tracing.DefaultMetrics)
+	}
+	if path := tune.GetFeatureFlagsFile(); path != "" {
+		go tracing.DefaultFlags.WatchFile(path, time.Duration(tune.GetFeatureFlagsPollInterval())*time.Millisecond)
+	}
 
 	// init grpc clients
 	if err := s.initGeoClient("srv-geo"); err != nil {
@@ -73,6 +174,12 @@ func (s *Server) Run() error {
 	if err := s.initRateClient("srv-rate"); err != nil {
 		return err
 	}
+	if err := s.initProfileClient("srv-profile"); err != nil {
+		return err
+	}
+	if err := s.initReviewClient("srv-review"); err != nil {
+		return err
+	}
 
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", s.Port))
 	if err != nil {
@@ -88,52 +195,151 @@ func (s *Server) Run() error {
 	return srv.Serve(lis)
 }
 
-// Shutdown cleans up any processes
+// Shutdown reports the server NOT_SERVING, deregisters it and drains
+// in-flight RPCs before stopping it, then reports the traffic it served.
 func (s *Server) Shutdown() {
+	s.health.Shutdown()
 	s.Registry.Deregister(s.uuid)
+	graceful.Stop(s.grpcServer)
+	graceful.Report(s.grpcServer, s.traffic)
 }
 
 func (s *Server) initGeoClient(name string) error {
+	if s.GeoClient != nil {
+		return nil
+	}
 	conn, err := s.getGprcConn(name)
 	if err != nil {
 		return fmt.Errorf("dialer error: %v", err)
 	}
-	s.geoClient = geo.NewGeoClient(conn)
+	s.GeoClient = geo.NewGeoClient(conn)
 	return nil
 }
 
 func (s *Server) initRateClient(name string) error {
+	if s.RateClient != nil {
+		return nil
+	}
 	conn, err := s.getGprcConn(name)
 	if err != nil {
 		return fmt.Errorf("dialer error: %v", err)
 	}
-	s.rateClient = rate.NewRateClient(conn)
+	s.RateClient = rate.NewRateClient(conn)
+	return nil
+}
+
+func (s *Server) initProfileClient(name string) error {
+	if s.ProfileClient != nil {
+		return nil
+	}
+	conn, err := s.getGprcConn(name)
+	if err != nil {
+		return fmt.Errorf("dialer error: %v", err)
+	}
+	s.ProfileClient = profile.NewProfileClient(conn)
+	return nil
+}
+
+func (s *Server) initReviewClient(name string) error {
+	if s.ReviewClient != nil {
+		return nil
+	}
+	conn, err := s.getGprcConn(name)
+	if err != nil {
+		return fmt.Errorf("dialer error: %v", err)
+	}
+	s.ReviewClient = review.NewReviewClient(conn)
 	return nil
 }
 
 func (s *Server) getGprcConn(name string) (*grpc.ClientConn, error) {
+	dep := strings.TrimPrefix(name, "srv-")
+	retry := tracing.RetryUnaryClientInterceptor(tune.GetRetryAttempts(),
+		time.Duration(tune.GetRetryBackoff())*time.Millisecond, codes.Unavailable)
+	interceptors := dialer.WithUnaryInterceptors(
+		tracing.BudgetUnaryClientInterceptor(s.BudgetSlice),
+		tracing.RequestIDUnaryClientInterceptor,
+		tracing.TenantUnaryClientInterceptor,
+		tracing.CostUnaryClientInterceptor,
+		tracing.StatusTaggingUnaryClientInterceptor,
+		tracing.LatencyTaggingUnaryClientInterceptor,
+		tracing.SizeTaggingUnaryClientInterceptor,
+		tracing.DeadlineWarningUnaryClientInterceptor(time.Duration(tune.GetDeadlineWarn())*time.Millisecond),
+		tracing.CircuitBreakerUnaryClientInterceptor(tune.GetBreakerThreshold(),
+			time.Duration(tune.GetBreakerCooldown())*time.Millisecond),
+		tracing.MethodsUnaryClientInterceptor(retry, idempotentMethods...),
+		tracing.TimeoutUnaryClientInterceptor(dep, s.Timeouts[dep]),
+		tracing.HedgingUnaryClientInterceptor(s.HedgeDelay, s.MaxHedges, idempotentMethods...),
+		tracing.ConnStateUnaryClientInterceptor(),
+	)
 	if s.KnativeDns != "" {
 		return dialer.Dial(
 			fmt.Sprintf("consul://%s/%s.%s", s.ConsulAddr, name, s.KnativeDns),
-			dialer.WithTracer(s.Tracer))
+			dialer.WithTracer(s.Tracer),
+			interceptors)
 	} else {
 		return dialer.Dial(
 			fmt.Sprintf("consul://%s/%s", s.ConsulAddr, name),
 			dialer.WithTracer(s.Tracer),
+			interceptors,
 			dialer.WithBalancer(s.Registry.Client),
 		)
 	}
 }
 
-// Nearby returns ids of nearby hotels ordered by ranking algo
+// Nearby returns ids of nearby hotels ordered by ranking algo, or by the sort
+// keys of req. Results are cached by request, so that identical searches are
+// answered without calling geo, rate and profile again until the cached
+// result expires. With the cache on, coordinates are rounded to
+// s.CachePrecision first, so that searches in the same cell are identical.
 func (s *Server) Nearby(ctx context.Context, req *pb.NearbyRequest) (*pb.SearchResult, error) {
+	if s.cache == nil {
+		return s.nearby(ctx, req)
+	}
+	req = quantize(req, s.CachePrecision)
+	key, err := cacheKey(req)
+	if err != nil {
+		return s.nearby(ctx, req)
+	}
+
+	span := opentracing.SpanFromContext(ctx)
+	if res, ok := s.cache.Get(key, time.Now()); ok {
+		if span != nil {
+			span.SetTag("cache", "hit")
+		}
+		return res.(*pb.SearchResult), nil
+	}
+	if span != nil {
+		span.SetTag("cache", "miss")
+	}
+
+	res, err := s.nearby(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	// degraded results are not worth keeping
+	if !res.RatesOmitted {
+		s.cache.Put(key, res, time.Now())
+	}
+	return res, nil
+}
+
+func (s *Server) nearby(ctx context.Context, req *pb.NearbyRequest) (*pb.SearchResult, error) {
 	// find nearby hotels
 	log.Trace().Msg("in Search Nearby")
 
 	log.Trace().Msgf("nearby lat = %f", req.Lat)
 	log.Trace().Msgf("nearby lon = %f", req.Lon)
 
-	nearby, err := s.geoClient.Nearby(ctx, &geo.Request{
+	if err := validateFilters(req); err != nil {
+		return nil, err
+	}
+	if err := validateSort(req); err != nil {
+		return nil, err
+	}
+	ord := order(req.Sort)
+
+	nearby, err := s.GeoClient.Nearby(ctx, &geo.Request{
 		Lat: req.Lat,
 		Lon: req.Lon,
 	})
@@ -145,12 +351,19 @@ func (s *Server) Nearby(ctx context.Context, req *pb.NearbyRequest) (*pb.SearchR
 		log.Trace().Msgf("get Nearby hotelId = %s", hid)
 	}
 
-	// find rates for hotels
-	rates, err := s.rateClient.GetRates(ctx, &rate.Request{
-		HotelIds: nearby.HotelIds,
+	// find rates for all the hotels in one call
+	rates, err := s.RateClient.GetRates(ctx, &rate.Request{
+		HotelIds: batchIDs(ctx, "rate", nearby.HotelIds),
 		InDate:   req.InDate,
 		OutDate:  req.OutDate,
 	})
+	ratesOmitted := false
+	if tracing.DependencyTimedOut(ctx, err) && req.MinPrice == 0 && req.MaxPrice == 0 && !ord.has(pb.SortKey_PRICE) {
+		// Without a price filter to apply or sort by, list the hotels
+		// unpriced.
+		log.Warn().Msgf("Searching without rates: %v", err)
+		rates, err, ratesOmitted = unpricedRates(nearby.HotelIds), nil, true
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -160,11 +373,30 @@ func (s *Server) Nearby(ctx context.Context, req *pb.NearbyRequest) (*pb.SearchR
 	// * price (best discount?)
 	// * reviews
 
-	// build the response
-	res := new(pb.SearchResult)
 	for _, ratePlan := range rates.RatePlans {
 		log.Trace().Msgf("get RatePlan HotelId = %s, Code = %s", ratePlan.HotelId, ratePlan.Code)
-		res.HotelIds = append(res.HotelIds, ratePlan.HotelId)
+	}
+
+	ratePlans, stars, err := s.filterRatePlans(ctx, req, rates.RatePlans)
+	if err != nil {
+		return nil, err
+	}
+
+	reviews, err := s.reviewRatings(ctx, ord, ratePlans)
+	if err != nil {
+		return nil, err
+	}
+
+	hotels := rankHotels(ratePlans, ord, nearbyDistances(nearby.HotelIds, nearby.Distances), stars, reviews)
+	page, nextPageToken, err := paginate(hotels, ord, req.Limit, req.PageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// build the response
+	res := &pb.SearchResult{NextPageToken: nextPageToken, RatesOmitted: ratesOmitted}
+	for _, h := range page {
+		res.HotelIds = append(res.HotelIds, h.id)
 	}
 	return res, nil
 }
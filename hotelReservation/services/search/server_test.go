@@ -0,0 +1,220 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	geo "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/geo/proto"
+	profile "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/profile/proto"
+	rate "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/rate/proto"
+	review "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/review/proto"
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/search/proto"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeHotel is a hotel as geo, rate, profile and review know it.
+type fakeHotel struct {
+	id       string
+	rate     float64
+	distance float32
+	stars    float32
+	reviews  float32
+}
+
+// backends fake geo, rate, profile and review, all knowing of the same
+// hotels, and count the calls made to them.
+type backends struct {
+	hotels []fakeHotel
+
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (b *backends) call(rpc string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.calls[rpc]++
+}
+
+// callCount returns the number of calls made to rpc.
+func (b *backends) callCount(rpc string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.calls[rpc]
+}
+
+func (b *backends) hotel(id string) (fakeHotel, bool) {
+	for _, h := range b.hotels {
+		if h.id == id {
+			return h, true
+		}
+	}
+	return fakeHotel{}, false
+}
+
+type fakeGeo struct {
+	geo.GeoClient
+	*backends
+}
+
+func (g fakeGeo) Nearby(ctx context.Context, req *geo.Request, opts ...grpc.CallOption) (*geo.Result, error) {
+	g.call("geo")
+	res := &geo.Result{}
+	for _, h := range g.hotels {
+		res.HotelIds = append(res.HotelIds, h.id)
+		res.Distances = append(res.Distances, h.distance)
+	}
+	return res, nil
+}
+
+type fakeRate struct {
+	rate.RateClient
+	*backends
+}
+
+func (r fakeRate) GetRates(ctx context.Context, req *rate.Request, opts ...grpc.CallOption) (*rate.Result, error) {
+	r.call("rate")
+	res := &rate.Result{}
+	for _, id := range req.HotelIds {
+		if h, ok := r.hotel(id); ok {
+			res.RatePlans = append(res.RatePlans, &rate.RatePlan{
+				HotelId:  id,
+				Code:     "RACK",
+				RoomType: &rate.RoomType{TotalRate: h.rate, Currency: "USD"},
+			})
+		}
+	}
+	return res, nil
+}
+
+type fakeProfile struct {
+	profile.ProfileClient
+	*backends
+}
+
+func (p fakeProfile) GetProfiles(ctx context.Context, req *profile.Request, opts ...grpc.CallOption) (*profile.Result, error) {
+	p.call("profile")
+	res := &profile.Result{}
+	for _, id := range req.HotelIds {
+		if h, ok := p.hotel(id); ok {
+			res.Hotels = append(res.Hotels, &profile.Hotel{Id: id, Name: "Hotel " + id, Stars: h.stars})
+		}
+	}
+	return res, nil
+}
+
+type fakeReview struct {
+	review.ReviewClient
+	*backends
+}
+
+func (r fakeReview) GetAggregateRating(ctx context.Context, req *review.Request, opts ...grpc.CallOption) (*review.AggregateRating, error) {
+	r.call("review")
+	h, ok := r.hotel(req.HotelId)
+	if !ok {
+		return &review.AggregateRating{HotelId: req.HotelId, Average: 2.5}, nil
+	}
+	return &review.AggregateRating{HotelId: req.HotelId, Average: h.reviews, Count: 1}, nil
+}
+
+// newTestServer returns a search server, without the cache, whose clients
+// are fakes knowing of hotels.
+func newTestServer(hotels ...fakeHotel) (*Server, *backends) {
+	b := &backends{hotels: hotels, calls: make(map[string]int)}
+	return &Server{
+		GeoClient:     fakeGeo{backends: b},
+		RateClient:    fakeRate{backends: b},
+		ProfileClient: fakeProfile{backends: b},
+		ReviewClient:  fakeReview{backends: b},
+	}, b
+}
+
+// withMockSpan returns ctx carrying a span of a mock tracer, whose tags the
+// test can check.
+func withMockSpan(ctx context.Context) (context.Context, *mocktracer.MockSpan) {
+	span := mocktracer.New().StartSpan("test").(*mocktracer.MockSpan)
+	return opentracing.ContextWithSpan(ctx, span), span
+}
+
+// filterHotels are priced from 50 to 250 with from 1 to 5 stars, the
+// cheapest having the fewest.
+var filterHotels = []fakeHotel{
+	{id: "1", rate: 50, stars: 1},
+	{id: "2", rate: 100, stars: 2},
+	{id: "3", rate: 150, stars: 3},
+	{id: "4", rate: 200, stars: 4},
+	{id: "5", rate: 250, stars: 5},
+}
+
+func TestNearbyFilters(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *pb.NearbyRequest
+		want []string
+	}{
+		{"unbounded", &pb.NearbyRequest{}, []string{"5", "4", "3", "2", "1"}},
+		{"min price only", &pb.NearbyRequest{MinPrice: 150}, []string{"5", "4", "3"}},
+		{"max price only", &pb.NearbyRequest{MaxPrice: 150}, []string{"3", "2", "1"}},
+		{"price range", &pb.NearbyRequest{MinPrice: 100, MaxPrice: 200}, []string{"4", "3", "2"}},
+		{"min stars only", &pb.NearbyRequest{MinStars: 4}, []string{"5", "4"}},
+		{"combined", &pb.NearbyRequest{MinPrice: 60, MaxPrice: 220, MinStars: 3}, []string{"4", "3"}},
+		{"equal bounds", &pb.NearbyRequest{MinPrice: 150, MaxPrice: 150}, []string{"3"}},
+		{"nothing matches", &pb.NearbyRequest{MaxPrice: 100, MinStars: 3}, nil},
+	}
+	for _, tt := range tests {
+		s, _ := newTestServer(filterHotels...)
+		res, err := s.Nearby(context.Background(), tt.req)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if fmt.Sprint(res.HotelIds) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: got hotels %v, want %v", tt.name, res.HotelIds, tt.want)
+		}
+	}
+}
+
+func TestNearbyFiltersSkipProfilesWithoutStarFilter(t *testing.T) {
+	s, b := newTestServer(filterHotels...)
+	if _, err := s.Nearby(context.Background(), &pb.NearbyRequest{MinPrice: 100}); err != nil {
+		t.Fatalf("Nearby: %v", err)
+	}
+	if n := b.callCount("profile"); n != 0 {
+		t.Errorf("profile called %d times without a star filter, want 0", n)
+	}
+}
+
+func TestNearbyFiltersTagged(t *testing.T) {
+	s, _ := newTestServer(filterHotels...)
+	ctx, span := withMockSpan(context.Background())
+	if _, err := s.Nearby(ctx, &pb.NearbyRequest{MinPrice: 60, MaxPrice: 220, MinStars: 3}); err != nil {
+		t.Fatalf("Nearby: %v", err)
+	}
+	tags := span.Tags()
+	if tags["search.filter.min_price"] != 60.0 || tags["search.filter.max_price"] != 220.0 || tags["search.filter.min_stars"] != float32(3) {
+		t.Errorf("got tags %v, want the filters", tags)
+	}
+}
+
+func TestNearbyInvalidFilters(t *testing.T) {
+	for _, req := range []*pb.NearbyRequest{
+		{MinPrice: 200, MaxPrice: 100},
+		{MinPrice: -1},
+		{MaxPrice: -1},
+		{MinStars: -1},
+	} {
+		s, b := newTestServer(filterHotels...)
+		if _, err := s.Nearby(context.Background(), req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("Nearby(%v): got %v, want InvalidArgument", req, err)
+		}
+		if n := b.callCount("geo"); n != 0 {
+			t.Errorf("Nearby(%v) called geo before rejecting the filters", req)
+		}
+	}
+}
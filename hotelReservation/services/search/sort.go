@@ -0,0 +1,87 @@
+package search
+
+import (
+	"fmt"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails"
+	errpb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails/proto"
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/search/proto"
+)
+
+// order is the order of search results: by each of its keys in turn, then
+// by descending rate, then by ascending hotel ID. The empty order is the
+// default one.
+type order []*pb.SortKey
+
+// before reports whether h comes before o in the order.
+func (ord order) before(h, o rankedHotel) bool {
+	for _, k := range ord {
+		a, b := h.value(k.Field), o.value(k.Field)
+		if a == b {
+			continue
+		}
+		if k.Descending {
+			return a > b
+		}
+		return a < b
+	}
+	if h.rate != o.rate {
+		return h.rate > o.rate
+	}
+	return h.id < o.id
+}
+
+// has reports whether the order has a key on field.
+func (ord order) has(field pb.SortKey_Field) bool {
+	for _, k := range ord {
+		if k.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// value returns the value of h ordered by field.
+func (h rankedHotel) value(field pb.SortKey_Field) float64 {
+	switch field {
+	case pb.SortKey_DISTANCE:
+		return h.distance
+	case pb.SortKey_PRICE:
+		return h.rate
+	case pb.SortKey_RATING:
+		return h.stars
+	case pb.SortKey_REVIEWS:
+		return h.reviews
+	}
+	return 0
+}
+
+// validateSort checks the sort keys of req: each must be on a known field,
+// and no field may be used twice.
+func validateSort(req *pb.NearbyRequest) error {
+	seen := make(map[pb.SortKey_Field]bool, len(req.Sort))
+	for i, k := range req.Sort {
+		field := fmt.Sprintf("sort[%d].field", i)
+		if k == nil || k.Field == pb.SortKey_FIELD_UNSPECIFIED || pb.SortKey_Field_name[int32(k.Field)] == "" {
+			return errdetails.InvalidArgument(errpb.ErrorDetail_UNSUPPORTED, field, "unsupported sort key %v", k.GetField())
+		}
+		if seen[k.Field] {
+			return errdetails.InvalidArgument(errpb.ErrorDetail_OUT_OF_RANGE, field, "duplicate sort key %v", k.Field)
+		}
+		seen[k.Field] = true
+	}
+	return nil
+}
+
+// nearbyDistances maps hotelIds to their distances, none if distances
+// doesn't have one per hotel.
+func nearbyDistances(hotelIds []string, distances []float32) map[string]float32 {
+	if len(distances) != len(hotelIds) {
+		return nil
+	}
+	m := make(map[string]float32, len(hotelIds))
+	for i, id := range hotelIds {
+		m[id] = distances[i]
+	}
+	return m
+}
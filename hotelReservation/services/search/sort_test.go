@@ -0,0 +1,106 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/search/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// sortHotels are at two distances, each with cheap and dear hotels, and
+// rated so that ties are left at every key.
+var sortHotels = []fakeHotel{
+	{id: "far-cheap", distance: 5, rate: 80, stars: 4, reviews: 3},
+	{id: "near-dear", distance: 1, rate: 300, stars: 4, reviews: 5},
+	{id: "near-cheap", distance: 1, rate: 90, stars: 2, reviews: 4},
+	{id: "far-dear", distance: 5, rate: 250, stars: 5, reviews: 4},
+	{id: "near-cheap-2", distance: 1, rate: 90, stars: 3, reviews: 2},
+	{id: "mid", distance: 3, rate: 120, stars: 3, reviews: 4},
+}
+
+func TestNearbySortKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		sort []*pb.SortKey
+		want []string
+	}{
+		{"default", nil, []string{"near-dear", "far-dear", "mid", "near-cheap", "near-cheap-2", "far-cheap"}},
+		{
+			"cheapest among the nearest",
+			[]*pb.SortKey{{Field: pb.SortKey_DISTANCE}, {Field: pb.SortKey_PRICE}},
+			// the equally near and cheap are in the default order, by ID
+			[]string{"near-cheap", "near-cheap-2", "near-dear", "mid", "far-cheap", "far-dear"},
+		},
+		{
+			"nearest among the dearest",
+			[]*pb.SortKey{{Field: pb.SortKey_PRICE, Descending: true}, {Field: pb.SortKey_DISTANCE}},
+			[]string{"near-dear", "far-dear", "mid", "near-cheap", "near-cheap-2", "far-cheap"},
+		},
+		{
+			"farthest, then best rated",
+			[]*pb.SortKey{{Field: pb.SortKey_DISTANCE, Descending: true}, {Field: pb.SortKey_RATING, Descending: true}},
+			[]string{"far-dear", "far-cheap", "mid", "near-dear", "near-cheap-2", "near-cheap"},
+		},
+		{
+			"best reviewed, then cheapest, then nearest",
+			[]*pb.SortKey{{Field: pb.SortKey_REVIEWS, Descending: true}, {Field: pb.SortKey_PRICE}, {Field: pb.SortKey_DISTANCE}},
+			[]string{"near-dear", "near-cheap", "mid", "far-dear", "far-cheap", "near-cheap-2"},
+		},
+		{
+			// ties at the only key fall back to the default order
+			"nearest",
+			[]*pb.SortKey{{Field: pb.SortKey_DISTANCE}},
+			[]string{"near-dear", "near-cheap", "near-cheap-2", "mid", "far-dear", "far-cheap"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestServer(sortHotels...)
+			res, err := s.Nearby(context.Background(), &pb.NearbyRequest{Sort: tt.sort})
+			if err != nil {
+				t.Fatalf("Nearby: %v", err)
+			}
+			if fmt.Sprint(res.HotelIds) != fmt.Sprint(tt.want) {
+				t.Errorf("got %v, want %v", res.HotelIds, tt.want)
+			}
+		})
+	}
+}
+
+func TestNearbySortFetchesOnlyNeededFields(t *testing.T) {
+	s, b := newTestServer(sortHotels...)
+	if _, err := s.Nearby(context.Background(), &pb.NearbyRequest{Sort: []*pb.SortKey{{Field: pb.SortKey_DISTANCE}, {Field: pb.SortKey_PRICE}}}); err != nil {
+		t.Fatalf("Nearby: %v", err)
+	}
+	if n := b.callCount("profile"); n != 0 {
+		t.Errorf("profile called %d times without sorting by rating, want 0", n)
+	}
+	if n := b.callCount("review"); n != 0 {
+		t.Errorf("review called %d times without sorting by reviews, want 0", n)
+	}
+}
+
+func TestNearbySortInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		sort []*pb.SortKey
+	}{
+		{"unspecified field", []*pb.SortKey{{Field: pb.SortKey_FIELD_UNSPECIFIED}}},
+		{"unknown field", []*pb.SortKey{{Field: pb.SortKey_DISTANCE}, {Field: pb.SortKey_Field(42)}}},
+		{"missing key", []*pb.SortKey{nil}},
+		{"duplicate field", []*pb.SortKey{{Field: pb.SortKey_PRICE}, {Field: pb.SortKey_DISTANCE}, {Field: pb.SortKey_PRICE, Descending: true}}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s, b := newTestServer(sortHotels...)
+			if _, err := s.Nearby(context.Background(), &pb.NearbyRequest{Sort: tt.sort}); status.Code(err) != codes.InvalidArgument {
+				t.Errorf("got %v, want InvalidArgument", err)
+			}
+			if n := b.callCount("geo"); n != 0 {
+				t.Errorf("geo called before rejecting the sort keys")
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	rate "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/rate/proto"
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/search/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// slowRate is a rate service that never answers, called through the
+// timeout interceptor of the rate dependency.
+type slowRate struct {
+	rate.RateClient
+	*backends
+	timeout time.Duration
+}
+
+func (r slowRate) GetRates(ctx context.Context, req *rate.Request, opts ...grpc.CallOption) (*rate.Result, error) {
+	r.call("rate")
+	hang := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		<-ctx.Done()
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	res := new(rate.Result)
+	err := tracing.TimeoutUnaryClientInterceptor("rate", r.timeout)(ctx, "/rate.Rate/GetRates", req, res, nil, hang)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// newSlowRateServer returns a search server caching results, whose rate
+// calls time out after 20ms.
+func newSlowRateServer(hotels ...fakeHotel) (*Server, *backends) {
+	s, b := newCachedTestServer(time.Minute, hotels...)
+	s.RateClient = slowRate{backends: b, timeout: 20 * time.Millisecond}
+	return s, b
+}
+
+func TestNearbyWithoutRates(t *testing.T) {
+	s, b := newSlowRateServer(filterHotels...)
+	req := &pb.NearbyRequest{Lat: 37.7, Lon: -122.4, InDate: "2015-04-09", OutDate: "2015-04-10"}
+
+	for i := 1; i <= 2; i++ {
+		ctx, span := withMockSpan(context.Background())
+		res, err := s.Nearby(ctx, req)
+		if err != nil {
+			t.Fatalf("Nearby: %v", err)
+		}
+		if !res.RatesOmitted || fmt.Sprint(res.HotelIds) != "[1 2 3 4 5]" {
+			t.Errorf("got %v, want every hotel by id, without rates", res)
+		}
+		if span.Tag("timeout.rate") != true {
+			t.Errorf("span not tagged with the rate timeout")
+		}
+		// degraded results are not cached
+		if n := b.callCount("rate"); n != i {
+			t.Errorf("rate called %d times after %d searches, want %d", n, i, i)
+		}
+	}
+}
+
+func TestNearbyNeedingRates(t *testing.T) {
+	s, _ := newSlowRateServer(filterHotels...)
+	for _, req := range []*pb.NearbyRequest{
+		{MinPrice: 100},
+		{MaxPrice: 100},
+		{Sort: []*pb.SortKey{{Field: pb.SortKey_PRICE}}},
+	} {
+		if _, err := s.Nearby(context.Background(), req); status.Code(err) != codes.DeadlineExceeded {
+			t.Errorf("%v: got %v, want DeadlineExceeded", req, err)
+		}
+	}
+}
+
+func TestNearbyHotelsWithoutRates(t *testing.T) {
+	s, _ := newSlowRateServer(filterHotels...)
+	ctx, span := withMockSpan(context.Background())
+	res, err := s.NearbyHotels(ctx, &pb.NearbyRequest{Lat: 37.7, Lon: -122.4, InDate: "2015-04-09", OutDate: "2015-04-10"})
+	if err != nil {
+		t.Fatalf("NearbyHotels: %v", err)
+	}
+	if !res.RatesOmitted || len(res.Hotels) != len(filterHotels) {
+		t.Errorf("got %v, want every hotel without rates", res)
+	}
+	if span.Tag("timeout.rate") != true || span.Tag("search.rates_omitted") != true {
+		t.Errorf("got tags %v, want the rate timeout and rates omitted", span.Tags())
+	}
+}
+
+func TestNearbyOutOfTime(t *testing.T) {
+	// the search itself running out of time fails
+	s, _ := newSlowRateServer(filterHotels...)
+	s.RateClient = slowRate{backends: s.RateClient.(slowRate).backends, timeout: time.Second}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := s.Nearby(ctx, &pb.NearbyRequest{}); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("got %v, want DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,87 @@
+package user
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/net/context"
+)
+
+// isLegacyPassword reports whether a stored password is a legacy record,
+// holding the hex SHA-256 digest of the password instead of a bcrypt hash.
+func isLegacyPassword(stored string) bool {
+	return !strings.HasPrefix(stored, "$2")
+}
+
+// legacyDigest returns the legacy record of password.
+func legacyDigest(password string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(password)))
+}
+
+// hashPassword returns the bcrypt hash of password at the configured cost.
+func (s *Server) hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), s.BcryptCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// checkPassword reports whether password matches the stored password of
+// username. Legacy records are upgraded to a bcrypt hash on the first
+// successful check. A bcrypt comparison runs whether or not username exists
+// and whatever its record format, so the time taken does not tell callers
+// which usernames are valid.
+func (s *Server) checkPassword(username, password string) bool {
+	s.usersMu.RLock()
+	stored, found := s.users[username]
+	s.usersMu.RUnlock()
+
+	if !found || isLegacyPassword(stored) {
+		bcrypt.CompareHashAndPassword(s.dummyHash, []byte(password))
+	}
+	if !found {
+		return false
+	}
+
+	if !isLegacyPassword(stored) {
+		return bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) == nil
+	}
+
+	if subtle.ConstantTimeCompare([]byte(stored), []byte(legacyDigest(password))) != 1 {
+		return false
+	}
+	s.upgradePassword(username, stored, password)
+	return true
+}
+
+// upgradePassword replaces the legacy record of username with a bcrypt hash
+// of password. Failures are logged and leave the legacy record in place, to
+// be retried on the next login.
+func (s *Server) upgradePassword(username, legacy, password string) {
+	hash, err := s.hashPassword(password)
+	if err != nil {
+		log.Error().Msgf("Failed to hash password of user %s: %v", username, err)
+		return
+	}
+
+	collection := s.DB.Collection("user")
+	filter := bson.M{"username": username, "password": legacy}
+	update := bson.M{"$set": bson.M{"password": hash}}
+	if _, err := collection.UpdateOne(context.TODO(), filter, update); err != nil {
+		log.Error().Msgf("Failed to upgrade password of user %s: %v", username, err)
+		return
+	}
+
+	s.usersMu.Lock()
+	if s.users[username] == legacy {
+		s.users[username] = hash
+	}
+	s.usersMu.Unlock()
+	log.Debug().Msgf("Upgraded password of user %s to bcrypt", username)
+}
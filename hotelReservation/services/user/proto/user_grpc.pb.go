@@ -19,7 +19,8 @@ import (
 const _ = grpc.SupportPackageIsVersion7
 
 const (
-	User_CheckUser_FullMethodName = "/user.User/CheckUser"
+	User_CheckUser_FullMethodName    = "/user.User/CheckUser"
+	User_RegisterUser_FullMethodName = "/user.User/RegisterUser"
 )
 
 // UserClient is the client API for User service.
@@ -28,6 +29,8 @@ const (
 type UserClient interface {
 	// CheckUser returns whether the username and password are correct
 	CheckUser(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Result, error)
+	// RegisterUser creates a user with a unique username
+	RegisterUser(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResult, error)
 }
 
 type userClient struct {
@@ -47,12 +50,23 @@ func (c *userClient) CheckUser(ctx context.Context, in *Request, opts ...grpc.Ca
 	return out, nil
 }
 
+func (c *userClient) RegisterUser(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResult, error) {
+	out := new(RegisterResult)
+	err := c.cc.Invoke(ctx, User_RegisterUser_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // UserServer is the server API for User service.
 // All implementations must embed UnimplementedUserServer
 // for forward compatibility
 type UserServer interface {
 	// CheckUser returns whether the username and password are correct
 	CheckUser(context.Context, *Request) (*Result, error)
+	// RegisterUser creates a user with a unique username
+	RegisterUser(context.Context, *RegisterRequest) (*RegisterResult, error)
 	mustEmbedUnimplementedUserServer()
 }
 
@@ -63,6 +77,9 @@ type UnimplementedUserServer struct {
 func (UnimplementedUserServer) CheckUser(context.Context, *Request) (*Result, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CheckUser not implemented")
 }
+func (UnimplementedUserServer) RegisterUser(context.Context, *RegisterRequest) (*RegisterResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegisterUser not implemented")
+}
 func (UnimplementedUserServer) mustEmbedUnimplementedUserServer() {}
 
 // UnsafeUserServer may be embedded to opt out of forward compatibility for this service.
@@ -94,6 +111,24 @@ func _User_CheckUser_Handler(srv interface{}, ctx context.Context, dec func(inte
 	return interceptor(ctx, in, info, handler)
 }
 
+func _User_RegisterUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServer).RegisterUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: User_RegisterUser_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServer).RegisterUser(ctx, req.(*RegisterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // User_ServiceDesc is the grpc.ServiceDesc for User service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -105,6 +140,10 @@ var User_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "CheckUser",
 			Handler:    _User_CheckUser_Handler,
 		},
+		{
+			MethodName: "RegisterUser",
+			Handler:    _User_RegisterUser_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "services/user/proto/user.proto",
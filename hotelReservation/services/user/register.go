@@ -0,0 +1,63 @@
+package user
+
+import (
+	"errors"
+	"unicode/utf8"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails"
+	errpb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails/proto"
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/user/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/store"
+	"github.com/opentracing/opentracing-go"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RegisterUser creates a user with a bcrypt hash of the given password and
+// returns its ID. Usernames are unique, enforced by a unique index on the
+// user collection.
+func (s *Server) RegisterUser(ctx context.Context, req *pb.RegisterRequest) (*pb.RegisterResult, error) {
+	log.Trace().Msgf("RegisterUser %s", req.Username)
+
+	if n := utf8.RuneCountInString(req.Password); n < s.MinPasswordLength {
+		return nil, errdetails.InvalidArgument(errpb.ErrorDetail_OUT_OF_RANGE, "password", "password must be at least %d characters, got %d", s.MinPasswordLength, n)
+	}
+
+	hash, err := s.hashPassword(req.Password)
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return nil, errdetails.InvalidArgument(errpb.ErrorDetail_OUT_OF_RANGE, "password", "password must be at most 72 bytes")
+	}
+	if err != nil {
+		log.Error().Msgf("Failed to hash password of user %s: %v", req.Username, err)
+		return nil, status.Errorf(codes.Internal, "failed to hash password")
+	}
+
+	collection := s.DB.Collection("user")
+
+	mongoSpan, _ := opentracing.StartSpanFromContext(ctx, "mongo_insert_user")
+	mongoSpan.SetTag("span.kind", "client")
+	inserted, err := collection.InsertOne(ctx, User{Username: req.Username, Password: hash})
+	mongoSpan.Finish()
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, status.Errorf(codes.AlreadyExists, "username %q is already taken", req.Username)
+	}
+	if err != nil {
+		log.Error().Msgf("Failed to insert user %s: %v", req.Username, err)
+		return nil, store.Error(ctx, err, codes.Internal, "failed to register user %s", req.Username)
+	}
+
+	s.usersMu.Lock()
+	s.users[req.Username] = hash
+	s.usersMu.Unlock()
+
+	res := new(pb.RegisterResult)
+	if id, ok := inserted.InsertedID.(primitive.ObjectID); ok {
+		res.UserId = id.Hex()
+	}
+	return res, nil
+}
@@ -0,0 +1,65 @@
+package user
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/user/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRegisterUser(t *testing.T) {
+	s := newTestServer(t, nil)
+	first, err := s.RegisterUser(context.Background(), &pb.RegisterRequest{Username: "Cornell_1", Password: "correct horse"})
+	if err != nil {
+		t.Fatalf("RegisterUser: %v", err)
+	}
+	second, err := s.RegisterUser(context.Background(), &pb.RegisterRequest{Username: "Cornell_2", Password: "correct horse"})
+	if err != nil {
+		t.Fatalf("RegisterUser: %v", err)
+	}
+	if first.UserId == "" || first.UserId == second.UserId {
+		t.Errorf("got user IDs %q and %q, want two distinct ones", first.UserId, second.UserId)
+	}
+}
+
+func TestRegisterDuplicateUser(t *testing.T) {
+	s := newTestServer(t, map[string]string{"Cornell_1": legacyDigest("1111111111")})
+	_, err := s.RegisterUser(context.Background(), &pb.RegisterRequest{Username: "Cornell_1", Password: "correct horse"})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("got %v, want AlreadyExists", err)
+	}
+	// the existing user keeps its password
+	if !login(t, s, "Cornell_1", "1111111111") {
+		t.Errorf("existing user's password rejected after a duplicate registration")
+	}
+	if login(t, s, "Cornell_1", "correct horse") {
+		t.Errorf("duplicate registration's password accepted")
+	}
+}
+
+func TestRegisterWeakPassword(t *testing.T) {
+	s := newTestServer(t, nil)
+	tests := map[string]string{
+		"empty":            "",
+		"too short":        "1234567",
+		"too short in utf": "ééééééé",
+		"over 72 bytes":    strings.Repeat("a", 73),
+	}
+	for name, password := range tests {
+		_, err := s.RegisterUser(context.Background(), &pb.RegisterRequest{Username: "Cornell_1", Password: password})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%s: got %v, want InvalidArgument", name, err)
+		}
+	}
+	if login(t, s, "Cornell_1", "1234567") {
+		t.Errorf("user registered with a rejected password")
+	}
+
+	// the minimum counts characters, not bytes
+	if _, err := s.RegisterUser(context.Background(), &pb.RegisterRequest{Username: "Cornell_1", Password: "éééééééé"}); err != nil {
+		t.Errorf("password of 8 characters: %v", err)
+	}
+}
@@ -1,39 +1,65 @@
 package user
 
 import (
-	"crypto/sha256"
 	"fmt"
 	"net"
+	"sync"
 	"time"
 
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/graceful"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/healthcheck"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/registry"
 	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/user/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/store"
 	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tls"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
 	"github.com/google/uuid"
-	"github.com/grpc-ecosystem/grpc-opentracing/go/otgrpc"
 	"github.com/opentracing/opentracing-go"
 	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/reflection"
 )
 
 const name = "srv-user"
 
+// validators check requests before their handler runs.
+var validators = map[string]tracing.Validator{
+	pb.User_RegisterUser_FullMethodName: tracing.Required("username"),
+}
+
 // Server implements the user service
 type Server struct {
 	pb.UnimplementedUserServer
 
-	users map[string]string
-	uuid  string
+	users      map[string]string
+	usersMu    sync.RWMutex
+	dummyHash  []byte
+	uuid       string
+	health     *healthcheck.Checker
+	grpcServer *grpc.Server
+	traffic    tracing.MetricsMark
 
 	Tracer      opentracing.Tracer
 	Registry    *registry.Client
 	Port        int
 	IpAddr      string
 	MongoClient *mongo.Client
+	// DB is the user-db database, that of MongoClient if nil.
+	DB store.Database
+	// BcryptCost is the bcrypt cost of new password hashes, bcrypt.DefaultCost
+	// if zero.
+	BcryptCost int
+	// MinPasswordLength is the minimum length in characters of the
+	// passwords of new users.
+	MinPasswordLength int
+	// MetricsPort is the admin port serving /metrics, zero to disable it.
+	MetricsPort int
 }
 
 // Run starts the server
@@ -42,8 +68,26 @@ func (s *Server) Run() error {
 		return fmt.Errorf("server port must be set")
 	}
 
+	if s.DB == nil {
+		s.DB = store.MongoDatabase(s.MongoClient.Database("user-db"))
+	}
+
+	if s.BcryptCost == 0 {
+		s.BcryptCost = bcrypt.DefaultCost
+	}
+	if s.BcryptCost < bcrypt.MinCost || s.BcryptCost > bcrypt.MaxCost {
+		return fmt.Errorf("bcrypt cost must be between %d and %d, got %d", bcrypt.MinCost, bcrypt.MaxCost, s.BcryptCost)
+	}
+
+	// compared against for unknown users, see checkPassword
+	dummyHash, err := bcrypt.GenerateFromPassword([]byte("dummy password"), s.BcryptCost)
+	if err != nil {
+		return fmt.Errorf("failed to generate dummy hash: %v", err)
+	}
+	s.dummyHash = dummyHash
+
 	if s.users == nil {
-		s.users = loadUsers(s.MongoClient)
+		s.users = loadUsers(s.DB)
 	}
 
 	s.uuid = uuid.New().String()
@@ -53,11 +97,19 @@ func (s *Server) Run() error {
 			Timeout: 120 * time.Second,
 		}),
 		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             time.Duration(tune.GetKeepaliveTime()) * time.Millisecond,
 			PermitWithoutStream: true,
 		}),
-		grpc.UnaryInterceptor(
-			otgrpc.OpenTracingServerInterceptor(s.Tracer),
-		),
+		grpc.MaxRecvMsgSize(tune.GetMaxRecvMsgSize()),
+		grpc.MaxSendMsgSize(tune.GetMaxSendMsgSize()),
+		grpc.UnaryInterceptor(tracing.DefaultServerInterceptorChain(tracing.ServerChainOptions{
+			Tracer: s.Tracer,
+			Interceptors: []grpc.UnaryServerInterceptor{
+				tracing.ChaosUnaryServerInterceptor(tracing.DefaultChaos),
+				tracing.RedactingUnaryServerInterceptor("password"),
+				tracing.ValidationUnaryServerInterceptor(validators),
+			},
+		})),
 	}
 
 	if tlsopt := tls.GetServerOpt(); tlsopt != nil {
@@ -65,8 +117,23 @@ func (s *Server) Run() error {
 	}
 
 	srv := grpc.NewServer(opts...)
+	s.grpcServer = srv
+	s.traffic = tracing.DefaultMetrics.Mark()
 
 	pb.RegisterUserServer(srv, s)
+	if tune.GetGrpcReflection() {
+		reflection.Register(srv)
+	}
+
+	s.health = healthcheck.Register(srv, pb.User_ServiceDesc.ServiceName, healthcheck.Mongo(s.MongoClient))
+	s.health.Start()
+
+	if s.MetricsPort != 0 {
+		go tracing.ServeMetrics(s.MetricsPort, tracing.DefaultMetrics)
+	}
+	if path := tune.GetFeatureFlagsFile(); path != "" {
+		go tracing.DefaultFlags.WatchFile(path, time.Duration(tune.GetFeatureFlagsPollInterval())*time.Millisecond)
+	}
 
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", s.Port))
 	if err != nil {
@@ -82,9 +149,13 @@ func (s *Server) Run() error {
 	return srv.Serve(lis)
 }
 
-// Shutdown cleans up any processes
+// Shutdown reports the server NOT_SERVING, deregisters it and drains
+// in-flight RPCs before stopping it, then reports the traffic it served.
 func (s *Server) Shutdown() {
+	s.health.Shutdown()
 	s.Registry.Deregister(s.uuid)
+	graceful.Stop(s.grpcServer)
+	graceful.Report(s.grpcServer, s.traffic)
 }
 
 // CheckUser returns whether the username and password are correct.
@@ -93,31 +164,25 @@ func (s *Server) CheckUser(ctx context.Context, req *pb.Request) (*pb.Result, er
 
 	log.Trace().Msg("CheckUser")
 
-	sum := sha256.Sum256([]byte(req.Password))
-	pass := fmt.Sprintf("%x", sum)
-
-	res.Correct = false
-	if true_pass, found := s.users[req.Username]; found {
-		res.Correct = pass == true_pass
-	}
+	res.Correct = s.checkPassword(req.Username, req.Password)
 
-	log.Trace().Msgf("CheckUser %d", res.Correct)
+	log.Trace().Msgf("CheckUser %t", res.Correct)
 
 	return res, nil
 }
 
 // loadUsers loads hotel users from mongodb.
-func loadUsers(client *mongo.Client) map[string]string {
-	collection := client.Database("user-db").Collection("user")
+func loadUsers(db store.Database) map[string]string {
+	collection := db.Collection("user")
 	curr, err := collection.Find(context.TODO(), bson.D{})
 	if err != nil {
-		log.Error().Msgf("Failed get users data: ", err)
+		log.Error().Msgf("Failed get users data: %v", err)
 	}
 
 	var users []User
 	curr.All(context.TODO(), &users)
 	if err != nil {
-		log.Error().Msgf("Failed get users data: ", err)
+		log.Error().Msgf("Failed get users data: %v", err)
 	}
 
 	res := make(map[string]string)
@@ -0,0 +1,123 @@
+package user
+
+import (
+	"context"
+	"testing"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/inproc/fakestore"
+	pb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/user/proto"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newTestServer returns a server backed by a stand-in of MongoDB holding
+// users, a map of usernames to stored passwords, hashing at the minimum
+// cost.
+func newTestServer(t *testing.T, users map[string]string) *Server {
+	t.Helper()
+	db := fakestore.Mongo(t).Database("user-db")
+	_, err := db.Collection("user").Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "username", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		t.Fatalf("creating the username index: %v", err)
+	}
+	for username, password := range users {
+		if _, err := db.Collection("user").InsertOne(context.Background(), bson.M{"username": username, "password": password}); err != nil {
+			t.Fatalf("inserting user %s: %v", username, err)
+		}
+	}
+
+	dummyHash, err := bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hashing the dummy password: %v", err)
+	}
+	s := &Server{
+		DB:                store.MongoDatabase(db),
+		BcryptCost:        bcrypt.MinCost,
+		MinPasswordLength: 8,
+		dummyHash:         dummyHash,
+	}
+	s.users = loadUsers(s.DB)
+	return s
+}
+
+// login reports whether s accepts password for username.
+func login(t *testing.T, s *Server, username, password string) bool {
+	t.Helper()
+	res, err := s.CheckUser(context.Background(), &pb.Request{Username: username, Password: password})
+	if err != nil {
+		t.Fatalf("CheckUser: %v", err)
+	}
+	return res.Correct
+}
+
+// storedPassword returns the password stored in the database for username.
+func storedPassword(t *testing.T, s *Server, username string) string {
+	t.Helper()
+	var u User
+	if err := s.DB.Collection("user").FindOne(context.Background(), bson.M{"username": username}).Decode(&u); err != nil {
+		t.Fatalf("finding user %s: %v", username, err)
+	}
+	return u.Password
+}
+
+func TestRegisterLoginRoundTrip(t *testing.T) {
+	s := newTestServer(t, nil)
+	if _, err := s.RegisterUser(context.Background(), &pb.RegisterRequest{Username: "Cornell_1", Password: "correct horse"}); err != nil {
+		t.Fatalf("RegisterUser: %v", err)
+	}
+
+	if stored := storedPassword(t, s, "Cornell_1"); isLegacyPassword(stored) || stored == "correct horse" {
+		t.Errorf("stored password %q, want a bcrypt hash", stored)
+	}
+	if !login(t, s, "Cornell_1", "correct horse") {
+		t.Errorf("right password rejected")
+	}
+	if login(t, s, "Cornell_1", "battery staple") {
+		t.Errorf("wrong password accepted")
+	}
+	if login(t, s, "Cornell_2", "correct horse") {
+		t.Errorf("unknown user accepted")
+	}
+
+	// a restarted server loads the hash
+	restarted := &Server{DB: s.DB, dummyHash: s.dummyHash}
+	restarted.users = loadUsers(restarted.DB)
+	if !login(t, restarted, "Cornell_1", "correct horse") {
+		t.Errorf("right password rejected after a restart")
+	}
+}
+
+func TestLegacyPasswordMigration(t *testing.T) {
+	s := newTestServer(t, map[string]string{"Cornell_1": legacyDigest("1111111111")})
+
+	if login(t, s, "Cornell_1", "2222222222") {
+		t.Fatalf("wrong password accepted")
+	}
+	if stored := storedPassword(t, s, "Cornell_1"); !isLegacyPassword(stored) {
+		t.Errorf("legacy record upgraded on a failed login")
+	}
+
+	if !login(t, s, "Cornell_1", "1111111111") {
+		t.Fatalf("right password rejected")
+	}
+	stored := storedPassword(t, s, "Cornell_1")
+	if isLegacyPassword(stored) {
+		t.Fatalf("legacy record not upgraded on a successful login")
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(stored), []byte("1111111111")); err != nil {
+		t.Errorf("upgraded hash doesn't match the password: %v", err)
+	}
+
+	if !login(t, s, "Cornell_1", "1111111111") {
+		t.Errorf("right password rejected after the upgrade")
+	}
+	if login(t, s, "Cornell_1", legacyDigest("1111111111")) {
+		t.Errorf("legacy digest accepted as the password")
+	}
+}
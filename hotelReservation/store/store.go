@@ -0,0 +1,87 @@
+// Package store declares the parts of the MongoDB and memcached clients the
+// services use, so that handlers can be run against fakes. The real clients
+// satisfy them: *memcache.Client is a Memcache, *mongo.Collection a
+// Collection, and MongoDatabase adapts a *mongo.Database.
+//
+// Fakes of Collection can return results built with mongo.NewCursorFromDocuments
+// and mongo.NewSingleResultFromDocument.
+package store
+
+import (
+	"context"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Memcache is a memcached client.
+type Memcache interface {
+	Get(key string) (*memcache.Item, error)
+	GetMulti(keys []string) (map[string]*memcache.Item, error)
+	Set(item *memcache.Item) error
+	Delete(key string) error
+	Ping() error
+}
+
+// Evict deletes keys from mc, returning how many it held. Keys it doesn't
+// hold are skipped, so evicting them again is harmless. It stops at the
+// first other error.
+func Evict(mc Memcache, keys []string) (int, error) {
+	evicted := 0
+	for _, key := range keys {
+		err := mc.Delete(key)
+		if err == memcache.ErrCacheMiss {
+			continue
+		}
+		if err != nil {
+			return evicted, err
+		}
+		evicted++
+	}
+	return evicted, nil
+}
+
+// Database is a MongoDB database.
+type Database interface {
+	Collection(name string) Collection
+}
+
+// Collection is a MongoDB collection.
+type Collection interface {
+	Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (*mongo.Cursor, error)
+	FindOne(ctx context.Context, filter interface{}, opts ...*options.FindOneOptions) *mongo.SingleResult
+	FindOneAndUpdate(ctx context.Context, filter interface{}, update interface{}, opts ...*options.FindOneAndUpdateOptions) *mongo.SingleResult
+	InsertOne(ctx context.Context, document interface{}, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error)
+	InsertMany(ctx context.Context, documents []interface{}, opts ...*options.InsertManyOptions) (*mongo.InsertManyResult, error)
+	UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
+	UpdateMany(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
+	DeleteOne(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error)
+	DeleteMany(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error)
+}
+
+// MongoDatabase returns db as a Database.
+func MongoDatabase(db *mongo.Database) Database {
+	return mongoDatabase{db}
+}
+
+type mongoDatabase struct {
+	db *mongo.Database
+}
+
+func (d mongoDatabase) Collection(name string) Collection {
+	return d.db.Collection(name)
+}
+
+// Error returns the status of a request whose database operation failed with
+// err: Canceled or DeadlineExceeded if ctx, the context of the request and
+// of the operation, is done, which aborted the operation, and code with the
+// formatted message otherwise.
+func Error(ctx context.Context, err error, code codes.Code, format string, a ...interface{}) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return status.FromContextError(ctxErr).Err()
+	}
+	return status.Errorf(code, format+": %v", append(a, err)...)
+}
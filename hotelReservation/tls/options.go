@@ -3,6 +3,7 @@ package tls
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"strings"
@@ -77,46 +78,141 @@ func checkTLS() (bool, string) {
 	return false, ""
 }
 
-func init() {
-	needTLS, cipher := checkTLS()
-	if needTLS {
-		b, err := ioutil.ReadFile("x509/ca_cert.pem")
+// Config locates the certificates TLS is set up with.
+type Config struct {
+	// CA is the PEM file of the authority that signed the server
+	// certificate, and the client one.
+	CA string
+	// Cert and Key are the PEM files of the certificate and key the servers
+	// present.
+	Cert string
+	Key  string
+	// ClientCert and ClientKey are the PEM files of the certificate and key
+	// the clients present, both or neither. They turn on mutual
+	// authentication: servers then reject clients without a certificate
+	// signed by CA.
+	ClientCert string
+	ClientKey  string
+	// ServerName is the name clients check the server certificate for.
+	ServerName string
+	// CipherSuite restricts clients and the HTTPS frontend to one of
+	// cipherSuites, any if empty.
+	CipherSuite string
+}
+
+// Mutual reports whether c sets up mutual authentication.
+func (c Config) Mutual() bool {
+	return c.ClientCert != "" || c.ClientKey != ""
+}
+
+// configFromEnv returns the Config set by TLS_CA_CERT, TLS_SERVER_CERT,
+// TLS_SERVER_KEY, TLS_CLIENT_CERT, TLS_CLIENT_KEY and TLS_SERVER_NAME, the
+// certificates of x509 by default, without mutual authentication.
+func configFromEnv(cipher string) Config {
+	env := func(key, def string) string {
+		if val, ok := os.LookupEnv(key); ok {
+			return val
+		}
+		return def
+	}
+	return Config{
+		CA:          env("TLS_CA_CERT", "x509/ca_cert.pem"),
+		Cert:        env("TLS_SERVER_CERT", "x509/server_cert.pem"),
+		Key:         env("TLS_SERVER_KEY", "x509/server_key.pem"),
+		ClientCert:  env("TLS_CLIENT_CERT", ""),
+		ClientKey:   env("TLS_CLIENT_KEY", ""),
+		ServerName:  env("TLS_SERVER_NAME", "x.test.example.com"),
+		CipherSuite: cipher,
+	}
+}
+
+// Credentials are the TLS settings of the gRPC servers and clients and of
+// the HTTPS frontend.
+type Credentials struct {
+	Server credentials.TransportCredentials
+	Client credentials.TransportCredentials
+	HTTPS  *tls.Config
+}
+
+// Load reads the certificates of c, failing if any is missing or invalid.
+func Load(c Config) (*Credentials, error) {
+	b, err := ioutil.ReadFile(c.CA)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the CA certificate: %v", err)
+	}
+	cp := x509.NewCertPool()
+	if !cp.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("no PEM certificate in CA certificate %s", c.CA)
+	}
+	cert, err := tls.LoadX509KeyPair(c.Cert, c.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the server certificate %s and key %s: %v", c.Cert, c.Key, err)
+	}
+
+	client := &tls.Config{
+		ServerName: c.ServerName,
+		RootCAs:    cp,
+	}
+	server := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	}
+	https := &tls.Config{
+		PreferServerCipherSuites: true,
+		RootCAs:                  cp,
+		Certificates:             []tls.Certificate{cert},
+	}
+	if c.Mutual() {
+		if c.ClientCert == "" || c.ClientKey == "" {
+			return nil, fmt.Errorf("mutual TLS needs both a client certificate and key, got certificate %q and key %q", c.ClientCert, c.ClientKey)
+		}
+		clientCert, err := tls.LoadX509KeyPair(c.ClientCert, c.ClientKey)
 		if err != nil {
-			log.Panic().Msgf("failed to read credentials: %v", err)
+			return nil, fmt.Errorf("failed to load the client certificate %s and key %s: %v", c.ClientCert, c.ClientKey, err)
 		}
-		cp := x509.NewCertPool()
-		if !cp.AppendCertsFromPEM(b) {
-			log.Panic().Msgf("credentials: failed to append certificates")
+		client.Certificates = []tls.Certificate{clientCert}
+		server.ClientAuth = tls.RequireAndVerifyClientCert
+		server.ClientCAs = cp
+	}
+	if c.CipherSuite != "" {
+		suite, ok := cipherSuites[c.CipherSuite]
+		if !ok {
+			return nil, fmt.Errorf("unknown cipher suite %s", c.CipherSuite)
 		}
-		config := tls.Config{
-			ServerName: "x.test.example.com",
-			RootCAs:    cp,
+		client.CipherSuites = append(client.CipherSuites, suite)
+		https.CipherSuites = append(https.CipherSuites, suite)
+		switch c.CipherSuite {
+		case "TLS_AES_128_GCM_SHA256", "TLS_AES_256_GCM_SHA384", "TLS_CHACHA20_POLY1305_SHA256":
+			https.MinVersion = tls.VersionTLS13
 		}
-		httpsopt = &tls.Config{
-			PreferServerCipherSuites: true,
-			RootCAs:                  cp,
+	}
+
+	return &Credentials{
+		Server: credentials.NewTLS(server),
+		Client: credentials.NewTLS(client),
+		HTTPS:  https,
+	}, nil
+}
+
+func init() {
+	needTLS, cipher := checkTLS()
+	if needTLS {
+		config := configFromEnv(cipher)
+		creds, err := Load(config)
+		if err != nil {
+			log.Panic().Msgf("TLS: %v", err)
 		}
 		if cipher != "" {
 			log.Info().Msgf("TLS enabled cipher suite %s", cipher)
-			config.CipherSuites = append(config.CipherSuites, cipherSuites[cipher])
-			httpsopt.CipherSuites = append(httpsopt.CipherSuites, cipherSuites[cipher])
-			switch cipher {
-			case "TLS_AES_128_GCM_SHA256", "TLS_AES_256_GCM_SHA384", "TLS_CHACHA20_POLY1305_SHA256":
-				httpsopt.MinVersion = tls.VersionTLS13
-			}
 		} else {
 			log.Info().Msgf("TLS enabled without specified cipher suite")
 		}
-
-		var creds credentials.TransportCredentials
-		creds = credentials.NewTLS(&config)
-		dialopt = grpc.WithTransportCredentials(creds)
-
-		creds, err = credentials.NewServerTLSFromFile("x509/server_cert.pem", "x509/server_key.pem")
-		if err != nil {
-			log.Panic().Msgf("failed to create credentials: %v", err)
+		if config.Mutual() {
+			log.Info().Msgf("TLS mutual authentication enabled")
 		}
-		serveropt = grpc.Creds(creds)
+
+		dialopt = grpc.WithTransportCredentials(creds.Client)
+		serveropt = grpc.Creds(creds.Server)
+		httpsopt = creds.HTTPS
 	} else {
 		log.Info().Msgf("TLS disabled.")
 		dialopt = nil
@@ -132,6 +228,8 @@ func GetServerOpt() grpc.ServerOption {
 	return serveropt
 }
 
+// GetHttpsOpt returns the TLS config of the HTTPS frontend, with the server
+// certificate, nil if TLS is disabled.
 func GetHttpsOpt() *tls.Config {
 	return httpsopt
 }
@@ -0,0 +1,250 @@
+package tls
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
+)
+
+// authority is a test CA writing the certificates it signs to dir.
+type authority struct {
+	t    *testing.T
+	dir  string
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	// CA is the PEM file of its certificate.
+	CA string
+}
+
+var serial int64
+
+// newAuthority returns a self-signed CA named name.
+func newAuthority(t *testing.T, name string) *authority {
+	t.Helper()
+	ca := &authority{t: t, dir: t.TempDir()}
+	ca.cert, ca.key, ca.CA = ca.issue(name, &x509.Certificate{
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	})
+	return ca
+}
+
+// issue signs a certificate of template named name, self-signed if ca has
+// no certificate yet, and writes it to a PEM file, whose path it returns.
+func (ca *authority) issue(name string, template *x509.Certificate) (*x509.Certificate, *ecdsa.PrivateKey, string) {
+	ca.t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		ca.t.Fatalf("generating key: %v", err)
+	}
+	serial++
+	template.SerialNumber = big.NewInt(serial)
+	template.Subject = pkix.Name{CommonName: name}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	parent, signer := template, key
+	if ca.cert != nil {
+		parent, signer = ca.cert, ca.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	if err != nil {
+		ca.t.Fatalf("signing %s: %v", name, err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		ca.t.Fatalf("parsing %s: %v", name, err)
+	}
+	path := filepath.Join(ca.dir, name+"_cert.pem")
+	ca.write(path, "CERTIFICATE", der)
+	return cert, key, path
+}
+
+func (ca *authority) write(path, typ string, der []byte) {
+	ca.t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+		ca.t.Fatalf("writing %s: %v", path, err)
+	}
+}
+
+// keyPair issues a certificate for name with usage, returning the PEM files
+// of the certificate and its key.
+func (ca *authority) keyPair(name string, usage x509.ExtKeyUsage) (string, string) {
+	ca.t.Helper()
+	_, key, cert := ca.issue(name, &x509.Certificate{
+		DNSNames:    []string{name},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{usage},
+	})
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		ca.t.Fatalf("marshalling key: %v", err)
+	}
+	path := filepath.Join(ca.dir, name+"_key.pem")
+	ca.write(path, "EC PRIVATE KEY", der)
+	return cert, path
+}
+
+// config returns the config of servers named server, and of clients
+// presenting the certificate of client if not empty.
+func (ca *authority) config(server, client string) Config {
+	c := Config{CA: ca.CA, ServerName: server}
+	c.Cert, c.Key = ca.keyPair(server, x509.ExtKeyUsageServerAuth)
+	if client != "" {
+		c.ClientCert, c.ClientKey = ca.keyPair(client, x509.ExtKeyUsageClientAuth)
+	}
+	return c
+}
+
+// load loads c.
+func load(t *testing.T, c Config) *Credentials {
+	t.Helper()
+	creds, err := Load(c)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return creds
+}
+
+// serveHealth serves the health service with creds, returning its address
+// and the names of the clients it saw.
+func serveHealth(t *testing.T, creds credentials.TransportCredentials) (string, chan string) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	clients := make(chan string, 1)
+	srv := grpc.NewServer(grpc.Creds(creds), grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		name := ""
+		if p, ok := peer.FromContext(ctx); ok {
+			if info, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(info.State.PeerCertificates) > 0 {
+				name = info.State.PeerCertificates[0].Subject.CommonName
+			}
+		}
+		clients <- name
+		return handler(ctx, req)
+	}))
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return lis.Addr().String(), clients
+}
+
+// check checks the health of addr with creds.
+func check(t *testing.T, addr string, creds credentials.TransportCredentials) error {
+	t.Helper()
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	return err
+}
+
+func TestMutualTLS(t *testing.T) {
+	ca := newAuthority(t, "ca")
+	creds := load(t, ca.config("x.test.example.com", "frontend"))
+	addr, clients := serveHealth(t, creds.Server)
+
+	if err := check(t, addr, creds.Client); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if name := <-clients; name != "frontend" {
+		t.Errorf("server saw client %q, want frontend", name)
+	}
+}
+
+func TestMutualTLSUntrustedClient(t *testing.T) {
+	ca := newAuthority(t, "ca")
+	server := load(t, ca.config("x.test.example.com", "frontend"))
+	addr, _ := serveHealth(t, server.Server)
+
+	// a client certificate of another authority
+	other := newAuthority(t, "other")
+	untrusted := ca.config("x.test.example.com", "")
+	untrusted.ClientCert, untrusted.ClientKey = other.keyPair("intruder", x509.ExtKeyUsageClientAuth)
+	if err := check(t, addr, load(t, untrusted).Client); err == nil {
+		t.Error("Check with an untrusted client certificate succeeded")
+	}
+
+	// or none
+	if err := check(t, addr, load(t, ca.config("x.test.example.com", "")).Client); err == nil {
+		t.Error("Check without a client certificate succeeded")
+	}
+}
+
+func TestServerTLS(t *testing.T) {
+	ca := newAuthority(t, "ca")
+	creds := load(t, ca.config("x.test.example.com", ""))
+	addr, clients := serveHealth(t, creds.Server)
+
+	if err := check(t, addr, creds.Client); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if name := <-clients; name != "" {
+		t.Errorf("server saw client %q, want none", name)
+	}
+
+	// clients check the server name
+	wrongName := ca.config("x.test.example.com", "")
+	wrongName.ServerName = "other.example.com"
+	if err := check(t, addr, load(t, wrongName).Client); err == nil {
+		t.Error("Check of a server of another name succeeded")
+	}
+}
+
+func TestLoadFailures(t *testing.T) {
+	ca := newAuthority(t, "ca")
+	good := ca.config("x.test.example.com", "frontend")
+	notPEM := filepath.Join(t.TempDir(), "not.pem")
+	os.WriteFile(notPEM, []byte("not a certificate"), 0o600)
+
+	for _, tt := range []struct {
+		name   string
+		change func(c *Config)
+		want   string
+	}{
+		{"missing CA", func(c *Config) { c.CA = notPEM + ".missing" }, "failed to read the CA certificate"},
+		{"CA not PEM", func(c *Config) { c.CA = notPEM }, "no PEM certificate"},
+		{"server key of the client", func(c *Config) { c.Key = c.ClientKey }, "failed to load the server certificate"},
+		{"client certificate only", func(c *Config) { c.ClientKey = "" }, "needs both a client certificate and key"},
+		{"invalid client key", func(c *Config) { c.ClientKey = notPEM }, "failed to load the client certificate"},
+		{"unknown cipher suite", func(c *Config) { c.CipherSuite = "TLS_NONE" }, "unknown cipher suite"},
+	} {
+		c := good
+		tt.change(&c)
+		if _, err := Load(c); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want an error of %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestPlaintextByDefault(t *testing.T) {
+	if os.Getenv("TLS") != "" {
+		t.Skip("TLS is set")
+	}
+	if GetDialOpt() != nil || GetServerOpt() != nil || GetHttpsOpt() != nil {
+		t.Error("TLS set up without TLS")
+	}
+}
@@ -0,0 +1,165 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultAccessLog samples the requests logged by
+// AccessLogUnaryServerInterceptor(DefaultAccessLog), one in every
+// DefaultAccessLogEvery successful ones. It is served at /accesslog on the
+// admin port.
+var DefaultAccessLog = NewAccessLog(DefaultAccessLogEvery, nil)
+
+// DefaultAccessLogEvery is the sampling of DefaultAccessLog.
+const DefaultAccessLogEvery = 100
+
+// AccessLogSampling logs one in every Every successful requests, or one in
+// every Methods[m] for the methods m it has, keyed by full method name
+// (e.g. "/geo.Geo/Nearby"). 1 logs them all and 0 or less none.
+type AccessLogSampling struct {
+	Every   int            `json:"every"`
+	Methods map[string]int `json:"methods,omitempty"`
+}
+
+// AccessLog decides which requests get an access log line: every failed
+// one, and the successful ones its sampling picks. Sampling is head-based:
+// whether a request is picked depends on its position among those of its
+// method, counted as they start, not on its outcome. It serves its sampling
+// as JSON and replaces it with a PUT of the same JSON. It is safe for
+// concurrent use, and its sampling can be changed while serving.
+type AccessLog struct {
+	mu       sync.RWMutex
+	sampling AccessLogSampling
+	counts   map[string]*uint64
+}
+
+// NewAccessLog returns an access log logging one in every every successful
+// requests, or one in every perMethod[m] for the methods m it has.
+func NewAccessLog(every int, perMethod map[string]int) *AccessLog {
+	l := &AccessLog{counts: make(map[string]*uint64)}
+	l.SetSampling(AccessLogSampling{Every: every, Methods: perMethod})
+	return l
+}
+
+// Sampling returns a copy of the sampling of l.
+func (l *AccessLog) Sampling() AccessLogSampling {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return copySampling(l.sampling)
+}
+
+// SetSampling replaces the sampling of l.
+func (l *AccessLog) SetSampling(s AccessLogSampling) {
+	s = copySampling(s)
+	l.mu.Lock()
+	l.sampling = s
+	l.mu.Unlock()
+}
+
+func copySampling(s AccessLogSampling) AccessLogSampling {
+	c := AccessLogSampling{Every: s.Every}
+	if len(s.Methods) > 0 {
+		c.Methods = make(map[string]int, len(s.Methods))
+		for m, n := range s.Methods {
+			c.Methods[m] = n
+		}
+	}
+	return c
+}
+
+// sample counts a request to method and reports whether it is picked.
+func (l *AccessLog) sample(method string) bool {
+	l.mu.RLock()
+	every, ok := l.sampling.Methods[method]
+	if !ok {
+		every = l.sampling.Every
+	}
+	count := l.counts[method]
+	l.mu.RUnlock()
+
+	if every <= 0 {
+		return false
+	}
+	if count == nil {
+		l.mu.Lock()
+		if count = l.counts[method]; count == nil {
+			count = new(uint64)
+			l.counts[method] = count
+		}
+		l.mu.Unlock()
+	}
+	return (atomic.AddUint64(count, 1)-1)%uint64(every) == 0
+}
+
+// ServeHTTP serves the sampling on GET and replaces it on PUT.
+func (l *AccessLog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var s AccessLogSampling
+		if err := json.Unmarshal(body, &s); err != nil {
+			http.Error(w, fmt.Sprintf("malformed sampling: %v", err), http.StatusBadRequest)
+			return
+		}
+		l.SetSampling(s)
+		Logger(r.Context()).Info().Msgf("Access log: sampling 1 in %d, per method %v", s.Every, s.Methods)
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "Use GET or PUT", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(l.Sampling())
+}
+
+// AccessLogUnaryServerInterceptor returns a server interceptor writing one
+// structured line per request picked by l, with its method, status code,
+// latency, request and response sizes and request ID. Successes are logged
+// at info level, failures at warn level and never sampled out.
+func AccessLogUnaryServerInterceptor(l *AccessLog) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		sampled := l.sample(info.FullMethod)
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		latency := time.Since(start)
+		if err == nil && !sampled {
+			return resp, err
+		}
+
+		code := status.Code(err)
+		event := Logger(ctx).Info()
+		if code != codes.OK {
+			event = Logger(ctx).Warn()
+		}
+		event = event.
+			Str("method", info.FullMethod).
+			Str("code", code.String()).
+			Dur("latency", latency).
+			Int("request_size", messageSize(req)).
+			Int("response_size", messageSize(resp)).
+			Bool("sampled", sampled)
+		// the logger of ctx has the request ID already if it is in ctx
+		if RequestIDFromContext(ctx) == "" {
+			event = event.Str("request_id", incomingRequestID(ctx))
+		}
+		event.Msgf("%s: %s in %.3fms", info.FullMethod, code, millis(latency))
+		return resp, err
+	}
+}
@@ -0,0 +1,147 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// accessLines decodes the access log lines of logs.
+func accessLines(t *testing.T, logs *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var lines []map[string]interface{}
+	dec := json.NewDecoder(logs)
+	for dec.More() {
+		var line map[string]interface{}
+		if err := dec.Decode(&line); err != nil {
+			t.Fatalf("decoding %s: %v", logs, err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// callLogged calls method through the access log interceptor of l with a
+// handler failing with err.
+func callLogged(ctx context.Context, l *AccessLog, method string, err error) {
+	AccessLogUnaryServerInterceptor(l)(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, err
+	})
+}
+
+func TestAccessLogSampling(t *testing.T) {
+	logs := captureLog(t, zerolog.InfoLevel)
+	l := NewAccessLog(10, map[string]int{"/geo.Geo/Nearby": 4, "/rate.Rate/GetRates": 0})
+	for i := 0; i < 100; i++ {
+		for _, method := range []string{testInfo.FullMethod, "/geo.Geo/Nearby", "/rate.Rate/GetRates"} {
+			callLogged(context.Background(), l, method, nil)
+		}
+	}
+
+	counts := map[string]int{}
+	for _, line := range accessLines(t, logs) {
+		if line["level"] != "info" || line["code"] != "OK" || line["sampled"] != true {
+			t.Errorf("got %v, want a sampled success at info level", line)
+		}
+		counts[line["method"].(string)]++
+	}
+	if counts[testInfo.FullMethod] != 10 || counts["/geo.Geo/Nearby"] != 25 || counts["/rate.Rate/GetRates"] != 0 {
+		t.Errorf("logged %v of 100 calls each, want 1 in 10, 1 in 4 of Nearby and none of GetRates", counts)
+	}
+}
+
+func TestAccessLogErrorsAlwaysLogged(t *testing.T) {
+	logs := captureLog(t, zerolog.InfoLevel)
+	l := NewAccessLog(0, nil)
+	for i := 0; i < 20; i++ {
+		callLogged(context.Background(), l, testInfo.FullMethod, status.Error(codes.Unavailable, "down"))
+		callLogged(context.Background(), l, testInfo.FullMethod, nil)
+	}
+	callLogged(context.Background(), l, testInfo.FullMethod, errors.New("not a status"))
+
+	lines := accessLines(t, logs)
+	if len(lines) != 21 {
+		t.Fatalf("logged %d lines, want the 21 errors", len(lines))
+	}
+	for _, line := range lines[:20] {
+		if line["level"] != "warn" || line["code"] != "Unavailable" || line["sampled"] != false {
+			t.Errorf("got %v, want an unsampled Unavailable at warn level", line)
+		}
+	}
+	if lines[20]["code"] != "Unknown" {
+		t.Errorf("got %v, want an Unknown error", lines[20])
+	}
+}
+
+func TestAccessLogLine(t *testing.T) {
+	l := NewAccessLog(1, nil)
+	for _, ctx := range []context.Context{
+		metadata.NewIncomingContext(context.Background(), metadata.Pairs(RequestIDKey, "req-1")),
+		ContextWithRequestID(context.Background(), "req-1"),
+	} {
+		logs := captureLog(t, zerolog.InfoLevel)
+		callLogged(ctx, l, testInfo.FullMethod, nil)
+		logged := logs.String()
+		lines := accessLines(t, logs)
+		if len(lines) != 1 {
+			t.Fatalf("logged %d lines, want 1", len(lines))
+		}
+		line := lines[0]
+		for _, field := range []string{"latency", "request_size", "response_size"} {
+			if _, ok := line[field]; !ok {
+				t.Errorf("no %s in %v", field, line)
+			}
+		}
+		if line["method"] != testInfo.FullMethod || line["request_id"] != "req-1" {
+			t.Errorf("got %v, want the method and request ID", line)
+		}
+		if n := strings.Count(logged, `"request_id"`); n != 1 {
+			t.Errorf("request ID logged %d times in %s, want once", n, logged)
+		}
+	}
+}
+
+func TestAccessLogServeHTTP(t *testing.T) {
+	logs := captureLog(t, zerolog.InfoLevel)
+	l := NewAccessLog(100, nil)
+
+	w := httptest.NewRecorder()
+	l.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/accesslog", strings.NewReader(`{"every": 1, "methods": {"/geo.Geo/Nearby": 0}}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT: got status %d: %s", w.Code, w.Body)
+	}
+	var s AccessLogSampling
+	if err := json.Unmarshal(w.Body.Bytes(), &s); err != nil || s.Every != 1 || s.Methods["/geo.Geo/Nearby"] != 0 || len(s.Methods) != 1 {
+		t.Errorf("PUT: got %s, %v, want the sampling put", w.Body, err)
+	}
+
+	// the new sampling applies at once
+	logs.Reset()
+	callLogged(context.Background(), l, testInfo.FullMethod, nil)
+	callLogged(context.Background(), l, "/geo.Geo/Nearby", nil)
+	if lines := accessLines(t, logs); len(lines) != 1 || lines[0]["method"] != testInfo.FullMethod {
+		t.Errorf("logged %v, want the call of %s only", lines, testInfo.FullMethod)
+	}
+
+	w = httptest.NewRecorder()
+	l.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/accesslog", strings.NewReader(`not json`)))
+	if w.Code != http.StatusBadRequest || l.Sampling().Every != 1 {
+		t.Errorf("malformed PUT: got status %d and sampling %+v, want 400 keeping the sampling", w.Code, l.Sampling())
+	}
+	w = httptest.NewRecorder()
+	l.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/accesslog", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST: got status %d, want 405", w.Code)
+	}
+}
@@ -0,0 +1,34 @@
+package tracing
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// AdminUnaryServerInterceptor returns a server interceptor restricting
+// methods, given by full method name (e.g. "/geo.Geo/IndexReload"), to
+// callers whose address is in allowed, given as IPs or CIDRs. Others fail
+// with PermissionDenied, and an empty allowlist denies everyone. Methods
+// absent from methods are open to all.
+func AdminUnaryServerInterceptor(allowed []string, methods ...string) (grpc.UnaryServerInterceptor, error) {
+	nets, err := parseAllowlist(allowed)
+	if err != nil {
+		return nil, err
+	}
+	admin := make(map[string]bool, len(methods))
+	for _, m := range methods {
+		admin[m] = true
+	}
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !admin[info.FullMethod] || peerAllowed(ctx, nets) {
+			return handler(ctx, req)
+		}
+
+		Logger(ctx).Warn().Msgf("%s: rejecting admin request from a caller not allowlisted", info.FullMethod)
+		return nil, status.Errorf(codes.PermissionDenied, "%s: caller is not allowed to call admin methods", info.FullMethod)
+	}, nil
+}
@@ -0,0 +1,159 @@
+package tracing
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing/otel"
+	"github.com/grpc-ecosystem/grpc-opentracing/go/otgrpc"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+	otelapi "go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otjaeger "go.opentelemetry.io/otel/exporters/jaeger"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+)
+
+// Backend names the tracing API the spans of the RPCs are reported to.
+type Backend string
+
+const (
+	// OpenTracing starts the spans with the Jaeger client returned by Init.
+	// It is the default.
+	OpenTracing Backend = "opentracing"
+	// OpenTelemetry starts the spans with the OpenTelemetry SDK set up by
+	// Init, exporting them to the same Jaeger.
+	OpenTelemetry Backend = "otel"
+)
+
+// instrumentationName names the OpenTelemetry tracer of the interceptors.
+const instrumentationName = "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing"
+
+// ParseBackend returns the backend named s, OpenTracing if s is empty.
+func ParseBackend(s string) (Backend, error) {
+	switch b := Backend(s); b {
+	case "":
+		return OpenTracing, nil
+	case OpenTracing, OpenTelemetry:
+		return b, nil
+	default:
+		return "", fmt.Errorf("unknown tracing backend %q, valid backends are: %s, %s", s, OpenTracing, OpenTelemetry)
+	}
+}
+
+// BackendFromEnv returns the backend named by the TRACING_BACKEND environment
+// variable, OpenTracing if it is unset or invalid. Init fails on an invalid
+// one, so that a service never runs with a backend other than the one asked
+// for.
+func BackendFromEnv() Backend {
+	b, err := ParseBackend(os.Getenv("TRACING_BACKEND"))
+	if err != nil {
+		return OpenTracing
+	}
+	return b
+}
+
+// otelTracer returns the tracer of the interceptors from tp, the global
+// provider set by Init if tp is nil.
+func otelTracer(tp trace.TracerProvider) trace.Tracer {
+	if tp == nil {
+		tp = otelapi.GetTracerProvider()
+	}
+	return tp.Tracer(instrumentationName)
+}
+
+// otelSampler returns the OpenTelemetry equivalent of sampler, respecting
+// the sampling decision of the caller.
+func otelSampler(sampler Sampler) (sdktrace.Sampler, error) {
+	switch sampler.Type {
+	case jaeger.SamplerTypeConst:
+		if sampler.Param == 0 {
+			return sdktrace.ParentBased(sdktrace.NeverSample()), nil
+		}
+		return sdktrace.ParentBased(sdktrace.AlwaysSample()), nil
+	case jaeger.SamplerTypeProbabilistic:
+		return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampler.Param)), nil
+	default:
+		return nil, fmt.Errorf("%s sampler is not supported by the %s tracing backend", sampler.Type, OpenTelemetry)
+	}
+}
+
+// initOTel sets the global OpenTelemetry tracer provider to one exporting
+// the spans of serviceName, sampled by sampler, as reporter would.
+func initOTel(serviceName string, sampler Sampler, reporter Reporter) error {
+	s, err := otelSampler(sampler)
+	if err != nil {
+		return err
+	}
+	endpoint := otjaeger.WithCollectorEndpoint(otjaeger.WithEndpoint(reporter.CollectorEndpoint))
+	if reporter.Mode != CollectorReporter {
+		host, port, err := net.SplitHostPort(reporter.AgentHostPort)
+		if err != nil {
+			return fmt.Errorf("invalid Jaeger agent address %q: %v", reporter.AgentHostPort, err)
+		}
+		endpoint = otjaeger.WithAgentEndpoint(otjaeger.WithAgentHost(host), otjaeger.WithAgentPort(port))
+	}
+	exporter, err := otjaeger.New(endpoint)
+	if err != nil {
+		return err
+	}
+	otelapi.SetTracerProvider(sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(s),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	))
+	return nil
+}
+
+// serverSpanInterceptors returns the interceptors starting the span of each
+// RPC with backend b, named after its OperationName, none if the
+// OpenTracing backend has no tracer.
+func serverSpanInterceptors(opts ServerChainOptions, b Backend) []grpc.UnaryServerInterceptor {
+	if b == OpenTelemetry {
+		return []grpc.UnaryServerInterceptor{otel.ServerUnaryServerInterceptor(otelTracer(opts.TracerProvider), OperationName)}
+	}
+	if opts.Tracer == nil {
+		return nil
+	}
+	return []grpc.UnaryServerInterceptor{otgrpc.OpenTracingServerInterceptor(opts.Tracer), SpanNamingUnaryServerInterceptor}
+}
+
+// recoveryInterceptor returns the recovery interceptor tagging the spans of
+// backend b with the panics.
+func recoveryInterceptor(b Backend) grpc.UnaryServerInterceptor {
+	if b == OpenTelemetry {
+		return otel.RecoveryUnaryServerInterceptor
+	}
+	return RecoveryUnaryServerInterceptor
+}
+
+// otelTaggingInterceptors returns the interceptors setting the attributes of
+// the OpenTelemetry span, to run alongside the tagging interceptors of this
+// package with backend b, none for OpenTracing.
+func otelTaggingInterceptors(b Backend) []grpc.UnaryServerInterceptor {
+	if b != OpenTelemetry {
+		return nil
+	}
+	return []grpc.UnaryServerInterceptor{
+		otel.StatusTaggingUnaryServerInterceptor,
+		otel.LatencyTaggingUnaryServerInterceptor,
+		otel.SizeTaggingUnaryServerInterceptor,
+	}
+}
+
+// ClientTracingInterceptors returns the client interceptors starting the
+// span of each outgoing RPC with the backend of BackendFromEnv: tracer for
+// OpenTracing, the tracer provider set by Init for OpenTelemetry.
+func ClientTracingInterceptors(tracer opentracing.Tracer) []grpc.UnaryClientInterceptor {
+	if BackendFromEnv() == OpenTelemetry {
+		return []grpc.UnaryClientInterceptor{
+			otel.ClientUnaryClientInterceptor(otelTracer(nil), OperationName),
+			otel.SizeTaggingUnaryClientInterceptor,
+		}
+	}
+	return []grpc.UnaryClientInterceptor{otgrpc.OpenTracingClientInterceptor(tracer)}
+}
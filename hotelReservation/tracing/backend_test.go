@@ -0,0 +1,130 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/rs/zerolog"
+	otelapi "go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseBackend(t *testing.T) {
+	for _, tt := range []struct {
+		s    string
+		want Backend
+	}{
+		{"", OpenTracing},
+		{"opentracing", OpenTracing},
+		{"otel", OpenTelemetry},
+	} {
+		if got, err := ParseBackend(tt.s); err != nil || got != tt.want {
+			t.Errorf("ParseBackend(%q) = %q, %v, want %q", tt.s, got, err, tt.want)
+		}
+	}
+	if _, err := ParseBackend("zipkin"); err == nil {
+		t.Error("ParseBackend(zipkin) succeeded")
+	}
+
+	t.Setenv("TRACING_BACKEND", "zipkin")
+	if b := BackendFromEnv(); b != OpenTracing {
+		t.Errorf("BackendFromEnv with an invalid backend = %q, want opentracing", b)
+	}
+	if _, err := Init("test", "localhost:6831"); err == nil {
+		t.Error("Init with an invalid backend succeeded")
+	}
+}
+
+func TestInitOpenTelemetry(t *testing.T) {
+	t.Setenv("TRACING_BACKEND", "otel")
+	defer otelapi.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	tracer, err := InitWithSampler("test", "localhost:6831", Sampler{Type: "const", Param: 1})
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if _, ok := tracer.(opentracing.NoopTracer); !ok {
+		t.Errorf("Init returned a %T, want the no-op OpenTracing tracer", tracer)
+	}
+	tp, ok := otelapi.GetTracerProvider().(*sdktrace.TracerProvider)
+	if !ok {
+		t.Fatalf("global tracer provider %T, want the SDK's", otelapi.GetTracerProvider())
+	}
+	tp.Shutdown(context.Background())
+
+	// OpenTelemetry has no rate limiting sampler
+	if _, err := InitWithSampler("test", "localhost:6831", Sampler{Type: "ratelimiting", Param: 10}); err == nil {
+		t.Error("Init with a ratelimiting sampler succeeded")
+	}
+}
+
+func TestDefaultServerInterceptorChainOpenTelemetry(t *testing.T) {
+	captureLog(t, zerolog.ErrorLevel)
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	client := serveHealth(t, serving, grpc.UnaryInterceptor(DefaultServerInterceptorChain(ServerChainOptions{
+		Backend:        OpenTelemetry,
+		TracerProvider: tp,
+		Metrics:        NewMetricsRegistry(),
+	})))
+	if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "geo"}); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	span := spans[0]
+	if span.Name != "v1.Health/Check" || span.SpanKind != trace.SpanKindServer {
+		t.Errorf("got %s span %q, want server span v1.Health/Check", span.SpanKind, span.Name)
+	}
+	attrs := make(map[string]bool)
+	for _, kv := range span.Attributes {
+		attrs[string(kv.Key)] = true
+	}
+	for _, key := range []string{"grpc.status_code", "grpc.handler.latency_ms", "grpc.request.size", "grpc.response.size"} {
+		if !attrs[key] {
+			t.Errorf("span has no attribute %s: %v", key, span.Attributes)
+		}
+	}
+}
+
+func TestDefaultServerInterceptorChainOpenTelemetryRecovery(t *testing.T) {
+	captureLog(t, zerolog.Disabled)
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	client := serveHealth(t, func(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+		panic("bad request")
+	}, grpc.UnaryInterceptor(DefaultServerInterceptorChain(ServerChainOptions{
+		Backend:        OpenTelemetry,
+		TracerProvider: tp,
+		Metrics:        NewMetricsRegistry(),
+	})))
+	if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}); status.Code(err) != codes.Internal {
+		t.Fatalf("Check: got %v, want Internal", err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	attrs := make(map[string]string)
+	for _, kv := range spans[0].Attributes {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attrs["error"] != "true" {
+		t.Errorf("span not tagged error: %v", spans[0].Attributes)
+	}
+	if !strings.Contains(attrs["panic.stack"], "TestDefaultServerInterceptorChainOpenTelemetryRecovery") {
+		t.Errorf("panic.stack = %q, want the stack of the panic", attrs["panic.stack"])
+	}
+}
@@ -0,0 +1,143 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// BreakerState is the state of a circuit breaker.
+type BreakerState int
+
+const (
+	// BreakerClosed lets calls through, counting consecutive failures.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen fails calls right away until the cooldown is over.
+	BreakerOpen
+	// BreakerHalfOpen lets a single probe call through to decide whether to
+	// close or open again.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// breaker is the circuit breaker of one target method.
+type breaker struct {
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+}
+
+// acquire reports whether a call may go ahead at now, and the state it goes
+// ahead in. A half-open breaker lets its probe through and fails the calls
+// made while the probe is out.
+func (b *breaker) acquire(now time.Time, cooldown time.Duration) (BreakerState, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if now.Sub(b.openedAt) < cooldown {
+			return BreakerOpen, false
+		}
+		b.state = BreakerHalfOpen
+		return BreakerHalfOpen, true
+	case BreakerHalfOpen:
+		return BreakerHalfOpen, false
+	}
+	return BreakerClosed, true
+}
+
+// release records the outcome of a call made in state, returning the new
+// state of the breaker.
+func (b *breaker) release(state BreakerState, failed bool, now time.Time, threshold int) BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case !failed:
+		b.state, b.failures = BreakerClosed, 0
+	case state == BreakerHalfOpen:
+		b.state, b.openedAt = BreakerOpen, now
+	case b.state == BreakerClosed:
+		b.failures++
+		if b.failures >= threshold {
+			b.state, b.openedAt = BreakerOpen, now
+		}
+	}
+	return b.state
+}
+
+// CircuitBreakerUnaryClientInterceptor returns a client interceptor keeping
+// a circuit breaker per target and method. After threshold consecutive
+// calls fail with a server-side error (see ErrorClass), the breaker opens
+// and calls fail with Unavailable without being sent. Once cooldown has
+// passed, a single probe call is let through: the breaker closes if it
+// succeeds and opens again if it fails. A threshold of zero or less
+// disables the breakers.
+//
+// The state of the breaker is tagged on the span in ctx as circuit.state.
+func CircuitBreakerUnaryClientInterceptor(threshold int, cooldown time.Duration) grpc.UnaryClientInterceptor {
+	if threshold < 1 {
+		return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+	}
+	var mu sync.Mutex
+	breakers := make(map[string]*breaker)
+
+	get := func(key string) *breaker {
+		mu.Lock()
+		defer mu.Unlock()
+		b, ok := breakers[key]
+		if !ok {
+			b = new(breaker)
+			breakers[key] = b
+		}
+		return b
+	}
+
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		key := method
+		if cc != nil {
+			key = cc.Target() + method
+		}
+		b := get(key)
+		span := opentracing.SpanFromContext(ctx)
+
+		state, ok := b.acquire(time.Now(), cooldown)
+		if !ok {
+			if span != nil {
+				span.SetTag("circuit.state", state.String())
+			}
+			return status.Errorf(codes.Unavailable, "%s: circuit breaker is %s", method, state)
+		}
+
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		failed := err != nil && ErrorClass(status.Code(err)) == "server"
+		newState := b.release(state, failed, time.Now(), threshold)
+		if newState != state {
+			Logger(ctx).Warn().Msgf("%s: circuit breaker of %s is now %s", method, key, newState)
+		}
+		if span != nil {
+			span.SetTag("circuit.state", newState.String())
+		}
+		return err
+	}
+}
@@ -0,0 +1,176 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeBackend answers calls with err, after delay, counting them.
+type fakeBackend struct {
+	mu    sync.Mutex
+	err   error
+	delay time.Duration
+	calls int
+}
+
+func (b *fakeBackend) set(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.err = err
+}
+
+func (b *fakeBackend) callCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.calls
+}
+
+func (b *fakeBackend) invoke(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+	b.mu.Lock()
+	b.calls++
+	err, delay := b.err, b.delay
+	b.mu.Unlock()
+	time.Sleep(delay)
+	return err
+}
+
+// callThrough calls method of backend through breaker, returning the state
+// tagged on the span and the error.
+func callThrough(breaker grpc.UnaryClientInterceptor, backend *fakeBackend, method string) (interface{}, error) {
+	span := mocktracer.New().StartSpan(method).(*mocktracer.MockSpan)
+	err := breaker(opentracing.ContextWithSpan(context.Background(), span), method, nil, nil, nil, backend.invoke)
+	return span.Tag("circuit.state"), err
+}
+
+const rateMethod = "/rate.Rate/GetRates"
+
+func TestCircuitBreakerOpensAndRecovers(t *testing.T) {
+	backend := &fakeBackend{err: status.Error(codes.Unavailable, "rate is down")}
+	breaker := CircuitBreakerUnaryClientInterceptor(3, 100*time.Millisecond)
+
+	// the breaker opens on the third consecutive failure
+	for i := 1; i <= 3; i++ {
+		state, err := callThrough(breaker, backend, rateMethod)
+		if status.Code(err) != codes.Unavailable {
+			t.Fatalf("call %d: got %v, want the backend error", i, err)
+		}
+		want := "closed"
+		if i == 3 {
+			want = "open"
+		}
+		if state != want {
+			t.Errorf("call %d: circuit.state = %v, want %s", i, state, want)
+		}
+	}
+
+	// and fails calls fast, without sending them
+	state, err := callThrough(breaker, backend, rateMethod)
+	if status.Code(err) != codes.Unavailable || state != "open" {
+		t.Errorf("got %v in state %v, want Unavailable with the breaker open", err, state)
+	}
+	if n := backend.callCount(); n != 3 {
+		t.Errorf("backend got %d calls, want 3", n)
+	}
+
+	// a failed probe opens it again
+	time.Sleep(100 * time.Millisecond)
+	if state, _ := callThrough(breaker, backend, rateMethod); state != "open" {
+		t.Errorf("circuit.state = %v after a failed probe, want open", state)
+	}
+	if n := backend.callCount(); n != 4 {
+		t.Errorf("backend got %d calls, want the probe too", n)
+	}
+	if state, _ := callThrough(breaker, backend, rateMethod); state != "open" || backend.callCount() != 4 {
+		t.Errorf("call sent right after a failed probe")
+	}
+
+	// a successful one closes it
+	backend.set(nil)
+	time.Sleep(100 * time.Millisecond)
+	if state, err := callThrough(breaker, backend, rateMethod); err != nil || state != "closed" {
+		t.Errorf("probe got %v in state %v, want success with the breaker closed", err, state)
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := callThrough(breaker, backend, rateMethod); err != nil {
+			t.Errorf("got %v once closed", err)
+		}
+	}
+}
+
+func TestCircuitBreakerSingleProbe(t *testing.T) {
+	backend := &fakeBackend{err: status.Error(codes.Internal, "rate failed")}
+	breaker := CircuitBreakerUnaryClientInterceptor(1, 50*time.Millisecond)
+	callThrough(breaker, backend, rateMethod)
+
+	time.Sleep(50 * time.Millisecond)
+	backend.mu.Lock()
+	backend.err, backend.delay = nil, 100*time.Millisecond
+	backend.mu.Unlock()
+
+	// calls made while the probe is out fail fast
+	probed := make(chan error)
+	go func() {
+		_, err := callThrough(breaker, backend, rateMethod)
+		probed <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	state, err := callThrough(breaker, backend, rateMethod)
+	if status.Code(err) != codes.Unavailable || state != "half-open" {
+		t.Errorf("got %v in state %v during the probe, want Unavailable half-open", err, state)
+	}
+	if err := <-probed; err != nil {
+		t.Errorf("probe: %v", err)
+	}
+	if n := backend.callCount(); n != 2 {
+		t.Errorf("backend got %d calls, want the failure and the probe", n)
+	}
+}
+
+func TestCircuitBreakerCountsConsecutiveServerErrors(t *testing.T) {
+	backend := &fakeBackend{}
+	breaker := CircuitBreakerUnaryClientInterceptor(2, time.Hour)
+
+	// client errors and successes don't open the breaker
+	for _, err := range []error{
+		status.Error(codes.Unavailable, "down"),
+		status.Error(codes.InvalidArgument, "bad request"),
+		status.Error(codes.NotFound, "no such hotel"),
+		nil,
+		status.Error(codes.DeadlineExceeded, "slow"),
+		nil,
+		status.Error(codes.Internal, "failed"),
+	} {
+		backend.set(err)
+		if state, _ := callThrough(breaker, backend, rateMethod); state != "closed" {
+			t.Fatalf("breaker %v after %v", state, err)
+		}
+	}
+
+	// breakers are per method
+	backend.set(status.Error(codes.Internal, "failed"))
+	if state, _ := callThrough(breaker, backend, "/profile.Profile/GetProfiles"); state != "closed" {
+		t.Errorf("breaker of another method %v after one failure", state)
+	}
+	if state, _ := callThrough(breaker, backend, rateMethod); state != "open" {
+		t.Errorf("breaker %v after two consecutive failures, want open", state)
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	backend := &fakeBackend{err: status.Error(codes.Unavailable, "down")}
+	breaker := CircuitBreakerUnaryClientInterceptor(0, time.Hour)
+	for i := 0; i < 20; i++ {
+		callThrough(breaker, backend, rateMethod)
+	}
+	if n := backend.callCount(); n != 20 {
+		t.Errorf("backend got %d of 20 calls with the breaker disabled", n)
+	}
+}
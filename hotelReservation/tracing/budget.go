@@ -0,0 +1,91 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// BudgetKey is the gRPC metadata key carrying the total deadline budget of
+// a request in milliseconds, as set by the frontend. The deadline itself is
+// propagated by gRPC; the budget lets every hop tell how much of it is left.
+const BudgetKey = "x-deadline-budget-ms"
+
+type budgetCtxKey struct{}
+
+// ContextWithBudget returns a copy of ctx carrying the total deadline budget
+// of its request.
+func ContextWithBudget(ctx context.Context, budget time.Duration) context.Context {
+	return context.WithValue(ctx, budgetCtxKey{}, budget)
+}
+
+// BudgetFromContext returns the total deadline budget stored in ctx.
+func BudgetFromContext(ctx context.Context) (time.Duration, bool) {
+	budget, ok := ctx.Value(budgetCtxKey{}).(time.Duration)
+	return budget, ok
+}
+
+// WithDeadlineBudget bounds every request served by handler to budget, so
+// that all the downstream calls made with the request context inherit the
+// same, shrinking, deadline. A budget of zero or less leaves requests
+// unbounded.
+func WithDeadlineBudget(handler http.Handler, budget time.Duration) http.Handler {
+	if budget <= 0 {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), budget)
+		defer cancel()
+		if span := opentracing.SpanFromContext(ctx); span != nil {
+			span.SetTag("deadline.budget_ms", millis(budget))
+		}
+		handler.ServeHTTP(w, r.WithContext(ContextWithBudget(ctx, budget)))
+	})
+}
+
+// BudgetUnaryServerInterceptor makes the total deadline budget sent by the
+// caller available to the handler through BudgetFromContext.
+func BudgetUnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if vals := md.Get(BudgetKey); len(vals) > 0 {
+			if ms, err := strconv.ParseInt(vals[0], 10, 64); err == nil && ms > 0 {
+				ctx = ContextWithBudget(ctx, time.Duration(ms)*time.Millisecond)
+			}
+		}
+	}
+	return handler(ctx, req)
+}
+
+// BudgetUnaryClientInterceptor returns a client interceptor forwarding the
+// total deadline budget in ctx to the server. Calls issued with less than
+// slice (e.g. 0.1 for 10%) of the budget left are logged and tag the span
+// with deadline.budget_exhausted=true, pointing at fan-outs that use up the
+// budget before reaching the last hop.
+func BudgetUnaryClientInterceptor(slice float64) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		budget, ok := BudgetFromContext(ctx)
+		if !ok {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		ctx = metadata.AppendToOutgoingContext(ctx, BudgetKey, strconv.FormatInt(budget.Milliseconds(), 10))
+
+		if deadline, ok := ctx.Deadline(); ok {
+			remaining := time.Until(deadline)
+			if span := opentracing.SpanFromContext(ctx); span != nil {
+				span.SetTag("grpc.deadline_remaining_ms", millis(remaining))
+			}
+			if float64(remaining) < slice*float64(budget) {
+				if span := opentracing.SpanFromContext(ctx); span != nil {
+					span.SetTag("deadline.budget_exhausted", true)
+				}
+				Logger(ctx).Warn().Msgf("%s: calling with %.3fms left of a %v budget", method, millis(remaining), budget)
+			}
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
@@ -0,0 +1,144 @@
+package tracing
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+// serveHop serves check as a hop of a request for the duration of t,
+// returning a client of it forwarding the budget.
+func serveHop(t *testing.T, check healthFunc) healthpb.HealthClient {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srv := grpc.NewServer(grpc.UnaryInterceptor(BudgetUnaryServerInterceptor))
+	healthpb.RegisterHealthServer(srv, check)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure(), grpc.WithUnaryInterceptor(BudgetUnaryClientInterceptor(0.1)))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return healthpb.NewHealthClient(conn)
+}
+
+// hop is what a hop of the request saw.
+type hop struct {
+	remaining float64
+	budget    time.Duration
+}
+
+// seen returns what ctx tells of the request.
+func seen(ctx context.Context) hop {
+	budget, _ := BudgetFromContext(ctx)
+	return hop{remaining: deadlineRemaining(ctx), budget: budget}
+}
+
+func TestBudgetShrinksAlongTheChain(t *testing.T) {
+	const budget = 500 * time.Millisecond
+	const work = 100 * time.Millisecond
+	hops := make([]hop, 0, 3)
+
+	// frontend -> search -> geo, each working a while before the next call
+	geo := serveHop(t, func(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+		hops = append(hops, seen(ctx))
+		return &healthpb.HealthCheckResponse{}, nil
+	})
+	search := serveHop(t, func(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+		hops = append(hops, seen(ctx))
+		time.Sleep(work)
+		return geo.Check(ctx, req)
+	})
+	frontend := WithDeadlineBudget(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hops = append(hops, seen(r.Context()))
+		time.Sleep(work)
+		if _, err := search.Check(r.Context(), &healthpb.HealthCheckRequest{}); err != nil {
+			t.Errorf("Check: %v", err)
+		}
+	}), budget)
+	frontend.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/hotels", nil))
+
+	if len(hops) != 3 {
+		t.Fatalf("request went through %d hops, want 3", len(hops))
+	}
+	prev := millis(budget) + millis(work)
+	for i, h := range hops {
+		if h.budget != budget {
+			t.Errorf("hop %d saw a budget of %v, want %v", i, h.budget, budget)
+		}
+		// each hop has what the previous had, less its work
+		if h.remaining > prev-millis(work) || h.remaining < prev-millis(work)-50 {
+			t.Errorf("hop %d had %.3fms left, want about %.3fms", i, h.remaining, prev-millis(work))
+		}
+		prev = h.remaining
+	}
+}
+
+func TestBudgetUnbounded(t *testing.T) {
+	var h hop
+	WithDeadlineBudget(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h = seen(r.Context())
+	}), 0).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/hotels", nil))
+	if h.remaining != -1 || h.budget != 0 {
+		t.Errorf("request bounded to %.3fms of a %v budget, want unbounded", h.remaining, h.budget)
+	}
+}
+
+// callWithBudget calls through the interceptor with remaining of budget
+// left, returning the span and the outgoing metadata.
+func callWithBudget(budget, remaining time.Duration) (*mocktracer.MockSpan, metadata.MD) {
+	span := mocktracer.New().StartSpan("Nearby").(*mocktracer.MockSpan)
+	ctx := opentracing.ContextWithSpan(context.Background(), span)
+	if budget > 0 {
+		ctx = ContextWithBudget(ctx, budget)
+	}
+	ctx, cancel := context.WithTimeout(ctx, remaining)
+	defer cancel()
+
+	var md metadata.MD
+	BudgetUnaryClientInterceptor(0.2)(ctx, "/geo.Geo/Nearby", nil, nil, nil, func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	})
+	return span, md
+}
+
+func TestBudgetExhausted(t *testing.T) {
+	for _, tt := range []struct {
+		remaining time.Duration
+		exhausted bool
+	}{
+		{900 * time.Millisecond, false},
+		{300 * time.Millisecond, false},
+		{100 * time.Millisecond, true},
+	} {
+		span, md := callWithBudget(time.Second, tt.remaining)
+		exhausted := span.Tag("deadline.budget_exhausted") == true
+		if exhausted != tt.exhausted {
+			t.Errorf("%v left of 1s: budget_exhausted %v, want %v", tt.remaining, exhausted, tt.exhausted)
+		}
+		if got := md.Get(BudgetKey); len(got) != 1 || got[0] != "1000" {
+			t.Errorf("%v left of 1s: sent budget %v, want 1000", tt.remaining, got)
+		}
+	}
+
+	// calls without a budget are left alone
+	span, md := callWithBudget(0, 10*time.Millisecond)
+	if span.Tag("deadline.budget_exhausted") != nil || len(md.Get(BudgetKey)) != 0 {
+		t.Errorf("call without a budget tagged or sent one")
+	}
+}
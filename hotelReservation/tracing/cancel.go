@@ -0,0 +1,23 @@
+package tracing
+
+import (
+	"context"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"google.golang.org/grpc"
+)
+
+// CancellationTaggingUnaryServerInterceptor tags the span in ctx with
+// cancelled=true when the handler fails after the caller cancelled the
+// request, e.g. on a client disconnect, which aborts the database operations
+// run with ctx.
+func CancellationTaggingUnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	if err != nil && ctx.Err() == context.Canceled {
+		if span := opentracing.SpanFromContext(ctx); span != nil {
+			span.SetTag("cancelled", true)
+		}
+		Logger(ctx).Debug().Msgf("%s: cancelled by the caller", info.FullMethod)
+	}
+	return resp, err
+}
@@ -0,0 +1,195 @@
+package tracing
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tracing/otel"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+)
+
+// ChainUnaryServerInterceptors composes interceptors into one. The first
+// interceptor is the outermost, i.e. it runs first and sees the final result.
+func ChainUnaryServerInterceptors(interceptors ...grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		chained := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			chained = bindUnaryServer(interceptors[i], info, chained)
+		}
+		return chained(ctx, req)
+	}
+}
+
+func bindUnaryServer(interceptor grpc.UnaryServerInterceptor, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) grpc.UnaryHandler {
+	return func(ctx context.Context, req interface{}) (interface{}, error) {
+		return interceptor(ctx, req, info, next)
+	}
+}
+
+// ChainStreamServerInterceptors composes stream interceptors into one, with
+// the same ordering as ChainUnaryServerInterceptors.
+func ChainStreamServerInterceptors(interceptors ...grpc.StreamServerInterceptor) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		chained := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			chained = bindStreamServer(interceptors[i], info, chained)
+		}
+		return chained(srv, ss)
+	}
+}
+
+func bindStreamServer(interceptor grpc.StreamServerInterceptor, info *grpc.StreamServerInfo, next grpc.StreamHandler) grpc.StreamHandler {
+	return func(srv interface{}, ss grpc.ServerStream) error {
+		return interceptor(srv, ss, info, next)
+	}
+}
+
+// ServerChainOptions configures DefaultServerInterceptorChain.
+type ServerChainOptions struct {
+	// Backend starts and tags the spans, BackendFromEnv if empty.
+	Backend Backend
+	// Tracer starts the span of each RPC with the OpenTracing backend, none
+	// if nil.
+	Tracer opentracing.Tracer
+	// TracerProvider starts the span of each RPC with the OpenTelemetry
+	// backend, the global provider set by Init if nil.
+	TracerProvider trace.TracerProvider
+	// Metrics records each RPC, DefaultMetrics if nil.
+	Metrics *MetricsRegistry
+	// Untagged leaves out the peer, status, deadline, latency and size
+	// tagging of the span, and the slow request warning.
+	Untagged bool
+	// Interceptors run after the default ones, closest to the handler, e.g.
+	// the validation of the service.
+	Interceptors []grpc.UnaryServerInterceptor
+	// Custom replaces the default interceptors and Interceptors, in order. A
+	// custom chain should start with RecoveryUnaryServerInterceptor, or that
+	// of package otel; one without either is logged.
+	Custom []grpc.UnaryServerInterceptor
+}
+
+// DefaultServerInterceptorChain returns the recommended server chain, from
+// the outermost:
+//
+//   - tracing, which starts the span the interceptors below tag, named
+//     after the OperationName of the method, with opts.Backend;
+//   - recovery, so that a panic in any interceptor below, not only in the
+//     handler, fails the RPC rather than the server, and is tagged on the
+//     span;
+//   - request ID, before anything logs, so that logs carry it, then tenant;
+//   - metrics, which then count the RPCs rejected by the interceptors below,
+//     and the access log, sampled by DefaultAccessLog;
+//   - peer, status, cancellation, deadline, latency and size tagging, and
+//     the warning about requests slower than SLOW_REQUEST_MS, with the
+//     status, latency and size tagging of package otel for the
+//     OpenTelemetry backend, below which
+//     the interceptors only add to the latency when rejecting a request;
+//   - rate limiting, per method as set by RATE_LIMITS, then the timeouts of
+//     METHOD_TIMEOUTS;
+//   - payload logging, for the requests asking for it from the callers in
+//     DEBUG_PAYLOAD_ALLOWLIST, with passwords redacted;
+//   - opts.Interceptors.
+//
+// opts.Custom replaces the chain; ChainUnaryServerInterceptors composes any
+// other order.
+func DefaultServerInterceptorChain(opts ServerChainOptions) grpc.UnaryServerInterceptor {
+	return ChainUnaryServerInterceptors(serverInterceptors(opts)...)
+}
+
+// serverInterceptors returns the interceptors chained by
+// DefaultServerInterceptorChain, in order.
+func serverInterceptors(opts ServerChainOptions) []grpc.UnaryServerInterceptor {
+	if opts.Custom != nil {
+		if !hasInterceptor(opts.Custom, RecoveryUnaryServerInterceptor) && !hasInterceptor(opts.Custom, otel.RecoveryUnaryServerInterceptor) {
+			log.Warn().Msgf("Custom server interceptor chain has no recovery interceptor: a panic in a handler crashes the server")
+		}
+		return opts.Custom
+	}
+
+	metrics := opts.Metrics
+	if metrics == nil {
+		metrics = DefaultMetrics
+	}
+	backend := opts.Backend
+	if backend == "" {
+		backend = BackendFromEnv()
+	}
+	chain := append(serverSpanInterceptors(opts, backend), recoveryInterceptor(backend))
+	chain = append(chain,
+		RequestIDUnaryServerInterceptor,
+		TenantUnaryServerInterceptor,
+		MetricsUnaryServerInterceptor(metrics),
+		AccessLogUnaryServerInterceptor(DefaultAccessLog),
+	)
+	if !opts.Untagged {
+		chain = append(chain,
+			PeerTaggingUnaryServerInterceptor,
+			StatusTaggingUnaryServerInterceptor,
+			CancellationTaggingUnaryServerInterceptor,
+			DeadlineTaggingUnaryServerInterceptor,
+			LatencyTaggingUnaryServerInterceptor,
+			SizeTaggingUnaryServerInterceptor,
+			SlowRequestUnaryServerInterceptor(time.Duration(tune.GetSlowRequest())*time.Millisecond, tune.GetSlowRequestMethods()),
+		)
+		chain = append(chain, otelTaggingInterceptors(backend)...)
+	}
+	if limits := tune.GetRateLimits(); len(limits) > 0 {
+		chain = append(chain, RateLimitUnaryServerInterceptor(limits))
+	}
+	if timeouts := tune.GetMethodTimeouts(); len(timeouts) > 0 {
+		chain = append(chain, MethodTimeoutUnaryServerInterceptor(timeouts))
+	}
+	if debug := debugPayloadInterceptor(); debug != nil {
+		chain = append(chain, debug)
+	}
+	return append(chain, opts.Interceptors...)
+}
+
+// debugPayloadInterceptor returns DebugPayloadUnaryServerInterceptor for the
+// callers in DEBUG_PAYLOAD_ALLOWLIST, nil if there are none or the allowlist
+// is invalid.
+func debugPayloadInterceptor() grpc.UnaryServerInterceptor {
+	allowed := tune.GetDebugPayloadAllowlist()
+	if len(allowed) == 0 {
+		return nil
+	}
+	debug, err := DebugPayloadUnaryServerInterceptor(allowed, "password")
+	if err != nil {
+		log.Error().Msgf("Not logging debug payloads: %v", err)
+		return nil
+	}
+	return debug
+}
+
+// hasInterceptor reports whether chain has interceptor, compared by function,
+// which is all Go allows.
+func hasInterceptor(chain []grpc.UnaryServerInterceptor, interceptor grpc.UnaryServerInterceptor) bool {
+	want := reflect.ValueOf(interceptor).Pointer()
+	for _, i := range chain {
+		if i != nil && reflect.ValueOf(i).Pointer() == want {
+			return true
+		}
+	}
+	return false
+}
+
+// MethodsUnaryClientInterceptor returns a client interceptor running
+// interceptor for calls to methods, given as full method names, only,
+// invoking the others directly.
+func MethodsUnaryClientInterceptor(interceptor grpc.UnaryClientInterceptor, methods ...string) grpc.UnaryClientInterceptor {
+	only := make(map[string]bool, len(methods))
+	for _, m := range methods {
+		only[m] = true
+	}
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if !only[method] {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		return interceptor(ctx, method, req, reply, cc, invoker, opts...)
+	}
+}
@@ -0,0 +1,161 @@
+package tracing
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-opentracing/go/otgrpc"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// funcNames returns the names of the functions of interceptors, those of
+// the function literals for closures.
+func funcNames(interceptors []grpc.UnaryServerInterceptor) []string {
+	names := make([]string, len(interceptors))
+	for i, interceptor := range interceptors {
+		names[i] = runtime.FuncForPC(reflect.ValueOf(interceptor).Pointer()).Name()
+	}
+	return names
+}
+
+// recording returns an interceptor appending name to calls on the way in
+// and out.
+func recording(name string, calls *[]string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		*calls = append(*calls, "in "+name)
+		defer func() { *calls = append(*calls, "out "+name) }()
+		return handler(ctx, req)
+	}
+}
+
+func TestChainUnaryServerInterceptors(t *testing.T) {
+	var calls []string
+	chain := ChainUnaryServerInterceptors(recording("a", &calls), recording("b", &calls), recording("c", &calls))
+	chain(context.Background(), nil, testInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+		calls = append(calls, "handler")
+		return nil, nil
+	})
+	want := "in a, in b, in c, handler, out c, out b, out a"
+	if got := strings.Join(calls, ", "); got != want {
+		t.Errorf("got calls %s, want %s", got, want)
+	}
+}
+
+func TestDefaultServerInterceptorChainOrder(t *testing.T) {
+	t.Setenv("RATE_LIMITS", "/health/Check=100")
+	t.Setenv("METHOD_TIMEOUTS", "/health/Check=100")
+	t.Setenv("DEBUG_PAYLOAD_ALLOWLIST", "")
+	var calls []string
+	last := recording("last", &calls)
+	metrics := NewMetricsRegistry()
+
+	got := funcNames(serverInterceptors(ServerChainOptions{
+		Tracer:       mocktracer.New(),
+		Metrics:      metrics,
+		Interceptors: []grpc.UnaryServerInterceptor{last},
+	}))
+	want := funcNames([]grpc.UnaryServerInterceptor{
+		otgrpc.OpenTracingServerInterceptor(mocktracer.New()),
+		SpanNamingUnaryServerInterceptor,
+		RecoveryUnaryServerInterceptor,
+		RequestIDUnaryServerInterceptor,
+		TenantUnaryServerInterceptor,
+		MetricsUnaryServerInterceptor(metrics),
+		AccessLogUnaryServerInterceptor(DefaultAccessLog),
+		PeerTaggingUnaryServerInterceptor,
+		StatusTaggingUnaryServerInterceptor,
+		CancellationTaggingUnaryServerInterceptor,
+		DeadlineTaggingUnaryServerInterceptor,
+		LatencyTaggingUnaryServerInterceptor,
+		SizeTaggingUnaryServerInterceptor,
+		SlowRequestUnaryServerInterceptor(0, nil),
+		RateLimitUnaryServerInterceptor(map[string]float64{"/health/Check": 100}),
+		MethodTimeoutUnaryServerInterceptor(nil),
+		last,
+	})
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got chain\n\t%s\nwant\n\t%s", strings.Join(got, "\n\t"), strings.Join(want, "\n\t"))
+	}
+}
+
+func TestDefaultServerInterceptorChainUntagged(t *testing.T) {
+	t.Setenv("RATE_LIMITS", "")
+	t.Setenv("METHOD_TIMEOUTS", "")
+	t.Setenv("DEBUG_PAYLOAD_ALLOWLIST", "")
+	metrics := NewMetricsRegistry()
+
+	got := funcNames(serverInterceptors(ServerChainOptions{Metrics: metrics, Untagged: true}))
+	want := funcNames([]grpc.UnaryServerInterceptor{
+		RecoveryUnaryServerInterceptor,
+		RequestIDUnaryServerInterceptor,
+		TenantUnaryServerInterceptor,
+		MetricsUnaryServerInterceptor(metrics),
+		AccessLogUnaryServerInterceptor(DefaultAccessLog),
+	})
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got chain\n\t%s\nwant\n\t%s", strings.Join(got, "\n\t"), strings.Join(want, "\n\t"))
+	}
+}
+
+func TestDefaultServerInterceptorChainRecovery(t *testing.T) {
+	captureLog(t, zerolog.Disabled)
+	panicking := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		panic("bad interceptor")
+	}
+	tracer := mocktracer.New()
+	client := serveHealth(t, serving, grpc.UnaryInterceptor(DefaultServerInterceptorChain(ServerChainOptions{
+		Tracer:       tracer,
+		Metrics:      NewMetricsRegistry(),
+		Interceptors: []grpc.UnaryServerInterceptor{panicking},
+	})))
+
+	// a panic in an interceptor fails the RPC, and not the server
+	for i := 0; i < 2; i++ {
+		_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		if status.Code(err) != codes.Internal {
+			t.Fatalf("Check %d: got %v, want Internal", i, err)
+		}
+	}
+
+	// and is tagged on the span of the RPC
+	spans := tracer.FinishedSpans()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	for _, span := range spans {
+		if span.Tag("error") != true {
+			t.Errorf("span %q not tagged error: %v", span.OperationName, span.Tags())
+		}
+		if stack, _ := span.Tag("panic.stack").(string); !strings.Contains(stack, "TestDefaultServerInterceptorChainRecovery") {
+			t.Errorf("span %q panic.stack = %q, want the stack of the panic", span.OperationName, stack)
+		}
+	}
+}
+
+func TestDefaultServerInterceptorChainCustom(t *testing.T) {
+	var calls []string
+	custom := []grpc.UnaryServerInterceptor{recording("a", &calls), recording("b", &calls)}
+
+	logs := captureLog(t, zerolog.WarnLevel)
+	got := funcNames(serverInterceptors(ServerChainOptions{Tracer: mocktracer.New(), Custom: custom}))
+	if strings.Join(got, "\n") != strings.Join(funcNames(custom), "\n") {
+		t.Errorf("got chain %v, want the custom one %v", got, funcNames(custom))
+	}
+	if !strings.Contains(logs.String(), "no recovery interceptor") {
+		t.Errorf("got logs %q, want a warning about the missing recovery", logs)
+	}
+
+	logs.Reset()
+	serverInterceptors(ServerChainOptions{Custom: append([]grpc.UnaryServerInterceptor{RecoveryUnaryServerInterceptor}, custom...)})
+	if logs.Len() != 0 {
+		t.Errorf("got logs %q of a custom chain with recovery, want none", logs)
+	}
+}
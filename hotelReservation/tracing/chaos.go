@@ -0,0 +1,176 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ChaosAllMethods is the Chaos key of the fault injected into the methods
+// without one of their own, health checks aside.
+const ChaosAllMethods = "*"
+
+// healthMethods prefixes the health check methods, so that faults injected
+// into all methods don't take the service out of its load balancer.
+const healthMethods = "/grpc.health.v1.Health/"
+
+// DefaultChaos holds the faults injected by the services' chaos interceptor.
+// It injects none until configured, at /chaos on the admin port.
+var DefaultChaos = NewChaos()
+
+// Fault is an artificial failure injected into calls to a method.
+type Fault struct {
+	// ErrorRate is the probability, in [0, 1], of failing a call with
+	// Unavailable instead of handling it.
+	ErrorRate float64 `json:"errorRate"`
+	// LatencyMs is the delay added before every call, in milliseconds.
+	LatencyMs int `json:"latencyMs"`
+}
+
+// Chaos maps full method names (e.g. "/geo.Geo/Nearby"), or
+// ChaosAllMethods, to the faults to inject into them. It serves its faults
+// as JSON and replaces them with a PUT of the same JSON, or removes them all
+// with a DELETE. It is safe for concurrent use.
+type Chaos struct {
+	mu     sync.RWMutex
+	faults map[string]Fault
+}
+
+// NewChaos returns a Chaos injecting no faults.
+func NewChaos() *Chaos {
+	return &Chaos{faults: make(map[string]Fault)}
+}
+
+// Set replaces the faults injected, failing if any is invalid.
+func (c *Chaos) Set(faults map[string]Fault) error {
+	for method, f := range faults {
+		if !(f.ErrorRate >= 0 && f.ErrorRate <= 1) {
+			return fmt.Errorf("error rate of %s must be in [0, 1], got %v", method, f.ErrorRate)
+		}
+		if f.LatencyMs < 0 {
+			return fmt.Errorf("latency of %s must not be negative, got %dms", method, f.LatencyMs)
+		}
+	}
+	m := make(map[string]Fault, len(faults))
+	for method, f := range faults {
+		m[method] = f
+	}
+
+	c.mu.Lock()
+	c.faults = m
+	c.mu.Unlock()
+	return nil
+}
+
+// Faults returns a copy of the faults injected.
+func (c *Chaos) Faults() map[string]Fault {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	m := make(map[string]Fault, len(c.faults))
+	for method, f := range c.faults {
+		m[method] = f
+	}
+	return m
+}
+
+// fault returns the fault to inject into method, if any.
+func (c *Chaos) fault(method string) (Fault, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if f, ok := c.faults[method]; ok {
+		return f, true
+	}
+	if strings.HasPrefix(method, healthMethods) {
+		return Fault{}, false
+	}
+	f, ok := c.faults[ChaosAllMethods]
+	return f, ok
+}
+
+// ServeHTTP serves the faults on GET, replaces them on PUT and removes them
+// on DELETE.
+func (c *Chaos) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var faults map[string]Fault
+		if err := json.Unmarshal(body, &faults); err != nil {
+			http.Error(w, fmt.Sprintf("malformed faults: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := c.Set(faults); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		Logger(r.Context()).Warn().Msgf("Chaos: injecting %v", faults)
+	case http.MethodDelete:
+		c.Set(nil)
+		Logger(r.Context()).Info().Msg("Chaos: no longer injecting faults")
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		http.Error(w, "Use GET, PUT or DELETE", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c.Faults())
+}
+
+// ChaosUnaryServerInterceptor returns a server interceptor injecting the
+// faults of c before the handler: it delays calls by their latency, then
+// fails them with Unavailable at their error rate. Injected faults tag the
+// span in ctx with chaos.latency_ms and chaos.error=true, telling them apart
+// from real failures. Methods without a fault are left alone, and so are all
+// methods while ChaosFlag is off.
+func ChaosUnaryServerInterceptor(c *Chaos) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !ChaosFlag.Enabled() {
+			return handler(ctx, req)
+		}
+		f, ok := c.fault(info.FullMethod)
+		if !ok {
+			return handler(ctx, req)
+		}
+		span := opentracing.SpanFromContext(ctx)
+
+		if f.LatencyMs > 0 {
+			if span != nil {
+				span.SetTag("chaos.latency_ms", f.LatencyMs)
+			}
+			timer := time.NewTimer(time.Duration(f.LatencyMs) * time.Millisecond)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, status.FromContextError(ctx.Err()).Err()
+			case <-timer.C:
+			}
+		}
+
+		if f.ErrorRate > 0 && rand.Float64() < f.ErrorRate {
+			if span != nil {
+				span.SetTag("chaos.error", true)
+			}
+			Logger(ctx).Debug().Msgf("%s: injecting an Unavailable error", info.FullMethod)
+			return nil, status.Errorf(codes.Unavailable, "%s: injected fault", info.FullMethod)
+		}
+		return handler(ctx, req)
+	}
+}
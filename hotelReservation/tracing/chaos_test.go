@@ -0,0 +1,168 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// callChaos calls method through the interceptor of c, returning the
+// error and whether the handler ran.
+func callChaos(ctx context.Context, c *Chaos, method string) (bool, error) {
+	handled := false
+	_, err := ChaosUnaryServerInterceptor(c)(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		handled = true
+		return nil, nil
+	})
+	return handled, err
+}
+
+func TestChaosErrorRate(t *testing.T) {
+	for _, rate := range []float64{0, 0.1, 0.5, 1} {
+		c := NewChaos()
+		if err := c.Set(map[string]Fault{testInfo.FullMethod: {ErrorRate: rate}}); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+
+		const calls = 10000
+		injected := 0
+		for i := 0; i < calls; i++ {
+			ctx, span := withMockSpan(context.Background())
+			handled, err := callChaos(ctx, c, testInfo.FullMethod)
+			if err == nil {
+				if !handled || span.Tag("chaos.error") != nil {
+					t.Fatalf("error rate %v: handled %v with tags %v, want handled and untagged", rate, handled, span.Tags())
+				}
+				continue
+			}
+			injected++
+			if status.Code(err) != codes.Unavailable || handled || span.Tag("chaos.error") != true {
+				t.Fatalf("error rate %v: got %v, handled %v, tags %v, want an Unavailable tagged chaos.error and not handled", rate, err, handled, span.Tags())
+			}
+		}
+		if got := float64(injected) / calls; math.Abs(got-rate) > 0.03 {
+			t.Errorf("error rate %v: injected errors into %v of calls", rate, got)
+		}
+	}
+}
+
+func TestChaosLatency(t *testing.T) {
+	c := NewChaos()
+	c.Set(map[string]Fault{testInfo.FullMethod: {LatencyMs: 50}})
+
+	ctx, span := withMockSpan(context.Background())
+	start := time.Now()
+	handled, err := callChaos(ctx, c, testInfo.FullMethod)
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("call took %v, want at least 50ms", elapsed)
+	}
+	if err != nil || !handled {
+		t.Errorf("got %v, handled %v, want the call handled", err, handled)
+	}
+	if span.Tag("chaos.latency_ms") != 50 {
+		t.Errorf("chaos.latency_ms = %v, want 50", span.Tag("chaos.latency_ms"))
+	}
+
+	// callers giving up aren't kept waiting
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if handled, err := callChaos(ctx, c, testInfo.FullMethod); status.Code(err) != codes.DeadlineExceeded || handled {
+		t.Errorf("got %v, handled %v, want DeadlineExceeded before handling", err, handled)
+	}
+}
+
+func TestChaosMethods(t *testing.T) {
+	c := NewChaos()
+	c.Set(map[string]Fault{
+		ChaosAllMethods:     {ErrorRate: 1},
+		testInfo.FullMethod: {ErrorRate: 0},
+	})
+	for _, tt := range []struct {
+		method   string
+		injected bool
+	}{
+		{"/geo.Geo/Nearby", true},
+		{testInfo.FullMethod, false},
+		{"/grpc.health.v1.Health/Check", false},
+	} {
+		if _, err := callChaos(context.Background(), c, tt.method); (err != nil) != tt.injected {
+			t.Errorf("%s: got %v, want injected %v", tt.method, err, tt.injected)
+		}
+	}
+}
+
+func TestChaosDisabled(t *testing.T) {
+	// no faults by default
+	if handled, err := callChaos(context.Background(), NewChaos(), testInfo.FullMethod); err != nil || !handled {
+		t.Errorf("got %v, handled %v, want the call handled", err, handled)
+	}
+
+	c := NewChaos()
+	c.Set(map[string]Fault{ChaosAllMethods: {ErrorRate: 1, LatencyMs: 1000}})
+	ChaosFlag.set(false)
+	defer ChaosFlag.set(true)
+	start := time.Now()
+	if handled, err := callChaos(context.Background(), c, testInfo.FullMethod); err != nil || !handled || time.Since(start) > 500*time.Millisecond {
+		t.Errorf("got %v, handled %v, want the call handled at once with chaos off", err, handled)
+	}
+}
+
+func TestChaosSetInvalid(t *testing.T) {
+	c := NewChaos()
+	c.Set(map[string]Fault{ChaosAllMethods: {ErrorRate: 0.5}})
+	for _, f := range []Fault{{ErrorRate: -0.1}, {ErrorRate: 1.1}, {ErrorRate: math.NaN()}, {LatencyMs: -1}} {
+		if err := c.Set(map[string]Fault{testInfo.FullMethod: f}); err == nil {
+			t.Errorf("Set(%+v) succeeded, want an error", f)
+		}
+	}
+	if got := c.Faults(); len(got) != 1 || got[ChaosAllMethods].ErrorRate != 0.5 {
+		t.Errorf("got faults %v after invalid sets, want the first ones", got)
+	}
+}
+
+// serveChaos sends a request of method with body to c, returning the
+// response.
+func serveChaos(c *Chaos, method, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, httptest.NewRequest(method, "/chaos", strings.NewReader(body)))
+	return w
+}
+
+func TestChaosServeHTTP(t *testing.T) {
+	c := NewChaos()
+	w := serveChaos(c, http.MethodPut, `{"/geo.Geo/Nearby": {"errorRate": 0.25, "latencyMs": 10}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT: got status %d: %s", w.Code, w.Body)
+	}
+	if f := c.Faults()["/geo.Geo/Nearby"]; f.ErrorRate != 0.25 || f.LatencyMs != 10 {
+		t.Errorf("got fault %+v, want the one put", f)
+	}
+
+	w = serveChaos(c, http.MethodGet, "")
+	var faults map[string]Fault
+	if err := json.Unmarshal(w.Body.Bytes(), &faults); err != nil || faults["/geo.Geo/Nearby"].ErrorRate != 0.25 {
+		t.Errorf("GET: got %s, %v, want the fault put", w.Body, err)
+	}
+
+	for _, body := range []string{`not json`, `{"/geo.Geo/Nearby": {"errorRate": 2}}`} {
+		if w := serveChaos(c, http.MethodPut, body); w.Code != http.StatusBadRequest {
+			t.Errorf("PUT %s: got status %d, want 400", body, w.Code)
+		}
+	}
+	if w := serveChaos(c, http.MethodPost, "{}"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST: got status %d, want 405", w.Code)
+	}
+
+	if w := serveChaos(c, http.MethodDelete, ""); w.Code != http.StatusOK || len(c.Faults()) != 0 {
+		t.Errorf("DELETE: got status %d and faults %v, want none left", w.Code, c.Faults())
+	}
+}
@@ -0,0 +1,81 @@
+package tracing
+
+import (
+	"compress/gzip"
+	"context"
+
+	"google.golang.org/grpc"
+	grpcgzip "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/protobuf/proto"
+)
+
+// wireSizes is filled in by CompressionUnaryServerInterceptor for
+// SizeTaggingUnaryServerInterceptor, which runs around it.
+type wireSizes struct {
+	compressed bool
+	respWire   int
+}
+
+type wireSizesCtxKey struct{}
+
+// CompressionUnaryServerInterceptor returns a server interceptor sending
+// responses of at least threshold bytes gzip-compressed, provided the client
+// accepts gzip. Smaller responses are sent as is, since compressing them
+// costs more CPU than it saves bandwidth.
+//
+// When installed after SizeTaggingUnaryServerInterceptor, the compressed
+// size of the response is measured for it to tag.
+func CompressionUnaryServerInterceptor(threshold int) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		if err != nil || messageSize(resp) < threshold || !acceptsGzip(ctx) {
+			return resp, err
+		}
+
+		if err := grpc.SetSendCompressor(ctx, grpcgzip.Name); err != nil {
+			Logger(ctx).Warn().Msgf("%s: failed to compress response: %v", info.FullMethod, err)
+			return resp, nil
+		}
+		if sizes, ok := ctx.Value(wireSizesCtxKey{}).(*wireSizes); ok {
+			sizes.compressed = true
+			sizes.respWire = gzipSize(resp.(proto.Message))
+		}
+		return resp, nil
+	}
+}
+
+// acceptsGzip reports whether the client of the RPC in ctx advertised gzip.
+func acceptsGzip(ctx context.Context) bool {
+	names, err := grpc.ClientSupportedCompressors(ctx)
+	if err != nil {
+		return false
+	}
+	for _, name := range names {
+		if name == grpcgzip.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// gzipSize returns the size of m once marshalled and gzip-compressed, or -1
+// if it cannot be marshalled.
+func gzipSize(m proto.Message) int {
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return -1
+	}
+	var n countingWriter
+	zw := gzip.NewWriter(&n)
+	zw.Write(b)
+	zw.Close()
+	return int(n)
+}
+
+// countingWriter counts the bytes written to it.
+type countingWriter int
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	*w += countingWriter(len(p))
+	return len(p), nil
+}
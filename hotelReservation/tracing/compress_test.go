@@ -0,0 +1,123 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+
+	geo "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/geo/proto"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/stats"
+)
+
+// manyHotelsGeo answers Nearby with as many hotels as the latitude asks.
+type manyHotelsGeo struct {
+	geo.UnimplementedGeoServer
+}
+
+func (manyHotelsGeo) Nearby(ctx context.Context, req *geo.Request) (*geo.Result, error) {
+	res := new(geo.Result)
+	for i := 0; i < int(req.Lat); i++ {
+		res.HotelIds = append(res.HotelIds, fmt.Sprintf("hotel-%d", i))
+	}
+	return res, nil
+}
+
+// payloadSizes records the sizes of the payloads a client receives.
+type payloadSizes struct {
+	mu             sync.Mutex
+	length, onWire int
+}
+
+func (p *payloadSizes) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context { return ctx }
+func (p *payloadSizes) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+func (p *payloadSizes) HandleConn(context.Context, stats.ConnStats) {}
+
+func (p *payloadSizes) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	if in, ok := s.(*stats.InPayload); ok {
+		p.mu.Lock()
+		p.length, p.onWire = in.Length, in.CompressedLength
+		p.mu.Unlock()
+	}
+}
+
+// compressingServer serves manyHotelsGeo compressing responses of at least
+// threshold bytes, returning a client recording payload sizes and the spans
+// of the calls served.
+func compressingServer(t *testing.T, threshold int) (geo.GeoClient, *payloadSizes, chan *mocktracer.MockSpan) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	spans := make(chan *mocktracer.MockSpan, 1)
+	withSpan := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, span := withMockSpan(ctx)
+		defer func() { spans <- span }()
+		return handler(ctx, req)
+	}
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(withSpan, SizeTaggingUnaryServerInterceptor, CompressionUnaryServerInterceptor(threshold)))
+	geo.RegisterGeoServer(srv, manyHotelsGeo{})
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	sizes := new(payloadSizes)
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure(), grpc.WithStatsHandler(sizes))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return geo.NewGeoClient(conn), sizes, spans
+}
+
+func TestCompressionUnaryServerInterceptor(t *testing.T) {
+	client, sizes, spans := compressingServer(t, 1024)
+	for _, tt := range []struct {
+		hotels     float32
+		compressed bool
+	}{
+		{10, false},
+		{1000, true},
+	} {
+		if _, err := client.Nearby(context.Background(), &geo.Request{Lat: tt.hotels}); err != nil {
+			t.Fatalf("Nearby: %v", err)
+		}
+		span := <-spans
+		sizes.mu.Lock()
+		length, onWire := sizes.length, sizes.onWire
+		sizes.mu.Unlock()
+
+		if span.Tag("grpc.response.compressed") != tt.compressed {
+			t.Errorf("%v hotels: grpc.response.compressed = %v, want %v", tt.hotels, span.Tag("grpc.response.compressed"), tt.compressed)
+		}
+		if span.Tag("grpc.response.size") != length {
+			t.Errorf("%v hotels: grpc.response.size = %v, want the %d bytes received", tt.hotels, span.Tag("grpc.response.size"), length)
+		}
+		if !tt.compressed {
+			if onWire != length {
+				t.Errorf("%v hotels: received %d bytes for %d, want them uncompressed", tt.hotels, onWire, length)
+			}
+			if span.Tag("grpc.response.compression_ratio") != nil {
+				t.Errorf("%v hotels: got a compression ratio for an uncompressed response", tt.hotels)
+			}
+			continue
+		}
+
+		if onWire >= length {
+			t.Errorf("%v hotels: received %d bytes for %d, want them compressed", tt.hotels, onWire, length)
+		}
+		wire, _ := span.Tag("grpc.response.wire_size").(int)
+		if wire <= 0 || wire >= length {
+			t.Errorf("%v hotels: grpc.response.wire_size = %v, want under the %d bytes", tt.hotels, span.Tag("grpc.response.wire_size"), length)
+		}
+		ratio, _ := span.Tag("grpc.response.compression_ratio").(float64)
+		if want := float64(length) / float64(wire); ratio != want || ratio <= 1 {
+			t.Errorf("%v hotels: grpc.response.compression_ratio = %v, want %v", tt.hotels, ratio, want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package tracing
+
+import (
+	"context"
+	"sync/atomic"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ConcurrencyLimiter bounds the number of unary RPCs a server handles at the
+// same time. A limit of zero or less disables shedding.
+type ConcurrencyLimiter struct {
+	inflight int64
+	limit    int64
+}
+
+// ConcurrencyLimitUnaryServerInterceptor returns a server interceptor that
+// rejects requests with ResourceExhausted while max requests are already in
+// flight, along with the limiter backing it so the in-flight count can be
+// exported and the limit changed at runtime.
+func ConcurrencyLimitUnaryServerInterceptor(max int) (grpc.UnaryServerInterceptor, *ConcurrencyLimiter) {
+	l := &ConcurrencyLimiter{limit: int64(max)}
+	return l.intercept, l
+}
+
+// InFlight returns the number of requests currently being handled.
+func (l *ConcurrencyLimiter) InFlight() int {
+	return int(atomic.LoadInt64(&l.inflight))
+}
+
+// Limit returns the current concurrency limit.
+func (l *ConcurrencyLimiter) Limit() int {
+	return int(atomic.LoadInt64(&l.limit))
+}
+
+// SetLimit changes the concurrency limit. Requests already in flight are not
+// affected.
+func (l *ConcurrencyLimiter) SetLimit(max int) {
+	atomic.StoreInt64(&l.limit, int64(max))
+}
+
+func (l *ConcurrencyLimiter) intercept(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	inflight := atomic.AddInt64(&l.inflight, 1)
+	defer atomic.AddInt64(&l.inflight, -1)
+
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		span.SetTag("grpc.inflight", inflight)
+	}
+
+	if limit := atomic.LoadInt64(&l.limit); limit > 0 && inflight > limit {
+		Logger(ctx).Warn().Msgf("%s: shedding request, %d in flight exceeds limit %d", info.FullMethod, inflight, limit)
+		return nil, status.Errorf(codes.ResourceExhausted, "%s: too many concurrent requests (limit %d)", info.FullMethod, limit)
+	}
+
+	return handler(ctx, req)
+}
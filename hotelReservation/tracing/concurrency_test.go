@@ -0,0 +1,68 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestConcurrencyLimitSheds(t *testing.T) {
+	const limit, calls = 3, 10
+	shed, limiter := ConcurrencyLimitUnaryServerInterceptor(limit)
+
+	release := make(chan struct{})
+	results := make(chan error, calls)
+	for i := 0; i < calls; i++ {
+		go func() {
+			_, err := shed(context.Background(), nil, testInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+				<-release
+				return nil, nil
+			})
+			results <- err
+		}()
+	}
+
+	// nothing returns before the release but the rejections
+	for i := 0; i < calls-limit; i++ {
+		if err := <-results; status.Code(err) != codes.ResourceExhausted {
+			t.Fatalf("got %v before the release, want ResourceExhausted", err)
+		}
+	}
+	if got := limiter.InFlight(); got != limit {
+		t.Errorf("InFlight() = %d with the handlers blocked, want %d", got, limit)
+	}
+	close(release)
+	for i := 0; i < limit; i++ {
+		if err := <-results; err != nil {
+			t.Errorf("admitted call failed: %v", err)
+		}
+	}
+	if got := limiter.InFlight(); got != 0 {
+		t.Errorf("InFlight() = %d once done, want 0", got)
+	}
+}
+
+func TestConcurrencyLimitDisabled(t *testing.T) {
+	shed, limiter := ConcurrencyLimitUnaryServerInterceptor(0)
+	_, err := shed(context.Background(), nil, testInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+		// a nested call is in flight at the same time
+		return shed(ctx, nil, testInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+	})
+	if err != nil {
+		t.Errorf("got %v with shedding disabled", err)
+	}
+
+	limiter.SetLimit(1)
+	_, err = shed(context.Background(), nil, testInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return shed(ctx, nil, testInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+	})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("got %v after lowering the limit to 1, want ResourceExhausted", err)
+	}
+}
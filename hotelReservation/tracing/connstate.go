@@ -0,0 +1,53 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/status"
+)
+
+// ConnStateUnaryClientInterceptor logs a warning for each call failing with
+// Unavailable on a connection that is not READY, naming the state of the
+// connection and tagging the span with it as grpc.conn_state. The first
+// call to succeed on such a connection afterwards is logged too, so that the
+// recovery from a backend restart shows in the logs.
+func ConnStateUnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	// broken holds the connections whose last call failed on their state.
+	var broken sync.Map
+
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if cc == nil {
+			return err
+		}
+		if err == nil {
+			if _, ok := broken.LoadAndDelete(cc); ok {
+				Logger(ctx).Info().Msgf("%s: connection to %s recovered", method, cc.Target())
+			}
+			return nil
+		}
+		if status.Code(err) != codes.Unavailable {
+			return err
+		}
+		state := cc.GetState()
+		if state == connectivity.Ready {
+			return err
+		}
+		if state == connectivity.Shutdown {
+			// Closed for good, it won't recover.
+			broken.Delete(cc)
+		} else {
+			broken.Store(cc, state)
+		}
+		if span := opentracing.SpanFromContext(ctx); span != nil {
+			span.SetTag("grpc.conn_state", state.String())
+		}
+		Logger(ctx).Warn().Msgf("%s: call failed with connection to %s in state %s: %v", method, cc.Target(), state, err)
+		return err
+	}
+}
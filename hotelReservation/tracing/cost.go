@@ -0,0 +1,133 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// The gRPC trailer keys through which a server reports the cost of the calls
+// it made to serve a request, see CostUnaryServerInterceptor.
+const (
+	CostCallsKey = "cost-downstream-calls"
+	CostBytesKey = "cost-downstream-bytes"
+)
+
+// Cost accumulates the downstream calls made on behalf of a request,
+// directly or by the services it called, and the bytes of their requests and
+// responses. It is safe for concurrent use by parallel calls.
+type Cost struct {
+	calls int64
+	bytes int64
+}
+
+// Add adds calls and bytes to c.
+func (c *Cost) Add(calls, bytes int64) {
+	atomic.AddInt64(&c.calls, calls)
+	atomic.AddInt64(&c.bytes, bytes)
+}
+
+// Calls returns the number of downstream calls accumulated.
+func (c *Cost) Calls() int64 { return atomic.LoadInt64(&c.calls) }
+
+// Bytes returns the total bytes of the downstream calls accumulated.
+func (c *Cost) Bytes() int64 { return atomic.LoadInt64(&c.bytes) }
+
+// tag tags span with the totals of c, as request.downstream_calls and
+// request.total_downstream_bytes.
+func (c *Cost) tag(span opentracing.Span) {
+	span.SetTag("request.downstream_calls", c.Calls())
+	span.SetTag("request.total_downstream_bytes", c.Bytes())
+}
+
+type costCtxKey struct{}
+
+// ContextWithCost returns a copy of ctx accumulating the cost of the calls
+// made with it into a new Cost, and that Cost.
+func ContextWithCost(ctx context.Context) (context.Context, *Cost) {
+	c := new(Cost)
+	return context.WithValue(ctx, costCtxKey{}, c), c
+}
+
+// CostFromContext returns the Cost of ctx, nil if it has none.
+func CostFromContext(ctx context.Context) *Cost {
+	c, _ := ctx.Value(costCtxKey{}).(*Cost)
+	return c
+}
+
+// CostUnaryServerInterceptor accumulates the cost of the calls the handler
+// makes with CostUnaryClientInterceptor, tags the span in ctx with it and
+// reports it to the caller in the CostCallsKey and CostBytesKey trailers.
+func CostUnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, cost := ContextWithCost(ctx)
+	resp, err := handler(ctx, req)
+
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		cost.tag(span)
+	}
+	grpc.SetTrailer(ctx, metadata.Pairs(
+		CostCallsKey, strconv.FormatInt(cost.Calls(), 10),
+		CostBytesKey, strconv.FormatInt(cost.Bytes(), 10),
+	))
+	return resp, err
+}
+
+// CostUnaryClientInterceptor adds an outgoing unary call to the Cost of ctx,
+// if any: the call itself and the sizes of its request and reply, as tagged
+// by SizeTaggingUnaryClientInterceptor, plus the cost the server reported
+// in its trailers for its own calls. Failed calls count too.
+func CostUnaryClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	cost := CostFromContext(ctx)
+	if cost == nil {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+
+	var trailer metadata.MD
+	err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Trailer(&trailer))...)
+
+	var bytes int64
+	if size := messageSize(req); size > 0 {
+		bytes += int64(size)
+	}
+	if err == nil {
+		if size := messageSize(reply); size > 0 {
+			bytes += int64(size)
+		}
+	}
+	cost.Add(1+trailerInt(trailer, CostCallsKey), bytes+trailerInt(trailer, CostBytesKey))
+	return err
+}
+
+// trailerInt returns the integer value of key in md, 0 if missing or
+// malformed.
+func trailerInt(md metadata.MD, key string) int64 {
+	vals := md.Get(key)
+	if len(vals) == 0 {
+		return 0
+	}
+	n, err := strconv.ParseInt(vals[0], 10, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// WithCostAccounting accumulates the cost of the calls made to serve the
+// requests of handler, with CostUnaryClientInterceptor, and tags their span
+// with it once handler returns.
+func WithCostAccounting(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cost := ContextWithCost(r.Context())
+		handler.ServeHTTP(w, r.WithContext(ctx))
+
+		if span := opentracing.SpanFromContext(ctx); span != nil {
+			cost.tag(span)
+		}
+		Logger(ctx).Debug().Msgf("%s %s: %d downstream calls, %d bytes", r.Method, r.URL.Path, cost.Calls(), cost.Bytes())
+	})
+}
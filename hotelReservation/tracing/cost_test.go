@@ -0,0 +1,177 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-opentracing/go/otgrpc"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// serveCostedHop serves check, accounting the cost of the calls it makes,
+// traced by tracer, and returns a client accounting the cost of its calls.
+func serveCostedHop(t *testing.T, tracer opentracing.Tracer, check healthFunc) healthpb.HealthClient {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(otgrpc.OpenTracingServerInterceptor(tracer), CostUnaryServerInterceptor))
+	healthpb.RegisterHealthServer(srv, check)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure(), grpc.WithUnaryInterceptor(CostUnaryClientInterceptor))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return healthpb.NewHealthClient(conn)
+}
+
+// checkSize returns the bytes of a call checking service, failed or not.
+func checkSize(service string, failed bool) int64 {
+	size := proto.Size(&healthpb.HealthCheckRequest{Service: service})
+	if !failed {
+		size += proto.Size(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING})
+	}
+	return int64(size)
+}
+
+// serving answers checks of every service but "down".
+func serving(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	if req.Service == "down" {
+		return nil, status.Error(codes.Unavailable, "down")
+	}
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+// fanOut returns a handler checking services with client in parallel.
+func fanOut(client healthpb.HealthClient, services ...string) healthFunc {
+	return func(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+		var wg sync.WaitGroup
+		for _, service := range services {
+			wg.Add(1)
+			go func(service string) {
+				defer wg.Done()
+				client.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+			}(service)
+		}
+		wg.Wait()
+		return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+	}
+}
+
+func TestCostAcrossHops(t *testing.T) {
+	tracer := mocktracer.New()
+	leaves := []string{"geo", "rate", "profile", "down"}
+	leaf := serveCostedHop(t, tracer, serving)
+	search := serveCostedHop(t, tracer, fanOut(leaf, leaves...))
+
+	ctx, cost := ContextWithCost(context.Background())
+	if _, err := search.Check(ctx, &healthpb.HealthCheckRequest{Service: "search"}); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+
+	var leafBytes int64
+	for _, service := range leaves {
+		leafBytes += checkSize(service, service == "down")
+	}
+	if cost.Calls() != int64(1+len(leaves)) {
+		t.Errorf("got %d calls, want %d", cost.Calls(), 1+len(leaves))
+	}
+	if want := checkSize("search", false) + leafBytes; cost.Bytes() != want {
+		t.Errorf("got %d bytes, want %d", cost.Bytes(), want)
+	}
+
+	// the search hop tags its span with the cost of its own calls
+	var searchSpan *mocktracer.MockSpan
+	for _, span := range tracer.FinishedSpans() {
+		if span.Tag("request.downstream_calls") == int64(len(leaves)) {
+			searchSpan = span
+		}
+	}
+	if searchSpan == nil {
+		t.Fatalf("no span tagged with the %d calls of the search hop", len(leaves))
+	}
+	if got := searchSpan.Tag("request.total_downstream_bytes"); got != leafBytes {
+		t.Errorf("request.total_downstream_bytes = %v, want %d", got, leafBytes)
+	}
+}
+
+func TestCostUnaccounted(t *testing.T) {
+	leaf := serveCostedHop(t, mocktracer.New(), serving)
+	var trailer metadata.MD
+	if _, err := leaf.Check(context.Background(), &healthpb.HealthCheckRequest{}, grpc.Trailer(&trailer)); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	// the leaf made no calls of its own
+	if calls, bytes := trailer.Get(CostCallsKey), trailer.Get(CostBytesKey); len(calls) != 1 || calls[0] != "0" || len(bytes) != 1 || bytes[0] != "0" {
+		t.Errorf("got cost trailers %v, want 0 calls of 0 bytes", trailer)
+	}
+}
+
+func TestCostConcurrentAdds(t *testing.T) {
+	var cost Cost
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cost.Add(1, 10)
+		}()
+	}
+	wg.Wait()
+	if cost.Calls() != 100 || cost.Bytes() != 1000 {
+		t.Errorf("got %d calls of %d bytes, want 100 of 1000", cost.Calls(), cost.Bytes())
+	}
+}
+
+func TestCostMalformedTrailers(t *testing.T) {
+	ctx, cost := ContextWithCost(context.Background())
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		for _, opt := range opts {
+			if trailer, ok := opt.(grpc.TrailerCallOption); ok {
+				*trailer.TrailerAddr = metadata.Pairs(CostCallsKey, "-3", CostBytesKey, "many")
+			}
+		}
+		return errors.New("failed")
+	}
+	CostUnaryClientInterceptor(ctx, "/health/Check", &healthpb.HealthCheckRequest{Service: "geo"}, &healthpb.HealthCheckResponse{}, nil, invoker)
+	if cost.Calls() != 1 || cost.Bytes() != checkSize("geo", true) {
+		t.Errorf("got %d calls of %d bytes, want 1 of %d", cost.Calls(), cost.Bytes(), checkSize("geo", true))
+	}
+}
+
+func TestWithCostAccounting(t *testing.T) {
+	tracer := mocktracer.New()
+	leaf := serveCostedHop(t, tracer, serving)
+	search := serveCostedHop(t, tracer, fanOut(leaf, "geo", "rate"))
+
+	span := tracer.StartSpan("GET /hotels").(*mocktracer.MockSpan)
+	handler := WithCostAccounting(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		search.Check(r.Context(), &healthpb.HealthCheckRequest{Service: "search"})
+	}))
+	r := httptest.NewRequest("GET", "/hotels", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), r.WithContext(opentracing.ContextWithSpan(r.Context(), span)))
+
+	if got := span.Tag("request.downstream_calls"); got != int64(3) {
+		t.Errorf("request.downstream_calls = %v, want 3", got)
+	}
+	want := checkSize("search", false) + checkSize("geo", false) + checkSize("rate", false)
+	if got := span.Tag("request.total_downstream_bytes"); got != want {
+		t.Errorf("request.total_downstream_bytes = %v, want %d", got, want)
+	}
+}
@@ -0,0 +1,115 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultDeadLetterEntries is the number of dead letters DefaultDeadLetters
+// keeps.
+const DefaultDeadLetterEntries = 256
+
+// DefaultDeadLetters keeps the dead letters of the retry interceptor. It is
+// served on the admin port at /deadletters.
+var DefaultDeadLetters = NewDeadLetterLog(DefaultDeadLetterEntries)
+
+// DeadLetter records a request that failed after all the attempts the retry
+// interceptor was allowed to make.
+type DeadLetter struct {
+	Time      time.Time     `json:"time"`
+	Method    string        `json:"method"`
+	Code      string        `json:"code"`
+	Message   string        `json:"message"`
+	Attempts  int           `json:"attempts"`
+	Elapsed   time.Duration `json:"elapsed_ns"`
+	RequestID string        `json:"request_id,omitempty"`
+}
+
+// DeadLetterLog is a ring buffer of the latest dead letters: once full,
+// adding one drops the oldest. It serves them as JSON, oldest first. It is
+// safe for concurrent use.
+type DeadLetterLog struct {
+	mu      sync.Mutex
+	entries []DeadLetter
+	next    int // index of the slot to write next
+	full    bool
+}
+
+// NewDeadLetterLog returns a log keeping up to size dead letters, none if
+// size is zero or less.
+func NewDeadLetterLog(size int) *DeadLetterLog {
+	if size < 0 {
+		size = 0
+	}
+	return &DeadLetterLog{entries: make([]DeadLetter, size)}
+}
+
+// Add records d, dropping the oldest dead letter if the log is full.
+func (l *DeadLetterLog) Add(d DeadLetter) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.entries) == 0 {
+		return
+	}
+	l.entries[l.next] = d
+	l.next = (l.next + 1) % len(l.entries)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// Entries returns a copy of the dead letters in the log, oldest first.
+func (l *DeadLetterLog) Entries() []DeadLetter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.full {
+		return append([]DeadLetter(nil), l.entries[:l.next]...)
+	}
+	out := make([]DeadLetter, 0, len(l.entries))
+	out = append(out, l.entries[l.next:]...)
+	return append(out, l.entries[:l.next]...)
+}
+
+// ServeHTTP writes the dead letters in the log as a JSON array.
+func (l *DeadLetterLog) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(l.Entries()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// recordDeadLetter logs the request to method that failed with err after
+// attempts attempts started at start, tags the span in ctx with
+// dead_letter=true and adds it to DefaultDeadLetters. The logger of ctx
+// adds the request ID to the log line.
+func recordDeadLetter(ctx context.Context, method string, err error, attempts int, start time.Time) {
+	st := status.Convert(err)
+	d := DeadLetter{
+		Time:      time.Now(),
+		Method:    method,
+		Code:      st.Code().String(),
+		Message:   st.Message(),
+		Attempts:  attempts,
+		Elapsed:   time.Since(start),
+		RequestID: RequestIDFromContext(ctx),
+	}
+	DefaultDeadLetters.Add(d)
+
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		span.SetTag("dead_letter", true)
+	}
+	Logger(ctx).Error().
+		Str("method", d.Method).
+		Str("code", d.Code).
+		Int("attempts", d.Attempts).
+		Dur("elapsed", d.Elapsed).
+		Msgf("%s: dead letter, giving up after %d attempts in %.3fms: %v", method, attempts, millis(d.Elapsed), err)
+}
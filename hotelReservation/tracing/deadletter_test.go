@@ -0,0 +1,158 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc/codes"
+)
+
+// captureDeadLetters replaces DefaultDeadLetters for the duration of t.
+func captureDeadLetters(t *testing.T) *DeadLetterLog {
+	t.Helper()
+	prev := DefaultDeadLetters
+	t.Cleanup(func() { DefaultDeadLetters = prev })
+	DefaultDeadLetters = NewDeadLetterLog(10)
+	return DefaultDeadLetters
+}
+
+func TestDeadLetterAfterRetries(t *testing.T) {
+	letters := captureDeadLetters(t)
+	logs := captureLog(t, zerolog.InfoLevel)
+	ctx, span := withMockSpan(ContextWithRequestID(context.Background(), "req-1"))
+
+	retry := RetryUnaryClientInterceptor(3, time.Millisecond, codes.Unavailable)
+	calls := 0
+	retry(ctx, "/rate.Rate/GetRates", nil, nil, nil, failingInvoker(10, codes.Unavailable, &calls))
+
+	entries := letters.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("got dead letters %v, want 1", entries)
+	}
+	d := entries[0]
+	if d.Method != "/rate.Rate/GetRates" || d.Code != "Unavailable" || d.Attempts != 3 || d.RequestID != "req-1" || d.Elapsed <= 0 {
+		t.Errorf("got dead letter %+v, want the 3 attempts of req-1 at GetRates", d)
+	}
+	if span.Tag("dead_letter") != true {
+		t.Errorf("dead_letter = %v, want true", span.Tag("dead_letter"))
+	}
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(logs.Bytes(), &line); err != nil {
+		t.Fatalf("decoding %s: %v", logs, err)
+	}
+	if line["level"] != "error" || line["method"] != "/rate.Rate/GetRates" || line["code"] != "Unavailable" || line["attempts"] != 3.0 || line["request_id"] != "req-1" {
+		t.Errorf("got %s, want an error with the method, code, attempts and request ID", logs)
+	}
+	if n := strings.Count(logs.String(), `"request_id"`); n != 1 {
+		t.Errorf("request ID logged %d times, want once", n)
+	}
+}
+
+func TestDeadLetterOnlyWhenGivingUp(t *testing.T) {
+	letters := captureDeadLetters(t)
+	retry := RetryUnaryClientInterceptor(3, time.Millisecond, codes.Unavailable)
+	for _, invoker := range []struct {
+		failures int
+		code     codes.Code
+	}{
+		{0, codes.Unavailable},
+		{2, codes.Unavailable},
+		{5, codes.InvalidArgument},
+	} {
+		calls := 0
+		retry(context.Background(), testInfo.FullMethod, nil, nil, nil, failingInvoker(invoker.failures, invoker.code, &calls))
+	}
+	if entries := letters.Entries(); len(entries) != 0 {
+		t.Errorf("got dead letters %v, want none", entries)
+	}
+}
+
+func TestDeadLetterAtDeadline(t *testing.T) {
+	letters := captureDeadLetters(t)
+	retry := RetryUnaryClientInterceptor(10, 50*time.Millisecond, codes.Unavailable)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	calls := 0
+	retry(ctx, testInfo.FullMethod, nil, nil, nil, failingInvoker(10, codes.Unavailable, &calls))
+	entries := letters.Entries()
+	if len(entries) != 1 || entries[0].Attempts != calls {
+		t.Errorf("got dead letters %v, want one of %d attempts", entries, calls)
+	}
+}
+
+// letter returns the dead letter of method i.
+func letter(i int) DeadLetter {
+	return DeadLetter{Method: fmt.Sprintf("/test.Test/Call%d", i), Attempts: i}
+}
+
+func TestDeadLetterLogDropsOldest(t *testing.T) {
+	l := NewDeadLetterLog(3)
+	if got := l.Entries(); len(got) != 0 {
+		t.Errorf("got %v in a new log, want none", got)
+	}
+	for i := 1; i <= 2; i++ {
+		l.Add(letter(i))
+	}
+	if got := l.Entries(); len(got) != 2 || got[0].Attempts != 1 || got[1].Attempts != 2 {
+		t.Errorf("got %v, want letters 1 and 2", got)
+	}
+	for i := 3; i <= 5; i++ {
+		l.Add(letter(i))
+	}
+	got := l.Entries()
+	if len(got) != 3 || got[0].Attempts != 3 || got[1].Attempts != 4 || got[2].Attempts != 5 {
+		t.Errorf("got %v, want letters 3 to 5, oldest first", got)
+	}
+
+	none := NewDeadLetterLog(0)
+	none.Add(letter(1))
+	if got := none.Entries(); len(got) != 0 {
+		t.Errorf("got %v in a log of no entries, want none", got)
+	}
+}
+
+func TestDeadLetterLogConcurrent(t *testing.T) {
+	l := NewDeadLetterLog(16)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				l.Add(letter(g*100 + i))
+				l.Entries()
+			}
+		}(g)
+	}
+	wg.Wait()
+	if got := l.Entries(); len(got) != 16 {
+		t.Errorf("got %d letters, want the log full with 16", len(got))
+	}
+}
+
+func TestDeadLetterLogServeHTTP(t *testing.T) {
+	l := NewDeadLetterLog(3)
+	l.Add(DeadLetter{Method: "/rate.Rate/GetRates", Code: "Unavailable", Attempts: 3, Elapsed: time.Second, RequestID: "req-1"})
+
+	w := httptest.NewRecorder()
+	l.ServeHTTP(w, httptest.NewRequest("GET", "/deadletters", nil))
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("got Content-Type %q, want JSON", ct)
+	}
+	var got []map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decoding %s: %v", w.Body, err)
+	}
+	if len(got) != 1 || got[0]["method"] != "/rate.Rate/GetRates" || got[0]["elapsed_ns"] != 1e9 || got[0]["request_id"] != "req-1" {
+		t.Errorf("got %s, want the dead letter", w.Body)
+	}
+}
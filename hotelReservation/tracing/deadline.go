@@ -0,0 +1,47 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"google.golang.org/grpc"
+)
+
+// deadlineRemaining returns the budget left in ctx in milliseconds, or -1 if
+// ctx has no deadline.
+func deadlineRemaining(ctx context.Context) float64 {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return -1
+	}
+	return millis(time.Until(deadline))
+}
+
+// DeadlineTaggingUnaryServerInterceptor tags the span in ctx with the deadline
+// budget left when the request arrived. Requests without a deadline are tagged
+// with -1 and logged so that unbounded callers can be found.
+func DeadlineTaggingUnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	remaining := deadlineRemaining(ctx)
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		span.SetTag("grpc.deadline_remaining_ms", remaining)
+	}
+	if remaining < 0 {
+		Logger(ctx).Warn().Msgf("%s: received request without a deadline", info.FullMethod)
+	}
+
+	return handler(ctx, req)
+}
+
+// DeadlineWarningUnaryClientInterceptor returns a client interceptor that logs
+// outgoing RPCs issued with less than threshold of deadline budget left.
+func DeadlineWarningUnaryClientInterceptor(threshold time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if deadline, ok := ctx.Deadline(); ok {
+			if remaining := time.Until(deadline); remaining < threshold {
+				Logger(ctx).Warn().Msgf("%s: calling with %.3fms of deadline budget left (threshold %v)", method, millis(remaining), threshold)
+			}
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
@@ -0,0 +1,49 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestDeadlineTaggingUnaryServerInterceptor(t *testing.T) {
+	ctx, span := withMockSpan(context.Background())
+	ctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	DeadlineTaggingUnaryServerInterceptor(ctx, nil, testInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	remaining, _ := span.Tag("grpc.deadline_remaining_ms").(float64)
+	if remaining <= 0 || remaining > 500 {
+		t.Errorf("grpc.deadline_remaining_ms = %v, want in (0, 500]", span.Tag("grpc.deadline_remaining_ms"))
+	}
+}
+
+func TestDeadlineTaggingWithoutDeadline(t *testing.T) {
+	ctx, span := withMockSpan(context.Background())
+	DeadlineTaggingUnaryServerInterceptor(ctx, nil, testInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	if got := span.Tag("grpc.deadline_remaining_ms"); got != float64(-1) {
+		t.Errorf("grpc.deadline_remaining_ms = %v, want -1", got)
+	}
+}
+
+func TestDeadlinePropagates(t *testing.T) {
+	remaining := make(chan float64, 1)
+	client := serveHealth(t, func(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+		remaining <- deadlineRemaining(ctx)
+		return &healthpb.HealthCheckResponse{}, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if got := <-remaining; got <= 0 || got > 1000 {
+		t.Errorf("server saw %.3fms of budget left, want in (0, 1000]", got)
+	}
+}
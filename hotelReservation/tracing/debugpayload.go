@@ -0,0 +1,135 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/proto"
+)
+
+// DebugPayloadKey is the gRPC metadata key a client sets to "true" to have
+// the payloads of its request logged.
+const DebugPayloadKey = "x-debug-payload"
+
+// DebugPayloadUnaryServerInterceptor returns a server interceptor that, for
+// requests whose metadata sets DebugPayloadKey to true, logs the request and
+// response as JSON at debug level, whatever the global log level. Fields are
+// redacted as by RedactingUnaryServerInterceptor. Other requests only get
+// their sizes logged, by SizeTaggingUnaryServerInterceptor.
+//
+// Only callers whose address is in allowed, given as IPs or CIDRs, can turn
+// payload logging on; the header is ignored for everyone else, and an empty
+// allowlist disables it. So does turning DebugPayloadFlag off.
+func DebugPayloadUnaryServerInterceptor(allowed []string, fields ...string) (grpc.UnaryServerInterceptor, error) {
+	nets, err := parseAllowlist(allowed)
+	if err != nil {
+		return nil, err
+	}
+	sensitive := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		sensitive[f] = true
+	}
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !DebugPayloadFlag.Enabled() || !debugPayloadRequested(ctx) || !peerAllowed(ctx, nets) {
+			return handler(ctx, req)
+		}
+
+		logPayload(ctx, info.FullMethod, "request", req, sensitive)
+		resp, err := handler(ctx, req)
+		if err == nil {
+			logPayload(ctx, info.FullMethod, "response", resp, sensitive)
+		}
+		return resp, err
+	}, nil
+}
+
+// parseAllowlist parses IPs and CIDRs into networks, an IP matching only
+// itself.
+func parseAllowlist(allowed []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, a := range allowed {
+		a = strings.TrimSpace(a)
+		if a == "" {
+			continue
+		}
+		if !strings.Contains(a, "/") {
+			ip := net.ParseIP(a)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid allowlist entry %q", a)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(a)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowlist entry %q: %v", a, err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// debugPayloadRequested reports whether the incoming metadata asks for
+// payload logging.
+func debugPayloadRequested(ctx context.Context) bool {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return false
+	}
+	vals := md.Get(DebugPayloadKey)
+	if len(vals) == 0 {
+		return false
+	}
+	on, _ := strconv.ParseBool(vals[0])
+	return on
+}
+
+// peerAllowed reports whether the caller's address is in one of nets.
+func peerAllowed(ctx context.Context, nets []*net.IPNet) bool {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return false
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		host = p.Addr.String()
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// logPayload logs msg at debug level, bypassing the global level since the
+// caller explicitly asked for it.
+func logPayload(ctx context.Context, method, kind string, msg interface{}, sensitive map[string]bool) {
+	m, ok := msg.(proto.Message)
+	if !ok {
+		return
+	}
+	payload, err := redactedJSON(m, sensitive)
+	if err != nil {
+		Logger(ctx).Warn().Msgf("%s: failed to marshal %s payload: %v", method, kind, err)
+		return
+	}
+	Logger(ctx).Log().Str(zerolog.LevelFieldName, zerolog.DebugLevel.String()).
+		RawJSON("payload", payload).Msgf("%s: %s payload", method, kind)
+}
@@ -0,0 +1,88 @@
+package tracing
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	user "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/user/proto"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// debugCtx returns the context of a request from ip, asking for payload
+// logging with the header value debug unless empty.
+func debugCtx(ip, debug string) context.Context {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 40000}})
+	if debug != "" {
+		ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(DebugPayloadKey, debug))
+	}
+	return ctx
+}
+
+func TestDebugPayloadToggle(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		allowed []string
+		ip      string
+		debug   string
+		logged  bool
+	}{
+		{"requested", []string{"10.0.0.0/8"}, "10.1.2.3", "true", true},
+		{"requested by IP", []string{"192.168.1.5"}, "192.168.1.5", "1", true},
+		{"not requested", []string{"10.0.0.0/8"}, "10.1.2.3", "", false},
+		{"turned off", []string{"10.0.0.0/8"}, "10.1.2.3", "false", false},
+		{"not a bool", []string{"10.0.0.0/8"}, "10.1.2.3", "please", false},
+		{"caller not allowed", []string{"10.0.0.0/8"}, "172.16.0.1", "true", false},
+		{"empty allowlist", nil, "10.1.2.3", "true", false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// payloads are logged whatever the level
+			logs := captureLog(t, zerolog.InfoLevel)
+			debug, err := DebugPayloadUnaryServerInterceptor(tt.allowed, "password")
+			if err != nil {
+				t.Fatalf("DebugPayloadUnaryServerInterceptor: %v", err)
+			}
+			req := &user.Request{Username: "Cornell_1", Password: "1111111111"}
+			debug(debugCtx(tt.ip, tt.debug), req, testInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return &user.Result{Correct: true}, nil
+			})
+
+			out := logs.String()
+			if strings.Contains(out, "1111111111") {
+				t.Errorf("password logged: %s", out)
+			}
+			logged := strings.Contains(out, `"username":"Cornell_1"`) && strings.Contains(out, `"correct":true`)
+			if logged != tt.logged {
+				t.Errorf("payloads logged %v, want %v: %s", logged, tt.logged, out)
+			}
+			if tt.logged && !strings.Contains(out, `"password":"***"`) {
+				t.Errorf("password not redacted: %s", out)
+			}
+		})
+	}
+}
+
+func TestDebugPayloadFlagOff(t *testing.T) {
+	logs := captureLog(t, zerolog.DebugLevel)
+	DebugPayloadFlag.set(false)
+	defer DebugPayloadFlag.set(true)
+
+	debug, _ := DebugPayloadUnaryServerInterceptor([]string{"10.0.0.0/8"})
+	debug(debugCtx("10.1.2.3", "true"), &user.Request{Username: "Cornell_1"}, testInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	if logs.Len() != 0 {
+		t.Errorf("payload logged with the flag off: %s", logs)
+	}
+}
+
+func TestDebugPayloadInvalidAllowlist(t *testing.T) {
+	for _, allowed := range [][]string{{"10.0.0.300"}, {"10.0.0.0/33"}, {"localhost"}} {
+		if _, err := DebugPayloadUnaryServerInterceptor(allowed); err == nil {
+			t.Errorf("allowlist %v accepted", allowed)
+		}
+	}
+}
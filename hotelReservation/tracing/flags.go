@@ -0,0 +1,231 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+)
+
+// DefaultFlags holds the feature flags the interceptors consult. It is
+// served at /flags on the admin port.
+var DefaultFlags = NewFlags()
+
+var (
+	// ChaosFlag turns ChaosUnaryServerInterceptor on. It is on by default,
+	// faults being injected only once configured anyway.
+	ChaosFlag = DefaultFlags.Register("chaos", true)
+	// DebugPayloadFlag turns the payload logging of
+	// DebugPayloadUnaryServerInterceptor on, for the requests asking for it.
+	DebugPayloadFlag = DefaultFlags.Register("debug_payload", true)
+)
+
+// Flag is a feature flag, cheap enough to check on every request.
+type Flag struct {
+	name    string
+	initial bool
+	on      int32
+}
+
+// Name returns the name of f.
+func (f *Flag) Name() string {
+	return f.name
+}
+
+// Enabled reports whether f is on.
+func (f *Flag) Enabled() bool {
+	return atomic.LoadInt32(&f.on) != 0
+}
+
+// set turns f on or off, reporting whether it changed.
+func (f *Flag) set(on bool) bool {
+	var v int32
+	if on {
+		v = 1
+	}
+	return atomic.SwapInt32(&f.on, v) != v
+}
+
+// Flags is a registry of feature flags, turned on and off at runtime. It
+// serves the flags as a JSON map from their names to whether they are on,
+// sets those named in a PUT of the same JSON, and resets them all to their
+// initial values with a DELETE. Every change is logged with its source. It
+// is safe for concurrent use.
+type Flags struct {
+	mu    sync.Mutex
+	flags map[string]*Flag
+}
+
+// NewFlags returns a registry without flags.
+func NewFlags() *Flags {
+	return &Flags{flags: make(map[string]*Flag)}
+}
+
+// Register returns the flag called name, registering it, on if enabled, if
+// it is new.
+func (fs *Flags) Register(name string, enabled bool) *Flag {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if f, ok := fs.flags[name]; ok {
+		return f
+	}
+	f := &Flag{name: name, initial: enabled}
+	f.set(enabled)
+	fs.flags[name] = f
+	return f
+}
+
+// Set turns the flags named in values on or off, failing without changing
+// any if one is unknown. Changes are logged as made by source.
+func (fs *Flags) Set(values map[string]bool, source string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	for name := range values {
+		if _, ok := fs.flags[name]; !ok {
+			return fmt.Errorf("unknown flag %q", name)
+		}
+	}
+	for name, on := range values {
+		if fs.flags[name].set(on) {
+			log.Warn().Msgf("Flags: %s turned %s by %s", name, onOff(on), source)
+		}
+	}
+	return nil
+}
+
+// Reset turns every flag back to its initial value, logging the changes as
+// made by source.
+func (fs *Flags) Reset(source string) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	for name, f := range fs.flags {
+		if f.set(f.initial) {
+			log.Warn().Msgf("Flags: %s reset %s by %s", name, onOff(f.initial), source)
+		}
+	}
+}
+
+// Values returns whether each flag is on, by name.
+func (fs *Flags) Values() map[string]bool {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	m := make(map[string]bool, len(fs.flags))
+	for name, f := range fs.flags {
+		m[name] = f.Enabled()
+	}
+	return m
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+// ServeHTTP serves the flags on GET, sets those named on PUT and resets
+// them on DELETE.
+func (fs *Flags) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	source := "admin request from " + r.RemoteAddr
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var values map[string]bool
+		if err := json.Unmarshal(body, &values); err != nil {
+			http.Error(w, fmt.Sprintf("malformed flags: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := fs.Set(values, source); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	case http.MethodDelete:
+		fs.Reset(source)
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		http.Error(w, "Use GET, PUT or DELETE", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fs.Values())
+}
+
+// WatchFile sets the flags from the JSON file at path, in the format PUT on
+// /flags, checking every interval whether it changed. A missing or invalid
+// file leaves the flags as they are. It is meant to run in its own
+// goroutine, and never returns.
+func (fs *Flags) WatchFile(path string, interval time.Duration) {
+	fs.watchFile(context.Background(), path, interval)
+}
+
+// watchFile is WatchFile, returning once ctx is done.
+func (fs *Flags) watchFile(ctx context.Context, path string, interval time.Duration) {
+	var (
+		modTime time.Time
+		size    int64 = -1
+		lastErr string
+	)
+	source := "file " + path
+	for ; ; time.Sleep(interval) {
+		if ctx.Err() != nil {
+			return
+		}
+		info, err := os.Stat(path)
+		if err == nil && info.ModTime().Equal(modTime) && info.Size() == size {
+			continue
+		}
+		if err == nil {
+			modTime, size = info.ModTime(), info.Size()
+			err = fs.setFromFile(path, source)
+		}
+		if err != nil {
+			if err.Error() != lastErr {
+				log.Error().Msgf("Flags: failed to read %s: %v", path, err)
+			}
+			lastErr = err.Error()
+			continue
+		}
+		lastErr = ""
+	}
+}
+
+func (fs *Flags) setFromFile(path, source string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var values map[string]bool
+	if err := json.Unmarshal(b, &values); err != nil {
+		return fmt.Errorf("malformed flags: %v", err)
+	}
+	return fs.Set(values, source)
+}
+
+// FlaggedUnaryServerInterceptor returns a server interceptor running
+// interceptor only while f is on, calling the handler directly otherwise.
+func FlaggedUnaryServerInterceptor(f *Flag, interceptor grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !f.Enabled() {
+			return handler(ctx, req)
+		}
+		return interceptor(ctx, req, info, handler)
+	}
+}
@@ -0,0 +1,267 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+)
+
+// equalValues reports whether got and want hold the same flags, equally set.
+func equalValues(got, want map[string]bool) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for name, on := range want {
+		if v, ok := got[name]; !ok || v != on {
+			return false
+		}
+	}
+	return true
+}
+
+func TestFlags(t *testing.T) {
+	logs := captureLog(t, zerolog.InfoLevel)
+	fs := NewFlags()
+	a := fs.Register("a", true)
+	b := fs.Register("b", false)
+	if fs.Register("a", false) != a || !a.Enabled() {
+		t.Fatal("registering a flag again did not return it unchanged")
+	}
+	if a.Name() != "a" || !a.Enabled() || b.Enabled() {
+		t.Fatalf("flags %s=%v %s=%v, want a on and b off", a.Name(), a.Enabled(), b.Name(), b.Enabled())
+	}
+
+	if err := fs.Set(map[string]bool{"a": false, "b": true}, "test"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if a.Enabled() || !b.Enabled() {
+		t.Errorf("after Set, a=%v b=%v, want a off and b on", a.Enabled(), b.Enabled())
+	}
+	out := logs.String()
+	if !strings.Contains(out, "a turned off by test") || !strings.Contains(out, "b turned on by test") {
+		t.Errorf("changes not logged: %s", out)
+	}
+
+	// setting a flag as it is logs nothing
+	logs.Reset()
+	fs.Set(map[string]bool{"a": false}, "test")
+	if logs.Len() != 0 {
+		t.Errorf("unchanged flag logged: %s", logs)
+	}
+
+	// an unknown flag fails the whole change
+	if err := fs.Set(map[string]bool{"a": true, "c": true}, "test"); err == nil || !strings.Contains(err.Error(), `"c"`) {
+		t.Errorf("Set of an unknown flag: got %v, want it named", err)
+	}
+	if a.Enabled() {
+		t.Error("a turned on by a failed Set")
+	}
+
+	fs.Reset("test")
+	if want := map[string]bool{"a": true, "b": false}; !equalValues(fs.Values(), want) {
+		t.Errorf("after Reset, flags %v, want %v", fs.Values(), want)
+	}
+	if out := logs.String(); !strings.Contains(out, "a reset on by test") || !strings.Contains(out, "b reset off by test") {
+		t.Errorf("reset not logged: %s", out)
+	}
+}
+
+// serveFlags serves a request of method with body to fs, returning the
+// status and the flags in the response if it succeeded.
+func serveFlags(t *testing.T, fs *Flags, method, body string) (int, map[string]bool) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	fs.ServeHTTP(rec, httptest.NewRequest(method, "/flags", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		return rec.Code, nil
+	}
+	var values map[string]bool
+	if err := json.Unmarshal(rec.Body.Bytes(), &values); err != nil {
+		t.Fatalf("%s /flags: malformed response %q: %v", method, rec.Body, err)
+	}
+	return rec.Code, values
+}
+
+func TestFlagsServeHTTP(t *testing.T) {
+	logs := captureLog(t, zerolog.InfoLevel)
+	fs := NewFlags()
+	chaos := fs.Register("chaos", true)
+	fs.Register("debug_payload", false)
+
+	if _, got := serveFlags(t, fs, http.MethodGet, ""); !equalValues(got, map[string]bool{"chaos": true, "debug_payload": false}) {
+		t.Errorf("GET: %v", got)
+	}
+	if _, got := serveFlags(t, fs, http.MethodPut, `{"chaos": false}`); !equalValues(got, map[string]bool{"chaos": false, "debug_payload": false}) {
+		t.Errorf("PUT: %v", got)
+	}
+	if chaos.Enabled() {
+		t.Error("chaos still on after the PUT")
+	}
+	if !strings.Contains(logs.String(), "chaos turned off by admin request from") {
+		t.Errorf("PUT not logged with its source: %s", logs)
+	}
+
+	for _, tt := range []struct {
+		method, body string
+		want         int
+	}{
+		{http.MethodPut, `{"chaos": "yes"}`, http.StatusBadRequest},
+		{http.MethodPut, `{"unknown": true}`, http.StatusBadRequest},
+		{http.MethodPost, `{"chaos": true}`, http.StatusMethodNotAllowed},
+	} {
+		if code, _ := serveFlags(t, fs, tt.method, tt.body); code != tt.want {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.body, code, tt.want)
+		}
+	}
+	if chaos.Enabled() {
+		t.Error("chaos turned on by a failed request")
+	}
+
+	if _, got := serveFlags(t, fs, http.MethodDelete, ""); !equalValues(got, map[string]bool{"chaos": true, "debug_payload": false}) {
+		t.Errorf("DELETE: %v", got)
+	}
+}
+
+// waitFlag waits for f to be on, or off.
+func waitFlag(t *testing.T, f *Flag, on bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); f.Enabled() != on; time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("flag %s never turned %s", f.Name(), onOff(on))
+		}
+	}
+}
+
+func TestFlagsWatchFile(t *testing.T) {
+	logs := captureLog(t, zerolog.InfoLevel)
+	fs := NewFlags()
+	chaos := fs.Register("chaos", true)
+	path := filepath.Join(t.TempDir(), "flags.json")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("writing the flags: %v", err)
+		}
+	}
+
+	write(`{"chaos": false}`)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fs.watchFile(ctx, path, 5*time.Millisecond)
+	}()
+	// stop watching before the log is read, or restored
+	stop := func() {
+		cancel()
+		<-done
+	}
+	defer stop()
+	waitFlag(t, chaos, false)
+
+	// a malformed file leaves the flags as they are
+	write(`{"chaos": tru`)
+	time.Sleep(50 * time.Millisecond)
+	if chaos.Enabled() {
+		t.Error("chaos turned on by a malformed file")
+	}
+
+	write(`{"chaos": true}`)
+	waitFlag(t, chaos, true)
+
+	stop()
+	out := logs.String()
+	for _, msg := range []string{"chaos turned off by file " + path, "failed to read " + path, "chaos turned on by file " + path} {
+		if !strings.Contains(out, msg) {
+			t.Errorf("%q not logged: %s", msg, out)
+		}
+	}
+}
+
+func TestFlaggedUnaryServerInterceptor(t *testing.T) {
+	fs := NewFlags()
+	f := fs.Register("tagging", false)
+	var intercepted int
+	tagging := FlaggedUnaryServerInterceptor(f, func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		intercepted++
+		return handler(ctx, req)
+	})
+	call := func() {
+		t.Helper()
+		handled := false
+		tagging(context.Background(), nil, testInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+			handled = true
+			return nil, nil
+		})
+		if !handled {
+			t.Fatal("handler not called")
+		}
+	}
+
+	call()
+	if intercepted != 0 {
+		t.Errorf("interceptor ran %d times while off", intercepted)
+	}
+	fs.Set(map[string]bool{"tagging": true}, "test")
+	call()
+	call()
+	if intercepted != 2 {
+		t.Errorf("interceptor ran %d times in 2 calls while on", intercepted)
+	}
+	fs.Set(map[string]bool{"tagging": false}, "test")
+	call()
+	if intercepted != 2 {
+		t.Errorf("interceptor ran after being turned off")
+	}
+}
+
+func TestFlagFlippedUnderLoad(t *testing.T) {
+	captureLog(t, zerolog.InfoLevel)
+	fs := NewFlags()
+	f := fs.Register("fail", false)
+	failing := FlaggedUnaryServerInterceptor(f, func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return nil, context.Canceled
+	})
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				failing(context.Background(), nil, testInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+					return nil, nil
+				})
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		fs.Set(map[string]bool{"fail": i%2 == 0}, "test")
+	}
+	close(stop)
+	wg.Wait()
+
+	// calls see the flag as last set
+	_, err := failing(context.Background(), nil, testInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	if err != nil {
+		t.Errorf("got %v with the flag off, want the handler's nil", err)
+	}
+}
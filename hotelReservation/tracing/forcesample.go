@@ -0,0 +1,58 @@
+package tracing
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+// ForceSampleKey is the HTTP header forcing the trace of a request to be
+// sampled, whatever the sampler decides, when it carries one of the tokens
+// given to WithForcedSampling.
+const ForceSampleKey = "X-Force-Sample"
+
+// WithForcedSampling samples the traces of the requests served by handler
+// that carry one of tokens in the ForceSampleKey header, by setting the
+// sampling priority of their span. The decision travels with the span
+// context, so the spans of every downstream hop are sampled too. Requests
+// with any other value are served as usual and logged. Empty tokens never
+// match.
+func WithForcedSampling(handler http.Handler, tokens []string) http.Handler {
+	allowed := make([][]byte, 0, len(tokens))
+	for _, t := range tokens {
+		if t != "" {
+			allowed = append(allowed, []byte(t))
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get(ForceSampleKey)
+		if token == "" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		if !allowedToken(allowed, []byte(token)) {
+			Logger(r.Context()).Warn().Msgf("Ignoring %s header from %s: token not allowlisted", ForceSampleKey, r.RemoteAddr)
+		} else if span := opentracing.SpanFromContext(r.Context()); span != nil {
+			ext.SamplingPriority.Set(span, 1)
+			span.SetTag("sampling.forced", true)
+			Logger(r.Context()).Debug().Msgf("Forcing the trace of %s %s to be sampled", r.Method, r.URL.Path)
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// allowedToken reports whether token is one of allowed, comparing them in
+// constant time.
+func allowedToken(allowed [][]byte, token []byte) bool {
+	ok := false
+	for _, a := range allowed {
+		if subtle.ConstantTimeCompare(a, token) == 1 {
+			ok = true
+		}
+	}
+	return ok
+}
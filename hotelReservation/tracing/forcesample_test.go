@@ -0,0 +1,91 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-opentracing/go/otgrpc"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/rs/zerolog"
+	"github.com/uber/jaeger-client-go"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// neverSampling returns a Jaeger tracer sampling no trace unless forced to,
+// and the reporter of the spans it samples.
+func neverSampling(t *testing.T, service string) (opentracing.Tracer, *jaeger.InMemoryReporter) {
+	t.Helper()
+	reporter := jaeger.NewInMemoryReporter()
+	tracer, closer := jaeger.NewTracer(service, jaeger.NewConstSampler(false), reporter)
+	t.Cleanup(func() { closer.Close() })
+	return tracer, reporter
+}
+
+// forceSampled serves a request with the ForceSampleKey header set to token,
+// unless empty, by a frontend forcing the sampling of the tokens "secret"
+// and calling a backend, and returns whether the backend span was sampled
+// and the spans reported by the frontend and the backend.
+func forceSampled(t *testing.T, token string) (bool, []opentracing.Span, []opentracing.Span) {
+	t.Helper()
+	frontTracer, frontSpans := neverSampling(t, "frontend")
+	backTracer, backSpans := neverSampling(t, "search")
+
+	var sampled bool
+	backend := serveTracedHop(t, backTracer, func(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+		sampled = opentracing.SpanFromContext(ctx).Context().(jaeger.SpanContext).IsSampled()
+		return &healthpb.HealthCheckResponse{}, nil
+	}, otgrpc.OpenTracingClientInterceptor(frontTracer))
+
+	mux := NewServeMux(frontTracer)
+	mux.Handle("/hotels", WithForcedSampling(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := backend.Check(r.Context(), &healthpb.HealthCheckRequest{}); err != nil {
+			t.Errorf("backend call: %v", err)
+		}
+	}), []string{"", "secret"}))
+	r := httptest.NewRequest(http.MethodGet, "/hotels", nil)
+	if token != "" {
+		r.Header.Set(ForceSampleKey, token)
+	}
+	mux.ServeHTTP(httptest.NewRecorder(), r)
+	return sampled, frontSpans.GetSpans(), backSpans.GetSpans()
+}
+
+func TestForcedSampling(t *testing.T) {
+	sampled, front, back := forceSampled(t, "secret")
+	if !sampled {
+		t.Errorf("backend span not sampled")
+	}
+	// the HTTP span and the client span of the backend call, then the
+	// server span of the backend
+	if len(front) != 2 || len(back) != 1 {
+		t.Fatalf("got %d frontend and %d backend spans reported, want 2 and 1", len(front), len(back))
+	}
+	var forced bool
+	for _, span := range front {
+		if span.(*jaeger.Span).Tags()["sampling.forced"] == true {
+			forced = true
+		}
+	}
+	if !forced {
+		t.Errorf("no frontend span tagged sampling.forced")
+	}
+	if front[0].Context().(jaeger.SpanContext).TraceID() != back[0].Context().(jaeger.SpanContext).TraceID() {
+		t.Errorf("frontend and backend spans of different traces")
+	}
+}
+
+func TestForcedSamplingUnauthorized(t *testing.T) {
+	logs := captureLog(t, zerolog.WarnLevel)
+	for _, token := range []string{"", "guess"} {
+		sampled, front, back := forceSampled(t, token)
+		if sampled || len(front) != 0 || len(back) != 0 {
+			t.Errorf("token %q: backend sampled %v, %d frontend and %d backend spans reported, want none", token, sampled, len(front), len(back))
+		}
+	}
+	if n := strings.Count(logs.String(), "Ignoring "+ForceSampleKey); n != 1 {
+		t.Errorf("logged %d ignored tokens, want 1: %s", n, logs)
+	}
+}
@@ -0,0 +1,98 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// HedgingUnaryClientInterceptor returns a client interceptor that, for the
+// given full method names (e.g. "/geo.Geo/Nearby"), sends a backup request
+// when a call has not completed after delay, up to maxHedges backups spaced
+// by delay. The first attempt to succeed wins and the others are cancelled;
+// once all the attempts sent have failed, the error of the first one to fail
+// is returned without sending more. Spans are tagged with the number of
+// attempts as hedge.attempts and with hedge.won, true if a backup rather
+// than the original request won.
+//
+// Other methods, and all of them if delay or maxHedges is zero or less, are
+// invoked once. Only list read-only methods: every attempt reaches the
+// backend.
+func HedgingUnaryClientInterceptor(delay time.Duration, maxHedges int, methods ...string) grpc.UnaryClientInterceptor {
+	hedged := make(map[string]bool, len(methods))
+	for _, m := range methods {
+		hedged[m] = true
+	}
+
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		out, ok := reply.(proto.Message)
+		if delay <= 0 || maxHedges <= 0 || !hedged[method] || !ok {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		hedgeCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		type result struct {
+			attempt int
+			reply   proto.Message
+			err     error
+		}
+		// Buffered so that the attempts losing the race never block.
+		results := make(chan result, maxHedges+1)
+		launch := func(attempt int) {
+			attemptReply := out.ProtoReflect().New().Interface()
+			go func() {
+				err := invoker(hedgeCtx, method, req, attemptReply, cc, opts...)
+				results <- result{attempt, attemptReply, err}
+			}()
+		}
+
+		launch(0)
+		launched, pending := 1, 1
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		var firstErr error
+		for {
+			select {
+			case <-timer.C:
+				if launched > maxHedges {
+					continue
+				}
+				Logger(ctx).Debug().Msgf("%s: no reply after %v, sending hedge %d", method, time.Duration(launched)*delay, launched)
+				launch(launched)
+				launched++
+				pending++
+				timer.Reset(delay)
+			case res := <-results:
+				pending--
+				if res.err == nil {
+					proto.Reset(out)
+					proto.Merge(out, res.reply)
+					tagHedge(ctx, launched, res.attempt > 0)
+					return nil
+				}
+				if firstErr == nil {
+					firstErr = res.err
+				}
+				if pending == 0 {
+					tagHedge(ctx, launched, false)
+					return firstErr
+				}
+			}
+		}
+	}
+}
+
+// tagHedge records on the span in ctx how many attempts a hedged call made
+// and whether a backup won.
+func tagHedge(ctx context.Context, attempts int, won bool) {
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		span.SetTag("hedge.attempts", attempts)
+		span.SetTag("hedge.won", won)
+	}
+}
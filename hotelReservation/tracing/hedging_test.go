@@ -0,0 +1,173 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// attempt is how an attempt of a hedged call goes: it replies with its
+// number, or fails with err, after d.
+type attempt struct {
+	d   time.Duration
+	err error
+}
+
+// hedgedBackend answers the attempts of calls as scripted, in the order
+// they are sent, and records which were cancelled.
+type hedgedBackend struct {
+	attempts []attempt
+
+	mu        sync.Mutex
+	sent      int
+	cancelled []int
+	done      sync.WaitGroup
+}
+
+func (b *hedgedBackend) invoke(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+	b.mu.Lock()
+	n := b.sent
+	b.sent++
+	b.done.Add(1)
+	b.mu.Unlock()
+	defer b.done.Done()
+
+	a := b.attempts[n]
+	select {
+	case <-time.After(a.d):
+	case <-ctx.Done():
+		b.mu.Lock()
+		b.cancelled = append(b.cancelled, n)
+		b.mu.Unlock()
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	if a.err != nil {
+		return a.err
+	}
+	reply.(*wrapperspb.Int32Value).Value = int32(n)
+	return nil
+}
+
+// hedge calls method through a hedging interceptor of delay and maxHedges
+// for "/test.Test/Read", with backend answering attempts, and returns the
+// number of the attempt that replied, the tags of the span of the call and
+// its error once every attempt returned.
+func hedge(t *testing.T, method string, delay time.Duration, maxHedges int, b *hedgedBackend) (int32, map[string]interface{}, error) {
+	t.Helper()
+	interceptor := HedgingUnaryClientInterceptor(delay, maxHedges, "/test.Test/Read")
+	ctx, span := withMockSpan(context.Background())
+	reply := new(wrapperspb.Int32Value)
+	reply.Value = -1
+	err := interceptor(ctx, method, new(wrapperspb.Int32Value), reply, nil, b.invoke)
+	b.done.Wait()
+	return reply.Value, span.Tags(), err
+}
+
+func TestHedgingSlowPrimary(t *testing.T) {
+	b := &hedgedBackend{attempts: []attempt{{d: time.Second}, {d: 10 * time.Millisecond}}}
+	start := time.Now()
+	got, tags, err := hedge(t, "/test.Test/Read", 20*time.Millisecond, 1, b)
+	if err != nil {
+		t.Fatalf("hedged call: %v", err)
+	}
+	if got != 1 || tags["hedge.won"] != true || tags["hedge.attempts"] != 2 {
+		t.Errorf("got reply of attempt %d, tags %v, want the hedge to win of 2 attempts", got, tags)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("hedged call took %v, want about 30ms", elapsed)
+	}
+	if len(b.cancelled) != 1 || b.cancelled[0] != 0 {
+		t.Errorf("cancelled attempts %v, want the primary", b.cancelled)
+	}
+}
+
+func TestHedgingSeveralHedges(t *testing.T) {
+	b := &hedgedBackend{attempts: []attempt{{d: time.Second}, {d: time.Second}, {d: 5 * time.Millisecond}}}
+	got, tags, err := hedge(t, "/test.Test/Read", 20*time.Millisecond, 3, b)
+	if err != nil {
+		t.Fatalf("hedged call: %v", err)
+	}
+	if got != 2 || tags["hedge.won"] != true || tags["hedge.attempts"] != 3 {
+		t.Errorf("got reply of attempt %d, tags %v, want the second hedge to win of 3 attempts", got, tags)
+	}
+	if b.sent != 3 || len(b.cancelled) != 2 {
+		t.Errorf("sent %d attempts, cancelled %v, want 3 sent and the 2 slow ones cancelled", b.sent, b.cancelled)
+	}
+}
+
+func TestHedgingFastPrimary(t *testing.T) {
+	b := &hedgedBackend{attempts: []attempt{{d: time.Millisecond}}}
+	got, tags, err := hedge(t, "/test.Test/Read", 50*time.Millisecond, 2, b)
+	if err != nil || got != 0 {
+		t.Fatalf("got reply of attempt %d, %v, want the primary's", got, err)
+	}
+	if b.sent != 1 || tags["hedge.won"] != false || tags["hedge.attempts"] != 1 {
+		t.Errorf("sent %d attempts, tags %v, want the primary only", b.sent, tags)
+	}
+}
+
+func TestHedgingPrimaryWinsAfterHedge(t *testing.T) {
+	b := &hedgedBackend{attempts: []attempt{{d: 40 * time.Millisecond}, {d: time.Second}}}
+	got, tags, err := hedge(t, "/test.Test/Read", 20*time.Millisecond, 1, b)
+	if err != nil || got != 0 {
+		t.Fatalf("got reply of attempt %d, %v, want the primary's", got, err)
+	}
+	if tags["hedge.won"] != false || tags["hedge.attempts"] != 2 {
+		t.Errorf("tags %v, want the primary to win of 2 attempts", tags)
+	}
+	if len(b.cancelled) != 1 || b.cancelled[0] != 1 {
+		t.Errorf("cancelled attempts %v, want the hedge", b.cancelled)
+	}
+}
+
+func TestHedgingFailures(t *testing.T) {
+	primaryErr := status.Error(codes.Unavailable, "primary failed")
+	hedgeErr := status.Error(codes.Unavailable, "hedge failed")
+
+	// a hedge succeeding after the primary failed wins
+	b := &hedgedBackend{attempts: []attempt{{d: 30 * time.Millisecond, err: primaryErr}, {d: 30 * time.Millisecond}}}
+	if got, _, err := hedge(t, "/test.Test/Read", 20*time.Millisecond, 1, b); err != nil || got != 1 {
+		t.Errorf("got reply of attempt %d, %v, want the hedge's", got, err)
+	}
+
+	// once all the attempts failed, the first error is returned
+	b = &hedgedBackend{attempts: []attempt{{d: 60 * time.Millisecond, err: primaryErr}, {d: 10 * time.Millisecond, err: hedgeErr}}}
+	if _, tags, err := hedge(t, "/test.Test/Read", 20*time.Millisecond, 1, b); !errors.Is(err, hedgeErr) || tags["hedge.won"] != false {
+		t.Errorf("got %v, tags %v, want the error of the hedge, failing first", err, tags)
+	}
+
+	// and no more attempts are sent
+	b = &hedgedBackend{attempts: []attempt{{d: time.Millisecond, err: primaryErr}}}
+	if _, _, err := hedge(t, "/test.Test/Read", 20*time.Millisecond, 3, b); !errors.Is(err, primaryErr) || b.sent != 1 {
+		t.Errorf("got %v after %d attempts, want the error of the primary only", err, b.sent)
+	}
+}
+
+func TestHedgingOnlyListedMethods(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		method    string
+		delay     time.Duration
+		maxHedges int
+	}{
+		{"unlisted method", "/test.Test/Write", 10 * time.Millisecond, 2},
+		{"no delay", "/test.Test/Read", 0, 2},
+		{"no hedges", "/test.Test/Read", 10 * time.Millisecond, 0},
+	} {
+		b := &hedgedBackend{attempts: []attempt{{d: 50 * time.Millisecond}}}
+		got, tags, err := hedge(t, tt.method, tt.delay, tt.maxHedges, b)
+		if err != nil || got != 0 || b.sent != 1 {
+			t.Errorf("%s: got reply of attempt %d, %v after %d attempts, want the primary's only", tt.name, got, err, b.sent)
+		}
+		if _, ok := tags["hedge.attempts"]; ok {
+			t.Errorf("%s: got tags %v of a call not hedged", tt.name, tags)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package tracing
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// DefaultSizeBuckets are the histogram bucket upper bounds, in bytes, used by
+// DefaultSizeHistograms.
+var DefaultSizeBuckets = []int{64, 128, 256, 512, 1024, 2048, 4096, 8192, 16384, 65536, 262144, 1048576}
+
+// DefaultSizeHistograms is the collector fed by the size tagging interceptors.
+var DefaultSizeHistograms = NewSizeHistogramCollector(DefaultSizeBuckets...)
+
+// SizeHistogramCollector records request and response sizes into per-method
+// histograms. It is safe for concurrent use.
+type SizeHistogramCollector struct {
+	buckets []int
+
+	mu      sync.Mutex
+	methods map[string]*methodSizes
+}
+
+type methodSizes struct {
+	req  *sizeHistogram
+	resp *sizeHistogram
+}
+
+// NewSizeHistogramCollector returns a collector using the given bucket upper
+// bounds in bytes. Sizes above the largest bound go to an overflow bucket.
+func NewSizeHistogramCollector(buckets ...int) *SizeHistogramCollector {
+	b := append([]int(nil), buckets...)
+	sort.Ints(b)
+	return &SizeHistogramCollector{
+		buckets: b,
+		methods: make(map[string]*methodSizes),
+	}
+}
+
+// Observe records one request/response size pair for method. Negative sizes,
+// i.e. non-proto messages, are ignored.
+func (c *SizeHistogramCollector) Observe(method string, reqSize, respSize int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m, ok := c.methods[method]
+	if !ok {
+		m = &methodSizes{req: newSizeHistogram(c.buckets), resp: newSizeHistogram(c.buckets)}
+		c.methods[method] = m
+	}
+	m.req.observe(reqSize)
+	m.resp.observe(respSize)
+}
+
+// SizePercentiles are estimated percentiles of a size distribution in bytes.
+// Each value is the upper bound of the bucket the percentile falls in, or the
+// largest observed size for the overflow bucket.
+type SizePercentiles struct {
+	Count int64 `json:"count"`
+	P50   int   `json:"p50"`
+	P90   int   `json:"p90"`
+	P99   int   `json:"p99"`
+}
+
+// MethodSizeSnapshot holds the size percentiles of one method.
+type MethodSizeSnapshot struct {
+	Request  SizePercentiles `json:"request"`
+	Response SizePercentiles `json:"response"`
+}
+
+// Snapshot returns the current size percentiles of every observed method.
+func (c *SizeHistogramCollector) Snapshot() map[string]MethodSizeSnapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	snap := make(map[string]MethodSizeSnapshot, len(c.methods))
+	for method, m := range c.methods {
+		snap[method] = MethodSizeSnapshot{
+			Request:  m.req.percentiles(),
+			Response: m.resp.percentiles(),
+		}
+	}
+	return snap
+}
+
+// ServeHTTP writes the Snapshot of c as JSON.
+func (c *SizeHistogramCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Use GET", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c.Snapshot())
+}
+
+// sizeHistogram is a fixed-bucket histogram. counts has one more entry than
+// bounds for the overflow bucket.
+type sizeHistogram struct {
+	bounds []int
+	counts []int64
+	total  int64
+	max    int
+}
+
+func newSizeHistogram(bounds []int) *sizeHistogram {
+	return &sizeHistogram{bounds: bounds, counts: make([]int64, len(bounds)+1)}
+}
+
+func (h *sizeHistogram) observe(size int) {
+	if size < 0 {
+		return
+	}
+	i := sort.SearchInts(h.bounds, size)
+	h.counts[i]++
+	h.total++
+	if size > h.max {
+		h.max = size
+	}
+}
+
+func (h *sizeHistogram) percentiles() SizePercentiles {
+	return SizePercentiles{
+		Count: h.total,
+		P50:   h.quantile(0.50),
+		P90:   h.quantile(0.90),
+		P99:   h.quantile(0.99),
+	}
+}
+
+// quantile returns the upper bound of the bucket holding the q-th quantile.
+func (h *sizeHistogram) quantile(q float64) int {
+	if h.total == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(q * float64(h.total)))
+	var cum int64
+	for i, n := range h.counts {
+		cum += n
+		if cum >= rank {
+			if i < len(h.bounds) {
+				return h.bounds[i]
+			}
+			break
+		}
+	}
+	return h.max
+}
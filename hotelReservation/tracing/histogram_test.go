@@ -0,0 +1,65 @@
+package tracing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSizeHistogramPercentiles(t *testing.T) {
+	c := NewSizeHistogramCollector(10000, 100, 1000)
+	observe := func(n, reqSize, respSize int) {
+		for i := 0; i < n; i++ {
+			c.Observe("/test.Test/Call", reqSize, respSize)
+		}
+	}
+	observe(50, 50, 20)
+	observe(40, 500, 20)
+	observe(9, 5000, 20)
+	observe(1, 20000, 30000)
+
+	got := c.Snapshot()["/test.Test/Call"]
+	if want := (SizePercentiles{Count: 100, P50: 100, P90: 1000, P99: 10000}); got.Request != want {
+		t.Errorf("request percentiles = %+v, want %+v", got.Request, want)
+	}
+	if want := (SizePercentiles{Count: 100, P50: 100, P90: 100, P99: 100}); got.Response != want {
+		t.Errorf("response percentiles = %+v, want %+v", got.Response, want)
+	}
+
+	// the largest size stands for the overflow bucket
+	observe(2, 20000, 30000)
+	if got := c.Snapshot()["/test.Test/Call"].Response.P99; got != 30000 {
+		t.Errorf("response p99 = %d once in the overflow bucket, want the largest size 30000", got)
+	}
+}
+
+func TestSizeHistogramIgnoresNonProto(t *testing.T) {
+	c := NewSizeHistogramCollector(DefaultSizeBuckets...)
+	c.Observe("/test.Test/Call", -1, 10)
+	got := c.Snapshot()["/test.Test/Call"]
+	if got.Request.Count != 0 || got.Response.Count != 1 {
+		t.Errorf("counts = %d, %d, want 0, 1", got.Request.Count, got.Response.Count)
+	}
+}
+
+func TestSizeHistogramServeHTTP(t *testing.T) {
+	c := NewSizeHistogramCollector(DefaultSizeBuckets...)
+	c.Observe("/test.Test/Call", 100, 3000)
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/sizes", nil))
+	var snap map[string]MethodSizeSnapshot
+	if err := json.NewDecoder(rec.Body).Decode(&snap); err != nil {
+		t.Fatalf("decoding snapshot: %v", err)
+	}
+	if got := snap["/test.Test/Call"].Response.P50; got != 4096 {
+		t.Errorf("response p50 = %d, want 4096", got)
+	}
+
+	rec = httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/debug/sizes", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST: status %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,44 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"google.golang.org/grpc"
+)
+
+// millis converts d to fractional milliseconds.
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// LatencyTaggingUnaryServerInterceptor tags the span in ctx with the wall-clock
+// duration of the handler, whether or not it returns an error.
+func LatencyTaggingUnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	latency := millis(time.Since(start))
+
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		span.SetTag("grpc.handler.latency_ms", latency)
+	}
+	Logger(ctx).Debug().Msgf("%s: handler latency %.3fms", info.FullMethod, latency)
+
+	return resp, err
+}
+
+// LatencyTaggingUnaryClientInterceptor tags the span in ctx with the wall-clock
+// duration of an outgoing unary RPC.
+func LatencyTaggingUnaryClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	start := time.Now()
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	latency := millis(time.Since(start))
+
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		span.SetTag("grpc.client.latency_ms", latency)
+	}
+	Logger(ctx).Debug().Msgf("%s: client latency %.3fms", method, latency)
+
+	return err
+}
@@ -0,0 +1,80 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+)
+
+// testInfo is the info of the unary RPCs interceptors are tested with.
+var testInfo = &grpc.UnaryServerInfo{FullMethod: "/test.Test/Call"}
+
+func TestLatencyTaggingUnaryServerInterceptor(t *testing.T) {
+	const sleep = 20 * time.Millisecond
+	ctx, span := withMockSpan(context.Background())
+	_, err := LatencyTaggingUnaryServerInterceptor(ctx, nil, testInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+		time.Sleep(sleep)
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("interceptor: %v", err)
+	}
+
+	latency, ok := span.Tag("grpc.handler.latency_ms").(float64)
+	if !ok {
+		t.Fatalf("grpc.handler.latency_ms = %v, want a float64", span.Tag("grpc.handler.latency_ms"))
+	}
+	// allow for a slow scheduler, but not for a unit mix-up
+	if latency < millis(sleep) || latency > 10*millis(sleep) {
+		t.Errorf("grpc.handler.latency_ms = %.3f, want about %.3f", latency, millis(sleep))
+	}
+}
+
+func TestLatencyTaggingUnaryClientInterceptor(t *testing.T) {
+	const sleep = 20 * time.Millisecond
+	ctx, span := withMockSpan(context.Background())
+	err := LatencyTaggingUnaryClientInterceptor(ctx, testInfo.FullMethod, nil, nil, nil, func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		time.Sleep(sleep)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("interceptor: %v", err)
+	}
+
+	latency, _ := span.Tag("grpc.client.latency_ms").(float64)
+	if latency < millis(sleep) || latency > 10*millis(sleep) {
+		t.Errorf("grpc.client.latency_ms = %.3f, want about %.3f", latency, millis(sleep))
+	}
+}
+
+func TestTaggingLogsOnlyAtDebug(t *testing.T) {
+	call := func() {
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+		invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			return nil
+		}
+		LatencyTaggingUnaryServerInterceptor(context.Background(), nil, testInfo, handler)
+		SizeTaggingUnaryServerInterceptor(context.Background(), nil, testInfo, handler)
+		LatencyTaggingUnaryClientInterceptor(context.Background(), testInfo.FullMethod, nil, nil, nil, invoker)
+		SizeTaggingUnaryClientInterceptor(context.Background(), testInfo.FullMethod, nil, nil, nil, invoker)
+	}
+
+	// every request passes through them, so they stay quiet by default
+	logs := captureLog(t, zerolog.InfoLevel)
+	call()
+	if logs.Len() != 0 {
+		t.Errorf("tagging logged at info level: %s", logs)
+	}
+
+	logs = captureLog(t, zerolog.DebugLevel)
+	call()
+	for _, msg := range []string{"handler latency", "client latency", "request size"} {
+		if !strings.Contains(logs.String(), msg) {
+			t.Errorf("%q not logged at debug level: %s", msg, logs)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package tracing
+
+import (
+	"context"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/uber/jaeger-client-go"
+)
+
+// Logger returns the global logger with the trace_id and span_id of the
+// Jaeger span in ctx, so log lines of a request can be matched to its
+// trace, and with the request_id of the request, as set by the request ID
+// interceptors. Without such a span or ID, the fields are omitted.
+func Logger(ctx context.Context) *zerolog.Logger {
+	traceID, spanID, ok := spanIDs(ctx)
+	requestID := RequestIDFromContext(ctx)
+	if !ok && requestID == "" {
+		return &log.Logger
+	}
+	c := log.With()
+	if ok {
+		c = c.Str("trace_id", traceID).Str("span_id", spanID)
+	}
+	if requestID != "" {
+		c = c.Str("request_id", requestID)
+	}
+	l := c.Logger()
+	return &l
+}
+
+// spanIDs returns the trace and span IDs of the span in ctx.
+func spanIDs(ctx context.Context) (string, string, bool) {
+	span := opentracing.SpanFromContext(ctx)
+	if span == nil {
+		return "", "", false
+	}
+	sc, ok := span.Context().(jaeger.SpanContext)
+	if !ok || !sc.IsValid() {
+		return "", "", false
+	}
+	return sc.TraceID().String(), sc.SpanID().String(), true
+}
@@ -0,0 +1,87 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/uber/jaeger-client-go"
+)
+
+// logLine logs a line with the logger of ctx, returning its fields.
+func logLine(t *testing.T, ctx context.Context) map[string]interface{} {
+	t.Helper()
+	logs := captureLog(t, zerolog.DebugLevel)
+	Logger(ctx).Info().Msg("handling")
+	var fields map[string]interface{}
+	if err := json.Unmarshal(logs.Bytes(), &fields); err != nil {
+		t.Fatalf("decoding %s: %v", logs, err)
+	}
+	return fields
+}
+
+func TestLoggerSpanIDs(t *testing.T) {
+	tracer, closer := jaeger.NewTracer("test", jaeger.NewConstSampler(true), jaeger.NewNullReporter())
+	defer closer.Close()
+	span := tracer.StartSpan("GetProfiles")
+	defer span.Finish()
+	sc := span.Context().(jaeger.SpanContext)
+
+	fields := logLine(t, opentracing.ContextWithSpan(context.Background(), span))
+	if fields["trace_id"] != sc.TraceID().String() || fields["span_id"] != sc.SpanID().String() {
+		t.Errorf("got trace_id %v, span_id %v, want %v and %v", fields["trace_id"], fields["span_id"], sc.TraceID(), sc.SpanID())
+	}
+	if _, ok := fields["request_id"]; ok {
+		t.Errorf("got request_id %v without a request ID", fields["request_id"])
+	}
+}
+
+func TestLoggerRequestID(t *testing.T) {
+	tracer, closer := jaeger.NewTracer("test", jaeger.NewConstSampler(true), jaeger.NewNullReporter())
+	defer closer.Close()
+	span := tracer.StartSpan("GetProfiles")
+	defer span.Finish()
+
+	ctx := ContextWithRequestID(opentracing.ContextWithSpan(context.Background(), span), "req-1")
+	fields := logLine(t, ctx)
+	if fields["request_id"] != "req-1" || fields["trace_id"] == nil {
+		t.Errorf("got fields %v, want the request ID and the span IDs", fields)
+	}
+}
+
+func TestLoggerWithoutSpan(t *testing.T) {
+	for name, ctx := range map[string]context.Context{
+		"no span":         context.Background(),
+		"non-jaeger span": opentracing.ContextWithSpan(context.Background(), mocktracer.New().StartSpan("GetProfiles")),
+	} {
+		fields := logLine(t, ctx)
+		for _, key := range []string{"trace_id", "span_id", "request_id"} {
+			if _, ok := fields[key]; ok {
+				t.Errorf("%s: got %s %v, want it omitted", name, key, fields[key])
+			}
+		}
+		if fields["message"] != "handling" {
+			t.Errorf("%s: got line %v, want the message", name, fields)
+		}
+	}
+}
+
+func TestLoggerFormat(t *testing.T) {
+	// the logger of the interceptors follows the format of the global one
+	t.Setenv("LOG_FORMAT", "console")
+	logs := captureLog(t, zerolog.DebugLevel)
+	log.Logger = tune.NewLogger(logs)
+
+	Logger(ContextWithRequestID(context.Background(), "req-1")).Info().Msg("handling")
+	line := regexp.MustCompile(`\x1b\[[0-9;]*m`).ReplaceAllString(logs.String(), "")
+	if strings.HasPrefix(line, "{") || !strings.Contains(line, "handling") || !strings.Contains(line, "request_id=req-1") {
+		t.Errorf("got %q, want a console line with the request ID", line)
+	}
+}
@@ -0,0 +1,202 @@
+package tracing
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultLatencyBuckets are the latency histogram bucket upper bounds, in
+// seconds, used by DefaultMetrics.
+var DefaultLatencyBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// DefaultMetrics is the registry fed by the services' metrics interceptor.
+var DefaultMetrics = NewMetricsRegistry(DefaultLatencyBuckets...)
+
+// MetricsRegistry counts the RPCs handled per method, and their errors per
+// status code, and records their latency into histograms. It serves them in
+// the Prometheus text exposition format. It is safe for concurrent use.
+type MetricsRegistry struct {
+	buckets []float64
+	created time.Time
+
+	mu       sync.Mutex
+	methods  map[string]*methodMetrics
+	counters []counterFunc
+}
+
+type counterFunc struct {
+	name  string
+	help  string
+	kind  string
+	value func() uint64
+}
+
+type methodMetrics struct {
+	requests uint64
+	errors   map[codes.Code]uint64
+	// counts[i] counts the latencies in (buckets[i-1], buckets[i]], and the
+	// last one those above the largest bound.
+	counts []uint64
+	sum    float64
+}
+
+// NewMetricsRegistry returns a registry using the given latency bucket upper
+// bounds in seconds.
+func NewMetricsRegistry(buckets ...float64) *MetricsRegistry {
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+	return &MetricsRegistry{
+		buckets: b,
+		created: time.Now(),
+		methods: make(map[string]*methodMetrics),
+	}
+}
+
+// Observe records one RPC to method that finished with code after latency.
+func (r *MetricsRegistry) Observe(method string, code codes.Code, latency time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m, ok := r.methods[method]
+	if !ok {
+		m = &methodMetrics{
+			errors: make(map[codes.Code]uint64),
+			counts: make([]uint64, len(r.buckets)+1),
+		}
+		r.methods[method] = m
+	}
+
+	m.requests++
+	if code != codes.OK {
+		m.errors[code]++
+	}
+	seconds := latency.Seconds()
+	m.counts[sort.SearchFloat64s(r.buckets, seconds)]++
+	m.sum += seconds
+}
+
+// RegisterCounter adds a counter to the metrics served by r, read from value
+// at every scrape.
+func (r *MetricsRegistry) RegisterCounter(name, help string, value func() uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counters = append(r.counters, counterFunc{name: name, help: help, kind: "counter", value: value})
+}
+
+// RegisterGauge adds a gauge to the metrics served by r, read from value at
+// every scrape.
+func (r *MetricsRegistry) RegisterGauge(name, help string, value func() uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counters = append(r.counters, counterFunc{name: name, help: help, kind: "gauge", value: value})
+}
+
+// MetricsUnaryServerInterceptor records every RPC into r. Methods are
+// labelled by info.FullMethod, so there are only as many series as the
+// server has methods.
+func MetricsUnaryServerInterceptor(r *MetricsRegistry) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		r.Observe(info.FullMethod, status.Code(err), time.Since(start))
+		return resp, err
+	}
+}
+
+// ServeHTTP writes the metrics of r in the Prometheus text format.
+func (r *MetricsRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	r.write(bw)
+	bw.Flush()
+}
+
+func (r *MetricsRegistry) write(w *bufio.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	methods := make([]string, 0, len(r.methods))
+	for method := range r.methods {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+
+	fmt.Fprintln(w, "# HELP grpc_server_requests_total Total number of RPCs handled, per method.")
+	fmt.Fprintln(w, "# TYPE grpc_server_requests_total counter")
+	for _, method := range methods {
+		fmt.Fprintf(w, "grpc_server_requests_total{method=%s} %d\n", quoteLabel(method), r.methods[method].requests)
+	}
+
+	fmt.Fprintln(w, "# HELP grpc_server_errors_total Total number of failed RPCs, per method and status code.")
+	fmt.Fprintln(w, "# TYPE grpc_server_errors_total counter")
+	for _, method := range methods {
+		m := r.methods[method]
+		failed := make([]codes.Code, 0, len(m.errors))
+		for code := range m.errors {
+			failed = append(failed, code)
+		}
+		sort.Slice(failed, func(i, j int) bool { return failed[i] < failed[j] })
+		for _, code := range failed {
+			fmt.Fprintf(w, "grpc_server_errors_total{method=%s,code=%s} %d\n", quoteLabel(method), quoteLabel(code.String()), m.errors[code])
+		}
+	}
+
+	fmt.Fprintln(w, "# HELP grpc_server_handling_seconds Latency of the RPCs handled, per method.")
+	fmt.Fprintln(w, "# TYPE grpc_server_handling_seconds histogram")
+	for _, method := range methods {
+		m := r.methods[method]
+		label := quoteLabel(method)
+		var cumulative uint64
+		for i, bound := range r.buckets {
+			cumulative += m.counts[i]
+			fmt.Fprintf(w, "grpc_server_handling_seconds_bucket{method=%s,le=%s} %d\n", label, quoteLabel(strconv.FormatFloat(bound, 'g', -1, 64)), cumulative)
+		}
+		fmt.Fprintf(w, "grpc_server_handling_seconds_bucket{method=%s,le=\"+Inf\"} %d\n", label, m.requests)
+		fmt.Fprintf(w, "grpc_server_handling_seconds_sum{method=%s} %s\n", label, strconv.FormatFloat(m.sum, 'g', -1, 64))
+		fmt.Fprintf(w, "grpc_server_handling_seconds_count{method=%s} %d\n", label, m.requests)
+	}
+
+	for _, c := range r.counters {
+		fmt.Fprintf(w, "# HELP %s %s\n", c.name, c.help)
+		fmt.Fprintf(w, "# TYPE %s %s\n", c.name, c.kind)
+		fmt.Fprintf(w, "%s %d\n", c.name, c.value())
+	}
+}
+
+// quoteLabel quotes a label value, escaping backslashes, double quotes and
+// newlines as the text format requires.
+func quoteLabel(v string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v) + `"`
+}
+
+// ServeMetrics serves r at /metrics, DefaultSizeHistograms at /sizes,
+// DefaultDeadLetters at /deadletters, DefaultChaos at /chaos,
+// DefaultAccessLog at /accesslog and DefaultFlags at /flags, on an admin
+// HTTP port, separate from the service's gRPC port. It is meant to run in
+// its own goroutine.
+func ServeMetrics(port int, r *MetricsRegistry) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", r)
+	mux.Handle("/sizes", DefaultSizeHistograms)
+	mux.Handle("/deadletters", DefaultDeadLetters)
+	mux.Handle("/chaos", DefaultChaos)
+	mux.Handle("/accesslog", DefaultAccessLog)
+	mux.Handle("/flags", DefaultFlags)
+
+	log.Info().Msgf("Serving metrics on :%d/metrics", port)
+	if err := http.ListenAndServe(fmt.Sprintf(":%d", port), mux); err != nil {
+		log.Error().Msgf("Metrics server failed: %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package tracing
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// scrape returns the lines r serves.
+func scrape(r *MetricsRegistry) []string {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	return strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+}
+
+// hasLine reports whether lines has line.
+func hasLine(lines []string, line string) bool {
+	for _, l := range lines {
+		if l == line {
+			return true
+		}
+	}
+	return false
+}
+
+func TestMetricsInterceptor(t *testing.T) {
+	r := NewMetricsRegistry(.01, .1, 1)
+	interceptor := MetricsUnaryServerInterceptor(r)
+	get := &grpc.UnaryServerInfo{FullMethod: "/test.Test/Get"}
+	put := &grpc.UnaryServerInfo{FullMethod: "/test.Test/Put"}
+
+	ok := func(ctx context.Context, req interface{}) (interface{}, error) { return "ok", nil }
+	slow := func(ctx context.Context, req interface{}) (interface{}, error) {
+		time.Sleep(20 * time.Millisecond)
+		return "ok", nil
+	}
+	notFound := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "not found")
+	}
+	for _, call := range []struct {
+		info    *grpc.UnaryServerInfo
+		handler grpc.UnaryHandler
+	}{
+		{get, ok}, {get, ok}, {get, slow}, {get, notFound}, {put, notFound},
+	} {
+		interceptor(context.Background(), nil, call.info, call.handler)
+	}
+
+	lines := scrape(r)
+	for _, want := range []string{
+		`# TYPE grpc_server_requests_total counter`,
+		`grpc_server_requests_total{method="/test.Test/Get"} 4`,
+		`grpc_server_requests_total{method="/test.Test/Put"} 1`,
+		`grpc_server_errors_total{method="/test.Test/Get",code="NotFound"} 1`,
+		`grpc_server_errors_total{method="/test.Test/Put",code="NotFound"} 1`,
+		`# TYPE grpc_server_handling_seconds histogram`,
+		`grpc_server_handling_seconds_bucket{method="/test.Test/Get",le="0.01"} 3`,
+		`grpc_server_handling_seconds_bucket{method="/test.Test/Get",le="0.1"} 4`,
+		`grpc_server_handling_seconds_bucket{method="/test.Test/Get",le="1"} 4`,
+		`grpc_server_handling_seconds_bucket{method="/test.Test/Get",le="+Inf"} 4`,
+		`grpc_server_handling_seconds_count{method="/test.Test/Get"} 4`,
+	} {
+		if !hasLine(lines, want) {
+			t.Errorf("scrape missing %s", want)
+		}
+	}
+	for _, line := range lines {
+		if strings.HasPrefix(line, "grpc_server_errors_total") && strings.Contains(line, `code="OK"`) {
+			t.Errorf("successful RPCs counted as errors: %s", line)
+		}
+	}
+}
+
+func TestMetricsRegisteredCounters(t *testing.T) {
+	r := NewMetricsRegistry(DefaultLatencyBuckets...)
+	hits := uint64(0)
+	r.RegisterCounter("cache_hits_total", "Cache hits.", func() uint64 { return hits })
+	r.RegisterGauge("inflight_requests", "Requests being handled.", func() uint64 { return 3 })
+
+	hits = 7
+	lines := scrape(r)
+	for _, want := range []string{
+		`# TYPE cache_hits_total counter`,
+		`cache_hits_total 7`,
+		`# TYPE inflight_requests gauge`,
+		`inflight_requests 3`,
+	} {
+		if !hasLine(lines, want) {
+			t.Errorf("scrape missing %s", want)
+		}
+	}
+}
+
+func TestQuoteLabel(t *testing.T) {
+	if got, want := quoteLabel("a\"b\\c\nd"), `"a\"b\\c\nd"`; got != want {
+		t.Errorf("quoteLabel = %s, want %s", got, want)
+	}
+}
@@ -17,6 +17,7 @@ package tracing
 import (
 	"net/http"
 
+	"github.com/google/uuid"
 	"github.com/opentracing-contrib/go-stdlib/nethttp"
 	opentracing "github.com/opentracing/opentracing-go"
 )
@@ -39,7 +40,7 @@ type TracedServeMux struct {
 func (tm *TracedServeMux) Handle(pattern string, handler http.Handler) {
 	middleware := nethttp.Middleware(
 		tm.tracer,
-		handler,
+		withRequestID(withTenant(handler)),
 		nethttp.OperationNameFunc(func(r *http.Request) string {
 			return "HTTP " + r.Method + " " + pattern
 		}))
@@ -50,3 +51,19 @@ func (tm *TracedServeMux) Handle(pattern string, handler http.Handler) {
 func (tm *TracedServeMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	tm.mux.ServeHTTP(w, r)
 }
+
+// withRequestID stores the request ID from the X-Request-Id header, or a new
+// one if absent, in the request context and echoes it in the response.
+func withRequestID(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDKey)
+		if id == "" {
+			id = uuid.New().String()
+		}
+		w.Header().Set(RequestIDKey, id)
+		if span := opentracing.SpanFromContext(r.Context()); span != nil {
+			span.SetTag("request.id", id)
+		}
+		handler.ServeHTTP(w, r.WithContext(ContextWithRequestID(r.Context(), id)))
+	})
+}
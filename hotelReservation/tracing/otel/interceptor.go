@@ -0,0 +1,102 @@
+// Package otel provides the tracing interceptors implemented against the
+// OpenTelemetry API instead of OpenTracing. The tagging interceptors take the
+// span from the context, as started by ServerUnaryServerInterceptor or
+// ClientUnaryClientInterceptor, and only set attributes: logging, metrics and
+// histograms are left to the interceptors of package tracing, which run
+// alongside them whatever the backend.
+package otel
+
+import (
+	"context"
+	"runtime/debug"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// messageSize returns the wire size of msg, or -1 if msg is not a proto message.
+func messageSize(msg interface{}) int {
+	if m, ok := msg.(proto.Message); ok {
+		return proto.Size(m)
+	}
+	return -1
+}
+
+// SizeTaggingUnaryServerInterceptor sets the size of the request and response
+// messages of a unary RPC as attributes of the span in ctx.
+func SizeTaggingUnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.Int("grpc.request.size", messageSize(req)),
+		attribute.Int("grpc.response.size", messageSize(resp)),
+	)
+
+	return resp, err
+}
+
+// SizeTaggingUnaryClientInterceptor sets the size of the request and reply
+// messages of an outgoing unary RPC as attributes of the span in ctx.
+func SizeTaggingUnaryClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	err := invoker(ctx, method, req, reply, cc, opts...)
+
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.Int("grpc.request.size", messageSize(req)),
+		attribute.Int("grpc.response.size", messageSize(reply)),
+	)
+
+	return err
+}
+
+// LatencyTaggingUnaryServerInterceptor sets the wall-clock duration of the
+// handler as an attribute of the span in ctx.
+func LatencyTaggingUnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	latency := float64(time.Since(start)) / float64(time.Millisecond)
+
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Float64("grpc.handler.latency_ms", latency))
+
+	return resp, err
+}
+
+// StatusTaggingUnaryServerInterceptor records the gRPC status code returned by
+// the handler on the span in ctx, setting the span status to Error on failure.
+func StatusTaggingUnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.String("grpc.status_code", status.Code(err).String()))
+	if err != nil {
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+
+	return resp, err
+}
+
+// RecoveryUnaryServerInterceptor converts a panic in the handler into an
+// Internal error. The panic and its stack are logged and recorded on the span
+// in ctx.
+func RecoveryUnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			stack := string(debug.Stack())
+			span := trace.SpanFromContext(ctx)
+			span.SetStatus(otelcodes.Error, "panic")
+			span.SetAttributes(attribute.Bool("error", true), attribute.String("panic.stack", stack))
+			log.Error().Str("stack", stack).Msgf("%s: recovered from panic: %v", info.FullMethod, r)
+
+			resp = nil
+			err = status.Errorf(codes.Internal, "panic in %s: %v", info.FullMethod, r)
+		}
+	}()
+
+	return handler(ctx, req)
+}
@@ -0,0 +1,190 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+var testInfo = &grpc.UnaryServerInfo{FullMethod: "/test.Test/Call"}
+
+// newExporter returns a tracer whose spans are exported to the in-memory
+// exporter returned as they end.
+func newExporter(t *testing.T) (trace.Tracer, *tracetest.InMemoryExporter) {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() { tp.Shutdown(context.Background()) })
+	return tp.Tracer("test"), exporter
+}
+
+// intercept calls interceptor with handler in a span of tracer, returning
+// the span once ended.
+func intercept(t *testing.T, tracer trace.Tracer, exporter *tracetest.InMemoryExporter, interceptor grpc.UnaryServerInterceptor, req interface{}, handler grpc.UnaryHandler) (tracetest.SpanStub, error) {
+	t.Helper()
+	exporter.Reset()
+	ctx, span := tracer.Start(context.Background(), "call")
+	_, err := interceptor(ctx, req, testInfo, handler)
+	span.End()
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	return spans[0], err
+}
+
+// attr returns the value of the attribute key of span, if set.
+func attr(span tracetest.SpanStub, key string) (attribute.Value, bool) {
+	for _, kv := range span.Attributes {
+		if string(kv.Key) == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestSizeTaggingUnaryServerInterceptor(t *testing.T) {
+	tracer, exporter := newExporter(t)
+	req := &healthpb.HealthCheckRequest{Service: "geo"}
+	resp := &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}
+	span, _ := intercept(t, tracer, exporter, SizeTaggingUnaryServerInterceptor, req, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return resp, nil
+	})
+	if v, ok := attr(span, "grpc.request.size"); !ok || v.AsInt64() != 5 {
+		t.Errorf("grpc.request.size = %v, want 5", v.Emit())
+	}
+	if v, ok := attr(span, "grpc.response.size"); !ok || v.AsInt64() != 2 {
+		t.Errorf("grpc.response.size = %v, want 2", v.Emit())
+	}
+
+	// not proto messages
+	span, _ = intercept(t, tracer, exporter, SizeTaggingUnaryServerInterceptor, "req", func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	if v, ok := attr(span, "grpc.request.size"); !ok || v.AsInt64() != -1 {
+		t.Errorf("grpc.request.size = %v, want -1", v.Emit())
+	}
+}
+
+func TestLatencyTaggingUnaryServerInterceptor(t *testing.T) {
+	tracer, exporter := newExporter(t)
+	span, _ := intercept(t, tracer, exporter, LatencyTaggingUnaryServerInterceptor, nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, errors.New("failed")
+	})
+	if v, ok := attr(span, "grpc.handler.latency_ms"); !ok || v.AsFloat64() < 0 {
+		t.Errorf("grpc.handler.latency_ms = %v, want a latency", v.Emit())
+	}
+}
+
+func TestStatusTaggingUnaryServerInterceptor(t *testing.T) {
+	tracer, exporter := newExporter(t)
+	span, _ := intercept(t, tracer, exporter, StatusTaggingUnaryServerInterceptor, nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	if v, _ := attr(span, "grpc.status_code"); v.AsString() != "OK" {
+		t.Errorf("grpc.status_code = %q, want OK", v.AsString())
+	}
+	if span.Status.Code == otelcodes.Error {
+		t.Error("span of a successful RPC errored")
+	}
+
+	span, _ = intercept(t, tracer, exporter, StatusTaggingUnaryServerInterceptor, nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "no hotel")
+	})
+	if v, _ := attr(span, "grpc.status_code"); v.AsString() != "NotFound" {
+		t.Errorf("grpc.status_code = %q, want NotFound", v.AsString())
+	}
+	if span.Status.Code != otelcodes.Error || !strings.Contains(span.Status.Description, "no hotel") {
+		t.Errorf("span status %v, want Error with the error", span.Status)
+	}
+}
+
+func TestRecoveryUnaryServerInterceptor(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	defer zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	tracer, exporter := newExporter(t)
+	span, err := intercept(t, tracer, exporter, RecoveryUnaryServerInterceptor, nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		panic("bad request")
+	})
+	if status.Code(err) != codes.Internal {
+		t.Errorf("got %v, want Internal", err)
+	}
+	if span.Status.Code != otelcodes.Error {
+		t.Errorf("span status %v, want Error", span.Status)
+	}
+	if v, _ := attr(span, "error"); !v.AsBool() {
+		t.Error("span not tagged error")
+	}
+	if v, _ := attr(span, "panic.stack"); !strings.Contains(v.AsString(), "TestRecoveryUnaryServerInterceptor") {
+		t.Errorf("panic.stack = %q, want the stack of the panic", v.AsString())
+	}
+}
+
+func TestSpanPropagation(t *testing.T) {
+	tracer, exporter := newExporter(t)
+	spanName := func(fullMethod string) string { return strings.TrimPrefix(fullMethod, "/") }
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(ServerUnaryServerInterceptor(tracer, spanName), SizeTaggingUnaryServerInterceptor))
+	healthpb.RegisterHealthServer(srv, healthServer{})
+	go srv.Serve(lis)
+	defer srv.Stop()
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure(),
+		grpc.WithChainUnaryInterceptor(ClientUnaryClientInterceptor(tracer, spanName), SizeTaggingUnaryClientInterceptor))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	ctx, parent := tracer.Start(context.Background(), "parent")
+	if _, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	parent.End()
+
+	var client, server tracetest.SpanStub
+	for _, s := range exporter.GetSpans() {
+		switch s.SpanKind {
+		case trace.SpanKindClient:
+			client = s
+		case trace.SpanKindServer:
+			server = s
+		}
+	}
+	if client.Name != "grpc.health.v1.Health/Check" || client.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Fatalf("client span %q with parent %v, want a child of the caller's span %v", client.Name, client.Parent.SpanID(), parent.SpanContext().SpanID())
+	}
+	if server.Name != "grpc.health.v1.Health/Check" || server.Parent.SpanID() != client.SpanContext.SpanID() || !server.Parent.IsRemote() {
+		t.Errorf("server span %q with parent %v, want the remote child of the client span %v", server.Name, server.Parent.SpanID(), client.SpanContext.SpanID())
+	}
+	for _, s := range []tracetest.SpanStub{client, server} {
+		if _, ok := attr(s, "grpc.response.size"); !ok {
+			t.Errorf("%s span not tagged grpc.response.size: %v", s.SpanKind, s.Attributes)
+		}
+	}
+}
+
+// healthServer is always serving.
+type healthServer struct {
+	healthpb.UnimplementedHealthServer
+}
+
+func (healthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
@@ -0,0 +1,73 @@
+package otel
+
+import (
+	"context"
+
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// propagator carries the span context across RPCs as W3C trace context.
+var propagator = propagation.TraceContext{}
+
+// metadataCarrier adapts gRPC metadata to a propagation.TextMapCarrier.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if vals := metadata.MD(c).Get(key); len(vals) > 0 {
+		return vals[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// ServerUnaryServerInterceptor starts a server span with tracer for each RPC,
+// named by spanName after the full method, as a child of the span the
+// caller propagated if any.
+func ServerUnaryServerInterceptor(tracer trace.Tracer, spanName func(fullMethod string) string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		ctx = propagator.Extract(ctx, metadataCarrier(md))
+		ctx, span := tracer.Start(ctx, spanName(info.FullMethod), trace.WithSpanKind(trace.SpanKindServer))
+		defer span.End()
+
+		return handler(ctx, req)
+	}
+}
+
+// ClientUnaryClientInterceptor starts a client span with tracer for each
+// outgoing RPC, named by spanName after the method, and propagates it to the
+// server in the metadata of the call.
+func ClientUnaryClientInterceptor(tracer trace.Tracer, spanName func(fullMethod string) string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, span := tracer.Start(ctx, spanName(method), trace.WithSpanKind(trace.SpanKindClient))
+		defer span.End()
+
+		md, ok := metadata.FromOutgoingContext(ctx)
+		if ok {
+			md = md.Copy()
+		} else {
+			md = metadata.MD{}
+		}
+		propagator.Inject(ctx, metadataCarrier(md))
+		err := invoker(metadata.NewOutgoingContext(ctx, md), method, req, reply, cc, opts...)
+		if err != nil {
+			span.SetStatus(otelcodes.Error, err.Error())
+		}
+		return err
+	}
+}
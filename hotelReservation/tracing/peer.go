@@ -0,0 +1,49 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// ForwardedForKey is the gRPC metadata key proxies set to the address of the
+// client they forward calls from.
+const ForwardedForKey = "x-forwarded-for"
+
+// PeerTaggingUnaryServerInterceptor tags the span in ctx with the address of
+// the caller as peer.address and its user agent as peer.user_agent. Behind
+// proxies, the caller is the first address of x-forwarded-for rather than
+// the peer of the connection. Tags that can't be known are left out.
+func PeerTaggingUnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		if addr := peerAddress(ctx); addr != "" {
+			span.SetTag("peer.address", addr)
+		}
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if ua := md.Get("user-agent"); len(ua) > 0 && ua[0] != "" {
+				span.SetTag("peer.user_agent", ua[0])
+			}
+		}
+	}
+	return handler(ctx, req)
+}
+
+// peerAddress returns the address of the caller in ctx, "" if unknown.
+func peerAddress(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, v := range md.Get(ForwardedForKey) {
+			first, _, _ := strings.Cut(v, ",")
+			if first = strings.TrimSpace(first); first != "" {
+				return first
+			}
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return ""
+}
@@ -0,0 +1,67 @@
+package tracing
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// tagPeer calls through PeerTaggingUnaryServerInterceptor with ctx,
+// returning the span of the call.
+func tagPeer(ctx context.Context) *mocktracer.MockSpan {
+	ctx, span := withMockSpan(ctx)
+	PeerTaggingUnaryServerInterceptor(ctx, nil, testInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	return span
+}
+
+func TestPeerTagging(t *testing.T) {
+	conn := &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.7"), Port: 41000}}
+	for _, tt := range []struct {
+		name      string
+		peer      *peer.Peer
+		md        metadata.MD
+		address   interface{}
+		userAgent interface{}
+	}{
+		{"peer", conn, nil, "10.0.0.7:41000", nil},
+		{"user agent", conn, metadata.Pairs("user-agent", "wrk2/4.0"), "10.0.0.7:41000", "wrk2/4.0"},
+		{"forwarded", conn, metadata.Pairs(ForwardedForKey, "203.0.113.5"), "203.0.113.5", nil},
+		{"forwarded twice", conn, metadata.Pairs(ForwardedForKey, " 203.0.113.5 , 10.0.0.2"), "203.0.113.5", nil},
+		{"empty forwarded", conn, metadata.Pairs(ForwardedForKey, ""), "10.0.0.7:41000", nil},
+		{"forwarded without peer", nil, metadata.Pairs(ForwardedForKey, "203.0.113.5"), "203.0.113.5", nil},
+		{"no peer", nil, nil, nil, nil},
+		{"empty user agent", nil, metadata.Pairs("user-agent", ""), nil, nil},
+	} {
+		ctx := context.Background()
+		if tt.peer != nil {
+			ctx = peer.NewContext(ctx, tt.peer)
+		}
+		if tt.md != nil {
+			ctx = metadata.NewIncomingContext(ctx, tt.md)
+		}
+		span := tagPeer(ctx)
+		if got := span.Tag("peer.address"); got != tt.address {
+			t.Errorf("%s: peer.address = %v, want %v", tt.name, got, tt.address)
+		}
+		if got := span.Tag("peer.user_agent"); got != tt.userAgent {
+			t.Errorf("%s: peer.user_agent = %v, want %v", tt.name, got, tt.userAgent)
+		}
+	}
+}
+
+func TestPeerTaggingWithoutSpan(t *testing.T) {
+	called := false
+	PeerTaggingUnaryServerInterceptor(context.Background(), nil, testInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+		called = true
+		return nil, nil
+	})
+	if !called {
+		t.Error("handler not called")
+	}
+}
@@ -0,0 +1,75 @@
+package tracing
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// tokenBucket allows rps requests per second on average, in bursts of up to
+// burst requests.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rps    float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket returns a full bucket refilled at rps, holding one second
+// of requests and at least one.
+func newTokenBucket(rps float64, now time.Time) *tokenBucket {
+	burst := math.Max(1, math.Ceil(rps))
+	return &tokenBucket{rps: rps, burst: burst, tokens: burst, last: now}
+}
+
+// allow takes a token from the bucket at now, reporting whether there was
+// one.
+func (b *tokenBucket) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed.Seconds()*b.rps)
+		b.last = now
+	}
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// RateLimitUnaryServerInterceptor returns a server interceptor capping the
+// rate of each method in limits, keyed by full method name (e.g.
+// "/profile.Profile/GetProfiles"), to the given requests per second.
+// Requests over the limit fail with ResourceExhausted and tag the span in
+// ctx with ratelimited=true. Methods absent from limits are unlimited.
+func RateLimitUnaryServerInterceptor(limits map[string]float64) grpc.UnaryServerInterceptor {
+	buckets := make(map[string]*tokenBucket, len(limits))
+	now := time.Now()
+	for method, rps := range limits {
+		if rps > 0 {
+			buckets[method] = newTokenBucket(rps, now)
+		}
+	}
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		b, ok := buckets[info.FullMethod]
+		if !ok || b.allow(time.Now()) {
+			return handler(ctx, req)
+		}
+
+		if span := opentracing.SpanFromContext(ctx); span != nil {
+			span.SetTag("ratelimited", true)
+		}
+		Logger(ctx).Warn().Msgf("%s: rejecting request over the rate limit of %v/s", info.FullMethod, b.rps)
+		return nil, status.Errorf(codes.ResourceExhausted, "%s: rate limit of %v requests per second exceeded", info.FullMethod, b.rps)
+	}
+}
@@ -0,0 +1,125 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	b := newTokenBucket(10, now)
+
+	// a burst of a second of requests goes through
+	for i := 0; i < 10; i++ {
+		if !b.allow(now) {
+			t.Fatalf("request %d of the burst rejected", i+1)
+		}
+	}
+	if b.allow(now) {
+		t.Fatal("request 11 of the burst allowed")
+	}
+
+	// then one every 100ms
+	now = now.Add(50 * time.Millisecond)
+	if b.allow(now) {
+		t.Error("request allowed 50ms after the burst")
+	}
+	now = now.Add(50 * time.Millisecond)
+	if !b.allow(now) {
+		t.Error("request rejected 100ms after the burst")
+	}
+	if b.allow(now) {
+		t.Error("second request allowed 100ms after the burst")
+	}
+
+	// an idle bucket fills up to the burst only
+	now = now.Add(time.Hour)
+	allowed := 0
+	for b.allow(now) {
+		allowed++
+	}
+	if allowed != 10 {
+		t.Errorf("allowed %d requests after an hour, want the burst of 10", allowed)
+	}
+}
+
+func TestTokenBucketBelowOnePerSecond(t *testing.T) {
+	now := time.Now()
+	b := newTokenBucket(0.5, now)
+	if !b.allow(now) || b.allow(now) {
+		t.Fatal("want a burst of one request")
+	}
+	if b.allow(now.Add(time.Second)) {
+		t.Error("request allowed after 1s at 0.5/s")
+	}
+	if !b.allow(now.Add(2 * time.Second)) {
+		t.Error("request rejected after 2s at 0.5/s")
+	}
+}
+
+// callLimited calls method through limit with a span, returning the span
+// and the error.
+func callLimited(limit grpc.UnaryServerInterceptor, method string) (*mocktracer.MockSpan, error) {
+	span := mocktracer.New().StartSpan(method).(*mocktracer.MockSpan)
+	ctx := opentracing.ContextWithSpan(context.Background(), span)
+	_, err := limit(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	})
+	return span, err
+}
+
+func TestRateLimitUnaryServerInterceptor(t *testing.T) {
+	const method = "/profile.Profile/GetProfiles"
+	limit := RateLimitUnaryServerInterceptor(map[string]float64{method: 5, "/rate.Rate/GetRates": 0})
+
+	// the rejections begin once the burst of 5 is spent
+	for i := 1; i <= 8; i++ {
+		span, err := callLimited(limit, method)
+		if i <= 5 {
+			if err != nil {
+				t.Errorf("request %d: %v, want it allowed", i, err)
+			}
+			if span.Tag("ratelimited") != nil {
+				t.Errorf("request %d tagged ratelimited", i)
+			}
+			continue
+		}
+		if status.Code(err) != codes.ResourceExhausted {
+			t.Errorf("request %d: got %v, want ResourceExhausted", i, err)
+		}
+		if span.Tag("ratelimited") != true {
+			t.Errorf("request %d: ratelimited = %v, want true", i, span.Tag("ratelimited"))
+		}
+	}
+
+	// other methods are unlimited, as are those of no positive rate
+	for _, other := range []string{"/geo.Geo/Nearby", "/rate.Rate/GetRates"} {
+		for i := 0; i < 100; i++ {
+			if _, err := callLimited(limit, other); err != nil {
+				t.Fatalf("request %d to %s: %v, want it unlimited", i+1, other, err)
+			}
+		}
+	}
+}
+
+func TestRateLimitRecovers(t *testing.T) {
+	const method = "/profile.Profile/GetProfiles"
+	limit := RateLimitUnaryServerInterceptor(map[string]float64{method: 20})
+	for i := 0; i < 20; i++ {
+		callLimited(limit, method)
+	}
+	if _, err := callLimited(limit, method); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("got %v past the burst, want ResourceExhausted", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, err := callLimited(limit, method); err != nil {
+		t.Errorf("got %v 100ms later at 20/s, want the request allowed", err)
+	}
+}
@@ -0,0 +1,52 @@
+package tracing
+
+import (
+	"context"
+	"runtime/debug"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RecoveryUnaryServerInterceptor converts a panic in the handler into an
+// Internal error so that a single bad request cannot take down the server.
+// The panic and its stack are logged and recorded on the span in ctx.
+func RecoveryUnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			stack := string(debug.Stack())
+			if span := opentracing.SpanFromContext(ctx); span != nil {
+				span.SetTag("error", true)
+				span.SetTag("panic.stack", stack)
+			}
+			Logger(ctx).Error().Str("stack", stack).Msgf("%s: recovered from panic: %v", info.FullMethod, r)
+
+			resp = nil
+			err = status.Errorf(codes.Internal, "panic in %s: %v", info.FullMethod, r)
+		}
+	}()
+
+	return handler(ctx, req)
+}
+
+// RecoveryStreamServerInterceptor is RecoveryUnaryServerInterceptor for
+// streams.
+func RecoveryStreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	ctx := ss.Context()
+	defer func() {
+		if r := recover(); r != nil {
+			stack := string(debug.Stack())
+			if span := opentracing.SpanFromContext(ctx); span != nil {
+				span.SetTag("error", true)
+				span.SetTag("panic.stack", stack)
+			}
+			Logger(ctx).Error().Str("stack", stack).Msgf("%s: recovered from panic: %v", info.FullMethod, r)
+
+			err = status.Errorf(codes.Internal, "panic in %s: %v", info.FullMethod, r)
+		}
+	}()
+
+	return handler(srv, ss)
+}
@@ -0,0 +1,83 @@
+package tracing
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// healthFunc is a health service answering Check with its function.
+type healthFunc func(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error)
+
+func (f healthFunc) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	return f(ctx, req)
+}
+
+func (f healthFunc) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	return status.Error(codes.Unimplemented, "no watching")
+}
+
+// serveHealth serves check as the health service on a loopback port for the
+// duration of t, returning a client of it.
+func serveHealth(t *testing.T, check healthFunc, opts ...grpc.ServerOption) healthpb.HealthClient {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srv := grpc.NewServer(opts...)
+	healthpb.RegisterHealthServer(srv, check)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return healthpb.NewHealthClient(conn)
+}
+
+func TestRecoveryUnaryServerInterceptor(t *testing.T) {
+	client := serveHealth(t, func(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+		if req.Service == "panic" {
+			panic("bad request")
+		}
+		return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+	}, grpc.UnaryInterceptor(RecoveryUnaryServerInterceptor))
+
+	_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "panic"})
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("panicking handler: got %v, want Internal", err)
+	}
+
+	// the server survived the panic
+	res, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("after the panic: %v", err)
+	}
+	if res.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("after the panic: got status %v, want SERVING", res.Status)
+	}
+}
+
+func TestRecoveryTagsSpan(t *testing.T) {
+	ctx, span := withMockSpan(context.Background())
+	_, err := RecoveryUnaryServerInterceptor(ctx, nil, testInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+		panic("bad request")
+	})
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("got %v, want Internal", err)
+	}
+	if span.Tag("error") != true {
+		t.Errorf("error = %v, want true", span.Tag("error"))
+	}
+	if stack, _ := span.Tag("panic.stack").(string); stack == "" {
+		t.Errorf("panic.stack not tagged")
+	}
+}
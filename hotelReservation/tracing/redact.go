@@ -0,0 +1,98 @@
+package tracing
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const redacted = "***"
+
+// RedactingUnaryServerInterceptor returns a server interceptor that logs the
+// request payload as JSON at debug level, so payloads are only logged with
+// LOG_LEVEL=debug (or trace). Fields matching one of fields, either by simple
+// name (e.g. "password") or by fully-qualified name (e.g.
+// "user.Request.password"), are replaced with "***" at any nesting depth.
+//
+// The request is copied before redaction; the handler always sees the
+// original message.
+func RedactingUnaryServerInterceptor(fields ...string) grpc.UnaryServerInterceptor {
+	sensitive := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		sensitive[f] = true
+	}
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if e := Logger(ctx).Debug(); e.Enabled() {
+			if m, ok := req.(proto.Message); ok {
+				payload, err := redactedJSON(m, sensitive)
+				if err != nil {
+					Logger(ctx).Warn().Msgf("%s: failed to marshal request payload: %v", info.FullMethod, err)
+				} else {
+					e.RawJSON("payload", payload).Msgf("%s: request payload", info.FullMethod)
+				}
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// redactedJSON returns the JSON encoding of a copy of m with the sensitive
+// fields redacted.
+func redactedJSON(m proto.Message, sensitive map[string]bool) ([]byte, error) {
+	c := proto.Clone(m)
+	redactMessage(c.ProtoReflect(), sensitive)
+	return protojson.Marshal(c)
+}
+
+// redactMessage redacts the sensitive fields of m in place, recursing into
+// message-typed fields, lists and maps.
+func redactMessage(m protoreflect.Message, sensitive map[string]bool) {
+	var matched []protoreflect.FieldDescriptor
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if sensitive[string(fd.Name())] || sensitive[string(fd.FullName())] {
+			matched = append(matched, fd)
+			return true
+		}
+
+		switch {
+		case fd.IsList() && fd.Message() != nil:
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				redactMessage(list.Get(i).Message(), sensitive)
+			}
+		case fd.IsMap() && fd.MapValue().Message() != nil:
+			v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+				redactMessage(mv.Message(), sensitive)
+				return true
+			})
+		case !fd.IsList() && !fd.IsMap() && fd.Message() != nil:
+			redactMessage(v.Message(), sensitive)
+		}
+		return true
+	})
+
+	for _, fd := range matched {
+		redactField(m, fd)
+	}
+}
+
+// redactField replaces the value of fd in m with "***". Fields that cannot
+// hold a string are cleared instead.
+func redactField(m protoreflect.Message, fd protoreflect.FieldDescriptor) {
+	if fd.IsList() || fd.IsMap() {
+		m.Clear(fd)
+		return
+	}
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		m.Set(fd, protoreflect.ValueOfString(redacted))
+	case protoreflect.BytesKind:
+		m.Set(fd, protoreflect.ValueOfBytes([]byte(redacted)))
+	default:
+		m.Clear(fd)
+	}
+}
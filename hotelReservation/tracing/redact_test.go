@@ -0,0 +1,71 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	user "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/user/proto"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// captureLog sends the global logger, at level, to the returned buffer for
+// the duration of t.
+func captureLog(t *testing.T, level zerolog.Level) *bytes.Buffer {
+	t.Helper()
+	logger, globalLevel := log.Logger, zerolog.GlobalLevel()
+	t.Cleanup(func() {
+		log.Logger = logger
+		zerolog.SetGlobalLevel(globalLevel)
+	})
+
+	buf := new(bytes.Buffer)
+	log.Logger = zerolog.New(buf)
+	zerolog.SetGlobalLevel(level)
+	return buf
+}
+
+func TestRedactingUnaryServerInterceptor(t *testing.T) {
+	logs := captureLog(t, zerolog.DebugLevel)
+	req := &user.Request{Username: "Cornell_1", Password: "1111111111"}
+
+	redact := RedactingUnaryServerInterceptor("password")
+	var seen *user.Request
+	redact(context.Background(), req, testInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+		seen = req.(*user.Request)
+		return nil, nil
+	})
+
+	if seen.Password != "1111111111" {
+		t.Errorf("handler saw password %q, want the original", seen.Password)
+	}
+	out := logs.String()
+	if strings.Contains(out, "1111111111") {
+		t.Errorf("password logged: %s", out)
+	}
+	if !strings.Contains(out, `"password":"***"`) || !strings.Contains(out, `"username":"Cornell_1"`) {
+		t.Errorf("payload not logged with the password redacted: %s", out)
+	}
+}
+
+func TestRedactingByFullName(t *testing.T) {
+	payload, err := redactedJSON(&user.Request{Username: "Cornell_1", Password: "secret"}, map[string]bool{"user.Request.password": true})
+	if err != nil {
+		t.Fatalf("redactedJSON: %v", err)
+	}
+	if strings.Contains(string(payload), "secret") {
+		t.Errorf("password not redacted: %s", payload)
+	}
+}
+
+func TestRedactingOnlyAtDebugLevel(t *testing.T) {
+	logs := captureLog(t, zerolog.InfoLevel)
+	RedactingUnaryServerInterceptor("password")(context.Background(), &user.Request{Username: "Cornell_1"}, testInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	if logs.Len() != 0 {
+		t.Errorf("payload logged at info level: %s", logs)
+	}
+}
@@ -0,0 +1,108 @@
+package tracing
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/uber/jaeger-client-go/config"
+)
+
+// Modes of reporting spans to Jaeger.
+const (
+	// AgentReporter sends spans over UDP to a Jaeger agent.
+	AgentReporter = "agent"
+	// CollectorReporter sends spans over HTTP to a Jaeger collector.
+	CollectorReporter = "collector"
+)
+
+// Reporter selects where a tracer sends its spans.
+type Reporter struct {
+	// Mode is AgentReporter or CollectorReporter.
+	Mode string
+	// AgentHostPort is the address of the agent in agent mode, that of
+	// JAEGER_AGENT_HOST and JAEGER_AGENT_PORT, localhost:6831 by default, if
+	// empty.
+	AgentHostPort string
+	// CollectorEndpoint is the URL spans are posted to in collector mode,
+	// e.g. http://jaeger:14268/api/traces.
+	CollectorEndpoint string
+	// User and Password authenticate to the collector with basic auth, if
+	// set. They must be set together.
+	User     string
+	Password string
+}
+
+// ReporterFromEnv returns the reporter selected by JAEGER_REPORTER, agent or
+// collector. In agent mode spans go to agentHostPort, the address in the
+// service config. In collector mode they go to JAEGER_ENDPOINT, with the
+// basic auth credentials of JAEGER_USER and JAEGER_PASSWORD if set. Without
+// JAEGER_REPORTER, the mode is collector if JAEGER_ENDPOINT is set and agent
+// otherwise, like the Jaeger client decides it.
+func ReporterFromEnv(agentHostPort string) (Reporter, error) {
+	r := Reporter{
+		Mode:              os.Getenv("JAEGER_REPORTER"),
+		AgentHostPort:     agentHostPort,
+		CollectorEndpoint: os.Getenv("JAEGER_ENDPOINT"),
+		User:              os.Getenv("JAEGER_USER"),
+		Password:          os.Getenv("JAEGER_PASSWORD"),
+	}
+	if r.Mode == "" {
+		r.Mode = AgentReporter
+		if r.CollectorEndpoint != "" {
+			r.Mode = CollectorReporter
+		}
+	}
+	if err := r.Validate(); err != nil {
+		return Reporter{}, err
+	}
+	return r, nil
+}
+
+// Validate returns an error if r is an invalid combination of settings.
+func (r Reporter) Validate() error {
+	switch r.Mode {
+	case AgentReporter:
+		if r.CollectorEndpoint != "" {
+			return fmt.Errorf("collector endpoint %q is set in agent reporter mode, set JAEGER_REPORTER=collector to use it", r.CollectorEndpoint)
+		}
+		if r.User != "" || r.Password != "" {
+			return fmt.Errorf("basic auth is set in agent reporter mode, it is only used by the collector reporter")
+		}
+	case CollectorReporter:
+		if r.CollectorEndpoint == "" {
+			return fmt.Errorf("collector reporter needs an endpoint, set JAEGER_ENDPOINT")
+		}
+		u, err := url.Parse(r.CollectorEndpoint)
+		if err != nil {
+			return fmt.Errorf("invalid collector endpoint %q: %v", r.CollectorEndpoint, err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid collector endpoint %q, want an http or https URL", r.CollectorEndpoint)
+		}
+		if (r.User == "") != (r.Password == "") {
+			return fmt.Errorf("collector basic auth needs both a user and a password")
+		}
+	default:
+		return fmt.Errorf("unknown reporter mode %q, valid modes are: agent, collector", r.Mode)
+	}
+	return nil
+}
+
+// reporterConfig returns the Jaeger reporter config of r, which reports in
+// agent mode unless it has a collector endpoint.
+func (r Reporter) reporterConfig() *config.ReporterConfig {
+	rc := &config.ReporterConfig{
+		LogSpans:            false,
+		BufferFlushInterval: 1 * time.Second,
+	}
+	if r.Mode == CollectorReporter {
+		rc.CollectorEndpoint = r.CollectorEndpoint
+		rc.User = r.User
+		rc.Password = r.Password
+	} else {
+		rc.LocalAgentHostPort = r.AgentHostPort
+	}
+	return rc
+}
@@ -0,0 +1,77 @@
+package tracing
+
+import "testing"
+
+// setReporterEnv sets the JAEGER_ variables selecting the reporter for the
+// duration of t, unset if empty.
+func setReporterEnv(t *testing.T, mode, endpoint, user, password string) {
+	t.Helper()
+	t.Setenv("JAEGER_REPORTER", mode)
+	t.Setenv("JAEGER_ENDPOINT", endpoint)
+	t.Setenv("JAEGER_USER", user)
+	t.Setenv("JAEGER_PASSWORD", password)
+}
+
+func TestReporterFromEnv(t *testing.T) {
+	tests := []struct {
+		name                           string
+		mode, endpoint, user, password string
+		wantMode                       string
+		wantAgent, wantCollector       string
+		wantUser, wantPassword         string
+	}{
+		{"default", "", "", "", "", AgentReporter, "jaeger:6831", "", "", ""},
+		{"agent", AgentReporter, "", "", "", AgentReporter, "jaeger:6831", "", "", ""},
+		{"collector", CollectorReporter, "http://jaeger:14268/api/traces", "", "", CollectorReporter, "", "http://jaeger:14268/api/traces", "", ""},
+		{"collector by endpoint", "", "https://jaeger:14268/api/traces", "", "", CollectorReporter, "", "https://jaeger:14268/api/traces", "", ""},
+		{"collector with basic auth", CollectorReporter, "http://jaeger:14268/api/traces", "hotel", "secret", CollectorReporter, "", "http://jaeger:14268/api/traces", "hotel", "secret"},
+	}
+	for _, tt := range tests {
+		setReporterEnv(t, tt.mode, tt.endpoint, tt.user, tt.password)
+		r, err := ReporterFromEnv("jaeger:6831")
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if r.Mode != tt.wantMode {
+			t.Errorf("%s: got mode %q, want %q", tt.name, r.Mode, tt.wantMode)
+		}
+		rc := r.reporterConfig()
+		if rc.LocalAgentHostPort != tt.wantAgent || rc.CollectorEndpoint != tt.wantCollector {
+			t.Errorf("%s: reporting to agent %q and collector %q, want %q and %q",
+				tt.name, rc.LocalAgentHostPort, rc.CollectorEndpoint, tt.wantAgent, tt.wantCollector)
+		}
+		if rc.User != tt.wantUser || rc.Password != tt.wantPassword {
+			t.Errorf("%s: got basic auth %q:%q, want %q:%q", tt.name, rc.User, rc.Password, tt.wantUser, tt.wantPassword)
+		}
+	}
+}
+
+func TestReporterFromEnvInvalid(t *testing.T) {
+	tests := []struct {
+		name                           string
+		mode, endpoint, user, password string
+	}{
+		{"collector without endpoint", CollectorReporter, "", "", ""},
+		{"collector endpoint without scheme", CollectorReporter, "jaeger:14268", "", ""},
+		{"collector endpoint of another scheme", CollectorReporter, "udp://jaeger:14268", "", ""},
+		{"user without password", CollectorReporter, "http://jaeger:14268/api/traces", "hotel", ""},
+		{"password without user", CollectorReporter, "http://jaeger:14268/api/traces", "", "secret"},
+		{"agent with endpoint", AgentReporter, "http://jaeger:14268/api/traces", "", ""},
+		{"agent with basic auth", AgentReporter, "", "hotel", "secret"},
+		{"unknown mode", "kafka", "", "", ""},
+	}
+	for _, tt := range tests {
+		setReporterEnv(t, tt.mode, tt.endpoint, tt.user, tt.password)
+		if r, err := ReporterFromEnv("jaeger:6831"); err == nil {
+			t.Errorf("%s: got reporter %+v, want an error", tt.name, r)
+		}
+	}
+}
+
+func TestInitWithInvalidReporter(t *testing.T) {
+	setReporterEnv(t, CollectorReporter, "", "", "")
+	if tracer, err := Init("test", "127.0.0.1:6831"); err == nil {
+		t.Errorf("Init in collector mode without endpoint returned %T, want an error", tracer)
+	}
+}
@@ -0,0 +1,102 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	opentracing "github.com/opentracing/opentracing-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// RequestIDKey is the gRPC metadata key (and HTTP header, case-insensitively)
+// carrying the request ID.
+const RequestIDKey = "x-request-id"
+
+type requestIDCtxKey struct{}
+
+// ContextWithRequestID returns a copy of ctx carrying the request ID id.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDCtxKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID stored in ctx, or "" if none.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDCtxKey{}).(string)
+	return id
+}
+
+// incomingRequestID returns the first request ID in the incoming metadata.
+func incomingRequestID(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if vals := md.Get(RequestIDKey); len(vals) > 0 {
+		return vals[0]
+	}
+	return ""
+}
+
+// RequestIDUnaryServerInterceptor makes the caller's request ID available to
+// the handler through RequestIDFromContext, generating a new one if the
+// incoming metadata has none, and tags the span in ctx with it.
+func RequestIDUnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	id := incomingRequestID(ctx)
+	if id == "" {
+		id = uuid.New().String()
+	}
+	ctx = ContextWithRequestID(ctx, id)
+
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		span.SetTag("request.id", id)
+	}
+
+	return handler(ctx, req)
+}
+
+// RequestIDUnaryClientInterceptor forwards the request ID stored in ctx to the
+// server through the outgoing metadata.
+func RequestIDUnaryClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if id := RequestIDFromContext(ctx); id != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, RequestIDKey, id)
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+// requestIDServerStream is a server stream whose context carries the request
+// ID.
+type requestIDServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *requestIDServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// RequestIDStreamServerInterceptor is RequestIDUnaryServerInterceptor for
+// streams.
+func RequestIDStreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx := ss.Context()
+	id := incomingRequestID(ctx)
+	if id == "" {
+		id = uuid.New().String()
+	}
+	ctx = ContextWithRequestID(ctx, id)
+
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		span.SetTag("request.id", id)
+	}
+
+	return handler(srv, &requestIDServerStream{ServerStream: ss, ctx: ctx})
+}
+
+// RequestIDStreamClientInterceptor is RequestIDUnaryClientInterceptor for
+// streams.
+func RequestIDStreamClientInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	if id := RequestIDFromContext(ctx); id != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, RequestIDKey, id)
+	}
+	return streamer(ctx, desc, cc, method, opts...)
+}
@@ -0,0 +1,68 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// handledRequestID runs the request ID server interceptor in ctx, returning
+// the request ID its handler saw.
+func handledRequestID(ctx context.Context) string {
+	var id string
+	RequestIDUnaryServerInterceptor(ctx, nil, testInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+		id = RequestIDFromContext(ctx)
+		return nil, nil
+	})
+	return id
+}
+
+func TestRequestIDGeneratedWhenAbsent(t *testing.T) {
+	ctx, span := withMockSpan(context.Background())
+	id := handledRequestID(ctx)
+	if id == "" {
+		t.Fatal("no request ID generated")
+	}
+	if got := span.Tag("request.id"); got != id {
+		t.Errorf("request.id = %v, want %s", got, id)
+	}
+	if other := handledRequestID(context.Background()); other == id {
+		t.Errorf("generated the same request ID %s twice", id)
+	}
+}
+
+func TestRequestIDPassedThrough(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(RequestIDKey, "req-42"))
+	if id := handledRequestID(ctx); id != "req-42" {
+		t.Errorf("handler saw request ID %q, want req-42", id)
+	}
+}
+
+func TestRequestIDRoundTrip(t *testing.T) {
+	// the client interceptor puts the ID in the outgoing metadata, which
+	// the server receives as incoming metadata
+	var outgoing metadata.MD
+	ctx := ContextWithRequestID(context.Background(), "req-7")
+	RequestIDUnaryClientInterceptor(ctx, testInfo.FullMethod, nil, nil, nil, func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		outgoing, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	})
+	if got := outgoing.Get(RequestIDKey); len(got) != 1 || got[0] != "req-7" {
+		t.Fatalf("outgoing %s = %v, want [req-7]", RequestIDKey, got)
+	}
+
+	if id := handledRequestID(metadata.NewIncomingContext(context.Background(), outgoing)); id != "req-7" {
+		t.Errorf("server saw request ID %q, want req-7", id)
+	}
+}
+
+func TestRequestIDClientWithoutID(t *testing.T) {
+	RequestIDUnaryClientInterceptor(context.Background(), testInfo.FullMethod, nil, nil, nil, func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		if md, _ := metadata.FromOutgoingContext(ctx); len(md.Get(RequestIDKey)) != 0 {
+			t.Errorf("sent %s %v without a request ID in the context", RequestIDKey, md.Get(RequestIDKey))
+		}
+		return nil
+	})
+}
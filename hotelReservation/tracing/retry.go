@@ -0,0 +1,83 @@
+package tracing
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	otlog "github.com/opentracing/opentracing-go/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RetryUnaryClientInterceptor returns a client interceptor that re-invokes an
+// RPC failing with one of the retryable codes, up to maxAttempts in total.
+// Attempts are separated by an exponential backoff starting at baseBackoff
+// with +/-25% jitter. It never sleeps past the deadline of ctx and stops
+// as soon as ctx is done or a non-retryable code is returned.
+//
+// Requests still failing with a retryable code when it gives up, for lack of
+// attempts or of time, are recorded as dead letters: logged at error level,
+// tagged dead_letter=true on their span and added to DefaultDeadLetters.
+//
+// Only use it for idempotent methods.
+func RetryUnaryClientInterceptor(maxAttempts int, baseBackoff time.Duration, retryable ...codes.Code) grpc.UnaryClientInterceptor {
+	retryableCodes := make(map[codes.Code]bool, len(retryable))
+	for _, c := range retryable {
+		retryableCodes[c] = true
+	}
+
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		var err error
+		for attempt := 1; ; attempt++ {
+			err = invoker(ctx, method, req, reply, cc, opts...)
+			if err == nil || !retryableCodes[status.Code(err)] {
+				return err
+			}
+			if attempt >= maxAttempts {
+				recordDeadLetter(ctx, method, err, attempt, start)
+				return err
+			}
+
+			backoff := retryBackoff(baseBackoff, attempt)
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < backoff {
+				Logger(ctx).Warn().Msgf("%s: not retrying, deadline budget smaller than backoff %v", method, backoff)
+				recordDeadLetter(ctx, method, err, attempt, start)
+				return err
+			}
+
+			if span := opentracing.SpanFromContext(ctx); span != nil {
+				span.LogFields(
+					otlog.String("event", "retry"),
+					otlog.Int("retry.attempt", attempt+1),
+					otlog.String("retry.backoff", backoff.String()),
+					otlog.String("retry.last_error", status.Code(err).String()),
+				)
+			}
+			Logger(ctx).Debug().Msgf("%s: attempt %d failed with %s, retrying in %v", method, attempt, status.Code(err), backoff)
+
+			timer := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				recordDeadLetter(ctx, method, err, attempt, start)
+				return err
+			case <-timer.C:
+			}
+		}
+	}
+}
+
+// retryBackoff returns the delay before the attempt following the given one.
+func retryBackoff(base time.Duration, attempt int) time.Duration {
+	backoff := base << uint(attempt-1)
+	if backoff <= 0 {
+		return base
+	}
+	// Spread retries over [0.75, 1.25] of the nominal backoff.
+	jitter := time.Duration(rand.Int63n(int64(backoff)/2 + 1))
+	return backoff - backoff/4 + jitter
+}
@@ -0,0 +1,81 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// failingInvoker fails with code the first failures calls, counting them
+// all in calls.
+func failingInvoker(failures int, code codes.Code, calls *int) grpc.UnaryInvoker {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		*calls++
+		if *calls <= failures {
+			return status.Error(code, "failing")
+		}
+		return nil
+	}
+}
+
+func TestRetryUnaryClientInterceptor(t *testing.T) {
+	tests := []struct {
+		name        string
+		failures    int
+		code        codes.Code
+		wantCalls   int
+		wantErrCode codes.Code
+	}{
+		{"succeeds at once", 0, codes.Unavailable, 1, codes.OK},
+		{"succeeds after retries", 2, codes.Unavailable, 3, codes.OK},
+		{"runs out of attempts", 5, codes.Unavailable, 3, codes.Unavailable},
+		{"does not retry other codes", 2, codes.InvalidArgument, 1, codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retry := RetryUnaryClientInterceptor(3, time.Millisecond, codes.Unavailable)
+			calls := 0
+			err := retry(context.Background(), testInfo.FullMethod, nil, nil, nil, failingInvoker(tt.failures, tt.code, &calls))
+			if status.Code(err) != tt.wantErrCode {
+				t.Errorf("got %v, want code %s", err, tt.wantErrCode)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("invoked %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryStopsAtDeadline(t *testing.T) {
+	retry := RetryUnaryClientInterceptor(10, 50*time.Millisecond, codes.Unavailable)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	calls := 0
+	start := time.Now()
+	err := retry(ctx, testInfo.FullMethod, nil, nil, nil, failingInvoker(10, codes.Unavailable, &calls))
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("got %v, want Unavailable", err)
+	}
+	if calls >= 10 {
+		t.Errorf("invoked %d times, want fewer than the 10 attempts", calls)
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Errorf("gave up after %v, past the deadline", elapsed)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	const base = 100 * time.Millisecond
+	for attempt, nominal := range map[int]time.Duration{1: base, 2: 2 * base, 3: 4 * base} {
+		for i := 0; i < 20; i++ {
+			if got := retryBackoff(base, attempt); got < nominal*3/4 || got > nominal*5/4 {
+				t.Fatalf("retryBackoff(%v, %d) = %v, want within 25%% of %v", base, attempt, got, nominal)
+			}
+		}
+	}
+}
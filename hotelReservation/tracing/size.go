@@ -0,0 +1,217 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/tune"
+	opentracing "github.com/opentracing/opentracing-go"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// messageSize returns the wire size of msg, or -1 if msg is not a proto message.
+func messageSize(msg interface{}) int {
+	if m, ok := msg.(proto.Message); ok {
+		return proto.Size(m)
+	}
+	return -1
+}
+
+var (
+	sizeLimitOnce sync.Once
+	sizeLimit     int
+)
+
+// messageSizeLimit returns the size of the largest message the services
+// exchange: the lower of the sizes they send and receive, which peers are
+// configured with too.
+func messageSizeLimit() int {
+	sizeLimitOnce.Do(func() {
+		sizeLimit = tune.GetMaxRecvMsgSize()
+		if send := tune.GetMaxSendMsgSize(); send < sizeLimit {
+			sizeLimit = send
+		}
+	})
+	return sizeLimit
+}
+
+// warnOversize logs a warning if msg, of size bytes, can't be sent in one
+// message, so that the cryptic error the call fails with can be traced to
+// the limits.
+func warnOversize(ctx context.Context, method, msg string, size int) {
+	if limit := messageSizeLimit(); size > limit {
+		Logger(ctx).Warn().Msgf("%s: %s of %d bytes is over the message size limit of %d bytes, raise GRPC_MAX_RECV_MSG_BYTES and GRPC_MAX_SEND_MSG_BYTES", method, msg, size, limit)
+	}
+}
+
+// SizeTaggingUnaryServerInterceptor tags the span in ctx with the size of the
+// request and response messages of a unary RPC and records them in
+// DefaultSizeHistograms. Responses compressed by
+// CompressionUnaryServerInterceptor are also tagged with their compressed
+// size and compression ratio. Responses over the message size limit are
+// logged as a warning.
+func SizeTaggingUnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	sizes := new(wireSizes)
+	resp, err := handler(context.WithValue(ctx, wireSizesCtxKey{}, sizes), req)
+
+	reqSize, respSize := messageSize(req), messageSize(resp)
+	warnOversize(ctx, info.FullMethod, "response", respSize)
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		span.SetTag("grpc.request.size", reqSize)
+		span.SetTag("grpc.response.size", respSize)
+		span.SetTag("grpc.response.compressed", sizes.compressed)
+		if sizes.compressed && sizes.respWire > 0 {
+			span.SetTag("grpc.response.wire_size", sizes.respWire)
+			span.SetTag("grpc.response.compression_ratio", float64(respSize)/float64(sizes.respWire))
+		}
+	}
+	DefaultSizeHistograms.Observe(info.FullMethod, reqSize, respSize)
+	Logger(ctx).Debug().Msgf("%s: request size %d, response size %d", info.FullMethod, reqSize, respSize)
+
+	return resp, err
+}
+
+// SizeTaggingUnaryClientInterceptor tags the span in ctx with the size of the
+// request and reply messages of an outgoing unary RPC and records them in
+// DefaultSizeHistograms. Requests over the message size limit are logged as
+// a warning before they are sent.
+func SizeTaggingUnaryClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	warnOversize(ctx, method, "request", messageSize(req))
+	err := invoker(ctx, method, req, reply, cc, opts...)
+
+	reqSize, replySize := messageSize(req), messageSize(reply)
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		span.SetTag("grpc.request.size", reqSize)
+		span.SetTag("grpc.response.size", replySize)
+	}
+	DefaultSizeHistograms.Observe(method, reqSize, replySize)
+	Logger(ctx).Debug().Msgf("%s: request size %d, response size %d", method, reqSize, replySize)
+
+	return err
+}
+
+// streamSizes accumulates the bytes and message counts seen on a stream in
+// each direction. A byte total of -1 means at least one message in that
+// direction was not a proto message.
+type streamSizes struct {
+	mu           sync.Mutex
+	reqBytes     int
+	respBytes    int
+	reqMessages  int
+	respMessages int
+}
+
+func (s *streamSizes) add(total *int, count *int, msg interface{}) {
+	size := messageSize(msg)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	*count++
+	if size < 0 || *total < 0 {
+		*total = -1
+		return
+	}
+	*total += size
+}
+
+func (s *streamSizes) addRequest(msg interface{})  { s.add(&s.reqBytes, &s.reqMessages, msg) }
+func (s *streamSizes) addResponse(msg interface{}) { s.add(&s.respBytes, &s.respMessages, msg) }
+
+func (s *streamSizes) tag(ctx context.Context, method string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		span.SetTag("grpc.stream.request.bytes", s.reqBytes)
+		span.SetTag("grpc.stream.response.bytes", s.respBytes)
+		span.SetTag("grpc.stream.request.messages", s.reqMessages)
+		span.SetTag("grpc.stream.response.messages", s.respMessages)
+	}
+	Logger(ctx).Debug().Msgf("%s: stream request bytes %d (%d messages), response bytes %d (%d messages)",
+		method, s.reqBytes, s.reqMessages, s.respBytes, s.respMessages)
+}
+
+// sizeTaggingServerStream counts messages received from and sent to the client.
+type sizeTaggingServerStream struct {
+	grpc.ServerStream
+	sizes *streamSizes
+}
+
+func (s *sizeTaggingServerStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.sizes.addResponse(m)
+	}
+	return err
+}
+
+func (s *sizeTaggingServerStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.sizes.addRequest(m)
+	}
+	return err
+}
+
+// SizeTaggingStreamServerInterceptor tags the span of a streaming RPC with the
+// total bytes and number of messages exchanged in each direction once the
+// handler returns.
+func SizeTaggingStreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	wrapped := &sizeTaggingServerStream{ServerStream: ss, sizes: &streamSizes{}}
+	err := handler(srv, wrapped)
+	wrapped.sizes.tag(ss.Context(), info.FullMethod)
+	return err
+}
+
+// sizeTaggingClientStream counts messages sent to and received from the
+// server, and tags the span once the stream is finished.
+type sizeTaggingClientStream struct {
+	grpc.ClientStream
+	ctx    context.Context
+	method string
+	sizes  *streamSizes
+	once   sync.Once
+}
+
+func (s *sizeTaggingClientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.sizes.addRequest(m)
+	} else if err != io.EOF {
+		s.finish()
+	}
+	return err
+}
+
+func (s *sizeTaggingClientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err == nil {
+		s.sizes.addResponse(m)
+	} else {
+		// io.EOF marks the normal end of the stream.
+		s.finish()
+	}
+	return err
+}
+
+func (s *sizeTaggingClientStream) finish() {
+	s.once.Do(func() { s.sizes.tag(s.ctx, s.method) })
+}
+
+// SizeTaggingStreamClientInterceptor tags the span in ctx with the total bytes
+// and number of messages exchanged in each direction of an outgoing stream.
+// Tags are set when the stream ends, i.e. when RecvMsg returns an error.
+func SizeTaggingStreamClientInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	cs, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &sizeTaggingClientStream{
+		ClientStream: cs,
+		ctx:          ctx,
+		method:       method,
+		sizes:        &streamSizes{},
+	}, nil
+}
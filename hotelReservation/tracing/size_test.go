@@ -0,0 +1,222 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// withMockSpan returns ctx carrying a span of a mock tracer, whose tags the
+// test can check once it finished.
+func withMockSpan(ctx context.Context) (context.Context, *mocktracer.MockSpan) {
+	span := mocktracer.New().StartSpan("test").(*mocktracer.MockSpan)
+	return opentracing.ContextWithSpan(ctx, span), span
+}
+
+// fakeServerStream receives reqs in turn, then io.EOF, and keeps what is
+// sent.
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	reqs []proto.Message
+	sent []interface{}
+}
+
+func (s *fakeServerStream) Context() context.Context { return s.ctx }
+
+func (s *fakeServerStream) SendMsg(m interface{}) error {
+	s.sent = append(s.sent, m)
+	return nil
+}
+
+func (s *fakeServerStream) RecvMsg(m interface{}) error {
+	if len(s.reqs) == 0 {
+		return io.EOF
+	}
+	proto.Merge(m.(proto.Message), s.reqs[0])
+	s.reqs = s.reqs[1:]
+	return nil
+}
+
+func TestSizeTaggingStreamServerInterceptor(t *testing.T) {
+	ctx, span := withMockSpan(context.Background())
+	reqs := []proto.Message{durationpb.New(time.Second), durationpb.New(time.Hour), durationpb.New(time.Millisecond)}
+	resps := []proto.Message{durationpb.New(2 * time.Minute), durationpb.New(3 * time.Nanosecond)}
+	reqBytes, respBytes := 0, 0
+	for _, m := range reqs {
+		reqBytes += proto.Size(m)
+	}
+	for _, m := range resps {
+		respBytes += proto.Size(m)
+	}
+
+	ss := &fakeServerStream{ctx: ctx, reqs: reqs}
+	err := SizeTaggingStreamServerInterceptor(nil, ss, &grpc.StreamServerInfo{FullMethod: "/test.Test/Stream"}, func(srv interface{}, stream grpc.ServerStream) error {
+		for {
+			err := stream.RecvMsg(new(durationpb.Duration))
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+		}
+		for _, m := range resps {
+			if err := stream.SendMsg(m); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("interceptor: %v", err)
+	}
+
+	want := map[string]interface{}{
+		"grpc.stream.request.bytes":     reqBytes,
+		"grpc.stream.response.bytes":    respBytes,
+		"grpc.stream.request.messages":  len(reqs),
+		"grpc.stream.response.messages": len(resps),
+	}
+	for tag, v := range want {
+		if got := span.Tag(tag); got != v {
+			t.Errorf("%s = %v, want %v", tag, got, v)
+		}
+	}
+}
+
+// fakeClientStream sends to nowhere and receives resps in turn, then io.EOF.
+type fakeClientStream struct {
+	grpc.ClientStream
+	resps []proto.Message
+}
+
+func (s *fakeClientStream) SendMsg(m interface{}) error { return nil }
+
+func (s *fakeClientStream) RecvMsg(m interface{}) error {
+	if len(s.resps) == 0 {
+		return io.EOF
+	}
+	proto.Merge(m.(proto.Message), s.resps[0])
+	s.resps = s.resps[1:]
+	return nil
+}
+
+func TestSizeTaggingStreamClientInterceptor(t *testing.T) {
+	ctx, span := withMockSpan(context.Background())
+	req := durationpb.New(time.Second)
+	resps := []proto.Message{durationpb.New(time.Minute), durationpb.New(time.Hour)}
+	respBytes := proto.Size(resps[0]) + proto.Size(resps[1])
+
+	cs, err := SizeTaggingStreamClientInterceptor(ctx, &grpc.StreamDesc{}, nil, "/test.Test/Stream",
+		func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return &fakeClientStream{resps: resps}, nil
+		})
+	if err != nil {
+		t.Fatalf("interceptor: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := cs.SendMsg(req); err != nil {
+			t.Fatalf("SendMsg: %v", err)
+		}
+	}
+	if span.Tag("grpc.stream.request.messages") != nil {
+		t.Fatalf("span tagged before the stream ended")
+	}
+	for {
+		if err := cs.RecvMsg(new(durationpb.Duration)); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("RecvMsg: %v", err)
+		}
+	}
+
+	want := map[string]interface{}{
+		"grpc.stream.request.bytes":     2 * proto.Size(req),
+		"grpc.stream.response.bytes":    respBytes,
+		"grpc.stream.request.messages":  2,
+		"grpc.stream.response.messages": 2,
+	}
+	for tag, v := range want {
+		if got := span.Tag(tag); got != v {
+			t.Errorf("%s = %v, want %v", tag, got, v)
+		}
+	}
+}
+
+func TestSizeTaggingStreamNonProto(t *testing.T) {
+	ctx, span := withMockSpan(context.Background())
+	ss := &fakeServerStream{ctx: ctx}
+	err := SizeTaggingStreamServerInterceptor(nil, ss, &grpc.StreamServerInfo{FullMethod: "/test.Test/Stream"}, func(srv interface{}, stream grpc.ServerStream) error {
+		stream.SendMsg(durationpb.New(time.Second))
+		return stream.SendMsg("not a proto message")
+	})
+	if err != nil {
+		t.Fatalf("interceptor: %v", err)
+	}
+	if got := span.Tag("grpc.stream.response.bytes"); got != -1 {
+		t.Errorf("grpc.stream.response.bytes = %v, want -1", got)
+	}
+	if got := span.Tag("grpc.stream.response.messages"); got != 2 {
+		t.Errorf("grpc.stream.response.messages = %v, want 2", got)
+	}
+}
+
+// setSizeLimits sets the message size limits for the duration of t.
+func setSizeLimits(t *testing.T, recv, send string) {
+	t.Helper()
+	t.Setenv("GRPC_MAX_RECV_MSG_BYTES", recv)
+	t.Setenv("GRPC_MAX_SEND_MSG_BYTES", send)
+	sizeLimitOnce = sync.Once{}
+	t.Cleanup(func() { sizeLimitOnce = sync.Once{} })
+}
+
+func TestSizeTaggingWarnsOversize(t *testing.T) {
+	setSizeLimits(t, "2048", "1024")
+	logs := captureLog(t, zerolog.WarnLevel)
+	large, small := &wrapperspb.StringValue{Value: strings.Repeat("x", 2000)}, &wrapperspb.StringValue{Value: "x"}
+	respond := func(resp interface{}) grpc.UnaryHandler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) { return resp, nil }
+	}
+
+	SizeTaggingUnaryServerInterceptor(context.Background(), small, testInfo, respond(small))
+	if logs.Len() != 0 {
+		t.Fatalf("got logs %q of a small response, want none", logs)
+	}
+	SizeTaggingUnaryServerInterceptor(context.Background(), small, testInfo, respond(large))
+	if !strings.Contains(logs.String(), "response of 2003 bytes is over the message size limit of 1024 bytes") {
+		t.Errorf("got logs %q, want a warning about the response", logs)
+	}
+
+	logs.Reset()
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+	SizeTaggingUnaryClientInterceptor(context.Background(), "/test.Test/Call", large, new(wrapperspb.StringValue), nil, invoker)
+	if !strings.Contains(logs.String(), "/test.Test/Call: request of 2003 bytes is over the message size limit of 1024 bytes") {
+		t.Errorf("got logs %q, want a warning about the request", logs)
+	}
+}
+
+func TestSizeTaggingDefaultLimit(t *testing.T) {
+	setSizeLimits(t, "", "")
+	logs := captureLog(t, zerolog.WarnLevel)
+	resp := &wrapperspb.BytesValue{Value: make([]byte, 5<<20)}
+	SizeTaggingUnaryServerInterceptor(context.Background(), resp, testInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return resp, nil
+	})
+	if !strings.Contains(logs.String(), "over the message size limit of 4194304 bytes") {
+		t.Errorf("got logs %q, want a warning about the 4MB default", logs)
+	}
+}
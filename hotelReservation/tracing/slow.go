@@ -0,0 +1,48 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"google.golang.org/grpc"
+)
+
+// SlowRequestUnaryServerInterceptor returns a server interceptor warning
+// about handlers slower than threshold, or than the threshold of their
+// method in perMethod, keyed by full method name (e.g.
+// "/profile.Profile/GetProfiles"). Slow requests are logged with their
+// method, duration and request ID, and tag the span in ctx with slow=true;
+// faster ones are left alone. A threshold of zero or less disables the
+// warning for the methods it applies to.
+func SlowRequestUnaryServerInterceptor(threshold time.Duration, perMethod map[string]time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		limit := threshold
+		if t, ok := perMethod[info.FullMethod]; ok {
+			limit = t
+		}
+		if limit <= 0 {
+			return handler(ctx, req)
+		}
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		elapsed := time.Since(start)
+		if elapsed <= limit {
+			return resp, err
+		}
+
+		if span := opentracing.SpanFromContext(ctx); span != nil {
+			span.SetTag("slow", true)
+		}
+		event := Logger(ctx).Warn().
+			Str("method", info.FullMethod).
+			Dur("duration", elapsed)
+		// the logger of ctx has the request ID already if it is in ctx
+		if RequestIDFromContext(ctx) == "" {
+			event = event.Str("request_id", incomingRequestID(ctx))
+		}
+		event.Msgf("%s: slow request took %.3fms, over the %v threshold", info.FullMethod, millis(elapsed), limit)
+		return resp, err
+	}
+}
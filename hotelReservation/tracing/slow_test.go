@@ -0,0 +1,88 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// callTaking calls method through slow with a handler taking d, returning
+// the logs and the span of the call.
+func callTaking(t *testing.T, slow grpc.UnaryServerInterceptor, ctx context.Context, method string, d time.Duration) (string, interface{}) {
+	t.Helper()
+	logs := captureLog(t, zerolog.InfoLevel)
+	ctx, span := withMockSpan(ctx)
+	slow(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		time.Sleep(d)
+		return nil, nil
+	})
+	return logs.String(), span.Tag("slow")
+}
+
+func TestSlowRequestUnaryServerInterceptor(t *testing.T) {
+	slow := SlowRequestUnaryServerInterceptor(50*time.Millisecond, nil)
+
+	if logs, tag := callTaking(t, slow, context.Background(), "/geo.Geo/Nearby", 0); logs != "" || tag != nil {
+		t.Errorf("fast request logged %q, tagged slow=%v", logs, tag)
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(RequestIDKey, "req-1"))
+	logs, tag := callTaking(t, slow, ctx, "/geo.Geo/Nearby", 80*time.Millisecond)
+	if tag != true {
+		t.Errorf("slow request tagged slow=%v, want true", tag)
+	}
+	var line map[string]interface{}
+	if err := json.Unmarshal([]byte(logs), &line); err != nil {
+		t.Fatalf("decoding %q: %v", logs, err)
+	}
+	if line["level"] != "warn" || line["method"] != "/geo.Geo/Nearby" || line["request_id"] != "req-1" {
+		t.Errorf("got %s, want a warning with the method and request ID", logs)
+	}
+	if d, _ := line["duration"].(float64); d < 80 {
+		t.Errorf("logged a duration of %vms, want at least 80ms", line["duration"])
+	}
+}
+
+func TestSlowRequestIDOnce(t *testing.T) {
+	slow := SlowRequestUnaryServerInterceptor(time.Nanosecond, nil)
+	logs, _ := callTaking(t, slow, ContextWithRequestID(context.Background(), "req-2"), "/geo.Geo/Nearby", time.Millisecond)
+	if n := strings.Count(logs, `"request_id"`); n != 1 || !strings.Contains(logs, `"request_id":"req-2"`) {
+		t.Errorf("got %s, want the request ID once", logs)
+	}
+}
+
+func TestSlowRequestPerMethod(t *testing.T) {
+	slow := SlowRequestUnaryServerInterceptor(50*time.Millisecond, map[string]time.Duration{
+		"/search.Search/Nearby":                             200 * time.Millisecond,
+		"/profile.Profile/GetProfiles":                      10 * time.Millisecond,
+		"/recommendation.Recommendation/GetRecommendations": 0,
+	})
+	for _, tt := range []struct {
+		method string
+		took   time.Duration
+		slow   bool
+	}{
+		{"/geo.Geo/Nearby", 80 * time.Millisecond, true},
+		{"/search.Search/Nearby", 80 * time.Millisecond, false},
+		{"/profile.Profile/GetProfiles", 30 * time.Millisecond, true},
+		{"/recommendation.Recommendation/GetRecommendations", 80 * time.Millisecond, false},
+	} {
+		logs, tag := callTaking(t, slow, context.Background(), tt.method, tt.took)
+		if (logs != "") != tt.slow || (tag == true) != tt.slow {
+			t.Errorf("%s taking %v: logged %q, tagged slow=%v, want slow %v", tt.method, tt.took, logs, tag, tt.slow)
+		}
+	}
+}
+
+func TestSlowRequestDisabled(t *testing.T) {
+	slow := SlowRequestUnaryServerInterceptor(0, nil)
+	if logs, tag := callTaking(t, slow, context.Background(), "/geo.Geo/Nearby", 20*time.Millisecond); logs != "" || tag != nil {
+		t.Errorf("logged %q, tagged slow=%v with no threshold", logs, tag)
+	}
+}
@@ -0,0 +1,49 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"google.golang.org/grpc"
+)
+
+// OperationName returns the canonical span name of the gRPC method
+// fullMethod: its service qualified by the innermost package only, then its
+// method, without leading slashes, e.g. geo.Geo/Nearby for /geo.Geo/Nearby
+// as for hotelreservation.geo.Geo/Nearby. Names that aren't service/method
+// are returned without their leading slashes.
+func OperationName(fullMethod string) string {
+	name := strings.TrimLeft(fullMethod, "/")
+	slash := strings.LastIndex(name, "/")
+	if slash <= 0 || slash == len(name)-1 {
+		return name
+	}
+	service, method := name[:slash], name[slash+1:]
+	if dot := strings.LastIndex(service, "."); dot >= 0 {
+		if pkg := strings.LastIndex(service[:dot], "."); pkg >= 0 {
+			service = service[pkg+1:]
+		}
+	}
+	return service + "/" + method
+}
+
+// SpanNamingUnaryServerInterceptor renames the span in ctx after the
+// OperationName of the method called, so that spans aggregate by method
+// whatever the tracer named them. Only the name changes: the tags set on the
+// span before or after are kept.
+func SpanNamingUnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		span.SetOperationName(OperationName(info.FullMethod))
+	}
+	return handler(ctx, req)
+}
+
+// SpanNamingStreamServerInterceptor is SpanNamingUnaryServerInterceptor for
+// streams.
+func SpanNamingStreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if span := opentracing.SpanFromContext(ss.Context()); span != nil {
+		span.SetOperationName(OperationName(info.FullMethod))
+	}
+	return handler(srv, ss)
+}
@@ -0,0 +1,104 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-opentracing/go/otgrpc"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestOperationName(t *testing.T) {
+	for _, tt := range []struct {
+		fullMethod, want string
+	}{
+		{"/geo.Geo/Nearby", "geo.Geo/Nearby"},
+		{"geo.Geo/Nearby", "geo.Geo/Nearby"},
+		{"//geo.Geo/Nearby", "geo.Geo/Nearby"},
+		{"/hotelreservation.geo.Geo/Nearby", "geo.Geo/Nearby"},
+		{"/grpc.health.v1.Health/Check", "v1.Health/Check"},
+		{"/Geo/Nearby", "Geo/Nearby"},
+		{"/reservation.Reservation/MakeReservation", "reservation.Reservation/MakeReservation"},
+		// not service/method
+		{"", ""},
+		{"/", ""},
+		{"/Nearby", "Nearby"},
+		{"/geo.Geo/", "geo.Geo/"},
+	} {
+		if got := OperationName(tt.fullMethod); got != tt.want {
+			t.Errorf("OperationName(%q) = %q, want %q", tt.fullMethod, got, tt.want)
+		}
+	}
+}
+
+func TestSpanNamingUnaryServerInterceptor(t *testing.T) {
+	ctx, span := withMockSpan(context.Background())
+	span.SetTag("before", true)
+	_, err := SpanNamingUnaryServerInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/hotelreservation.geo.Geo/Nearby"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("got %v", err)
+	}
+	if span.OperationName != "geo.Geo/Nearby" {
+		t.Errorf("span named %q, want geo.Geo/Nearby", span.OperationName)
+	}
+	if span.Tag("before") != true {
+		t.Errorf("tags set before the renaming lost: %v", span.Tags())
+	}
+
+	// calls without a span go through
+	handled := false
+	SpanNamingUnaryServerInterceptor(context.Background(), nil, testInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+		handled = true
+		return nil, nil
+	})
+	if !handled {
+		t.Error("handler not called without a span")
+	}
+}
+
+func TestSpanNamingStreamServerInterceptor(t *testing.T) {
+	ctx, span := withMockSpan(context.Background())
+	info := &grpc.StreamServerInfo{FullMethod: "/search.Search/NearbyStream"}
+	err := SpanNamingStreamServerInterceptor(nil, &fakeServerStream{ctx: ctx}, info, func(srv interface{}, ss grpc.ServerStream) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("got %v", err)
+	}
+	if span.OperationName != "search.Search/NearbyStream" {
+		t.Errorf("span named %q, want search.Search/NearbyStream", span.OperationName)
+	}
+}
+
+func TestSpanNamingKeepsTagging(t *testing.T) {
+	captureLog(t, zerolog.ErrorLevel)
+	tracer := mocktracer.New()
+	client := serveHealth(t, serving, grpc.ChainUnaryInterceptor(
+		otgrpc.OpenTracingServerInterceptor(tracer),
+		SpanNamingUnaryServerInterceptor,
+		LatencyTaggingUnaryServerInterceptor,
+		SizeTaggingUnaryServerInterceptor,
+	))
+	if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "geo"}); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+
+	spans := tracer.FinishedSpans()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	span := spans[0]
+	if span.OperationName != "v1.Health/Check" {
+		t.Errorf("span named %q, want v1.Health/Check", span.OperationName)
+	}
+	for _, tag := range []string{"grpc.handler.latency_ms", "grpc.request.size", "grpc.response.size", "span.kind"} {
+		if span.Tag(tag) == nil {
+			t.Errorf("span not tagged %s: %v", tag, span.Tags())
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package tracing
+
+import (
+	"context"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorClass groups status codes by who is likely at fault.
+func ErrorClass(code codes.Code) string {
+	switch code {
+	case codes.OK:
+		return ""
+	case codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists,
+		codes.PermissionDenied, codes.Unauthenticated, codes.FailedPrecondition, codes.OutOfRange:
+		return "client"
+	default:
+		return "server"
+	}
+}
+
+// tagStatus records the gRPC status of err on the span in ctx. Errors that do
+// not carry a status are reported as Unknown.
+func tagStatus(ctx context.Context, method string, err error) {
+	code := status.Code(err)
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		span.SetTag("grpc.status_code", code.String())
+		if err != nil {
+			span.SetTag("error", true)
+			span.SetTag("grpc.error_class", ErrorClass(code))
+		}
+	}
+	if err != nil {
+		Logger(ctx).Warn().Msgf("%s: failed with status %s: %v", method, code, err)
+	}
+}
+
+// StatusTaggingUnaryServerInterceptor tags the span in ctx with the gRPC status
+// code returned by the handler, marking failed RPCs with error=true.
+func StatusTaggingUnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	tagStatus(ctx, info.FullMethod, err)
+	return resp, err
+}
+
+// StatusTaggingUnaryClientInterceptor tags the span in ctx with the gRPC status
+// code of an outgoing unary RPC.
+func StatusTaggingUnaryClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	tagStatus(ctx, method, err)
+	return err
+}
@@ -0,0 +1,60 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestStatusTaggingUnaryServerInterceptor(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantCode  string
+		wantError interface{}
+		wantClass interface{}
+	}{
+		{"success", nil, "OK", nil, nil},
+		{"not found", status.Error(codes.NotFound, "no such hotel"), "NotFound", true, "client"},
+		{"plain error", errors.New("boom"), "Unknown", true, "server"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, span := withMockSpan(context.Background())
+			_, err := StatusTaggingUnaryServerInterceptor(ctx, nil, testInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, tt.err
+			})
+			if err != tt.err {
+				t.Errorf("got error %v, want %v", err, tt.err)
+			}
+			if got := span.Tag("grpc.status_code"); got != tt.wantCode {
+				t.Errorf("grpc.status_code = %v, want %v", got, tt.wantCode)
+			}
+			if got := span.Tag("error"); got != tt.wantError {
+				t.Errorf("error = %v, want %v", got, tt.wantError)
+			}
+			if got := span.Tag("grpc.error_class"); got != tt.wantClass {
+				t.Errorf("grpc.error_class = %v, want %v", got, tt.wantClass)
+			}
+		})
+	}
+}
+
+func TestErrorClass(t *testing.T) {
+	for code, want := range map[codes.Code]string{
+		codes.OK:                 "",
+		codes.InvalidArgument:    "client",
+		codes.NotFound:           "client",
+		codes.FailedPrecondition: "client",
+		codes.Internal:           "server",
+		codes.Unavailable:        "server",
+		codes.Unknown:            "server",
+	} {
+		if got := ErrorClass(code); got != want {
+			t.Errorf("ErrorClass(%s) = %q, want %q", code, got, want)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package tracing
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+)
+
+// MetricsMark is the state of a MetricsRegistry at some point, from which
+// Summary counts. The zero MetricsMark counts from the creation of the
+// registry.
+type MetricsMark struct {
+	at      time.Time
+	methods map[string]methodMetrics
+}
+
+// Mark returns the current state of r, e.g. when a server starts, so that
+// the summary of its traffic leaves out that of an earlier server of the
+// process sharing r.
+func (r *MetricsRegistry) Mark() MetricsMark {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	mark := MetricsMark{at: time.Now(), methods: make(map[string]methodMetrics, len(r.methods))}
+	for method, m := range r.methods {
+		errors := make(map[codes.Code]uint64, len(m.errors))
+		for code, n := range m.errors {
+			errors[code] = n
+		}
+		mark.methods[method] = methodMetrics{
+			requests: m.requests,
+			errors:   errors,
+			counts:   append([]uint64(nil), m.counts...),
+			sum:      m.sum,
+		}
+	}
+	return mark
+}
+
+// TrafficSummary is the traffic a server handled over a period.
+type TrafficSummary struct {
+	// Services are the full names of the gRPC services summarized.
+	Services []string  `json:"services"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Requests uint64    `json:"requests"`
+	Errors   uint64    `json:"errors"`
+	// P50 and P99 are the latency percentiles in seconds over every method,
+	// estimated from the histogram buckets.
+	P50     float64                  `json:"p50_seconds"`
+	P99     float64                  `json:"p99_seconds"`
+	Methods map[string]MethodSummary `json:"methods"`
+}
+
+// MethodSummary is the traffic of one method in a TrafficSummary.
+type MethodSummary struct {
+	Requests uint64 `json:"requests"`
+	Errors   uint64 `json:"errors"`
+	// ErrorCodes counts the errors per status code.
+	ErrorCodes map[string]uint64 `json:"error_codes,omitempty"`
+	P50        float64           `json:"p50_seconds"`
+	P99        float64           `json:"p99_seconds"`
+}
+
+// Summary returns the traffic r recorded since mark for the methods of
+// services, every method if none is given.
+func (r *MetricsRegistry) Summary(since MetricsMark, services ...string) TrafficSummary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := TrafficSummary{
+		Services: services,
+		Start:    since.at,
+		End:      time.Now(),
+		Methods:  make(map[string]MethodSummary),
+	}
+	if s.Start.IsZero() {
+		s.Start = r.created
+	}
+	total := make([]uint64, len(r.buckets)+1)
+	for method, m := range r.methods {
+		if !inServices(method, services) {
+			continue
+		}
+		old := since.methods[method]
+		ms := MethodSummary{Requests: m.requests - old.requests}
+		if ms.Requests == 0 {
+			continue
+		}
+		for code, n := range m.errors {
+			if n -= old.errors[code]; n > 0 {
+				if ms.ErrorCodes == nil {
+					ms.ErrorCodes = make(map[string]uint64)
+				}
+				ms.ErrorCodes[code.String()] = n
+				ms.Errors += n
+			}
+		}
+		counts := make([]uint64, len(m.counts))
+		for i, n := range m.counts {
+			if i < len(old.counts) {
+				n -= old.counts[i]
+			}
+			counts[i] = n
+			total[i] += n
+		}
+		ms.P50, ms.P99 = r.quantile(counts, 0.5), r.quantile(counts, 0.99)
+
+		s.Methods[method] = ms
+		s.Requests += ms.Requests
+		s.Errors += ms.Errors
+	}
+	s.P50, s.P99 = r.quantile(total, 0.5), r.quantile(total, 0.99)
+	return s
+}
+
+// inServices reports whether method, e.g. "/geo.Geo/Nearby", is one of
+// services, or services is empty.
+func inServices(method string, services []string) bool {
+	if len(services) == 0 {
+		return true
+	}
+	for _, service := range services {
+		if strings.HasPrefix(method, "/"+service+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// quantile estimates the q-quantile of the latencies counted per bucket of
+// r, interpolating linearly within the bucket it falls in like Prometheus'
+// histogram_quantile. Latencies above the largest bound count as that bound.
+func (r *MetricsRegistry) quantile(counts []uint64, q float64) float64 {
+	var n uint64
+	for _, c := range counts {
+		n += c
+	}
+	if n == 0 || len(r.buckets) == 0 {
+		return 0
+	}
+
+	rank := q * float64(n)
+	var cumulative uint64
+	for i, c := range counts {
+		if float64(cumulative+c) < rank || c == 0 {
+			cumulative += c
+			continue
+		}
+		if i == len(r.buckets) {
+			break
+		}
+		lower := 0.0
+		if i > 0 {
+			lower = r.buckets[i-1]
+		}
+		return lower + (r.buckets[i]-lower)*(rank-float64(cumulative))/float64(c)
+	}
+	return r.buckets[len(r.buckets)-1]
+}
+
+// Log logs s as one structured line.
+func (s TrafficSummary) Log() {
+	log.Info().
+		Strs("services", s.Services).
+		Float64("uptime_seconds", s.End.Sub(s.Start).Seconds()).
+		Uint64("requests", s.Requests).
+		Uint64("errors", s.Errors).
+		Float64("p50_seconds", s.P50).
+		Float64("p99_seconds", s.P99).
+		Interface("methods", s.Methods).
+		Msg("Traffic summary")
+}
+
+// WriteFile writes s as JSON to a file of dir named after its first service
+// and the process ID, so that replicas sharing dir don't overwrite each
+// other's, and returns its path.
+func (s TrafficSummary) WriteFile(dir string) (string, error) {
+	name := "all"
+	if len(s.Services) > 0 {
+		name = s.Services[0]
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s-%d.json", name, os.Getpid()))
+
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, append(b, '\n'), 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,137 @@
+package tracing
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc/codes"
+)
+
+// observeN observes n calls of method ending with code after latency.
+func observeN(r *MetricsRegistry, n int, method string, code codes.Code, latency time.Duration) {
+	for i := 0; i < n; i++ {
+		r.Observe(method, code, latency)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	r := NewMetricsRegistry(0.01, 0.1, 1)
+	observeN(r, 88, "/geo.Geo/Nearby", codes.OK, 5*time.Millisecond)
+	observeN(r, 2, "/geo.Geo/Nearby", codes.Unavailable, 5*time.Millisecond)
+	observeN(r, 10, "/geo.Geo/Nearby", codes.OK, 50*time.Millisecond)
+	observeN(r, 5, "/geo.Geo/NearestK", codes.InvalidArgument, 500*time.Millisecond)
+	observeN(r, 7, "/rate.Rate/GetRates", codes.OK, time.Millisecond)
+
+	s := r.Summary(MetricsMark{}, "geo.Geo")
+	if s.Requests != 105 || s.Errors != 7 {
+		t.Errorf("got %d requests and %d errors, want 105 and 7", s.Requests, s.Errors)
+	}
+	if _, ok := s.Methods["/rate.Rate/GetRates"]; ok || len(s.Methods) != 2 {
+		t.Errorf("got methods %v, want those of geo.Geo", s.Methods)
+	}
+	nearby := s.Methods["/geo.Geo/Nearby"]
+	if nearby.Requests != 100 || nearby.Errors != 2 || nearby.ErrorCodes["Unavailable"] != 2 {
+		t.Errorf("got Nearby %+v, want 100 requests and 2 unavailable", nearby)
+	}
+	// the median is the 50th of the 90 calls of the first bucket, the 99th
+	// percentile the 9th of the 10 calls of the second
+	for _, q := range []struct {
+		name      string
+		got, want float64
+	}{
+		{"Nearby p50", nearby.P50, 0.01 * 50 / 90},
+		{"Nearby p99", nearby.P99, 0.01 + 0.09*9/10},
+		{"NearestK p50", s.Methods["/geo.Geo/NearestK"].P50, 0.1 + 0.9*2.5/5},
+	} {
+		if math.Abs(q.got-q.want) > 1e-9 {
+			t.Errorf("%s = %v, want %v", q.name, q.got, q.want)
+		}
+	}
+	if s.Start != r.created || s.End.Before(s.Start) {
+		t.Errorf("got summary from %v to %v, want from the creation of the registry", s.Start, s.End)
+	}
+
+	if all := r.Summary(MetricsMark{}); all.Requests != 112 || len(all.Methods) != 3 {
+		t.Errorf("got %d requests of %d methods without services, want 112 of 3", all.Requests, len(all.Methods))
+	}
+}
+
+func TestSummarySinceMark(t *testing.T) {
+	r := NewMetricsRegistry(0.01, 0.1, 1)
+	observeN(r, 50, "/geo.Geo/Nearby", codes.Unavailable, 500*time.Millisecond)
+	observeN(r, 3, "/geo.Geo/NearestK", codes.OK, time.Millisecond)
+
+	// a server restarted in the same process only reports its own traffic
+	mark := r.Mark()
+	observeN(r, 10, "/geo.Geo/Nearby", codes.OK, 5*time.Millisecond)
+	observeN(r, 1, "/geo.Geo/Nearby", codes.Unavailable, 5*time.Millisecond)
+
+	s := r.Summary(mark, "geo.Geo")
+	if s.Requests != 11 || s.Errors != 1 || s.Start != mark.at {
+		t.Errorf("got %d requests and %d errors since %v, want 11 and 1 since %v", s.Requests, s.Errors, s.Start, mark.at)
+	}
+	if _, ok := s.Methods["/geo.Geo/NearestK"]; ok {
+		t.Errorf("got methods %v, want those called since the mark only", s.Methods)
+	}
+	if p99 := s.Methods["/geo.Geo/Nearby"].P99; p99 > 0.01 {
+		t.Errorf("Nearby p99 = %v, want the latencies since the mark only", p99)
+	}
+
+	if s := r.Summary(r.Mark(), "geo.Geo"); s.Requests != 0 || len(s.Methods) != 0 || s.P50 != 0 {
+		t.Errorf("got %+v right after a mark, want no traffic", s)
+	}
+}
+
+func TestSummaryLog(t *testing.T) {
+	logs := captureLog(t, zerolog.InfoLevel)
+	r := NewMetricsRegistry(0.01, 0.1, 1)
+	observeN(r, 4, "/geo.Geo/Nearby", codes.OK, 5*time.Millisecond)
+	r.Summary(MetricsMark{}, "geo.Geo").Log()
+
+	var line struct {
+		Services []string                 `json:"services"`
+		Requests uint64                   `json:"requests"`
+		Methods  map[string]MethodSummary `json:"methods"`
+		Message  string                   `json:"message"`
+	}
+	if err := json.Unmarshal(logs.Bytes(), &line); err != nil {
+		t.Fatalf("got log %q, want one JSON line: %v", logs, err)
+	}
+	if line.Message != "Traffic summary" || line.Requests != 4 || line.Methods["/geo.Geo/Nearby"].Requests != 4 || len(line.Services) != 1 {
+		t.Errorf("got log %q, want the summary of 4 requests", logs)
+	}
+}
+
+func TestSummaryWriteFile(t *testing.T) {
+	r := NewMetricsRegistry(0.01, 0.1, 1)
+	observeN(r, 3, "/geo.Geo/Nearby", codes.NotFound, 5*time.Millisecond)
+	dir := t.TempDir()
+
+	path, err := r.Summary(MetricsMark{}, "geo.Geo").WriteFile(dir)
+	if err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if !strings.HasPrefix(path, dir) || !strings.Contains(path, "geo.Geo-") {
+		t.Errorf("wrote %s, want a file of %s named after geo.Geo", path, dir)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading the summary: %v", err)
+	}
+	var s TrafficSummary
+	if err := json.Unmarshal(b, &s); err != nil {
+		t.Fatalf("decoding %s: %v", b, err)
+	}
+	if s.Requests != 3 || s.Errors != 3 || s.Methods["/geo.Geo/Nearby"].ErrorCodes["NotFound"] != 3 {
+		t.Errorf("got summary %s, want 3 requests not found", b)
+	}
+
+	if _, err := r.Summary(MetricsMark{}).WriteFile(dir + "/missing"); err == nil {
+		t.Error("WriteFile to a missing directory succeeded")
+	}
+}
@@ -0,0 +1,108 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// TenantKey is the gRPC metadata key (and HTTP header, case-insensitively)
+// naming the tenant a request is made for, e.g. the load generator sending
+// it. It is also the span baggage key carrying the tenant across hops.
+const TenantKey = "tenant-id"
+
+// UnknownTenant is the tenant of requests that don't name one.
+const UnknownTenant = "unknown"
+
+type tenantCtxKey struct{}
+
+// ContextWithTenant returns a copy of ctx carrying the tenant t.
+func ContextWithTenant(ctx context.Context, t string) context.Context {
+	return context.WithValue(ctx, tenantCtxKey{}, t)
+}
+
+// TenantFromContext returns the tenant stored in ctx, or UnknownTenant if
+// none.
+func TenantFromContext(ctx context.Context) string {
+	if t, ok := ctx.Value(tenantCtxKey{}).(string); ok && t != "" {
+		return t
+	}
+	return UnknownTenant
+}
+
+// tagTenant stores the tenant t in ctx and in the baggage of the span in
+// ctx, which hands it down to the spans of the calls made on behalf of the
+// request, and tags the span with tenant.id.
+func tagTenant(ctx context.Context, t string) context.Context {
+	if t == "" {
+		t = UnknownTenant
+	}
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		span.SetBaggageItem(TenantKey, t)
+		span.SetTag("tenant.id", t)
+	}
+	return ContextWithTenant(ctx, t)
+}
+
+// incomingTenant returns the tenant of the incoming request: that of its
+// metadata, else that of the baggage of the span in ctx, else "".
+func incomingTenant(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if vals := md.Get(TenantKey); len(vals) > 0 && vals[0] != "" {
+			return vals[0]
+		}
+	}
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		return span.BaggageItem(TenantKey)
+	}
+	return ""
+}
+
+// TenantUnaryServerInterceptor makes the tenant of the caller available to
+// the handler through TenantFromContext, UnknownTenant if it names none, and
+// tags the span in ctx with it. It must run after the tracing interceptor.
+func TenantUnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return handler(tagTenant(ctx, incomingTenant(ctx)), req)
+}
+
+// TenantUnaryClientInterceptor forwards the tenant stored in ctx to the
+// server through the outgoing metadata, so that it propagates even if the
+// tracer drops baggage.
+func TenantUnaryClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if t, ok := ctx.Value(tenantCtxKey{}).(string); ok && t != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, TenantKey, t)
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+// TenantTracer returns tracer tagging every span it starts with tenant.id,
+// from the baggage the span inherits from its parent, so that the spans of
+// the database and cache calls of a request are attributed to its tenant
+// too.
+func TenantTracer(tracer opentracing.Tracer) opentracing.Tracer {
+	return tenantTracer{tracer}
+}
+
+type tenantTracer struct {
+	opentracing.Tracer
+}
+
+func (t tenantTracer) StartSpan(operationName string, opts ...opentracing.StartSpanOption) opentracing.Span {
+	span := t.Tracer.StartSpan(operationName, opts...)
+	if tenant := span.BaggageItem(TenantKey); tenant != "" {
+		span.SetTag("tenant.id", tenant)
+	}
+	return span
+}
+
+// withTenant stores the tenant from the Tenant-Id header, UnknownTenant if
+// absent, in the request context and the baggage of its span.
+func withTenant(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := tagTenant(r.Context(), r.Header.Get(TenantKey))
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
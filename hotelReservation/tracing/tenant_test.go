@@ -0,0 +1,134 @@
+package tracing
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-opentracing/go/otgrpc"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+// serveTracedHop serves check with the default server chain traced by
+// tracer for the duration of t, returning a client of it through
+// interceptors.
+func serveTracedHop(t *testing.T, tracer opentracing.Tracer, check healthFunc, interceptors ...grpc.UnaryClientInterceptor) healthpb.HealthClient {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srv := grpc.NewServer(grpc.UnaryInterceptor(DefaultServerInterceptorChain(ServerChainOptions{Tracer: tracer, Untagged: true, Metrics: NewMetricsRegistry()})))
+	healthpb.RegisterHealthServer(srv, check)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure(), grpc.WithChainUnaryInterceptor(interceptors...))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return healthpb.NewHealthClient(conn)
+}
+
+// callTwoHops calls a search hop calling a geo hop with ctx, as a load
+// generator would, returning the tenant each hop saw and the spans of the
+// hops. If forward, search forwards the tenant in metadata as well as in
+// the baggage of its spans.
+func callTwoHops(t *testing.T, ctx context.Context, forward bool) ([]string, []*mocktracer.MockSpan) {
+	t.Helper()
+	tracer := mocktracer.New()
+	// the load generator traces its calls with a tracer of its own: the
+	// mock tracer roots the spans of untraced calls in a trace it can't
+	// propagate
+	caller := mocktracer.New()
+	var mu sync.Mutex
+	var tenants []string
+	saw := func(ctx context.Context) {
+		mu.Lock()
+		defer mu.Unlock()
+		tenants = append(tenants, TenantFromContext(ctx))
+	}
+
+	interceptors := []grpc.UnaryClientInterceptor{otgrpc.OpenTracingClientInterceptor(TenantTracer(tracer))}
+	if forward {
+		interceptors = append(interceptors, TenantUnaryClientInterceptor)
+	}
+	geo := serveTracedHop(t, TenantTracer(tracer), func(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+		saw(ctx)
+		return &healthpb.HealthCheckResponse{}, nil
+	}, interceptors...)
+	search := serveTracedHop(t, TenantTracer(tracer), func(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+		saw(ctx)
+		// a database call of the hop
+		span, _ := opentracing.StartSpanFromContextWithTracer(ctx, TenantTracer(tracer), "mongo_find")
+		span.Finish()
+		return geo.Check(ctx, req)
+	}, otgrpc.OpenTracingClientInterceptor(caller))
+	if _, err := search.Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	return tenants, tracer.FinishedSpans()
+}
+
+func TestTenantAcrossHops(t *testing.T) {
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		forward bool
+		want    string
+	}{
+		{"named", metadata.AppendToOutgoingContext(context.Background(), TenantKey, "loadgen-1"), true, "loadgen-1"},
+		{"in baggage only", metadata.AppendToOutgoingContext(context.Background(), TenantKey, "loadgen-1"), false, "loadgen-1"},
+		{"unnamed", context.Background(), true, UnknownTenant},
+	}
+	for _, tt := range tests {
+		tenants, spans := callTwoHops(t, tt.ctx, tt.forward)
+		if len(tenants) != 2 || tenants[0] != tt.want || tenants[1] != tt.want {
+			t.Errorf("%s: hops saw tenants %v, want %s", tt.name, tenants, tt.want)
+		}
+		// search and geo servers, the call to geo and the database call
+		if len(spans) != 4 {
+			t.Errorf("%s: got %d spans, want 4", tt.name, len(spans))
+		}
+		for _, span := range spans {
+			if span.Tag("tenant.id") != tt.want {
+				t.Errorf("%s: span %s tagged tenant.id=%v, want %s", tt.name, span.OperationName, span.Tag("tenant.id"), tt.want)
+			}
+		}
+	}
+}
+
+func TestTenantOfHTTPRequests(t *testing.T) {
+	for _, tt := range []struct{ header, want string }{
+		{"loadgen-1", "loadgen-1"},
+		{"", UnknownTenant},
+	} {
+		tracer := mocktracer.New()
+		var got string
+		mux := NewServeMux(tracer)
+		mux.Handle("/hotels", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = TenantFromContext(r.Context())
+		}))
+		r := httptest.NewRequest(http.MethodGet, "/hotels", nil)
+		if tt.header != "" {
+			r.Header.Set("Tenant-Id", tt.header)
+		}
+		mux.ServeHTTP(httptest.NewRecorder(), r)
+
+		if got != tt.want {
+			t.Errorf("header %q: got tenant %q, want %q", tt.header, got, tt.want)
+		}
+		spans := tracer.FinishedSpans()
+		if len(spans) != 1 || spans[0].Tag("tenant.id") != tt.want || spans[0].BaggageItem(TenantKey) != tt.want {
+			t.Errorf("header %q: got spans %v, want one tagged and carrying %s", tt.header, spans, tt.want)
+		}
+	}
+}
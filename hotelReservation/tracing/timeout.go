@@ -0,0 +1,74 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TimeoutUnaryClientInterceptor returns a client interceptor bounding each
+// call to the dependency dep, e.g. "rate", to timeout on top of the deadline
+// of ctx. Calls cut short by it fail with DeadlineExceeded and tag the span
+// in ctx with timeout.<dep>=true. A timeout of zero or less leaves calls
+// unbounded.
+func TimeoutUnaryClientInterceptor(dep string, timeout time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if timeout <= 0 {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		callCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		err := invoker(callCtx, method, req, reply, cc, opts...)
+		if err == nil || callCtx.Err() != context.DeadlineExceeded || ctx.Err() != nil {
+			return err
+		}
+		if span := opentracing.SpanFromContext(ctx); span != nil {
+			span.SetTag("timeout."+dep, true)
+		}
+		Logger(ctx).Warn().Msgf("%s: %s timed out after %v", method, dep, timeout)
+		return status.Errorf(codes.DeadlineExceeded, "%s timed out after %v", dep, timeout)
+	}
+}
+
+// MethodTimeoutUnaryServerInterceptor returns a server interceptor bounding
+// the handler of each method in defaults, keyed by full method name (e.g.
+// "/rate.Rate/GetRates"), to its timeout, when the caller sets no deadline
+// or a later one. Requests cut short by it fail with DeadlineExceeded and
+// tag the span in ctx with grpc.method_timeout=true. Handlers only stop
+// early if they give up once their context is done; methods absent from
+// defaults or with a timeout of zero or less are left unbounded.
+func MethodTimeoutUnaryServerInterceptor(defaults map[string]time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		timeout := defaults[info.FullMethod]
+		if timeout <= 0 {
+			return handler(ctx, req)
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= timeout {
+			return handler(ctx, req)
+		}
+		handlerCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		resp, err := handler(handlerCtx, req)
+		if handlerCtx.Err() != context.DeadlineExceeded || ctx.Err() != nil {
+			return resp, err
+		}
+		if span := opentracing.SpanFromContext(ctx); span != nil {
+			span.SetTag("grpc.method_timeout", true)
+		}
+		Logger(ctx).Warn().Msgf("%s: timed out after %v", info.FullMethod, timeout)
+		return nil, status.Errorf(codes.DeadlineExceeded, "%s timed out after %v", info.FullMethod, timeout)
+	}
+}
+
+// DependencyTimedOut reports whether err is a dependency call timing out
+// while ctx, the context of the caller, is still live, in which case the
+// caller may carry on without the result of the call.
+func DependencyTimedOut(ctx context.Context, err error) bool {
+	return status.Code(err) == codes.DeadlineExceeded && ctx.Err() == nil
+}
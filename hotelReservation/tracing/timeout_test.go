@@ -0,0 +1,157 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// slowInvoker answers after d, or fails with the error of its context once
+// done.
+func slowInvoker(d time.Duration) grpc.UnaryInvoker {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		select {
+		case <-time.After(d):
+			return nil
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+}
+
+func TestTimeoutUnaryClientInterceptor(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeout  time.Duration
+		call     time.Duration
+		want     codes.Code
+		timedOut bool
+	}{
+		{"fast", 100 * time.Millisecond, 0, codes.OK, false},
+		{"slow", 20 * time.Millisecond, time.Second, codes.DeadlineExceeded, true},
+		{"unbounded", 0, 50 * time.Millisecond, codes.OK, false},
+	}
+	for _, tt := range tests {
+		timeout := TimeoutUnaryClientInterceptor("rate", tt.timeout)
+		ctx, span := withMockSpan(context.Background())
+		err := timeout(ctx, "/rate.Rate/GetRates", nil, nil, nil, slowInvoker(tt.call))
+		if status.Code(err) != tt.want {
+			t.Errorf("%s: got %v, want %s", tt.name, err, tt.want)
+		}
+		if timedOut := span.Tag("timeout.rate") == true; timedOut != tt.timedOut {
+			t.Errorf("%s: tagged timed out %v, want %v", tt.name, timedOut, tt.timedOut)
+		}
+		if got := DependencyTimedOut(ctx, err); got != tt.timedOut {
+			t.Errorf("%s: DependencyTimedOut = %v, want %v", tt.name, got, tt.timedOut)
+		}
+	}
+}
+
+func TestTimeoutOfTheCaller(t *testing.T) {
+	// the request running out of time is not the dependency's doing
+	timeout := TimeoutUnaryClientInterceptor("rate", time.Second)
+	ctx, span := withMockSpan(context.Background())
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+
+	err := timeout(ctx, "/rate.Rate/GetRates", nil, nil, nil, slowInvoker(time.Second))
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("got %v, want DeadlineExceeded", err)
+	}
+	if span.Tag("timeout.rate") != nil {
+		t.Errorf("tagged the dependency timed out with the caller out of time")
+	}
+	if DependencyTimedOut(ctx, err) {
+		t.Errorf("DependencyTimedOut with the caller out of time")
+	}
+}
+
+func TestMethodTimeoutUnaryServerInterceptor(t *testing.T) {
+	timeout := MethodTimeoutUnaryServerInterceptor(map[string]time.Duration{testInfo.FullMethod: 20 * time.Millisecond})
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, slowInvoker(time.Second)(ctx, "", nil, nil, nil)
+	}
+
+	ctx, span := withMockSpan(context.Background())
+	if _, err := timeout(ctx, nil, testInfo, handler); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("got %v, want DeadlineExceeded", err)
+	}
+	if span.Tag("grpc.method_timeout") != true {
+		t.Errorf("span not tagged with the method timeout")
+	}
+
+	// other methods are unbounded
+	fast := func(ctx context.Context, req interface{}) (interface{}, error) {
+		if _, ok := ctx.Deadline(); ok {
+			t.Errorf("handler of another method has a deadline")
+		}
+		return "ok", nil
+	}
+	if res, err := timeout(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/test.Test/Other"}, fast); res != "ok" || err != nil {
+		t.Errorf("got %v, %v, want ok", res, err)
+	}
+}
+
+func TestMethodTimeoutOfCallerDeadlines(t *testing.T) {
+	timeout := MethodTimeoutUnaryServerInterceptor(map[string]time.Duration{testInfo.FullMethod: 50 * time.Millisecond})
+	tests := []struct {
+		name     string
+		deadline time.Duration
+		work     time.Duration
+		want     codes.Code
+		tagged   bool
+	}{
+		{"no deadline, quick", 0, 0, codes.OK, false},
+		{"no deadline, slow", 0, time.Second, codes.DeadlineExceeded, true},
+		{"later deadline, slow", time.Minute, time.Second, codes.DeadlineExceeded, true},
+		// the caller's own deadline is not the method timeout
+		{"earlier deadline, slow", 10 * time.Millisecond, time.Second, codes.DeadlineExceeded, false},
+	}
+	for _, tt := range tests {
+		ctx, span := withMockSpan(context.Background())
+		if tt.deadline > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, tt.deadline)
+			defer cancel()
+		}
+		var left time.Duration
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			if deadline, ok := ctx.Deadline(); ok {
+				left = time.Until(deadline)
+			}
+			return nil, slowInvoker(tt.work)(ctx, "", nil, nil, nil)
+		}
+
+		_, err := timeout(ctx, nil, testInfo, handler)
+		if status.Code(err) != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+		if got := span.Tag("grpc.method_timeout") == true; got != tt.tagged {
+			t.Errorf("%s: span tagged with the method timeout: %v, want %v", tt.name, got, tt.tagged)
+		}
+		if left <= 0 || left > 50*time.Millisecond {
+			t.Errorf("%s: handler given %v, want at most the method timeout", tt.name, left)
+		}
+	}
+}
+
+func TestMethodTimeoutOfChain(t *testing.T) {
+	t.Setenv("METHOD_TIMEOUTS", "grpc.health.v1.Health/Check=20")
+	client := serveHealth(t, func(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+		return nil, slowInvoker(time.Minute)(ctx, "", nil, nil, nil)
+	}, grpc.UnaryInterceptor(DefaultServerInterceptorChain(ServerChainOptions{Metrics: NewMetricsRegistry()})))
+
+	// a caller setting no deadline is cut short all the same
+	start := time.Now()
+	if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("got %v, want DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("call took %v, want about the 20ms of the method timeout", elapsed)
+	}
+}
@@ -1,12 +1,13 @@
 package tracing
 
 import (
+	"fmt"
 	"os"
 	"strconv"
-	"time"
 
 	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/rs/zerolog/log"
+	"github.com/uber/jaeger-client-go"
 	"github.com/uber/jaeger-client-go/config"
 )
 
@@ -14,8 +15,18 @@ var (
 	defaultSampleRatio float64 = 0.01
 )
 
-// Init returns a newly configured tracer
-func Init(serviceName, host string) (opentracing.Tracer, error) {
+// Sampler selects the Jaeger sampler of a tracer.
+type Sampler struct {
+	// Type is one of "const", "probabilistic" or "ratelimiting".
+	Type string
+	// Param is 0 or 1 for const, the sampling probability for
+	// probabilistic, and the maximum traces per second for ratelimiting.
+	Param float64
+}
+
+// DefaultSampler returns the probabilistic sampler with the ratio set by
+// JAEGER_SAMPLE_RATIO, 0.01 by default.
+func DefaultSampler() Sampler {
 	ratio := defaultSampleRatio
 	if val, ok := os.LookupEnv("JAEGER_SAMPLE_RATIO"); ok {
 		ratio, _ = strconv.ParseFloat(val, 64)
@@ -23,19 +34,83 @@ func Init(serviceName, host string) (opentracing.Tracer, error) {
 			ratio = 1.0
 		}
 	}
+	return Sampler{Type: jaeger.SamplerTypeProbabilistic, Param: ratio}
+}
+
+// ParseSampler returns the sampler of the given type and parameter, as read
+// from the service config. An empty type selects DefaultSampler.
+func ParseSampler(samplerType, param string) (Sampler, error) {
+	if samplerType == "" {
+		return DefaultSampler(), nil
+	}
+
+	p, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return Sampler{}, fmt.Errorf("invalid %s sampler param %q: %v", samplerType, param, err)
+	}
+
+	switch samplerType {
+	case jaeger.SamplerTypeConst:
+		if p != 0 && p != 1 {
+			return Sampler{}, fmt.Errorf("const sampler param must be 0 or 1, got %v", p)
+		}
+	case jaeger.SamplerTypeProbabilistic:
+		if p < 0 || p > 1 {
+			return Sampler{}, fmt.Errorf("probabilistic sampler param must be between 0 and 1, got %v", p)
+		}
+	case jaeger.SamplerTypeRateLimiting:
+		if p < 0 {
+			return Sampler{}, fmt.Errorf("ratelimiting sampler param must not be negative, got %v", p)
+		}
+	default:
+		return Sampler{}, fmt.Errorf("unknown sampler type %q, valid types are: const, probabilistic, ratelimiting", samplerType)
+	}
+	return Sampler{Type: samplerType, Param: p}, nil
+}
+
+// Init returns a newly configured tracer using DefaultSampler.
+func Init(serviceName, host string) (opentracing.Tracer, error) {
+	return InitWithSampler(serviceName, host, DefaultSampler())
+}
 
-	log.Info().Msgf("Jaeger client: adjusted sample ratio %f", ratio)
+// InitWithSampler returns a newly configured tracer using sampler and the
+// reporter of ReporterFromEnv, sending spans to the agent at host by
+// default. Jaeger tags every sampled root span with sampler.type and
+// sampler.param, and the tracer tags every span with its tenant, see
+// TenantTracer. An invalid config is an error, but if Jaeger is unreachable
+// a warning is logged and a no-op tracer returned, so that the service
+// serves untraced. With the OpenTelemetry backend selected by
+// TRACING_BACKEND, the spans are started with the global OpenTelemetry
+// tracer provider set up here instead, and the tracer returned is a no-op.
+func InitWithSampler(serviceName, host string, sampler Sampler) (opentracing.Tracer, error) {
+	reporter, err := ReporterFromEnv(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Jaeger reporter config: %v", err)
+	}
+	backend, err := ParseBackend(os.Getenv("TRACING_BACKEND"))
+	if err != nil {
+		return nil, err
+	}
+	if backend == OpenTelemetry {
+		if err := initOTel(serviceName, sampler, reporter); err != nil {
+			return nil, fmt.Errorf("invalid OpenTelemetry config: %v", err)
+		}
+		log.Info().Msgf("OpenTelemetry: %s sampler with param %v, spans exported to Jaeger", sampler.Type, sampler.Param)
+		return opentracing.NoopTracer{}, nil
+	}
+	log.Info().Msgf("Jaeger client: %s sampler with param %v", sampler.Type, sampler.Param)
+	if reporter.Mode == CollectorReporter {
+		log.Info().Msgf("Jaeger client: reporting to collector %s", reporter.CollectorEndpoint)
+	} else {
+		log.Info().Msgf("Jaeger client: reporting to agent %s", reporter.AgentHostPort)
+	}
 	tempCfg := &config.Configuration{
 		ServiceName: serviceName,
 		Sampler: &config.SamplerConfig{
-			Type:  "probabilistic",
-			Param: ratio,
-		},
-		Reporter: &config.ReporterConfig{
-			LogSpans:            false,
-			BufferFlushInterval: 1 * time.Second,
-			LocalAgentHostPort:  host,
+			Type:  sampler.Type,
+			Param: sampler.Param,
 		},
+		Reporter: reporter.reporterConfig(),
 	}
 
 	log.Info().Msg("Overriding Jaeger config with env variables")
@@ -46,7 +121,9 @@ func Init(serviceName, host string) (opentracing.Tracer, error) {
 
 	tracer, _, err := cfg.NewTracer()
 	if err != nil {
-		return nil, err
+		// Jaeger being unreachable must not take the service down with it.
+		log.Warn().Msgf("Jaeger client: failed to create tracer, serving without tracing: %v", err)
+		return opentracing.NoopTracer{}, nil
 	}
-	return tracer, nil
+	return TenantTracer(tracer), nil
 }
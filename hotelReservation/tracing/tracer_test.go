@@ -0,0 +1,164 @@
+package tracing
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-opentracing/go/otgrpc"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseSampler(t *testing.T) {
+	t.Setenv("JAEGER_SAMPLE_RATIO", "0.5")
+	tests := []struct {
+		samplerType, param string
+		want               Sampler
+	}{
+		{"", "", Sampler{Type: "probabilistic", Param: 0.5}},
+		{"const", "1", Sampler{Type: "const", Param: 1}},
+		{"const", "0", Sampler{Type: "const", Param: 0}},
+		{"probabilistic", "0.25", Sampler{Type: "probabilistic", Param: 0.25}},
+		{"ratelimiting", "100", Sampler{Type: "ratelimiting", Param: 100}},
+	}
+	for _, tt := range tests {
+		got, err := ParseSampler(tt.samplerType, tt.param)
+		if err != nil {
+			t.Errorf("ParseSampler(%q, %q): %v", tt.samplerType, tt.param, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseSampler(%q, %q) = %+v, want %+v", tt.samplerType, tt.param, got, tt.want)
+		}
+	}
+}
+
+func TestParseSamplerInvalid(t *testing.T) {
+	for _, tt := range []struct{ samplerType, param string }{
+		{"const", "0.5"},
+		{"probabilistic", "2"},
+		{"probabilistic", "-0.1"},
+		{"ratelimiting", "-1"},
+		{"ratelimiting", "many"},
+		{"remote", "1"},
+	} {
+		if _, err := ParseSampler(tt.samplerType, tt.param); err == nil {
+			t.Errorf("ParseSampler(%q, %q) succeeded, want an error", tt.samplerType, tt.param)
+		}
+	}
+}
+
+func TestInitWithSampler(t *testing.T) {
+	tests := []struct {
+		sampler Sampler
+		sampled bool
+		param   interface{}
+	}{
+		{Sampler{Type: "const", Param: 1}, true, true},
+		{Sampler{Type: "const", Param: 0}, false, nil},
+		{Sampler{Type: "probabilistic", Param: 1}, true, 1.0},
+		{Sampler{Type: "ratelimiting", Param: 100}, true, 100.0},
+	}
+	for _, tt := range tests {
+		tracer, err := InitWithSampler("test", "127.0.0.1:6831", tt.sampler)
+		if err != nil {
+			t.Fatalf("InitWithSampler(%+v): %v", tt.sampler, err)
+		}
+		span, ok := tracer.StartSpan("test").(*jaeger.Span)
+		if !ok {
+			t.Fatalf("InitWithSampler(%+v) returned a tracer of %T spans, want Jaeger ones", tt.sampler, span)
+		}
+		span.Finish()
+
+		if got := span.SpanContext().IsSampled(); got != tt.sampled {
+			t.Errorf("%s sampler with param %v: sampled %v, want %v", tt.sampler.Type, tt.sampler.Param, got, tt.sampled)
+		}
+		if !tt.sampled {
+			continue
+		}
+		tags := span.Tags()
+		if tags["sampler.type"] != tt.sampler.Type || tags["sampler.param"] != tt.param {
+			t.Errorf("%s sampler with param %v: got tags %v, want sampler.type %s and sampler.param %v",
+				tt.sampler.Type, tt.sampler.Param, tags, tt.sampler.Type, tt.param)
+		}
+	}
+}
+
+func TestInitUnreachable(t *testing.T) {
+	// an agent that can't be resolved neither blocks nor fails the tracer
+	start := time.Now()
+	tracer, err := Init("test", "no-such-host.invalid:6831")
+	if err != nil {
+		t.Fatalf("Init with an unresolvable agent: %v", err)
+	}
+	tracer.StartSpan("test").Finish()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Init with an unresolvable agent took %v", elapsed)
+	}
+
+	// an agent address the Jaeger client rejects falls back to no tracing
+	tracer, err = Init("test", "::::")
+	if err != nil {
+		t.Fatalf("Init with an invalid agent address: %v", err)
+	}
+	if _, ok := tracer.(opentracing.NoopTracer); !ok {
+		t.Errorf("Init with an invalid agent address returned a %T, want a no-op tracer", tracer)
+	}
+}
+
+func TestInterceptorsUntraced(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	tracer := opentracing.NoopTracer{}
+	srv := grpc.NewServer(grpc.UnaryInterceptor(DefaultServerInterceptorChain(ServerChainOptions{
+		Tracer:  tracer,
+		Metrics: NewMetricsRegistry(),
+	})))
+	healthpb.RegisterHealthServer(srv, healthFunc(func(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+		switch req.Service {
+		case "panic":
+			panic("bad request")
+		case "unavailable":
+			return nil, status.Error(codes.Unavailable, "down")
+		}
+		return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+	}))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure(), grpc.WithChainUnaryInterceptor(
+		otgrpc.OpenTracingClientInterceptor(tracer),
+		RequestIDUnaryClientInterceptor,
+		TenantUnaryClientInterceptor,
+		StatusTaggingUnaryClientInterceptor,
+		LatencyTaggingUnaryClientInterceptor,
+		SizeTaggingUnaryClientInterceptor,
+		CostUnaryClientInterceptor,
+		TimeoutUnaryClientInterceptor("health", time.Second),
+		RetryUnaryClientInterceptor(2, time.Millisecond, codes.Unavailable),
+	))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	ctx := ContextWithRequestID(context.Background(), "req-1")
+	res, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil || res.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("got %v, %v, want SERVING", res, err)
+	}
+	for service, want := range map[string]codes.Code{"unavailable": codes.Unavailable, "panic": codes.Internal} {
+		if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: service}); status.Code(err) != want {
+			t.Errorf("%s: got error %v, want %v", service, err, want)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails"
+	errpb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Validator checks a request, returning an InvalidArgument error naming the
+// offending field if it is invalid.
+type Validator func(req proto.Message) error
+
+// ValidationUnaryServerInterceptor returns a server interceptor running the
+// validator of each method in validators, keyed by full method name (e.g.
+// "/geo.Geo/Nearby"), before its handler. Requests failing validation never
+// reach the handler. Methods absent from validators are not validated.
+func ValidationUnaryServerInterceptor(validators map[string]Validator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		validate, ok := validators[info.FullMethod]
+		if !ok {
+			return handler(ctx, req)
+		}
+		msg, ok := req.(proto.Message)
+		if !ok {
+			return handler(ctx, req)
+		}
+		if err := validate(msg); err != nil {
+			Logger(ctx).Debug().Msgf("%s: rejecting invalid request: %v", info.FullMethod, err)
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// validatingServerStream validates the messages received from the client.
+type validatingServerStream struct {
+	grpc.ServerStream
+	method   string
+	validate Validator
+}
+
+func (s *validatingServerStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	msg, ok := m.(proto.Message)
+	if !ok {
+		return nil
+	}
+	if err := s.validate(msg); err != nil {
+		Logger(s.Context()).Debug().Msgf("%s: rejecting invalid request: %v", s.method, err)
+		return err
+	}
+	return nil
+}
+
+// ValidationStreamServerInterceptor is ValidationUnaryServerInterceptor for
+// streaming RPCs: every message received from the client is validated, and
+// the handler gets the validation error instead of an invalid message.
+func ValidationStreamServerInterceptor(validators map[string]Validator) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		validate, ok := validators[info.FullMethod]
+		if !ok {
+			return handler(srv, ss)
+		}
+		return handler(srv, &validatingServerStream{ServerStream: ss, method: info.FullMethod, validate: validate})
+	}
+}
+
+// ValidateAll returns a validator running validators in order, failing with
+// the first error.
+func ValidateAll(validators ...Validator) Validator {
+	return func(req proto.Message) error {
+		for _, validate := range validators {
+			if err := validate(req); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Required returns a validator failing if any of fields, given by proto name,
+// is blank: a string of only spaces, empty bytes or an empty list or map.
+func Required(fields ...string) Validator {
+	return func(req proto.Message) error {
+		m := req.ProtoReflect()
+		for _, name := range fields {
+			fd, err := validatedField(m, name)
+			if err != nil {
+				return err
+			}
+			v := m.Get(fd)
+			var blank bool
+			switch {
+			case fd.IsList():
+				blank = v.List().Len() == 0
+			case fd.IsMap():
+				blank = v.Map().Len() == 0
+			case fd.Kind() == protoreflect.StringKind:
+				blank = strings.TrimSpace(v.String()) == ""
+			case fd.Kind() == protoreflect.BytesKind:
+				blank = len(v.Bytes()) == 0
+			default:
+				blank = !m.Has(fd)
+			}
+			if blank {
+				return errdetails.InvalidArgument(errpb.ErrorDetail_MISSING, name, "%s must not be empty", name)
+			}
+		}
+		return nil
+	}
+}
+
+// Positive returns a validator failing if any of fields, numeric fields
+// given by proto name, isn't greater than zero, NaN included.
+func Positive(fields ...string) Validator {
+	return func(req proto.Message) error {
+		m := req.ProtoReflect()
+		for _, name := range fields {
+			fd, err := validatedField(m, name)
+			if err != nil {
+				return err
+			}
+			n, ok := numberValue(m.Get(fd), fd)
+			if !ok {
+				return status.Errorf(codes.Internal, "field %s of %s is not numeric", name, m.Descriptor().FullName())
+			}
+			if !(n > 0) {
+				return errdetails.InvalidArgument(errpb.ErrorDetail_OUT_OF_RANGE, name, "%s must be positive, got %v", name, n)
+			}
+		}
+		return nil
+	}
+}
+
+// LatLon returns a validator failing if the numeric fields lat and lon,
+// given by proto name, are outside of [-90, 90] and [-180, 180], or NaN.
+func LatLon(lat, lon string) Validator {
+	bounds := []struct {
+		name  string
+		limit float64
+	}{{lat, 90}, {lon, 180}}
+
+	return func(req proto.Message) error {
+		m := req.ProtoReflect()
+		for _, b := range bounds {
+			fd, err := validatedField(m, b.name)
+			if err != nil {
+				return err
+			}
+			n, ok := numberValue(m.Get(fd), fd)
+			if !ok {
+				return status.Errorf(codes.Internal, "field %s of %s is not numeric", b.name, m.Descriptor().FullName())
+			}
+			if !(n >= -b.limit && n <= b.limit) {
+				return errdetails.InvalidArgument(errpb.ErrorDetail_OUT_OF_RANGE, b.name, "%s must be in [-%v, %v], got %v", b.name, b.limit, b.limit, n)
+			}
+		}
+		return nil
+	}
+}
+
+// validatedField returns the field of m named name, failing with Internal if
+// there is none since the validator was registered for the wrong message.
+func validatedField(m protoreflect.Message, name string) (protoreflect.FieldDescriptor, error) {
+	fd := m.Descriptor().Fields().ByName(protoreflect.Name(name))
+	if fd == nil {
+		return nil, status.Errorf(codes.Internal, "%s has no field %s to validate", m.Descriptor().FullName(), name)
+	}
+	return fd, nil
+}
+
+// numberValue returns v as a float64 if fd is a singular numeric field.
+func numberValue(v protoreflect.Value, fd protoreflect.FieldDescriptor) (float64, bool) {
+	if fd.IsList() || fd.IsMap() {
+		return 0, false
+	}
+	switch fd.Kind() {
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return float64(v.Int()), true
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return float64(v.Uint()), true
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return v.Float(), true
+	}
+	return 0, false
+}
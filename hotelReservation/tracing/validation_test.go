@@ -0,0 +1,165 @@
+package tracing
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails"
+	errpb "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/errdetails/proto"
+	geo "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/geo/proto"
+	profile "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/profile/proto"
+	user "github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/services/user/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// checkValid checks that validate accepts req if field is empty, and
+// otherwise rejects it with InvalidArgument naming field.
+func checkValid(t *testing.T, validate Validator, req proto.Message, field string) {
+	t.Helper()
+	err := validate(req)
+	if field == "" {
+		if err != nil {
+			t.Errorf("%v: %v, want it valid", req, err)
+		}
+		return
+	}
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("%v: got %v, want InvalidArgument", req, err)
+		return
+	}
+	if details := errdetails.FromError(err); len(details) != 1 || details[0].Field != field {
+		t.Errorf("%v: got details %v, want field %s", req, details, field)
+	}
+}
+
+func TestRequired(t *testing.T) {
+	validate := Required("username", "password")
+	checkValid(t, validate, &user.Request{Username: "Cornell_1", Password: "secret"}, "")
+	checkValid(t, validate, &user.Request{Password: "secret"}, "username")
+	checkValid(t, validate, &user.Request{Username: "  ", Password: "secret"}, "username")
+	checkValid(t, validate, &user.Request{Username: "Cornell_1"}, "password")
+
+	hotels := Required("hotelIds")
+	checkValid(t, hotels, &profile.Request{HotelIds: []string{"1"}}, "")
+	checkValid(t, hotels, &profile.Request{}, "hotelIds")
+}
+
+func TestPositive(t *testing.T) {
+	validate := Positive("k")
+	checkValid(t, validate, &geo.NearestRequest{K: 1}, "")
+	checkValid(t, validate, &geo.NearestRequest{K: 0}, "k")
+	checkValid(t, validate, &geo.NearestRequest{K: -3}, "k")
+
+	lat := Positive("lat")
+	checkValid(t, lat, &geo.Request{Lat: 0.5}, "")
+	checkValid(t, lat, &geo.Request{Lat: float32(math.NaN())}, "lat")
+}
+
+func TestLatLon(t *testing.T) {
+	validate := LatLon("lat", "lon")
+	for _, tt := range []struct {
+		lat, lon float32
+		field    string
+	}{
+		{37.7, -122.4, ""},
+		{90, 180, ""},
+		{-90, -180, ""},
+		{90.1, 0, "lat"},
+		{-91, 0, "lat"},
+		{0, 180.5, "lon"},
+		{0, -181, "lon"},
+		{float32(math.NaN()), 0, "lat"},
+		{0, float32(math.Inf(1)), "lon"},
+	} {
+		checkValid(t, validate, &geo.Request{Lat: tt.lat, Lon: tt.lon}, tt.field)
+	}
+}
+
+func TestValidateAll(t *testing.T) {
+	validate := ValidateAll(LatLon("lat", "lon"), Positive("k"))
+	checkValid(t, validate, &geo.NearestRequest{Lat: 37.7, Lon: -122.4, K: 5}, "")
+	// the first failure is reported
+	checkValid(t, validate, &geo.NearestRequest{Lat: 100, K: 0}, "lat")
+	checkValid(t, validate, &geo.NearestRequest{Lat: 37.7, K: 0}, "k")
+}
+
+func TestValidatorOfWrongMessage(t *testing.T) {
+	if err := Required("username")(&geo.Request{}); status.Code(err) != codes.Internal {
+		t.Errorf("got %v validating a missing field, want Internal", err)
+	}
+	if err := Positive("hotelIds")(&profile.Request{}); status.Code(err) != codes.Internal {
+		t.Errorf("got %v validating a list as a number, want Internal", err)
+	}
+}
+
+func TestValidationUnaryServerInterceptor(t *testing.T) {
+	// a custom validator, registered alongside the built-in ones
+	locale := func(req proto.Message) error {
+		if l := req.(*profile.Request).Locale; l != "" && l != "en" && l != "fr" {
+			return errdetails.InvalidArgument(errpb.ErrorDetail_UNSUPPORTED, "locale", "unsupported locale %q", l)
+		}
+		return nil
+	}
+	validate := ValidationUnaryServerInterceptor(map[string]Validator{
+		"/geo.Geo/Nearby":              LatLon("lat", "lon"),
+		"/profile.Profile/GetProfiles": ValidateAll(Required("hotelIds"), locale),
+	})
+
+	for _, tt := range []struct {
+		method string
+		req    proto.Message
+		field  string
+	}{
+		{"/geo.Geo/Nearby", &geo.Request{Lat: 37.7, Lon: -122.4}, ""},
+		{"/geo.Geo/Nearby", &geo.Request{Lat: 137.7, Lon: -122.4}, "lat"},
+		{"/profile.Profile/GetProfiles", &profile.Request{HotelIds: []string{"1"}, Locale: "fr"}, ""},
+		{"/profile.Profile/GetProfiles", &profile.Request{Locale: "fr"}, "hotelIds"},
+		{"/profile.Profile/GetProfiles", &profile.Request{HotelIds: []string{"1"}, Locale: "xx"}, "locale"},
+		// methods without a validator aren't validated
+		{"/geo.Geo/NearbyBox", &geo.Request{Lat: 137.7}, ""},
+	} {
+		called := false
+		_, err := validate(context.Background(), tt.req, &grpc.UnaryServerInfo{FullMethod: tt.method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			called = true
+			return nil, nil
+		})
+		if called != (tt.field == "") {
+			t.Errorf("%s %v: handler called %v", tt.method, tt.req, called)
+		}
+		if tt.field == "" {
+			if err != nil {
+				t.Errorf("%s %v: %v", tt.method, tt.req, err)
+			}
+			continue
+		}
+		if details := errdetails.FromError(err); status.Code(err) != codes.InvalidArgument || len(details) != 1 || details[0].Field != tt.field {
+			t.Errorf("%s %v: got %v, want InvalidArgument on %s", tt.method, tt.req, err, tt.field)
+		}
+	}
+}
+
+func TestValidationStreamServerInterceptor(t *testing.T) {
+	validate := ValidationStreamServerInterceptor(map[string]Validator{"/geo.Geo/Watch": LatLon("lat", "lon")})
+	ss := &fakeServerStream{ctx: context.Background(), reqs: []proto.Message{
+		&geo.Request{Lat: 37.7, Lon: -122.4},
+		&geo.Request{Lat: 37.7, Lon: -222.4},
+	}}
+
+	var errs []error
+	validate(nil, ss, &grpc.StreamServerInfo{FullMethod: "/geo.Geo/Watch"}, func(srv interface{}, stream grpc.ServerStream) error {
+		for i := 0; i < 2; i++ {
+			errs = append(errs, stream.RecvMsg(new(geo.Request)))
+		}
+		return nil
+	})
+	if errs[0] != nil {
+		t.Errorf("valid message: %v", errs[0])
+	}
+	if status.Code(errs[1]) != codes.InvalidArgument {
+		t.Errorf("invalid message: got %v, want InvalidArgument", errs[1])
+	}
+}
@@ -0,0 +1,69 @@
+package tune
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+const (
+	// minPingBackoff and maxPingBackoff bound the wait between two pings
+	// of mongo at startup.
+	minPingBackoff = 100 * time.Millisecond
+	maxPingBackoff = 5 * time.Second
+)
+
+// NewMongoClient connects to the mongo server at uri with the pool settings
+// of the environment. A service creates one client at startup and shares it
+// between all its requests, which borrow connections from its pool.
+//
+// Mongo is pinged until it answers or the startup timeout expires, so that
+// services starting before their database do not crash.
+func NewMongoClient(uri string) (*mongo.Client, error) {
+	opts := options.Client().ApplyURI(uri).
+		SetMaxPoolSize(uint64(GetMongoMaxPoolSize())).
+		SetMinPoolSize(uint64(GetMongoMinPoolSize())).
+		SetConnectTimeout(time.Second * time.Duration(GetMongoConnectTimeout()))
+
+	client, err := mongo.Connect(context.TODO(), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := time.Second * time.Duration(GetMongoStartupTimeout())
+	if err := pingWithRetry(client.Ping, timeout); err != nil {
+		client.Disconnect(context.TODO())
+		return nil, fmt.Errorf("mongo at %s is unreachable: %v", uri, err)
+	}
+	return client, nil
+}
+
+// pingWithRetry calls ping until it succeeds, backing off exponentially
+// between attempts, and gives up with the last error after timeout.
+func pingWithRetry(ping func(context.Context, *readpref.ReadPref) error, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	backoff := minPingBackoff
+	for {
+		ctx, cancel := context.WithDeadline(context.Background(), deadline)
+		err := ping(ctx, nil)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if time.Now().Add(backoff).After(deadline) {
+			return err
+		}
+		log.Warn().Msgf("Mongo is not ready, retrying in %v: %v", backoff, err)
+		time.Sleep(backoff)
+
+		backoff *= 2
+		if backoff > maxPingBackoff {
+			backoff = maxPingBackoff
+		}
+	}
+}
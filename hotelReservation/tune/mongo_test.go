@@ -0,0 +1,166 @@
+package tune
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/inproc/fakestore"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// flakyPing returns a ping failing the first fails times, counting calls.
+func flakyPing(fails int, calls *int) func(context.Context, *readpref.ReadPref) error {
+	return func(context.Context, *readpref.ReadPref) error {
+		*calls++
+		if *calls <= fails {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+}
+
+func TestPingWithRetry(t *testing.T) {
+	var calls int
+	if err := pingWithRetry(flakyPing(3, &calls), 10*time.Second); err != nil {
+		t.Fatalf("got %v, want mongo reached", err)
+	}
+	if calls != 4 {
+		t.Errorf("pinged %d times, want 4", calls)
+	}
+}
+
+func TestPingWithRetryGivesUp(t *testing.T) {
+	var calls int
+	start := time.Now()
+	err := pingWithRetry(flakyPing(1000, &calls), 500*time.Millisecond)
+	if err == nil {
+		t.Fatal("got no error from an unreachable mongo")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("gave up after %v, want about the timeout", elapsed)
+	}
+	if calls < 2 {
+		t.Errorf("pinged %d times, want retries", calls)
+	}
+}
+
+// freeAddr returns an address nothing listens on yet.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := lis.Addr().String()
+	lis.Close()
+	return addr
+}
+
+func TestNewMongoClientWaitsForMongo(t *testing.T) {
+	t.Setenv("MONGO_STARTUP_TIMEOUT", "10")
+	addr := freeAddr(t)
+
+	// mongo comes up after the service
+	started := make(chan *fakestore.MongoServer)
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		srv, err := fakestore.NewMongoServer(addr)
+		if err != nil {
+			t.Error(err)
+		}
+		started <- srv
+	}()
+
+	client, err := NewMongoClient("mongodb://" + addr)
+	srv := <-started
+	if srv != nil {
+		defer srv.Close()
+	}
+	if err != nil {
+		t.Fatalf("NewMongoClient: %v", err)
+	}
+	client.Disconnect(context.Background())
+}
+
+// countingProxy forwards connections to addr, counting them.
+type countingProxy struct {
+	lis   net.Listener
+	conns int32
+}
+
+func newCountingProxy(t *testing.T, addr string) *countingProxy {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lis.Close() })
+	p := &countingProxy{lis: lis}
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&p.conns, 1)
+			backend, err := net.Dial("tcp", addr)
+			if err != nil {
+				conn.Close()
+				continue
+			}
+			go func() {
+				io.Copy(backend, conn)
+				backend.Close()
+			}()
+			go func() {
+				io.Copy(conn, backend)
+				conn.Close()
+			}()
+		}
+	}()
+	return p
+}
+
+func TestNewMongoClientReusesConnections(t *testing.T) {
+	t.Setenv("MONGO_MAX_POOL_SIZE", "4")
+	srv, err := fakestore.NewMongoServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	proxy := newCountingProxy(t, srv.Addr())
+
+	client, err := NewMongoClient("mongodb://" + proxy.lis.Addr().String())
+	if err != nil {
+		t.Fatalf("NewMongoClient: %v", err)
+	}
+	defer client.Disconnect(context.Background())
+
+	coll := client.Database("test").Collection("hotels")
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if _, err := coll.InsertOne(context.Background(), bson.M{"id": i*10 + j}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// the pool's connections, and those of the driver's monitors
+	if n := atomic.LoadInt32(&proxy.conns); n > 4+2 {
+		t.Errorf("opened %d connections for 500 queries, want at most the pool size of 4 and the monitors'", n)
+	}
+}
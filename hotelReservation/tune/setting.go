@@ -1,6 +1,8 @@
 package tune
 
 import (
+	"io"
+	"math"
 	"os"
 	"runtime/debug"
 	"strconv"
@@ -8,15 +10,83 @@ import (
 	"time"
 
 	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/delimitrou/DeathStarBench/tree/master/hotelReservation/memcring"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
 var (
-	defaultGCPercent        int    = 100
-	defaultMemCTimeout      int    = 2
-	defaultMemCMaxIdleConns int    = 512
-	defaultLogLevel         string = "info"
+	defaultGCPercent        int     = 100
+	defaultMemCTimeout      int     = 2
+	defaultMemCTTL          int     = 0
+	defaultMemCNegativeTTL  int     = 10
+	defaultBloomFPRate      float64 = 0.01
+	defaultBloomRefresh     int     = 60
+	defaultFreeCancelHours  int     = 24
+	defaultIdempotencyHours int     = 24
+	defaultBcryptCost       int     = 10
+	defaultMinPasswordLen   int     = 8
+	defaultHealthInterval   int     = 5
+	defaultShutdownTimeout  int     = 20
+	defaultShutdownReport   string  = ""
+	defaultGrpcPoolSize     int     = 1
+	defaultMemCProbeSeconds int     = 1
+	defaultMemCMaxIdleConns int     = 512
+	defaultMongoMaxPool     int     = 100
+	defaultMongoMinPool     int     = 0
+	defaultMongoConnTimeout int     = 10
+	defaultMongoStartup     int     = 60
+	defaultConsulRefresh    int     = 5
+	defaultRequestBudgetMs  int     = 10000
+	defaultBudgetWarnPct    int     = 10
+	defaultCompressBytes    int     = 1024
+	defaultDatagenHotels    int     = 0
+	defaultDatagenSeed      int64   = 1
+	defaultGeoReloadSeconds int     = 0
+	defaultAdminAllowlist   string  = "127.0.0.1,::1"
+	defaultReserveShards    int     = 1
+	defaultTraceSampleToken string  = ""
+	defaultSearchCacheSize  int     = 1024
+	defaultSearchCacheTTLMs int     = 0
+	defaultCachePrecision   int     = 4
+	defaultSearchMissing    string  = "drop"
+	defaultRecommendMemo    int     = 1024
+	defaultRecommendMemoMs  int     = 0
+	defaultRegisterAttempts int     = 10
+	defaultRegisterTimeout  int     = 60
+	defaultServiceWeight    int     = 1
+	defaultRoomType         string  = "standard"
+	defaultDepTimeoutMs     int     = 0
+	defaultWarmup           string  = "off"
+	defaultWarmupConcur     int     = 8
+	defaultMongoFanout      int     = 8
+	defaultGrpcReflection   bool    = true
+	defaultMaxRecvMsgBytes  int     = 4 << 20
+	defaultMaxSendMsgBytes  int     = math.MaxInt32
+	defaultHedgeDelayMs     int     = 0
+	defaultMaxHedges        int     = 1
+	defaultKeepaliveTimeMs  int     = 30000
+	defaultKeepaliveWaitMs  int     = 20000
+	defaultKeepaliveIdle    bool    = true
+	defaultBackoffBaseMs    int     = 1000
+	defaultBackoffMaxMs     int     = 5000
+	defaultConnectTimeoutMs int     = 5000
+	defaultFlagsFile        string  = ""
+	defaultFlagsPollMs      int     = 5000
+	defaultDeadlineWarnMs   int     = 0
+	defaultRetryAttempts    int     = 3
+	defaultRetryBackoffMs   int     = 25
+	defaultMaxInflight      int     = 0
+	defaultDebugPayload     string  = ""
+	defaultRateLimits       string  = ""
+	defaultBreakerFailures  int     = 0
+	defaultBreakerCoolMs    int     = 1000
+	defaultSlowRequestMs    int     = 1000
+	defaultMethodTimeouts   string  = ""
+	defaultRatingTTL        int     = 60
+	defaultRatingRefresh    int     = 60
+	defaultLogLevel         string  = "info"
+	defaultLogFormat        string  = "json"
 )
 
 func setGCPercent() {
@@ -52,6 +122,34 @@ func setLogLevel() {
 	log.Info().Msgf("Set global log level: %s", logLevel)
 }
 
+// NewLogger returns a logger writing to w in the format set by LOG_FORMAT:
+// one JSON object per line with "json", the default, or human-readable lines
+// with "console". Lines carry their timestamp and caller in both formats.
+func NewLogger(w io.Writer) zerolog.Logger {
+	if logFormat() == "console" {
+		w = zerolog.ConsoleWriter{Out: w, TimeFormat: time.RFC3339}
+	}
+	return zerolog.New(w).With().Timestamp().Caller().Logger()
+}
+
+func logFormat() string {
+	format := defaultLogFormat
+	if val, ok := os.LookupEnv("LOG_FORMAT"); ok {
+		format = strings.ToLower(val)
+	}
+	if format != "console" {
+		format = "json"
+	}
+	return format
+}
+
+// setLogger makes the global logger, which the services and their
+// interceptors log through, write to stdout in the LOG_FORMAT format.
+func setLogger() {
+	log.Logger = NewLogger(os.Stdout)
+	log.Info().Msgf("Tune: setLogger to %s format", logFormat())
+}
+
 func GetMemCTimeout() int {
 	timeout := defaultMemCTimeout
 	if val, ok := os.LookupEnv("MEMC_TIMEOUT"); ok {
@@ -61,39 +159,939 @@ func GetMemCTimeout() int {
 	return timeout
 }
 
+// GetMemCTTL returns the expiration in seconds of cached results. Zero means
+// cached results never expire.
+func GetMemCTTL() int {
+	ttl := defaultMemCTTL
+	if val, ok := os.LookupEnv("MEMC_TTL"); ok {
+		ttl, _ = strconv.Atoi(val)
+	}
+	log.Info().Msgf("Tune: GetMemCTTL %d", ttl)
+	return ttl
+}
+
+// GetMemCNegativeTTL returns the expiration in seconds of cached lookup
+// misses. Zero disables negative caching.
+func GetMemCNegativeTTL() int {
+	ttl := defaultMemCNegativeTTL
+	if val, ok := os.LookupEnv("MEMC_NEGATIVE_TTL"); ok {
+		ttl, _ = strconv.Atoi(val)
+	}
+	log.Info().Msgf("Tune: GetMemCNegativeTTL %d", ttl)
+	return ttl
+}
+
+// GetBloomFPRate returns the false positive rate of the bloom filter of
+// hotel IDs of the profile service. Zero disables it.
+func GetBloomFPRate() float64 {
+	rate := defaultBloomFPRate
+	if val, ok := os.LookupEnv("PROFILE_BLOOM_FP_RATE"); ok {
+		rate, _ = strconv.ParseFloat(val, 64)
+	}
+	if rate < 0 || rate >= 1 {
+		rate = defaultBloomFPRate
+	}
+	log.Info().Msgf("Tune: GetBloomFPRate %v", rate)
+	return rate
+}
+
+// GetBloomRefresh returns how often in seconds the profile service rebuilds
+// its bloom filter of hotel IDs. Zero only builds it at startup.
+func GetBloomRefresh() int {
+	interval := defaultBloomRefresh
+	if val, ok := os.LookupEnv("PROFILE_BLOOM_REFRESH"); ok {
+		interval, _ = strconv.Atoi(val)
+	}
+	if interval < 0 {
+		interval = defaultBloomRefresh
+	}
+	log.Info().Msgf("Tune: GetBloomRefresh %d", interval)
+	return interval
+}
+
+// GetFreeCancellationHours returns how many hours before check-in a
+// reservation can still be cancelled for free.
+func GetFreeCancellationHours() int {
+	hours := defaultFreeCancelHours
+	if val, ok := os.LookupEnv("FREE_CANCELLATION_HOURS"); ok {
+		hours, _ = strconv.Atoi(val)
+	}
+	log.Info().Msgf("Tune: GetFreeCancellationHours %d", hours)
+	return hours
+}
+
+// GetIdempotencyHours returns how many hours the idempotency key of a
+// reservation keeps replaying it before it can be reused.
+func GetIdempotencyHours() int {
+	hours := defaultIdempotencyHours
+	if val, ok := os.LookupEnv("IDEMPOTENCY_HOURS"); ok {
+		hours, _ = strconv.Atoi(val)
+	}
+	log.Info().Msgf("Tune: GetIdempotencyHours %d", hours)
+	return hours
+}
+
+// GetReservationShards returns the number of shards the reservations are
+// spread over by hotel, 1 if less.
+func GetReservationShards() int {
+	shards := defaultReserveShards
+	if val, ok := os.LookupEnv("RESERVATION_SHARDS"); ok {
+		shards, _ = strconv.Atoi(val)
+	}
+	if shards < 1 {
+		shards = 1
+	}
+	log.Info().Msgf("Tune: GetReservationShards %d", shards)
+	return shards
+}
+
+// GetBcryptCost returns the bcrypt cost of new password hashes.
+func GetBcryptCost() int {
+	cost := defaultBcryptCost
+	if val, ok := os.LookupEnv("BCRYPT_COST"); ok {
+		cost, _ = strconv.Atoi(val)
+	}
+	log.Info().Msgf("Tune: GetBcryptCost %d", cost)
+	return cost
+}
+
+// GetMinPasswordLength returns the minimum length in characters of the
+// passwords of new users.
+func GetMinPasswordLength() int {
+	length := defaultMinPasswordLen
+	if val, ok := os.LookupEnv("MIN_PASSWORD_LENGTH"); ok {
+		length, _ = strconv.Atoi(val)
+	}
+	log.Info().Msgf("Tune: GetMinPasswordLength %d", length)
+	return length
+}
+
+// GetHealthCheckInterval returns how often in seconds services probe their
+// dependencies for health checks.
+func GetHealthCheckInterval() int {
+	interval := defaultHealthInterval
+	if val, ok := os.LookupEnv("HEALTH_CHECK_INTERVAL"); ok {
+		interval, _ = strconv.Atoi(val)
+	}
+	if interval <= 0 {
+		interval = defaultHealthInterval
+	}
+	log.Info().Msgf("Tune: GetHealthCheckInterval %d", interval)
+	return interval
+}
+
+// GetShutdownTimeout returns how long in seconds services wait for
+// in-flight requests to finish when shutting down.
+func GetShutdownTimeout() int {
+	timeout := defaultShutdownTimeout
+	if val, ok := os.LookupEnv("SHUTDOWN_TIMEOUT"); ok {
+		timeout, _ = strconv.Atoi(val)
+	}
+	log.Info().Msgf("Tune: GetShutdownTimeout %d", timeout)
+	return timeout
+}
+
+// GetShutdownReportDir returns the directory the services write the summary
+// of the traffic they served to when they shut down, none if empty.
+func GetShutdownReportDir() string {
+	dir := defaultShutdownReport
+	if val, ok := os.LookupEnv("SHUTDOWN_REPORT_DIR"); ok {
+		dir = val
+	}
+	log.Info().Msgf("Tune: GetShutdownReportDir %q", dir)
+	return dir
+}
+
+// GetGrpcPoolSize returns how many connections the frontend opens to each
+// backend service.
+func GetGrpcPoolSize() int {
+	size := defaultGrpcPoolSize
+	if val, ok := os.LookupEnv("GRPC_POOL_SIZE"); ok {
+		size, _ = strconv.Atoi(val)
+	}
+	if size < 1 {
+		size = defaultGrpcPoolSize
+	}
+	log.Info().Msgf("Tune: GetGrpcPoolSize %d", size)
+	return size
+}
+
+// GetMemCProbeInterval returns how often in seconds memcached servers are
+// probed, to take dead ones off the hash ring and put them back.
+func GetMemCProbeInterval() int {
+	interval := defaultMemCProbeSeconds
+	if val, ok := os.LookupEnv("MEMC_PROBE_INTERVAL"); ok {
+		interval, _ = strconv.Atoi(val)
+	}
+	if interval <= 0 {
+		interval = defaultMemCProbeSeconds
+	}
+	log.Info().Msgf("Tune: GetMemCProbeInterval %d", interval)
+	return interval
+}
+
+// GetMongoMaxPoolSize returns the maximum number of connections each
+// service keeps open to mongo.
+func GetMongoMaxPoolSize() int {
+	size := defaultMongoMaxPool
+	if val, ok := os.LookupEnv("MONGO_MAX_POOL_SIZE"); ok {
+		size, _ = strconv.Atoi(val)
+	}
+	if size < 0 {
+		size = defaultMongoMaxPool
+	}
+	log.Info().Msgf("Tune: GetMongoMaxPoolSize %d", size)
+	return size
+}
+
+// GetMongoMinPoolSize returns the number of connections each service keeps
+// open to mongo even when idle.
+func GetMongoMinPoolSize() int {
+	size := defaultMongoMinPool
+	if val, ok := os.LookupEnv("MONGO_MIN_POOL_SIZE"); ok {
+		size, _ = strconv.Atoi(val)
+	}
+	if size < 0 {
+		size = defaultMongoMinPool
+	}
+	log.Info().Msgf("Tune: GetMongoMinPoolSize %d", size)
+	return size
+}
+
+// GetMongoConnectTimeout returns the timeout in seconds of opening a
+// connection to mongo.
+func GetMongoConnectTimeout() int {
+	timeout := defaultMongoConnTimeout
+	if val, ok := os.LookupEnv("MONGO_CONNECT_TIMEOUT"); ok {
+		timeout, _ = strconv.Atoi(val)
+	}
+	if timeout <= 0 {
+		timeout = defaultMongoConnTimeout
+	}
+	log.Info().Msgf("Tune: GetMongoConnectTimeout %d", timeout)
+	return timeout
+}
+
+// GetMongoStartupTimeout returns how long in seconds services retry
+// reaching mongo when starting up.
+func GetMongoStartupTimeout() int {
+	timeout := defaultMongoStartup
+	if val, ok := os.LookupEnv("MONGO_STARTUP_TIMEOUT"); ok {
+		timeout, _ = strconv.Atoi(val)
+	}
+	if timeout <= 0 {
+		timeout = defaultMongoStartup
+	}
+	log.Info().Msgf("Tune: GetMongoStartupTimeout %d", timeout)
+	return timeout
+}
+
+// GetConsulRefreshInterval returns how often in seconds clients list the
+// instances of the services they call from Consul, at the latest.
+func GetConsulRefreshInterval() int {
+	interval := defaultConsulRefresh
+	if val, ok := os.LookupEnv("CONSUL_REFRESH_INTERVAL"); ok {
+		interval, _ = strconv.Atoi(val)
+	}
+	if interval <= 0 {
+		interval = defaultConsulRefresh
+	}
+	log.Info().Msgf("Tune: GetConsulRefreshInterval %d", interval)
+	return interval
+}
+
+// GetRequestBudget returns the deadline in milliseconds the frontend sets on
+// each request, shared by all the downstream calls it makes. Zero leaves
+// requests unbounded.
+func GetRequestBudget() int {
+	budget := defaultRequestBudgetMs
+	if val, ok := os.LookupEnv("REQUEST_BUDGET_MS"); ok {
+		budget, _ = strconv.Atoi(val)
+	}
+	log.Info().Msgf("Tune: GetRequestBudget %d", budget)
+	return budget
+}
+
+// GetBudgetWarnPercent returns the share in percent of the request budget
+// under which downstream calls are reported as starved.
+func GetBudgetWarnPercent() int {
+	pct := defaultBudgetWarnPct
+	if val, ok := os.LookupEnv("BUDGET_WARN_PERCENT"); ok {
+		pct, _ = strconv.Atoi(val)
+	}
+	if pct < 0 || pct > 100 {
+		pct = defaultBudgetWarnPct
+	}
+	log.Info().Msgf("Tune: GetBudgetWarnPercent %d", pct)
+	return pct
+}
+
+// GetCompressThreshold returns the size in bytes from which services send
+// their responses gzip-compressed.
+func GetCompressThreshold() int {
+	threshold := defaultCompressBytes
+	if val, ok := os.LookupEnv("GRPC_COMPRESS_THRESHOLD"); ok {
+		threshold, _ = strconv.Atoi(val)
+	}
+	log.Info().Msgf("Tune: GetCompressThreshold %d", threshold)
+	return threshold
+}
+
+// GetDatagenHotels returns the number of hotels services seed their database
+// with, generated by the datagen package. Zero keeps the built-in dataset.
+func GetDatagenHotels() int {
+	hotels := defaultDatagenHotels
+	if val, ok := os.LookupEnv("DATAGEN_HOTELS"); ok {
+		hotels, _ = strconv.Atoi(val)
+	}
+	log.Info().Msgf("Tune: GetDatagenHotels %d", hotels)
+	return hotels
+}
+
+// GetDatagenSeed returns the seed of the dataset generated when
+// GetDatagenHotels is non-zero. Services must share it to agree on hotels.
+func GetDatagenSeed() int64 {
+	seed := defaultDatagenSeed
+	if val, ok := os.LookupEnv("DATAGEN_SEED"); ok {
+		seed, _ = strconv.ParseInt(val, 10, 64)
+	}
+	log.Info().Msgf("Tune: GetDatagenSeed %d", seed)
+	return seed
+}
+
+// GetGeoReloadInterval returns how often in seconds the geo service checks
+// its database for changed hotels, rebuilding its index if any. Zero only
+// rebuilds it on IndexReload.
+func GetGeoReloadInterval() int {
+	interval := defaultGeoReloadSeconds
+	if val, ok := os.LookupEnv("GEO_RELOAD_INTERVAL"); ok {
+		interval, _ = strconv.Atoi(val)
+	}
+	if interval < 0 {
+		interval = defaultGeoReloadSeconds
+	}
+	log.Info().Msgf("Tune: GetGeoReloadInterval %d", interval)
+	return interval
+}
+
+// GetAdminAllowlist returns the IPs and CIDRs of the callers allowed to call
+// admin RPCs.
+func GetAdminAllowlist() []string {
+	list := defaultAdminAllowlist
+	if val, ok := os.LookupEnv("ADMIN_ALLOWLIST"); ok {
+		list = val
+	}
+	log.Info().Msgf("Tune: GetAdminAllowlist %s", list)
+	return strings.Split(list, ",")
+}
+
+// GetTraceSampleTokens returns the tokens allowed to force the sampling of a
+// request's trace, none by default.
+func GetTraceSampleTokens() []string {
+	list := defaultTraceSampleToken
+	if val, ok := os.LookupEnv("TRACE_SAMPLE_TOKENS"); ok {
+		list = val
+	}
+	var tokens []string
+	for _, t := range strings.Split(list, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tokens = append(tokens, t)
+		}
+	}
+	log.Info().Msgf("Tune: GetTraceSampleTokens %d tokens", len(tokens))
+	return tokens
+}
+
+// GetSearchCacheEntries returns the number of results the search service
+// caches at most.
+func GetSearchCacheEntries() int {
+	entries := defaultSearchCacheSize
+	if val, ok := os.LookupEnv("SEARCH_CACHE_ENTRIES"); ok {
+		entries, _ = strconv.Atoi(val)
+	}
+	log.Info().Msgf("Tune: GetSearchCacheEntries %d", entries)
+	return entries
+}
+
+// GetSearchMissingData returns what the search service does with the hotels
+// missing a profile, "drop" or "partial".
+func GetSearchMissingData() string {
+	mode := defaultSearchMissing
+	if val, ok := os.LookupEnv("SEARCH_MISSING_DATA"); ok {
+		mode = strings.ToLower(val)
+	}
+	log.Info().Msgf("Tune: GetSearchMissingData %s", mode)
+	return mode
+}
+
+// GetSearchCacheTTL returns how long in milliseconds the search service
+// caches results. Zero disables the cache.
+func GetSearchCacheTTL() int {
+	ttl := defaultSearchCacheTTLMs
+	if val, ok := os.LookupEnv("SEARCH_CACHE_TTL_MS"); ok {
+		ttl, _ = strconv.Atoi(val)
+	}
+	log.Info().Msgf("Tune: GetSearchCacheTTL %d", ttl)
+	return ttl
+}
+
+// GetSearchCachePrecision returns the number of decimal places the search
+// service rounds the coordinates of cached searches to. Negative keeps them
+// exact.
+func GetSearchCachePrecision() int {
+	digits := defaultCachePrecision
+	if val, ok := os.LookupEnv("SEARCH_CACHE_PRECISION"); ok {
+		n, err := strconv.Atoi(val)
+		if err == nil {
+			digits = n
+		}
+	}
+	log.Info().Msgf("Tune: GetSearchCachePrecision %d", digits)
+	return digits
+}
+
+// GetRecommendMemoEntries returns the number of recommendations the
+// recommendation service memoizes at most.
+func GetRecommendMemoEntries() int {
+	entries := defaultRecommendMemo
+	if val, ok := os.LookupEnv("RECOMMEND_MEMO_ENTRIES"); ok {
+		entries, _ = strconv.Atoi(val)
+	}
+	log.Info().Msgf("Tune: GetRecommendMemoEntries %d", entries)
+	return entries
+}
+
+// GetRecommendMemoTTL returns how long in milliseconds the recommendation
+// service memoizes recommendations. Zero disables memoization.
+func GetRecommendMemoTTL() int {
+	ttl := defaultRecommendMemoMs
+	if val, ok := os.LookupEnv("RECOMMEND_MEMO_TTL_MS"); ok {
+		ttl, _ = strconv.Atoi(val)
+	}
+	log.Info().Msgf("Tune: GetRecommendMemoTTL %d", ttl)
+	return ttl
+}
+
+// GetRegisterAttempts returns the number of attempts services make at
+// registering in Consul at startup before failing.
+func GetRegisterAttempts() int {
+	attempts := defaultRegisterAttempts
+	if val, ok := os.LookupEnv("REGISTER_MAX_ATTEMPTS"); ok {
+		attempts, _ = strconv.Atoi(val)
+	}
+	if attempts <= 0 {
+		attempts = defaultRegisterAttempts
+	}
+	log.Info().Msgf("Tune: GetRegisterAttempts %d", attempts)
+	return attempts
+}
+
+// GetRegisterTimeout returns the time in seconds services spend at most
+// trying to register in Consul at startup.
+func GetRegisterTimeout() int {
+	timeout := defaultRegisterTimeout
+	if val, ok := os.LookupEnv("REGISTER_TIMEOUT"); ok {
+		timeout, _ = strconv.Atoi(val)
+	}
+	if timeout <= 0 {
+		timeout = defaultRegisterTimeout
+	}
+	log.Info().Msgf("Tune: GetRegisterTimeout %d", timeout)
+	return timeout
+}
+
+// GetServiceWeight returns the weight services publish in Consul, the share
+// of the RPCs of their clients they get relative to the other instances.
+func GetServiceWeight() int {
+	weight := defaultServiceWeight
+	if val, ok := os.LookupEnv("SERVICE_WEIGHT"); ok {
+		weight, _ = strconv.Atoi(val)
+	}
+	if weight <= 0 {
+		weight = defaultServiceWeight
+	}
+	log.Info().Msgf("Tune: GetServiceWeight %d", weight)
+	return weight
+}
+
+// GetDefaultRoomType returns the room type priced and booked by requests
+// that don't name one.
+func GetDefaultRoomType() string {
+	roomType := defaultRoomType
+	if val, ok := os.LookupEnv("DEFAULT_ROOM_TYPE"); ok && val != "" {
+		roomType = val
+	}
+	log.Info().Msgf("Tune: GetDefaultRoomType %s", roomType)
+	return roomType
+}
+
+// GetDependencyTimeouts returns the timeouts bounding each call to the geo,
+// rate, profile and reservation services, keyed by name. Zero leaves the
+// calls to a service unbounded.
+func GetDependencyTimeouts() map[string]time.Duration {
+	timeouts := make(map[string]time.Duration)
+	for _, dep := range []string{"geo", "rate", "profile", "reservation"} {
+		ms := defaultDepTimeoutMs
+		if val, ok := os.LookupEnv(strings.ToUpper(dep) + "_TIMEOUT_MS"); ok {
+			ms, _ = strconv.Atoi(val)
+		}
+		timeouts[dep] = time.Duration(ms) * time.Millisecond
+	}
+	log.Info().Msgf("Tune: GetDependencyTimeouts %v", timeouts)
+	return timeouts
+}
+
+// GetWarmup returns whether the profile and rate services preload their
+// cache at startup: "off", "on", or "strict" to fail to start if preloading
+// fails.
+func GetWarmup() string {
+	mode := defaultWarmup
+	if val, ok := os.LookupEnv("WARMUP"); ok {
+		mode = strings.ToLower(val)
+	}
+	log.Info().Msgf("Tune: GetWarmup %s", mode)
+	return mode
+}
+
+// GetWarmupConcurrency returns how many records the services cache at once
+// while warming up.
+func GetWarmupConcurrency() int {
+	n := defaultWarmupConcur
+	if val, ok := os.LookupEnv("WARMUP_CONCURRENCY"); ok {
+		n, _ = strconv.Atoi(val)
+	}
+	log.Info().Msgf("Tune: GetWarmupConcurrency %d", n)
+	return n
+}
+
+// GetMongoFanout returns how many mongo queries the profile and rate
+// services run at once for the cache misses of a request, 1 if less.
+func GetMongoFanout() int {
+	n := defaultMongoFanout
+	if val, ok := os.LookupEnv("MONGO_FANOUT"); ok {
+		n, _ = strconv.Atoi(val)
+	}
+	if n < 1 {
+		n = 1
+	}
+	log.Info().Msgf("Tune: GetMongoFanout %d", n)
+	return n
+}
+
+// GetGrpcReflection returns whether the backends serve the gRPC reflection
+// service, which lets grpcurl list and call their methods without the proto
+// files.
+func GetGrpcReflection() bool {
+	enabled := defaultGrpcReflection
+	if val, ok := os.LookupEnv("GRPC_REFLECTION"); ok {
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			log.Warn().Msgf("Ignoring GRPC_REFLECTION %q: %v", val, err)
+		} else {
+			enabled = b
+		}
+	}
+	log.Info().Msgf("Tune: GetGrpcReflection %v", enabled)
+	return enabled
+}
+
+// GetMaxRecvMsgSize returns the size in bytes of the largest message the
+// services receive, requests on servers and replies on clients. The default
+// is that of gRPC.
+func GetMaxRecvMsgSize() int {
+	size := defaultMaxRecvMsgBytes
+	if val, ok := os.LookupEnv("GRPC_MAX_RECV_MSG_BYTES"); ok {
+		size, _ = strconv.Atoi(val)
+	}
+	if size < 1 {
+		size = defaultMaxRecvMsgBytes
+	}
+	log.Info().Msgf("Tune: GetMaxRecvMsgSize %d", size)
+	return size
+}
+
+// GetMaxSendMsgSize returns the size in bytes of the largest message the
+// services send, replies on servers and requests on clients. The default is
+// that of gRPC, no limit.
+func GetMaxSendMsgSize() int {
+	size := defaultMaxSendMsgBytes
+	if val, ok := os.LookupEnv("GRPC_MAX_SEND_MSG_BYTES"); ok {
+		size, _ = strconv.Atoi(val)
+	}
+	if size < 1 {
+		size = defaultMaxSendMsgBytes
+	}
+	log.Info().Msgf("Tune: GetMaxSendMsgSize %d", size)
+	return size
+}
+
+// GetHedgeDelay returns the time in milliseconds after which the frontend
+// and search services send a backup request for a read-only call still
+// waiting for its reply, 0 to never hedge.
+func GetHedgeDelay() int {
+	ms := defaultHedgeDelayMs
+	if val, ok := os.LookupEnv("HEDGE_DELAY_MS"); ok {
+		ms, _ = strconv.Atoi(val)
+	}
+	if ms < 0 {
+		ms = 0
+	}
+	log.Info().Msgf("Tune: GetHedgeDelay %d", ms)
+	return ms
+}
+
+// GetMaxHedges returns the number of backup requests sent at most for a
+// read-only call.
+func GetMaxHedges() int {
+	n := defaultMaxHedges
+	if val, ok := os.LookupEnv("HEDGE_MAX"); ok {
+		n, _ = strconv.Atoi(val)
+	}
+	if n < 0 {
+		n = 0
+	}
+	log.Info().Msgf("Tune: GetMaxHedges %d", n)
+	return n
+}
+
+// GetKeepaliveTime returns the time in milliseconds after which gRPC
+// clients ping an idle connection to check it is alive, and the shortest
+// interval between pings servers accept from clients. gRPC pings every 10
+// seconds at most.
+func GetKeepaliveTime() int {
+	ms := defaultKeepaliveTimeMs
+	if val, ok := os.LookupEnv("GRPC_KEEPALIVE_TIME_MS"); ok {
+		ms, _ = strconv.Atoi(val)
+	}
+	if ms < 1 {
+		ms = defaultKeepaliveTimeMs
+	}
+	log.Info().Msgf("Tune: GetKeepaliveTime %d", ms)
+	return ms
+}
+
+// GetKeepaliveTimeout returns the time in milliseconds gRPC clients wait for
+// the reply to a ping before closing the connection.
+func GetKeepaliveTimeout() int {
+	ms := defaultKeepaliveWaitMs
+	if val, ok := os.LookupEnv("GRPC_KEEPALIVE_TIMEOUT_MS"); ok {
+		ms, _ = strconv.Atoi(val)
+	}
+	if ms < 1 {
+		ms = defaultKeepaliveWaitMs
+	}
+	log.Info().Msgf("Tune: GetKeepaliveTimeout %d", ms)
+	return ms
+}
+
+// GetKeepalivePermitWithoutStream returns whether gRPC clients ping
+// connections without calls in flight.
+func GetKeepalivePermitWithoutStream() bool {
+	permit := defaultKeepaliveIdle
+	if val, ok := os.LookupEnv("GRPC_KEEPALIVE_PERMIT_WITHOUT_STREAM"); ok {
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			log.Warn().Msgf("Ignoring GRPC_KEEPALIVE_PERMIT_WITHOUT_STREAM %q: %v", val, err)
+		} else {
+			permit = b
+		}
+	}
+	log.Info().Msgf("Tune: GetKeepalivePermitWithoutStream %v", permit)
+	return permit
+}
+
+// GetBackoffBaseDelay returns the time in milliseconds gRPC clients wait
+// before reconnecting after a first failed connection attempt, growing 1.6
+// times with each further failure.
+func GetBackoffBaseDelay() int {
+	ms := defaultBackoffBaseMs
+	if val, ok := os.LookupEnv("GRPC_BACKOFF_BASE_MS"); ok {
+		ms, _ = strconv.Atoi(val)
+	}
+	if ms < 1 {
+		ms = defaultBackoffBaseMs
+	}
+	log.Info().Msgf("Tune: GetBackoffBaseDelay %d", ms)
+	return ms
+}
+
+// GetBackoffMaxDelay returns the time in milliseconds gRPC clients wait at
+// most between connection attempts.
+func GetBackoffMaxDelay() int {
+	ms := defaultBackoffMaxMs
+	if val, ok := os.LookupEnv("GRPC_BACKOFF_MAX_MS"); ok {
+		ms, _ = strconv.Atoi(val)
+	}
+	if ms < 1 {
+		ms = defaultBackoffMaxMs
+	}
+	log.Info().Msgf("Tune: GetBackoffMaxDelay %d", ms)
+	return ms
+}
+
+// GetMinConnectTimeout returns the time in milliseconds gRPC clients give a
+// connection attempt at least to complete.
+func GetMinConnectTimeout() int {
+	ms := defaultConnectTimeoutMs
+	if val, ok := os.LookupEnv("GRPC_MIN_CONNECT_TIMEOUT_MS"); ok {
+		ms, _ = strconv.Atoi(val)
+	}
+	if ms < 1 {
+		ms = defaultConnectTimeoutMs
+	}
+	log.Info().Msgf("Tune: GetMinConnectTimeout %d", ms)
+	return ms
+}
+
+// GetFeatureFlagsFile returns the JSON file the services read their
+// feature flags from, watching it for changes, none if empty.
+func GetFeatureFlagsFile() string {
+	path := defaultFlagsFile
+	if val, ok := os.LookupEnv("FEATURE_FLAGS_FILE"); ok {
+		path = val
+	}
+	log.Info().Msgf("Tune: GetFeatureFlagsFile %q", path)
+	return path
+}
+
+// GetFeatureFlagsPollInterval returns how often in milliseconds the feature
+// flags file is checked for changes.
+func GetFeatureFlagsPollInterval() int {
+	ms := defaultFlagsPollMs
+	if val, ok := os.LookupEnv("FEATURE_FLAGS_POLL_MS"); ok {
+		ms, _ = strconv.Atoi(val)
+	}
+	if ms < 1 {
+		ms = defaultFlagsPollMs
+	}
+	log.Info().Msgf("Tune: GetFeatureFlagsPollInterval %d", ms)
+	return ms
+}
+
+// GetDeadlineWarn returns the deadline budget in milliseconds under which the
+// frontend and search services log their downstream calls.
+func GetDeadlineWarn() int {
+	ms := defaultDeadlineWarnMs
+	if val, ok := os.LookupEnv("DEADLINE_WARN_MS"); ok {
+		ms, _ = strconv.Atoi(val)
+	}
+	if ms < 0 {
+		ms = defaultDeadlineWarnMs
+	}
+	log.Info().Msgf("Tune: GetDeadlineWarn %d", ms)
+	return ms
+}
+
+// GetRetryAttempts returns the number of attempts the frontend and search
+// services make at read-only downstream calls failing with Unavailable.
+func GetRetryAttempts() int {
+	attempts := defaultRetryAttempts
+	if val, ok := os.LookupEnv("RETRY_MAX_ATTEMPTS"); ok {
+		attempts, _ = strconv.Atoi(val)
+	}
+	if attempts <= 0 {
+		attempts = defaultRetryAttempts
+	}
+	log.Info().Msgf("Tune: GetRetryAttempts %d", attempts)
+	return attempts
+}
+
+// GetRetryBackoff returns the delay in milliseconds before the first retry
+// of a downstream call, doubling for each following one.
+func GetRetryBackoff() int {
+	ms := defaultRetryBackoffMs
+	if val, ok := os.LookupEnv("RETRY_BACKOFF_MS"); ok {
+		ms, _ = strconv.Atoi(val)
+	}
+	if ms < 0 {
+		ms = defaultRetryBackoffMs
+	}
+	log.Info().Msgf("Tune: GetRetryBackoff %d", ms)
+	return ms
+}
+
+// GetMaxInflight returns the number of requests the profile and search
+// services handle at once before shedding new ones, 0 for no limit.
+func GetMaxInflight() int {
+	n := defaultMaxInflight
+	if val, ok := os.LookupEnv("MAX_INFLIGHT"); ok {
+		n, _ = strconv.Atoi(val)
+	}
+	if n < 0 {
+		n = defaultMaxInflight
+	}
+	log.Info().Msgf("Tune: GetMaxInflight %d", n)
+	return n
+}
+
+// GetDebugPayloadAllowlist returns the IPs and CIDRs of the callers allowed
+// to have the payloads of their requests logged, none by default.
+func GetDebugPayloadAllowlist() []string {
+	list := defaultDebugPayload
+	if val, ok := os.LookupEnv("DEBUG_PAYLOAD_ALLOWLIST"); ok {
+		list = val
+	}
+	var allowed []string
+	for _, a := range strings.Split(list, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			allowed = append(allowed, a)
+		}
+	}
+	log.Info().Msgf("Tune: GetDebugPayloadAllowlist %s", list)
+	return allowed
+}
+
+// GetRateLimits returns the requests per second each gRPC service allows
+// the methods of RATE_LIMITS, keyed by full method name, none by default.
+func GetRateLimits() map[string]float64 {
+	list := defaultRateLimits
+	if val, ok := os.LookupEnv("RATE_LIMITS"); ok {
+		list = val
+	}
+	limits := make(map[string]float64)
+	for method, val := range methodValues(list) {
+		if rps, err := strconv.ParseFloat(val, 64); err == nil && rps > 0 {
+			limits[method] = rps
+		}
+	}
+	log.Info().Msgf("Tune: GetRateLimits %v", limits)
+	return limits
+}
+
+// methodValues parses a comma-separated list of method=value pairs, keyed by
+// full method name: the leading slash of a method can be left out.
+func methodValues(list string) map[string]string {
+	values := make(map[string]string)
+	for _, kv := range strings.Split(list, ",") {
+		method, val, ok := strings.Cut(kv, "=")
+		method = strings.TrimSpace(method)
+		if !ok || method == "" {
+			continue
+		}
+		values["/"+strings.TrimPrefix(method, "/")] = strings.TrimSpace(val)
+	}
+	return values
+}
+
+// GetBreakerThreshold returns the number of consecutive failed calls to a
+// method of a backend after which the frontend and search services stop
+// sending it calls for a while, 0 to keep sending them.
+func GetBreakerThreshold() int {
+	n := defaultBreakerFailures
+	if val, ok := os.LookupEnv("BREAKER_THRESHOLD"); ok {
+		n, _ = strconv.Atoi(val)
+	}
+	log.Info().Msgf("Tune: GetBreakerThreshold %d", n)
+	return n
+}
+
+// GetBreakerCooldown returns the number of milliseconds an open circuit
+// breaker waits before letting a probe call through.
+func GetBreakerCooldown() int {
+	ms := defaultBreakerCoolMs
+	if val, ok := os.LookupEnv("BREAKER_COOLDOWN_MS"); ok {
+		ms, _ = strconv.Atoi(val)
+	}
+	if ms < 0 {
+		ms = defaultBreakerCoolMs
+	}
+	log.Info().Msgf("Tune: GetBreakerCooldown %d", ms)
+	return ms
+}
+
+// GetSlowRequest returns the handling time in milliseconds over which the
+// gRPC services log a request as slow, 0 to log none.
+func GetSlowRequest() int {
+	ms := defaultSlowRequestMs
+	if val, ok := os.LookupEnv("SLOW_REQUEST_MS"); ok {
+		ms, _ = strconv.Atoi(val)
+	}
+	log.Info().Msgf("Tune: GetSlowRequest %d", ms)
+	return ms
+}
+
+// GetSlowRequestMethods returns the slow request thresholds of the methods
+// of SLOW_REQUEST_METHODS, keyed by full method name, overriding
+// SLOW_REQUEST_MS.
+func GetSlowRequestMethods() map[string]time.Duration {
+	thresholds := make(map[string]time.Duration)
+	for method, val := range methodValues(os.Getenv("SLOW_REQUEST_METHODS")) {
+		if ms, err := strconv.Atoi(val); err == nil {
+			thresholds[method] = time.Duration(ms) * time.Millisecond
+		}
+	}
+	log.Info().Msgf("Tune: GetSlowRequestMethods %v", thresholds)
+	return thresholds
+}
+
+// GetMethodTimeouts returns the longest time the gRPC services give the
+// handlers of the methods of METHOD_TIMEOUTS, keyed by full method name,
+// none by default.
+func GetMethodTimeouts() map[string]time.Duration {
+	list := defaultMethodTimeouts
+	if val, ok := os.LookupEnv("METHOD_TIMEOUTS"); ok {
+		list = val
+	}
+	timeouts := make(map[string]time.Duration)
+	for method, val := range methodValues(list) {
+		if ms, err := strconv.Atoi(val); err == nil && ms > 0 {
+			timeouts[method] = time.Duration(ms) * time.Millisecond
+		}
+	}
+	log.Info().Msgf("Tune: GetMethodTimeouts %v", timeouts)
+	return timeouts
+}
+
+// GetRatingTTL returns the expiration in seconds of the aggregate ratings
+// cached by the review service, bounding how long a rating computed while a
+// review was added can stay stale.
+func GetRatingTTL() int {
+	ttl := defaultRatingTTL
+	if val, ok := os.LookupEnv("RATING_TTL"); ok {
+		ttl, _ = strconv.Atoi(val)
+	}
+	if ttl <= 0 {
+		ttl = defaultRatingTTL
+	}
+	log.Info().Msgf("Tune: GetRatingTTL %d", ttl)
+	return ttl
+}
+
+// GetRatingRefresh returns how often in seconds the recommendation service
+// rates the hotels again by their reviews. Zero keeps the ratings of the
+// recommendation database.
+func GetRatingRefresh() int {
+	interval := defaultRatingRefresh
+	if val, ok := os.LookupEnv("RATING_REFRESH"); ok {
+		interval, _ = strconv.Atoi(val)
+	}
+	log.Info().Msgf("Tune: GetRatingRefresh %d", interval)
+	return interval
+}
+
 // Hack of memcache.New to avoid 'no server error' during running
 func NewMemCClient(server ...string) *memcache.Client {
-	ss := new(memcache.ServerList)
-	err := ss.SetServers(server...)
+	ring, err := memcring.New(server, time.Second*time.Duration(GetMemCProbeInterval()))
 	if err != nil {
 		// Hack: panic early to avoid pod restart during running
 		panic(err)
 		//return nil, err
 	} else {
-		memc_client := memcache.NewFromSelector(ss)
+		memc_client := memcache.NewFromSelector(ring)
 		memc_client.Timeout = time.Second * time.Duration(GetMemCTimeout())
 		memc_client.MaxIdleConns = defaultMemCMaxIdleConns
 		return memc_client
 	}
 }
 
+// NewMemCClient2 returns a client over a comma-separated list of servers,
+// spreading keys over them with consistent hashing.
 func NewMemCClient2(servers string) *memcache.Client {
-	ss := new(memcache.ServerList)
-	server_list := strings.Split(servers, ",")
-	err := ss.SetServers(server_list...)
-	if err != nil {
-		// Hack: panic early to avoid pod restart during running
-		panic(err)
-		//return nil, err
-	} else {
-		memc_client := memcache.NewFromSelector(ss)
-		memc_client.Timeout = time.Second * time.Duration(GetMemCTimeout())
-		memc_client.MaxIdleConns = defaultMemCMaxIdleConns
-		return memc_client
-	}
+	return NewMemCClient(strings.Split(servers, ",")...)
 }
 
 func Init() {
+	setLogger()
 	setLogLevel()
 	setGCPercent()
 }
@@ -0,0 +1,105 @@
+package tune
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetRateLimits(t *testing.T) {
+	t.Setenv("RATE_LIMITS", " profile.Profile/GetProfiles=50, /rate.Rate/GetRates = 2.5,geo.Geo/Nearby=0,search.Search/Nearby=many,=3,user.User/CheckUser")
+	want := map[string]float64{
+		"/profile.Profile/GetProfiles": 50,
+		"/rate.Rate/GetRates":          2.5,
+	}
+	if got := GetRateLimits(); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetRateLimits() = %v, want %v", got, want)
+	}
+}
+
+// colors matches the color escapes of console log lines.
+var colors = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// consoleLine matches a console log line of NewLogger without its colors:
+// its RFC 3339 timestamp, level and caller.
+var consoleLine = regexp.MustCompile(`^\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\S* INF setting_test.go:\d+ > started`)
+
+func TestNewLogger(t *testing.T) {
+	for _, tt := range []struct {
+		format  string
+		console bool
+	}{
+		{"", false},
+		{"json", false},
+		{"console", true},
+		{"CONSOLE", true},
+		{"xml", false},
+	} {
+		t.Setenv("LOG_FORMAT", tt.format)
+		buf := new(bytes.Buffer)
+		logger := NewLogger(buf)
+		logger.Info().Str("service", "geo").Msg("started")
+		line := buf.String()
+
+		if tt.console {
+			line = colors.ReplaceAllString(line, "")
+			if !consoleLine.MatchString(line) || !strings.Contains(line, "service=geo") {
+				t.Errorf("LOG_FORMAT=%q: got %q, want a console line", tt.format, line)
+			}
+			continue
+		}
+		var fields map[string]interface{}
+		if err := json.Unmarshal(buf.Bytes(), &fields); err != nil {
+			t.Errorf("LOG_FORMAT=%q: got %q, want JSON: %v", tt.format, line, err)
+			continue
+		}
+		if fields["message"] != "started" || fields["service"] != "geo" || fields["level"] != "info" || fields["time"] == nil || fields["caller"] == nil {
+			t.Errorf("LOG_FORMAT=%q: got %v, want the message, fields, level, time and caller", tt.format, fields)
+		}
+	}
+}
+
+func TestGetMethodTimeouts(t *testing.T) {
+	t.Setenv("METHOD_TIMEOUTS", "rate.Rate/GetRates=250, /geo.Geo/Nearby = 100,profile.Profile/GetProfiles=0,search.Search/Nearby=soon")
+	want := map[string]time.Duration{
+		"/rate.Rate/GetRates": 250 * time.Millisecond,
+		"/geo.Geo/Nearby":     100 * time.Millisecond,
+	}
+	if got := GetMethodTimeouts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetMethodTimeouts() = %v, want %v", got, want)
+	}
+}
+
+func TestGetReconnectSettings(t *testing.T) {
+	t.Setenv("GRPC_KEEPALIVE_TIME_MS", "15000")
+	t.Setenv("GRPC_KEEPALIVE_TIMEOUT_MS", "0")
+	t.Setenv("GRPC_KEEPALIVE_PERMIT_WITHOUT_STREAM", "no")
+	t.Setenv("GRPC_BACKOFF_BASE_MS", "250")
+	t.Setenv("GRPC_BACKOFF_MAX_MS", "-1")
+	t.Setenv("GRPC_MIN_CONNECT_TIMEOUT_MS", "soon")
+
+	for _, tt := range []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"GetKeepaliveTime", GetKeepaliveTime(), 15000},
+		{"GetKeepaliveTimeout", GetKeepaliveTimeout(), defaultKeepaliveWaitMs},
+		{"GetKeepalivePermitWithoutStream", GetKeepalivePermitWithoutStream(), defaultKeepaliveIdle},
+		{"GetBackoffBaseDelay", GetBackoffBaseDelay(), 250},
+		{"GetBackoffMaxDelay", GetBackoffMaxDelay(), defaultBackoffMaxMs},
+		{"GetMinConnectTimeout", GetMinConnectTimeout(), defaultConnectTimeoutMs},
+	} {
+		if tt.got != tt.want {
+			t.Errorf("%s() = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+
+	t.Setenv("GRPC_KEEPALIVE_PERMIT_WITHOUT_STREAM", "false")
+	if GetKeepalivePermitWithoutStream() {
+		t.Error("GetKeepalivePermitWithoutStream() = true, want false")
+	}
+}
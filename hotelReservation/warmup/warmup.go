@@ -0,0 +1,76 @@
+// Package warmup preloads the cache of a service at startup, so that load
+// tests don't start with a latency spike while every lookup misses.
+package warmup
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Modes of warmup.
+const (
+	// Off starts services with a cold cache.
+	Off = "off"
+	// On warms the cache up before services report healthy, starting them
+	// anyway, with whatever was loaded, if warming up fails.
+	On = "on"
+	// Strict is On, except that services fail to start if warming up fails.
+	Strict = "strict"
+)
+
+// Config configures the warmup of a service.
+type Config struct {
+	// Mode is Off, On or Strict. Unknown modes are Off.
+	Mode string
+	// Concurrency is how many records are cached at once, 1 if less.
+	Concurrency int
+}
+
+// Enabled reports whether c warms the cache up.
+func (c Config) Enabled() bool {
+	return c.Mode == On || c.Mode == Strict
+}
+
+// Run warms up the cache of what, e.g. "profiles", with warm, which returns
+// how many records it cached. It logs the outcome and returns the failure of
+// warm in Strict mode only. It does nothing unless c is enabled.
+func (c Config) Run(what string, warm func(concurrency int) (int, error)) error {
+	if !c.Enabled() {
+		return nil
+	}
+	concurrency := c.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	start := time.Now()
+	n, err := warm(concurrency)
+	if err != nil {
+		if c.Mode == Strict {
+			return fmt.Errorf("failed to warm up %s: %v", what, err)
+		}
+		log.Warn().Msgf("Warmup: cached %d %s in %v, then failed: %v", n, what, time.Since(start), err)
+		return nil
+	}
+	log.Info().Msgf("Warmup: cached %d %s in %v", n, what, time.Since(start))
+	return nil
+}
+
+// Tally returns how many records were cached given the errors of caching
+// each, and the first of those errors.
+func Tally(errs []error) (int, error) {
+	var (
+		done     int
+		firstErr error
+	)
+	for _, err := range errs {
+		if err == nil {
+			done++
+		} else if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return done, firstErr
+}
@@ -0,0 +1,49 @@
+package warmup
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	failure := errors.New("memcached down")
+	tests := []struct {
+		name            string
+		config          Config
+		warmErr         error
+		wantWarmed      bool
+		wantConcurrency int
+		wantErr         bool
+	}{
+		{"off", Config{Mode: Off, Concurrency: 4}, nil, false, 0, false},
+		{"unknown mode", Config{Mode: "eager", Concurrency: 4}, nil, false, 0, false},
+		{"on", Config{Mode: On, Concurrency: 4}, nil, true, 4, false},
+		{"on, no concurrency", Config{Mode: On}, nil, true, 1, false},
+		{"on, failing", Config{Mode: On, Concurrency: 4}, failure, true, 4, false},
+		{"strict", Config{Mode: Strict, Concurrency: 4}, nil, true, 4, false},
+		{"strict, failing", Config{Mode: Strict, Concurrency: 4}, failure, true, 4, true},
+	}
+	for _, tt := range tests {
+		warmed, concurrency := false, 0
+		err := tt.config.Run("profiles", func(c int) (int, error) {
+			warmed, concurrency = true, c
+			return 3, tt.warmErr
+		})
+		if warmed != tt.wantWarmed || concurrency != tt.wantConcurrency {
+			t.Errorf("%s: warmed=%v with concurrency %d, want %v with %d", tt.name, warmed, concurrency, tt.wantWarmed, tt.wantConcurrency)
+		}
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: got error %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestTally(t *testing.T) {
+	first, second := errors.New("first"), errors.New("second")
+	if n, err := Tally([]error{nil, first, nil, second}); n != 2 || err != first {
+		t.Errorf("got %d, %v, want 2, first", n, err)
+	}
+	if n, err := Tally(make([]error, 3)); n != 3 || err != nil {
+		t.Errorf("got %d, %v, want 3, nil", n, err)
+	}
+}